@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"net/url"
 )
 
 type CartClient struct{ c *Client }
@@ -14,6 +15,16 @@ func (cc *CartClient) AddItem(ctx context.Context, userId, rawQuery string, body
 	return cc.c.Do(ctx, http.MethodPost, "/api/cart/"+userId+"/items", rawQuery, body, headers)
 }
 
+// AddItems adds several lines in one request; cart-service adds all of them or none.
+func (cc *CartClient) AddItems(ctx context.Context, userId, rawQuery string, body io.Reader, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodPost, "/api/cart/"+userId+"/items:batch", rawQuery, body, headers)
+}
+
+// ReplaceCart sets the cart's lines to exactly the ones in body.
+func (cc *CartClient) ReplaceCart(ctx context.Context, userId, rawQuery string, body io.Reader, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodPut, "/api/cart/"+userId, rawQuery, body, headers)
+}
+
 func (cc *CartClient) GetCart(ctx context.Context, userId, rawQuery string, headers http.Header) (*http.Response, error) {
 	return cc.c.Do(ctx, http.MethodGet, "/api/cart/"+userId, rawQuery, nil, headers)
 }
@@ -21,3 +32,40 @@ func (cc *CartClient) GetCart(ctx context.Context, userId, rawQuery string, head
 func (cc *CartClient) Checkout(ctx context.Context, userId, rawQuery string, body io.Reader, headers http.Header) (*http.Response, error) {
 	return cc.c.Do(ctx, http.MethodPost, "/api/cart/"+userId+"/checkout", rawQuery, body, headers)
 }
+
+func (cc *CartClient) UpdateItem(ctx context.Context, userId, productId, rawQuery string, body io.Reader, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodPatch, "/api/cart/"+userId+"/items/"+productId, rawQuery, body, headers)
+}
+
+func (cc *CartClient) RemoveItem(ctx context.Context, userId, productId, rawQuery string, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodDelete, "/api/cart/"+userId+"/items/"+productId, rawQuery, nil, headers)
+}
+
+func (cc *CartClient) ClearCart(ctx context.Context, userId, rawQuery string, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodDelete, "/api/cart/"+userId, rawQuery, nil, headers)
+}
+
+func (cc *CartClient) CreateGuestCart(ctx context.Context, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodPost, "/api/cart/guests", "", nil, headers)
+}
+
+func (cc *CartClient) MergeCart(ctx context.Context, userId, rawQuery string, body io.Reader, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodPost, "/api/cart/"+userId+"/merge", rawQuery, body, headers)
+}
+
+func (cc *CartClient) ApplyCoupon(ctx context.Context, userId, rawQuery string, body io.Reader, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodPost, "/api/cart/"+userId+"/coupons", rawQuery, body, headers)
+}
+
+func (cc *CartClient) SetTaxLocation(ctx context.Context, userId, rawQuery string, body io.Reader, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodPut, "/api/cart/"+userId+"/tax-location", rawQuery, body, headers)
+}
+
+func (cc *CartClient) Validate(ctx context.Context, userId, rawQuery string, body io.Reader, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodPost, "/api/cart/"+userId+"/validate", rawQuery, body, headers)
+}
+
+// RemoveCoupon escapes code, which unlike the IDs in other paths is typed in by the customer.
+func (cc *CartClient) RemoveCoupon(ctx context.Context, userId, code, rawQuery string, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodDelete, "/api/cart/"+userId+"/coupons/"+url.PathEscape(code), rawQuery, nil, headers)
+}
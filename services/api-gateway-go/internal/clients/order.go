@@ -2,6 +2,7 @@ package clients
 
 import (
 	"context"
+	"io"
 	"net/http"
 )
 
@@ -16,3 +17,7 @@ func (oc *OrderClient) GetOrder(ctx context.Context, orderId, rawQuery string, h
 func (oc *OrderClient) ListOrdersByUser(ctx context.Context, userId, rawQuery string, headers http.Header) (*http.Response, error) {
 	return oc.c.Do(ctx, http.MethodGet, "/api/users/"+userId+"/orders", rawQuery, nil, headers)
 }
+
+func (oc *OrderClient) CancelOrder(ctx context.Context, orderId string, body io.Reader, headers http.Header) (*http.Response, error) {
+	return oc.c.Do(ctx, http.MethodPost, "/api/orders/"+orderId+"/cancel", "", body, headers)
+}
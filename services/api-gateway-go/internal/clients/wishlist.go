@@ -0,0 +1,59 @@
+package clients
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Wishlists are kept by cart-service, so these go through the cart client.
+
+func (cc *CartClient) ListWishlists(ctx context.Context, userId, rawQuery string, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodGet, "/api/wishlists/"+userId, rawQuery, nil, headers)
+}
+
+func (cc *CartClient) CreateWishlist(ctx context.Context, userId, rawQuery string, body io.Reader, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodPost, "/api/wishlists/"+userId, rawQuery, body, headers)
+}
+
+func (cc *CartClient) GetWishlist(ctx context.Context, userId, wishlistId, rawQuery string, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodGet, "/api/wishlists/"+userId+"/"+wishlistId, rawQuery, nil, headers)
+}
+
+func (cc *CartClient) RenameWishlist(ctx context.Context, userId, wishlistId, rawQuery string, body io.Reader, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodPatch, "/api/wishlists/"+userId+"/"+wishlistId, rawQuery, body, headers)
+}
+
+func (cc *CartClient) DeleteWishlist(ctx context.Context, userId, wishlistId, rawQuery string, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodDelete, "/api/wishlists/"+userId+"/"+wishlistId, rawQuery, nil, headers)
+}
+
+func (cc *CartClient) AddWishlistItem(ctx context.Context, userId, wishlistId, rawQuery string, body io.Reader, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodPost, "/api/wishlists/"+userId+"/"+wishlistId+"/items", rawQuery, body, headers)
+}
+
+func (cc *CartClient) RemoveWishlistItem(ctx context.Context, userId, wishlistId, productId, rawQuery string, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodDelete, "/api/wishlists/"+userId+"/"+wishlistId+"/items/"+productId, rawQuery, nil, headers)
+}
+
+func (cc *CartClient) MoveToCart(ctx context.Context, userId, wishlistId, productId, rawQuery string, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodPost, "/api/wishlists/"+userId+"/"+wishlistId+"/items/"+productId+"/move-to-cart", rawQuery, nil, headers)
+}
+
+func (cc *CartClient) MoveToWishlist(ctx context.Context, userId, productId, rawQuery string, body io.Reader, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodPost, "/api/cart/"+userId+"/items/"+productId+"/move-to-wishlist", rawQuery, body, headers)
+}
+
+func (cc *CartClient) ShareWishlist(ctx context.Context, userId, wishlistId, rawQuery string, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodPost, "/api/wishlists/"+userId+"/"+wishlistId+"/share", rawQuery, nil, headers)
+}
+
+func (cc *CartClient) UnshareWishlist(ctx context.Context, userId, wishlistId, rawQuery string, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodDelete, "/api/wishlists/"+userId+"/"+wishlistId+"/share", rawQuery, nil, headers)
+}
+
+// GetSharedWishlist escapes token, which arrives in links passed around outside the site.
+func (cc *CartClient) GetSharedWishlist(ctx context.Context, token, rawQuery string, headers http.Header) (*http.Response, error) {
+	return cc.c.Do(ctx, http.MethodGet, "/api/wishlists/shared/"+url.PathEscape(token), rawQuery, nil, headers)
+}
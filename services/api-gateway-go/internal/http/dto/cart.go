@@ -3,25 +3,117 @@ package dto
 import "time"
 
 type CartItem struct {
-	ProductID string  `json:"productId"`
-	Quantity  int     `json:"quantity"`
-	Price     float64 `json:"price"`
+	ProductID string    `json:"productId"`
+	Quantity  int       `json:"quantity"`
+	Price     Money     `json:"price"`
+	PricedAt  time.Time `json:"pricedAt"`
+}
+
+// CartAdjustment is a coupon discount; Amount is negative.
+type CartAdjustment struct {
+	Code        string `json:"code"`
+	PromotionID string `json:"promotionId"`
+	Description string `json:"description,omitempty"`
+	Amount      Money  `json:"amount"`
+}
+
+// CartTaxLine is the tax on one cart line. TaxableAmount excludes the tax.
+type CartTaxLine struct {
+	ProductID       string `json:"productId"`
+	TaxClass        string `json:"taxClass"`
+	RateBasisPoints int    `json:"rateBasisPoints"`
+	Inclusive       bool   `json:"inclusive"`
+	TaxableAmount   Money  `json:"taxableAmount"`
+	TaxAmount       Money  `json:"taxAmount"`
+}
+
+// CartTax is the tax breakdown of a cart; ExclusiveTax is the part of
+// TotalTax added on top of the prices.
+type CartTax struct {
+	Country      string        `json:"country"`
+	Region       string        `json:"region,omitempty"`
+	Lines        []CartTaxLine `json:"lines"`
+	TotalTax     Money         `json:"totalTax"`
+	ExclusiveTax Money         `json:"exclusiveTax"`
 }
 
 type Cart struct {
-	CartID      string     `json:"cartId"`
-	UserID      string     `json:"userId"`
-	Items       []CartItem `json:"items"`
-	TotalAmount float64    `json:"totalAmount"`
-	UpdatedAt   time.Time  `json:"updatedAt"`
+	CartID      string           `json:"cartId"`
+	UserID      string           `json:"userId"`
+	Items       []CartItem       `json:"items"`
+	Coupons     []string         `json:"coupons,omitempty"`
+	Subtotal    Money            `json:"subtotal"`
+	Adjustments []CartAdjustment `json:"adjustments,omitempty"`
+	Tax         *CartTax         `json:"tax,omitempty"`
+	TotalAmount Money            `json:"totalAmount"`
+	Version     int64            `json:"version"`
+	UpdatedAt   time.Time        `json:"updatedAt"`
 }
 
+// AddCartItemRequest carries no price; cart-service prices lines from the catalog.
 type AddCartItemRequest struct {
-	ProductID string  `json:"productId"`
-	Quantity  int     `json:"quantity"`
-	Price     float64 `json:"price"`
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
+
+// CartItemsRequest is the body of a cart replace or batch add: at most 100
+// lines, each product once.
+type CartItemsRequest struct {
+	Items []AddCartItemRequest `json:"items"`
+}
+
+type UpdateCartItemRequest struct {
+	Quantity int `json:"quantity"`
+}
+
+type ApplyCouponRequest struct {
+	Code string `json:"code"`
+}
+
+// SetTaxLocationRequest sets where the cart is taxed; an empty Country goes
+// back to the service default.
+type SetTaxLocationRequest struct {
+	Country string `json:"country"`
+	Region  string `json:"region,omitempty"`
+}
+
+// CheckoutRequest is optional. AcceptCorrections is the correctionsToken of a
+// CartValidation whose corrected cart the customer agreed to. ShippingMethod
+// is standard or express and defaults to standard when an address is given.
+type CheckoutRequest struct {
+	AcceptCorrections string   `json:"acceptCorrections,omitempty"`
+	ShippingAddress   *Address `json:"shippingAddress,omitempty"`
+	ShippingMethod    string   `json:"shippingMethod,omitempty"`
 }
 
 type CheckoutResponse struct {
 	Status string `json:"status"`
 }
+
+// CartProblem is one thing checkout would not accept about a cart line. Code
+// is out_of_stock, price_changed, product_inactive or product_not_found.
+type CartProblem struct {
+	Code              string `json:"code"`
+	ProductID         string `json:"productId"`
+	Message           string `json:"message"`
+	RequestedQuantity int    `json:"requestedQuantity,omitempty"`
+	AvailableQuantity *int   `json:"availableQuantity,omitempty"`
+	PreviousPrice     *Money `json:"previousPrice,omitempty"`
+	CurrentPrice      *Money `json:"currentPrice,omitempty"`
+}
+
+// CartValidation is returned by the validate endpoints, and with 409 by
+// checkout when the cart has problems.
+type CartValidation struct {
+	Valid            bool          `json:"valid"`
+	Problems         []CartProblem `json:"problems"`
+	CorrectedCart    *Cart         `json:"correctedCart"`
+	CorrectionsToken string        `json:"correctionsToken,omitempty"`
+}
+
+// MergeCartRequest folds a guest cart into the current user's cart. The gateway
+// fills GuestCartID from the guest cart cookie/header when it is omitted.
+type MergeCartRequest struct {
+	GuestCartID string `json:"guestCartId,omitempty"`
+	Strategy    string `json:"strategy,omitempty"`
+}
@@ -1,8 +1,12 @@
 package dto
 
+// AvailabilityResponse splits available stock into the part held by carts
+// and the part still free.
 type AvailabilityResponse struct {
 	ProductID string `json:"productId"`
 	Available int    `json:"available"`
+	Held      int    `json:"held"`
+	Free      int    `json:"free"`
 }
 
 type AdjustInventoryRequest struct {
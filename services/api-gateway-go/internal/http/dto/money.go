@@ -0,0 +1,8 @@
+package dto
+
+// Money is an amount in the currency's minor unit (e.g. cents) with its
+// ISO 4217 code, as returned by cart-service and order-service.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
@@ -3,16 +3,48 @@ package dto
 import "time"
 
 type OrderItem struct {
-	ProductID string  `json:"productId"`
-	Quantity  int     `json:"quantity"`
-	Price     float64 `json:"price"`
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+	Price     Money  `json:"price"`
 }
 
+// OrderTaxLine is the tax charged on one order line at checkout.
+type OrderTaxLine struct {
+	ProductID       string `json:"productId"`
+	TaxClass        string `json:"taxClass"`
+	Country         string `json:"country"`
+	Region          string `json:"region,omitempty"`
+	RateBasisPoints int    `json:"rateBasisPoints"`
+	Inclusive       bool   `json:"inclusive"`
+	TaxableAmount   Money  `json:"taxableAmount"`
+	TaxAmount       Money  `json:"taxAmount"`
+}
+
+// OrderShipping is where and how an order is delivered, as chosen at
+// checkout.
+type OrderShipping struct {
+	Address Address `json:"address"`
+	Method  string  `json:"method"`
+}
+
+// Order is an order as order-service returns it. Status is one of pending,
+// stock_reserved, payment_failed, completed and cancelled; PaymentOK and
+// StockOK are set as payment and inventory confirm the order.
 type Order struct {
-	OrderID     string      `json:"orderId"`
-	CartID      string      `json:"cartId"`
-	UserID      string      `json:"userId"`
-	Items       []OrderItem `json:"items"`
-	TotalAmount float64     `json:"totalAmount"`
-	CreatedAt   time.Time   `json:"createdAt"`
+	OrderID      string         `json:"orderId"`
+	CartID       string         `json:"cartId"`
+	UserID       string         `json:"userId"`
+	Items        []OrderItem    `json:"items"`
+	TotalAmount  Money          `json:"totalAmount"`
+	Discount     Money          `json:"discount"`
+	Tax          Money          `json:"tax"`
+	TaxLines     []OrderTaxLine `json:"taxLines"`
+	Shipping     *OrderShipping `json:"shipping,omitempty"`
+	Status       string         `json:"status"`
+	PaymentOK    bool           `json:"paymentOk"`
+	StockOK      bool           `json:"stockOk"`
+	PaymentError string         `json:"paymentError,omitempty"`
+	CancelReason string         `json:"cancelReason,omitempty"`
+	CreatedAt    time.Time      `json:"createdAt"`
+	UpdatedAt    time.Time      `json:"updatedAt"`
 }
@@ -0,0 +1,50 @@
+package dto
+
+import "time"
+
+// WishlistItem is a product parked on a wishlist. It carries no price; the
+// product is priced again when it moves back into the cart.
+type WishlistItem struct {
+	ProductID string    `json:"productId"`
+	Quantity  int       `json:"quantity"`
+	AddedAt   time.Time `json:"addedAt"`
+}
+
+// Wishlist is one of the user's named lists. ShareToken is set while the list
+// is shared.
+type Wishlist struct {
+	WishlistID string         `json:"wishlistId"`
+	UserID     string         `json:"userId"`
+	Name       string         `json:"name"`
+	ShareToken string         `json:"shareToken,omitempty"`
+	Items      []WishlistItem `json:"items"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	UpdatedAt  time.Time      `json:"updatedAt"`
+}
+
+type WishlistsResponse struct {
+	Wishlists []Wishlist `json:"wishlists"`
+}
+
+// SharedWishlist is what anyone with the share token sees.
+type SharedWishlist struct {
+	Name      string         `json:"name"`
+	Items     []WishlistItem `json:"items"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+}
+
+type WishlistNameRequest struct {
+	Name string `json:"name"`
+}
+
+// AddWishlistItemRequest defaults Quantity to 1.
+type AddWishlistItemRequest struct {
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity,omitempty"`
+}
+
+// MoveToWishlistRequest is optional; without a WishlistID the line goes to the
+// "Saved for later" list.
+type MoveToWishlistRequest struct {
+	WishlistID string `json:"wishlistId,omitempty"`
+}
@@ -5,8 +5,11 @@ import (
 
 	"github.com/andreasstove999/ecommerce-system/api-gateway-go/internal/clients"
 	"github.com/andreasstove999/ecommerce-system/api-gateway-go/internal/middleware"
+	"github.com/google/uuid"
 )
 
+const headerIdempotencyKey = "Idempotency-Key"
+
 type CartHandler struct{ c *clients.CartClient }
 
 func NewCartHandler(c *clients.CartClient) *CartHandler { return &CartHandler{c: c} }
@@ -19,7 +22,32 @@ func (h *CartHandler) AddItemMe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer resp.Body.Close()
-	CopyUpstreamResponse(w, resp)
+	CopyUpstreamResponse(w, r, resp)
+}
+
+// AddItemsMe adds several lines to the user's cart at once.
+func (h *CartHandler) AddItemsMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+	resp, err := h.c.AddItems(r.Context(), userId, r.URL.RawQuery, r.Body, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+// ReplaceCartMe replaces every line of the user's cart, as clients syncing a
+// whole cart do.
+func (h *CartHandler) ReplaceCartMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+	resp, err := h.c.ReplaceCart(r.Context(), userId, r.URL.RawQuery, r.Body, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
 }
 
 func (h *CartHandler) GetCartMe(w http.ResponseWriter, r *http.Request) {
@@ -30,16 +58,102 @@ func (h *CartHandler) GetCartMe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer resp.Body.Close()
-	CopyUpstreamResponse(w, resp)
+	CopyUpstreamResponse(w, r, resp)
 }
 
 func (h *CartHandler) CheckoutMe(w http.ResponseWriter, r *http.Request) {
 	userId := middleware.GetUserID(r.Context())
-	resp, err := h.c.Checkout(r.Context(), userId, r.URL.RawQuery, r.Body, r.Header)
+	// Give every checkout a key so a timed-out upstream call can be retried safely.
+	headers := r.Header.Clone()
+	if headers.Get(headerIdempotencyKey) == "" {
+		headers.Set(headerIdempotencyKey, uuid.NewString())
+	}
+	resp, err := h.c.Checkout(r.Context(), userId, r.URL.RawQuery, r.Body, headers)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+func (h *CartHandler) UpdateItemMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+	productId := r.PathValue("productId")
+	resp, err := h.c.UpdateItem(r.Context(), userId, productId, r.URL.RawQuery, r.Body, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+func (h *CartHandler) RemoveItemMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+	productId := r.PathValue("productId")
+	resp, err := h.c.RemoveItem(r.Context(), userId, productId, r.URL.RawQuery, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+func (h *CartHandler) ClearCartMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+	resp, err := h.c.ClearCart(r.Context(), userId, r.URL.RawQuery, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+func (h *CartHandler) ApplyCouponMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+	resp, err := h.c.ApplyCoupon(r.Context(), userId, r.URL.RawQuery, r.Body, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+func (h *CartHandler) RemoveCouponMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+	code := r.PathValue("code")
+	resp, err := h.c.RemoveCoupon(r.Context(), userId, code, r.URL.RawQuery, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+func (h *CartHandler) SetTaxLocationMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+	resp, err := h.c.SetTaxLocation(r.Context(), userId, r.URL.RawQuery, r.Body, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+// ValidateMe reports what checkout would find wrong with the user's cart.
+func (h *CartHandler) ValidateMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+	resp, err := h.c.Validate(r.Context(), userId, r.URL.RawQuery, r.Body, r.Header)
 	if err != nil {
 		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
 		return
 	}
 	defer resp.Body.Close()
-	CopyUpstreamResponse(w, resp)
+	CopyUpstreamResponse(w, r, resp)
 }
@@ -17,7 +17,7 @@ func (h *CatalogHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer resp.Body.Close()
-	CopyUpstreamResponse(w, resp)
+	CopyUpstreamResponse(w, r, resp)
 }
 
 func (h *CatalogHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
@@ -28,7 +28,7 @@ func (h *CatalogHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer resp.Body.Close()
-	CopyUpstreamResponse(w, resp)
+	CopyUpstreamResponse(w, r, resp)
 }
 
 func (h *CatalogHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
@@ -38,5 +38,5 @@ func (h *CatalogHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer resp.Body.Close()
-	CopyUpstreamResponse(w, resp)
+	CopyUpstreamResponse(w, r, resp)
 }
@@ -3,28 +3,85 @@ package handlers
 import (
 	"encoding/json"
 	"io"
+	"mime"
 	"net/http"
+	"strings"
 
 	"github.com/andreasstove999/ecommerce-system/api-gateway-go/internal/middleware"
-	"github.com/andreasstove999/ecommerce-system/api-gateway-go/internal/model"
+	"github.com/andreasstove999/ecommerce-system/api-gateway-go/internal/problem"
 )
 
-func CopyUpstreamResponse(w http.ResponseWriter, resp *http.Response) {
+// maxErrorBody caps how much of an upstream error body is read to normalise it.
+const maxErrorBody = 1 << 20
+
+// CopyUpstreamResponse sends resp back to the client. Error responses are
+// normalised to problem details, so clients see one error shape whatever the
+// service behind the route.
+func CopyUpstreamResponse(w http.ResponseWriter, r *http.Request, resp *http.Response) {
 	// Copy headers (avoid hop-by-hop)
 	for k, vv := range resp.Header {
 		for _, v := range vv {
 			w.Header().Add(k, v)
 		}
 	}
+	if resp.StatusCode < http.StatusBadRequest {
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+		return
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	p := upstreamProblem(resp, body)
+	p["instance"] = r.URL.Path
+	if cid, _ := p["correlationId"].(string); cid == "" {
+		p["correlationId"] = middleware.GetCorrelationID(r.Context())
+	}
+
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", problem.ContentType)
 	w.WriteHeader(resp.StatusCode)
-	_, _ = io.Copy(w, resp.Body)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// upstreamProblem reads an upstream error body as problem details. Problem
+// bodies are kept as they are; an {"error": "..."} body keeps its members and
+// gains the problem ones, and any other body becomes the detail.
+func upstreamProblem(resp *http.Response, body []byte) map[string]any {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+
+	var p map[string]any
+	if strings.HasSuffix(mediaType, "json") && json.Unmarshal(body, &p) == nil && p != nil {
+		if mediaType == problem.ContentType {
+			return p
+		}
+	} else {
+		p = map[string]any{}
+		if detail := strings.TrimSpace(string(body)); detail != "" {
+			p["error"] = detail
+		}
+	}
+
+	detail, _ := p["error"].(string)
+	if detail == "" {
+		detail = http.StatusText(resp.StatusCode)
+	}
+	code, _ := p["code"].(string)
+	if code == "" {
+		code = problem.CodeForStatus(resp.StatusCode)
+	}
+	d := problem.New(resp.StatusCode, code, detail)
+	p["type"] = d.Type
+	p["title"] = d.Title
+	p["status"] = d.Status
+	p["detail"] = d.Detail
+	p["code"] = d.Code
+	p["error"] = d.Error
+	return p
 }
 
+// WriteUpstreamError answers for the gateway itself, when the upstream call
+// could not be made or its answer could not be used.
 func WriteUpstreamError(w http.ResponseWriter, r *http.Request, status int, msg string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	_ = json.NewEncoder(w).Encode(model.ErrorResponse{
-		Error:         msg,
-		CorrelationID: middleware.GetCorrelationID(r.Context()),
-	})
+	d := problem.New(status, problem.CodeForStatus(status), msg)
+	problem.Write(w, r, &d)
 }
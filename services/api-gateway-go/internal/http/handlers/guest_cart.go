@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/api-gateway-go/internal/http/dto"
+	"github.com/andreasstove999/ecommerce-system/api-gateway-go/internal/middleware"
+	"github.com/google/uuid"
+)
+
+// Guest carts are identified by an ID issued by cart-service ("guest-<uuid>").
+// Browsers keep it in a cookie; other clients send it as a header.
+const (
+	HeaderGuestCartID = "X-Guest-Cart-Id"
+	GuestCartCookie   = "guest_cart_id"
+
+	guestCartCookieMaxAge = 30 * 24 * time.Hour
+)
+
+// guestCartID returns the guest cart ID from the header or cookie, or "" when
+// none (or a malformed one) was sent.
+func guestCartID(r *http.Request) string {
+	id := strings.TrimSpace(r.Header.Get(HeaderGuestCartID))
+	if id == "" {
+		if c, err := r.Cookie(GuestCartCookie); err == nil {
+			id = c.Value
+		}
+	}
+	if !isGuestCartID(id) {
+		return ""
+	}
+	return id
+}
+
+// isGuestCartID only lets guest IDs through so the guest routes can't be used
+// to reach a registered user's cart.
+func isGuestCartID(id string) bool {
+	rest, ok := strings.CutPrefix(id, "guest-")
+	if !ok {
+		return false
+	}
+	_, err := uuid.Parse(rest)
+	return err == nil
+}
+
+func setGuestCartCookie(w http.ResponseWriter, id string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     GuestCartCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(guestCartCookieMaxAge.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.Header().Set(HeaderGuestCartID, id)
+}
+
+func expireGuestCartCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     GuestCartCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// issueGuestCart asks cart-service for a new guest cart and hands its ID to the
+// client. On failure the upstream response has already been written.
+func (h *CartHandler) issueGuestCart(w http.ResponseWriter, r *http.Request) (string, bool) {
+	resp, err := h.c.CreateGuestCart(r.Context(), r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return "", false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		CopyUpstreamResponse(w, r, resp)
+		return "", false
+	}
+
+	var c dto.Cart
+	if err := json.NewDecoder(resp.Body).Decode(&c); err != nil || !isGuestCartID(c.UserID) {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service returned an invalid guest cart")
+		return "", false
+	}
+
+	setGuestCartCookie(w, c.UserID)
+	return c.UserID, true
+}
+
+// GetCartGuest returns the visitor's guest cart, issuing one on first use.
+func (h *CartHandler) GetCartGuest(w http.ResponseWriter, r *http.Request) {
+	guestId := guestCartID(r)
+	if guestId == "" {
+		var ok bool
+		if guestId, ok = h.issueGuestCart(w, r); !ok {
+			return
+		}
+	}
+	resp, err := h.c.GetCart(r.Context(), guestId, r.URL.RawQuery, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+// AddItemGuest adds to the visitor's guest cart, issuing one on first use.
+func (h *CartHandler) AddItemGuest(w http.ResponseWriter, r *http.Request) {
+	guestId := guestCartID(r)
+	if guestId == "" {
+		var ok bool
+		if guestId, ok = h.issueGuestCart(w, r); !ok {
+			return
+		}
+	}
+	resp, err := h.c.AddItem(r.Context(), guestId, r.URL.RawQuery, r.Body, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+// AddItemsGuest adds several lines to the visitor's guest cart, issuing one
+// on first use.
+func (h *CartHandler) AddItemsGuest(w http.ResponseWriter, r *http.Request) {
+	guestId := guestCartID(r)
+	if guestId == "" {
+		var ok bool
+		if guestId, ok = h.issueGuestCart(w, r); !ok {
+			return
+		}
+	}
+	resp, err := h.c.AddItems(r.Context(), guestId, r.URL.RawQuery, r.Body, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+// ReplaceCartGuest replaces every line of the visitor's guest cart, issuing
+// one on first use.
+func (h *CartHandler) ReplaceCartGuest(w http.ResponseWriter, r *http.Request) {
+	guestId := guestCartID(r)
+	if guestId == "" {
+		var ok bool
+		if guestId, ok = h.issueGuestCart(w, r); !ok {
+			return
+		}
+	}
+	resp, err := h.c.ReplaceCart(r.Context(), guestId, r.URL.RawQuery, r.Body, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+func (h *CartHandler) UpdateItemGuest(w http.ResponseWriter, r *http.Request) {
+	guestId := guestCartID(r)
+	if guestId == "" {
+		WriteUpstreamError(w, r, http.StatusBadRequest, "missing guest cart id")
+		return
+	}
+	productId := r.PathValue("productId")
+	resp, err := h.c.UpdateItem(r.Context(), guestId, productId, r.URL.RawQuery, r.Body, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+func (h *CartHandler) RemoveItemGuest(w http.ResponseWriter, r *http.Request) {
+	guestId := guestCartID(r)
+	if guestId == "" {
+		WriteUpstreamError(w, r, http.StatusBadRequest, "missing guest cart id")
+		return
+	}
+	productId := r.PathValue("productId")
+	resp, err := h.c.RemoveItem(r.Context(), guestId, productId, r.URL.RawQuery, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+func (h *CartHandler) ClearCartGuest(w http.ResponseWriter, r *http.Request) {
+	guestId := guestCartID(r)
+	if guestId == "" {
+		WriteUpstreamError(w, r, http.StatusBadRequest, "missing guest cart id")
+		return
+	}
+	resp, err := h.c.ClearCart(r.Context(), guestId, r.URL.RawQuery, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+// MergeCartMe folds the visitor's guest cart into the logged-in user's cart.
+// The guest cookie is dropped once the guest cart is gone.
+func (h *CartHandler) MergeCartMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+
+	var req dto.MergeCartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		WriteUpstreamError(w, r, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if req.GuestCartID == "" {
+		req.GuestCartID = guestCartID(r)
+	}
+	if req.GuestCartID == "" {
+		WriteUpstreamError(w, r, http.StatusBadRequest, "missing guest cart id")
+		return
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusInternalServerError, "failed to encode merge request")
+		return
+	}
+	headers := r.Header.Clone()
+	headers.Del("Content-Length")
+	headers.Set("Content-Type", "application/json")
+
+	resp, err := h.c.MergeCart(r.Context(), userId, r.URL.RawQuery, bytes.NewReader(body), headers)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNotFound {
+		expireGuestCartCookie(w)
+	}
+	CopyUpstreamResponse(w, r, resp)
+}
+
+func (h *CartHandler) ApplyCouponGuest(w http.ResponseWriter, r *http.Request) {
+	guestId := guestCartID(r)
+	if guestId == "" {
+		WriteUpstreamError(w, r, http.StatusBadRequest, "missing guest cart id")
+		return
+	}
+	resp, err := h.c.ApplyCoupon(r.Context(), guestId, r.URL.RawQuery, r.Body, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+func (h *CartHandler) RemoveCouponGuest(w http.ResponseWriter, r *http.Request) {
+	guestId := guestCartID(r)
+	if guestId == "" {
+		WriteUpstreamError(w, r, http.StatusBadRequest, "missing guest cart id")
+		return
+	}
+	code := r.PathValue("code")
+	resp, err := h.c.RemoveCoupon(r.Context(), guestId, code, r.URL.RawQuery, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+func (h *CartHandler) SetTaxLocationGuest(w http.ResponseWriter, r *http.Request) {
+	guestId := guestCartID(r)
+	if guestId == "" {
+		WriteUpstreamError(w, r, http.StatusBadRequest, "missing guest cart id")
+		return
+	}
+	resp, err := h.c.SetTaxLocation(r.Context(), guestId, r.URL.RawQuery, r.Body, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+func (h *CartHandler) ValidateGuest(w http.ResponseWriter, r *http.Request) {
+	guestId := guestCartID(r)
+	if guestId == "" {
+		WriteUpstreamError(w, r, http.StatusBadRequest, "missing guest cart id")
+		return
+	}
+	resp, err := h.c.Validate(r.Context(), guestId, r.URL.RawQuery, r.Body, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
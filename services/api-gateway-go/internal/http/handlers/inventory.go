@@ -20,7 +20,7 @@ func (h *InventoryHandler) Availability(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	defer resp.Body.Close()
-	CopyUpstreamResponse(w, resp)
+	CopyUpstreamResponse(w, r, resp)
 }
 
 func (h *InventoryHandler) Adjust(w http.ResponseWriter, r *http.Request) {
@@ -30,5 +30,5 @@ func (h *InventoryHandler) Adjust(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer resp.Body.Close()
-	CopyUpstreamResponse(w, resp)
+	CopyUpstreamResponse(w, r, resp)
 }
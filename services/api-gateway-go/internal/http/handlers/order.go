@@ -1,12 +1,23 @@
 package handlers
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/andreasstove999/ecommerce-system/api-gateway-go/internal/clients"
+	"github.com/andreasstove999/ecommerce-system/api-gateway-go/internal/http/dto"
 	"github.com/andreasstove999/ecommerce-system/api-gateway-go/internal/middleware"
+	"github.com/andreasstove999/ecommerce-system/api-gateway-go/internal/problem"
 )
 
+// codeOrderNotFound is order-service's code for an unknown order, also given
+// for orders of other users.
+const codeOrderNotFound = "order_not_found"
+
+// headerNextCursor is order-service's cursor of the next page of a listing.
+const headerNextCursor = "X-Next-Cursor"
+
 type OrderHandler struct{ c *clients.OrderClient }
 
 func NewOrderHandler(c *clients.OrderClient) *OrderHandler { return &OrderHandler{c: c} }
@@ -19,9 +30,11 @@ func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer resp.Body.Close()
-	CopyUpstreamResponse(w, resp)
+	CopyUpstreamResponse(w, r, resp)
 }
 
+// ListOrdersMe lists a page of the caller's orders. order-service's Link to
+// the next page is rewritten to point at this path.
 func (h *OrderHandler) ListOrdersMe(w http.ResponseWriter, r *http.Request) {
 	userId := middleware.GetUserID(r.Context())
 	resp, err := h.c.ListOrdersByUser(r.Context(), userId, r.URL.RawQuery, r.Header)
@@ -30,5 +43,50 @@ func (h *OrderHandler) ListOrdersMe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer resp.Body.Close()
-	CopyUpstreamResponse(w, resp)
+
+	resp.Header.Del("Link")
+	if cursor := resp.Header.Get(headerNextCursor); cursor != "" {
+		q := r.URL.Query()
+		q.Set("cursor", cursor)
+		resp.Header.Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, q.Encode()))
+	}
+	CopyUpstreamResponse(w, r, resp)
+}
+
+// CancelOrderMe cancels one of the caller's orders. The order is loaded first
+// to check it belongs to the caller; other users' orders are reported as not
+// found.
+func (h *OrderHandler) CancelOrderMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+	orderId := r.PathValue("orderId")
+
+	resp, err := h.c.GetOrder(r.Context(), orderId, "", r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "order-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		CopyUpstreamResponse(w, r, resp)
+		return
+	}
+	var o dto.Order
+	if err := json.NewDecoder(resp.Body).Decode(&o); err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "order-service returned an invalid order")
+		return
+	}
+	if o.UserID != userId {
+		d := problem.New(http.StatusNotFound, codeOrderNotFound, "order not found")
+		problem.Write(w, r, &d)
+		return
+	}
+
+	cancelResp, err := h.c.CancelOrder(r.Context(), orderId, r.Body, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "order-service request failed: "+err.Error())
+		return
+	}
+	defer cancelResp.Body.Close()
+	CopyUpstreamResponse(w, r, cancelResp)
 }
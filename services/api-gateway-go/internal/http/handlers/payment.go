@@ -18,5 +18,5 @@ func (h *PaymentHandler) ByOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer resp.Body.Close()
-	CopyUpstreamResponse(w, resp)
+	CopyUpstreamResponse(w, r, resp)
 }
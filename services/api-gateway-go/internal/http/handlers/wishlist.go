@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/andreasstove999/ecommerce-system/api-gateway-go/internal/clients"
+	"github.com/andreasstove999/ecommerce-system/api-gateway-go/internal/middleware"
+)
+
+// WishlistHandler serves the signed-in user's wishlists, which cart-service keeps.
+type WishlistHandler struct{ c *clients.CartClient }
+
+func NewWishlistHandler(c *clients.CartClient) *WishlistHandler { return &WishlistHandler{c: c} }
+
+func (h *WishlistHandler) ListWishlistsMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+	resp, err := h.c.ListWishlists(r.Context(), userId, r.URL.RawQuery, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+func (h *WishlistHandler) CreateWishlistMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+	resp, err := h.c.CreateWishlist(r.Context(), userId, r.URL.RawQuery, r.Body, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+func (h *WishlistHandler) GetWishlistMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+	wishlistId := r.PathValue("wishlistId")
+	resp, err := h.c.GetWishlist(r.Context(), userId, wishlistId, r.URL.RawQuery, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+func (h *WishlistHandler) RenameWishlistMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+	wishlistId := r.PathValue("wishlistId")
+	resp, err := h.c.RenameWishlist(r.Context(), userId, wishlistId, r.URL.RawQuery, r.Body, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+func (h *WishlistHandler) DeleteWishlistMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+	wishlistId := r.PathValue("wishlistId")
+	resp, err := h.c.DeleteWishlist(r.Context(), userId, wishlistId, r.URL.RawQuery, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+func (h *WishlistHandler) AddWishlistItemMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+	wishlistId := r.PathValue("wishlistId")
+	resp, err := h.c.AddWishlistItem(r.Context(), userId, wishlistId, r.URL.RawQuery, r.Body, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+func (h *WishlistHandler) RemoveWishlistItemMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+	wishlistId := r.PathValue("wishlistId")
+	productId := r.PathValue("productId")
+	resp, err := h.c.RemoveWishlistItem(r.Context(), userId, wishlistId, productId, r.URL.RawQuery, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+// MoveToCartMe returns the cart, with its ETag, after the product has moved into it.
+func (h *WishlistHandler) MoveToCartMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+	wishlistId := r.PathValue("wishlistId")
+	productId := r.PathValue("productId")
+	resp, err := h.c.MoveToCart(r.Context(), userId, wishlistId, productId, r.URL.RawQuery, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+// MoveToWishlistMe takes a cart line to the wishlist named in the body, or to
+// "Saved for later" when none is, and returns the cart.
+func (h *WishlistHandler) MoveToWishlistMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+	productId := r.PathValue("productId")
+	resp, err := h.c.MoveToWishlist(r.Context(), userId, productId, r.URL.RawQuery, r.Body, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+func (h *WishlistHandler) ShareWishlistMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+	wishlistId := r.PathValue("wishlistId")
+	resp, err := h.c.ShareWishlist(r.Context(), userId, wishlistId, r.URL.RawQuery, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+func (h *WishlistHandler) UnshareWishlistMe(w http.ResponseWriter, r *http.Request) {
+	userId := middleware.GetUserID(r.Context())
+	wishlistId := r.PathValue("wishlistId")
+	resp, err := h.c.UnshareWishlist(r.Context(), userId, wishlistId, r.URL.RawQuery, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
+
+// GetShared serves a shared wishlist to anyone with its token; no user is needed.
+func (h *WishlistHandler) GetShared(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+	resp, err := h.c.GetSharedWishlist(r.Context(), token, r.URL.RawQuery, r.Header)
+	if err != nil {
+		WriteUpstreamError(w, r, http.StatusBadGateway, "cart-service request failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	CopyUpstreamResponse(w, r, resp)
+}
@@ -35,8 +35,48 @@ func NewRouter(d Deps) http.Handler {
 	// BFF: Cart (me)
 	cart := handlers.NewCartHandler(d.Cart)
 	mux.HandleFunc("GET /me/cart", cart.GetCartMe)
+	mux.HandleFunc("PUT /me/cart", cart.ReplaceCartMe)
+	mux.HandleFunc("DELETE /me/cart", cart.ClearCartMe)
 	mux.HandleFunc("POST /me/cart/items", cart.AddItemMe)
+	mux.HandleFunc("POST /me/cart/items:batch", cart.AddItemsMe)
+	mux.HandleFunc("PATCH /me/cart/items/{productId}", cart.UpdateItemMe)
+	mux.HandleFunc("PUT /me/cart/items/{productId}", cart.UpdateItemMe)
+	mux.HandleFunc("DELETE /me/cart/items/{productId}", cart.RemoveItemMe)
+	mux.HandleFunc("POST /me/cart/validate", cart.ValidateMe)
 	mux.HandleFunc("POST /me/cart/checkout", cart.CheckoutMe)
+	mux.HandleFunc("POST /me/cart/merge", cart.MergeCartMe)
+	mux.HandleFunc("POST /me/cart/coupons", cart.ApplyCouponMe)
+	mux.HandleFunc("DELETE /me/cart/coupons/{code}", cart.RemoveCouponMe)
+	mux.HandleFunc("PUT /me/cart/tax-location", cart.SetTaxLocationMe)
+
+	// BFF: Cart (guest, identified by the guest cart cookie/header)
+	mux.HandleFunc("GET /cart", cart.GetCartGuest)
+	mux.HandleFunc("PUT /cart", cart.ReplaceCartGuest)
+	mux.HandleFunc("DELETE /cart", cart.ClearCartGuest)
+	mux.HandleFunc("POST /cart/items", cart.AddItemGuest)
+	mux.HandleFunc("POST /cart/items:batch", cart.AddItemsGuest)
+	mux.HandleFunc("PATCH /cart/items/{productId}", cart.UpdateItemGuest)
+	mux.HandleFunc("PUT /cart/items/{productId}", cart.UpdateItemGuest)
+	mux.HandleFunc("DELETE /cart/items/{productId}", cart.RemoveItemGuest)
+	mux.HandleFunc("POST /cart/coupons", cart.ApplyCouponGuest)
+	mux.HandleFunc("DELETE /cart/coupons/{code}", cart.RemoveCouponGuest)
+	mux.HandleFunc("PUT /cart/tax-location", cart.SetTaxLocationGuest)
+	mux.HandleFunc("POST /cart/validate", cart.ValidateGuest)
+
+	// BFF: Wishlists (me), plus read-only access by share token
+	wl := handlers.NewWishlistHandler(d.Cart)
+	mux.HandleFunc("GET /me/wishlists", wl.ListWishlistsMe)
+	mux.HandleFunc("POST /me/wishlists", wl.CreateWishlistMe)
+	mux.HandleFunc("GET /me/wishlists/{wishlistId}", wl.GetWishlistMe)
+	mux.HandleFunc("PATCH /me/wishlists/{wishlistId}", wl.RenameWishlistMe)
+	mux.HandleFunc("DELETE /me/wishlists/{wishlistId}", wl.DeleteWishlistMe)
+	mux.HandleFunc("POST /me/wishlists/{wishlistId}/items", wl.AddWishlistItemMe)
+	mux.HandleFunc("DELETE /me/wishlists/{wishlistId}/items/{productId}", wl.RemoveWishlistItemMe)
+	mux.HandleFunc("POST /me/wishlists/{wishlistId}/items/{productId}/move-to-cart", wl.MoveToCartMe)
+	mux.HandleFunc("POST /me/wishlists/{wishlistId}/share", wl.ShareWishlistMe)
+	mux.HandleFunc("DELETE /me/wishlists/{wishlistId}/share", wl.UnshareWishlistMe)
+	mux.HandleFunc("POST /me/cart/items/{productId}/move-to-wishlist", wl.MoveToWishlistMe)
+	mux.HandleFunc("GET /wishlists/shared/{token}", wl.GetShared)
 
 	// BFF: Products (catalog)
 	cat := handlers.NewCatalogHandler(d.Catalog)
@@ -47,6 +87,7 @@ func NewRouter(d Deps) http.Handler {
 	// BFF: Orders
 	order := handlers.NewOrderHandler(d.Order)
 	mux.HandleFunc("GET /me/orders", order.ListOrdersMe)
+	mux.HandleFunc("POST /me/orders/{orderId}/cancel", order.CancelOrderMe)
 	mux.HandleFunc("GET /orders/{orderId}", order.GetOrder)
 
 	// BFF: Inventory
@@ -65,8 +106,8 @@ func NewRouter(d Deps) http.Handler {
 	var h http.Handler = mux
 	h = middleware.Recover(d.Logger)(h)
 	h = middleware.CORS(d.Cfg.CORSAllowOrigins)(h)
-	h = middleware.CorrelationID(h)
 	h = middleware.RequireUserIDForMeRoutes(h) // <-- new
+	h = middleware.CorrelationID(h)            // outside the checks so their errors carry the id
 	h = middleware.AuthJWT(h)                  // still placeholder
 	h = middleware.Logging(d.Logger)(h)
 
@@ -97,6 +97,9 @@ func TestRequireUserIDMiddleware(t *testing.T) {
 	if resp["error"] == nil {
 		t.Fatalf("expected error message in response: %v", resp)
 	}
+	if resp["code"] != "validation_failed" || resp["correlationId"] != rr.Header().Get("X-Correlation-Id") {
+		t.Fatalf("expected a problem carrying the correlation id: %v", resp)
+	}
 }
 
 func TestCorrelationIDEchoAndGeneration(t *testing.T) {
@@ -167,6 +170,56 @@ func middlewareRecover(logger *log.Logger) func(http.Handler) http.Handler {
 	}
 }
 
+func TestUpstreamErrorsAreProblems(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantCode    string
+		wantDetail  string
+		wantExtra   string
+	}{
+		{"plain text", "text/plain; charset=utf-8", "not found\n", "not_found", "not found", ""},
+		{"json error", "application/json", `{"error":"product gone"}`, "not_found", "product gone", ""},
+		{"problem", "application/problem+json", `{"type":"x","title":"Not Found","status":404,"code":"product_not_found","detail":"no such product","instance":"/api/catalog/products/p1","error":"no such product","productId":"p1"}`, "product_not_found", "no such product", "p1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer srv.Close()
+
+			req := httptest.NewRequest(http.MethodGet, "/products/p1", nil)
+			req.Header.Set("X-Correlation-Id", "cid-1")
+			rr := httptest.NewRecorder()
+			newRouterWithBaseURL(srv.URL).ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusNotFound {
+				t.Fatalf("expected 404, got %d", rr.Code)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Fatalf("expected a problem response, got %q", ct)
+			}
+			var body map[string]any
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to decode body: %v", err)
+			}
+			if body["code"] != tc.wantCode || body["detail"] != tc.wantDetail || body["status"] != float64(404) {
+				t.Fatalf("unexpected problem %v", body)
+			}
+			if body["instance"] != "/products/p1" || body["correlationId"] != "cid-1" {
+				t.Fatalf("expected the gateway path and correlation id, got %v", body)
+			}
+			if tc.wantExtra != "" && body["productId"] != tc.wantExtra {
+				t.Fatalf("expected extension members to be kept, got %v", body)
+			}
+		})
+	}
+}
+
 func TestForwardingForCatalogProducts(t *testing.T) {
 	srv, ch := newStubServer(t)
 	defer srv.Close()
@@ -214,6 +267,31 @@ func TestForwardingPathsAndMethods(t *testing.T) {
 		{name: "availability", method: http.MethodGet, path: "/products/sku-1/availability", wantPath: "/api/inventory/sku-1"},
 		{name: "me orders", method: http.MethodGet, path: "/me/orders", wantPath: "/api/users/u-9/orders", headers: map[string]string{"X-User-Id": "u-9"}},
 		{name: "add to cart", method: http.MethodPost, path: "/me/cart/items", wantPath: "/api/cart/u-9/items", headers: map[string]string{"X-User-Id": "u-9"}},
+		{name: "update cart item", method: http.MethodPatch, path: "/me/cart/items/p-1", wantPath: "/api/cart/u-9/items/p-1", headers: map[string]string{"X-User-Id": "u-9"}},
+		{name: "remove cart item", method: http.MethodDelete, path: "/me/cart/items/p-1", wantPath: "/api/cart/u-9/items/p-1", headers: map[string]string{"X-User-Id": "u-9"}},
+		{name: "batch add to cart", method: http.MethodPost, path: "/me/cart/items:batch", wantPath: "/api/cart/u-9/items:batch", headers: map[string]string{"X-User-Id": "u-9"}},
+		{name: "replace cart", method: http.MethodPut, path: "/me/cart", wantPath: "/api/cart/u-9", headers: map[string]string{"X-User-Id": "u-9"}},
+		{name: "clear cart", method: http.MethodDelete, path: "/me/cart", wantPath: "/api/cart/u-9", headers: map[string]string{"X-User-Id": "u-9"}},
+		{name: "apply coupon", method: http.MethodPost, path: "/me/cart/coupons", wantPath: "/api/cart/u-9/coupons", headers: map[string]string{"X-User-Id": "u-9"}},
+		{name: "remove coupon", method: http.MethodDelete, path: "/me/cart/coupons/SAVE10", wantPath: "/api/cart/u-9/coupons/SAVE10", headers: map[string]string{"X-User-Id": "u-9"}},
+		{name: "set tax location", method: http.MethodPut, path: "/me/cart/tax-location", wantPath: "/api/cart/u-9/tax-location", headers: map[string]string{"X-User-Id": "u-9"}},
+		{name: "validate cart", method: http.MethodPost, path: "/me/cart/validate", wantPath: "/api/cart/u-9/validate", headers: map[string]string{"X-User-Id": "u-9"}},
+		{name: "list wishlists", method: http.MethodGet, path: "/me/wishlists", wantPath: "/api/wishlists/u-9", headers: map[string]string{"X-User-Id": "u-9"}},
+		{name: "create wishlist", method: http.MethodPost, path: "/me/wishlists", wantPath: "/api/wishlists/u-9", headers: map[string]string{"X-User-Id": "u-9"}},
+		{name: "rename wishlist", method: http.MethodPatch, path: "/me/wishlists/w-1", wantPath: "/api/wishlists/u-9/w-1", headers: map[string]string{"X-User-Id": "u-9"}},
+		{name: "remove wishlist item", method: http.MethodDelete, path: "/me/wishlists/w-1/items/p-1", wantPath: "/api/wishlists/u-9/w-1/items/p-1", headers: map[string]string{"X-User-Id": "u-9"}},
+		{name: "move wishlist item to cart", method: http.MethodPost, path: "/me/wishlists/w-1/items/p-1/move-to-cart", wantPath: "/api/wishlists/u-9/w-1/items/p-1/move-to-cart", headers: map[string]string{"X-User-Id": "u-9"}},
+		{name: "move cart item to wishlist", method: http.MethodPost, path: "/me/cart/items/p-1/move-to-wishlist", wantPath: "/api/cart/u-9/items/p-1/move-to-wishlist", headers: map[string]string{"X-User-Id": "u-9"}},
+		{name: "share wishlist", method: http.MethodPost, path: "/me/wishlists/w-1/share", wantPath: "/api/wishlists/u-9/w-1/share", headers: map[string]string{"X-User-Id": "u-9"}},
+		{name: "shared wishlist", method: http.MethodGet, path: "/wishlists/shared/t-1", wantPath: "/api/wishlists/shared/t-1"},
+		{name: "guest cart", method: http.MethodGet, path: "/cart", wantPath: "/api/cart/" + testGuestID, headers: map[string]string{"X-Guest-Cart-Id": testGuestID}},
+		{name: "guest batch add", method: http.MethodPost, path: "/cart/items:batch", wantPath: "/api/cart/" + testGuestID + "/items:batch", headers: map[string]string{"X-Guest-Cart-Id": testGuestID}},
+		{name: "guest replace cart", method: http.MethodPut, path: "/cart", wantPath: "/api/cart/" + testGuestID, headers: map[string]string{"X-Guest-Cart-Id": testGuestID}},
+		{name: "guest update item", method: http.MethodPatch, path: "/cart/items/p-1", wantPath: "/api/cart/" + testGuestID + "/items/p-1", headers: map[string]string{"X-Guest-Cart-Id": testGuestID}},
+		{name: "guest apply coupon", method: http.MethodPost, path: "/cart/coupons", wantPath: "/api/cart/" + testGuestID + "/coupons", headers: map[string]string{"X-Guest-Cart-Id": testGuestID}},
+		{name: "guest set tax location", method: http.MethodPut, path: "/cart/tax-location", wantPath: "/api/cart/" + testGuestID + "/tax-location", headers: map[string]string{"X-Guest-Cart-Id": testGuestID}},
+		{name: "guest validate cart", method: http.MethodPost, path: "/cart/validate", wantPath: "/api/cart/" + testGuestID + "/validate", headers: map[string]string{"X-Guest-Cart-Id": testGuestID}},
+		{name: "guest remove item", method: http.MethodDelete, path: "/cart/items/p-1", wantPath: "/api/cart/" + testGuestID + "/items/p-1", headers: map[string]string{"Cookie": "guest_cart_id=" + testGuestID}},
 	}
 
 	for _, tc := range cases {
@@ -248,6 +326,248 @@ func TestForwardingPathsAndMethods(t *testing.T) {
 	}
 }
 
+func TestCheckoutForwardsIdempotencyKey(t *testing.T) {
+	srv, ch := newStubServer(t)
+	defer srv.Close()
+
+	router := newRouterWithBaseURL(srv.URL)
+
+	cases := []struct {
+		name    string
+		key     string
+		wantKey string
+	}{
+		{name: "client key is forwarded", key: "client-key", wantKey: "client-key"},
+		{name: "key is generated when missing"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/me/cart/checkout", nil)
+			req.Header.Set("X-User-Id", "u-9")
+			if tc.key != "" {
+				req.Header.Set("Idempotency-Key", tc.key)
+			}
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("unexpected status: %d", rr.Code)
+			}
+
+			select {
+			case rec := <-ch:
+				if rec.Path != "/api/cart/u-9/checkout" {
+					t.Fatalf("unexpected upstream path %s", rec.Path)
+				}
+				got := rec.Header.Get("Idempotency-Key")
+				if tc.wantKey != "" && got != tc.wantKey {
+					t.Fatalf("expected key %q, got %q", tc.wantKey, got)
+				}
+				if got == "" {
+					t.Fatalf("expected idempotency key to be forwarded")
+				}
+			case <-time.After(time.Second):
+				t.Fatal("did not receive upstream request")
+			}
+		})
+	}
+}
+
+const testGuestID = "guest-0b6f3c8e-2f4f-4d8a-9a43-6f3f0f1c2d11"
+
+func TestGuestCartIssuedOnFirstAdd(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/cart/guests" {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"cartId":"c1","userId":"` + testGuestID + `","items":[]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	router := newRouterWithBaseURL(srv.URL)
+
+	req := httptest.NewRequest(http.MethodPost, "/cart/items", strings.NewReader(`{"productId":"p-1","quantity":1}`))
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rr.Code)
+	}
+	want := []string{"POST /api/cart/guests", "POST /api/cart/" + testGuestID + "/items"}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected upstream calls %v, got %v", want, paths)
+	}
+	if rr.Header().Get("X-Guest-Cart-Id") != testGuestID {
+		t.Fatalf("expected guest cart id header, got %q", rr.Header().Get("X-Guest-Cart-Id"))
+	}
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "guest_cart_id" || cookies[0].Value != testGuestID || !cookies[0].HttpOnly {
+		t.Fatalf("expected guest cart cookie, got %+v", cookies)
+	}
+}
+
+func TestGuestRoutesRejectNonGuestIDs(t *testing.T) {
+	router := newRouterWithBaseURL("http://example.com")
+
+	req := httptest.NewRequest(http.MethodDelete, "/cart", nil)
+	req.Header.Set("X-Guest-Cart-Id", "u-9")
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a non-guest id, got %d", rr.Code)
+	}
+}
+
+func TestMergeCartUsesGuestCookie(t *testing.T) {
+	srv, ch := newStubServer(t)
+	defer srv.Close()
+
+	router := newRouterWithBaseURL(srv.URL)
+
+	req := httptest.NewRequest(http.MethodPost, "/me/cart/merge", strings.NewReader(`{"strategy":"max"}`))
+	req.Header.Set("X-User-Id", "u-9")
+	req.AddCookie(&http.Cookie{Name: "guest_cart_id", Value: testGuestID})
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rr.Code)
+	}
+
+	select {
+	case rec := <-ch:
+		if rec.Path != "/api/cart/u-9/merge" {
+			t.Fatalf("unexpected upstream path %s", rec.Path)
+		}
+		var body map[string]string
+		if err := json.Unmarshal([]byte(rec.Body), &body); err != nil {
+			t.Fatalf("decode upstream body: %v", err)
+		}
+		if body["guestCartId"] != testGuestID || body["strategy"] != "max" {
+			t.Fatalf("unexpected upstream body %v", body)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("did not receive upstream request")
+	}
+
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "guest_cart_id" || cookies[0].MaxAge >= 0 {
+		t.Fatalf("expected guest cart cookie to be expired, got %+v", cookies)
+	}
+}
+
+func TestListOrdersMeForwardsStatusFilter(t *testing.T) {
+	srv, ch := newStubServer(t)
+	defer srv.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/me/orders?status=pending,completed", nil)
+	req.Header.Set("X-User-Id", "u-9")
+	rr := httptest.NewRecorder()
+
+	newRouterWithBaseURL(srv.URL).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rr.Code)
+	}
+	select {
+	case rec := <-ch:
+		if rec.Path != "/api/users/u-9/orders" || rec.RawQuery != "status=pending,completed" {
+			t.Fatalf("unexpected upstream request %s?%s", rec.Path, rec.RawQuery)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("did not receive upstream request")
+	}
+}
+
+func TestListOrdersMeRewritesNextLink(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `</api/users/u-9/orders?cursor=c2&limit=2>; rel="next"`)
+		w.Header().Set("X-Next-Cursor", "c2")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/me/orders?limit=2", nil)
+	req.Header.Set("X-User-Id", "u-9")
+	rr := httptest.NewRecorder()
+
+	newRouterWithBaseURL(srv.URL).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rr.Code)
+	}
+	if got := rr.Header().Values("Link"); len(got) != 1 || got[0] != `</me/orders?cursor=c2&limit=2>; rel="next"` {
+		t.Fatalf("unexpected Link %q", got)
+	}
+	if got := rr.Header().Get("X-Next-Cursor"); got != "c2" {
+		t.Fatalf("expected the next cursor to be passed through, got %q", got)
+	}
+}
+
+func TestCancelOrderChecksOwnership(t *testing.T) {
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path+" "+r.Header.Get("X-User-Id"))
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"orderId":"ord-1","userId":"u-9","items":[]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"orderId":"ord-1","status":"cancelled"}`))
+	}))
+	defer srv.Close()
+
+	router := newRouterWithBaseURL(srv.URL)
+
+	cases := []struct {
+		name      string
+		userID    string
+		wantCode  int
+		wantCalls []string
+	}{
+		{name: "own order", userID: "u-9", wantCode: http.StatusOK, wantCalls: []string{"GET /api/orders/ord-1 u-9", "POST /api/orders/ord-1/cancel u-9"}},
+		{name: "other user's order", userID: "u-1", wantCode: http.StatusNotFound, wantCalls: []string{"GET /api/orders/ord-1 u-1"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls = nil
+			req := httptest.NewRequest(http.MethodPost, "/me/orders/ord-1/cancel", nil)
+			req.Header.Set("X-User-Id", tc.userID)
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tc.wantCode, rr.Code, rr.Body.String())
+			}
+			if strings.Join(calls, ",") != strings.Join(tc.wantCalls, ",") {
+				t.Fatalf("expected upstream calls %v, got %v", tc.wantCalls, calls)
+			}
+			if tc.wantCode == http.StatusNotFound {
+				var body map[string]any
+				if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+					t.Fatalf("failed to decode body: %v", err)
+				}
+				if body["code"] != "order_not_found" || body["instance"] != "/me/orders/ord-1/cancel" {
+					t.Fatalf("unexpected problem %v", body)
+				}
+			}
+		})
+	}
+}
+
 func TestForwardingBodyHeadersAndHopByHopStripping(t *testing.T) {
 	srv, ch := newStubServer(t)
 	defer srv.Close()
@@ -14,6 +14,7 @@ func CorrelationID(next http.Handler) http.Handler {
 		cid := r.Header.Get(HeaderCorrelationID)
 		if cid == "" {
 			cid = uuid.NewString()
+			r.Header.Set(HeaderCorrelationID, cid)
 		}
 
 		// expose to client + propagate downstream
@@ -41,7 +41,8 @@ func writeCORSHeaders(w http.ResponseWriter, origin string, allowOrigins []strin
 
 	w.Header().Set("Vary", "Origin")
 	w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,PATCH,DELETE,OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Correlation-Id, X-User-Id")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Correlation-Id, X-User-Id, X-Guest-Cart-Id, Idempotency-Key, If-Match")
+	w.Header().Set("Access-Control-Expose-Headers", "X-Correlation-Id, Idempotency-Key, Idempotent-Replayed, ETag, X-Guest-Cart-Id, Link, X-Next-Cursor")
 }
 
 func originAllowed(origin string, allow []string) bool {
@@ -1,11 +1,10 @@
 package middleware
 
 import (
-	"encoding/json"
 	"log"
 	"net/http"
 
-	"github.com/andreasstove999/ecommerce-system/api-gateway-go/internal/model"
+	"github.com/andreasstove999/ecommerce-system/api-gateway-go/internal/problem"
 )
 
 func Recover(logger *log.Logger) func(http.Handler) http.Handler {
@@ -14,12 +13,8 @@ func Recover(logger *log.Logger) func(http.Handler) http.Handler {
 			defer func() {
 				if rec := recover(); rec != nil {
 					logger.Printf("panic: %v", rec)
-					w.Header().Set("Content-Type", "application/json")
-					w.WriteHeader(http.StatusInternalServerError)
-					_ = json.NewEncoder(w).Encode(model.ErrorResponse{
-						Error:         "internal server error",
-						CorrelationID: GetCorrelationID(r.Context()),
-					})
+					d := problem.New(http.StatusInternalServerError, problem.CodeInternal, "internal server error")
+					problem.Write(w, r, &d)
 				}
 			}()
 			next.ServeHTTP(w, r)
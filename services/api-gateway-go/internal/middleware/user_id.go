@@ -2,11 +2,10 @@ package middleware
 
 import (
 	"context"
-	"encoding/json"
 	"net/http"
 	"strings"
 
-	"github.com/andreasstove999/ecommerce-system/api-gateway-go/internal/model"
+	"github.com/andreasstove999/ecommerce-system/api-gateway-go/internal/problem"
 )
 
 const HeaderUserID = "X-User-Id"
@@ -27,12 +26,9 @@ func RequireUserIDForMeRoutes(next http.Handler) http.Handler {
 		if path == "/me" || strings.HasPrefix(path, "/me/") {
 			uid := strings.TrimSpace(r.Header.Get(HeaderUserID))
 			if uid == "" {
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusBadRequest)
-				_ = json.NewEncoder(w).Encode(model.ErrorResponse{
-					Error:         "missing required header: X-User-Id",
-					CorrelationID: GetCorrelationID(r.Context()),
-				})
+				msg := "missing required header: X-User-Id"
+				d := problem.Invalid(msg, problem.FieldError{Field: HeaderUserID, Code: problem.FieldRequired, Message: msg})
+				problem.Write(w, r, &d)
 				return
 			}
 			ctx := context.WithValue(r.Context(), ctxUserID, uid)
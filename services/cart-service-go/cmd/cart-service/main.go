@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -13,6 +16,14 @@ import (
 	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/db"
 	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/events"
 	httpserver "github.com/andreasstove999/ecommerce-system/cart-service-go/internal/http"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/idempotency"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/inventory"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/outbox"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/pricing"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/promotion"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/rules"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/tax"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/wishlist"
 )
 
 func main() {
@@ -20,26 +31,94 @@ func main() {
 
 	logger := log.New(os.Stdout, "[cart-service] ", log.LstdFlags|log.Lshortfile)
 
-	dsn := db.GetDSN()
-	if err := db.RunMigrations(dsn, logger); err != nil {
-		logger.Fatalf("run migrations: %v", err)
+	idemTTL := getEnvDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour)
+	var store storage
+	switch driver := getEnv("STORAGE_DRIVER", "postgres"); driver {
+	case "postgres":
+		dsn := db.GetDSN()
+		if err := db.RunMigrations(dsn, logger); err != nil {
+			logger.Fatalf("run migrations: %v", err)
+		}
+
+		// Open DB and create repository
+		database := db.MustOpen()
+		defer database.Close()
+		store = postgresStorage(database, idemTTL)
+	case "memory":
+		logger.Printf("STORAGE_DRIVER=memory: carts are kept in memory and lost on restart")
+		store = memoryStorage(idemTTL)
+	default:
+		logger.Fatalf("invalid STORAGE_DRIVER %q: want postgres or memory", driver)
 	}
+	cartRepo := store.carts
+	idemStore := store.idempotency
+
+	var cartPublisher eventsPublisher
+	switch driver := getEnv("BROKER_DRIVER", "rabbitmq"); driver {
+	case "rabbitmq":
+		rabbitConn := events.MustDialRabbit()
+		defer rabbitConn.Close()
 
-	// Open DB and create repository
-	database := db.MustOpen()
-	defer database.Close()
-	cartRepo := cart.NewRepository(database)
+		rabbitPublisher, err := events.NewRabbitCartEventsPublisher(rabbitConn)
+		if err != nil {
+			logger.Fatalf("failed to create cart publisher: %v", err)
+		}
+		cartPublisher = rabbitPublisher
+	case "memory":
+		logger.Printf("BROKER_DRIVER=memory: events are logged instead of published")
+		cartPublisher = events.NewInMemoryCartEventsPublisher(logger)
+	default:
+		logger.Fatalf("invalid BROKER_DRIVER %q: want rabbitmq or memory", driver)
+	}
 
-	rabbitConn := events.MustDialRabbit()
-	defer rabbitConn.Close()
+	var prices pricing.PriceSource
+	switch driver := getEnv("PRICING_DRIVER", "catalog"); driver {
+	case "catalog":
+		prices = pricing.NewCatalogSource(getEnv("CATALOG_URL", "http://catalog-service-java:8086"), nil)
+	case "memory":
+		static, err := pricing.ParseStaticPrices(os.Getenv("PRICING_STATIC_PRICES"))
+		if err != nil {
+			logger.Fatalf("invalid PRICING_STATIC_PRICES: %v", err)
+		}
+		logger.Printf("PRICING_DRIVER=memory: %d products priced from PRICING_STATIC_PRICES", len(static))
+		prices = pricing.NewInMemorySource(static)
+	default:
+		logger.Fatalf("invalid PRICING_DRIVER %q: want catalog or memory", driver)
+	}
 
-	sequenceRepo := events.NewSequenceRepository(database)
-	cartPublisher, err := events.NewRabbitCartEventsPublisher(rabbitConn, sequenceRepo)
+	mergeStrategy, err := cart.ParseMergeStrategy(getEnv("CART_MERGE_STRATEGY", string(cart.MergeSum)))
 	if err != nil {
-		logger.Fatalf("failed to create cart publisher: %v", err)
+		logger.Fatalf("invalid CART_MERGE_STRATEGY: %v", err)
 	}
 
-	mux := httpserver.NewRouter(cartRepo, cartPublisher)
+	// Stock holds are optional; without an inventory URL carts take no holds.
+	var holds inventory.Holds
+	if inventoryURL := os.Getenv("INVENTORY_URL"); inventoryURL != "" {
+		holds = inventory.NewClient(inventoryURL, nil)
+	}
+
+	rulesCfg, err := rules.LoadConfig(os.Getenv)
+	if err != nil {
+		logger.Fatalf("invalid cart rules: %v", err)
+	}
+	cartRules, err := rules.New(rulesCfg)
+	if err != nil {
+		logger.Fatalf("invalid cart rules: %v", err)
+	}
+
+	mux := httpserver.NewRouter(cartRepo, cartPublisher, prices, idemStore, store.promotions, store.taxes, httpserver.Config{
+		MergeStrategy: mergeStrategy,
+		DefaultTaxLocation: tax.Location{
+			Country: strings.ToUpper(os.Getenv("TAX_DEFAULT_COUNTRY")),
+			Region:  strings.ToUpper(os.Getenv("TAX_DEFAULT_REGION")),
+		},
+		Holds:          holds,
+		HoldTTL:        getEnvDuration("CART_HOLD_TTL", httpserver.DefaultHoldTTL),
+		Wishlists:      store.wishlists,
+		ActivityEvents: getEnvBool("CART_ACTIVITY_EVENTS", false),
+		Rules:          cartRules,
+		Logger:         logger,
+	})
 
 	srv := &http.Server{
 		Addr:         ":" + port,
@@ -51,6 +130,49 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	// Outbox relay publishes events committed together with cart changes.
+	relayCfg := outbox.DefaultRelayConfig()
+	relayCfg.PollInterval = getEnvDuration("OUTBOX_POLL_INTERVAL", relayCfg.PollInterval)
+	relayCfg.MaxBackoff = getEnvDuration("OUTBOX_MAX_BACKOFF", relayCfg.MaxBackoff)
+	relayCfg.Retention = getEnvDuration("OUTBOX_RETENTION", relayCfg.Retention)
+	relay := outbox.NewRelay(store.outbox, cartPublisher, relayCfg, logger)
+
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		relay.Run(relayCtx)
+	}()
+
+	// Abandoned cart sweeper reports idle carts through the same outbox.
+	sweepCfg := cart.DefaultSweeperConfig()
+	sweepCfg.Interval = getEnvDuration("CART_ABANDON_SWEEP_INTERVAL", sweepCfg.Interval)
+	sweepCfg.AbandonAfter = getEnvDuration("CART_ABANDON_AFTER", sweepCfg.AbandonAfter)
+	sweepCfg.ExpireAfter = getEnvDuration("CART_EXPIRE_AFTER", sweepCfg.ExpireAfter)
+	if sweepCfg.ExpireAfter > 0 && sweepCfg.ExpireAfter <= sweepCfg.AbandonAfter {
+		logger.Printf("CART_EXPIRE_AFTER (%s) is not above CART_ABANDON_AFTER (%s); some carts will expire without a CartAbandoned event", sweepCfg.ExpireAfter, sweepCfg.AbandonAfter)
+	}
+	sweeper := cart.NewSweeper(store.abandoned, cartPublisher.CartAbandonedEvent, sweepCfg, logger)
+	go sweeper.Run(ctx)
+
+	// Expired idempotency keys are only ignored on lookup; remove them periodically.
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if n, err := idemStore.DeleteExpired(ctx); err != nil {
+					logger.Printf("idempotency cleanup: %v", err)
+				} else if n > 0 {
+					logger.Printf("idempotency cleanup: removed %d keys", n)
+				}
+			}
+		}
+	}()
+
 	errCh := make(chan error, 1)
 	go func() {
 		logger.Printf("cart-service listening on :%s", port)
@@ -72,14 +194,96 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		logger.Printf("graceful shutdown error: %v", err)
 	}
+	// Stop polling, then flush what the last requests committed before closing the channel.
+	stopRelay()
+	<-relayDone
+	relay.Drain(shutdownCtx)
 	if err := cartPublisher.Close(); err != nil {
 		logger.Printf("publisher close error: %v", err)
 	}
 }
 
+// eventsPublisher builds the cart's events and publishes them for the outbox relay.
+type eventsPublisher interface {
+	httpserver.CartEventsPublisher
+	outbox.Publisher
+	CartAbandonedEvent(c *cart.Cart) outbox.Event
+	Close() error
+}
+
+// storage is everything the service keeps, backed by Postgres or by memory.
+type storage struct {
+	carts       cart.Repository
+	abandoned   cart.AbandonedStore
+	idempotency idempotency.Store
+	promotions  promotion.Store
+	taxes       tax.Rules
+	wishlists   wishlist.Repository
+	outbox      outbox.Store
+}
+
+func postgresStorage(database *sql.DB, idemTTL time.Duration) storage {
+	return storage{
+		carts:       cart.NewRepository(database),
+		abandoned:   cart.NewAbandonedStore(database),
+		idempotency: idempotency.NewStore(database, idemTTL),
+		promotions:  promotion.NewStore(database),
+		taxes:       tax.NewStore(database),
+		wishlists:   wishlist.NewStore(database),
+		outbox:      outbox.NewStore(database),
+	}
+}
+
+// memoryStorage keeps everything in memory for local runs without a
+// database. It starts with no promotions or tax rates.
+func memoryStorage(idemTTL time.Duration) storage {
+	stores := cart.InMemoryStores{
+		Outbox:      outbox.NewInMemoryStore(),
+		Promotions:  promotion.NewInMemoryStore(),
+		Wishlists:   wishlist.NewInMemoryRepository(),
+		Idempotency: idempotency.NewInMemoryStore(idemTTL),
+	}
+	carts := cart.NewInMemoryRepository(stores)
+	return storage{
+		carts:       carts,
+		abandoned:   carts,
+		idempotency: stores.Idempotency,
+		promotions:  stores.Promotions,
+		taxes:       tax.NewInMemoryRules(),
+		wishlists:   stores.Wishlists,
+		outbox:      stores.Outbox,
+	}
+}
+
 func getEnv(key, def string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
 	}
 	return def
 }
+
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("invalid %s=%q, using default %s", key, v, def)
+		return def
+	}
+	return d
+}
+
+func getEnvBool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("invalid %s=%q, using default %t", key, v, def)
+		return def
+	}
+	return b
+}
@@ -10,4 +10,6 @@ require github.com/google/uuid v1.6.0
 
 require github.com/rabbitmq/amqp091-go v1.10.0
 
+require github.com/DATA-DOG/go-sqlmock v1.5.2
+
 replace go.uber.org/goleak => ./internal/tools/goleak
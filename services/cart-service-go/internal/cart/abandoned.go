@@ -0,0 +1,199 @@
+package cart
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/outbox"
+)
+
+// AbandonedStore gives the sweeper access to idle carts.
+type AbandonedStore interface {
+	// MarkAbandoned picks up to limit non-empty user carts last changed before
+	// idleSince that have not been reported since that change. For each it
+	// records the event built by newEvent in the outbox and sets abandoned_at,
+	// all in one transaction. It returns the number of carts marked.
+	MarkAbandoned(ctx context.Context, idleSince time.Time, limit int, newEvent func(*Cart) outbox.Event) (int, error)
+	// DeleteIdle removes carts, guest carts included, last changed before the
+	// given time.
+	DeleteIdle(ctx context.Context, before time.Time) (int64, error)
+}
+
+type abandonedStore struct {
+	db *sql.DB
+}
+
+func NewAbandonedStore(db *sql.DB) AbandonedStore {
+	return &abandonedStore{db: db}
+}
+
+func (s *abandonedStore) MarkAbandoned(ctx context.Context, idleSince time.Time, limit int, newEvent func(*Cart) outbox.Event) (n int, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// Guest carts have nobody to remind. SKIP LOCKED lets several instances
+	// sweep at once and keeps the sweep from waiting on carts being edited.
+	const idleSQL = `
+SELECT c.user_id
+FROM carts c
+WHERE c.updated_at < $1
+  AND (c.abandoned_at IS NULL OR c.abandoned_at < c.updated_at)
+  AND c.user_id NOT LIKE $2
+  AND EXISTS (SELECT 1 FROM cart_items i WHERE i.cart_id = c.id)
+ORDER BY c.updated_at
+LIMIT $3
+FOR UPDATE SKIP LOCKED
+`
+	rows, err := tx.QueryContext(ctx, idleSQL, idleSince, GuestIDPrefix+"%", limit)
+	if err != nil {
+		return 0, err
+	}
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err = rows.Scan(&userID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, userID := range userIDs {
+		var c *Cart
+		if c, err = loadCart(ctx, tx, userID); err != nil {
+			return 0, err
+		}
+		if c == nil {
+			continue
+		}
+
+		if err = outbox.Enqueue(ctx, tx, newEvent(c)); err != nil {
+			return 0, err
+		}
+		// abandoned_at is bookkeeping only: neither updated_at nor version change,
+		// so the cart's ETag stays valid.
+		if _, err = tx.ExecContext(ctx, `UPDATE carts SET abandoned_at = NOW() WHERE id = $1`, c.ID); err != nil {
+			return 0, err
+		}
+		n++
+	}
+
+	err = tx.Commit()
+	return n, err
+}
+
+func (s *abandonedStore) DeleteIdle(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM carts WHERE updated_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// SweeperConfig controls when carts count as abandoned and when they expire.
+type SweeperConfig struct {
+	Interval     time.Duration
+	AbandonAfter time.Duration
+	// ExpireAfter deletes carts idle for longer than this. Zero keeps them.
+	ExpireAfter time.Duration
+	BatchSize   int
+}
+
+// DefaultSweeperConfig returns the settings used when nothing is configured.
+func DefaultSweeperConfig() SweeperConfig {
+	return SweeperConfig{
+		Interval:     5 * time.Minute,
+		AbandonAfter: 24 * time.Hour,
+		BatchSize:    100,
+	}
+}
+
+// Sweeper periodically reports idle carts as abandoned and, when configured,
+// deletes carts that have been idle for much longer.
+type Sweeper struct {
+	store    AbandonedStore
+	newEvent func(*Cart) outbox.Event
+	cfg      SweeperConfig
+	logger   *log.Logger
+	now      func() time.Time
+}
+
+func NewSweeper(store AbandonedStore, newEvent func(*Cart) outbox.Event, cfg SweeperConfig, logger *log.Logger) *Sweeper {
+	def := DefaultSweeperConfig()
+	if cfg.Interval <= 0 {
+		cfg.Interval = def.Interval
+	}
+	if cfg.AbandonAfter <= 0 {
+		cfg.AbandonAfter = def.AbandonAfter
+	}
+	if cfg.ExpireAfter < 0 {
+		cfg.ExpireAfter = 0
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = def.BatchSize
+	}
+	return &Sweeper{store: store, newEvent: newEvent, cfg: cfg, logger: logger, now: time.Now}
+}
+
+// Run sweeps every Interval until ctx is cancelled.
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Sweep(ctx); err != nil && ctx.Err() == nil {
+				s.logger.Printf("abandoned cart sweep: %v", err)
+			}
+		}
+	}
+}
+
+// Sweep marks every cart that is currently idle past AbandonAfter, batch by
+// batch, and then deletes expired carts. Carts are reported before they can
+// expire so an expiring cart is still announced once.
+func (s *Sweeper) Sweep(ctx context.Context) error {
+	now := s.now()
+
+	total := 0
+	for {
+		n, err := s.store.MarkAbandoned(ctx, now.Add(-s.cfg.AbandonAfter), s.cfg.BatchSize, s.newEvent)
+		if err != nil {
+			return err
+		}
+		total += n
+		if n < s.cfg.BatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		s.logger.Printf("abandoned cart sweep: marked %d carts", total)
+	}
+
+	if s.cfg.ExpireAfter > 0 {
+		n, err := s.store.DeleteIdle(ctx, now.Add(-s.cfg.ExpireAfter))
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			s.logger.Printf("abandoned cart sweep: expired %d carts", n)
+		}
+	}
+	return nil
+}
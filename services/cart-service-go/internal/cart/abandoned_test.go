@@ -0,0 +1,149 @@
+package cart
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/outbox"
+)
+
+func TestMarkAbandonedEnqueuesAndMarks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	idleSince := time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+	updatedAt := idleSince.Add(-time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("FOR UPDATE SKIP LOCKED")).
+		WithArgs(idleSince, "guest-%", 10).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("u1"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, user_id, subtotal_minor, currency, tax_country, tax_region, version, updated_at FROM carts")).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "subtotal_minor", "currency", "tax_country", "tax_region", "version", "updated_at"}).
+			AddRow("c1", "u1", int64(400), "USD", "", "", int64(2), updatedAt))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT product_id, quantity, price_minor, currency, priced_at FROM cart_items")).
+		WithArgs("c1").
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity", "price_minor", "currency", "priced_at"}).
+			AddRow("p1", 2, int64(200), "USD", updatedAt))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT code FROM cart_coupons")).
+		WithArgs("c1").
+		WillReturnRows(sqlmock.NewRows([]string{"code"}))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO event_sequences")).
+		WithArgs("c1").
+		WillReturnRows(sqlmock.NewRows([]string{"last_sequence"}).AddRow(int64(5)))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox")).
+		WithArgs("c1", int64(5), "cart.abandoned.v1", []byte("body")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE carts SET abandoned_at = NOW() WHERE id = $1")).
+		WithArgs("c1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var got *Cart
+	newEvent := func(c *Cart) outbox.Event {
+		got = c
+		return outbox.Event{
+			PartitionKey: c.ID,
+			RoutingKey:   "cart.abandoned.v1",
+			Encode:       func(int64) ([]byte, error) { return []byte("body"), nil },
+		}
+	}
+
+	n, err := NewAbandonedStore(db).MarkAbandoned(context.Background(), idleSince, 10, newEvent)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 cart marked, got %d", n)
+	}
+	if got == nil || len(got.Items) != 1 || !got.UpdatedAt.Equal(updatedAt) {
+		t.Fatalf("expected the loaded cart to be passed to newEvent, got %+v", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+type fakeAbandonedStore struct {
+	batches   []int
+	idleSince []time.Time
+	deleted   []time.Time
+	err       error
+}
+
+func (f *fakeAbandonedStore) MarkAbandoned(_ context.Context, idleSince time.Time, _ int, _ func(*Cart) outbox.Event) (int, error) {
+	f.idleSince = append(f.idleSince, idleSince)
+	if f.err != nil {
+		return 0, f.err
+	}
+	if len(f.batches) == 0 {
+		return 0, nil
+	}
+	n := f.batches[0]
+	f.batches = f.batches[1:]
+	return n, nil
+}
+
+func (f *fakeAbandonedStore) DeleteIdle(_ context.Context, before time.Time) (int64, error) {
+	f.deleted = append(f.deleted, before)
+	return 0, nil
+}
+
+func TestSweeperSweep(t *testing.T) {
+	now := time.Date(2024, time.May, 2, 0, 0, 0, 0, time.UTC)
+	logger := log.New(io.Discard, "", 0)
+
+	t.Run("drains full batches", func(t *testing.T) {
+		store := &fakeAbandonedStore{batches: []int{2, 2, 1}}
+		s := NewSweeper(store, nil, SweeperConfig{AbandonAfter: time.Hour, BatchSize: 2}, logger)
+		s.now = func() time.Time { return now }
+
+		if err := s.Sweep(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(store.idleSince) != 3 {
+			t.Fatalf("expected 3 batches, got %d", len(store.idleSince))
+		}
+		if !store.idleSince[0].Equal(now.Add(-time.Hour)) {
+			t.Fatalf("unexpected idle threshold %s", store.idleSince[0])
+		}
+		if len(store.deleted) != 0 {
+			t.Fatalf("expected no expiry when ExpireAfter is zero")
+		}
+	})
+
+	t.Run("expires old carts", func(t *testing.T) {
+		store := &fakeAbandonedStore{}
+		s := NewSweeper(store, nil, SweeperConfig{AbandonAfter: time.Hour, ExpireAfter: 30 * 24 * time.Hour}, logger)
+		s.now = func() time.Time { return now }
+
+		if err := s.Sweep(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(store.deleted) != 1 || !store.deleted[0].Equal(now.Add(-30*24*time.Hour)) {
+			t.Fatalf("unexpected expiry calls %v", store.deleted)
+		}
+	})
+
+	t.Run("mark failure skips expiry", func(t *testing.T) {
+		store := &fakeAbandonedStore{err: errors.New("db down")}
+		s := NewSweeper(store, nil, SweeperConfig{ExpireAfter: time.Hour}, logger)
+
+		if err := s.Sweep(context.Background()); err == nil {
+			t.Fatalf("expected error")
+		}
+		if len(store.deleted) != 0 {
+			t.Fatalf("expected no expiry after a failed mark")
+		}
+	})
+}
@@ -0,0 +1,567 @@
+// Package carttest holds the behaviour every cart.Repository must share, so
+// the in-memory repository can be checked against the Postgres one.
+package carttest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/idempotency"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/outbox"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/promotion"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/wishlist"
+	"github.com/google/uuid"
+)
+
+// Harness is a repository under test together with the stores it writes to
+// in the same transaction. Every call of the factory passed to
+// RunRepositoryTests must return a harness with nothing stored.
+type Harness struct {
+	Repo        cart.Repository
+	Wishlists   wishlist.Repository
+	Idempotency idempotency.Store
+	// AddPromotion stores p so checkouts can redeem it.
+	AddPromotion func(t *testing.T, p promotion.Promotion)
+	// Outbox returns every event recorded so far, oldest first.
+	Outbox func(t *testing.T) []outbox.Message
+}
+
+// RunRepositoryTests checks the cart.Repository contract against the
+// repositories newHarness returns.
+func RunRepositoryTests(t *testing.T, newHarness func(t *testing.T) Harness) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, h Harness)
+	}{
+		{"GetCartMissing", testGetCartMissing},
+		{"AddItem", testAddItem},
+		{"AddItemCurrencyMismatch", testAddItemCurrencyMismatch},
+		{"AddItemCheck", testAddItemCheck},
+		{"AddItems", testAddItems},
+		{"UpsertCart", testUpsertCart},
+		{"UpsertCartVersionConflict", testUpsertCartVersionConflict},
+		{"UpsertCartKeepsCouponOrder", testUpsertCartKeepsCouponOrder},
+		{"ClearCart", testClearCart},
+		{"ClearCartRecordsActivity", testClearCartRecordsActivity},
+		{"MergeCarts", testMergeCarts},
+		{"MergeCartsGuestChanged", testMergeCartsGuestChanged},
+		{"CheckoutCart", testCheckoutCart},
+		{"CheckoutCartConflicts", testCheckoutCartConflicts},
+		{"CheckoutCartUsageLimit", testCheckoutCartUsageLimit},
+		{"MoveToWishlist", testMoveToWishlist},
+		{"MoveFromWishlist", testMoveFromWishlist},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newHarness(t))
+		})
+	}
+}
+
+func usd(amount int64) money.Money {
+	return money.New(amount, "USD")
+}
+
+func item(productID string, quantity int, price int64) cart.Item {
+	return cart.Item{ProductID: productID, Quantity: quantity, Price: usd(price), PricedAt: time.Now().UTC().Truncate(time.Millisecond)}
+}
+
+// newCart stores a cart for userID holding items and returns it as stored.
+func newCart(t *testing.T, h Harness, userID string, items ...cart.Item) *cart.Cart {
+	t.Helper()
+	c := &cart.Cart{UserID: userID, Items: items}
+	if err := c.RecalculateTotal(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if err := h.Repo.UpsertCart(context.Background(), c, nil); err != nil {
+		t.Fatalf("save cart: %v", err)
+	}
+	return mustGet(t, h, userID)
+}
+
+func mustGet(t *testing.T, h Harness, userID string) *cart.Cart {
+	t.Helper()
+	c, err := h.Repo.GetCart(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("get cart: %v", err)
+	}
+	if c == nil {
+		t.Fatalf("expected a cart for %s", userID)
+	}
+	return c
+}
+
+func quantities(c *cart.Cart) map[string]int {
+	q := make(map[string]int, len(c.Items))
+	for _, it := range c.Items {
+		q[it.ProductID] = it.Quantity
+	}
+	return q
+}
+
+// event returns an outbox event whose body names its partition and sequence.
+func event(partitionKey, routingKey string) outbox.Event {
+	return outbox.Event{
+		PartitionKey: partitionKey,
+		RoutingKey:   routingKey,
+		Encode: func(sequence int64) ([]byte, error) {
+			return []byte(routingKey), nil
+		},
+	}
+}
+
+func testGetCartMissing(t *testing.T, h Harness) {
+	c, err := h.Repo.GetCart(context.Background(), "nobody")
+	if err != nil || c != nil {
+		t.Fatalf("expected no cart and no error, got %+v, %v", c, err)
+	}
+}
+
+func testAddItem(t *testing.T, h Harness) {
+	ctx := context.Background()
+
+	c, err := h.Repo.AddItem(ctx, "u1", item("p1", 2, 500), nil, nil)
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if c.ID == "" || c.Version != 1 || c.Subtotal != usd(1000) || c.Total != usd(1000) {
+		t.Fatalf("unexpected new cart %+v", c)
+	}
+
+	if c, err = h.Repo.AddItem(ctx, "u1", item("p1", 1, 600), nil, nil); err != nil {
+		t.Fatalf("add again: %v", err)
+	}
+	if c, err = h.Repo.AddItem(ctx, "u1", item("p2", 1, 100), nil, nil); err != nil {
+		t.Fatalf("add other: %v", err)
+	}
+	if c.Version != 3 {
+		t.Fatalf("expected version 3, got %d", c.Version)
+	}
+	if q := quantities(c); q["p1"] != 3 || q["p2"] != 1 {
+		t.Fatalf("unexpected quantities %v", q)
+	}
+	// The last add reprices the whole line.
+	if c.Subtotal != usd(3*600+100) {
+		t.Fatalf("unexpected subtotal %+v", c.Subtotal)
+	}
+
+	got := mustGet(t, h, "u1")
+	if got.ID != c.ID || got.Version != c.Version || got.Subtotal != c.Subtotal || len(got.Items) != 2 {
+		t.Fatalf("stored cart %+v differs from returned %+v", got, c)
+	}
+}
+
+func testAddItemCurrencyMismatch(t *testing.T, h Harness) {
+	ctx := context.Background()
+	if _, err := h.Repo.AddItem(ctx, "u1", item("p1", 1, 500), nil, nil); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	eur := cart.Item{ProductID: "p2", Quantity: 1, Price: money.New(500, "EUR")}
+	if _, err := h.Repo.AddItem(ctx, "u1", eur, nil, nil); !errors.Is(err, money.ErrCurrencyMismatch) {
+		t.Fatalf("expected ErrCurrencyMismatch, got %v", err)
+	}
+	if c := mustGet(t, h, "u1"); c.Version != 1 || len(c.Items) != 1 {
+		t.Fatalf("expected the cart unchanged, got %+v", c)
+	}
+}
+
+func testAddItemCheck(t *testing.T, h Harness) {
+	ctx := context.Background()
+	var seen []*cart.Cart
+	check := func(ctx context.Context, before *cart.Cart) error {
+		seen = append(seen, before)
+		return nil
+	}
+
+	if _, err := h.Repo.AddItem(ctx, "u1", item("p1", 2, 500), check, nil); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if _, err := h.Repo.AddItems(ctx, "u1", []cart.Item{item("p1", 1, 500)}, check, nil); err != nil {
+		t.Fatalf("add items: %v", err)
+	}
+	if len(seen) != 2 || (seen[0] != nil && len(seen[0].Items) != 0) {
+		t.Fatalf("expected the first check to find no lines, got %+v", seen)
+	}
+	if q := quantities(seen[1]); q["p1"] != 2 {
+		t.Fatalf("expected the second check to find the first add, got %v", q)
+	}
+
+	// A failing check abandons the change.
+	errRejected := errors.New("rejected")
+	_, err := h.Repo.AddItem(ctx, "u1", item("p1", 1, 500), func(ctx context.Context, before *cart.Cart) error {
+		return errRejected
+	}, nil)
+	if !errors.Is(err, errRejected) {
+		t.Fatalf("expected the check's error, got %v", err)
+	}
+	if got := mustGet(t, h, "u1"); got.Version != 2 || quantities(got)["p1"] != 3 {
+		t.Fatalf("expected the cart unchanged, got %+v", got)
+	}
+}
+
+func testAddItems(t *testing.T, h Harness) {
+	ctx := context.Background()
+	if _, err := h.Repo.AddItem(ctx, "u1", item("p1", 1, 500), nil, nil); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	c, err := h.Repo.AddItems(ctx, "u1", []cart.Item{item("p1", 2, 500), item("p2", 1, 100), item("p3", 4, 50)}, nil, nil)
+	if err != nil {
+		t.Fatalf("add items: %v", err)
+	}
+	if c.Version != 2 {
+		t.Fatalf("expected one version for the batch, got %d", c.Version)
+	}
+	if q := quantities(c); q["p1"] != 3 || q["p2"] != 1 || q["p3"] != 4 {
+		t.Fatalf("unexpected quantities %v", q)
+	}
+	if c.Subtotal != usd(3*500+100+4*50) {
+		t.Fatalf("unexpected subtotal %+v", c.Subtotal)
+	}
+
+	// One line in another currency keeps every line out.
+	eur := cart.Item{ProductID: "p5", Quantity: 1, Price: money.New(500, "EUR")}
+	if _, err := h.Repo.AddItems(ctx, "u1", []cart.Item{item("p4", 1, 100), eur}, nil, nil); !errors.Is(err, money.ErrCurrencyMismatch) {
+		t.Fatalf("expected ErrCurrencyMismatch, got %v", err)
+	}
+	if got := mustGet(t, h, "u1"); got.Version != 2 || len(got.Items) != 3 {
+		t.Fatalf("expected the cart unchanged, got %+v", got)
+	}
+}
+
+func testUpsertCart(t *testing.T, h Harness) {
+	ctx := context.Background()
+
+	var before, after *cart.Cart
+	calls := 0
+	activity := func(b, a *cart.Cart) []outbox.Event {
+		calls++
+		before, after = b, a
+		return nil
+	}
+
+	c := &cart.Cart{UserID: "u1", Items: []cart.Item{item("p1", 2, 500)}, Coupons: []string{"SAVE10"}, TaxCountry: "US", TaxRegion: "NY"}
+	if err := c.RecalculateTotal(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if err := h.Repo.UpsertCart(ctx, c, activity); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if c.ID == "" || c.Version != 1 || c.UpdatedAt.IsZero() {
+		t.Fatalf("expected the saved ID, version and time on the cart, got %+v", c)
+	}
+	if calls != 1 || before != nil || after != c {
+		t.Fatalf("expected activity from no cart to the saved one, got %d calls, %+v -> %+v", calls, before, after)
+	}
+
+	got := mustGet(t, h, "u1")
+	if got.ID != c.ID || got.Version != 1 || got.Subtotal != usd(1000) || got.TaxCountry != "US" || got.TaxRegion != "NY" {
+		t.Fatalf("unexpected stored cart %+v", got)
+	}
+	if !slices.Equal(got.Coupons, []string{"SAVE10"}) || len(got.Items) != 1 || !got.Items[0].PricedAt.Equal(c.Items[0].PricedAt) {
+		t.Fatalf("unexpected stored lines or coupons %+v", got)
+	}
+
+	got.Items[0].Quantity = 5
+	if err := got.RecalculateTotal(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if err := h.Repo.UpsertCart(ctx, got, activity); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got.Version != 2 || before == nil || before.Version != 1 || before.Items[0].Quantity != 2 {
+		t.Fatalf("expected activity from version 1 to 2, got %+v -> %+v", before, got)
+	}
+	if c := mustGet(t, h, "u1"); c.Version != 2 || c.Subtotal != usd(2500) {
+		t.Fatalf("unexpected updated cart %+v", c)
+	}
+}
+
+func testUpsertCartVersionConflict(t *testing.T, h Harness) {
+	ctx := context.Background()
+	stored := newCart(t, h, "u1", item("p1", 1, 500))
+
+	// Someone who never saw the stored cart creates one anyway.
+	if err := h.Repo.UpsertCart(ctx, &cart.Cart{UserID: "u1"}, nil); !errors.Is(err, cart.ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict for a second new cart, got %v", err)
+	}
+
+	fresh := *stored
+	if err := h.Repo.UpsertCart(ctx, &fresh, nil); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	stale := *stored
+	stale.Items = nil
+	if err := h.Repo.UpsertCart(ctx, &stale, nil); !errors.Is(err, cart.ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict for a stale version, got %v", err)
+	}
+	if c := mustGet(t, h, "u1"); c.Version != 2 || len(c.Items) != 1 {
+		t.Fatalf("expected the stale save to change nothing, got %+v", c)
+	}
+}
+
+func testUpsertCartKeepsCouponOrder(t *testing.T, h Harness) {
+	ctx := context.Background()
+	c := newCart(t, h, "u1", item("p1", 1, 500))
+
+	c.Coupons = []string{"WELCOME"}
+	if err := h.Repo.UpsertCart(ctx, c, nil); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	c.Coupons = []string{"SAVE10", "WELCOME"}
+	if err := h.Repo.UpsertCart(ctx, c, nil); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if got := mustGet(t, h, "u1").Coupons; !slices.Equal(got, []string{"WELCOME", "SAVE10"}) {
+		t.Fatalf("expected coupons in the order entered, got %v", got)
+	}
+
+	c.Coupons = []string{"SAVE10"}
+	if err := h.Repo.UpsertCart(ctx, c, nil); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if got := mustGet(t, h, "u1").Coupons; !slices.Equal(got, []string{"SAVE10"}) {
+		t.Fatalf("expected the removed coupon gone, got %v", got)
+	}
+}
+
+func testClearCart(t *testing.T, h Harness) {
+	ctx := context.Background()
+
+	if err := h.Repo.ClearCart(ctx, "u1", 0, nil); err != nil {
+		t.Fatalf("clearing no cart: %v", err)
+	}
+	if err := h.Repo.ClearCart(ctx, "u1", 1, nil); !errors.Is(err, cart.ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict clearing no cart at a version, got %v", err)
+	}
+
+	c := newCart(t, h, "u1", item("p1", 1, 500))
+	if err := h.Repo.ClearCart(ctx, "u1", c.Version+1, nil); !errors.Is(err, cart.ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	if err := h.Repo.ClearCart(ctx, "u1", c.Version, nil); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	if got, _ := h.Repo.GetCart(ctx, "u1"); got != nil {
+		t.Fatalf("expected the cart gone, got %+v", got)
+	}
+}
+
+func testClearCartRecordsActivity(t *testing.T, h Harness) {
+	c := newCart(t, h, "u1", item("p1", 2, 500))
+
+	var before, after *cart.Cart
+	activity := func(b, a *cart.Cart) []outbox.Event {
+		before, after = b, a
+		return []outbox.Event{event(b.ID, "cart.cleared.v1")}
+	}
+	if err := h.Repo.ClearCart(context.Background(), "u1", 0, activity); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	if before == nil || before.ID != c.ID || len(before.Items) != 1 || after != nil {
+		t.Fatalf("expected the cleared cart and no cart after, got %+v / %+v", before, after)
+	}
+
+	msgs := h.Outbox(t)
+	if len(msgs) != 1 || msgs[0].PartitionKey != c.ID || msgs[0].Sequence != 1 || msgs[0].RoutingKey != "cart.cleared.v1" {
+		t.Fatalf("unexpected outbox %+v", msgs)
+	}
+}
+
+func testMergeCarts(t *testing.T, h Harness) {
+	ctx := context.Background()
+	guest := newCart(t, h, cart.GuestIDPrefix+"g1", item("p1", 1, 500))
+	c := &cart.Cart{UserID: "u1", Items: guest.Items, Coupons: []string{"SAVE10"}}
+	if err := c.RecalculateTotal(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+
+	var changes [][2]*cart.Cart
+	activity := func(b, a *cart.Cart) []outbox.Event {
+		changes = append(changes, [2]*cart.Cart{b, a})
+		return nil
+	}
+	if err := h.Repo.MergeCarts(ctx, c, guest, activity); err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+
+	if got, _ := h.Repo.GetCart(ctx, guest.UserID); got != nil {
+		t.Fatalf("expected the guest cart gone, got %+v", got)
+	}
+	if got := mustGet(t, h, "u1"); got.Version != 1 || quantities(got)["p1"] != 1 || !slices.Equal(got.Coupons, []string{"SAVE10"}) {
+		t.Fatalf("unexpected merged cart %+v", got)
+	}
+	if len(changes) != 2 || changes[0][0] != nil || changes[0][1] != c || changes[1][0] != guest || changes[1][1] != nil {
+		t.Fatalf("expected activity for the saved cart and the cleared guest cart, got %+v", changes)
+	}
+}
+
+func testMergeCartsGuestChanged(t *testing.T, h Harness) {
+	ctx := context.Background()
+	guest := newCart(t, h, cart.GuestIDPrefix+"g1", item("p1", 1, 500))
+	stored := newCart(t, h, "u1", item("p2", 1, 500))
+
+	stale := *guest
+	stale.Version++
+	merged := *stored
+	merged.Items = append(slices.Clone(stored.Items), guest.Items...)
+	if err := h.Repo.MergeCarts(ctx, &merged, &stale, nil); !errors.Is(err, cart.ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+
+	if got := mustGet(t, h, "u1"); got.Version != stored.Version || len(got.Items) != 1 {
+		t.Fatalf("expected the user's cart unchanged, got %+v", got)
+	}
+	mustGet(t, h, guest.UserID)
+}
+
+func testCheckoutCart(t *testing.T, h Harness) {
+	ctx := context.Background()
+	c := newCart(t, h, "u1", item("p1", 1, 500))
+
+	evs := []outbox.Event{event(c.ID, "cart.checkedout.v1"), event(c.ID, "cart.checkedout.v2")}
+	idem := &idempotency.Record{UserID: "u1", Key: "k1", StatusCode: 200, Body: []byte(`{"status":"checkout completed"}`)}
+	if err := h.Repo.CheckoutCart(ctx, c, evs, idem); err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+
+	if got, _ := h.Repo.GetCart(ctx, "u1"); got != nil {
+		t.Fatalf("expected the cart gone, got %+v", got)
+	}
+	msgs := h.Outbox(t)
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 outbox events, got %+v", msgs)
+	}
+	for i, m := range msgs {
+		if m.PartitionKey != c.ID || m.Sequence != int64(i+1) || m.RoutingKey != evs[i].RoutingKey || string(m.Payload) != evs[i].RoutingKey {
+			t.Fatalf("unexpected outbox event %d: %+v", i, m)
+		}
+	}
+	rec, err := h.Idempotency.Lookup(ctx, "u1", "k1")
+	if err != nil || rec == nil || rec.StatusCode != 200 || string(rec.Body) != string(idem.Body) {
+		t.Fatalf("expected the response stored, got %+v, %v", rec, err)
+	}
+
+	if err := h.Repo.CheckoutCart(ctx, c, evs, nil); !errors.Is(err, cart.ErrCartNotFound) {
+		t.Fatalf("expected ErrCartNotFound checking out again, got %v", err)
+	}
+}
+
+func testCheckoutCartConflicts(t *testing.T, h Harness) {
+	ctx := context.Background()
+	c := newCart(t, h, "u1", item("p1", 1, 500))
+
+	stale := *c
+	stale.Version--
+	if err := h.Repo.CheckoutCart(ctx, &stale, []outbox.Event{event(c.ID, "cart.checkedout.v1")}, nil); !errors.Is(err, cart.ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	mustGet(t, h, "u1")
+	if msgs := h.Outbox(t); len(msgs) != 0 {
+		t.Fatalf("expected nothing recorded, got %+v", msgs)
+	}
+}
+
+func testCheckoutCartUsageLimit(t *testing.T, h Harness) {
+	ctx := context.Background()
+	p := promotion.Promotion{ID: uuid.NewString(), Code: "ONCE", Kind: promotion.KindPercentage, PercentOff: 10, AmountOff: usd(0), MinSpend: usd(0), MaxUsesPerUser: 1, Active: true}
+	h.AddPromotion(t, p)
+	adjustments := []cart.Adjustment{{Code: "ONCE", PromotionID: p.ID, Amount: usd(-50)}}
+
+	first := newCart(t, h, "u1", item("p1", 1, 500))
+	first.Adjustments = adjustments
+	if err := h.Repo.CheckoutCart(ctx, first, []outbox.Event{event(first.ID, "cart.checkedout.v1")}, nil); err != nil {
+		t.Fatalf("first checkout: %v", err)
+	}
+
+	second := newCart(t, h, "u1", item("p1", 1, 500))
+	second.Adjustments = adjustments
+	err := h.Repo.CheckoutCart(ctx, second, []outbox.Event{event(second.ID, "cart.checkedout.v1")}, nil)
+	if !errors.Is(err, promotion.ErrUsageLimitReached) {
+		t.Fatalf("expected ErrUsageLimitReached, got %v", err)
+	}
+	mustGet(t, h, "u1")
+	if msgs := h.Outbox(t); len(msgs) != 1 {
+		t.Fatalf("expected only the first checkout recorded, got %+v", msgs)
+	}
+}
+
+func testMoveToWishlist(t *testing.T, h Harness) {
+	ctx := context.Background()
+	c := newCart(t, h, "u1", item("p1", 2, 500), item("p2", 1, 500))
+
+	moved := *c
+	moved.Items = c.Items[1:]
+	if err := moved.RecalculateTotal(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	unknown := uuid.NewString()
+	if err := h.Repo.MoveToWishlist(ctx, &moved, unknown, wishlist.Item{ProductID: "p1", Quantity: 2}, nil); !errors.Is(err, wishlist.ErrNotFound) {
+		t.Fatalf("expected wishlist.ErrNotFound, got %v", err)
+	}
+	if got := mustGet(t, h, "u1"); got.Version != c.Version || len(got.Items) != 2 {
+		t.Fatalf("expected the cart unchanged, got %+v", got)
+	}
+
+	moved = *c
+	moved.Items = c.Items[1:]
+	if err := moved.RecalculateTotal(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if err := h.Repo.MoveToWishlist(ctx, &moved, "", wishlist.Item{ProductID: "p1", Quantity: 2}, nil); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	if got := mustGet(t, h, "u1"); got.Version != c.Version+1 || len(got.Items) != 1 || got.Items[0].ProductID != "p2" {
+		t.Fatalf("unexpected cart after the move %+v", got)
+	}
+
+	lists, err := h.Wishlists.List(ctx, "u1")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(lists) != 1 || lists[0].Name != wishlist.SavedForLater || len(lists[0].Items) != 1 || lists[0].Items[0].Quantity != 2 {
+		t.Fatalf("expected the line saved for later, got %+v", lists)
+	}
+}
+
+func testMoveFromWishlist(t *testing.T, h Harness) {
+	ctx := context.Background()
+	list, err := h.Wishlists.Create(ctx, "u1", "Birthday")
+	if err != nil {
+		t.Fatalf("create list: %v", err)
+	}
+	if _, err := h.Wishlists.AddItem(ctx, "u1", list.ID, wishlist.Item{ProductID: "p1", Quantity: 1}); err != nil {
+		t.Fatalf("add to list: %v", err)
+	}
+
+	c := &cart.Cart{UserID: "u1", Items: []cart.Item{item("p2", 1, 500)}}
+	if err := c.RecalculateTotal(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if err := h.Repo.MoveFromWishlist(ctx, c, list.ID, "p2", nil); !errors.Is(err, wishlist.ErrItemNotFound) {
+		t.Fatalf("expected wishlist.ErrItemNotFound, got %v", err)
+	}
+	if got, _ := h.Repo.GetCart(ctx, "u1"); got != nil {
+		t.Fatalf("expected no cart saved, got %+v", got)
+	}
+
+	c = &cart.Cart{UserID: "u1", Items: []cart.Item{item("p1", 1, 500)}}
+	if err := c.RecalculateTotal(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if err := h.Repo.MoveFromWishlist(ctx, c, list.ID, "p1", nil); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	if got := mustGet(t, h, "u1"); got.Version != 1 || quantities(got)["p1"] != 1 {
+		t.Fatalf("unexpected cart after the move %+v", got)
+	}
+	if w, _ := h.Wishlists.Get(ctx, "u1", list.ID); w == nil || len(w.Items) != 0 {
+		t.Fatalf("expected the item taken off the list, got %+v", w)
+	}
+}
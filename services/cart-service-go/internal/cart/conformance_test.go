@@ -0,0 +1,112 @@
+package cart_test
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart/carttest"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/db"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/idempotency"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/outbox"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/promotion"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/wishlist"
+	_ "github.com/lib/pq"
+)
+
+func TestInMemoryRepositoryConformance(t *testing.T) {
+	carttest.RunRepositoryTests(t, func(t *testing.T) carttest.Harness {
+		stores := cart.InMemoryStores{
+			Outbox:      outbox.NewInMemoryStore(),
+			Promotions:  promotion.NewInMemoryStore(),
+			Wishlists:   wishlist.NewInMemoryRepository(),
+			Idempotency: idempotency.NewInMemoryStore(time.Hour),
+		}
+		return carttest.Harness{
+			Repo:        cart.NewInMemoryRepository(stores),
+			Wishlists:   stores.Wishlists,
+			Idempotency: stores.Idempotency,
+			AddPromotion: func(t *testing.T, p promotion.Promotion) {
+				stores.Promotions.Add(p)
+			},
+			Outbox: func(t *testing.T) []outbox.Message {
+				return stores.Outbox.Messages()
+			},
+		}
+	})
+}
+
+var migrateOnce sync.Once
+
+// TestPostgresRepositoryConformance runs the same suite against the database
+// named by CART_TEST_DB_DSN. Every test empties the cart tables first, so
+// never point it at a database whose data matters.
+func TestPostgresRepositoryConformance(t *testing.T) {
+	dsn := os.Getenv("CART_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("CART_TEST_DB_DSN not set")
+	}
+
+	var migrateErr error
+	migrateOnce.Do(func() {
+		migrateErr = db.RunMigrations(dsn, log.New(io.Discard, "", 0))
+	})
+	if migrateErr != nil {
+		t.Fatalf("run migrations: %v", migrateErr)
+	}
+	database, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	carttest.RunRepositoryTests(t, func(t *testing.T) carttest.Harness {
+		ctx := context.Background()
+		const truncateSQL = `TRUNCATE carts, cart_items, cart_coupons, promotions, promotion_redemptions, wishlists, wishlist_items, outbox, event_sequences, idempotency_keys`
+		if _, err := database.ExecContext(ctx, truncateSQL); err != nil {
+			t.Fatalf("truncate: %v", err)
+		}
+
+		return carttest.Harness{
+			Repo:        cart.NewRepository(database),
+			Wishlists:   wishlist.NewStore(database),
+			Idempotency: idempotency.NewStore(database, time.Hour),
+			AddPromotion: func(t *testing.T, p promotion.Promotion) {
+				const insertSQL = `
+INSERT INTO promotions (id, code, kind, percent_off, amount_off_minor, min_spend_minor, currency, max_uses_per_user, active)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+`
+				_, err := database.ExecContext(ctx, insertSQL, p.ID, promotion.NormalizeCode(p.Code), p.Kind, p.PercentOff,
+					p.AmountOff.Amount, p.MinSpend.Amount, p.AmountOff.Currency, p.MaxUsesPerUser, p.Active)
+				if err != nil {
+					t.Fatalf("insert promotion: %v", err)
+				}
+			},
+			Outbox: func(t *testing.T) []outbox.Message {
+				rows, err := database.QueryContext(ctx, `SELECT id, partition_key, sequence, routing_key, payload, attempts FROM outbox ORDER BY id`)
+				if err != nil {
+					t.Fatalf("select outbox: %v", err)
+				}
+				defer rows.Close()
+				var msgs []outbox.Message
+				for rows.Next() {
+					var m outbox.Message
+					if err := rows.Scan(&m.ID, &m.PartitionKey, &m.Sequence, &m.RoutingKey, &m.Payload, &m.Attempts); err != nil {
+						t.Fatalf("scan outbox: %v", err)
+					}
+					msgs = append(msgs, m)
+				}
+				if err := rows.Err(); err != nil {
+					t.Fatalf("rows: %v", err)
+				}
+				return msgs
+			},
+		}
+	})
+}
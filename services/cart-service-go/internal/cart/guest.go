@@ -0,0 +1,93 @@
+package cart
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GuestIDPrefix marks owner IDs issued to anonymous visitors. Guest carts are
+// stored like any other cart, keyed by the guest ID instead of a user ID.
+const GuestIDPrefix = "guest-"
+
+// NewGuestID issues a new anonymous cart owner ID.
+func NewGuestID() string {
+	return GuestIDPrefix + uuid.NewString()
+}
+
+// IsGuestID reports whether id was issued by NewGuestID.
+func IsGuestID(id string) bool {
+	rest, ok := strings.CutPrefix(id, GuestIDPrefix)
+	if !ok {
+		return false
+	}
+	_, err := uuid.Parse(rest)
+	return err == nil
+}
+
+// MergeStrategy decides the quantity of a product that is in both carts.
+type MergeStrategy string
+
+const (
+	MergeSum       MergeStrategy = "sum"   // add both quantities
+	MergeMax       MergeStrategy = "max"   // keep the larger quantity
+	MergeKeepUser  MergeStrategy = "user"  // keep the user's line
+	MergeKeepGuest MergeStrategy = "guest" // replace with the guest's line
+)
+
+func ParseMergeStrategy(s string) (MergeStrategy, error) {
+	switch st := MergeStrategy(strings.ToLower(strings.TrimSpace(s))); st {
+	case MergeSum, MergeMax, MergeKeepUser, MergeKeepGuest:
+		return st, nil
+	default:
+		return "", fmt.Errorf("unknown merge strategy %q", s)
+	}
+}
+
+// Merge folds the guest's lines into c. Lines only in the guest cart are
+// appended; conflicts are resolved with strategy. The newer price snapshot of
+// a conflicting line is kept, and the guest's coupons are carried over, as is
+// the guest's tax location if the user has not set one. The caller
+// recalculates the total.
+func Merge(c, guest *Cart, strategy MergeStrategy) {
+	for _, g := range guest.Items {
+		idx := -1
+		for i := range c.Items {
+			if c.Items[i].ProductID == g.ProductID {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			c.Items = append(c.Items, g)
+			continue
+		}
+
+		line := &c.Items[idx]
+		switch strategy {
+		case MergeSum:
+			line.Quantity += g.Quantity
+		case MergeMax:
+			line.Quantity = max(line.Quantity, g.Quantity)
+		case MergeKeepUser:
+			continue
+		case MergeKeepGuest:
+			line.Quantity = g.Quantity
+		}
+		if g.PricedAt.After(line.PricedAt) {
+			line.Price = g.Price
+			line.PricedAt = g.PricedAt
+		}
+	}
+	for _, code := range guest.Coupons {
+		if !c.HasCoupon(code) {
+			c.Coupons = append(c.Coupons, code)
+		}
+	}
+	if c.TaxCountry == "" {
+		c.TaxCountry, c.TaxRegion = guest.TaxCountry, guest.TaxRegion
+	}
+	c.UpdatedAt = time.Now()
+}
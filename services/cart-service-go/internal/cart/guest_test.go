@@ -0,0 +1,61 @@
+package cart
+
+import (
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+	"testing"
+	"time"
+)
+
+func TestIsGuestID(t *testing.T) {
+	if !IsGuestID(NewGuestID()) {
+		t.Fatalf("expected issued id to be a guest id")
+	}
+	for _, id := range []string{"", "user-1", "guest-", "guest-not-a-uuid"} {
+		if IsGuestID(id) {
+			t.Fatalf("did not expect %q to be a guest id", id)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+
+	cases := []struct {
+		strategy MergeStrategy
+		wantQty  int
+	}{
+		{MergeSum, 5},
+		{MergeMax, 3},
+		{MergeKeepUser, 2},
+		{MergeKeepGuest, 3},
+	}
+
+	for _, tc := range cases {
+		t.Run(string(tc.strategy), func(t *testing.T) {
+			user := &Cart{Items: []Item{{ProductID: "p1", Quantity: 2, Price: money.New(100, "USD"), PricedAt: older}}}
+			guest := &Cart{Items: []Item{
+				{ProductID: "p1", Quantity: 3, Price: money.New(200, "USD"), PricedAt: newer},
+				{ProductID: "p2", Quantity: 1, Price: money.New(400, "USD"), PricedAt: newer},
+			}}
+
+			Merge(user, guest, tc.strategy)
+
+			if len(user.Items) != 2 || user.Items[1].ProductID != "p2" {
+				t.Fatalf("expected guest-only line to be appended, got %+v", user.Items)
+			}
+			if user.Items[0].Quantity != tc.wantQty {
+				t.Fatalf("expected quantity %d, got %d", tc.wantQty, user.Items[0].Quantity)
+			}
+		})
+	}
+}
+
+func TestParseMergeStrategy(t *testing.T) {
+	if st, err := ParseMergeStrategy(" MAX "); err != nil || st != MergeMax {
+		t.Fatalf("expected max, got %q, %v", st, err)
+	}
+	if _, err := ParseMergeStrategy("newest"); err == nil {
+		t.Fatalf("expected error for unknown strategy")
+	}
+}
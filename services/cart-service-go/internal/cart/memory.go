@@ -0,0 +1,408 @@
+package cart
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/idempotency"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/outbox"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/promotion"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/wishlist"
+	"github.com/google/uuid"
+)
+
+// InMemoryStores are the other in-memory stores the in-memory repository
+// changes together with a cart, standing in for the tables the Postgres
+// repository writes in the same transaction.
+type InMemoryStores struct {
+	Outbox *outbox.InMemoryStore
+	// Promotions, when set, records the redemptions of a checkout. Without it
+	// a checkout with adjustments fails with promotion.ErrNotFound.
+	Promotions *promotion.InMemoryStore
+	// Wishlists, when set, takes the lines moved out of the cart. Without it
+	// moves fail with wishlist.ErrNotFound.
+	Wishlists *wishlist.InMemoryRepository
+	// Idempotency, when set, stores the checkout responses.
+	Idempotency *idempotency.InMemoryStore
+}
+
+// InMemoryRepository is a Repository and AbandonedStore backed by a map,
+// used in tests and local runs. A single lock stands in for the transactions
+// of the Postgres repository, so a change either happens entirely or not at
+// all.
+type InMemoryRepository struct {
+	mu     sync.Mutex
+	stores InMemoryStores
+	carts  map[string]*memoryCart // by user ID
+}
+
+type memoryCart struct {
+	cart        Cart
+	abandonedAt time.Time
+}
+
+func NewInMemoryRepository(stores InMemoryStores) *InMemoryRepository {
+	if stores.Outbox == nil {
+		stores.Outbox = outbox.NewInMemoryStore()
+	}
+	return &InMemoryRepository{stores: stores, carts: make(map[string]*memoryCart)}
+}
+
+func (m *InMemoryRepository) GetCart(ctx context.Context, userID string) (*Cart, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.load(userID), nil
+}
+
+// load returns a copy of the stored cart the way the Postgres repository reads
+// it back: without adjustments or tax and with Total equal to Subtotal.
+func (m *InMemoryRepository) load(userID string) *Cart {
+	mc := m.carts[userID]
+	if mc == nil {
+		return nil
+	}
+	c := mc.cart
+	c.Items = slices.Clone(mc.cart.Items)
+	c.Coupons = slices.Clone(mc.cart.Coupons)
+	c.Total = c.Subtotal
+	return &c
+}
+
+func (m *InMemoryRepository) byID(cartID string) *memoryCart {
+	for _, mc := range m.carts {
+		if mc.cart.ID == cartID {
+			return mc
+		}
+	}
+	return nil
+}
+
+func (m *InMemoryRepository) AddItem(ctx context.Context, userID string, item Item, check CheckFunc, activity ActivityFunc) (*Cart, error) {
+	return m.AddItems(ctx, userID, []Item{item}, check, activity)
+}
+
+func (m *InMemoryRepository) AddItems(ctx context.Context, userID string, items []Item, check CheckFunc, activity ActivityFunc) (*Cart, error) {
+	if len(items) == 0 {
+		return nil, errors.New("no items to add")
+	}
+	currency := items[0].Price.Currency
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := m.load(userID)
+	stored := Cart{ID: uuid.NewString(), UserID: userID, Subtotal: money.Zero(currency)}
+	if before != nil {
+		stored = *before
+		stored.Items = slices.Clone(before.Items)
+	}
+	for _, it := range append(slices.Clone(stored.Items), items...) {
+		if it.Price.Currency != currency {
+			return nil, money.ErrCurrencyMismatch
+		}
+	}
+	if check != nil {
+		if err := check(ctx, before); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, item := range items {
+		if item.PricedAt.IsZero() {
+			item.PricedAt = time.Now().UTC()
+		}
+		if idx := slices.IndexFunc(stored.Items, func(it Item) bool { return it.ProductID == item.ProductID }); idx >= 0 {
+			item.Quantity += stored.Items[idx].Quantity
+			stored.Items[idx] = item
+		} else {
+			stored.Items = append(stored.Items, item)
+		}
+	}
+
+	var subtotal int64
+	for _, it := range stored.Items {
+		subtotal += int64(it.Quantity) * it.Price.Amount
+	}
+	stored.Subtotal = money.New(subtotal, currency)
+	stored.Version++
+	stored.UpdatedAt = time.Now().UTC()
+
+	after := stored
+	after.Total = after.Subtotal
+	err := m.commit(func() { m.store(&stored) }, func() []outbox.Event {
+		return activityEvents(activity, before, &after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.load(userID), nil
+}
+
+func (m *InMemoryRepository) UpsertCart(ctx context.Context, c *Cart, activity ActivityFunc) error {
+	return m.saveWith(c, activity, nil)
+}
+
+func (m *InMemoryRepository) MergeCarts(ctx context.Context, c *Cart, guest *Cart, activity ActivityFunc) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.byID(guest.ID)
+	if stored == nil || stored.cart.Version != guest.Version {
+		// The guest cart changed or was merged by a concurrent request.
+		return ErrVersionConflict
+	}
+	before := m.load(c.UserID)
+	save, err := m.prepareSave(c)
+	if err != nil {
+		return err
+	}
+
+	apply := func() {
+		save()
+		delete(m.carts, stored.cart.UserID)
+	}
+	// The version check above makes guest exactly the cart that is deleted.
+	return m.commit(apply, func() []outbox.Event {
+		return append(activityEvents(activity, before, c), activityEvents(activity, guest, nil)...)
+	})
+}
+
+func (m *InMemoryRepository) ClearCart(ctx context.Context, userID string, expectedVersion int64, activity ActivityFunc) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.carts[userID]
+	if stored == nil {
+		if expectedVersion != 0 {
+			return ErrVersionConflict
+		}
+		return nil
+	}
+	if expectedVersion != 0 && stored.cart.Version != expectedVersion {
+		return ErrVersionConflict
+	}
+	before := m.load(userID)
+	return m.commit(func() { delete(m.carts, userID) }, func() []outbox.Event {
+		return activityEvents(activity, before, nil)
+	})
+}
+
+func (m *InMemoryRepository) CheckoutCart(ctx context.Context, c *Cart, evs []outbox.Event, idem *idempotency.Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Only remove the cart the event was built from, as the Postgres repository does.
+	stored := m.byID(c.ID)
+	if stored == nil {
+		return ErrCartNotFound
+	}
+	if stored.cart.UserID != c.UserID || stored.cart.Version != c.Version {
+		return ErrVersionConflict
+	}
+
+	if len(c.Adjustments) > 0 {
+		if m.stores.Promotions == nil {
+			return promotion.ErrNotFound
+		}
+		ids := make([]string, len(c.Adjustments))
+		for i, adj := range c.Adjustments {
+			ids[i] = adj.PromotionID
+		}
+		if err := m.stores.Promotions.Redeem(c.UserID, c.ID, ids); err != nil {
+			return err
+		}
+	}
+
+	if err := m.commit(func() { delete(m.carts, c.UserID) }, func() []outbox.Event { return evs }); err != nil {
+		return err
+	}
+	if idem != nil && m.stores.Idempotency != nil {
+		m.stores.Idempotency.Save(*idem)
+	}
+	return nil
+}
+
+func (m *InMemoryRepository) MoveToWishlist(ctx context.Context, c *Cart, listID string, item wishlist.Item, activity ActivityFunc) error {
+	return m.saveWith(c, activity, func() error {
+		if m.stores.Wishlists == nil {
+			return wishlist.ErrNotFound
+		}
+		_, err := m.stores.Wishlists.AddItem(ctx, c.UserID, listID, item)
+		return err
+	})
+}
+
+func (m *InMemoryRepository) MoveFromWishlist(ctx context.Context, c *Cart, listID, productID string, activity ActivityFunc) error {
+	return m.saveWith(c, activity, func() error {
+		if m.stores.Wishlists == nil {
+			return wishlist.ErrNotFound
+		}
+		_, err := m.stores.Wishlists.RemoveItem(ctx, c.UserID, listID, productID)
+		return err
+	})
+}
+
+// saveWith saves c, runs fn when set and records the activity. fn runs once
+// the version check has passed and nothing is saved if it fails.
+func (m *InMemoryRepository) saveWith(c *Cart, activity ActivityFunc, fn func() error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := m.load(c.UserID)
+	save, err := m.prepareSave(c)
+	if err != nil {
+		return err
+	}
+	if fn != nil {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return m.commit(save, func() []outbox.Event {
+		return activityEvents(activity, before, c)
+	})
+}
+
+// prepareSave checks c's version as described on UpsertCart and returns the
+// function that stores it and bumps c.Version.
+func (m *InMemoryRepository) prepareSave(c *Cart) (func(), error) {
+	stored := m.carts[c.UserID]
+	if c.Version == 0 {
+		if stored != nil {
+			return nil, ErrVersionConflict
+		}
+	} else if stored == nil || stored.cart.ID != c.ID || stored.cart.Version != c.Version {
+		return nil, ErrVersionConflict
+	}
+
+	return func() {
+		if c.ID == "" {
+			c.ID = uuid.NewString()
+		}
+		c.Version++
+		c.UpdatedAt = time.Now().UTC()
+
+		s := Cart{
+			ID:         c.ID,
+			UserID:     c.UserID,
+			Items:      slices.Clone(c.Items),
+			Coupons:    keepCouponOrder(stored, c.Coupons),
+			Subtotal:   c.Subtotal,
+			TaxCountry: c.TaxCountry,
+			TaxRegion:  c.TaxRegion,
+			Version:    c.Version,
+			UpdatedAt:  c.UpdatedAt,
+		}
+		for i := range s.Items {
+			if s.Items[i].PricedAt.IsZero() {
+				s.Items[i].PricedAt = time.Now().UTC()
+			}
+		}
+		m.store(&s)
+	}, nil
+}
+
+// keepCouponOrder lists codes in the order they were entered: the ones
+// already on the stored cart first, then the new ones by code.
+func keepCouponOrder(stored *memoryCart, codes []string) []string {
+	var out, added []string
+	if stored != nil {
+		for _, code := range stored.cart.Coupons {
+			if slices.Contains(codes, code) {
+				out = append(out, code)
+			}
+		}
+	}
+	for _, code := range codes {
+		if !slices.Contains(out, code) && !slices.Contains(added, code) {
+			added = append(added, code)
+		}
+	}
+	sort.Strings(added)
+	return append(out, added...)
+}
+
+func (m *InMemoryRepository) store(c *Cart) {
+	c.Total = money.Money{}
+	c.Adjustments, c.Tax, c.Shipping = nil, nil, nil
+	abandonedAt := time.Time{}
+	if prev := m.carts[c.UserID]; prev != nil && prev.cart.ID == c.ID {
+		abandonedAt = prev.abandonedAt
+	}
+	m.carts[c.UserID] = &memoryCart{cart: *c, abandonedAt: abandonedAt}
+}
+
+// commit applies a change and records the events describing it in the
+// outbox. The events are built once the change is applied, so they see the
+// saved cart's ID and version; if the outbox refuses them the carts are put
+// back as they were.
+func (m *InMemoryRepository) commit(apply func(), events func() []outbox.Event) error {
+	prev := maps.Clone(m.carts)
+	apply()
+	if err := m.stores.Outbox.Enqueue(events()...); err != nil {
+		m.carts = prev
+		return err
+	}
+	return nil
+}
+
+func activityEvents(activity ActivityFunc, before, after *Cart) []outbox.Event {
+	if activity == nil {
+		return nil
+	}
+	return activity(before, after)
+}
+
+func (m *InMemoryRepository) MarkAbandoned(ctx context.Context, idleSince time.Time, limit int, newEvent func(*Cart) outbox.Event) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var idle []*memoryCart
+	for _, mc := range m.carts {
+		if !mc.cart.UpdatedAt.Before(idleSince) || len(mc.cart.Items) == 0 || strings.HasPrefix(mc.cart.UserID, GuestIDPrefix) {
+			continue
+		}
+		if !mc.abandonedAt.IsZero() && !mc.abandonedAt.Before(mc.cart.UpdatedAt) {
+			continue
+		}
+		idle = append(idle, mc)
+	}
+	sort.Slice(idle, func(i, j int) bool { return idle[i].cart.UpdatedAt.Before(idle[j].cart.UpdatedAt) })
+	if len(idle) > limit {
+		idle = idle[:limit]
+	}
+
+	evs := make([]outbox.Event, len(idle))
+	for i, mc := range idle {
+		evs[i] = newEvent(m.load(mc.cart.UserID))
+	}
+	if err := m.stores.Outbox.Enqueue(evs...); err != nil {
+		return 0, err
+	}
+	// abandonedAt is bookkeeping only: neither UpdatedAt nor Version change.
+	now := time.Now().UTC()
+	for _, mc := range idle {
+		mc.abandonedAt = now
+	}
+	return len(idle), nil
+}
+
+func (m *InMemoryRepository) DeleteIdle(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for userID, mc := range m.carts {
+		if mc.cart.UpdatedAt.Before(before) {
+			delete(m.carts, userID)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -1,17 +1,116 @@
 package cart
 
-import "time"
+import (
+	"time"
 
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/tax"
+)
+
+// Item is a cart line. Price is the catalog unit price snapshot taken at PricedAt.
 type Item struct {
-	ProductID string  `json:"productId"`
-	Quantity  int     `json:"quantity"`
-	Price     float64 `json:"price"`
+	ProductID string      `json:"productId"`
+	Quantity  int         `json:"quantity"`
+	Price     money.Money `json:"price"`
+	PricedAt  time.Time   `json:"pricedAt"`
+}
+
+// Adjustment is a discount applied to the cart by a coupon. Amount is negative.
+type Adjustment struct {
+	Code        string      `json:"code"`
+	PromotionID string      `json:"promotionId"`
+	Description string      `json:"description,omitempty"`
+	Amount      money.Money `json:"amount"`
 }
 
 type Cart struct {
-	ID        string    `json:"cartId"`
-	UserID    string    `json:"userId"`
-	Items     []Item    `json:"items"`
-	Total     float64   `json:"totalAmount"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID     string `json:"cartId"`
+	UserID string `json:"userId"`
+	Items  []Item `json:"items"`
+	// Coupons are the codes entered on the cart. Only the ones that currently
+	// apply show up in Adjustments.
+	Coupons     []string     `json:"coupons,omitempty"`
+	Subtotal    money.Money  `json:"subtotal"`
+	Adjustments []Adjustment `json:"adjustments,omitempty"`
+	// TaxCountry and TaxRegion are where the customer asked the cart to be
+	// taxed. An empty country leaves it to the service default.
+	TaxCountry string `json:"-"`
+	TaxRegion  string `json:"-"`
+	// Tax is worked out by the caller; nil when the cart is not taxed.
+	Tax *tax.Breakdown `json:"tax,omitempty"`
+	// Shipping is set from the checkout request and is not stored with the
+	// cart; nil when the customer gave no shipping details.
+	Shipping *Shipping `json:"-"`
+	// Total is Subtotal plus Adjustments plus any tax not already included in
+	// the prices, and is what the customer pays.
+	Total     money.Money `json:"totalAmount"`
+	Version   int64       `json:"version"`
+	UpdatedAt time.Time   `json:"updatedAt"`
+}
+
+// Currency is the currency of the cart's lines. An empty cart keeps the
+// currency of its subtotal, or money.DefaultCurrency if it never had one.
+func (c *Cart) Currency() string {
+	if len(c.Items) > 0 {
+		return c.Items[0].Price.Currency
+	}
+	if c.Subtotal.Currency != "" {
+		return c.Subtotal.Currency
+	}
+	return money.DefaultCurrency
+}
+
+// HasCoupon reports whether code has been entered on the cart.
+func (c *Cart) HasCoupon(code string) bool {
+	for _, cc := range c.Coupons {
+		if cc == code {
+			return true
+		}
+	}
+	return false
+}
+
+// TotalBeforeTax is Total without the tax added on top of the prices, i.e.
+// the subtotal after adjustments.
+func (c *Cart) TotalBeforeTax() money.Money {
+	total := c.Total
+	if c.Tax != nil {
+		total.Amount -= c.Tax.Exclusive.Amount
+	}
+	return total
+}
+
+// RecalculateTotal sets Subtotal to the exact sum of the lines and Total to
+// the subtotal after adjustments, never below zero, plus exclusive tax. A
+// cart holds a single currency; lines in another one fail with
+// money.ErrCurrencyMismatch.
+func (c *Cart) RecalculateTotal() error {
+	subtotal := money.Zero(c.Currency())
+	for _, it := range c.Items {
+		var err error
+		if subtotal, err = subtotal.Add(it.Price.Mul(it.Quantity)); err != nil {
+			return err
+		}
+	}
+
+	total := subtotal
+	for _, adj := range c.Adjustments {
+		var err error
+		if total, err = total.Add(adj.Amount); err != nil {
+			return err
+		}
+	}
+	if total.Amount < 0 {
+		total.Amount = 0
+	}
+	if c.Tax != nil {
+		var err error
+		if total, err = total.Add(c.Tax.Exclusive); err != nil {
+			return err
+		}
+	}
+
+	c.Subtotal = subtotal
+	c.Total = total
+	return nil
 }
@@ -0,0 +1,67 @@
+package cart
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/tax"
+)
+
+func TestRecalculateTotal(t *testing.T) {
+	c := &Cart{Items: []Item{
+		{ProductID: "p1", Quantity: 3, Price: money.New(1999, "EUR")},
+		{ProductID: "p2", Quantity: 1, Price: money.New(10, "EUR")},
+	}}
+	if err := c.RecalculateTotal(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Total != money.New(6007, "EUR") {
+		t.Fatalf("unexpected total %+v", c.Total)
+	}
+
+	c.Items = nil
+	if err := c.RecalculateTotal(); err != nil || c.Total != money.Zero("EUR") {
+		t.Fatalf("expected empty cart to keep its currency, got %+v (%v)", c.Total, err)
+	}
+
+	mixed := &Cart{Items: []Item{
+		{ProductID: "p1", Quantity: 1, Price: money.New(100, "EUR")},
+		{ProductID: "p2", Quantity: 1, Price: money.New(100, "USD")},
+	}}
+	if err := mixed.RecalculateTotal(); !errors.Is(err, money.ErrCurrencyMismatch) {
+		t.Fatalf("expected ErrCurrencyMismatch, got %v", err)
+	}
+}
+
+func TestRecalculateTotalAppliesAdjustments(t *testing.T) {
+	c := &Cart{
+		Items:       []Item{{ProductID: "p1", Quantity: 2, Price: money.New(500, "USD")}},
+		Adjustments: []Adjustment{{Code: "SAVE3", Amount: money.New(-300, "USD")}},
+	}
+	if err := c.RecalculateTotal(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Subtotal != money.New(1000, "USD") || c.Total != money.New(700, "USD") {
+		t.Fatalf("unexpected subtotal %+v and total %+v", c.Subtotal, c.Total)
+	}
+
+	c.Adjustments = append(c.Adjustments, Adjustment{Code: "BIG", Amount: money.New(-5000, "USD")})
+	if err := c.RecalculateTotal(); err != nil || c.Total != money.Zero("USD") {
+		t.Fatalf("expected the total to stop at zero, got %+v (%v)", c.Total, err)
+	}
+}
+
+func TestRecalculateTotalAddsExclusiveTax(t *testing.T) {
+	c := &Cart{
+		Items:       []Item{{ProductID: "p1", Quantity: 2, Price: money.New(500, "USD")}},
+		Adjustments: []Adjustment{{Code: "SAVE3", Amount: money.New(-300, "USD")}},
+		Tax:         &tax.Breakdown{Total: money.New(90, "USD"), Exclusive: money.New(56, "USD")},
+	}
+	if err := c.RecalculateTotal(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Subtotal != money.New(1000, "USD") || c.Total != money.New(756, "USD") {
+		t.Fatalf("expected only the exclusive tax on top, got subtotal %+v and total %+v", c.Subtotal, c.Total)
+	}
+}
@@ -3,14 +3,81 @@ package cart
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"time"
 
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/idempotency"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/outbox"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/promotion"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/wishlist"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+// ErrCartNotFound is returned when a cart disappeared before it could be checked out.
+var ErrCartNotFound = errors.New("cart not found")
+
+// ErrVersionConflict is returned when the stored cart no longer has the version
+// the caller read, i.e. someone else changed it in between.
+var ErrVersionConflict = errors.New("cart version conflict")
+
+// ActivityFunc builds the events describing a change to a cart. before is the
+// stored cart as the change found it and after the cart it left behind; either
+// is nil when there was no cart. The events are recorded in the outbox in the
+// transaction that makes the change. A nil ActivityFunc records nothing.
+type ActivityFunc func(before, after *Cart) []outbox.Event
+
+// CheckFunc vets a change against the stored cart as the change found it,
+// nil when there was none. It runs with the cart locked, before the change is
+// made, so concurrent changes to the cart check one after the other. An error
+// abandons the change and is returned. A nil CheckFunc checks nothing.
+type CheckFunc func(ctx context.Context, before *Cart) error
+
 type Repository interface {
 	GetCart(ctx context.Context, userID string) (*Cart, error)
-	UpsertCart(ctx context.Context, c *Cart) error
-	ClearCart(ctx context.Context, userID string) error
+	// AddItem adds item.Quantity to the product's line, creating the cart and the
+	// line when missing. It does not read-modify-write, so concurrent adds never
+	// lose updates. The resulting cart is returned. It fails with
+	// money.ErrCurrencyMismatch if the cart holds lines in another currency.
+	// check runs before the line is added.
+	AddItem(ctx context.Context, userID string, item Item, check CheckFunc, activity ActivityFunc) (*Cart, error)
+	// AddItems is AddItem for several lines in one transaction: either every
+	// line is added or none is. The items must share a currency and name each
+	// product once.
+	AddItems(ctx context.Context, userID string, items []Item, check CheckFunc, activity ActivityFunc) (*Cart, error)
+	// UpsertCart replaces the stored cart if its version still equals c.Version
+	// (0 for a cart that was never stored) and bumps c.Version. It returns
+	// ErrVersionConflict otherwise.
+	UpsertCart(ctx context.Context, c *Cart, activity ActivityFunc) error
+	// ClearCart deletes the user's cart. A non-zero expectedVersion makes the
+	// delete conditional and returns ErrVersionConflict on mismatch.
+	ClearCart(ctx context.Context, userID string, expectedVersion int64, activity ActivityFunc) error
+	// MergeCarts saves c (see UpsertCart) and deletes guest in one transaction.
+	// Both carts must still have the versions the caller read. The activity
+	// covers both carts: the guest cart ends up cleared.
+	MergeCarts(ctx context.Context, c *Cart, guest *Cart, activity ActivityFunc) error
+	// CheckoutCart removes the cart, redeems the promotions behind c.Adjustments
+	// and records evs in the outbox in one transaction. When idem is non-nil the
+	// response is stored in the same transaction. It fails with
+	// ErrVersionConflict if the cart changed since c was read, and with
+	// promotion.ErrUsageLimitReached if a coupon has been used up meanwhile.
+	CheckoutCart(ctx context.Context, c *Cart, evs []outbox.Event, idem *idempotency.Record) error
+	// MoveToWishlist saves c (see UpsertCart), from which the caller has taken
+	// item's line, and adds the line to the user's wishlist in one transaction.
+	// An empty listID means the wishlist.SavedForLater list. It fails with
+	// wishlist.ErrNotFound if the user has no such list.
+	MoveToWishlist(ctx context.Context, c *Cart, listID string, item wishlist.Item, activity ActivityFunc) error
+	// MoveFromWishlist saves c (see UpsertCart), to which the caller has added
+	// the product, and takes the product off the wishlist in one transaction.
+	// It fails with wishlist.ErrItemNotFound if the list no longer holds it.
+	MoveFromWishlist(ctx context.Context, c *Cart, listID, productID string, activity ActivityFunc) error
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
 }
 
 type repo struct {
@@ -22,10 +89,14 @@ func NewRepository(db *sql.DB) Repository {
 }
 
 func (r *repo) GetCart(ctx context.Context, userID string) (*Cart, error) {
-	const cartQuery = `SELECT id, user_id, total, updated_at FROM carts WHERE user_id = $1`
+	return loadCart(ctx, r.db, userID)
+}
+
+func loadCart(ctx context.Context, q queryer, userID string) (*Cart, error) {
+	const cartQuery = `SELECT id, user_id, subtotal_minor, currency, tax_country, tax_region, version, updated_at FROM carts WHERE user_id = $1`
 
 	var c Cart
-	err := r.db.QueryRowContext(ctx, cartQuery, userID).Scan(&c.ID, &c.UserID, &c.Total, &c.UpdatedAt)
+	err := q.QueryRowContext(ctx, cartQuery, userID).Scan(&c.ID, &c.UserID, &c.Subtotal.Amount, &c.Subtotal.Currency, &c.TaxCountry, &c.TaxRegion, &c.Version, &c.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// caller (handler) can turn this into 404
@@ -34,8 +105,8 @@ func (r *repo) GetCart(ctx context.Context, userID string) (*Cart, error) {
 		return nil, err
 	}
 
-	rows, err := r.db.QueryContext(ctx,
-		`SELECT product_id, quantity, price FROM cart_items WHERE cart_id = $1`, c.ID)
+	rows, err := q.QueryContext(ctx,
+		`SELECT product_id, quantity, price_minor, currency, priced_at FROM cart_items WHERE cart_id = $1`, c.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -43,7 +114,7 @@ func (r *repo) GetCart(ctx context.Context, userID string) (*Cart, error) {
 
 	for rows.Next() {
 		var it Item
-		if err := rows.Scan(&it.ProductID, &it.Quantity, &it.Price); err != nil {
+		if err := rows.Scan(&it.ProductID, &it.Quantity, &it.Price.Amount, &it.Price.Currency, &it.PricedAt); err != nil {
 			return nil, err
 		}
 		c.Items = append(c.Items, it)
@@ -52,13 +123,43 @@ func (r *repo) GetCart(ctx context.Context, userID string) (*Cart, error) {
 		return nil, err
 	}
 
+	couponRows, err := q.QueryContext(ctx,
+		`SELECT code FROM cart_coupons WHERE cart_id = $1 ORDER BY applied_at, code`, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer couponRows.Close()
+
+	for couponRows.Next() {
+		var code string
+		if err := couponRows.Scan(&code); err != nil {
+			return nil, err
+		}
+		c.Coupons = append(c.Coupons, code)
+	}
+	if err := couponRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Discounts and tax depend on the promotions and tax rules, which are
+	// applied by the caller.
+	c.Total = c.Subtotal
 	return &c, nil
 }
 
-func (r *repo) UpsertCart(ctx context.Context, c *Cart) error {
+func (r *repo) AddItem(ctx context.Context, userID string, item Item, check CheckFunc, activity ActivityFunc) (*Cart, error) {
+	return r.AddItems(ctx, userID, []Item{item}, check, activity)
+}
+
+func (r *repo) AddItems(ctx context.Context, userID string, items []Item, check CheckFunc, activity ActivityFunc) (c *Cart, err error) {
+	if len(items) == 0 {
+		return nil, errors.New("no items to add")
+	}
+	currency := items[0].Price.Currency
+
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func() {
 		if err != nil {
@@ -66,44 +167,393 @@ func (r *repo) UpsertCart(ctx context.Context, c *Cart) error {
 		}
 	}()
 
+	// Creating or touching the cart row also locks it, so concurrent adds to the
+	// same cart queue up behind each other for the rest of the transaction.
+	const touchCartSQL = `
+INSERT INTO carts (id, user_id, subtotal_minor, currency, version, updated_at)
+VALUES ($1, $2, 0, $3, 1, NOW())
+ON CONFLICT (user_id) DO UPDATE
+SET version = carts.version + 1, updated_at = NOW()
+RETURNING id
+`
+	var cartID string
+	if err = tx.QueryRowContext(ctx, touchCartSQL, uuid.NewString(), userID, currency).Scan(&cartID); err != nil {
+		return nil, err
+	}
+
+	// A cart created just now has no lines yet, which reads the same as no cart.
+	var before *Cart
+	if check != nil || activity != nil {
+		if before, err = loadCart(ctx, tx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	// The row lock taken above makes this check and the upserts below atomic.
+	var mixed bool
+	const mixedSQL = `SELECT EXISTS (SELECT 1 FROM cart_items WHERE cart_id = $1 AND currency <> $2)`
+	if err = tx.QueryRowContext(ctx, mixedSQL, cartID, currency).Scan(&mixed); err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		mixed = mixed || item.Price.Currency != currency
+	}
+	if mixed {
+		err = money.ErrCurrencyMismatch
+		return nil, err
+	}
+	if check != nil {
+		if err = check(ctx, before); err != nil {
+			return nil, err
+		}
+	}
+
+	const upsertLineSQL = `
+INSERT INTO cart_items (id, cart_id, product_id, quantity, price_minor, currency, priced_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (cart_id, product_id) DO UPDATE
+SET quantity = cart_items.quantity + EXCLUDED.quantity,
+    price_minor = EXCLUDED.price_minor,
+    currency = EXCLUDED.currency,
+    priced_at = EXCLUDED.priced_at
+`
+	for _, item := range items {
+		pricedAt := item.PricedAt
+		if pricedAt.IsZero() {
+			pricedAt = time.Now().UTC()
+		}
+		if _, err = tx.ExecContext(ctx, upsertLineSQL, uuid.NewString(), cartID, item.ProductID, item.Quantity, item.Price.Amount, item.Price.Currency, pricedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	const totalSQL = `
+UPDATE carts
+SET subtotal_minor = (SELECT COALESCE(SUM(quantity * price_minor), 0) FROM cart_items WHERE cart_id = $1),
+    currency = $2
+WHERE id = $1
+`
+	if _, err = tx.ExecContext(ctx, totalSQL, cartID, currency); err != nil {
+		return nil, err
+	}
+
+	if c, err = loadCart(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+	if err = recordActivity(ctx, tx, activity, before, c); err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	return c, err
+}
+
+func (r *repo) UpsertCart(ctx context.Context, c *Cart, activity ActivityFunc) error {
+	return r.saveWith(ctx, c, activity, nil)
+}
+
+// saveCart writes c and its lines with the version check described on UpsertCart.
+func saveCart(ctx context.Context, tx *sql.Tx, c *Cart) error {
 	if c.ID == "" {
 		c.ID = uuid.NewString()
 	}
 
-	const upsertCartSQL = `
-INSERT INTO carts (id, user_id, total, updated_at)
-VALUES ($1, $2, $3, NOW())
-ON CONFLICT (user_id) DO UPDATE
-SET total = EXCLUDED.total, updated_at = NOW()
-RETURNING id, updated_at
+	var err error
+	if c.Version == 0 {
+		const insertCartSQL = `
+INSERT INTO carts (id, user_id, subtotal_minor, currency, tax_country, tax_region, version, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, 1, NOW())
+ON CONFLICT (user_id) DO NOTHING
+RETURNING version, updated_at
+`
+		err = tx.QueryRowContext(ctx, insertCartSQL, c.ID, c.UserID, c.Subtotal.Amount, c.Subtotal.Currency, c.TaxCountry, c.TaxRegion).Scan(&c.Version, &c.UpdatedAt)
+	} else {
+		const updateCartSQL = `
+UPDATE carts
+SET subtotal_minor = $3, currency = $4, tax_country = $5, tax_region = $6, version = version + 1, updated_at = NOW()
+WHERE id = $1 AND user_id = $2 AND version = $7
+RETURNING version, updated_at
 `
-	if err = tx.QueryRowContext(ctx, upsertCartSQL, c.ID, c.UserID, c.Total).Scan(&c.ID, &c.UpdatedAt); err != nil {
+		err = tx.QueryRowContext(ctx, updateCartSQL, c.ID, c.UserID, c.Subtotal.Amount, c.Subtotal.Currency, c.TaxCountry, c.TaxRegion, c.Version).Scan(&c.Version, &c.UpdatedAt)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrVersionConflict
+		}
 		return err
 	}
 
-	if _, err = tx.ExecContext(ctx, `DELETE FROM cart_items WHERE cart_id = $1`, c.ID); err != nil {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM cart_items WHERE cart_id = $1`, c.ID); err != nil {
 		return err
 	}
 
 	if len(c.Items) > 0 {
-		stmt, err := tx.PrepareContext(ctx, `INSERT INTO cart_items (id, cart_id, product_id, quantity, price) VALUES ($1, $2, $3, $4, $5)`)
+		stmt, err := tx.PrepareContext(ctx, `INSERT INTO cart_items (id, cart_id, product_id, quantity, price_minor, currency, priced_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`)
 		if err != nil {
 			return err
 		}
 		defer stmt.Close()
 
 		for _, it := range c.Items {
-			if _, err = stmt.ExecContext(ctx, uuid.NewString(), c.ID, it.ProductID, it.Quantity, it.Price); err != nil {
+			pricedAt := it.PricedAt
+			if pricedAt.IsZero() {
+				pricedAt = time.Now().UTC()
+			}
+			if _, err := stmt.ExecContext(ctx, uuid.NewString(), c.ID, it.ProductID, it.Quantity, it.Price.Amount, it.Price.Currency, pricedAt); err != nil {
 				return err
 			}
 		}
 	}
 
+	return saveCoupons(ctx, tx, c)
+}
+
+// saveCoupons replaces the cart's coupon codes, keeping when each was entered.
+func saveCoupons(ctx context.Context, tx *sql.Tx, c *Cart) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM cart_coupons WHERE cart_id = $1 AND NOT (code = ANY($2))`, c.ID, pq.Array(c.Coupons)); err != nil {
+		return err
+	}
+	for _, code := range c.Coupons {
+		const insertSQL = `INSERT INTO cart_coupons (cart_id, code, applied_at) VALUES ($1, $2, NOW()) ON CONFLICT (cart_id, code) DO NOTHING`
+		if _, err := tx.ExecContext(ctx, insertSQL, c.ID, code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *repo) MergeCarts(ctx context.Context, c *Cart, guest *Cart, activity ActivityFunc) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	before, err := loadBefore(ctx, tx, c.UserID, activity)
+	if err != nil {
+		return err
+	}
+	if err = saveCart(ctx, tx, c); err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM carts WHERE id = $1 AND version = $2`, guest.ID, guest.Version)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// The guest cart changed or was merged by a concurrent request.
+		err = ErrVersionConflict
+		return err
+	}
+
+	// The version check above makes guest exactly the cart that was deleted.
+	if err = recordActivity(ctx, tx, activity, before, c); err != nil {
+		return err
+	}
+	if err = recordActivity(ctx, tx, activity, guest, nil); err != nil {
+		return err
+	}
+
 	err = tx.Commit()
 	return err
 }
 
-func (r *repo) ClearCart(ctx context.Context, userID string) error {
-	_, err := r.db.ExecContext(ctx, `DELETE FROM carts WHERE user_id = $1`, userID)
+func (r *repo) ClearCart(ctx context.Context, userID string, expectedVersion int64, activity ActivityFunc) error {
+	if activity != nil {
+		return r.clearCartWithActivity(ctx, userID, expectedVersion, activity)
+	}
+	if expectedVersion == 0 {
+		_, err := r.db.ExecContext(ctx, `DELETE FROM carts WHERE user_id = $1`, userID)
+		return err
+	}
+
+	res, err := r.db.ExecContext(ctx, `DELETE FROM carts WHERE user_id = $1 AND version = $2`, userID, expectedVersion)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// clearCartWithActivity is ClearCart for callers that record activity, which
+// needs the cart's lines before they are deleted.
+func (r *repo) clearCartWithActivity(ctx context.Context, userID string, expectedVersion int64, activity ActivityFunc) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var version int64
+	err = tx.QueryRowContext(ctx, `SELECT version FROM carts WHERE user_id = $1 FOR UPDATE`, userID).Scan(&version)
+	if err == sql.ErrNoRows {
+		if expectedVersion != 0 {
+			err = ErrVersionConflict
+			return err
+		}
+		return tx.Commit()
+	}
+	if err != nil {
+		return err
+	}
+	if expectedVersion != 0 && version != expectedVersion {
+		err = ErrVersionConflict
+		return err
+	}
+
+	before, err := loadCart(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM carts WHERE id = $1`, before.ID); err != nil {
+		return err
+	}
+	if err = recordActivity(ctx, tx, activity, before, nil); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func (r *repo) CheckoutCart(ctx context.Context, c *Cart, evs []outbox.Event, idem *idempotency.Record) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// Only delete the cart the event was built from; a concurrent change means
+	// the event would no longer match what the user checked out.
+	res, err := tx.ExecContext(ctx, `DELETE FROM carts WHERE id = $1 AND user_id = $2 AND version = $3`, c.ID, c.UserID, c.Version)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		var exists bool
+		if err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM carts WHERE id = $1)`, c.ID).Scan(&exists); err != nil {
+			return err
+		}
+		err = ErrCartNotFound
+		if exists {
+			err = ErrVersionConflict
+		}
+		return err
+	}
+
+	for _, adj := range c.Adjustments {
+		if err = promotion.Redeem(ctx, tx, adj.PromotionID, c.UserID, c.ID); err != nil {
+			return err
+		}
+	}
+
+	for _, ev := range evs {
+		if err = outbox.Enqueue(ctx, tx, ev); err != nil {
+			return err
+		}
+	}
+
+	if idem != nil {
+		if err = idempotency.Save(ctx, tx, *idem); err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func (r *repo) MoveToWishlist(ctx context.Context, c *Cart, listID string, item wishlist.Item, activity ActivityFunc) error {
+	return r.saveWith(ctx, c, activity, func(tx *sql.Tx) error {
+		return wishlist.AddItemTx(ctx, tx, c.UserID, listID, item)
+	})
+}
+
+func (r *repo) MoveFromWishlist(ctx context.Context, c *Cart, listID, productID string, activity ActivityFunc) error {
+	return r.saveWith(ctx, c, activity, func(tx *sql.Tx) error {
+		return wishlist.RemoveItemTx(ctx, tx, c.UserID, listID, productID)
+	})
+}
+
+// saveWith saves c, runs fn when set and records the activity in the same
+// transaction.
+func (r *repo) saveWith(ctx context.Context, c *Cart, activity ActivityFunc, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	before, err := loadBefore(ctx, tx, c.UserID, activity)
+	if err != nil {
+		return err
+	}
+	if err = saveCart(ctx, tx, c); err != nil {
+		return err
+	}
+	if fn != nil {
+		if err = fn(tx); err != nil {
+			return err
+		}
+	}
+	if err = recordActivity(ctx, tx, activity, before, c); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
 	return err
 }
+
+// loadBefore reads the stored cart for activity, or skips the read when no
+// activity is recorded. Saving with a version check fails if the cart changes
+// after the read, so a successful save always starts from what was read.
+func loadBefore(ctx context.Context, tx *sql.Tx, userID string, activity ActivityFunc) (*Cart, error) {
+	if activity == nil {
+		return nil, nil
+	}
+	return loadCart(ctx, tx, userID)
+}
+
+// recordActivity stores the events activity builds for the change in the outbox.
+func recordActivity(ctx context.Context, tx *sql.Tx, activity ActivityFunc, before, after *Cart) error {
+	if activity == nil {
+		return nil
+	}
+	for _, ev := range activity(before, after) {
+		if err := outbox.Enqueue(ctx, tx, ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
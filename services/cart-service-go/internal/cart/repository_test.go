@@ -0,0 +1,133 @@
+package cart
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/outbox"
+)
+
+func TestUpsertCartVersionConflict(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE carts")).
+		WithArgs("c1", "u1", int64(1000), "USD", "", "", int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "updated_at"}))
+	mock.ExpectRollback()
+
+	err = NewRepository(db).UpsertCart(context.Background(), &Cart{ID: "c1", UserID: "u1", Subtotal: money.New(1000, "USD"), Version: 3}, nil)
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestUpsertCartNewCartAlreadyCreated(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("ON CONFLICT (user_id) DO NOTHING")).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "updated_at"}))
+	mock.ExpectRollback()
+
+	err = NewRepository(db).UpsertCart(context.Background(), &Cart{UserID: "u1"}, nil)
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+}
+
+func TestClearCartWithVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	repo := NewRepository(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM carts WHERE user_id = $1 AND version = $2")).
+		WithArgs("u1", int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM carts WHERE user_id = $1")).
+		WithArgs("u1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repo.ClearCart(context.Background(), "u1", 2, nil); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	if err := repo.ClearCart(context.Background(), "u1", 0, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestClearCartRecordsActivity(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version FROM carts WHERE user_id = $1 FOR UPDATE")).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(int64(2)))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM carts WHERE user_id = $1")).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "subtotal_minor", "currency", "tax_country", "tax_region", "version", "updated_at"}).
+			AddRow("c1", "u1", int64(1000), "USD", "", "", int64(2), time.Now()))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM cart_items WHERE cart_id = $1")).
+		WithArgs("c1").
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity", "price_minor", "currency", "priced_at"}).
+			AddRow("p1", 2, int64(500), "USD", time.Now()))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM cart_coupons WHERE cart_id = $1")).
+		WithArgs("c1").
+		WillReturnRows(sqlmock.NewRows([]string{"code"}))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM carts WHERE id = $1")).
+		WithArgs("c1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO event_sequences")).
+		WithArgs("c1").
+		WillReturnRows(sqlmock.NewRows([]string{"last_sequence"}).AddRow(int64(4)))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox")).
+		WithArgs("c1", int64(4), "cart.cleared.v1", []byte("cleared")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	var gotBefore, gotAfter *Cart
+	activity := func(before, after *Cart) []outbox.Event {
+		gotBefore, gotAfter = before, after
+		return []outbox.Event{{
+			PartitionKey: before.ID,
+			RoutingKey:   "cart.cleared.v1",
+			Encode:       func(sequence int64) ([]byte, error) { return []byte("cleared"), nil },
+		}}
+	}
+
+	if err := NewRepository(db).ClearCart(context.Background(), "u1", 2, activity); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotBefore == nil || len(gotBefore.Items) != 1 || gotAfter != nil {
+		t.Fatalf("expected the cleared cart and no cart after, got %+v / %+v", gotBefore, gotAfter)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
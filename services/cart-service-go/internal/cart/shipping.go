@@ -0,0 +1,36 @@
+package cart
+
+// Shipping methods a customer can choose at checkout.
+const (
+	ShippingStandard = "standard"
+	ShippingExpress  = "express"
+)
+
+// ShippingMethods lists the shipping methods in the order they are offered.
+var ShippingMethods = []string{ShippingStandard, ShippingExpress}
+
+// Address is a postal address. Country is an ISO 3166-1 alpha-2 code.
+type Address struct {
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city"`
+	State      string `json:"state,omitempty"`
+	PostalCode string `json:"postalCode"`
+	Country    string `json:"country"`
+}
+
+// Shipping is where and how a checked-out cart is delivered.
+type Shipping struct {
+	Address Address `json:"address"`
+	Method  string  `json:"method"`
+}
+
+// ValidShippingMethod reports whether method is one of ShippingMethods.
+func ValidShippingMethod(method string) bool {
+	for _, m := range ShippingMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package cart
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+// Problem codes reported by cart validation.
+const (
+	ProblemOutOfStock      = "out_of_stock"
+	ProblemPriceChanged    = "price_changed"
+	ProblemProductInactive = "product_inactive"
+	ProblemProductNotFound = "product_not_found"
+)
+
+// Problem is something about a cart line that checkout won't take as it is.
+type Problem struct {
+	Code      string `json:"code"`
+	ProductID string `json:"productId"`
+	Message   string `json:"message"`
+	// RequestedQuantity and AvailableQuantity are set for out_of_stock.
+	RequestedQuantity int  `json:"requestedQuantity,omitempty"`
+	AvailableQuantity *int `json:"availableQuantity,omitempty"`
+	// PreviousPrice and CurrentPrice are set for price_changed.
+	PreviousPrice *money.Money `json:"previousPrice,omitempty"`
+	CurrentPrice  *money.Money `json:"currentPrice,omitempty"`
+}
+
+// Validation is the result of checking a cart against the catalog and
+// inventory. CorrectedCart is the cart with its problems fixed: lines that
+// can't be bought are dropped, short lines are cut to what is free and
+// prices are brought up to date.
+type Validation struct {
+	Valid         bool      `json:"valid"`
+	Problems      []Problem `json:"problems"`
+	CorrectedCart *Cart     `json:"correctedCart"`
+	// CorrectionsToken stands for the problems found. Checkout only goes
+	// ahead with a corrected cart when the client sends back the token of the
+	// same problems.
+	CorrectionsToken string `json:"correctionsToken,omitempty"`
+}
+
+// CorrectionsToken returns a token that changes whenever problems do.
+func CorrectionsToken(problems []Problem) string {
+	b, _ := json.Marshal(problems) // plain structs always marshal
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,54 @@
+package contracts
+
+import (
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+)
+
+const (
+	CartAbandonedEventName           = "CartAbandoned"
+	CartAbandonedEventVersion        = 1
+	CartAbandonedEnvelopedSchemaPath = "contracts/events/cart/CartAbandoned.v1.enveloped.schema.json"
+)
+
+type CartAbandonedEnvelope = Envelope[CartAbandonedPayload]
+
+type CartAbandonedPayload struct {
+	CartID         string              `json:"cartId"`
+	UserID         string              `json:"userId"`
+	Items          []CartAbandonedItem `json:"items"`
+	TotalAmount    float64             `json:"totalAmount"`
+	LastActivityAt time.Time           `json:"lastActivityAt"`
+	Timestamp      time.Time           `json:"timestamp"`
+}
+
+type CartAbandonedItem struct {
+	ProductID string  `json:"productId"`
+	Quantity  int     `json:"quantity"`
+	Price     float64 `json:"price"`
+}
+
+// BuildCartAbandonedEvent builds the envelope for a cart that has been idle
+// since c.UpdatedAt.
+func BuildCartAbandonedEvent(c *cart.Cart, opts EnvelopeOptions) CartAbandonedEnvelope {
+	env := newEnvelope[CartAbandonedPayload](CartAbandonedEventName, CartAbandonedEventVersion, CartAbandonedEnvelopedSchemaPath, opts)
+
+	env.Payload = CartAbandonedPayload{
+		CartID:         c.ID,
+		UserID:         c.UserID,
+		TotalAmount:    c.Total.Float64(),
+		LastActivityAt: c.UpdatedAt.UTC(),
+		Timestamp:      env.OccurredAt,
+	}
+
+	for _, it := range c.Items {
+		env.Payload.Items = append(env.Payload.Items, CartAbandonedItem{
+			ProductID: it.ProductID,
+			Quantity:  it.Quantity,
+			Price:     it.Price.Float64(),
+		})
+	}
+
+	return env
+}
@@ -0,0 +1,76 @@
+package contracts
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+	"github.com/google/uuid"
+)
+
+func TestBuildCartAbandonedEvent(t *testing.T) {
+	now := time.Date(2024, time.May, 2, 12, 40, 0, 0, time.UTC)
+	lastActivity := now.Add(-26 * time.Hour)
+	c := &cart.Cart{
+		ID:        "7d8e9f10-1112-1314-1516-171819202122",
+		UserID:    "1a2b3c4d-5e6f-7081-920a-bc0d1e2f3a4b",
+		Items:     []cart.Item{{ProductID: "9a8b7c6d-5e4f-3a2b-1c0d-9e8f7a6b5c4d", Quantity: 2, Price: money.New(4999, "USD")}},
+		Total:     money.New(9998, "USD"),
+		UpdatedAt: lastActivity,
+	}
+
+	env := BuildCartAbandonedEvent(c, EnvelopeOptions{
+		PartitionKey: c.ID,
+		Sequence:     3,
+		OccurredAt:   now,
+	})
+
+	if env.EventName != CartAbandonedEventName || env.EventVersion != CartAbandonedEventVersion {
+		t.Fatalf("unexpected event %s v%d", env.EventName, env.EventVersion)
+	}
+	if env.Schema != CartAbandonedEnvelopedSchemaPath {
+		t.Fatalf("expected default schema path, got %s", env.Schema)
+	}
+	if env.Producer != CartServiceProducer {
+		t.Fatalf("expected default producer, got %s", env.Producer)
+	}
+	if _, err := uuid.Parse(env.EventID); err != nil {
+		t.Fatalf("expected generated event id, got %q", env.EventID)
+	}
+	if !env.Payload.LastActivityAt.Equal(lastActivity) || !env.Payload.Timestamp.Equal(now) {
+		t.Fatalf("unexpected payload times %s / %s", env.Payload.LastActivityAt, env.Payload.Timestamp)
+	}
+
+	if err := validateAgainstSchema(env.PartitionKey, env.Sequence, env, "CartAbandoned.v1.enveloped.schema.json"); err != nil {
+		t.Fatalf("expected envelope to be valid, got error: %v", err)
+	}
+
+	t.Run("empty cart", func(t *testing.T) {
+		empty := BuildCartAbandonedEvent(&cart.Cart{ID: c.ID, UserID: c.UserID}, EnvelopeOptions{PartitionKey: c.ID, Sequence: 1})
+		if err := validateAgainstSchema(empty.PartitionKey, empty.Sequence, empty, "CartAbandoned.v1.enveloped.schema.json"); err == nil {
+			t.Fatalf("expected an abandoned cart without items to be invalid")
+		}
+	})
+}
+
+func TestCartAbandonedExampleMatchesSchema(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+	repoRoot := filepath.Clean(filepath.Join(filepath.Dir(filename), "..", "..", "..", ".."))
+	raw, err := os.ReadFile(filepath.Join(repoRoot, "contracts", "examples", "cart", "CartAbandoned.v1.json"))
+	if err != nil {
+		t.Fatalf("read example: %v", err)
+	}
+
+	var env CartAbandonedEnvelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		t.Fatalf("decode example: %v", err)
+	}
+	if err := validateAgainstSchema(env.PartitionKey, env.Sequence, env, "CartAbandoned.v1.enveloped.schema.json"); err != nil {
+		t.Fatalf("example does not match schema: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package contracts
+
+import (
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+const (
+	CartItemAddedEventName           = "CartItemAdded"
+	CartItemAddedEventVersion        = 1
+	CartItemAddedEnvelopedSchemaPath = "contracts/events/cart/CartItemAdded.v1.enveloped.schema.json"
+
+	CartItemRemovedEventName           = "CartItemRemoved"
+	CartItemRemovedEventVersion        = 1
+	CartItemRemovedEnvelopedSchemaPath = "contracts/events/cart/CartItemRemoved.v1.enveloped.schema.json"
+
+	CartItemQuantityChangedEventName           = "CartItemQuantityChanged"
+	CartItemQuantityChangedEventVersion        = 1
+	CartItemQuantityChangedEnvelopedSchemaPath = "contracts/events/cart/CartItemQuantityChanged.v1.enveloped.schema.json"
+
+	CartClearedEventName           = "CartCleared"
+	CartClearedEventVersion        = 1
+	CartClearedEnvelopedSchemaPath = "contracts/events/cart/CartCleared.v1.enveloped.schema.json"
+)
+
+type CartItemAddedEnvelope = Envelope[CartItemAddedPayload]
+
+type CartItemAddedPayload struct {
+	CartID    string      `json:"cartId"`
+	UserID    string      `json:"userId"`
+	ProductID string      `json:"productId"`
+	Quantity  int         `json:"quantity"`
+	Price     money.Money `json:"price"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+type CartItemRemovedEnvelope = Envelope[CartItemRemovedPayload]
+
+type CartItemRemovedPayload struct {
+	CartID    string      `json:"cartId"`
+	UserID    string      `json:"userId"`
+	ProductID string      `json:"productId"`
+	Quantity  int         `json:"quantity"`
+	Price     money.Money `json:"price"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+type CartItemQuantityChangedEnvelope = Envelope[CartItemQuantityChangedPayload]
+
+type CartItemQuantityChangedPayload struct {
+	CartID           string      `json:"cartId"`
+	UserID           string      `json:"userId"`
+	ProductID        string      `json:"productId"`
+	PreviousQuantity int         `json:"previousQuantity"`
+	Quantity         int         `json:"quantity"`
+	Price            money.Money `json:"price"`
+	Timestamp        time.Time   `json:"timestamp"`
+}
+
+type CartClearedEnvelope = Envelope[CartClearedPayload]
+
+type CartClearedPayload struct {
+	CartID    string            `json:"cartId"`
+	UserID    string            `json:"userId"`
+	Items     []CartClearedItem `json:"items"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+type CartClearedItem struct {
+	ProductID string      `json:"productId"`
+	Quantity  int         `json:"quantity"`
+	Price     money.Money `json:"price"`
+}
+
+// BuildCartItemAddedEvent builds the envelope for it having been added to c as
+// a new line.
+func BuildCartItemAddedEvent(c *cart.Cart, it cart.Item, opts EnvelopeOptions) CartItemAddedEnvelope {
+	env := newEnvelope[CartItemAddedPayload](CartItemAddedEventName, CartItemAddedEventVersion, CartItemAddedEnvelopedSchemaPath, opts)
+
+	env.Payload = CartItemAddedPayload{
+		CartID:    c.ID,
+		UserID:    c.UserID,
+		ProductID: it.ProductID,
+		Quantity:  it.Quantity,
+		Price:     it.Price,
+		Timestamp: env.OccurredAt,
+	}
+	return env
+}
+
+// BuildCartItemRemovedEvent builds the envelope for the line it having been
+// taken out of c.
+func BuildCartItemRemovedEvent(c *cart.Cart, it cart.Item, opts EnvelopeOptions) CartItemRemovedEnvelope {
+	env := newEnvelope[CartItemRemovedPayload](CartItemRemovedEventName, CartItemRemovedEventVersion, CartItemRemovedEnvelopedSchemaPath, opts)
+
+	env.Payload = CartItemRemovedPayload{
+		CartID:    c.ID,
+		UserID:    c.UserID,
+		ProductID: it.ProductID,
+		Quantity:  it.Quantity,
+		Price:     it.Price,
+		Timestamp: env.OccurredAt,
+	}
+	return env
+}
+
+// BuildCartItemQuantityChangedEvent builds the envelope for the line it, which
+// held previousQuantity before the change.
+func BuildCartItemQuantityChangedEvent(c *cart.Cart, previousQuantity int, it cart.Item, opts EnvelopeOptions) CartItemQuantityChangedEnvelope {
+	env := newEnvelope[CartItemQuantityChangedPayload](CartItemQuantityChangedEventName, CartItemQuantityChangedEventVersion, CartItemQuantityChangedEnvelopedSchemaPath, opts)
+
+	env.Payload = CartItemQuantityChangedPayload{
+		CartID:           c.ID,
+		UserID:           c.UserID,
+		ProductID:        it.ProductID,
+		PreviousQuantity: previousQuantity,
+		Quantity:         it.Quantity,
+		Price:            it.Price,
+		Timestamp:        env.OccurredAt,
+	}
+	return env
+}
+
+// BuildCartClearedEvent builds the envelope for c, as it was just before it
+// was cleared.
+func BuildCartClearedEvent(c *cart.Cart, opts EnvelopeOptions) CartClearedEnvelope {
+	env := newEnvelope[CartClearedPayload](CartClearedEventName, CartClearedEventVersion, CartClearedEnvelopedSchemaPath, opts)
+
+	env.Payload = CartClearedPayload{
+		CartID:    c.ID,
+		UserID:    c.UserID,
+		Items:     []CartClearedItem{},
+		Timestamp: env.OccurredAt,
+	}
+
+	for _, it := range c.Items {
+		env.Payload.Items = append(env.Payload.Items, CartClearedItem{
+			ProductID: it.ProductID,
+			Quantity:  it.Quantity,
+			Price:     it.Price,
+		})
+	}
+
+	return env
+}
@@ -0,0 +1,85 @@
+package contracts
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+func TestBuildCartActivityEvents(t *testing.T) {
+	now := time.Date(2024, time.May, 1, 12, 30, 0, 0, time.UTC)
+	it := cart.Item{ProductID: "9a8b7c6d-5e4f-3a2b-1c0d-9e8f7a6b5c4d", Quantity: 2, Price: money.New(4999, "USD")}
+	c := &cart.Cart{
+		ID:     "7d8e9f10-1112-1314-1516-171819202122",
+		UserID: cart.NewGuestID(),
+		Items:  []cart.Item{it},
+	}
+	opts := EnvelopeOptions{
+		PartitionKey:  c.ID,
+		Sequence:      4,
+		CorrelationID: "6a8c0e2f-4b5d-4e7a-8c9f-1b3d5e6f7a8b",
+		OccurredAt:    now,
+	}
+
+	tests := []struct {
+		name   string
+		env    any
+		schema string
+	}{
+		{"added", BuildCartItemAddedEvent(c, it, opts), "CartItemAdded.v1.enveloped.schema.json"},
+		{"removed", BuildCartItemRemovedEvent(c, it, opts), "CartItemRemoved.v1.enveloped.schema.json"},
+		{"quantity changed", BuildCartItemQuantityChangedEvent(c, 1, it, opts), "CartItemQuantityChanged.v1.enveloped.schema.json"},
+		{"cleared", BuildCartClearedEvent(c, opts), "CartCleared.v1.enveloped.schema.json"},
+		{"cleared empty cart", BuildCartClearedEvent(&cart.Cart{ID: c.ID, UserID: c.UserID}, opts), "CartCleared.v1.enveloped.schema.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateAgainstSchema(opts.PartitionKey, opts.Sequence, tt.env, tt.schema); err != nil {
+				t.Fatalf("expected envelope to be valid, got error: %v", err)
+			}
+		})
+	}
+
+	changed := BuildCartItemQuantityChangedEvent(c, 1, it, opts)
+	if changed.EventName != CartItemQuantityChangedEventName || changed.Schema != CartItemQuantityChangedEnvelopedSchemaPath {
+		t.Fatalf("unexpected event %s / %s", changed.EventName, changed.Schema)
+	}
+	if changed.Payload.PreviousQuantity != 1 || changed.Payload.Quantity != 2 || !changed.Payload.Timestamp.Equal(now) {
+		t.Fatalf("unexpected payload %+v", changed.Payload)
+	}
+
+	t.Run("zero quantity", func(t *testing.T) {
+		env := BuildCartItemQuantityChangedEvent(c, 0, it, opts)
+		if err := validateAgainstSchema(env.PartitionKey, env.Sequence, env, "CartItemQuantityChanged.v1.enveloped.schema.json"); err == nil {
+			t.Fatalf("expected a line without a previous quantity to be invalid")
+		}
+	})
+}
+
+func TestCartActivityExamplesMatchSchemas(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+	repoRoot := filepath.Clean(filepath.Join(filepath.Dir(filename), "..", "..", "..", ".."))
+
+	for _, name := range []string{CartItemAddedEventName, CartItemRemovedEventName, CartItemQuantityChangedEventName, CartClearedEventName} {
+		t.Run(name, func(t *testing.T) {
+			raw, err := os.ReadFile(filepath.Join(repoRoot, "contracts", "examples", "cart", name+".v1.json"))
+			if err != nil {
+				t.Fatalf("read example: %v", err)
+			}
+
+			var env Envelope[json.RawMessage]
+			if err := json.Unmarshal(raw, &env); err != nil {
+				t.Fatalf("decode example: %v", err)
+			}
+			if err := validateAgainstSchema(env.PartitionKey, env.Sequence, env, name+".v1.enveloped.schema.json"); err != nil {
+				t.Fatalf("example does not match schema: %v", err)
+			}
+		})
+	}
+}
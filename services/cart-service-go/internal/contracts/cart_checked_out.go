@@ -14,20 +14,23 @@ const (
 	CartServiceProducer               = "cart-service"
 )
 
-type EventEnvelope struct {
-	EventName     string                `json:"eventName"`
-	EventVersion  int                   `json:"eventVersion"`
-	EventID       string                `json:"eventId"`
-	CorrelationID string                `json:"correlationId,omitempty"`
-	CausationID   string                `json:"causationId,omitempty"`
-	Producer      string                `json:"producer"`
-	PartitionKey  string                `json:"partitionKey"`
-	Sequence      int64                 `json:"sequence"`
-	OccurredAt    time.Time             `json:"occurredAt"`
-	Schema        string                `json:"schema"`
-	Payload       CartCheckedOutPayload `json:"payload"`
+// Envelope is the v1 event envelope around a typed payload.
+type Envelope[T any] struct {
+	EventName     string    `json:"eventName"`
+	EventVersion  int       `json:"eventVersion"`
+	EventID       string    `json:"eventId"`
+	CorrelationID string    `json:"correlationId,omitempty"`
+	CausationID   string    `json:"causationId,omitempty"`
+	Producer      string    `json:"producer"`
+	PartitionKey  string    `json:"partitionKey"`
+	Sequence      int64     `json:"sequence"`
+	OccurredAt    time.Time `json:"occurredAt"`
+	Schema        string    `json:"schema"`
+	Payload       T         `json:"payload"`
 }
 
+type EventEnvelope = Envelope[CartCheckedOutPayload]
+
 type CartCheckedOutPayload struct {
 	CartID      string               `json:"cartId"`
 	UserID      string               `json:"userId"`
@@ -54,6 +57,29 @@ type EnvelopeOptions struct {
 }
 
 func BuildCartCheckedOutEvent(c *cart.Cart, opts EnvelopeOptions) EventEnvelope {
+	env := newEnvelope[CartCheckedOutPayload](CartCheckedOutEventName, CartCheckedOutEventVersion, CartCheckedOutEnvelopedSchemaPath, opts)
+
+	env.Payload = CartCheckedOutPayload{
+		CartID:      c.ID,
+		UserID:      c.UserID,
+		TotalAmount: c.Total.Float64(),
+		Timestamp:   env.OccurredAt,
+	}
+
+	for _, it := range c.Items {
+		env.Payload.Items = append(env.Payload.Items, CartCheckedOutItem{
+			ProductID: it.ProductID,
+			Quantity:  it.Quantity,
+			Price:     it.Price.Float64(),
+		})
+	}
+
+	return env
+}
+
+// newEnvelope fills the envelope metadata, defaulting the event id, time,
+// producer and schema path when opts leaves them empty.
+func newEnvelope[T any](name string, version int, defaultSchemaPath string, opts EnvelopeOptions) Envelope[T] {
 	eventID := opts.EventID
 	if eventID == "" {
 		eventID = uuid.NewString()
@@ -66,7 +92,7 @@ func BuildCartCheckedOutEvent(c *cart.Cart, opts EnvelopeOptions) EventEnvelope
 
 	schemaPath := opts.SchemaPath
 	if schemaPath == "" {
-		schemaPath = CartCheckedOutEnvelopedSchemaPath
+		schemaPath = defaultSchemaPath
 	}
 
 	producer := opts.Producer
@@ -74,24 +100,9 @@ func BuildCartCheckedOutEvent(c *cart.Cart, opts EnvelopeOptions) EventEnvelope
 		producer = CartServiceProducer
 	}
 
-	payload := CartCheckedOutPayload{
-		CartID:      c.ID,
-		UserID:      c.UserID,
-		TotalAmount: c.Total,
-		Timestamp:   occurredAt,
-	}
-
-	for _, it := range c.Items {
-		payload.Items = append(payload.Items, CartCheckedOutItem{
-			ProductID: it.ProductID,
-			Quantity:  it.Quantity,
-			Price:     it.Price,
-		})
-	}
-
-	return EventEnvelope{
-		EventName:     CartCheckedOutEventName,
-		EventVersion:  CartCheckedOutEventVersion,
+	return Envelope[T]{
+		EventName:     name,
+		EventVersion:  version,
 		EventID:       eventID,
 		CorrelationID: opts.CorrelationID,
 		CausationID:   opts.CausationID,
@@ -100,6 +111,5 @@ func BuildCartCheckedOutEvent(c *cart.Cart, opts EnvelopeOptions) EventEnvelope
 		Sequence:      opts.Sequence,
 		OccurredAt:    occurredAt,
 		Schema:        schemaPath,
-		Payload:       payload,
 	}
 }
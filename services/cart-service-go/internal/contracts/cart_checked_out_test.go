@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
 	"github.com/google/uuid"
 )
 
@@ -22,9 +23,9 @@ func TestBuildCartCheckedOutEvent(t *testing.T) {
 		ID:     "a9c9bf1d-32f2-46a0-9243-97c2cf8a6c4a",
 		UserID: "1d439ea2-c678-4f2a-9ca9-d8a9755a6a5d",
 		Items: []cart.Item{
-			{ProductID: "15b50d93-e94b-4e2b-aba8-9ed785a7cdf6", Quantity: 2, Price: 3.5},
+			{ProductID: "15b50d93-e94b-4e2b-aba8-9ed785a7cdf6", Quantity: 2, Price: money.New(350, "USD")},
 		},
-		Total: 7.0,
+		Total: money.New(700, "USD"),
 	}
 
 	env := BuildCartCheckedOutEvent(c, EnvelopeOptions{
@@ -77,10 +78,10 @@ func TestCartCheckedOutEnvelopeSchemaValidation(t *testing.T) {
 			ID:     "e4a9ae13-b4a6-489a-a5fd-89bd88d8e1a5",
 			UserID: "f8f98928-87f6-4fce-8c55-7a7a62012f1a",
 			Items: []cart.Item{
-				{ProductID: "f4007d5d-0212-4bf0-996a-bfde9e0f0170", Quantity: 1, Price: 10.0},
-				{ProductID: "bb0a9128-b176-4c0c-9240-8c9a25ffbfc8", Quantity: 2, Price: 5.0},
+				{ProductID: "f4007d5d-0212-4bf0-996a-bfde9e0f0170", Quantity: 1, Price: money.New(1000, "USD")},
+				{ProductID: "bb0a9128-b176-4c0c-9240-8c9a25ffbfc8", Quantity: 2, Price: money.New(500, "USD")},
 			},
-			Total: 20.0,
+			Total: money.New(2000, "USD"),
 		}
 
 		return BuildCartCheckedOutEvent(c, EnvelopeOptions{
@@ -143,10 +144,14 @@ func assertEnvelopeInvalid(t *testing.T, env EventEnvelope) {
 }
 
 func validateEnvelopeAgainstSchema(env EventEnvelope) error {
-	if env.PartitionKey == "" {
+	return validateAgainstSchema(env.PartitionKey, env.Sequence, env, "CartCheckedOut.v1.enveloped.schema.json")
+}
+
+func validateAgainstSchema(partitionKey string, sequence int64, env any, schemaFile string) error {
+	if partitionKey == "" {
 		return errors.New("partitionKey is required")
 	}
-	if env.Sequence <= 0 {
+	if sequence <= 0 {
 		return errors.New("sequence must be positive")
 	}
 
@@ -157,7 +162,7 @@ func validateEnvelopeAgainstSchema(env EventEnvelope) error {
 
 	_, filename, _, _ := runtime.Caller(0)
 	repoRoot := filepath.Clean(filepath.Join(filepath.Dir(filename), "..", "..", "..", ".."))
-	schemaPath := filepath.Join(repoRoot, "contracts", "events", "cart", "CartCheckedOut.v1.enveloped.schema.json")
+	schemaPath := filepath.Join(repoRoot, "contracts", "events", "cart", schemaFile)
 	schema, baseDir, err := loadSchema(schemaPath)
 	if err != nil {
 		return err
@@ -0,0 +1,51 @@
+package contracts
+
+import (
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+const (
+	CartCheckedOutV2EventVersion        = 2
+	CartCheckedOutV2EnvelopedSchemaPath = "contracts/events/cart/CartCheckedOut.v2.enveloped.schema.json"
+)
+
+type CartCheckedOutV2Envelope = Envelope[CartCheckedOutV2Payload]
+
+// CartCheckedOutV2Payload carries amounts as Money instead of floats.
+type CartCheckedOutV2Payload struct {
+	CartID      string                 `json:"cartId"`
+	UserID      string                 `json:"userId"`
+	Items       []CartCheckedOutV2Item `json:"items"`
+	TotalAmount money.Money            `json:"totalAmount"`
+	Timestamp   time.Time              `json:"timestamp"`
+}
+
+type CartCheckedOutV2Item struct {
+	ProductID string      `json:"productId"`
+	Quantity  int         `json:"quantity"`
+	Price     money.Money `json:"price"`
+}
+
+func BuildCartCheckedOutV2Event(c *cart.Cart, opts EnvelopeOptions) CartCheckedOutV2Envelope {
+	env := newEnvelope[CartCheckedOutV2Payload](CartCheckedOutEventName, CartCheckedOutV2EventVersion, CartCheckedOutV2EnvelopedSchemaPath, opts)
+
+	env.Payload = CartCheckedOutV2Payload{
+		CartID:      c.ID,
+		UserID:      c.UserID,
+		TotalAmount: c.Total,
+		Timestamp:   env.OccurredAt,
+	}
+
+	for _, it := range c.Items {
+		env.Payload.Items = append(env.Payload.Items, CartCheckedOutV2Item{
+			ProductID: it.ProductID,
+			Quantity:  it.Quantity,
+			Price:     it.Price,
+		})
+	}
+
+	return env
+}
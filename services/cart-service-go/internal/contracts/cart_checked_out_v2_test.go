@@ -0,0 +1,55 @@
+package contracts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+func TestBuildCartCheckedOutV2Event(t *testing.T) {
+	makeEnvelope := func() CartCheckedOutV2Envelope {
+		c := &cart.Cart{
+			ID:     "e4a9ae13-b4a6-489a-a5fd-89bd88d8e1a5",
+			UserID: "f8f98928-87f6-4fce-8c55-7a7a62012f1a",
+			Items: []cart.Item{
+				{ProductID: "f4007d5d-0212-4bf0-996a-bfde9e0f0170", Quantity: 3, Price: money.New(1999, "EUR")},
+			},
+			Total: money.New(5997, "EUR"),
+		}
+		return BuildCartCheckedOutV2Event(c, EnvelopeOptions{
+			PartitionKey: c.ID,
+			Sequence:     2,
+			OccurredAt:   time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC),
+		})
+	}
+
+	env := makeEnvelope()
+	if env.EventVersion != CartCheckedOutV2EventVersion || env.Schema != CartCheckedOutV2EnvelopedSchemaPath {
+		t.Fatalf("unexpected version/schema %d %s", env.EventVersion, env.Schema)
+	}
+	if env.Payload.TotalAmount != money.New(5997, "EUR") || env.Payload.Items[0].Price != money.New(1999, "EUR") {
+		t.Fatalf("expected money amounts to be copied exactly, got %+v", env.Payload)
+	}
+	if err := validateAgainstSchema(env.PartitionKey, env.Sequence, env, "CartCheckedOut.v2.enveloped.schema.json"); err != nil {
+		t.Fatalf("expected envelope to be valid, got error: %v", err)
+	}
+
+	t.Run("v1 schema rejects money objects", func(t *testing.T) {
+		invalid := makeEnvelope()
+		invalid.EventVersion = CartCheckedOutEventVersion
+		invalid.Schema = CartCheckedOutEnvelopedSchemaPath
+		if err := validateAgainstSchema(invalid.PartitionKey, invalid.Sequence, invalid, "CartCheckedOut.v1.enveloped.schema.json"); err == nil {
+			t.Fatalf("expected v2 payload to be invalid against v1 schema")
+		}
+	})
+
+	t.Run("missing currency", func(t *testing.T) {
+		invalid := makeEnvelope()
+		invalid.Payload.TotalAmount.Currency = ""
+		if err := validateAgainstSchema(invalid.PartitionKey, invalid.Sequence, invalid, "CartCheckedOut.v2.enveloped.schema.json"); err == nil {
+			t.Fatalf("expected envelope without currency to be invalid")
+		}
+	})
+}
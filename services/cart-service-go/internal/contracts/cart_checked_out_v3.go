@@ -0,0 +1,65 @@
+package contracts
+
+import (
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+const (
+	CartCheckedOutV3EventVersion        = 3
+	CartCheckedOutV3EnvelopedSchemaPath = "contracts/events/cart/CartCheckedOut.v3.enveloped.schema.json"
+)
+
+type CartCheckedOutV3Envelope = Envelope[CartCheckedOutV3Payload]
+
+// CartCheckedOutV3Payload adds the subtotal and coupon adjustments to v2.
+// TotalAmount is the discounted total before tax; v4 adds the tax.
+type CartCheckedOutV3Payload struct {
+	CartID      string                       `json:"cartId"`
+	UserID      string                       `json:"userId"`
+	Items       []CartCheckedOutV2Item       `json:"items"`
+	Subtotal    money.Money                  `json:"subtotal"`
+	Adjustments []CartCheckedOutV3Adjustment `json:"adjustments"`
+	TotalAmount money.Money                  `json:"totalAmount"`
+	Timestamp   time.Time                    `json:"timestamp"`
+}
+
+type CartCheckedOutV3Adjustment struct {
+	Code        string      `json:"code"`
+	PromotionID string      `json:"promotionId"`
+	Description string      `json:"description,omitempty"`
+	Amount      money.Money `json:"amount"`
+}
+
+func BuildCartCheckedOutV3Event(c *cart.Cart, opts EnvelopeOptions) CartCheckedOutV3Envelope {
+	env := newEnvelope[CartCheckedOutV3Payload](CartCheckedOutEventName, CartCheckedOutV3EventVersion, CartCheckedOutV3EnvelopedSchemaPath, opts)
+
+	env.Payload = CartCheckedOutV3Payload{
+		CartID:      c.ID,
+		UserID:      c.UserID,
+		Subtotal:    c.Subtotal,
+		Adjustments: []CartCheckedOutV3Adjustment{},
+		TotalAmount: c.TotalBeforeTax(),
+		Timestamp:   env.OccurredAt,
+	}
+
+	for _, it := range c.Items {
+		env.Payload.Items = append(env.Payload.Items, CartCheckedOutV2Item{
+			ProductID: it.ProductID,
+			Quantity:  it.Quantity,
+			Price:     it.Price,
+		})
+	}
+	for _, adj := range c.Adjustments {
+		env.Payload.Adjustments = append(env.Payload.Adjustments, CartCheckedOutV3Adjustment{
+			Code:        adj.Code,
+			PromotionID: adj.PromotionID,
+			Description: adj.Description,
+			Amount:      adj.Amount,
+		})
+	}
+
+	return env
+}
@@ -0,0 +1,87 @@
+package contracts
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+func TestBuildCartCheckedOutV3Event(t *testing.T) {
+	makeEnvelope := func() CartCheckedOutV3Envelope {
+		c := &cart.Cart{
+			ID:     "e4a9ae13-b4a6-489a-a5fd-89bd88d8e1a5",
+			UserID: "f8f98928-87f6-4fce-8c55-7a7a62012f1a",
+			Items: []cart.Item{
+				{ProductID: "f4007d5d-0212-4bf0-996a-bfde9e0f0170", Quantity: 3, Price: money.New(1999, "EUR")},
+			},
+			Adjustments: []cart.Adjustment{
+				{Code: "SAVE5", PromotionID: "0f1e2d3c-4b5a-4697-8877-665544332211", Amount: money.New(-500, "EUR")},
+			},
+		}
+		if err := c.RecalculateTotal(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return BuildCartCheckedOutV3Event(c, EnvelopeOptions{
+			PartitionKey: c.ID,
+			Sequence:     3,
+			OccurredAt:   time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC),
+		})
+	}
+
+	env := makeEnvelope()
+	if env.EventVersion != CartCheckedOutV3EventVersion || env.Schema != CartCheckedOutV3EnvelopedSchemaPath {
+		t.Fatalf("unexpected version/schema %d %s", env.EventVersion, env.Schema)
+	}
+	if env.Payload.Subtotal != money.New(5997, "EUR") || env.Payload.TotalAmount != money.New(5497, "EUR") {
+		t.Fatalf("expected subtotal 59.97 and discounted total 54.97, got %+v", env.Payload)
+	}
+	if len(env.Payload.Adjustments) != 1 || env.Payload.Adjustments[0].Amount != money.New(-500, "EUR") {
+		t.Fatalf("unexpected adjustments %+v", env.Payload.Adjustments)
+	}
+	if err := validateAgainstSchema(env.PartitionKey, env.Sequence, env, "CartCheckedOut.v3.enveloped.schema.json"); err != nil {
+		t.Fatalf("expected envelope to be valid, got error: %v", err)
+	}
+
+	t.Run("without coupons", func(t *testing.T) {
+		plain := makeEnvelope()
+		plain.Payload.Adjustments = BuildCartCheckedOutV3Event(&cart.Cart{}, EnvelopeOptions{}).Payload.Adjustments
+		if plain.Payload.Adjustments == nil {
+			t.Fatalf("expected an empty adjustments array rather than null")
+		}
+		if err := validateAgainstSchema(plain.PartitionKey, plain.Sequence, plain, "CartCheckedOut.v3.enveloped.schema.json"); err != nil {
+			t.Fatalf("expected envelope to be valid, got error: %v", err)
+		}
+	})
+
+	t.Run("v2 schema rejects adjustments", func(t *testing.T) {
+		invalid := makeEnvelope()
+		invalid.EventVersion = CartCheckedOutV2EventVersion
+		invalid.Schema = CartCheckedOutV2EnvelopedSchemaPath
+		if err := validateAgainstSchema(invalid.PartitionKey, invalid.Sequence, invalid, "CartCheckedOut.v2.enveloped.schema.json"); err == nil {
+			t.Fatalf("expected v3 payload to be invalid against v2 schema")
+		}
+	})
+}
+
+func TestCartCheckedOutV3ExampleMatchesSchema(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+	repoRoot := filepath.Clean(filepath.Join(filepath.Dir(filename), "..", "..", "..", ".."))
+	raw, err := os.ReadFile(filepath.Join(repoRoot, "contracts", "examples", "cart", "CartCheckedOut.v3.json"))
+	if err != nil {
+		t.Fatalf("read example: %v", err)
+	}
+
+	var env CartCheckedOutV3Envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		t.Fatalf("decode example: %v", err)
+	}
+	if err := validateAgainstSchema(env.PartitionKey, env.Sequence, env, "CartCheckedOut.v3.enveloped.schema.json"); err != nil {
+		t.Fatalf("example does not match schema: %v", err)
+	}
+}
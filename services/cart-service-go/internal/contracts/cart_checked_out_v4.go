@@ -0,0 +1,82 @@
+package contracts
+
+import (
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+const (
+	CartCheckedOutV4EventVersion        = 4
+	CartCheckedOutV4EnvelopedSchemaPath = "contracts/events/cart/CartCheckedOut.v4.enveloped.schema.json"
+)
+
+type CartCheckedOutV4Envelope = Envelope[CartCheckedOutV4Payload]
+
+// CartCheckedOutV4Payload adds the tax breakdown to v3. Tax is nil when the
+// cart was not taxed; TotalAmount includes the tax not already in the prices.
+type CartCheckedOutV4Payload struct {
+	CartID      string                       `json:"cartId"`
+	UserID      string                       `json:"userId"`
+	Items       []CartCheckedOutV2Item       `json:"items"`
+	Subtotal    money.Money                  `json:"subtotal"`
+	Adjustments []CartCheckedOutV3Adjustment `json:"adjustments"`
+	Tax         *CartCheckedOutV4Tax         `json:"tax,omitempty"`
+	TotalAmount money.Money                  `json:"totalAmount"`
+	Timestamp   time.Time                    `json:"timestamp"`
+}
+
+type CartCheckedOutV4Tax struct {
+	Country      string                    `json:"country"`
+	Region       string                    `json:"region,omitempty"`
+	Lines        []CartCheckedOutV4TaxLine `json:"lines"`
+	TotalTax     money.Money               `json:"totalTax"`
+	ExclusiveTax money.Money               `json:"exclusiveTax"`
+}
+
+type CartCheckedOutV4TaxLine struct {
+	ProductID       string      `json:"productId"`
+	TaxClass        string      `json:"taxClass"`
+	RateBasisPoints int         `json:"rateBasisPoints"`
+	Inclusive       bool        `json:"inclusive"`
+	TaxableAmount   money.Money `json:"taxableAmount"`
+	TaxAmount       money.Money `json:"taxAmount"`
+}
+
+func BuildCartCheckedOutV4Event(c *cart.Cart, opts EnvelopeOptions) CartCheckedOutV4Envelope {
+	env := newEnvelope[CartCheckedOutV4Payload](CartCheckedOutEventName, CartCheckedOutV4EventVersion, CartCheckedOutV4EnvelopedSchemaPath, opts)
+
+	v3 := BuildCartCheckedOutV3Event(c, opts).Payload
+	env.Payload = CartCheckedOutV4Payload{
+		CartID:      v3.CartID,
+		UserID:      v3.UserID,
+		Items:       v3.Items,
+		Subtotal:    v3.Subtotal,
+		Adjustments: v3.Adjustments,
+		TotalAmount: c.Total,
+		Timestamp:   env.OccurredAt,
+	}
+
+	if c.Tax != nil {
+		env.Payload.Tax = &CartCheckedOutV4Tax{
+			Country:      c.Tax.Country,
+			Region:       c.Tax.Region,
+			Lines:        []CartCheckedOutV4TaxLine{},
+			TotalTax:     c.Tax.Total,
+			ExclusiveTax: c.Tax.Exclusive,
+		}
+		for _, l := range c.Tax.Lines {
+			env.Payload.Tax.Lines = append(env.Payload.Tax.Lines, CartCheckedOutV4TaxLine{
+				ProductID:       l.ProductID,
+				TaxClass:        l.TaxClass,
+				RateBasisPoints: l.RateBasisPoints,
+				Inclusive:       l.Inclusive,
+				TaxableAmount:   l.Taxable,
+				TaxAmount:       l.Amount,
+			})
+		}
+	}
+
+	return env
+}
@@ -0,0 +1,104 @@
+package contracts
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/tax"
+)
+
+func TestBuildCartCheckedOutV4Event(t *testing.T) {
+	makeEnvelope := func(b *tax.Breakdown) CartCheckedOutV4Envelope {
+		c := &cart.Cart{
+			ID:     "e4a9ae13-b4a6-489a-a5fd-89bd88d8e1a5",
+			UserID: "f8f98928-87f6-4fce-8c55-7a7a62012f1a",
+			Items: []cart.Item{
+				{ProductID: "f4007d5d-0212-4bf0-996a-bfde9e0f0170", Quantity: 2, Price: money.New(1000, "USD")},
+			},
+			Adjustments: []cart.Adjustment{
+				{Code: "SAVE5", PromotionID: "0f1e2d3c-4b5a-4697-8877-665544332211", Amount: money.New(-500, "USD")},
+			},
+			Tax: b,
+		}
+		if err := c.RecalculateTotal(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return BuildCartCheckedOutV4Event(c, EnvelopeOptions{
+			PartitionKey: c.ID,
+			Sequence:     4,
+			OccurredAt:   time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC),
+		})
+	}
+
+	env := makeEnvelope(&tax.Breakdown{
+		Country: "US",
+		Region:  "NY",
+		Lines: []tax.LineTax{{
+			ProductID:       "f4007d5d-0212-4bf0-996a-bfde9e0f0170",
+			TaxClass:        tax.DefaultClass,
+			RateBasisPoints: 888,
+			Taxable:         money.New(1500, "USD"),
+			Amount:          money.New(133, "USD"),
+		}},
+		Total:     money.New(133, "USD"),
+		Exclusive: money.New(133, "USD"),
+	})
+	if env.EventVersion != CartCheckedOutV4EventVersion || env.Schema != CartCheckedOutV4EnvelopedSchemaPath {
+		t.Fatalf("unexpected version/schema %d %s", env.EventVersion, env.Schema)
+	}
+	if env.Payload.Subtotal != money.New(2000, "USD") || env.Payload.TotalAmount != money.New(1633, "USD") {
+		t.Fatalf("expected subtotal 20.00 and taxed total 16.33, got %+v", env.Payload)
+	}
+	if env.Payload.Tax == nil || len(env.Payload.Tax.Lines) != 1 || env.Payload.Tax.Lines[0].TaxableAmount != money.New(1500, "USD") {
+		t.Fatalf("unexpected tax %+v", env.Payload.Tax)
+	}
+	if err := validateAgainstSchema(env.PartitionKey, env.Sequence, env, "CartCheckedOut.v4.enveloped.schema.json"); err != nil {
+		t.Fatalf("expected envelope to be valid, got error: %v", err)
+	}
+
+	t.Run("without tax", func(t *testing.T) {
+		untaxed := makeEnvelope(nil)
+		if untaxed.Payload.Tax != nil || untaxed.Payload.TotalAmount != money.New(1500, "USD") {
+			t.Fatalf("expected no tax and the discounted total, got %+v", untaxed.Payload)
+		}
+		if err := validateAgainstSchema(untaxed.PartitionKey, untaxed.Sequence, untaxed, "CartCheckedOut.v4.enveloped.schema.json"); err != nil {
+			t.Fatalf("expected envelope to be valid, got error: %v", err)
+		}
+	})
+
+	t.Run("v3 keeps the total before tax", func(t *testing.T) {
+		c := &cart.Cart{
+			Items: []cart.Item{{ProductID: "p1", Quantity: 1, Price: money.New(1000, "USD")}},
+			Tax:   &tax.Breakdown{Total: money.New(50, "USD"), Exclusive: money.New(50, "USD")},
+		}
+		if err := c.RecalculateTotal(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := BuildCartCheckedOutV3Event(c, EnvelopeOptions{}).Payload.TotalAmount; got != money.New(1000, "USD") {
+			t.Fatalf("expected v3 total 10.00, got %+v", got)
+		}
+	})
+}
+
+func TestCartCheckedOutV4ExampleMatchesSchema(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+	repoRoot := filepath.Clean(filepath.Join(filepath.Dir(filename), "..", "..", "..", ".."))
+	raw, err := os.ReadFile(filepath.Join(repoRoot, "contracts", "examples", "cart", "CartCheckedOut.v4.json"))
+	if err != nil {
+		t.Fatalf("read example: %v", err)
+	}
+
+	var env CartCheckedOutV4Envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		t.Fatalf("decode example: %v", err)
+	}
+	if err := validateAgainstSchema(env.PartitionKey, env.Sequence, env, "CartCheckedOut.v4.enveloped.schema.json"); err != nil {
+		t.Fatalf("example does not match schema: %v", err)
+	}
+}
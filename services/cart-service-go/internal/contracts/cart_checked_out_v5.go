@@ -0,0 +1,75 @@
+package contracts
+
+import (
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+const (
+	CartCheckedOutV5EventVersion        = 5
+	CartCheckedOutV5EnvelopedSchemaPath = "contracts/events/cart/CartCheckedOut.v5.enveloped.schema.json"
+)
+
+type CartCheckedOutV5Envelope = Envelope[CartCheckedOutV5Payload]
+
+// CartCheckedOutV5Payload adds the shipping details chosen at checkout to
+// v4. Shipping is nil when the checkout carried none.
+type CartCheckedOutV5Payload struct {
+	CartID      string                       `json:"cartId"`
+	UserID      string                       `json:"userId"`
+	Items       []CartCheckedOutV2Item       `json:"items"`
+	Subtotal    money.Money                  `json:"subtotal"`
+	Adjustments []CartCheckedOutV3Adjustment `json:"adjustments"`
+	Tax         *CartCheckedOutV4Tax         `json:"tax,omitempty"`
+	Shipping    *CartCheckedOutV5Shipping    `json:"shipping,omitempty"`
+	TotalAmount money.Money                  `json:"totalAmount"`
+	Timestamp   time.Time                    `json:"timestamp"`
+}
+
+type CartCheckedOutV5Shipping struct {
+	Address CartCheckedOutV5Address `json:"address"`
+	Method  string                  `json:"method"`
+}
+
+type CartCheckedOutV5Address struct {
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city"`
+	State      string `json:"state,omitempty"`
+	PostalCode string `json:"postalCode"`
+	Country    string `json:"country"`
+}
+
+func BuildCartCheckedOutV5Event(c *cart.Cart, opts EnvelopeOptions) CartCheckedOutV5Envelope {
+	env := newEnvelope[CartCheckedOutV5Payload](CartCheckedOutEventName, CartCheckedOutV5EventVersion, CartCheckedOutV5EnvelopedSchemaPath, opts)
+
+	v4 := BuildCartCheckedOutV4Event(c, opts).Payload
+	env.Payload = CartCheckedOutV5Payload{
+		CartID:      v4.CartID,
+		UserID:      v4.UserID,
+		Items:       v4.Items,
+		Subtotal:    v4.Subtotal,
+		Adjustments: v4.Adjustments,
+		Tax:         v4.Tax,
+		TotalAmount: v4.TotalAmount,
+		Timestamp:   env.OccurredAt,
+	}
+
+	if s := c.Shipping; s != nil {
+		env.Payload.Shipping = &CartCheckedOutV5Shipping{
+			Address: CartCheckedOutV5Address{
+				Line1:      s.Address.Line1,
+				Line2:      s.Address.Line2,
+				City:       s.Address.City,
+				State:      s.Address.State,
+				PostalCode: s.Address.PostalCode,
+				Country:    s.Address.Country,
+			},
+			Method: s.Method,
+		}
+	}
+
+	return env
+}
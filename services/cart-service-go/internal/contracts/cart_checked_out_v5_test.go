@@ -0,0 +1,81 @@
+package contracts
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+func TestBuildCartCheckedOutV5Event(t *testing.T) {
+	makeEnvelope := func(s *cart.Shipping) CartCheckedOutV5Envelope {
+		c := &cart.Cart{
+			ID:     "e4a9ae13-b4a6-489a-a5fd-89bd88d8e1a5",
+			UserID: "f8f98928-87f6-4fce-8c55-7a7a62012f1a",
+			Items: []cart.Item{
+				{ProductID: "f4007d5d-0212-4bf0-996a-bfde9e0f0170", Quantity: 2, Price: money.New(1000, "DKK")},
+			},
+			Shipping: s,
+		}
+		if err := c.RecalculateTotal(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return BuildCartCheckedOutV5Event(c, EnvelopeOptions{
+			PartitionKey: c.ID,
+			Sequence:     5,
+			OccurredAt:   time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC),
+		})
+	}
+
+	env := makeEnvelope(&cart.Shipping{
+		Address: cart.Address{Line1: "Aaboulevarden 12", City: "Aarhus", PostalCode: "8000", Country: "DK"},
+		Method:  cart.ShippingExpress,
+	})
+	if env.EventVersion != CartCheckedOutV5EventVersion || env.Schema != CartCheckedOutV5EnvelopedSchemaPath {
+		t.Fatalf("unexpected version/schema %d %s", env.EventVersion, env.Schema)
+	}
+	if env.Payload.TotalAmount != money.New(2000, "DKK") || !env.Payload.Timestamp.Equal(env.OccurredAt) {
+		t.Fatalf("unexpected payload %+v", env.Payload)
+	}
+	if s := env.Payload.Shipping; s == nil || s.Method != cart.ShippingExpress || s.Address.City != "Aarhus" || s.Address.Country != "DK" {
+		t.Fatalf("unexpected shipping %+v", env.Payload.Shipping)
+	}
+	if err := validateAgainstSchema(env.PartitionKey, env.Sequence, env, "CartCheckedOut.v5.enveloped.schema.json"); err != nil {
+		t.Fatalf("expected envelope to be valid, got error: %v", err)
+	}
+
+	t.Run("without shipping", func(t *testing.T) {
+		unshipped := makeEnvelope(nil)
+		if unshipped.Payload.Shipping != nil {
+			t.Fatalf("expected no shipping, got %+v", unshipped.Payload.Shipping)
+		}
+		if err := validateAgainstSchema(unshipped.PartitionKey, unshipped.Sequence, unshipped, "CartCheckedOut.v5.enveloped.schema.json"); err != nil {
+			t.Fatalf("expected envelope to be valid, got error: %v", err)
+		}
+	})
+}
+
+func TestCartCheckedOutV5ExampleMatchesSchema(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+	repoRoot := filepath.Clean(filepath.Join(filepath.Dir(filename), "..", "..", "..", ".."))
+	raw, err := os.ReadFile(filepath.Join(repoRoot, "contracts", "examples", "cart", "CartCheckedOut.v5.json"))
+	if err != nil {
+		t.Fatalf("read example: %v", err)
+	}
+
+	var env CartCheckedOutV5Envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		t.Fatalf("decode example: %v", err)
+	}
+	if env.Payload.Shipping == nil {
+		t.Fatalf("expected the example to carry shipping details")
+	}
+	if err := validateAgainstSchema(env.PartitionKey, env.Sequence, env, "CartCheckedOut.v5.enveloped.schema.json"); err != nil {
+		t.Fatalf("example does not match schema: %v", err)
+	}
+}
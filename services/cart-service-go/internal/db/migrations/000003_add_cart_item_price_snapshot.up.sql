@@ -0,0 +1,3 @@
+-- Price snapshot per line: cart_items.price is the catalog price taken at priced_at
+ALTER TABLE cart_items
+    ADD COLUMN IF NOT EXISTS priced_at TIMESTAMPTZ NOT NULL DEFAULT now();
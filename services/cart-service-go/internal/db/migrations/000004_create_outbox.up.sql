@@ -0,0 +1,17 @@
+-- Transactional outbox: events are written here in the same transaction as the
+-- cart change that produced them and published afterwards by the relay.
+CREATE TABLE IF NOT EXISTS outbox (
+    id              BIGSERIAL PRIMARY KEY,
+    partition_key   TEXT NOT NULL,
+    sequence        BIGINT NOT NULL,
+    routing_key     TEXT NOT NULL,
+    payload         BYTEA NOT NULL,
+    attempts        INT NOT NULL DEFAULT 0,
+    last_error      TEXT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    published_at    TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS ix_outbox_pending ON outbox (partition_key, id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS ix_outbox_published_at ON outbox (published_at) WHERE published_at IS NOT NULL;
@@ -0,0 +1,11 @@
+-- Responses of idempotent requests, replayed when a client retries with the same key.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id         TEXT NOT NULL,
+    idempotency_key TEXT NOT NULL,
+    status_code     INT NOT NULL,
+    response_body   BYTEA NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS ix_idempotency_keys_created_at ON idempotency_keys (created_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS ux_cart_items_cart_product;
+ALTER TABLE carts DROP COLUMN IF EXISTS version;
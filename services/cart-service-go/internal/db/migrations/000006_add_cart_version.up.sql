@@ -0,0 +1,12 @@
+-- Optimistic concurrency: every cart change bumps version, exposed as the ETag.
+ALTER TABLE carts ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+-- One line per product so adds can upsert the line atomically. The handler has
+-- always merged lines, so this only drops rows a lost update left behind.
+DELETE FROM cart_items a
+USING cart_items b
+WHERE a.cart_id = b.cart_id
+  AND a.product_id = b.product_id
+  AND a.id < b.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS ux_cart_items_cart_product ON cart_items (cart_id, product_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS ix_carts_updated_at;
+ALTER TABLE carts DROP COLUMN IF EXISTS abandoned_at;
@@ -0,0 +1,5 @@
+-- Set by the abandoned cart sweeper. A cart is reported again only after it
+-- has been changed (updated_at > abandoned_at) and gone idle once more.
+ALTER TABLE carts ADD COLUMN IF NOT EXISTS abandoned_at TIMESTAMPTZ NULL;
+
+CREATE INDEX IF NOT EXISTS ix_carts_updated_at ON carts (updated_at);
@@ -0,0 +1,8 @@
+ALTER TABLE carts ADD COLUMN IF NOT EXISTS total NUMERIC(12,2) NOT NULL DEFAULT 0;
+UPDATE carts SET total = total_minor / 100.0;
+ALTER TABLE carts DROP COLUMN IF EXISTS total_minor, DROP COLUMN IF EXISTS currency;
+
+ALTER TABLE cart_items ADD COLUMN IF NOT EXISTS price NUMERIC(12,2) NOT NULL DEFAULT 0;
+UPDATE cart_items SET price = price_minor / 100.0;
+ALTER TABLE cart_items ALTER COLUMN price DROP DEFAULT;
+ALTER TABLE cart_items DROP COLUMN IF EXISTS price_minor, DROP COLUMN IF EXISTS currency;
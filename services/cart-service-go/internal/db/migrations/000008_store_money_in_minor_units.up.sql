@@ -0,0 +1,23 @@
+-- Amounts are stored as integer minor units (e.g. cents) with their ISO 4217
+-- currency. Existing rows were all priced by the catalog's default currency.
+ALTER TABLE cart_items
+    ADD COLUMN IF NOT EXISTS price_minor BIGINT,
+    ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'USD';
+
+UPDATE cart_items SET price_minor = ROUND(price * 100) WHERE price_minor IS NULL;
+
+ALTER TABLE cart_items
+    ALTER COLUMN price_minor SET NOT NULL,
+    ALTER COLUMN currency DROP DEFAULT,
+    DROP COLUMN IF EXISTS price;
+
+ALTER TABLE carts
+    ADD COLUMN IF NOT EXISTS total_minor BIGINT,
+    ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'USD';
+
+UPDATE carts SET total_minor = ROUND(total * 100) WHERE total_minor IS NULL;
+
+ALTER TABLE carts
+    ALTER COLUMN total_minor SET NOT NULL,
+    ALTER COLUMN total_minor SET DEFAULT 0,
+    DROP COLUMN IF EXISTS total;
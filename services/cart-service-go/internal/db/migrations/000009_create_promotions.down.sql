@@ -0,0 +1,4 @@
+ALTER TABLE carts RENAME COLUMN subtotal_minor TO total_minor;
+DROP TABLE IF EXISTS cart_coupons;
+DROP TABLE IF EXISTS promotion_redemptions;
+DROP TABLE IF EXISTS promotions;
@@ -0,0 +1,43 @@
+-- Promotions are looked up by coupon code. Amount columns use the minor unit of
+-- currency; percent_off is a whole percentage.
+CREATE TABLE IF NOT EXISTS promotions (
+    id                 UUID PRIMARY KEY,
+    code               TEXT NOT NULL UNIQUE,
+    description        TEXT NOT NULL DEFAULT '',
+    kind               TEXT NOT NULL CHECK (kind IN ('percentage', 'fixed_amount', 'buy_x_get_y')),
+    percent_off        INT NOT NULL DEFAULT 0 CHECK (percent_off BETWEEN 0 AND 100),
+    amount_off_minor   BIGINT NOT NULL DEFAULT 0,
+    product_id         TEXT NULL,
+    buy_quantity       INT NOT NULL DEFAULT 0,
+    get_quantity       INT NOT NULL DEFAULT 0,
+    min_spend_minor    BIGINT NOT NULL DEFAULT 0,
+    currency           TEXT NOT NULL DEFAULT 'USD',
+    starts_at          TIMESTAMPTZ NULL,
+    ends_at            TIMESTAMPTZ NULL,
+    max_uses_per_user  INT NOT NULL DEFAULT 0,
+    active             BOOLEAN NOT NULL DEFAULT true,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- One row per checkout that used a promotion; counted for per-user limits.
+CREATE TABLE IF NOT EXISTS promotion_redemptions (
+    promotion_id UUID NOT NULL REFERENCES promotions(id) ON DELETE CASCADE,
+    user_id      TEXT NOT NULL,
+    cart_id      UUID NOT NULL,
+    redeemed_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (promotion_id, cart_id)
+);
+
+CREATE INDEX IF NOT EXISTS ix_promotion_redemptions_user ON promotion_redemptions (promotion_id, user_id);
+
+-- Coupon codes the user entered on a cart. Discounts are worked out from the
+-- promotion whenever the cart is read, so they follow changes to the lines.
+CREATE TABLE IF NOT EXISTS cart_coupons (
+    cart_id    UUID NOT NULL REFERENCES carts(id) ON DELETE CASCADE,
+    code       TEXT NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (cart_id, code)
+);
+
+-- The stored amount is the sum of the lines; discounts are applied on top.
+ALTER TABLE carts RENAME COLUMN total_minor TO subtotal_minor;
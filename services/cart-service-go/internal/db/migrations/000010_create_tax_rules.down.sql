@@ -0,0 +1,5 @@
+ALTER TABLE carts
+    DROP COLUMN IF EXISTS tax_region,
+    DROP COLUMN IF EXISTS tax_country;
+DROP TABLE IF EXISTS product_tax_classes;
+DROP TABLE IF EXISTS tax_rates;
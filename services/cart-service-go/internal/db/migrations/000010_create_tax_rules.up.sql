@@ -0,0 +1,23 @@
+-- Tax rates per country, optionally narrowed to a region, and product tax
+-- class. A row with an empty region applies to the whole country. Rates are in
+-- basis points (2500 = 25%). prices_include_tax says whether catalog prices
+-- in that jurisdiction already contain the tax.
+CREATE TABLE IF NOT EXISTS tax_rates (
+    country            TEXT NOT NULL,
+    region             TEXT NOT NULL DEFAULT '',
+    tax_class          TEXT NOT NULL DEFAULT 'standard',
+    rate_bp            INT NOT NULL CHECK (rate_bp >= 0),
+    prices_include_tax BOOLEAN NOT NULL DEFAULT false,
+    PRIMARY KEY (country, region, tax_class)
+);
+
+-- Products without a row here are in the 'standard' class.
+CREATE TABLE IF NOT EXISTS product_tax_classes (
+    product_id TEXT PRIMARY KEY,
+    tax_class  TEXT NOT NULL
+);
+
+-- Where the cart is taxed; empty means the service's default location.
+ALTER TABLE carts
+    ADD COLUMN IF NOT EXISTS tax_country TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS tax_region  TEXT NOT NULL DEFAULT '';
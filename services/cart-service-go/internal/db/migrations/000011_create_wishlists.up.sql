@@ -0,0 +1,20 @@
+-- Named lists of products a user keeps outside the cart, including the
+-- "Saved for later" list cart lines are moved to. share_token, when set, lets
+-- anyone with the token read the list.
+CREATE TABLE IF NOT EXISTS wishlists (
+    id          UUID PRIMARY KEY,
+    user_id     TEXT NOT NULL,
+    name        TEXT NOT NULL,
+    share_token TEXT NULL UNIQUE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS wishlist_items (
+    wishlist_id UUID NOT NULL REFERENCES wishlists(id) ON DELETE CASCADE,
+    product_id  TEXT NOT NULL,
+    quantity    INT NOT NULL CHECK (quantity > 0),
+    added_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (wishlist_id, product_id)
+);
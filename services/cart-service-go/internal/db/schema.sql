@@ -1,17 +1,27 @@
 -- Cart Service schema (reference)
 CREATE TABLE IF NOT EXISTS carts (
-    id         UUID PRIMARY KEY,
-    user_id    TEXT NOT NULL UNIQUE,
-    total      NUMERIC(12,2) NOT NULL DEFAULT 0,
-    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+    id             UUID PRIMARY KEY,
+    user_id        TEXT NOT NULL UNIQUE,
+    subtotal_minor BIGINT NOT NULL DEFAULT 0,
+    currency       TEXT NOT NULL DEFAULT 'USD',
+    version        BIGINT NOT NULL DEFAULT 1,
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    abandoned_at   TIMESTAMPTZ NULL,
+    tax_country    TEXT NOT NULL DEFAULT '',
+    tax_region     TEXT NOT NULL DEFAULT ''
 );
 
+CREATE INDEX IF NOT EXISTS ix_carts_updated_at ON carts (updated_at);
+
 CREATE TABLE IF NOT EXISTS cart_items (
-    id         UUID PRIMARY KEY,
-    cart_id    UUID NOT NULL REFERENCES carts(id) ON DELETE CASCADE,
-    product_id TEXT NOT NULL,
-    quantity   INT NOT NULL,
-    price      NUMERIC(12,2) NOT NULL
+    id          UUID PRIMARY KEY,
+    cart_id     UUID NOT NULL REFERENCES carts(id) ON DELETE CASCADE,
+    product_id  TEXT NOT NULL,
+    quantity    INT NOT NULL,
+    price_minor BIGINT NOT NULL,
+    currency    TEXT NOT NULL,
+    priced_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (cart_id, product_id)
 );
 
 CREATE TABLE IF NOT EXISTS event_sequences (
@@ -19,3 +29,91 @@ CREATE TABLE IF NOT EXISTS event_sequences (
     last_sequence BIGINT NOT NULL,
     updated_at    TIMESTAMPTZ NOT NULL DEFAULT now()
 );
+
+CREATE TABLE IF NOT EXISTS outbox (
+    id              BIGSERIAL PRIMARY KEY,
+    partition_key   TEXT NOT NULL,
+    sequence        BIGINT NOT NULL,
+    routing_key     TEXT NOT NULL,
+    payload         BYTEA NOT NULL,
+    attempts        INT NOT NULL DEFAULT 0,
+    last_error      TEXT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    published_at    TIMESTAMPTZ NULL
+);
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id         TEXT NOT NULL,
+    idempotency_key TEXT NOT NULL,
+    status_code     INT NOT NULL,
+    response_body   BYTEA NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, idempotency_key)
+);
+
+CREATE TABLE IF NOT EXISTS promotions (
+    id                 UUID PRIMARY KEY,
+    code               TEXT NOT NULL UNIQUE,
+    description        TEXT NOT NULL DEFAULT '',
+    kind               TEXT NOT NULL CHECK (kind IN ('percentage', 'fixed_amount', 'buy_x_get_y')),
+    percent_off        INT NOT NULL DEFAULT 0 CHECK (percent_off BETWEEN 0 AND 100),
+    amount_off_minor   BIGINT NOT NULL DEFAULT 0,
+    product_id         TEXT NULL,
+    buy_quantity       INT NOT NULL DEFAULT 0,
+    get_quantity       INT NOT NULL DEFAULT 0,
+    min_spend_minor    BIGINT NOT NULL DEFAULT 0,
+    currency           TEXT NOT NULL DEFAULT 'USD',
+    starts_at          TIMESTAMPTZ NULL,
+    ends_at            TIMESTAMPTZ NULL,
+    max_uses_per_user  INT NOT NULL DEFAULT 0,
+    active             BOOLEAN NOT NULL DEFAULT true,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS promotion_redemptions (
+    promotion_id UUID NOT NULL REFERENCES promotions(id) ON DELETE CASCADE,
+    user_id      TEXT NOT NULL,
+    cart_id      UUID NOT NULL,
+    redeemed_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (promotion_id, cart_id)
+);
+
+CREATE TABLE IF NOT EXISTS cart_coupons (
+    cart_id    UUID NOT NULL REFERENCES carts(id) ON DELETE CASCADE,
+    code       TEXT NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (cart_id, code)
+);
+
+CREATE TABLE IF NOT EXISTS tax_rates (
+    country            TEXT NOT NULL,
+    region             TEXT NOT NULL DEFAULT '',
+    tax_class          TEXT NOT NULL DEFAULT 'standard',
+    rate_bp            INT NOT NULL CHECK (rate_bp >= 0),
+    prices_include_tax BOOLEAN NOT NULL DEFAULT false,
+    PRIMARY KEY (country, region, tax_class)
+);
+
+CREATE TABLE IF NOT EXISTS product_tax_classes (
+    product_id TEXT PRIMARY KEY,
+    tax_class  TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS wishlists (
+    id          UUID PRIMARY KEY,
+    user_id     TEXT NOT NULL,
+    name        TEXT NOT NULL,
+    share_token TEXT NULL UNIQUE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS wishlist_items (
+    wishlist_id UUID NOT NULL REFERENCES wishlists(id) ON DELETE CASCADE,
+    product_id  TEXT NOT NULL,
+    quantity    INT NOT NULL CHECK (quantity > 0),
+    added_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (wishlist_id, product_id)
+);
@@ -0,0 +1,99 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/contracts"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/outbox"
+)
+
+// CartActivityEvents builds the outbox events for a change from before to
+// after: CartItemAdded, CartItemQuantityChanged or CartItemRemoved for each
+// line that changed, or CartCleared when after is nil. A nil before reads as an
+// empty cart. Repricing a line on its own is not activity. The events have no
+// legacy shape and are always enveloped.
+func (p *RabbitCartEventsPublisher) CartActivityEvents(before, after *cart.Cart, metadata PublishMetadata) []outbox.Event {
+	return cartActivityEvents(before, after, metadata)
+}
+
+func cartActivityEvents(before, after *cart.Cart, metadata PublishMetadata) []outbox.Event {
+	if after == nil {
+		if before == nil {
+			return nil
+		}
+		return []outbox.Event{cartClearedEvent(before, metadata)}
+	}
+
+	var previous []cart.Item
+	if before != nil {
+		previous = before.Items
+	}
+
+	// Snapshot the cart so later mutations by the caller don't leak into the events.
+	snapshot := *after
+	snapshot.Items = append([]cart.Item(nil), after.Items...)
+
+	var evs []outbox.Event
+	for _, it := range snapshot.Items {
+		idx := findLine(previous, it.ProductID)
+		switch {
+		case idx < 0:
+			evs = append(evs, cartActivityEvent(&snapshot, CartItemAddedRoutingKey, metadata, func(opts contracts.EnvelopeOptions) any {
+				return contracts.BuildCartItemAddedEvent(&snapshot, it, opts)
+			}))
+		case previous[idx].Quantity != it.Quantity:
+			previousQuantity := previous[idx].Quantity
+			evs = append(evs, cartActivityEvent(&snapshot, CartItemQuantityChangedRoutingKey, metadata, func(opts contracts.EnvelopeOptions) any {
+				return contracts.BuildCartItemQuantityChangedEvent(&snapshot, previousQuantity, it, opts)
+			}))
+		}
+	}
+	for _, it := range previous {
+		if findLine(snapshot.Items, it.ProductID) < 0 {
+			evs = append(evs, cartActivityEvent(&snapshot, CartItemRemovedRoutingKey, metadata, func(opts contracts.EnvelopeOptions) any {
+				return contracts.BuildCartItemRemovedEvent(&snapshot, it, opts)
+			}))
+		}
+	}
+	return evs
+}
+
+func cartClearedEvent(c *cart.Cart, metadata PublishMetadata) outbox.Event {
+	snapshot := *c
+	snapshot.Items = append([]cart.Item(nil), c.Items...)
+
+	return cartActivityEvent(&snapshot, CartClearedRoutingKey, metadata, func(opts contracts.EnvelopeOptions) any {
+		return contracts.BuildCartClearedEvent(&snapshot, opts)
+	})
+}
+
+// cartActivityEvent wraps an envelope builder in an outbox event partitioned by
+// the cart ID, so a cart's activity keeps its order.
+func cartActivityEvent(c *cart.Cart, routingKey string, metadata PublishMetadata, build func(opts contracts.EnvelopeOptions) any) outbox.Event {
+	return outbox.Event{
+		PartitionKey: c.ID,
+		RoutingKey:   routingKey,
+		Encode: func(sequence int64) ([]byte, error) {
+			envelope := build(contracts.EnvelopeOptions{
+				PartitionKey:  c.ID,
+				Sequence:      sequence,
+				Producer:      contracts.CartServiceProducer,
+				CorrelationID: metadata.CorrelationID,
+				CausationID:   metadata.CausationID,
+			})
+
+			body, err := json.Marshal(envelope)
+			if err != nil {
+				return nil, fmt.Errorf("marshal enveloped event: %w", err)
+			}
+			return body, nil
+		},
+	}
+}
+
+func findLine(items []cart.Item, productID string) int {
+	return slices.IndexFunc(items, func(it cart.Item) bool { return it.ProductID == productID })
+}
@@ -0,0 +1,115 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+func TestCartActivityEventsDiffLines(t *testing.T) {
+	before := &cart.Cart{ID: "cart-1", UserID: "user-1", Items: []cart.Item{
+		{ProductID: "p1", Quantity: 1, Price: money.New(200, "USD")},
+		{ProductID: "p2", Quantity: 2, Price: money.New(300, "USD")},
+		{ProductID: "p3", Quantity: 1, Price: money.New(400, "USD")},
+	}}
+	after := &cart.Cart{ID: "cart-1", UserID: "user-1", Items: []cart.Item{
+		{ProductID: "p1", Quantity: 3, Price: money.New(200, "USD")},
+		{ProductID: "p3", Quantity: 1, Price: money.New(450, "USD")},
+		{ProductID: "p4", Quantity: 1, Price: money.New(500, "USD")},
+	}}
+
+	evs := cartActivityEvents(before, after, PublishMetadata{CorrelationID: "corr-1"})
+
+	want := []string{CartItemQuantityChangedRoutingKey, CartItemAddedRoutingKey, CartItemRemovedRoutingKey}
+	if len(evs) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), evs)
+	}
+	for i, ev := range evs {
+		if ev.RoutingKey != want[i] || ev.PartitionKey != "cart-1" {
+			t.Fatalf("event %d: expected %s on cart-1, got %s on %s", i, want[i], ev.RoutingKey, ev.PartitionKey)
+		}
+	}
+
+	// Mutating the cart after building the events must not change the payload.
+	after.Items[0].Quantity = 9
+
+	body, err := evs[0].Encode(5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var changed struct {
+		EventName     string `json:"eventName"`
+		Sequence      int64  `json:"sequence"`
+		CorrelationID string `json:"correlationId"`
+		Payload       struct {
+			ProductID        string `json:"productId"`
+			PreviousQuantity int    `json:"previousQuantity"`
+			Quantity         int    `json:"quantity"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &changed); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if changed.EventName != "CartItemQuantityChanged" || changed.Sequence != 5 || changed.CorrelationID != "corr-1" {
+		t.Fatalf("unexpected envelope %+v", changed)
+	}
+	if changed.Payload.ProductID != "p1" || changed.Payload.PreviousQuantity != 1 || changed.Payload.Quantity != 3 {
+		t.Fatalf("unexpected payload %+v", changed.Payload)
+	}
+
+	body, err = evs[2].Encode(7)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var removed struct {
+		Payload struct {
+			ProductID string `json:"productId"`
+			Quantity  int    `json:"quantity"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &removed); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if removed.Payload.ProductID != "p2" || removed.Payload.Quantity != 2 {
+		t.Fatalf("unexpected removed payload %+v", removed.Payload)
+	}
+}
+
+func TestCartActivityEventsNewAndClearedCarts(t *testing.T) {
+	c := &cart.Cart{ID: "cart-1", UserID: "user-1", Items: []cart.Item{{ProductID: "p1", Quantity: 2, Price: money.New(200, "USD")}}}
+
+	if evs := cartActivityEvents(nil, c, PublishMetadata{}); len(evs) != 1 || evs[0].RoutingKey != CartItemAddedRoutingKey {
+		t.Fatalf("expected a new cart to add its lines, got %+v", evs)
+	}
+	if evs := cartActivityEvents(c, c, PublishMetadata{}); len(evs) != 0 {
+		t.Fatalf("expected no events for an unchanged cart, got %+v", evs)
+	}
+	if evs := cartActivityEvents(nil, nil, PublishMetadata{}); len(evs) != 0 {
+		t.Fatalf("expected no events without a cart, got %+v", evs)
+	}
+
+	evs := cartActivityEvents(c, nil, PublishMetadata{})
+	if len(evs) != 1 || evs[0].RoutingKey != CartClearedRoutingKey {
+		t.Fatalf("expected a cleared event, got %+v", evs)
+	}
+	body, err := evs[0].Encode(2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded struct {
+		EventName string `json:"eventName"`
+		Payload   struct {
+			Items []struct {
+				ProductID string `json:"productId"`
+			} `json:"items"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if decoded.EventName != "CartCleared" || len(decoded.Payload.Items) != 1 || decoded.Payload.Items[0].ProductID != "p1" {
+		t.Fatalf("unexpected cleared envelope %s", body)
+	}
+}
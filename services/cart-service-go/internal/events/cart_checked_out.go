@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/outbox"
 )
 
 type LegacyCartCheckedOut struct {
@@ -28,5 +29,7 @@ type PublishMetadata struct {
 }
 
 type CartEventsPublisher interface {
-	PublishCartCheckedOut(ctx context.Context, c *cart.Cart, metadata PublishMetadata) error
+	CartCheckedOutEvents(c *cart.Cart, metadata PublishMetadata) []outbox.Event
+	CartActivityEvents(before, after *cart.Cart, metadata PublishMetadata) []outbox.Event
+	Publish(ctx context.Context, routingKey string, body []byte) error
 }
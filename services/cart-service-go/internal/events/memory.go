@@ -0,0 +1,76 @@
+package events
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/outbox"
+)
+
+// maxPublished bounds how many published messages an
+// InMemoryCartEventsPublisher keeps, so a long local run doesn't grow without
+// limit.
+const maxPublished = 1000
+
+// PublishedMessage is a message handed to an InMemoryCartEventsPublisher.
+type PublishedMessage struct {
+	RoutingKey string
+	Body       []byte
+}
+
+// InMemoryCartEventsPublisher builds the same events as the RabbitMQ
+// publisher but keeps the last messages the outbox relay publishes, and logs
+// them when it has a logger. It lets the service run without a broker.
+type InMemoryCartEventsPublisher struct {
+	publishEnveloped bool
+	logger           *log.Logger
+
+	mu        sync.Mutex
+	published []PublishedMessage
+}
+
+func NewInMemoryCartEventsPublisher(logger *log.Logger) *InMemoryCartEventsPublisher {
+	return &InMemoryCartEventsPublisher{publishEnveloped: publishEnvelopedFromEnv(), logger: logger}
+}
+
+// CartCheckedOutEvents builds the same events as
+// RabbitCartEventsPublisher.CartCheckedOutEvents.
+func (p *InMemoryCartEventsPublisher) CartCheckedOutEvents(c *cart.Cart, metadata PublishMetadata) []outbox.Event {
+	return cartCheckedOutEvents(c, metadata, p.publishEnveloped)
+}
+
+func (p *InMemoryCartEventsPublisher) CartAbandonedEvent(c *cart.Cart) outbox.Event {
+	return cartAbandonedEvent(c)
+}
+
+func (p *InMemoryCartEventsPublisher) CartActivityEvents(before, after *cart.Cart, metadata PublishMetadata) []outbox.Event {
+	return cartActivityEvents(before, after, metadata)
+}
+
+// Publish records the message. It is used by the outbox relay.
+func (p *InMemoryCartEventsPublisher) Publish(ctx context.Context, routingKey string, body []byte) error {
+	p.mu.Lock()
+	p.published = append(p.published, PublishedMessage{RoutingKey: routingKey, Body: append([]byte(nil), body...)})
+	if len(p.published) > maxPublished {
+		p.published = p.published[len(p.published)-maxPublished:]
+	}
+	p.mu.Unlock()
+
+	if p.logger != nil {
+		p.logger.Printf("published %s: %s", routingKey, body)
+	}
+	return nil
+}
+
+// Published returns the messages published so far, oldest first.
+func (p *InMemoryCartEventsPublisher) Published() []PublishedMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]PublishedMessage(nil), p.published...)
+}
+
+func (p *InMemoryCartEventsPublisher) Close() error {
+	return nil
+}
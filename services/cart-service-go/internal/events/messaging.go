@@ -5,9 +5,19 @@ import (
 )
 
 const (
-	EventsExchange           = "ecommerce.events"
-	CartCheckedOutRoutingKey = "cart.checkedout.v1"
-	cartServiceName          = "cart-service-go"
+	EventsExchange             = "ecommerce.events"
+	CartCheckedOutRoutingKey   = "cart.checkedout.v1"
+	CartCheckedOutV2RoutingKey = "cart.checkedout.v2"
+	CartCheckedOutV3RoutingKey = "cart.checkedout.v3"
+	CartCheckedOutV4RoutingKey = "cart.checkedout.v4"
+	CartCheckedOutV5RoutingKey = "cart.checkedout.v5"
+	CartAbandonedRoutingKey    = "cart.abandoned.v1"
+	cartServiceName            = "cart-service-go"
+
+	CartItemAddedRoutingKey           = "cart.itemadded.v1"
+	CartItemRemovedRoutingKey         = "cart.itemremoved.v1"
+	CartItemQuantityChangedRoutingKey = "cart.itemquantitychanged.v1"
+	CartClearedRoutingKey             = "cart.cleared.v1"
 )
 
 func serviceQueue(serviceName, routingKey string) string {
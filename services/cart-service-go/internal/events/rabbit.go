@@ -7,50 +7,77 @@ import (
 	"log"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
 	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/contracts"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/outbox"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/tax"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-type SequenceRepository interface {
-	NextSequence(ctx context.Context, partitionKey string) (int64, error)
-}
+// publishConfirmTimeout bounds how long Publish waits for the broker to
+// confirm a message.
+const publishConfirmTimeout = 5 * time.Second
 
 type RabbitCartEventsPublisher struct {
-	ch                 *amqp.Channel
-	sequenceRepository SequenceRepository
-	publishEnveloped   bool
+	conn             *amqp.Connection
+	mu               sync.Mutex
+	ch               *amqp.Channel
+	publishEnveloped bool
 }
 
 func (p *RabbitCartEventsPublisher) Close() error {
-	if p == nil || p.ch == nil {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ch == nil {
 		return nil
 	}
 	return p.ch.Close()
 }
 
-func NewRabbitCartEventsPublisher(conn *amqp.Connection, sequenceRepo SequenceRepository) (*RabbitCartEventsPublisher, error) {
-	ch, err := conn.Channel()
+func NewRabbitCartEventsPublisher(conn *amqp.Connection) (*RabbitCartEventsPublisher, error) {
+	p := &RabbitCartEventsPublisher{
+		conn:             conn,
+		publishEnveloped: publishEnvelopedFromEnv(),
+	}
+	if err := p.open(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// open opens the publishing channel in confirm mode. Publish reopens it when
+// the broker has closed it.
+func (p *RabbitCartEventsPublisher) open() error {
+	ch, err := p.conn.Channel()
 	if err != nil {
-		return nil, fmt.Errorf("failed to open a channel: %v", err)
+		return fmt.Errorf("failed to open a channel: %v", err)
 	}
 
 	if err := declareEventsExchange(ch); err != nil {
-		return nil, fmt.Errorf("failed to declare exchange: %v", err)
+		_ = ch.Close()
+		return fmt.Errorf("failed to declare exchange: %v", err)
 	}
 
-	publishEnveloped := true
-	if v := os.Getenv("PUBLISH_ENVELOPED_EVENTS"); strings.EqualFold(v, "false") {
-		publishEnveloped = false
+	if err := ch.Confirm(false); err != nil {
+		_ = ch.Close()
+		return fmt.Errorf("failed to enable publisher confirms: %v", err)
 	}
 
-	return &RabbitCartEventsPublisher{
-		ch:                 ch,
-		sequenceRepository: sequenceRepo,
-		publishEnveloped:   publishEnveloped,
-	}, nil
+	p.ch = ch
+	return nil
+}
+
+func publishEnvelopedFromEnv() bool {
+	if v := os.Getenv("PUBLISH_ENVELOPED_EVENTS"); strings.EqualFold(v, "false") {
+		return false
+	}
+	return true
 }
 
 func MustDialRabbit() *amqp.Connection {
@@ -66,19 +93,90 @@ func MustDialRabbit() *amqp.Connection {
 	return conn
 }
 
-func (p *RabbitCartEventsPublisher) PublishCartCheckedOut(ctx context.Context, c *cart.Cart, metadata PublishMetadata) error {
-	if p.publishEnveloped {
-		return p.publishEnvelopedEvent(ctx, c, metadata)
+// CartCheckedOutEvents builds the outbox events for a checkout: v1 for existing
+// consumers and, unless only legacy payloads are published, v2 with exact
+// Money amounts, v3 with the coupon adjustments, v4 with the tax breakdown
+// and v5 with the shipping details. v1, v2, v4 and v5 carry the total the
+// customer pays; v3 keeps its total before tax as its schema defines it.
+// Bodies are encoded once the outbox has allocated the partition sequence.
+func (p *RabbitCartEventsPublisher) CartCheckedOutEvents(c *cart.Cart, metadata PublishMetadata) []outbox.Event {
+	return cartCheckedOutEvents(c, metadata, p.publishEnveloped)
+}
+
+func cartCheckedOutEvents(c *cart.Cart, metadata PublishMetadata, enveloped bool) []outbox.Event {
+	evs := []outbox.Event{cartCheckedOutEvent(c, metadata, enveloped)}
+	if enveloped {
+		evs = append(evs, cartCheckedOutV2Event(c, metadata), cartCheckedOutV3Event(c, metadata), cartCheckedOutV4Event(c, metadata), cartCheckedOutV5Event(c, metadata))
 	}
-	return p.publishLegacyEvent(ctx, c)
+	return evs
+}
+
+// CartAbandonedEvent builds the outbox event for a cart found idle by the
+// abandoned cart sweeper. It has no legacy shape and is always enveloped.
+func (p *RabbitCartEventsPublisher) CartAbandonedEvent(c *cart.Cart) outbox.Event {
+	return cartAbandonedEvent(c)
 }
 
-func (p *RabbitCartEventsPublisher) publishLegacyEvent(ctx context.Context, c *cart.Cart) error {
+// Publish sends an already encoded event to the events exchange and waits
+// for the broker to confirm it, so the outbox relay only marks a row
+// published once the broker has taken it. A nack is an error.
+func (p *RabbitCartEventsPublisher) Publish(ctx context.Context, routingKey string, body []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ch == nil || p.ch.IsClosed() {
+		if err := p.open(); err != nil {
+			return fmt.Errorf("publish %s: %w", routingKey, err)
+		}
+	}
+
+	pub := amqp.Publishing{
+		ContentType:  "application/json",
+		Body:         body,
+		DeliveryMode: amqp.Persistent,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, publishConfirmTimeout)
+	defer cancel()
+
+	confirm, err := p.ch.PublishWithDeferredConfirmWithContext(ctx, EventsExchange, routingKey, false, false, pub)
+	if err != nil {
+		return fmt.Errorf("publish %s: %w", routingKey, err)
+	}
+
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("publish %s: wait for confirm: %w", routingKey, err)
+	}
+	if !acked {
+		return fmt.Errorf("publish %s: broker nacked the message", routingKey)
+	}
+
+	return nil
+}
+
+func cartCheckedOutEvent(c *cart.Cart, metadata PublishMetadata, enveloped bool) outbox.Event {
+	partitionKey := cartPartitionKey(c)
+	snapshot := snapshotCart(c)
+
+	return outbox.Event{
+		PartitionKey: partitionKey,
+		RoutingKey:   CartCheckedOutRoutingKey,
+		Encode: func(sequence int64) ([]byte, error) {
+			if !enveloped {
+				return encodeLegacyCartCheckedOut(snapshot)
+			}
+			return encodeEnveloped(contracts.BuildCartCheckedOutEvent, snapshot, partitionKey, contracts.CartCheckedOutEnvelopedSchemaPath, sequence, metadata)
+		},
+	}
+}
+
+func encodeLegacyCartCheckedOut(c *cart.Cart) ([]byte, error) {
 	ev := LegacyCartCheckedOut{
 		EventType:   "CartCheckedOut",
 		CartID:      c.ID,
 		UserID:      c.UserID,
-		TotalAmount: c.Total,
+		TotalAmount: c.Total.Float64(),
 		Timestamp:   time.Now().UTC(),
 	}
 
@@ -86,66 +184,116 @@ func (p *RabbitCartEventsPublisher) publishLegacyEvent(ctx context.Context, c *c
 		ev.Items = append(ev.Items, CartItemEvent{
 			ProductID: it.ProductID,
 			Quantity:  it.Quantity,
-			Price:     it.Price,
+			Price:     it.Price.Float64(),
 		})
 	}
 
 	body, err := json.Marshal(ev)
 	if err != nil {
-		return fmt.Errorf("marshal legacy event: %w", err)
+		return nil, fmt.Errorf("marshal legacy event: %w", err)
 	}
+	return body, nil
+}
 
-	pub := amqp.Publishing{
-		ContentType:  "application/json",
-		Body:         body,
-		DeliveryMode: amqp.Persistent,
-	}
+func cartCheckedOutV2Event(c *cart.Cart, metadata PublishMetadata) outbox.Event {
+	return envelopedCartCheckedOutEvent(c, metadata, CartCheckedOutV2RoutingKey, contracts.CartCheckedOutV2EnvelopedSchemaPath, contracts.BuildCartCheckedOutV2Event)
+}
 
-	if err := p.ch.PublishWithContext(ctx, EventsExchange, CartCheckedOutRoutingKey, false, false, pub); err != nil {
-		return fmt.Errorf("publish legacy: %w", err)
-	}
+func cartCheckedOutV3Event(c *cart.Cart, metadata PublishMetadata) outbox.Event {
+	return envelopedCartCheckedOutEvent(c, metadata, CartCheckedOutV3RoutingKey, contracts.CartCheckedOutV3EnvelopedSchemaPath, contracts.BuildCartCheckedOutV3Event)
+}
 
-	return nil
+func cartCheckedOutV4Event(c *cart.Cart, metadata PublishMetadata) outbox.Event {
+	return envelopedCartCheckedOutEvent(c, metadata, CartCheckedOutV4RoutingKey, contracts.CartCheckedOutV4EnvelopedSchemaPath, contracts.BuildCartCheckedOutV4Event)
 }
 
-func (p *RabbitCartEventsPublisher) publishEnvelopedEvent(ctx context.Context, c *cart.Cart, metadata PublishMetadata) error {
-	if p.sequenceRepository == nil {
-		return fmt.Errorf("sequence repository not configured")
-	}
+func cartCheckedOutV5Event(c *cart.Cart, metadata PublishMetadata) outbox.Event {
+	return envelopedCartCheckedOutEvent(c, metadata, CartCheckedOutV5RoutingKey, contracts.CartCheckedOutV5EnvelopedSchemaPath, contracts.BuildCartCheckedOutV5Event)
+}
 
-	partitionKey := c.ID
-	if partitionKey == "" {
-		partitionKey = c.UserID
-	}
+// envelopedCartCheckedOutEvent builds the outbox event of one enveloped
+// CartCheckedOut version, which build renders from a snapshot of the cart.
+func envelopedCartCheckedOutEvent[E any](c *cart.Cart, metadata PublishMetadata, routingKey, schemaPath string, build func(*cart.Cart, contracts.EnvelopeOptions) E) outbox.Event {
+	partitionKey := cartPartitionKey(c)
+	snapshot := snapshotCart(c)
 
-	sequence, err := p.sequenceRepository.NextSequence(ctx, partitionKey)
-	if err != nil {
-		return fmt.Errorf("next sequence: %w", err)
+	return outbox.Event{
+		PartitionKey: partitionKey,
+		RoutingKey:   routingKey,
+		Encode: func(sequence int64) ([]byte, error) {
+			return encodeEnveloped(build, snapshot, partitionKey, schemaPath, sequence, metadata)
+		},
 	}
+}
 
-	envelope := contracts.BuildCartCheckedOutEvent(c, contracts.EnvelopeOptions{
+func encodeEnveloped[E any](build func(*cart.Cart, contracts.EnvelopeOptions) E, c *cart.Cart, partitionKey, schemaPath string, sequence int64, metadata PublishMetadata) ([]byte, error) {
+	envelope := build(c, contracts.EnvelopeOptions{
 		PartitionKey:  partitionKey,
 		Sequence:      sequence,
 		Producer:      contracts.CartServiceProducer,
-		SchemaPath:    contracts.CartCheckedOutEnvelopedSchemaPath,
+		SchemaPath:    schemaPath,
 		CorrelationID: metadata.CorrelationID,
 		CausationID:   metadata.CausationID,
 	})
 
 	body, err := json.Marshal(envelope)
 	if err != nil {
-		return fmt.Errorf("marshal enveloped event: %w", err)
+		return nil, fmt.Errorf("marshal enveloped event: %w", err)
 	}
+	return body, nil
+}
 
-	pub := amqp.Publishing{
-		ContentType:  "application/json",
-		Body:         body,
-		DeliveryMode: amqp.Persistent,
+// cartPartitionKey is the partition of a cart's checkout events.
+func cartPartitionKey(c *cart.Cart) string {
+	if c.ID == "" {
+		return c.UserID
 	}
+	return c.ID
+}
 
-	if err := p.ch.PublishWithContext(ctx, EventsExchange, CartCheckedOutRoutingKey, false, false, pub); err != nil {
-		return fmt.Errorf("publish enveloped: %w", err)
+// snapshotCart copies the cart so later mutations by the caller don't leak
+// into the events.
+func snapshotCart(c *cart.Cart) *cart.Cart {
+	snapshot := *c
+	snapshot.Items = append([]cart.Item(nil), c.Items...)
+	snapshot.Adjustments = append([]cart.Adjustment(nil), c.Adjustments...)
+	snapshot.Tax = snapshotTax(c.Tax)
+	if c.Shipping != nil {
+		shipping := *c.Shipping
+		snapshot.Shipping = &shipping
 	}
+	return &snapshot
+}
 
-	return nil
+func snapshotTax(b *tax.Breakdown) *tax.Breakdown {
+	if b == nil {
+		return nil
+	}
+	snapshot := *b
+	snapshot.Lines = append([]tax.LineTax(nil), b.Lines...)
+	return &snapshot
+}
+
+func cartAbandonedEvent(c *cart.Cart) outbox.Event {
+	snapshot := *c
+	snapshot.Items = append([]cart.Item(nil), c.Items...)
+
+	return outbox.Event{
+		PartitionKey: c.ID,
+		RoutingKey:   CartAbandonedRoutingKey,
+		Encode: func(sequence int64) ([]byte, error) {
+			envelope := contracts.BuildCartAbandonedEvent(&snapshot, contracts.EnvelopeOptions{
+				PartitionKey: snapshot.ID,
+				Sequence:     sequence,
+				Producer:     contracts.CartServiceProducer,
+				SchemaPath:   contracts.CartAbandonedEnvelopedSchemaPath,
+			})
+
+			body, err := json.Marshal(envelope)
+			if err != nil {
+				return nil, fmt.Errorf("marshal enveloped event: %w", err)
+			}
+			return body, nil
+		},
+	}
 }
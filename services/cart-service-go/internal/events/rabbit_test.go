@@ -0,0 +1,175 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+func TestCartCheckedOutEventEncodesAllocatedSequence(t *testing.T) {
+	c := &cart.Cart{ID: "cart-1", UserID: "user-1", Items: []cart.Item{{ProductID: "p1", Quantity: 1, Price: money.New(200, "USD")}}, Total: money.New(200, "USD")}
+	ev := cartCheckedOutEvent(c, PublishMetadata{CorrelationID: "corr-1"}, true)
+
+	// Mutating the cart after building the event must not change the payload.
+	c.Items[0].Quantity = 5
+
+	body, err := ev.Encode(3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded struct {
+		PartitionKey  string `json:"partitionKey"`
+		Sequence      int64  `json:"sequence"`
+		CorrelationID string `json:"correlationId"`
+		Payload       struct {
+			Items []struct {
+				Quantity int `json:"quantity"`
+			} `json:"items"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if ev.PartitionKey != "cart-1" || decoded.PartitionKey != "cart-1" {
+		t.Fatalf("expected partition key cart-1, got %s/%s", ev.PartitionKey, decoded.PartitionKey)
+	}
+	if decoded.Sequence != 3 {
+		t.Fatalf("expected sequence 3, got %d", decoded.Sequence)
+	}
+	if decoded.CorrelationID != "corr-1" {
+		t.Fatalf("expected correlation id corr-1, got %s", decoded.CorrelationID)
+	}
+	if len(decoded.Payload.Items) != 1 || decoded.Payload.Items[0].Quantity != 1 {
+		t.Fatalf("expected snapshot of cart items, got %+v", decoded.Payload.Items)
+	}
+}
+
+func TestCartCheckedOutEventFallsBackToUserID(t *testing.T) {
+	ev := cartCheckedOutEvent(&cart.Cart{UserID: "user-1"}, PublishMetadata{}, false)
+	if ev.PartitionKey != "user-1" {
+		t.Fatalf("expected user id partition key, got %s", ev.PartitionKey)
+	}
+	if _, err := ev.Encode(1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCartAbandonedEventIsEnveloped(t *testing.T) {
+	c := &cart.Cart{ID: "cart-1", UserID: "user-1", Items: []cart.Item{{ProductID: "p1", Quantity: 2, Price: money.New(200, "USD")}}, Total: money.New(400, "USD")}
+	ev := cartAbandonedEvent(c)
+	if ev.PartitionKey != "cart-1" || ev.RoutingKey != CartAbandonedRoutingKey {
+		t.Fatalf("unexpected event routing %s/%s", ev.PartitionKey, ev.RoutingKey)
+	}
+
+	body, err := ev.Encode(7)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded struct {
+		EventName string `json:"eventName"`
+		Sequence  int64  `json:"sequence"`
+		Payload   struct {
+			CartID string `json:"cartId"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if decoded.EventName != "CartAbandoned" || decoded.Sequence != 7 || decoded.Payload.CartID != "cart-1" {
+		t.Fatalf("unexpected envelope %+v", decoded)
+	}
+}
+
+func TestCartCheckedOutEventsAddsNewVersionsWhenEnveloped(t *testing.T) {
+	c := &cart.Cart{ID: "cart-1", UserID: "user-1", Items: []cart.Item{{ProductID: "p1", Quantity: 3, Price: money.New(1999, "EUR")}}, Total: money.New(5997, "EUR")}
+
+	legacy := (&RabbitCartEventsPublisher{publishEnveloped: false}).CartCheckedOutEvents(c, PublishMetadata{})
+	if len(legacy) != 1 || legacy[0].RoutingKey != CartCheckedOutRoutingKey {
+		t.Fatalf("expected only the v1 event in legacy mode, got %+v", legacy)
+	}
+
+	evs := (&RabbitCartEventsPublisher{publishEnveloped: true}).CartCheckedOutEvents(c, PublishMetadata{})
+	if len(evs) != 5 || evs[0].RoutingKey != CartCheckedOutRoutingKey || evs[1].RoutingKey != CartCheckedOutV2RoutingKey || evs[2].RoutingKey != CartCheckedOutV3RoutingKey || evs[3].RoutingKey != CartCheckedOutV4RoutingKey || evs[4].RoutingKey != CartCheckedOutV5RoutingKey {
+		t.Fatalf("expected v1 to v5 events, got %+v", evs)
+	}
+
+	body, err := evs[1].Encode(4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded struct {
+		EventVersion int `json:"eventVersion"`
+		Payload      struct {
+			TotalAmount money.Money `json:"totalAmount"`
+			Items       []struct {
+				Price money.Money `json:"price"`
+			} `json:"items"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if decoded.EventVersion != 2 || decoded.Payload.TotalAmount != money.New(5997, "EUR") {
+		t.Fatalf("unexpected v2 envelope %+v", decoded)
+	}
+	if len(decoded.Payload.Items) != 1 || decoded.Payload.Items[0].Price != money.New(1999, "EUR") {
+		t.Fatalf("unexpected v2 items %+v", decoded.Payload.Items)
+	}
+
+	body, err = evs[2].Encode(5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var v3 struct {
+		EventVersion int `json:"eventVersion"`
+		Payload      struct {
+			Adjustments []json.RawMessage `json:"adjustments"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &v3); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if v3.EventVersion != 3 || v3.Payload.Adjustments == nil {
+		t.Fatalf("unexpected v3 envelope %s", body)
+	}
+
+	body, err = evs[3].Encode(6)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var v4 struct {
+		EventVersion int `json:"eventVersion"`
+	}
+	if err := json.Unmarshal(body, &v4); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if v4.EventVersion != 4 {
+		t.Fatalf("unexpected v4 envelope %s", body)
+	}
+
+	// The shipping details are copied, so a change after checkout doesn't
+	// reach the event.
+	c.Shipping = &cart.Shipping{Address: cart.Address{Line1: "1 Main St", City: "Springfield", PostalCode: "62701", Country: "US"}, Method: cart.ShippingStandard}
+	ev := (&RabbitCartEventsPublisher{publishEnveloped: true}).CartCheckedOutEvents(c, PublishMetadata{})[4]
+	c.Shipping.Method = cart.ShippingExpress
+	body, err = ev.Encode(7)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var v5 struct {
+		EventVersion int `json:"eventVersion"`
+		Payload      struct {
+			Shipping *cart.Shipping `json:"shipping"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &v5); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if v5.EventVersion != 5 || v5.Payload.Shipping == nil || v5.Payload.Shipping.Method != cart.ShippingStandard {
+		t.Fatalf("unexpected v5 envelope %s", body)
+	}
+}
@@ -0,0 +1,35 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/events"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/outbox"
+	"github.com/google/uuid"
+)
+
+// activity returns what records the request's cart changes as activity events,
+// or nil when the activity stream is turned off.
+func (h *CartHandler) activity(r *http.Request) cart.ActivityFunc {
+	if !h.activityEvents || h.eventPublisher == nil {
+		return nil
+	}
+	metadata := publishMetadata(r)
+	return func(before, after *cart.Cart) []outbox.Event {
+		return h.eventPublisher.CartActivityEvents(before, after, metadata)
+	}
+}
+
+// publishMetadata ties the events of a request to the flow it belongs to,
+// starting a new one when the caller sent no correlation ID.
+func publishMetadata(r *http.Request) events.PublishMetadata {
+	metadata := events.PublishMetadata{
+		CorrelationID: r.Header.Get("X-Correlation-Id"),
+		CausationID:   r.Header.Get("X-Causation-Id"),
+	}
+	if metadata.CorrelationID == "" {
+		metadata.CorrelationID = uuid.NewString()
+	}
+	return metadata
+}
@@ -0,0 +1,90 @@
+package http_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cartpkg "github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/events"
+	httphandler "github.com/andreasstove999/ecommerce-system/cart-service-go/internal/http"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/outbox"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/pricing"
+)
+
+func TestActivityEventsSwitch(t *testing.T) {
+	newRepo := func() *RepositoryMock {
+		return &RepositoryMock{
+			GetCartFunc: cartWith(cartpkg.Item{ProductID: "p1", Quantity: 1, Price: usd(1000)}),
+			UpsertCartFunc: func(ctx context.Context, c *cartpkg.Cart, activity cartpkg.ActivityFunc) error {
+				return nil
+			},
+			ClearCartFunc: func(ctx context.Context, userID string, expectedVersion int64, activity cartpkg.ActivityFunc) error {
+				return nil
+			},
+		}
+	}
+	publisher := &RabbitCartEventsPublisherMock{
+		CartActivityEventsFunc: func(before, after *cartpkg.Cart, metadata events.PublishMetadata) []outbox.Event {
+			return []outbox.Event{{PartitionKey: "c1", RoutingKey: events.CartItemQuantityChangedRoutingKey}}
+		},
+	}
+	router := func(repo *RepositoryMock, enabled bool) http.Handler {
+		return httphandler.NewRouter(repo, publisher, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil, httphandler.Config{ActivityEvents: enabled})
+	}
+
+	t.Run("off by default", func(t *testing.T) {
+		repo := newRepo()
+		w := httptest.NewRecorder()
+
+		router(repo, false).ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/api/cart/u1/items/p1", strings.NewReader(`{"quantity":2}`)))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if repo.UpsertCartCalls()[0].Activity != nil {
+			t.Fatalf("expected no activity to be recorded")
+		}
+	})
+
+	t.Run("records changes with the request's correlation id", func(t *testing.T) {
+		repo := newRepo()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPatch, "/api/cart/u1/items/p1", strings.NewReader(`{"quantity":2}`))
+		r.Header.Set("X-Correlation-Id", "corr-1")
+
+		router(repo, true).ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		call := repo.UpsertCartCalls()[0]
+		if call.Activity == nil {
+			t.Fatalf("expected activity to be recorded")
+		}
+		before := &cartpkg.Cart{ID: "c1", UserID: "u1", Items: []cartpkg.Item{{ProductID: "p1", Quantity: 1}}}
+		if evs := call.Activity(before, call.C); len(evs) != 1 {
+			t.Fatalf("expected the publisher's events, got %+v", evs)
+		}
+		got := publisher.CartActivityEventsCalls()
+		if len(got) != 1 || got[0].Before != before || got[0].After != call.C || got[0].Metadata.CorrelationID != "corr-1" {
+			t.Fatalf("unexpected publisher calls %+v", got)
+		}
+	})
+
+	t.Run("clearing the cart", func(t *testing.T) {
+		repo := newRepo()
+		w := httptest.NewRecorder()
+
+		router(repo, true).ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/cart/u1", nil))
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected 204, got %d", w.Code)
+		}
+		if repo.ClearCartCalls()[0].Activity == nil {
+			t.Fatalf("expected activity to be recorded")
+		}
+	})
+}
@@ -0,0 +1,334 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/inventory"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/pricing"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/problem"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/rules"
+)
+
+// maxBatchLines caps the lines a replace or batch add request may carry.
+const maxBatchLines = 100
+
+// Codes reported for rejected lines of a replace or batch add request. Lines
+// the catalog rejects use the cart validation codes.
+const (
+	lineMissingProductID = "missing_product_id"
+	lineDuplicateProduct = "duplicate_product"
+	lineRulesViolated    = "rules_violated"
+	lineCurrencyMismatch = "currency_mismatch"
+)
+
+type batchLine struct {
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
+
+// lineError is what is wrong with one line of a replace or batch add request.
+// Index is the line's position in the request.
+type lineError struct {
+	Index     int    `json:"index"`
+	ProductID string `json:"productId"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	// Violations is set for rules_violated.
+	Violations []rules.Violation `json:"violations,omitempty"`
+	// AvailableQuantity is set for out_of_stock.
+	AvailableQuantity *int `json:"availableQuantity,omitempty"`
+}
+
+// lineErrors rejects a whole request for the lines listed; no line is applied.
+type lineErrors struct {
+	lines []lineError
+}
+
+func (e *lineErrors) Error() string {
+	return fmt.Sprintf("%d lines rejected", len(e.lines))
+}
+
+// linesProblem is the body of a replace or batch add refused for some of its
+// lines. It lists each rejected line.
+type linesProblem struct {
+	problem.Details
+	Lines []lineError `json:"lines"`
+}
+
+// ReplaceCart sets the cart's lines to exactly the ones sent, creating the
+// cart if there is none. Coupons and the tax location are kept.
+func (h *CartHandler) ReplaceCart(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("userId")
+	if userID == "" {
+		writeInvalid(w, r, "userId", problem.FieldRequired, "missing userId")
+		return
+	}
+
+	lines, ok := decodeBatchLines(w, r)
+	if !ok {
+		return
+	}
+	if lines == nil {
+		// An empty list clears the cart; a missing one is a mistake.
+		writeInvalid(w, r, "items", problem.FieldRequired, "missing items")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	items, rejected, err := h.priceLines(ctx, lines)
+	if err != nil {
+		writePriceError(w, r, err)
+		return
+	}
+	if len(rejected) > 0 {
+		writeLineErrors(w, r, rejected)
+		return
+	}
+
+	activity := h.activity(r)
+	var replaced []cart.Item
+	c, err := h.saveCartChange(ctx, userID, parseIfMatch(r), true, func(c *cart.Cart) error {
+		if err := h.holdLines(ctx, userID, items, func(string) int { return 0 }); err != nil {
+			return err
+		}
+		replaced = c.Items
+		c.Items = append([]cart.Item{}, items...)
+		return nil
+	}, func(ctx context.Context, c *cart.Cart) error {
+		return h.repo.UpsertCart(ctx, c, activity)
+	})
+	if err != nil {
+		writeBatchError(w, r, err)
+		return
+	}
+	for _, it := range replaced {
+		if findItem(c, it.ProductID) < 0 {
+			h.releaseStock(ctx, userID, it.ProductID)
+		}
+	}
+
+	h.writeCart(ctx, w, r, http.StatusOK, c)
+}
+
+// AddItems adds several lines at once, as AddItem would one by one, except
+// that either every line is added or none is.
+func (h *CartHandler) AddItems(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("userId")
+	if userID == "" {
+		writeInvalid(w, r, "userId", problem.FieldRequired, "missing userId")
+		return
+	}
+
+	lines, ok := decodeBatchLines(w, r)
+	if !ok {
+		return
+	}
+	if len(lines) == 0 {
+		writeInvalid(w, r, "items", problem.FieldRequired, "no items to add")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	items, rejected, err := h.priceLines(ctx, lines)
+	if err != nil {
+		writePriceError(w, r, err)
+		return
+	}
+	if len(rejected) > 0 {
+		writeLineErrors(w, r, rejected)
+		return
+	}
+
+	// As with AddItem, limits on what the cart already holds need the
+	// versioned read-modify-write.
+	cond := parseIfMatch(r)
+	if cond == nil && !h.cartRules.LimitsCart() {
+		var check cart.CheckFunc
+		if h.holds != nil {
+			check = func(ctx context.Context, before *cart.Cart) error {
+				return h.holdLines(ctx, userID, items, inCart(before))
+			}
+		}
+
+		c, err := h.repo.AddItems(ctx, userID, items, check, h.activity(r))
+		if err != nil {
+			writeBatchError(w, r, err)
+			return
+		}
+		h.writeCart(ctx, w, r, http.StatusOK, c)
+		return
+	}
+
+	activity := h.activity(r)
+	c, err := h.saveCartChange(ctx, userID, cond, true, func(c *cart.Cart) error {
+		if err := h.holdLines(ctx, userID, items, inCart(c)); err != nil {
+			return err
+		}
+		for _, item := range items {
+			if idx := findItem(c, item.ProductID); idx >= 0 {
+				c.Items[idx].Quantity += item.Quantity
+				c.Items[idx].Price = item.Price
+				c.Items[idx].PricedAt = item.PricedAt
+				continue
+			}
+			c.Items = append(c.Items, item)
+		}
+		return nil
+	}, func(ctx context.Context, c *cart.Cart) error {
+		return h.repo.UpsertCart(ctx, c, activity)
+	})
+	if err != nil {
+		writeBatchError(w, r, err)
+		return
+	}
+
+	h.writeCart(ctx, w, r, http.StatusOK, c)
+}
+
+// decodeBatchLines reads the {"items": [...]} body shared by ReplaceCart and
+// AddItems. A body without items gives nil lines.
+func decodeBatchLines(w http.ResponseWriter, r *http.Request) ([]batchLine, bool) {
+	var body struct {
+		Items []batchLine `json:"items"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "invalid json")
+		return nil, false
+	}
+	if len(body.Items) > maxBatchLines {
+		writeInvalid(w, r, "items", problem.FieldInvalid, fmt.Sprintf("at most %d items per request", maxBatchLines))
+		return nil, false
+	}
+	return body.Items, true
+}
+
+// priceLines checks every line against the cart rules and prices it from the
+// catalog. The items are only usable when no line is rejected; a catalog that
+// can't be reached fails the request as a whole.
+func (h *CartHandler) priceLines(ctx context.Context, lines []batchLine) ([]cart.Item, []lineError, error) {
+	var rejected []lineError
+	reject := func(i int, l batchLine, code, msg string) {
+		rejected = append(rejected, lineError{Index: i, ProductID: l.ProductID, Code: code, Message: msg})
+	}
+
+	items := make([]cart.Item, 0, len(lines))
+	seen := make(map[string]bool, len(lines))
+	currency := ""
+	pricedAt := time.Now().UTC()
+	for i, l := range lines {
+		if l.ProductID == "" {
+			reject(i, l, lineMissingProductID, "missing productId")
+			continue
+		}
+		if seen[l.ProductID] {
+			reject(i, l, lineDuplicateProduct, "product is listed more than once")
+			continue
+		}
+		seen[l.ProductID] = true
+
+		var violated *rules.Error
+		if err := h.cartRules.CheckItem(l.ProductID, l.Quantity); errors.As(err, &violated) {
+			rejected = append(rejected, lineError{
+				Index:      i,
+				ProductID:  l.ProductID,
+				Code:       lineRulesViolated,
+				Message:    "cart rules violated",
+				Violations: violated.Violations,
+			})
+			continue
+		}
+
+		price, err := h.prices.Price(ctx, l.ProductID)
+		switch {
+		case errors.Is(err, pricing.ErrProductInactive):
+			reject(i, l, cart.ProblemProductInactive, "product is no longer sold")
+			continue
+		case errors.Is(err, pricing.ErrProductNotFound):
+			reject(i, l, cart.ProblemProductNotFound, "product not found")
+			continue
+		case err != nil:
+			return nil, nil, err
+		}
+		if currency == "" {
+			currency = price.Currency
+		} else if price.Currency != currency {
+			reject(i, l, lineCurrencyMismatch, fmt.Sprintf("product is priced in %s, other lines in %s", price.Currency, currency))
+			continue
+		}
+
+		items = append(items, cart.Item{
+			ProductID: l.ProductID,
+			Quantity:  l.Quantity,
+			Price:     price,
+			PricedAt:  pricedAt,
+		})
+	}
+
+	return items, rejected, nil
+}
+
+// holdLines holds stock for every item on top of what held reports the cart
+// already has of the product. All short lines are reported together; holds
+// taken for the other lines are left to expire if the change is refused.
+func (h *CartHandler) holdLines(ctx context.Context, userID string, items []cart.Item, held func(productID string) int) error {
+	var rejected []lineError
+	for i, it := range items {
+		var shortage *inventory.ShortageError
+		if err := h.holdStock(ctx, userID, it.ProductID, held(it.ProductID)+it.Quantity); errors.As(err, &shortage) {
+			free := shortage.Free
+			rejected = append(rejected, lineError{
+				Index:             i,
+				ProductID:         it.ProductID,
+				Code:              cart.ProblemOutOfStock,
+				Message:           fmt.Sprintf("insufficient stock: %d available", free),
+				AvailableQuantity: &free,
+			})
+		} else if err != nil {
+			return err
+		}
+	}
+	if len(rejected) > 0 {
+		return &lineErrors{lines: rejected}
+	}
+	return nil
+}
+
+// inCart reports how much of a product c holds. A nil c holds nothing.
+func inCart(c *cart.Cart) func(productID string) int {
+	return func(productID string) int {
+		if c == nil {
+			return 0
+		}
+		if idx := findItem(c, productID); idx >= 0 {
+			return c.Items[idx].Quantity
+		}
+		return 0
+	}
+}
+
+// writeBatchError maps errors from saving a replace or batch add to responses.
+func writeBatchError(w http.ResponseWriter, r *http.Request, err error) {
+	var rejected *lineErrors
+	if errors.As(err, &rejected) {
+		writeLineErrors(w, r, rejected.lines)
+		return
+	}
+	writeCartUpdateError(w, r, err)
+}
+
+func writeLineErrors(w http.ResponseWriter, r *http.Request, lines []lineError) {
+	problem.Write(w, r, &linesProblem{
+		Details: problem.New(http.StatusUnprocessableEntity, codeCartLinesRejected, "some items were rejected"),
+		Lines:   lines,
+	})
+}
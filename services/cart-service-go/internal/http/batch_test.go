@@ -0,0 +1,165 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cartpkg "github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/inventory"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/rules"
+)
+
+type lineErrorBody struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+	Lines []struct {
+		Index             int               `json:"index"`
+		ProductID         string            `json:"productId"`
+		Code              string            `json:"code"`
+		Violations        []rules.Violation `json:"violations"`
+		AvailableQuantity *int              `json:"availableQuantity"`
+	} `json:"lines"`
+}
+
+func decodeLineErrors(t *testing.T, w *httptest.ResponseRecorder) lineErrorBody {
+	t.Helper()
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", w.Code, w.Body.String())
+	}
+	var body lineErrorBody
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Error == "" || body.Code != "cart_lines_rejected" || len(body.Lines) == 0 {
+		t.Fatalf("expected per-line errors, got %s", w.Body.String())
+	}
+	return body
+}
+
+func TestReplaceCart(t *testing.T) {
+	holds := inventory.NewInMemoryHolds(map[string]int{"p1": 5, "p2": 5})
+	if err := holds.Hold(context.Background(), "123", "p1", 1, 0); err != nil {
+		t.Fatalf("seed hold: %v", err)
+	}
+	repo := &RepositoryMock{
+		GetCartFunc:    cartWith(cartpkg.Item{ProductID: "p1", Quantity: 1, Price: usd(1000)}),
+		UpsertCartFunc: func(ctx context.Context, c *cartpkg.Cart, activity cartpkg.ActivityFunc) error { return nil },
+	}
+	w := httptest.NewRecorder()
+
+	holdsRouter(repo, holds).ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/cart/123", strings.NewReader(`{"items":[{"productId":"p2","quantity":3}]}`)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	calls := repo.UpsertCartCalls()
+	if len(calls) != 1 {
+		t.Fatalf("expected one save, got %d", len(calls))
+	}
+	if c := calls[0].C; len(c.Items) != 1 || c.Items[0].ProductID != "p2" || c.Items[0].Quantity != 3 || c.Subtotal != usd(1500) {
+		t.Fatalf("unexpected saved cart %+v", c)
+	}
+	if got := holds.Held("123"); got["p1"] != 0 || got["p2"] != 3 {
+		t.Fatalf("expected the dropped line released and the new one held, got %v", got)
+	}
+}
+
+func TestReplaceCartReportsEveryBadLine(t *testing.T) {
+	repo := &RepositoryMock{}
+	w := httptest.NewRecorder()
+
+	body := `{"items":[{"productId":"p1","quantity":1},{"productId":"nope","quantity":1},{"productId":"p1","quantity":2},{"productId":"p2","quantity":0}]}`
+	rulesRouter(t, repo, rules.Config{}).ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/cart/u1", strings.NewReader(body)))
+
+	got := decodeLineErrors(t, w)
+	if len(got.Lines) != 3 {
+		t.Fatalf("expected 3 rejected lines, got %+v", got.Lines)
+	}
+	want := []struct {
+		index int
+		code  string
+	}{{1, cartpkg.ProblemProductNotFound}, {2, "duplicate_product"}, {3, "rules_violated"}}
+	for i, l := range got.Lines {
+		if l.Index != want[i].index || l.Code != want[i].code {
+			t.Fatalf("line %d: expected %+v, got %+v", i, want[i], l)
+		}
+	}
+	if v := got.Lines[2].Violations; len(v) != 1 || v[0].Rule != rules.RuleQuantity {
+		t.Fatalf("unexpected violations %+v", v)
+	}
+	if len(repo.GetCartCalls()) != 0 || len(repo.UpsertCartCalls()) != 0 {
+		t.Fatalf("expected the cart to be left alone")
+	}
+}
+
+func TestReplaceCartRequiresItems(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	rulesRouter(t, &RepositoryMock{}, rules.Config{}).ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/cart/u1", strings.NewReader(`{}`)))
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAddItemsBatch(t *testing.T) {
+	repo := &RepositoryMock{
+		AddItemsFunc: func(ctx context.Context, userID string, items []cartpkg.Item, check cartpkg.CheckFunc, activity cartpkg.ActivityFunc) (*cartpkg.Cart, error) {
+			return &cartpkg.Cart{ID: "c1", UserID: userID, Items: items, Version: 1}, nil
+		},
+	}
+	w := httptest.NewRecorder()
+
+	rulesRouter(t, repo, rules.Config{}).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/cart/u1/items:batch", strings.NewReader(`{"items":[{"productId":"p1","quantity":1},{"productId":"p3","quantity":2}]}`)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	calls := repo.AddItemsCalls()
+	if len(calls) != 1 || len(calls[0].Items) != 2 {
+		t.Fatalf("expected both lines added in one call, got %+v", calls)
+	}
+	if it := calls[0].Items[1]; it.ProductID != "p3" || it.Quantity != 2 || it.Price != usd(200) {
+		t.Fatalf("expected the line priced from the catalog, got %+v", it)
+	}
+}
+
+func TestAddItemsBatchRejectsShortStock(t *testing.T) {
+	holds := inventory.NewInMemoryHolds(map[string]int{"p1": 3, "p2": 1})
+	repo := &RepositoryMock{
+		AddItemsFunc: addChecked(&cartpkg.Cart{ID: "c1", UserID: "123", Items: []cartpkg.Item{{ProductID: "p1", Quantity: 2, Price: usd(1000)}}, Version: 1}),
+	}
+	w := httptest.NewRecorder()
+
+	holdsRouter(repo, holds).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/cart/123/items:batch", strings.NewReader(`{"items":[{"productId":"p1","quantity":2},{"productId":"p2","quantity":1}]}`)))
+
+	got := decodeLineErrors(t, w)
+	if len(got.Lines) != 1 || got.Lines[0].Index != 0 || got.Lines[0].Code != cartpkg.ProblemOutOfStock || *got.Lines[0].AvailableQuantity != 3 {
+		t.Fatalf("expected the whole p1 line to be short, got %+v", got.Lines)
+	}
+	if got := holds.Held("123"); got["p1"] != 0 {
+		t.Fatalf("expected p1 not to be held, got %v", got)
+	}
+}
+
+func TestAddItemsBatchCartRules(t *testing.T) {
+	repo := &RepositoryMock{
+		GetCartFunc:    cartWith(cartpkg.Item{ProductID: "p1", Quantity: 1, Price: usd(1000)}),
+		UpsertCartFunc: func(ctx context.Context, c *cartpkg.Cart, activity cartpkg.ActivityFunc) error { return nil },
+	}
+	w := httptest.NewRecorder()
+
+	rulesRouter(t, repo, rules.Config{MaxLines: 2}).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/cart/u1/items:batch", strings.NewReader(`{"items":[{"productId":"p2","quantity":1},{"productId":"p3","quantity":1}]}`)))
+
+	body := decodeRulesError(t, w)
+	if v := body.Violations[0]; v.Rule != rules.RuleMaxLines || v.Actual != 3 {
+		t.Fatalf("unexpected violation %+v", v)
+	}
+	if len(repo.UpsertCartCalls()) != 0 || len(repo.AddItemsCalls()) != 0 {
+		t.Fatalf("expected nothing to be saved")
+	}
+}
@@ -3,25 +3,54 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
 	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/events"
-	"github.com/google/uuid"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/idempotency"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/inventory"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/outbox"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/pricing"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/problem"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/promotion"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/rules"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/tax"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/wishlist"
 )
 
 type CartHandler struct {
 	repo           cart.Repository
 	eventPublisher CartEventsPublisher
+	prices         pricing.PriceSource
+	idempotency    idempotency.Store
+	promotions     promotion.Store
+	taxes          tax.Rules
+	mergeStrategy  cart.MergeStrategy
+	// defaultTaxLocation applies to carts whose customer has not set one.
+	defaultTaxLocation tax.Location
+	// holds, when set, holds stock in inventory for the cart's lines for holdTTL.
+	holds   inventory.Holds
+	holdTTL time.Duration
+	// wishlists, when set, is where lines moved out of the cart are kept.
+	wishlists wishlist.Repository
+	// activityEvents records cart activity events with each cart change.
+	activityEvents bool
+	// cartRules limits what a cart may hold.
+	cartRules *rules.Rules
+	logger    *log.Logger
 }
 
 type CartEventsPublisher interface {
-	PublishCartCheckedOut(ctx context.Context, c *cart.Cart, metadata events.PublishMetadata) error
+	CartCheckedOutEvents(c *cart.Cart, metadata events.PublishMetadata) []outbox.Event
+	CartActivityEvents(before, after *cart.Cart, metadata events.PublishMetadata) []outbox.Event
 }
 
-func NewCartHandler(repo cart.Repository, eventPublisher CartEventsPublisher) *CartHandler {
-	return &CartHandler{repo: repo, eventPublisher: eventPublisher}
+func NewCartHandler(repo cart.Repository, eventPublisher CartEventsPublisher, prices pricing.PriceSource, idem idempotency.Store, promotions promotion.Store, taxes tax.Rules) *CartHandler {
+	return &CartHandler{repo: repo, eventPublisher: eventPublisher, prices: prices, idempotency: idem, promotions: promotions, taxes: taxes, mergeStrategy: cart.MergeSum, cartRules: &rules.Rules{}, logger: log.Default()}
 }
 
 func (h *CartHandler) GetCart(w http.ResponseWriter, r *http.Request) {
@@ -30,7 +59,7 @@ func (h *CartHandler) GetCart(w http.ResponseWriter, r *http.Request) {
 
 	userID := r.PathValue("userId")
 	if userID == "" {
-		writeError(w, http.StatusBadRequest, "missing userId")
+		writeInvalid(w, r, "userId", problem.FieldRequired, "missing userId")
 		return
 	}
 
@@ -40,134 +69,529 @@ func (h *CartHandler) GetCart(w http.ResponseWriter, r *http.Request) {
 	c, err := h.repo.GetCart(ctx, userID)
 	if err != nil {
 		// You can later define your own error types; for now, treat as 500
-		writeError(w, http.StatusInternalServerError, "failed to load cart")
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to load cart")
 		return
 	}
 
 	if c == nil {
-		writeError(w, http.StatusNotFound, "cart not found")
+		writeError(w, r, http.StatusNotFound, codeCartNotFound, "cart not found")
 		return
 	}
 
-	writeJSON(w, http.StatusOK, c)
+	h.writeCart(ctx, w, r, http.StatusOK, c)
 }
 
 func (h *CartHandler) AddItem(w http.ResponseWriter, r *http.Request) {
-	// parse body (productId, quantity, price), load cart, update, save via repo.UpsertCart
+	// parse body (productId, quantity), price it, then add the line atomically
+	// (or via a versioned read-modify-write when If-Match is sent)
 	userID := r.PathValue("userId")
 	if userID == "" {
-		writeError(w, http.StatusBadRequest, "missing userId")
+		writeInvalid(w, r, "userId", problem.FieldRequired, "missing userId")
 		return
 	}
 
+	// Any client-sent "price" is ignored; the catalog is the source of truth.
 	var body struct {
-		ProductID string  `json:"productId"`
-		Quantity  int     `json:"quantity"`
-		Price     float64 `json:"price"`
+		ProductID string `json:"productId"`
+		Quantity  int    `json:"quantity"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid json")
+		writeError(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "invalid json")
+		return
+	}
+	if err := h.cartRules.CheckItem(body.ProductID, body.Quantity); err != nil {
+		writeCartUpdateError(w, r, err)
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 	defer cancel()
 
-	// Load existing cart (or nil)
-	c, err := h.repo.GetCart(ctx, userID)
+	price, err := h.prices.Price(ctx, body.ProductID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to load cart")
+		writePriceError(w, r, err)
 		return
 	}
+	item := cart.Item{
+		ProductID: body.ProductID,
+		Quantity:  body.Quantity,
+		Price:     price,
+		PricedAt:  time.Now().UTC(),
+	}
 
-	// If no cart exists, create new
-	if c == nil {
-		c = &cart.Cart{
-			ID:        uuid.NewString(),
-			UserID:    userID,
-			Items:     []cart.Item{},
-			UpdatedAt: time.Now(),
+	// Limits on what the cart already holds need the versioned read-modify-write.
+	cond := parseIfMatch(r)
+	if cond == nil && !h.cartRules.LimitsCart() {
+		// The hold covers the whole line, so it needs what is already in the
+		// cart. It is taken with the cart locked, so concurrent adds hold what
+		// each of them leaves.
+		var check cart.CheckFunc
+		if h.holds != nil {
+			check = func(ctx context.Context, before *cart.Cart) error {
+				return h.holdStock(ctx, userID, item.ProductID, inCart(before)(item.ProductID)+item.Quantity)
+			}
+		}
+
+		// Single upsert of the line; concurrent adds can't overwrite each other.
+		c, err := h.repo.AddItem(ctx, userID, item, check, h.activity(r))
+		if err != nil {
+			writeCartUpdateError(w, r, err)
+			return
 		}
+		h.writeCart(ctx, w, r, http.StatusOK, c)
+		return
 	}
 
-	// Find existing item or append new
-	updated := false
-	for i := range c.Items {
-		if c.Items[i].ProductID == body.ProductID {
-			c.Items[i].Quantity += body.Quantity
-			c.Items[i].Price = body.Price
-			updated = true
-			break
-		}
-	}
-	if !updated {
-		c.Items = append(c.Items, cart.Item{
-			ProductID: body.ProductID,
-			Quantity:  body.Quantity,
-			Price:     body.Price,
-		})
+	// The first add creates the cart, as the single upsert does.
+	activity := h.activity(r)
+	c, err := h.saveCartChange(ctx, userID, cond, true, func(c *cart.Cart) error {
+		// Find existing item or append new
+		if idx := findItem(c, item.ProductID); idx >= 0 {
+			if err := h.holdStock(ctx, userID, item.ProductID, c.Items[idx].Quantity+item.Quantity); err != nil {
+				return err
+			}
+			c.Items[idx].Quantity += item.Quantity
+			c.Items[idx].Price = item.Price
+			c.Items[idx].PricedAt = item.PricedAt
+			return nil
+		}
+		if err := h.holdStock(ctx, userID, item.ProductID, item.Quantity); err != nil {
+			return err
+		}
+		c.Items = append(c.Items, item)
+		return nil
+	}, func(ctx context.Context, c *cart.Cart) error {
+		return h.repo.UpsertCart(ctx, c, activity)
+	})
+	if err != nil {
+		writeCartUpdateError(w, r, err)
+		return
 	}
 
-	// Recalculate total
-	total := 0.0
-	for _, item := range c.Items {
-		total += float64(item.Quantity) * item.Price
+	h.writeCart(ctx, w, r, http.StatusOK, c)
+}
+
+// UpdateItem sets the quantity of an existing line to an absolute value.
+func (h *CartHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("userId")
+	if userID == "" {
+		writeInvalid(w, r, "userId", problem.FieldRequired, "missing userId")
+		return
 	}
-	c.Total = total
-	c.UpdatedAt = time.Now()
+	productID := r.PathValue("productId")
+	if productID == "" {
+		writeInvalid(w, r, "productId", problem.FieldRequired, "missing productId")
+		return
+	}
+
+	var body struct {
+		Quantity *int `json:"quantity"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "invalid json")
+		return
+	}
+	if body.Quantity == nil {
+		writeInvalid(w, r, "quantity", problem.FieldRequired, "missing quantity")
+		return
+	}
+	if err := h.cartRules.CheckItem(productID, *body.Quantity); err != nil {
+		writeCartUpdateError(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
 
-	// Save to DB
-	if err := h.repo.UpsertCart(ctx, c); err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to save cart")
+	c, err := h.updateCart(ctx, userID, parseIfMatch(r), h.activity(r), func(c *cart.Cart) error {
+		idx := findItem(c, productID)
+		if idx < 0 {
+			return errItemNotFound
+		}
+		if err := h.holdStock(ctx, userID, productID, *body.Quantity); err != nil {
+			return err
+		}
+		c.Items[idx].Quantity = *body.Quantity
+		return nil
+	})
+	if err != nil {
+		writeCartUpdateError(w, r, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, c)
+	h.writeCart(ctx, w, r, http.StatusOK, c)
+}
+
+// RemoveItem drops a single product line from the cart.
+func (h *CartHandler) RemoveItem(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("userId")
+	if userID == "" {
+		writeInvalid(w, r, "userId", problem.FieldRequired, "missing userId")
+		return
+	}
+	productID := r.PathValue("productId")
+	if productID == "" {
+		writeInvalid(w, r, "productId", problem.FieldRequired, "missing productId")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	c, err := h.updateCart(ctx, userID, parseIfMatch(r), h.activity(r), func(c *cart.Cart) error {
+		idx := findItem(c, productID)
+		if idx < 0 {
+			return errItemNotFound
+		}
+		c.Items = append(c.Items[:idx], c.Items[idx+1:]...)
+		return nil
+	})
+	if err != nil {
+		writeCartUpdateError(w, r, err)
+		return
+	}
+	h.releaseStock(ctx, userID, productID)
+
+	h.writeCart(ctx, w, r, http.StatusOK, c)
+}
+
+// CreateGuestCart issues an anonymous cart. The returned userId is the guest
+// cart ID and is used in place of a user ID on the other cart endpoints.
+func (h *CartHandler) CreateGuestCart(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	c := &cart.Cart{
+		UserID: cart.NewGuestID(),
+		Items:  []cart.Item{},
+	}
+	_ = recalculateTotal(c) // an empty cart cannot mix currencies
+
+	// An empty cart has no activity to record yet.
+	if err := h.repo.UpsertCart(ctx, c, nil); err != nil {
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to create guest cart")
+		return
+	}
+
+	h.writeCart(ctx, w, r, http.StatusCreated, c)
+}
+
+// MergeCart folds a guest cart into the user's cart and deletes the guest cart.
+func (h *CartHandler) MergeCart(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("userId")
+	if userID == "" {
+		writeInvalid(w, r, "userId", problem.FieldRequired, "missing userId")
+		return
+	}
+	if cart.IsGuestID(userID) {
+		writeError(w, r, http.StatusBadRequest, codeGuestNotAllowed, "cannot merge into a guest cart")
+		return
+	}
+
+	var body struct {
+		GuestCartID string `json:"guestCartId"`
+		Strategy    string `json:"strategy"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "invalid json")
+		return
+	}
+	if !cart.IsGuestID(body.GuestCartID) {
+		writeInvalid(w, r, "guestCartId", problem.FieldInvalid, "invalid guestCartId")
+		return
+	}
+
+	strategy := h.mergeStrategy
+	if body.Strategy != "" {
+		st, err := cart.ParseMergeStrategy(body.Strategy)
+		if err != nil {
+			writeInvalid(w, r, "strategy", problem.FieldInvalid, "strategy must be one of sum, max, user, guest")
+			return
+		}
+		strategy = st
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	cond := parseIfMatch(r)
+	for attempt := 1; ; attempt++ {
+		guest, err := h.repo.GetCart(ctx, body.GuestCartID)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to load cart")
+			return
+		}
+		if guest == nil {
+			writeError(w, r, http.StatusNotFound, codeCartNotFound, "guest cart not found")
+			return
+		}
+
+		c, err := h.repo.GetCart(ctx, userID)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to load cart")
+			return
+		}
+		if cond != nil && !cond.matches(c) {
+			writeError(w, r, http.StatusPreconditionFailed, problem.CodePreconditionFailed, "cart has been modified")
+			return
+		}
+		if c == nil {
+			c = &cart.Cart{UserID: userID, Items: []cart.Item{}}
+		}
+
+		before := snapshotCart(c)
+		cart.Merge(c, guest, strategy)
+		if err := recalculateTotal(c); err != nil {
+			writeCartUpdateError(w, r, err)
+			return
+		}
+		if err := h.cartRules.Check(before, c); err != nil {
+			writeCartUpdateError(w, r, err)
+			return
+		}
+
+		err = h.repo.MergeCarts(ctx, c, guest, h.activity(r))
+		if err == nil {
+			h.moveHolds(ctx, c, guest.UserID)
+			h.writeCart(ctx, w, r, http.StatusOK, c)
+			return
+		}
+		if !errors.Is(err, cart.ErrVersionConflict) {
+			writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to merge carts")
+			return
+		}
+		if cond != nil {
+			writeError(w, r, http.StatusPreconditionFailed, problem.CodePreconditionFailed, "cart has been modified")
+			return
+		}
+		if attempt == maxCartWriteAttempts {
+			writeCartUpdateError(w, r, err)
+			return
+		}
+	}
+}
+
+// ClearCart empties the cart without checking out.
+func (h *CartHandler) ClearCart(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("userId")
+	if userID == "" {
+		writeInvalid(w, r, "userId", problem.FieldRequired, "missing userId")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	var expectedVersion int64
+	if cond := parseIfMatch(r); cond != nil {
+		c, err := h.repo.GetCart(ctx, userID)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to load cart")
+			return
+		}
+		if !cond.matches(c) {
+			writeError(w, r, http.StatusPreconditionFailed, problem.CodePreconditionFailed, "cart has been modified")
+			return
+		}
+		expectedVersion = c.Version
+	}
+
+	if err := h.repo.ClearCart(ctx, userID, expectedVersion, h.activity(r)); err != nil {
+		if errors.Is(err, cart.ErrVersionConflict) {
+			writeError(w, r, http.StatusPreconditionFailed, problem.CodePreconditionFailed, "cart has been modified")
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to clear cart")
+		return
+	}
+	h.releaseAllStock(ctx, userID)
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (h *CartHandler) Checkout(w http.ResponseWriter, r *http.Request) {
 	userID := r.PathValue("userId")
 	if userID == "" {
-		writeError(w, http.StatusBadRequest, "missing userId")
+		writeInvalid(w, r, "userId", problem.FieldRequired, "missing userId")
+		return
+	}
+
+	if cart.IsGuestID(userID) {
+		writeError(w, r, http.StatusBadRequest, codeGuestNotAllowed, "guest carts must be merged into a user cart before checkout")
+		return
+	}
+
+	key := r.Header.Get(idempotency.HeaderKey)
+	if len(key) > idempotency.MaxKeyLength {
+		writeInvalid(w, r, "Idempotency-Key", problem.FieldTooLong, "idempotency key too long")
+		return
+	}
+
+	// The body is optional; acceptCorrections is the correctionsToken of a
+	// validation the client agrees to, and the shipping details are passed
+	// on to the order.
+	var req struct {
+		AcceptCorrections string        `json:"acceptCorrections"`
+		ShippingAddress   *cart.Address `json:"shippingAddress"`
+		ShippingMethod    string        `json:"shippingMethod"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "invalid json")
+		return
+	}
+	shipping, err := parseShipping(req.ShippingAddress, req.ShippingMethod)
+	if err != nil {
+		writeFieldError(w, r, err)
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 	defer cancel()
 
+	// A retried checkout gets the original response instead of a second event.
+	if key != "" && h.replayCheckout(ctx, w, r, userID, key) {
+		return
+	}
+
 	c, err := h.repo.GetCart(ctx, userID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to load cart")
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to load cart")
+		return
+	}
+	cond := parseIfMatch(r)
+	if cond != nil && !cond.matches(c) {
+		writeError(w, r, http.StatusPreconditionFailed, problem.CodePreconditionFailed, "cart has been modified")
 		return
 	}
 	if c == nil {
-		writeError(w, http.StatusNotFound, "cart not found")
+		h.writeCheckoutNotFound(ctx, w, r, userID, key)
 		return
 	}
 
-	// Publish event (later you add real RabbitMQ)
-	metadata := events.PublishMetadata{
-		CorrelationID: r.Header.Get("X-Correlation-Id"),
-		CausationID:   r.Header.Get("X-Causation-Id"),
+	// Validate every line so the event carries current catalog prices and
+	// only stock that is there.
+	validation, err := h.validateCart(ctx, c)
+	if err != nil {
+		writeValidationError(w, r, err)
+		return
 	}
-	if metadata.CorrelationID == "" {
-		metadata.CorrelationID = uuid.NewString()
+	// The discounts are worked out again at the new prices.
+	if err := h.applyPromotions(ctx, c); err != nil {
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to apply promotions")
+		return
 	}
-	if err := h.eventPublisher.PublishCartCheckedOut(ctx, c, metadata); err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to publish cart checked out event")
+	if err := h.applyTax(ctx, c); err != nil {
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to calculate tax")
 		return
 	}
-
-	// Clear DB cart
-	if err := h.repo.ClearCart(ctx, userID); err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to clear cart")
+	// A cart with problems is only checked out once the client has seen the
+	// corrected cart and sent back its token.
+	if !validation.Valid && validation.CorrectionsToken != req.AcceptCorrections {
+		problem.Write(w, r, &correctionsProblem{
+			Details:    problem.New(http.StatusConflict, codeCartNeedsCorrections, "cart needs corrections, accept them to check out"),
+			Validation: validation,
+		})
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]string{
+	c.Shipping = shipping
+	metadata := publishMetadata(r)
+
+	body, err := json.Marshal(map[string]string{
 		"status": "checkout completed",
 	})
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to checkout cart")
+		return
+	}
+
+	var idem *idempotency.Record
+	if key != "" {
+		idem = &idempotency.Record{UserID: userID, Key: key, StatusCode: http.StatusOK, Body: body}
+	}
+
+	// Clear the cart and record the events in the outbox atomically; the relay publishes them.
+	evs := h.eventPublisher.CartCheckedOutEvents(c, metadata)
+	if err := h.repo.CheckoutCart(ctx, c, evs, idem); err != nil {
+		if errors.Is(err, cart.ErrCartNotFound) {
+			h.writeCheckoutNotFound(ctx, w, r, userID, key)
+			return
+		}
+		if errors.Is(err, cart.ErrVersionConflict) {
+			if cond != nil {
+				writeError(w, r, http.StatusPreconditionFailed, problem.CodePreconditionFailed, "cart has been modified")
+				return
+			}
+			writeError(w, r, http.StatusConflict, problem.CodeVersionConflict, "cart was modified during checkout, retry the request")
+			return
+		}
+		if errors.Is(err, promotion.ErrUsageLimitReached) {
+			writeError(w, r, http.StatusUnprocessableEntity, codeCouponUsageLimit, "coupon usage limit reached, remove the coupon and retry")
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to checkout cart")
+		return
+	}
+
+	if key != "" {
+		w.Header().Set(idempotency.HeaderKey, key)
+	}
+	writeRawJSON(w, http.StatusOK, body)
+}
+
+// replayCheckout writes the stored response for key, if there is one, and
+// reports whether a response was written.
+func (h *CartHandler) replayCheckout(ctx context.Context, w http.ResponseWriter, r *http.Request, userID, key string) bool {
+	rec, err := h.idempotency.Lookup(ctx, userID, key)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to look up idempotency key")
+		return true
+	}
+	if rec == nil {
+		return false
+	}
+
+	w.Header().Set(idempotency.HeaderKey, key)
+	w.Header().Set("Idempotent-Replayed", "true")
+	writeRawJSON(w, rec.StatusCode, rec.Body)
+	return true
+}
+
+// writeCheckoutNotFound replays the response of a concurrent request with the
+// same key that cleared the cart first, and reports 404 otherwise.
+func (h *CartHandler) writeCheckoutNotFound(ctx context.Context, w http.ResponseWriter, r *http.Request, userID, key string) {
+	if key != "" && h.replayCheckout(ctx, w, r, userID, key) {
+		return
+	}
+	writeError(w, r, http.StatusNotFound, codeCartNotFound, "cart not found")
+}
+
+func writePriceError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, pricing.ErrProductNotFound) {
+		writeError(w, r, http.StatusNotFound, codeProductNotFound, "product not found")
+		return
+	}
+	writeError(w, r, http.StatusBadGateway, problem.CodeUpstreamFailed, "failed to resolve product price")
+}
+
+func findItem(c *cart.Cart, productID string) int {
+	for i := range c.Items {
+		if c.Items[i].ProductID == productID {
+			return i
+		}
+	}
+	return -1
+}
+
+func recalculateTotal(c *cart.Cart) error {
+	if err := c.RecalculateTotal(); err != nil {
+		return err
+	}
+	c.UpdatedAt = time.Now()
+	return nil
 }
 
 func writeJSON(w http.ResponseWriter, status int, v any) {
@@ -176,8 +600,8 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 	_ = json.NewEncoder(w).Encode(v)
 }
 
-func writeError(w http.ResponseWriter, status int, msg string) {
-	writeJSON(w, status, map[string]string{
-		"error": msg,
-	})
+func writeRawJSON(w http.ResponseWriter, status int, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
 }
@@ -1,5 +1,6 @@
 // Code generated by moq; DO NOT EDIT.
 // github.com/matryer/moq
+
 package http_test
 
 import (
@@ -8,6 +9,10 @@ import (
 
 	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
 	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/events"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/idempotency"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/outbox"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/promotion"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/wishlist"
 )
 
 // RepositoryMock is a mock implementation of cart.Repository.
@@ -18,12 +23,30 @@ import (
 //	        GetCartFunc: func(ctx context.Context, userID string) (*cart.Cart, error) {
 //	            panic("mock out the GetCart method")
 //	        },
-//	        UpsertCartFunc: func(ctx context.Context, c *cart.Cart) error {
+//	        AddItemFunc: func(ctx context.Context, userID string, item cart.Item, check cart.CheckFunc, activity cart.ActivityFunc) (*cart.Cart, error) {
+//	            panic("mock out the AddItem method")
+//	        },
+//	        AddItemsFunc: func(ctx context.Context, userID string, items []cart.Item, check cart.CheckFunc, activity cart.ActivityFunc) (*cart.Cart, error) {
+//	            panic("mock out the AddItems method")
+//	        },
+//	        UpsertCartFunc: func(ctx context.Context, c *cart.Cart, activity cart.ActivityFunc) error {
 //	            panic("mock out the UpsertCart method")
 //	        },
-//	        ClearCartFunc: func(ctx context.Context, userID string) error {
+//	        ClearCartFunc: func(ctx context.Context, userID string, expectedVersion int64, activity cart.ActivityFunc) error {
 //	            panic("mock out the ClearCart method")
 //	        },
+//	        MergeCartsFunc: func(ctx context.Context, c *cart.Cart, guest *cart.Cart, activity cart.ActivityFunc) error {
+//	            panic("mock out the MergeCarts method")
+//	        },
+//	        CheckoutCartFunc: func(ctx context.Context, c *cart.Cart, evs []outbox.Event, idem *idempotency.Record) error {
+//	            panic("mock out the CheckoutCart method")
+//	        },
+//	        MoveToWishlistFunc: func(ctx context.Context, c *cart.Cart, listID string, item wishlist.Item, activity cart.ActivityFunc) error {
+//	            panic("mock out the MoveToWishlist method")
+//	        },
+//	        MoveFromWishlistFunc: func(ctx context.Context, c *cart.Cart, listID string, productID string, activity cart.ActivityFunc) error {
+//	            panic("mock out the MoveFromWishlist method")
+//	        },
 //	    }
 //
 //	    // use mockedRepository in code that requires cart.Repository
@@ -32,11 +55,29 @@ type RepositoryMock struct {
 	// GetCartFunc mocks the GetCart method.
 	GetCartFunc func(ctx context.Context, userID string) (*cart.Cart, error)
 
+	// AddItemFunc mocks the AddItem method.
+	AddItemFunc func(ctx context.Context, userID string, item cart.Item, check cart.CheckFunc, activity cart.ActivityFunc) (*cart.Cart, error)
+
+	// AddItemsFunc mocks the AddItems method.
+	AddItemsFunc func(ctx context.Context, userID string, items []cart.Item, check cart.CheckFunc, activity cart.ActivityFunc) (*cart.Cart, error)
+
 	// UpsertCartFunc mocks the UpsertCart method.
-	UpsertCartFunc func(ctx context.Context, c *cart.Cart) error
+	UpsertCartFunc func(ctx context.Context, c *cart.Cart, activity cart.ActivityFunc) error
 
 	// ClearCartFunc mocks the ClearCart method.
-	ClearCartFunc func(ctx context.Context, userID string) error
+	ClearCartFunc func(ctx context.Context, userID string, expectedVersion int64, activity cart.ActivityFunc) error
+
+	// MergeCartsFunc mocks the MergeCarts method.
+	MergeCartsFunc func(ctx context.Context, c *cart.Cart, guest *cart.Cart, activity cart.ActivityFunc) error
+
+	// CheckoutCartFunc mocks the CheckoutCart method.
+	CheckoutCartFunc func(ctx context.Context, c *cart.Cart, evs []outbox.Event, idem *idempotency.Record) error
+
+	// MoveToWishlistFunc mocks the MoveToWishlist method.
+	MoveToWishlistFunc func(ctx context.Context, c *cart.Cart, listID string, item wishlist.Item, activity cart.ActivityFunc) error
+
+	// MoveFromWishlistFunc mocks the MoveFromWishlist method.
+	MoveFromWishlistFunc func(ctx context.Context, c *cart.Cart, listID string, productID string, activity cart.ActivityFunc) error
 
 	// calls tracks calls to the methods.
 	calls struct {
@@ -47,12 +88,40 @@ type RepositoryMock struct {
 			// UserID is the userID argument value.
 			UserID string
 		}
+		// AddItem holds details about calls to the AddItem method.
+		AddItem []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// UserID is the userID argument value.
+			UserID string
+			// Item is the item argument value.
+			Item cart.Item
+			// Check is the check argument value.
+			Check cart.CheckFunc
+			// Activity is the activity argument value.
+			Activity cart.ActivityFunc
+		}
+		// AddItems holds details about calls to the AddItems method.
+		AddItems []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// UserID is the userID argument value.
+			UserID string
+			// Items is the items argument value.
+			Items []cart.Item
+			// Check is the check argument value.
+			Check cart.CheckFunc
+			// Activity is the activity argument value.
+			Activity cart.ActivityFunc
+		}
 		// UpsertCart holds details about calls to the UpsertCart method.
 		UpsertCart []struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 			// C is the c argument value.
 			C *cart.Cart
+			// Activity is the activity argument value.
+			Activity cart.ActivityFunc
 		}
 		// ClearCart holds details about calls to the ClearCart method.
 		ClearCart []struct {
@@ -60,78 +129,69 @@ type RepositoryMock struct {
 			Ctx context.Context
 			// UserID is the userID argument value.
 			UserID string
+			// ExpectedVersion is the expectedVersion argument value.
+			ExpectedVersion int64
+			// Activity is the activity argument value.
+			Activity cart.ActivityFunc
 		}
-	}
-	lockRepositoryMockGetCart    sync.RWMutex
-	lockRepositoryMockUpsertCart sync.RWMutex
-	lockRepositoryMockClearCart  sync.RWMutex
-}
-
-// RabbitCartEventsPublisherMock is a mock implementation of events.RabbitCartEventsPublisher.
-//
-//	func TestSomethingThatUsesRabbitCartEventsPublisher(t *testing.T) {
-//	    // make and configure a mocked events.RabbitCartEventsPublisher
-//	    mockedRabbitCartEventsPublisher := &RabbitCartEventsPublisherMock{
-//	        PublishCartCheckedOutFunc: func(ctx context.Context, c *cart.Cart) error {
-//	            panic("mock out the PublishCartCheckedOut method")
-//	        },
-//	    }
-//
-//	    // use mockedRabbitCartEventsPublisher in code that requires events.RabbitCartEventsPublisher
-//	}
-type RabbitCartEventsPublisherMock struct {
-	// PublishCartCheckedOutFunc mocks the PublishCartCheckedOut method.
-	PublishCartCheckedOutFunc func(ctx context.Context, c *cart.Cart, metadata events.PublishMetadata) error
-
-	// calls tracks calls to the methods.
-	calls struct {
-		// PublishCartCheckedOut holds details about calls to the PublishCartCheckedOut method.
-		PublishCartCheckedOut []struct {
+		// MergeCarts holds details about calls to the MergeCarts method.
+		MergeCarts []struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 			// C is the c argument value.
 			C *cart.Cart
-			// Metadata is the metadata argument value.
-			Metadata events.PublishMetadata
+			// Guest is the guest argument value.
+			Guest *cart.Cart
+			// Activity is the activity argument value.
+			Activity cart.ActivityFunc
+		}
+		// CheckoutCart holds details about calls to the CheckoutCart method.
+		CheckoutCart []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// C is the c argument value.
+			C *cart.Cart
+			// Evs is the evs argument value.
+			Evs []outbox.Event
+			// Idem is the idem argument value.
+			Idem *idempotency.Record
+		}
+		// MoveToWishlist holds details about calls to the MoveToWishlist method.
+		MoveToWishlist []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// C is the c argument value.
+			C *cart.Cart
+			// ListID is the listID argument value.
+			ListID string
+			// Item is the item argument value.
+			Item wishlist.Item
+			// Activity is the activity argument value.
+			Activity cart.ActivityFunc
+		}
+		// MoveFromWishlist holds details about calls to the MoveFromWishlist method.
+		MoveFromWishlist []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// C is the c argument value.
+			C *cart.Cart
+			// ListID is the listID argument value.
+			ListID string
+			// ProductID is the productID argument value.
+			ProductID string
+			// Activity is the activity argument value.
+			Activity cart.ActivityFunc
 		}
 	}
-	lockRabbitCartEventsPublisherMockPublishCartCheckedOut sync.RWMutex
-}
-
-// PublishCartCheckedOut calls PublishCartCheckedOutFunc.
-func (mock *RabbitCartEventsPublisherMock) PublishCartCheckedOut(ctx context.Context, c *cart.Cart, metadata events.PublishMetadata) error {
-	if mock.PublishCartCheckedOutFunc == nil {
-		panic("RabbitCartEventsPublisherMock.PublishCartCheckedOutFunc: method is nil but RabbitCartEventsPublisher.PublishCartCheckedOut was just called")
-	}
-	callInfo := struct {
-		Ctx      context.Context
-		C        *cart.Cart
-		Metadata events.PublishMetadata
-	}{Ctx: ctx, C: c, Metadata: metadata}
-	mock.lockRabbitCartEventsPublisherMockPublishCartCheckedOut.Lock()
-	mock.calls.PublishCartCheckedOut = append(mock.calls.PublishCartCheckedOut, callInfo)
-	mock.lockRabbitCartEventsPublisherMockPublishCartCheckedOut.Unlock()
-	return mock.PublishCartCheckedOutFunc(ctx, c, metadata)
-}
-
-// PublishCartCheckedOutCalls gets all the calls that were made to PublishCartCheckedOut.
-// Check the length with:
-//
-//	len(mockedRabbitCartEventsPublisher.PublishCartCheckedOutCalls())
-func (mock *RabbitCartEventsPublisherMock) PublishCartCheckedOutCalls() []struct {
-	Ctx      context.Context
-	C        *cart.Cart
-	Metadata events.PublishMetadata
-} {
-	var calls []struct {
-		Ctx      context.Context
-		C        *cart.Cart
-		Metadata events.PublishMetadata
-	}
-	mock.lockRabbitCartEventsPublisherMockPublishCartCheckedOut.RLock()
-	calls = mock.calls.PublishCartCheckedOut
-	mock.lockRabbitCartEventsPublisherMockPublishCartCheckedOut.RUnlock()
-	return calls
+	lockRepositoryMockGetCart          sync.RWMutex
+	lockRepositoryMockAddItem          sync.RWMutex
+	lockRepositoryMockAddItems         sync.RWMutex
+	lockRepositoryMockUpsertCart       sync.RWMutex
+	lockRepositoryMockClearCart        sync.RWMutex
+	lockRepositoryMockMergeCarts       sync.RWMutex
+	lockRepositoryMockCheckoutCart     sync.RWMutex
+	lockRepositoryMockMoveToWishlist   sync.RWMutex
+	lockRepositoryMockMoveFromWishlist sync.RWMutex
 }
 
 // GetCart calls GetCartFunc.
@@ -167,19 +227,104 @@ func (mock *RepositoryMock) GetCartCalls() []struct {
 	return calls
 }
 
+// AddItem calls AddItemFunc.
+func (mock *RepositoryMock) AddItem(ctx context.Context, userID string, item cart.Item, check cart.CheckFunc, activity cart.ActivityFunc) (*cart.Cart, error) {
+	if mock.AddItemFunc == nil {
+		panic("RepositoryMock.AddItemFunc: method is nil but Repository.AddItem was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		UserID   string
+		Item     cart.Item
+		Check    cart.CheckFunc
+		Activity cart.ActivityFunc
+	}{Ctx: ctx, UserID: userID, Item: item, Check: check, Activity: activity}
+	mock.lockRepositoryMockAddItem.Lock()
+	mock.calls.AddItem = append(mock.calls.AddItem, callInfo)
+	mock.lockRepositoryMockAddItem.Unlock()
+	return mock.AddItemFunc(ctx, userID, item, check, activity)
+}
+
+// AddItemCalls gets all the calls that were made to AddItem.
+// Check the length with:
+//
+//	len(mockedRepository.AddItemCalls())
+func (mock *RepositoryMock) AddItemCalls() []struct {
+	Ctx      context.Context
+	UserID   string
+	Item     cart.Item
+	Check    cart.CheckFunc
+	Activity cart.ActivityFunc
+} {
+	var calls []struct {
+		Ctx      context.Context
+		UserID   string
+		Item     cart.Item
+		Check    cart.CheckFunc
+		Activity cart.ActivityFunc
+	}
+	mock.lockRepositoryMockAddItem.RLock()
+	calls = mock.calls.AddItem
+	mock.lockRepositoryMockAddItem.RUnlock()
+	return calls
+}
+
+// AddItems calls AddItemsFunc.
+func (mock *RepositoryMock) AddItems(ctx context.Context, userID string, items []cart.Item, check cart.CheckFunc, activity cart.ActivityFunc) (*cart.Cart, error) {
+	if mock.AddItemsFunc == nil {
+		panic("RepositoryMock.AddItemsFunc: method is nil but Repository.AddItems was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		UserID   string
+		Items    []cart.Item
+		Check    cart.CheckFunc
+		Activity cart.ActivityFunc
+	}{Ctx: ctx, UserID: userID, Items: items, Check: check, Activity: activity}
+	mock.lockRepositoryMockAddItems.Lock()
+	mock.calls.AddItems = append(mock.calls.AddItems, callInfo)
+	mock.lockRepositoryMockAddItems.Unlock()
+	return mock.AddItemsFunc(ctx, userID, items, check, activity)
+}
+
+// AddItemsCalls gets all the calls that were made to AddItems.
+// Check the length with:
+//
+//	len(mockedRepository.AddItemsCalls())
+func (mock *RepositoryMock) AddItemsCalls() []struct {
+	Ctx      context.Context
+	UserID   string
+	Items    []cart.Item
+	Check    cart.CheckFunc
+	Activity cart.ActivityFunc
+} {
+	var calls []struct {
+		Ctx      context.Context
+		UserID   string
+		Items    []cart.Item
+		Check    cart.CheckFunc
+		Activity cart.ActivityFunc
+	}
+	mock.lockRepositoryMockAddItems.RLock()
+	calls = mock.calls.AddItems
+	mock.lockRepositoryMockAddItems.RUnlock()
+	return calls
+}
+
 // UpsertCart calls UpsertCartFunc.
-func (mock *RepositoryMock) UpsertCart(ctx context.Context, c *cart.Cart) error {
+func (mock *RepositoryMock) UpsertCart(ctx context.Context, c *cart.Cart, activity cart.ActivityFunc) error {
 	if mock.UpsertCartFunc == nil {
 		panic("RepositoryMock.UpsertCartFunc: method is nil but Repository.UpsertCart was just called")
 	}
 	callInfo := struct {
-		Ctx context.Context
-		C   *cart.Cart
-	}{Ctx: ctx, C: c}
+		Ctx      context.Context
+		C        *cart.Cart
+		Activity cart.ActivityFunc
+	}{Ctx: ctx, C: c, Activity: activity}
 	mock.lockRepositoryMockUpsertCart.Lock()
 	mock.calls.UpsertCart = append(mock.calls.UpsertCart, callInfo)
 	mock.lockRepositoryMockUpsertCart.Unlock()
-	return mock.UpsertCartFunc(ctx, c)
+	return mock.UpsertCartFunc(ctx, c, activity)
 }
 
 // UpsertCartCalls gets all the calls that were made to UpsertCart.
@@ -187,12 +332,14 @@ func (mock *RepositoryMock) UpsertCart(ctx context.Context, c *cart.Cart) error
 //
 //	len(mockedRepository.UpsertCartCalls())
 func (mock *RepositoryMock) UpsertCartCalls() []struct {
-	Ctx context.Context
-	C   *cart.Cart
+	Ctx      context.Context
+	C        *cart.Cart
+	Activity cart.ActivityFunc
 } {
 	var calls []struct {
-		Ctx context.Context
-		C   *cart.Cart
+		Ctx      context.Context
+		C        *cart.Cart
+		Activity cart.ActivityFunc
 	}
 	mock.lockRepositoryMockUpsertCart.RLock()
 	calls = mock.calls.UpsertCart
@@ -201,18 +348,20 @@ func (mock *RepositoryMock) UpsertCartCalls() []struct {
 }
 
 // ClearCart calls ClearCartFunc.
-func (mock *RepositoryMock) ClearCart(ctx context.Context, userID string) error {
+func (mock *RepositoryMock) ClearCart(ctx context.Context, userID string, expectedVersion int64, activity cart.ActivityFunc) error {
 	if mock.ClearCartFunc == nil {
 		panic("RepositoryMock.ClearCartFunc: method is nil but Repository.ClearCart was just called")
 	}
 	callInfo := struct {
-		Ctx    context.Context
-		UserID string
-	}{Ctx: ctx, UserID: userID}
+		Ctx             context.Context
+		UserID          string
+		ExpectedVersion int64
+		Activity        cart.ActivityFunc
+	}{Ctx: ctx, UserID: userID, ExpectedVersion: expectedVersion, Activity: activity}
 	mock.lockRepositoryMockClearCart.Lock()
 	mock.calls.ClearCart = append(mock.calls.ClearCart, callInfo)
 	mock.lockRepositoryMockClearCart.Unlock()
-	return mock.ClearCartFunc(ctx, userID)
+	return mock.ClearCartFunc(ctx, userID, expectedVersion, activity)
 }
 
 // ClearCartCalls gets all the calls that were made to ClearCart.
@@ -220,15 +369,518 @@ func (mock *RepositoryMock) ClearCart(ctx context.Context, userID string) error
 //
 //	len(mockedRepository.ClearCartCalls())
 func (mock *RepositoryMock) ClearCartCalls() []struct {
+	Ctx             context.Context
+	UserID          string
+	ExpectedVersion int64
+	Activity        cart.ActivityFunc
+} {
+	var calls []struct {
+		Ctx             context.Context
+		UserID          string
+		ExpectedVersion int64
+		Activity        cart.ActivityFunc
+	}
+	mock.lockRepositoryMockClearCart.RLock()
+	calls = mock.calls.ClearCart
+	mock.lockRepositoryMockClearCart.RUnlock()
+	return calls
+}
+
+// MergeCarts calls MergeCartsFunc.
+func (mock *RepositoryMock) MergeCarts(ctx context.Context, c *cart.Cart, guest *cart.Cart, activity cart.ActivityFunc) error {
+	if mock.MergeCartsFunc == nil {
+		panic("RepositoryMock.MergeCartsFunc: method is nil but Repository.MergeCarts was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		C        *cart.Cart
+		Guest    *cart.Cart
+		Activity cart.ActivityFunc
+	}{Ctx: ctx, C: c, Guest: guest, Activity: activity}
+	mock.lockRepositoryMockMergeCarts.Lock()
+	mock.calls.MergeCarts = append(mock.calls.MergeCarts, callInfo)
+	mock.lockRepositoryMockMergeCarts.Unlock()
+	return mock.MergeCartsFunc(ctx, c, guest, activity)
+}
+
+// MergeCartsCalls gets all the calls that were made to MergeCarts.
+// Check the length with:
+//
+//	len(mockedRepository.MergeCartsCalls())
+func (mock *RepositoryMock) MergeCartsCalls() []struct {
+	Ctx      context.Context
+	C        *cart.Cart
+	Guest    *cart.Cart
+	Activity cart.ActivityFunc
+} {
+	var calls []struct {
+		Ctx      context.Context
+		C        *cart.Cart
+		Guest    *cart.Cart
+		Activity cart.ActivityFunc
+	}
+	mock.lockRepositoryMockMergeCarts.RLock()
+	calls = mock.calls.MergeCarts
+	mock.lockRepositoryMockMergeCarts.RUnlock()
+	return calls
+}
+
+// CheckoutCart calls CheckoutCartFunc.
+func (mock *RepositoryMock) CheckoutCart(ctx context.Context, c *cart.Cart, evs []outbox.Event, idem *idempotency.Record) error {
+	if mock.CheckoutCartFunc == nil {
+		panic("RepositoryMock.CheckoutCartFunc: method is nil but Repository.CheckoutCart was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		C    *cart.Cart
+		Evs  []outbox.Event
+		Idem *idempotency.Record
+	}{Ctx: ctx, C: c, Evs: evs, Idem: idem}
+	mock.lockRepositoryMockCheckoutCart.Lock()
+	mock.calls.CheckoutCart = append(mock.calls.CheckoutCart, callInfo)
+	mock.lockRepositoryMockCheckoutCart.Unlock()
+	return mock.CheckoutCartFunc(ctx, c, evs, idem)
+}
+
+// CheckoutCartCalls gets all the calls that were made to CheckoutCart.
+// Check the length with:
+//
+//	len(mockedRepository.CheckoutCartCalls())
+func (mock *RepositoryMock) CheckoutCartCalls() []struct {
+	Ctx  context.Context
+	C    *cart.Cart
+	Evs  []outbox.Event
+	Idem *idempotency.Record
+} {
+	var calls []struct {
+		Ctx  context.Context
+		C    *cart.Cart
+		Evs  []outbox.Event
+		Idem *idempotency.Record
+	}
+	mock.lockRepositoryMockCheckoutCart.RLock()
+	calls = mock.calls.CheckoutCart
+	mock.lockRepositoryMockCheckoutCart.RUnlock()
+	return calls
+}
+
+// MoveToWishlist calls MoveToWishlistFunc.
+func (mock *RepositoryMock) MoveToWishlist(ctx context.Context, c *cart.Cart, listID string, item wishlist.Item, activity cart.ActivityFunc) error {
+	if mock.MoveToWishlistFunc == nil {
+		panic("RepositoryMock.MoveToWishlistFunc: method is nil but Repository.MoveToWishlist was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		C        *cart.Cart
+		ListID   string
+		Item     wishlist.Item
+		Activity cart.ActivityFunc
+	}{Ctx: ctx, C: c, ListID: listID, Item: item, Activity: activity}
+	mock.lockRepositoryMockMoveToWishlist.Lock()
+	mock.calls.MoveToWishlist = append(mock.calls.MoveToWishlist, callInfo)
+	mock.lockRepositoryMockMoveToWishlist.Unlock()
+	return mock.MoveToWishlistFunc(ctx, c, listID, item, activity)
+}
+
+// MoveToWishlistCalls gets all the calls that were made to MoveToWishlist.
+// Check the length with:
+//
+//	len(mockedRepository.MoveToWishlistCalls())
+func (mock *RepositoryMock) MoveToWishlistCalls() []struct {
+	Ctx      context.Context
+	C        *cart.Cart
+	ListID   string
+	Item     wishlist.Item
+	Activity cart.ActivityFunc
+} {
+	var calls []struct {
+		Ctx      context.Context
+		C        *cart.Cart
+		ListID   string
+		Item     wishlist.Item
+		Activity cart.ActivityFunc
+	}
+	mock.lockRepositoryMockMoveToWishlist.RLock()
+	calls = mock.calls.MoveToWishlist
+	mock.lockRepositoryMockMoveToWishlist.RUnlock()
+	return calls
+}
+
+// MoveFromWishlist calls MoveFromWishlistFunc.
+func (mock *RepositoryMock) MoveFromWishlist(ctx context.Context, c *cart.Cart, listID string, productID string, activity cart.ActivityFunc) error {
+	if mock.MoveFromWishlistFunc == nil {
+		panic("RepositoryMock.MoveFromWishlistFunc: method is nil but Repository.MoveFromWishlist was just called")
+	}
+	callInfo := struct {
+		Ctx       context.Context
+		C         *cart.Cart
+		ListID    string
+		ProductID string
+		Activity  cart.ActivityFunc
+	}{Ctx: ctx, C: c, ListID: listID, ProductID: productID, Activity: activity}
+	mock.lockRepositoryMockMoveFromWishlist.Lock()
+	mock.calls.MoveFromWishlist = append(mock.calls.MoveFromWishlist, callInfo)
+	mock.lockRepositoryMockMoveFromWishlist.Unlock()
+	return mock.MoveFromWishlistFunc(ctx, c, listID, productID, activity)
+}
+
+// MoveFromWishlistCalls gets all the calls that were made to MoveFromWishlist.
+// Check the length with:
+//
+//	len(mockedRepository.MoveFromWishlistCalls())
+func (mock *RepositoryMock) MoveFromWishlistCalls() []struct {
+	Ctx       context.Context
+	C         *cart.Cart
+	ListID    string
+	ProductID string
+	Activity  cart.ActivityFunc
+} {
+	var calls []struct {
+		Ctx       context.Context
+		C         *cart.Cart
+		ListID    string
+		ProductID string
+		Activity  cart.ActivityFunc
+	}
+	mock.lockRepositoryMockMoveFromWishlist.RLock()
+	calls = mock.calls.MoveFromWishlist
+	mock.lockRepositoryMockMoveFromWishlist.RUnlock()
+	return calls
+}
+
+// RabbitCartEventsPublisherMock is a mock implementation of events.RabbitCartEventsPublisher.
+//
+//	func TestSomethingThatUsesRabbitCartEventsPublisher(t *testing.T) {
+//	    // make and configure a mocked events.RabbitCartEventsPublisher
+//	    mockedRabbitCartEventsPublisher := &RabbitCartEventsPublisherMock{
+//	        CartCheckedOutEventsFunc: func(c *cart.Cart, metadata events.PublishMetadata) []outbox.Event {
+//	            panic("mock out the CartCheckedOutEvents method")
+//	        },
+//	        CartActivityEventsFunc: func(before *cart.Cart, after *cart.Cart, metadata events.PublishMetadata) []outbox.Event {
+//	            panic("mock out the CartActivityEvents method")
+//	        },
+//	    }
+//
+//	    // use mockedRabbitCartEventsPublisher in code that requires events.RabbitCartEventsPublisher
+//	}
+type RabbitCartEventsPublisherMock struct {
+	// CartCheckedOutEventsFunc mocks the CartCheckedOutEvents method.
+	CartCheckedOutEventsFunc func(c *cart.Cart, metadata events.PublishMetadata) []outbox.Event
+
+	// CartActivityEventsFunc mocks the CartActivityEvents method.
+	CartActivityEventsFunc func(before *cart.Cart, after *cart.Cart, metadata events.PublishMetadata) []outbox.Event
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// CartCheckedOutEvents holds details about calls to the CartCheckedOutEvents method.
+		CartCheckedOutEvents []struct {
+			// C is the c argument value.
+			C *cart.Cart
+			// Metadata is the metadata argument value.
+			Metadata events.PublishMetadata
+		}
+		// CartActivityEvents holds details about calls to the CartActivityEvents method.
+		CartActivityEvents []struct {
+			// Before is the before argument value.
+			Before *cart.Cart
+			// After is the after argument value.
+			After *cart.Cart
+			// Metadata is the metadata argument value.
+			Metadata events.PublishMetadata
+		}
+	}
+	lockRabbitCartEventsPublisherMockCartCheckedOutEvents sync.RWMutex
+	lockRabbitCartEventsPublisherMockCartActivityEvents   sync.RWMutex
+}
+
+// CartCheckedOutEvents calls CartCheckedOutEventsFunc.
+func (mock *RabbitCartEventsPublisherMock) CartCheckedOutEvents(c *cart.Cart, metadata events.PublishMetadata) []outbox.Event {
+	if mock.CartCheckedOutEventsFunc == nil {
+		panic("RabbitCartEventsPublisherMock.CartCheckedOutEventsFunc: method is nil but RabbitCartEventsPublisher.CartCheckedOutEvents was just called")
+	}
+	callInfo := struct {
+		C        *cart.Cart
+		Metadata events.PublishMetadata
+	}{C: c, Metadata: metadata}
+	mock.lockRabbitCartEventsPublisherMockCartCheckedOutEvents.Lock()
+	mock.calls.CartCheckedOutEvents = append(mock.calls.CartCheckedOutEvents, callInfo)
+	mock.lockRabbitCartEventsPublisherMockCartCheckedOutEvents.Unlock()
+	return mock.CartCheckedOutEventsFunc(c, metadata)
+}
+
+// CartCheckedOutEventsCalls gets all the calls that were made to CartCheckedOutEvents.
+// Check the length with:
+//
+//	len(mockedRabbitCartEventsPublisher.CartCheckedOutEventsCalls())
+func (mock *RabbitCartEventsPublisherMock) CartCheckedOutEventsCalls() []struct {
+	C        *cart.Cart
+	Metadata events.PublishMetadata
+} {
+	var calls []struct {
+		C        *cart.Cart
+		Metadata events.PublishMetadata
+	}
+	mock.lockRabbitCartEventsPublisherMockCartCheckedOutEvents.RLock()
+	calls = mock.calls.CartCheckedOutEvents
+	mock.lockRabbitCartEventsPublisherMockCartCheckedOutEvents.RUnlock()
+	return calls
+}
+
+// CartActivityEvents calls CartActivityEventsFunc.
+func (mock *RabbitCartEventsPublisherMock) CartActivityEvents(before *cart.Cart, after *cart.Cart, metadata events.PublishMetadata) []outbox.Event {
+	if mock.CartActivityEventsFunc == nil {
+		panic("RabbitCartEventsPublisherMock.CartActivityEventsFunc: method is nil but RabbitCartEventsPublisher.CartActivityEvents was just called")
+	}
+	callInfo := struct {
+		Before   *cart.Cart
+		After    *cart.Cart
+		Metadata events.PublishMetadata
+	}{Before: before, After: after, Metadata: metadata}
+	mock.lockRabbitCartEventsPublisherMockCartActivityEvents.Lock()
+	mock.calls.CartActivityEvents = append(mock.calls.CartActivityEvents, callInfo)
+	mock.lockRabbitCartEventsPublisherMockCartActivityEvents.Unlock()
+	return mock.CartActivityEventsFunc(before, after, metadata)
+}
+
+// CartActivityEventsCalls gets all the calls that were made to CartActivityEvents.
+// Check the length with:
+//
+//	len(mockedRabbitCartEventsPublisher.CartActivityEventsCalls())
+func (mock *RabbitCartEventsPublisherMock) CartActivityEventsCalls() []struct {
+	Before   *cart.Cart
+	After    *cart.Cart
+	Metadata events.PublishMetadata
+} {
+	var calls []struct {
+		Before   *cart.Cart
+		After    *cart.Cart
+		Metadata events.PublishMetadata
+	}
+	mock.lockRabbitCartEventsPublisherMockCartActivityEvents.RLock()
+	calls = mock.calls.CartActivityEvents
+	mock.lockRabbitCartEventsPublisherMockCartActivityEvents.RUnlock()
+	return calls
+}
+
+// IdempotencyStoreMock is a mock implementation of idempotency.Store.
+//
+//	func TestSomethingThatUsesIdempotencyStore(t *testing.T) {
+//	    // make and configure a mocked idempotency.Store
+//	    mockedIdempotencyStore := &IdempotencyStoreMock{
+//	        LookupFunc: func(ctx context.Context, userID string, key string) (*idempotency.Record, error) {
+//	            panic("mock out the Lookup method")
+//	        },
+//	        DeleteExpiredFunc: func(ctx context.Context) (int64, error) {
+//	            panic("mock out the DeleteExpired method")
+//	        },
+//	    }
+//
+//	    // use mockedIdempotencyStore in code that requires idempotency.Store
+//	}
+type IdempotencyStoreMock struct {
+	// LookupFunc mocks the Lookup method.
+	LookupFunc func(ctx context.Context, userID string, key string) (*idempotency.Record, error)
+
+	// DeleteExpiredFunc mocks the DeleteExpired method.
+	DeleteExpiredFunc func(ctx context.Context) (int64, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Lookup holds details about calls to the Lookup method.
+		Lookup []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// UserID is the userID argument value.
+			UserID string
+			// Key is the key argument value.
+			Key string
+		}
+		// DeleteExpired holds details about calls to the DeleteExpired method.
+		DeleteExpired []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+	}
+	lockIdempotencyStoreMockLookup        sync.RWMutex
+	lockIdempotencyStoreMockDeleteExpired sync.RWMutex
+}
+
+// Lookup calls LookupFunc.
+func (mock *IdempotencyStoreMock) Lookup(ctx context.Context, userID string, key string) (*idempotency.Record, error) {
+	if mock.LookupFunc == nil {
+		panic("IdempotencyStoreMock.LookupFunc: method is nil but Store.Lookup was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		UserID string
+		Key    string
+	}{Ctx: ctx, UserID: userID, Key: key}
+	mock.lockIdempotencyStoreMockLookup.Lock()
+	mock.calls.Lookup = append(mock.calls.Lookup, callInfo)
+	mock.lockIdempotencyStoreMockLookup.Unlock()
+	return mock.LookupFunc(ctx, userID, key)
+}
+
+// LookupCalls gets all the calls that were made to Lookup.
+// Check the length with:
+//
+//	len(mockedIdempotencyStore.LookupCalls())
+func (mock *IdempotencyStoreMock) LookupCalls() []struct {
 	Ctx    context.Context
 	UserID string
+	Key    string
 } {
 	var calls []struct {
 		Ctx    context.Context
 		UserID string
+		Key    string
 	}
-	mock.lockRepositoryMockClearCart.RLock()
-	calls = mock.calls.ClearCart
-	mock.lockRepositoryMockClearCart.RUnlock()
+	mock.lockIdempotencyStoreMockLookup.RLock()
+	calls = mock.calls.Lookup
+	mock.lockIdempotencyStoreMockLookup.RUnlock()
+	return calls
+}
+
+// DeleteExpired calls DeleteExpiredFunc.
+func (mock *IdempotencyStoreMock) DeleteExpired(ctx context.Context) (int64, error) {
+	if mock.DeleteExpiredFunc == nil {
+		panic("IdempotencyStoreMock.DeleteExpiredFunc: method is nil but Store.DeleteExpired was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{Ctx: ctx}
+	mock.lockIdempotencyStoreMockDeleteExpired.Lock()
+	mock.calls.DeleteExpired = append(mock.calls.DeleteExpired, callInfo)
+	mock.lockIdempotencyStoreMockDeleteExpired.Unlock()
+	return mock.DeleteExpiredFunc(ctx)
+}
+
+// DeleteExpiredCalls gets all the calls that were made to DeleteExpired.
+// Check the length with:
+//
+//	len(mockedIdempotencyStore.DeleteExpiredCalls())
+func (mock *IdempotencyStoreMock) DeleteExpiredCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockIdempotencyStoreMockDeleteExpired.RLock()
+	calls = mock.calls.DeleteExpired
+	mock.lockIdempotencyStoreMockDeleteExpired.RUnlock()
+	return calls
+}
+
+// PromotionStoreMock is a mock implementation of promotion.Store.
+//
+//	func TestSomethingThatUsesPromotionStore(t *testing.T) {
+//	    // make and configure a mocked promotion.Store
+//	    mockedPromotionStore := &PromotionStoreMock{
+//	        GetByCodeFunc: func(ctx context.Context, code string) (*promotion.Promotion, error) {
+//	            panic("mock out the GetByCode method")
+//	        },
+//	        RedemptionsFunc: func(ctx context.Context, promotionID string, userID string) (int, error) {
+//	            panic("mock out the Redemptions method")
+//	        },
+//	    }
+//
+//	    // use mockedPromotionStore in code that requires promotion.Store
+//	}
+type PromotionStoreMock struct {
+	// GetByCodeFunc mocks the GetByCode method.
+	GetByCodeFunc func(ctx context.Context, code string) (*promotion.Promotion, error)
+
+	// RedemptionsFunc mocks the Redemptions method.
+	RedemptionsFunc func(ctx context.Context, promotionID string, userID string) (int, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// GetByCode holds details about calls to the GetByCode method.
+		GetByCode []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Code is the code argument value.
+			Code string
+		}
+		// Redemptions holds details about calls to the Redemptions method.
+		Redemptions []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// PromotionID is the promotionID argument value.
+			PromotionID string
+			// UserID is the userID argument value.
+			UserID string
+		}
+	}
+	lockPromotionStoreMockGetByCode   sync.RWMutex
+	lockPromotionStoreMockRedemptions sync.RWMutex
+}
+
+// GetByCode calls GetByCodeFunc.
+func (mock *PromotionStoreMock) GetByCode(ctx context.Context, code string) (*promotion.Promotion, error) {
+	if mock.GetByCodeFunc == nil {
+		panic("PromotionStoreMock.GetByCodeFunc: method is nil but Store.GetByCode was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Code string
+	}{Ctx: ctx, Code: code}
+	mock.lockPromotionStoreMockGetByCode.Lock()
+	mock.calls.GetByCode = append(mock.calls.GetByCode, callInfo)
+	mock.lockPromotionStoreMockGetByCode.Unlock()
+	return mock.GetByCodeFunc(ctx, code)
+}
+
+// GetByCodeCalls gets all the calls that were made to GetByCode.
+// Check the length with:
+//
+//	len(mockedPromotionStore.GetByCodeCalls())
+func (mock *PromotionStoreMock) GetByCodeCalls() []struct {
+	Ctx  context.Context
+	Code string
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Code string
+	}
+	mock.lockPromotionStoreMockGetByCode.RLock()
+	calls = mock.calls.GetByCode
+	mock.lockPromotionStoreMockGetByCode.RUnlock()
+	return calls
+}
+
+// Redemptions calls RedemptionsFunc.
+func (mock *PromotionStoreMock) Redemptions(ctx context.Context, promotionID string, userID string) (int, error) {
+	if mock.RedemptionsFunc == nil {
+		panic("PromotionStoreMock.RedemptionsFunc: method is nil but Store.Redemptions was just called")
+	}
+	callInfo := struct {
+		Ctx         context.Context
+		PromotionID string
+		UserID      string
+	}{Ctx: ctx, PromotionID: promotionID, UserID: userID}
+	mock.lockPromotionStoreMockRedemptions.Lock()
+	mock.calls.Redemptions = append(mock.calls.Redemptions, callInfo)
+	mock.lockPromotionStoreMockRedemptions.Unlock()
+	return mock.RedemptionsFunc(ctx, promotionID, userID)
+}
+
+// RedemptionsCalls gets all the calls that were made to Redemptions.
+// Check the length with:
+//
+//	len(mockedPromotionStore.RedemptionsCalls())
+func (mock *PromotionStoreMock) RedemptionsCalls() []struct {
+	Ctx         context.Context
+	PromotionID string
+	UserID      string
+} {
+	var calls []struct {
+		Ctx         context.Context
+		PromotionID string
+		UserID      string
+	}
+	mock.lockPromotionStoreMockRedemptions.RLock()
+	calls = mock.calls.Redemptions
+	mock.lockPromotionStoreMockRedemptions.RUnlock()
 	return calls
 }
@@ -7,18 +7,27 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	cartpkg "github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
 	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/events"
 	httphandler "github.com/andreasstove999/ecommerce-system/cart-service-go/internal/http"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/idempotency"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/outbox"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/pricing"
 	"github.com/google/uuid"
 )
 
+func usd(cents int64) money.Money {
+	return money.New(cents, "USD")
+}
+
 // TODO: add RabbitCartEventsPublisher mock
 func TestGetCart(t *testing.T) {
 	t.Run("missing user id", func(t *testing.T) {
-		handler := httphandler.NewCartHandler(&RepositoryMock{}, nil)
+		handler := httphandler.NewCartHandler(&RepositoryMock{}, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
 		r := httptest.NewRequest(http.MethodGet, "/api/cart/", nil)
 		w := httptest.NewRecorder()
 
@@ -33,7 +42,7 @@ func TestGetCart(t *testing.T) {
 		repo := &RepositoryMock{GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
 			return nil, errors.New("db error")
 		}}
-		handler := httphandler.NewCartHandler(repo, nil)
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
 		r := httptest.NewRequest(http.MethodGet, "/api/cart/123", nil)
 		r.SetPathValue("userId", "123")
 		w := httptest.NewRecorder()
@@ -49,7 +58,7 @@ func TestGetCart(t *testing.T) {
 		repo := &RepositoryMock{GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
 			return nil, nil
 		}}
-		handler := httphandler.NewCartHandler(repo, nil)
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
 		r := httptest.NewRequest(http.MethodGet, "/api/cart/123", nil)
 		r.SetPathValue("userId", "123")
 		w := httptest.NewRecorder()
@@ -59,14 +68,24 @@ func TestGetCart(t *testing.T) {
 		if w.Code != http.StatusNotFound {
 			t.Fatalf("expected 404, got %d", w.Code)
 		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+			t.Fatalf("expected a problem response, got %q", ct)
+		}
+		var body struct {
+			Code     string `json:"code"`
+			Instance string `json:"instance"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code != "cart_not_found" || body.Instance != "/api/cart/123" {
+			t.Fatalf("unexpected body %s", w.Body.String())
+		}
 	})
 
 	t.Run("success", func(t *testing.T) {
-		expected := &cartpkg.Cart{ID: "c1", UserID: "123", Items: []cartpkg.Item{{ProductID: "p1", Quantity: 2, Price: 5}}, Total: 10}
+		expected := &cartpkg.Cart{ID: "c1", UserID: "123", Items: []cartpkg.Item{{ProductID: "p1", Quantity: 2, Price: usd(500)}}, Total: usd(1000), Version: 3}
 		repo := &RepositoryMock{GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
 			return expected, nil
 		}}
-		handler := httphandler.NewCartHandler(repo, nil)
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
 		r := httptest.NewRequest(http.MethodGet, "/api/cart/123", nil)
 		r.SetPathValue("userId", "123")
 		w := httptest.NewRecorder()
@@ -87,12 +106,15 @@ func TestGetCart(t *testing.T) {
 		if len(resp.Items) != 1 || resp.Items[0].ProductID != "p1" {
 			t.Fatalf("unexpected items %+v", resp.Items)
 		}
+		if resp.Version != 3 || w.Header().Get("ETag") != `"c1.3"` {
+			t.Fatalf("expected version 3 and matching ETag, got %d / %q", resp.Version, w.Header().Get("ETag"))
+		}
 	})
 }
 
 func TestAddItem(t *testing.T) {
 	t.Run("invalid json", func(t *testing.T) {
-		handler := httphandler.NewCartHandler(&RepositoryMock{}, nil)
+		handler := httphandler.NewCartHandler(&RepositoryMock{}, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
 		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/items", bytes.NewBufferString("{"))
 		r.SetPathValue("userId", "123")
 		w := httptest.NewRecorder()
@@ -104,12 +126,45 @@ func TestAddItem(t *testing.T) {
 		}
 	})
 
-	t.Run("load error", func(t *testing.T) {
-		repo := &RepositoryMock{GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
-			return nil, errors.New("load error")
-		}}
-		handler := httphandler.NewCartHandler(repo, nil)
-		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/items", bytes.NewBufferString(`{"productId":"p1","quantity":1,"price":2}`))
+	t.Run("adds line atomically", func(t *testing.T) {
+		var added cartpkg.Item
+		repo := &RepositoryMock{
+			AddItemFunc: func(ctx context.Context, userID string, item cartpkg.Item, check cartpkg.CheckFunc, activity cartpkg.ActivityFunc) (*cartpkg.Cart, error) {
+				added = item
+				return &cartpkg.Cart{ID: "c1", UserID: userID, Items: []cartpkg.Item{item}, Total: usd(600), Version: 4}, nil
+			},
+		}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(map[string]money.Money{"p1": usd(300)}), &IdempotencyStoreMock{}, nil, nil)
+		body := bytes.NewBufferString(`{"productId":"p1","quantity":2}`)
+		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/items", body)
+		r.SetPathValue("userId", "123")
+		w := httptest.NewRecorder()
+
+		handler.AddItem(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+		if added.ProductID != "p1" || added.Quantity != 2 || added.Price != usd(300) {
+			t.Fatalf("unexpected item %+v", added)
+		}
+		if got := w.Header().Get("ETag"); got != `"c1.4"` {
+			t.Fatalf("expected ETag of the stored cart, got %q", got)
+		}
+		if len(repo.GetCartCalls()) != 0 || len(repo.UpsertCartCalls()) != 0 {
+			t.Fatalf("did not expect a read-modify-write for an unconditional add")
+		}
+	})
+
+	t.Run("persist error", func(t *testing.T) {
+		repo := &RepositoryMock{
+			AddItemFunc: func(ctx context.Context, userID string, item cartpkg.Item, check cartpkg.CheckFunc, activity cartpkg.ActivityFunc) (*cartpkg.Cart, error) {
+				return nil, errors.New("save failed")
+			},
+		}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(map[string]money.Money{"p1": usd(200)}), &IdempotencyStoreMock{}, nil, nil)
+		body := bytes.NewBufferString(`{"productId":"p1","quantity":1}`)
+		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/items", body)
 		r.SetPathValue("userId", "123")
 		w := httptest.NewRecorder()
 
@@ -120,17 +175,35 @@ func TestAddItem(t *testing.T) {
 		}
 	})
 
-	t.Run("create new cart", func(t *testing.T) {
-		var saved *cartpkg.Cart
+	t.Run("rejects a second currency", func(t *testing.T) {
 		repo := &RepositoryMock{
-			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) { return nil, nil },
-			UpsertCartFunc: func(ctx context.Context, c *cartpkg.Cart) error {
-				saved = c
-				return nil
+			AddItemFunc: func(ctx context.Context, userID string, item cartpkg.Item, check cartpkg.CheckFunc, activity cartpkg.ActivityFunc) (*cartpkg.Cart, error) {
+				return nil, money.ErrCurrencyMismatch
+			},
+		}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(map[string]money.Money{"p1": money.New(200, "EUR")}), &IdempotencyStoreMock{}, nil, nil)
+		body := bytes.NewBufferString(`{"productId":"p1","quantity":1}`)
+		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/items", body)
+		r.SetPathValue("userId", "123")
+		w := httptest.NewRecorder()
+
+		handler.AddItem(w, r)
+
+		if w.Code != http.StatusConflict {
+			t.Fatalf("expected 409, got %d", w.Code)
+		}
+	})
+
+	t.Run("ignores client supplied price", func(t *testing.T) {
+		var added cartpkg.Item
+		repo := &RepositoryMock{
+			AddItemFunc: func(ctx context.Context, userID string, item cartpkg.Item, check cartpkg.CheckFunc, activity cartpkg.ActivityFunc) (*cartpkg.Cart, error) {
+				added = item
+				return &cartpkg.Cart{ID: "c1", UserID: userID, Items: []cartpkg.Item{item}}, nil
 			},
 		}
-		handler := httphandler.NewCartHandler(repo, nil)
-		body := bytes.NewBufferString(`{"productId":"p1","quantity":2,"price":3}`)
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(map[string]money.Money{"p1": usd(950)}), &IdempotencyStoreMock{}, nil, nil)
+		body := bytes.NewBufferString(`{"productId":"p1","quantity":2,"price":0.01}`)
 		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/items", body)
 		r.SetPathValue("userId", "123")
 		w := httptest.NewRecorder()
@@ -140,30 +213,29 @@ func TestAddItem(t *testing.T) {
 		if w.Code != http.StatusOK {
 			t.Fatalf("expected 200, got %d", w.Code)
 		}
-		if saved == nil {
-			t.Fatalf("expected cart to be saved")
+		if added.Price != usd(950) {
+			t.Fatalf("expected catalog price to be used, got %+v", added)
 		}
-		if saved.UserID != "123" || saved.Total != 6 {
-			t.Fatalf("unexpected saved cart %+v", saved)
-		}
-		if len(saved.Items) != 1 || saved.Items[0].ProductID != "p1" || saved.Items[0].Quantity != 2 {
-			t.Fatalf("unexpected items %+v", saved.Items)
+		if added.PricedAt.IsZero() {
+			t.Fatalf("expected price snapshot timestamp to be set")
 		}
 	})
 
-	t.Run("update existing item", func(t *testing.T) {
-		existing := &cartpkg.Cart{UserID: "123", Items: []cartpkg.Item{{ProductID: "p1", Quantity: 1, Price: 5}}}
+	t.Run("conditional add updates existing item", func(t *testing.T) {
+		existing := &cartpkg.Cart{ID: "c1", UserID: "123", Version: 2, Items: []cartpkg.Item{{ProductID: "p1", Quantity: 1, Price: usd(500)}}}
 		var saved *cartpkg.Cart
 		repo := &RepositoryMock{
 			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) { return existing, nil },
-			UpsertCartFunc: func(ctx context.Context, c *cartpkg.Cart) error {
+			UpsertCartFunc: func(ctx context.Context, c *cartpkg.Cart, activity cartpkg.ActivityFunc) error {
 				saved = c
+				c.Version++
 				return nil
 			},
 		}
-		handler := httphandler.NewCartHandler(repo, nil)
-		body := bytes.NewBufferString(`{"productId":"p1","quantity":2,"price":5}`)
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(map[string]money.Money{"p1": usd(500)}), &IdempotencyStoreMock{}, nil, nil)
+		body := bytes.NewBufferString(`{"productId":"p1","quantity":2}`)
 		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/items", body)
+		r.Header.Set("If-Match", `"c1.2"`)
 		r.SetPathValue("userId", "123")
 		w := httptest.NewRecorder()
 
@@ -178,19 +250,41 @@ func TestAddItem(t *testing.T) {
 		if saved.Items[0].Quantity != 3 {
 			t.Fatalf("expected quantity 3, got %d", saved.Items[0].Quantity)
 		}
-		if saved.Total != 15 {
-			t.Fatalf("expected total 15, got %f", saved.Total)
+		if saved.Total != usd(1500) {
+			t.Fatalf("expected total 15, got %v", saved.Total)
+		}
+		if got := w.Header().Get("ETag"); got != `"c1.3"` {
+			t.Fatalf("expected ETag of the new version, got %q", got)
 		}
 	})
 
-	t.Run("persist error", func(t *testing.T) {
-		repo := &RepositoryMock{
-			GetCartFunc:    func(ctx context.Context, userID string) (*cartpkg.Cart, error) { return nil, nil },
-			UpsertCartFunc: func(ctx context.Context, c *cartpkg.Cart) error { return errors.New("save failed") },
-		}
-		handler := httphandler.NewCartHandler(repo, nil)
-		body := bytes.NewBufferString(`{"productId":"p1","quantity":1,"price":2}`)
+	t.Run("conditional add with stale etag", func(t *testing.T) {
+		existing := &cartpkg.Cart{ID: "c1", UserID: "123", Version: 3}
+		repo := &RepositoryMock{GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) { return existing, nil }}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(map[string]money.Money{"p1": usd(500)}), &IdempotencyStoreMock{}, nil, nil)
+		body := bytes.NewBufferString(`{"productId":"p1","quantity":2}`)
 		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/items", body)
+		r.Header.Set("If-Match", `"c1.2"`)
+		r.SetPathValue("userId", "123")
+		w := httptest.NewRecorder()
+
+		handler.AddItem(w, r)
+
+		if w.Code != http.StatusPreconditionFailed {
+			t.Fatalf("expected 412, got %d", w.Code)
+		}
+		if len(repo.UpsertCartCalls()) != 0 {
+			t.Fatalf("did not expect cart to be saved")
+		}
+	})
+
+	t.Run("conditional add load error", func(t *testing.T) {
+		repo := &RepositoryMock{GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+			return nil, errors.New("load error")
+		}}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(map[string]money.Money{"p1": usd(200)}), &IdempotencyStoreMock{}, nil, nil)
+		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/items", bytes.NewBufferString(`{"productId":"p1","quantity":1}`))
+		r.Header.Set("If-Match", "*")
 		r.SetPathValue("userId", "123")
 		w := httptest.NewRecorder()
 
@@ -200,11 +294,34 @@ func TestAddItem(t *testing.T) {
 			t.Fatalf("expected 500, got %d", w.Code)
 		}
 	})
+
+	t.Run("unknown product", func(t *testing.T) {
+		handler := httphandler.NewCartHandler(&RepositoryMock{}, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		body := bytes.NewBufferString(`{"productId":"nope","quantity":1}`)
+		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/items", body)
+		r.SetPathValue("userId", "123")
+		w := httptest.NewRecorder()
+
+		handler.AddItem(w, r)
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d", w.Code)
+		}
+	})
 }
 
 func TestCheckout(t *testing.T) {
+	newPublisher := func(captured *events.PublishMetadata) *RabbitCartEventsPublisherMock {
+		return &RabbitCartEventsPublisherMock{CartCheckedOutEventsFunc: func(c *cartpkg.Cart, metadata events.PublishMetadata) []outbox.Event {
+			if captured != nil {
+				*captured = metadata
+			}
+			return []outbox.Event{{PartitionKey: c.ID, RoutingKey: events.CartCheckedOutRoutingKey}}
+		}}
+	}
+
 	t.Run("missing user id", func(t *testing.T) {
-		handler := httphandler.NewCartHandler(&RepositoryMock{}, &RabbitCartEventsPublisherMock{})
+		handler := httphandler.NewCartHandler(&RepositoryMock{}, &RabbitCartEventsPublisherMock{}, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
 		r := httptest.NewRequest(http.MethodPost, "/api/cart/", nil)
 		w := httptest.NewRecorder()
 
@@ -219,7 +336,7 @@ func TestCheckout(t *testing.T) {
 		repo := &RepositoryMock{GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
 			return nil, errors.New("db error")
 		}}
-		handler := httphandler.NewCartHandler(repo, &RabbitCartEventsPublisherMock{})
+		handler := httphandler.NewCartHandler(repo, &RabbitCartEventsPublisherMock{}, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
 		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/checkout", nil)
 		r.SetPathValue("userId", "123")
 		w := httptest.NewRecorder()
@@ -235,7 +352,7 @@ func TestCheckout(t *testing.T) {
 		repo := &RepositoryMock{GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
 			return nil, nil
 		}}
-		handler := httphandler.NewCartHandler(repo, &RabbitCartEventsPublisherMock{})
+		handler := httphandler.NewCartHandler(repo, &RabbitCartEventsPublisherMock{}, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
 		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/checkout", nil)
 		r.SetPathValue("userId", "123")
 		w := httptest.NewRecorder()
@@ -247,14 +364,16 @@ func TestCheckout(t *testing.T) {
 		}
 	})
 
-	t.Run("publish error", func(t *testing.T) {
-		repo := &RepositoryMock{GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
-			return &cartpkg.Cart{ID: "c1", UserID: userID}, nil
-		}}
-		publisher := &RabbitCartEventsPublisherMock{PublishCartCheckedOutFunc: func(ctx context.Context, c *cartpkg.Cart, _ events.PublishMetadata) error {
-			return errors.New("publish failed")
-		}}
-		handler := httphandler.NewCartHandler(repo, publisher)
+	t.Run("checkout transaction error", func(t *testing.T) {
+		repo := &RepositoryMock{
+			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+				return &cartpkg.Cart{ID: "c1", UserID: userID}, nil
+			},
+			CheckoutCartFunc: func(ctx context.Context, c *cartpkg.Cart, evs []outbox.Event, idem *idempotency.Record) error {
+				return errors.New("tx failed")
+			},
+		}
+		handler := httphandler.NewCartHandler(repo, newPublisher(nil), pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
 		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/checkout", nil)
 		r.SetPathValue("userId", "123")
 		w := httptest.NewRecorder()
@@ -264,49 +383,82 @@ func TestCheckout(t *testing.T) {
 		if w.Code != http.StatusInternalServerError {
 			t.Fatalf("expected 500, got %d", w.Code)
 		}
-		if len(publisher.PublishCartCheckedOutCalls()) != 1 {
-			t.Fatalf("expected publish to be called once, got %d", len(publisher.PublishCartCheckedOutCalls()))
+	})
+
+	t.Run("cart removed concurrently", func(t *testing.T) {
+		repo := &RepositoryMock{
+			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+				return &cartpkg.Cart{ID: "c1", UserID: userID}, nil
+			},
+			CheckoutCartFunc: func(ctx context.Context, c *cartpkg.Cart, evs []outbox.Event, idem *idempotency.Record) error {
+				return cartpkg.ErrCartNotFound
+			},
+		}
+		handler := httphandler.NewCartHandler(repo, newPublisher(nil), pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/checkout", nil)
+		r.SetPathValue("userId", "123")
+		w := httptest.NewRecorder()
+
+		handler.Checkout(w, r)
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d", w.Code)
 		}
 	})
 
-	t.Run("clear error", func(t *testing.T) {
-		cart := &cartpkg.Cart{ID: "c1", UserID: "123"}
+	t.Run("cart changed during checkout", func(t *testing.T) {
 		repo := &RepositoryMock{
-			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) { return cart, nil },
-			ClearCartFunc: func(ctx context.Context, userID string) error {
-				return errors.New("clear failed")
+			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+				return &cartpkg.Cart{ID: "c1", UserID: userID, Version: 2}, nil
+			},
+			CheckoutCartFunc: func(ctx context.Context, c *cartpkg.Cart, evs []outbox.Event, idem *idempotency.Record) error {
+				return cartpkg.ErrVersionConflict
 			},
 		}
-		publisher := &RabbitCartEventsPublisherMock{PublishCartCheckedOutFunc: func(ctx context.Context, c *cartpkg.Cart, _ events.PublishMetadata) error {
-			return nil
-		}}
-		handler := httphandler.NewCartHandler(repo, publisher)
+		handler := httphandler.NewCartHandler(repo, newPublisher(nil), pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
 		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/checkout", nil)
 		r.SetPathValue("userId", "123")
 		w := httptest.NewRecorder()
 
 		handler.Checkout(w, r)
 
-		if w.Code != http.StatusInternalServerError {
-			t.Fatalf("expected 500, got %d", w.Code)
+		if w.Code != http.StatusConflict {
+			t.Fatalf("expected 409, got %d", w.Code)
 		}
-		if len(publisher.PublishCartCheckedOutCalls()) != 1 {
-			t.Fatalf("expected publish to be called once, got %d", len(publisher.PublishCartCheckedOutCalls()))
+	})
+
+	t.Run("stale if-match", func(t *testing.T) {
+		repo := &RepositoryMock{
+			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+				return &cartpkg.Cart{ID: "c1", UserID: userID, Version: 2}, nil
+			},
+		}
+		handler := httphandler.NewCartHandler(repo, newPublisher(nil), pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/checkout", nil)
+		r.Header.Set("If-Match", `"c1.1"`)
+		r.SetPathValue("userId", "123")
+		w := httptest.NewRecorder()
+
+		handler.Checkout(w, r)
+
+		if w.Code != http.StatusPreconditionFailed {
+			t.Fatalf("expected 412, got %d", w.Code)
+		}
+		if len(repo.CheckoutCartCalls()) != 0 {
+			t.Fatalf("did not expect checkout")
 		}
 	})
 
 	t.Run("propagates correlation and causation headers", func(t *testing.T) {
 		cart := &cartpkg.Cart{ID: "c1", UserID: "123"}
 		repo := &RepositoryMock{
-			GetCartFunc:   func(ctx context.Context, userID string) (*cartpkg.Cart, error) { return cart, nil },
-			ClearCartFunc: func(ctx context.Context, userID string) error { return nil },
+			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) { return cart, nil },
+			CheckoutCartFunc: func(ctx context.Context, c *cartpkg.Cart, evs []outbox.Event, idem *idempotency.Record) error {
+				return nil
+			},
 		}
 		var captured events.PublishMetadata
-		publisher := &RabbitCartEventsPublisherMock{PublishCartCheckedOutFunc: func(ctx context.Context, c *cartpkg.Cart, metadata events.PublishMetadata) error {
-			captured = metadata
-			return nil
-		}}
-		handler := httphandler.NewCartHandler(repo, publisher)
+		handler := httphandler.NewCartHandler(repo, newPublisher(&captured), pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
 		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/checkout", nil)
 		r.Header.Set("X-Correlation-Id", "123e4567-e89b-12d3-a456-426614174000")
 		r.Header.Set("X-Causation-Id", "223e4567-e89b-12d3-a456-426614174000")
@@ -329,15 +481,13 @@ func TestCheckout(t *testing.T) {
 	t.Run("generates correlation id when missing", func(t *testing.T) {
 		cart := &cartpkg.Cart{ID: "c1", UserID: "123"}
 		repo := &RepositoryMock{
-			GetCartFunc:   func(ctx context.Context, userID string) (*cartpkg.Cart, error) { return cart, nil },
-			ClearCartFunc: func(ctx context.Context, userID string) error { return nil },
+			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) { return cart, nil },
+			CheckoutCartFunc: func(ctx context.Context, c *cartpkg.Cart, evs []outbox.Event, idem *idempotency.Record) error {
+				return nil
+			},
 		}
 		var captured events.PublishMetadata
-		publisher := &RabbitCartEventsPublisherMock{PublishCartCheckedOutFunc: func(ctx context.Context, c *cartpkg.Cart, metadata events.PublishMetadata) error {
-			captured = metadata
-			return nil
-		}}
-		handler := httphandler.NewCartHandler(repo, publisher)
+		handler := httphandler.NewCartHandler(repo, newPublisher(&captured), pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
 		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/checkout", nil)
 		r.SetPathValue("userId", "123")
 		w := httptest.NewRecorder()
@@ -358,20 +508,89 @@ func TestCheckout(t *testing.T) {
 		}
 	})
 
+	t.Run("price change must be accepted before publishing", func(t *testing.T) {
+		cart := &cartpkg.Cart{ID: "c1", UserID: "123", Items: []cartpkg.Item{{ProductID: "p1", Quantity: 2, Price: usd(100)}}, Total: usd(200)}
+		repo := &RepositoryMock{
+			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+				cp := *cart
+				cp.Items = append([]cartpkg.Item{}, cart.Items...)
+				return &cp, nil
+			},
+			CheckoutCartFunc: func(ctx context.Context, c *cartpkg.Cart, evs []outbox.Event, idem *idempotency.Record) error {
+				return nil
+			},
+		}
+		publisher := newPublisher(nil)
+		handler := httphandler.NewCartHandler(repo, publisher, pricing.NewInMemorySource(map[string]money.Money{"p1": usd(400)}), &IdempotencyStoreMock{}, nil, nil)
+		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/checkout", nil)
+		r.SetPathValue("userId", "123")
+		w := httptest.NewRecorder()
+
+		handler.Checkout(w, r)
+
+		if w.Code != http.StatusConflict {
+			t.Fatalf("expected 409, got %d", w.Code)
+		}
+		var validation cartpkg.Validation
+		if err := json.Unmarshal(w.Body.Bytes(), &validation); err != nil {
+			t.Fatalf("decode validation: %v", err)
+		}
+		if len(validation.Problems) != 1 || validation.Problems[0].Code != cartpkg.ProblemPriceChanged || validation.CorrectedCart.Total != usd(800) {
+			t.Fatalf("expected a price change to the corrected total, got %+v", validation)
+		}
+		if len(repo.CheckoutCartCalls()) != 0 {
+			t.Fatalf("expected no checkout before the correction is accepted")
+		}
+
+		r = httptest.NewRequest(http.MethodPost, "/api/cart/123/checkout", strings.NewReader(`{"acceptCorrections":"`+validation.CorrectionsToken+`"}`))
+		r.SetPathValue("userId", "123")
+		w = httptest.NewRecorder()
+
+		handler.Checkout(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		calls := publisher.CartCheckedOutEventsCalls()
+		if len(calls) != 1 || calls[0].C.Items[0].Price != usd(400) || calls[0].C.Total != usd(800) {
+			t.Fatalf("expected event to carry current prices, got %+v", calls)
+		}
+	})
+
+	t.Run("removed product blocks checkout", func(t *testing.T) {
+		cart := &cartpkg.Cart{ID: "c1", UserID: "123", Items: []cartpkg.Item{{ProductID: "gone", Quantity: 1, Price: usd(100)}}}
+		repo := &RepositoryMock{GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) { return cart, nil }}
+		publisher := &RabbitCartEventsPublisherMock{}
+		handler := httphandler.NewCartHandler(repo, publisher, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/checkout", nil)
+		r.SetPathValue("userId", "123")
+		w := httptest.NewRecorder()
+
+		handler.Checkout(w, r)
+
+		if w.Code != http.StatusConflict {
+			t.Fatalf("expected 409, got %d", w.Code)
+		}
+		if !strings.Contains(w.Body.String(), `"code":"product_not_found"`) {
+			t.Fatalf("expected a product_not_found problem, got %s", w.Body.String())
+		}
+		if len(repo.CheckoutCartCalls()) != 0 {
+			t.Fatalf("expected no checkout when a product is gone")
+		}
+	})
+
 	t.Run("success", func(t *testing.T) {
 		cart := &cartpkg.Cart{ID: "c1", UserID: "123"}
-		cleared := false
+		var recorded []outbox.Event
 		repo := &RepositoryMock{
 			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) { return cart, nil },
-			ClearCartFunc: func(ctx context.Context, userID string) error {
-				cleared = true
+			CheckoutCartFunc: func(ctx context.Context, c *cartpkg.Cart, evs []outbox.Event, idem *idempotency.Record) error {
+				recorded = evs
 				return nil
 			},
 		}
-		publisher := &RabbitCartEventsPublisherMock{PublishCartCheckedOutFunc: func(ctx context.Context, c *cartpkg.Cart, _ events.PublishMetadata) error {
-			return nil
-		}}
-		handler := httphandler.NewCartHandler(repo, publisher)
+		publisher := newPublisher(nil)
+		handler := httphandler.NewCartHandler(repo, publisher, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
 		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/checkout", nil)
 		r.SetPathValue("userId", "123")
 		w := httptest.NewRecorder()
@@ -381,11 +600,631 @@ func TestCheckout(t *testing.T) {
 		if w.Code != http.StatusOK {
 			t.Fatalf("expected 200, got %d", w.Code)
 		}
-		if len(publisher.PublishCartCheckedOutCalls()) != 1 {
-			t.Fatalf("expected publish to be called once, got %d", len(publisher.PublishCartCheckedOutCalls()))
+		if len(repo.CheckoutCartCalls()) != 1 {
+			t.Fatalf("expected checkout to be called once, got %d", len(repo.CheckoutCartCalls()))
+		}
+		if len(recorded) != 1 || recorded[0].PartitionKey != "c1" || recorded[0].RoutingKey != events.CartCheckedOutRoutingKey {
+			t.Fatalf("expected cart event to be written with the checkout, got %+v", recorded)
+		}
+		if len(repo.ClearCartCalls()) != 0 {
+			t.Fatalf("did not expect a separate ClearCart call")
+		}
+	})
+}
+
+func TestCheckoutIdempotency(t *testing.T) {
+	newPublisher := func() *RabbitCartEventsPublisherMock {
+		return &RabbitCartEventsPublisherMock{CartCheckedOutEventsFunc: func(c *cartpkg.Cart, metadata events.PublishMetadata) []outbox.Event {
+			return []outbox.Event{{PartitionKey: c.ID, RoutingKey: events.CartCheckedOutRoutingKey}}
+		}}
+	}
+	newRequest := func(key string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/checkout", nil)
+		r.SetPathValue("userId", "123")
+		if key != "" {
+			r.Header.Set("Idempotency-Key", key)
+		}
+		return r
+	}
+
+	t.Run("stores response with the checkout", func(t *testing.T) {
+		var stored *idempotency.Record
+		repo := &RepositoryMock{
+			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+				return &cartpkg.Cart{ID: "c1", UserID: userID}, nil
+			},
+			CheckoutCartFunc: func(ctx context.Context, c *cartpkg.Cart, evs []outbox.Event, idem *idempotency.Record) error {
+				stored = idem
+				return nil
+			},
+		}
+		idem := &IdempotencyStoreMock{LookupFunc: func(ctx context.Context, userID, key string) (*idempotency.Record, error) {
+			return nil, nil
+		}}
+		handler := httphandler.NewCartHandler(repo, newPublisher(), pricing.NewInMemorySource(nil), idem, nil, nil)
+		w := httptest.NewRecorder()
+
+		handler.Checkout(w, newRequest("key-1"))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+		if stored == nil || stored.UserID != "123" || stored.Key != "key-1" || stored.StatusCode != http.StatusOK {
+			t.Fatalf("expected idempotency record to be stored, got %+v", stored)
+		}
+		if string(stored.Body) != w.Body.String() {
+			t.Fatalf("expected stored body %q to match response %q", stored.Body, w.Body.String())
+		}
+		if got := w.Header().Get("Idempotency-Key"); got != "key-1" {
+			t.Fatalf("expected key to be echoed, got %q", got)
+		}
+	})
+
+	t.Run("replays stored response", func(t *testing.T) {
+		repo := &RepositoryMock{}
+		idem := &IdempotencyStoreMock{LookupFunc: func(ctx context.Context, userID, key string) (*idempotency.Record, error) {
+			return &idempotency.Record{UserID: userID, Key: key, StatusCode: http.StatusOK, Body: []byte(`{"status":"checkout completed"}`)}, nil
+		}}
+		handler := httphandler.NewCartHandler(repo, &RabbitCartEventsPublisherMock{}, pricing.NewInMemorySource(nil), idem, nil, nil)
+		w := httptest.NewRecorder()
+
+		handler.Checkout(w, newRequest("key-1"))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+		if w.Body.String() != `{"status":"checkout completed"}` {
+			t.Fatalf("unexpected body %q", w.Body.String())
+		}
+		if w.Header().Get("Idempotent-Replayed") != "true" {
+			t.Fatalf("expected replay header")
+		}
+		if len(repo.GetCartCalls()) != 0 || len(repo.CheckoutCartCalls()) != 0 {
+			t.Fatalf("did not expect the cart to be touched on replay")
+		}
+	})
+
+	t.Run("replays concurrent checkout with the same key", func(t *testing.T) {
+		lookups := 0
+		repo := &RepositoryMock{
+			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+				return &cartpkg.Cart{ID: "c1", UserID: userID}, nil
+			},
+			CheckoutCartFunc: func(ctx context.Context, c *cartpkg.Cart, evs []outbox.Event, idem *idempotency.Record) error {
+				return cartpkg.ErrCartNotFound
+			},
+		}
+		idem := &IdempotencyStoreMock{LookupFunc: func(ctx context.Context, userID, key string) (*idempotency.Record, error) {
+			lookups++
+			if lookups == 1 {
+				return nil, nil
+			}
+			return &idempotency.Record{StatusCode: http.StatusOK, Body: []byte(`{"status":"checkout completed"}`)}, nil
+		}}
+		handler := httphandler.NewCartHandler(repo, newPublisher(), pricing.NewInMemorySource(nil), idem, nil, nil)
+		w := httptest.NewRecorder()
+
+		handler.Checkout(w, newRequest("key-1"))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected replayed 200, got %d", w.Code)
+		}
+	})
+
+	t.Run("without key nothing is stored", func(t *testing.T) {
+		var stored *idempotency.Record
+		repo := &RepositoryMock{
+			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+				return &cartpkg.Cart{ID: "c1", UserID: userID}, nil
+			},
+			CheckoutCartFunc: func(ctx context.Context, c *cartpkg.Cart, evs []outbox.Event, idem *idempotency.Record) error {
+				stored = idem
+				return nil
+			},
+		}
+		idem := &IdempotencyStoreMock{}
+		handler := httphandler.NewCartHandler(repo, newPublisher(), pricing.NewInMemorySource(nil), idem, nil, nil)
+		w := httptest.NewRecorder()
+
+		handler.Checkout(w, newRequest(""))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+		if stored != nil || len(idem.LookupCalls()) != 0 {
+			t.Fatalf("did not expect idempotency handling without a key")
+		}
+	})
+
+	t.Run("key too long", func(t *testing.T) {
+		handler := httphandler.NewCartHandler(&RepositoryMock{}, &RabbitCartEventsPublisherMock{}, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		w := httptest.NewRecorder()
+
+		handler.Checkout(w, newRequest(strings.Repeat("k", 256)))
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("lookup error", func(t *testing.T) {
+		idem := &IdempotencyStoreMock{LookupFunc: func(ctx context.Context, userID, key string) (*idempotency.Record, error) {
+			return nil, errors.New("db error")
+		}}
+		handler := httphandler.NewCartHandler(&RepositoryMock{}, &RabbitCartEventsPublisherMock{}, pricing.NewInMemorySource(nil), idem, nil, nil)
+		w := httptest.NewRecorder()
+
+		handler.Checkout(w, newRequest("key-1"))
+
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("expected 500, got %d", w.Code)
+		}
+	})
+}
+
+func TestUpdateItem(t *testing.T) {
+	t.Run("missing product id", func(t *testing.T) {
+		handler := httphandler.NewCartHandler(&RepositoryMock{}, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		r := httptest.NewRequest(http.MethodPatch, "/api/cart/123/items/", bytes.NewBufferString(`{"quantity":1}`))
+		r.SetPathValue("userId", "123")
+		w := httptest.NewRecorder()
+
+		handler.UpdateItem(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("rejects non-positive quantity", func(t *testing.T) {
+		for body, status := range map[string]int{
+			`{"quantity":0}`:  http.StatusUnprocessableEntity,
+			`{"quantity":-2}`: http.StatusUnprocessableEntity,
+			`{}`:              http.StatusBadRequest,
+		} {
+			handler := httphandler.NewCartHandler(&RepositoryMock{}, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+			r := httptest.NewRequest(http.MethodPatch, "/api/cart/123/items/p1", bytes.NewBufferString(body))
+			r.SetPathValue("userId", "123")
+			r.SetPathValue("productId", "p1")
+			w := httptest.NewRecorder()
+
+			handler.UpdateItem(w, r)
+
+			if w.Code != status {
+				t.Fatalf("body %s: expected %d, got %d", body, status, w.Code)
+			}
+		}
+	})
+
+	t.Run("item not found", func(t *testing.T) {
+		existing := &cartpkg.Cart{ID: "c1", UserID: "123", Items: []cartpkg.Item{{ProductID: "p1", Quantity: 1, Price: usd(500)}}}
+		repo := &RepositoryMock{GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) { return existing, nil }}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		r := httptest.NewRequest(http.MethodPatch, "/api/cart/123/items/p2", bytes.NewBufferString(`{"quantity":1}`))
+		r.SetPathValue("userId", "123")
+		r.SetPathValue("productId", "p2")
+		w := httptest.NewRecorder()
+
+		handler.UpdateItem(w, r)
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d", w.Code)
+		}
+	})
+
+	t.Run("sets quantity and recalculates total", func(t *testing.T) {
+		existing := &cartpkg.Cart{ID: "c1", UserID: "123", Items: []cartpkg.Item{
+			{ProductID: "p1", Quantity: 4, Price: usd(500)},
+			{ProductID: "p2", Quantity: 1, Price: usd(200)},
+		}, Total: usd(2200)}
+		var saved *cartpkg.Cart
+		repo := &RepositoryMock{
+			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) { return existing, nil },
+			UpsertCartFunc: func(ctx context.Context, c *cartpkg.Cart, activity cartpkg.ActivityFunc) error {
+				saved = c
+				return nil
+			},
+		}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		r := httptest.NewRequest(http.MethodPatch, "/api/cart/123/items/p1", bytes.NewBufferString(`{"quantity":1}`))
+		r.SetPathValue("userId", "123")
+		r.SetPathValue("productId", "p1")
+		w := httptest.NewRecorder()
+
+		handler.UpdateItem(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+		if saved == nil || saved.Items[0].Quantity != 1 {
+			t.Fatalf("expected quantity to be set to 1, got %+v", saved)
+		}
+		if saved.Total != usd(700) {
+			t.Fatalf("expected total 7, got %v", saved.Total)
+		}
+	})
+
+	t.Run("stale if-match", func(t *testing.T) {
+		existing := &cartpkg.Cart{ID: "c1", UserID: "123", Version: 5, Items: []cartpkg.Item{{ProductID: "p1", Quantity: 1, Price: usd(500)}}}
+		repo := &RepositoryMock{GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) { return existing, nil }}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		r := httptest.NewRequest(http.MethodPatch, "/api/cart/123/items/p1", bytes.NewBufferString(`{"quantity":2}`))
+		r.Header.Set("If-Match", `"c1.4"`)
+		r.SetPathValue("userId", "123")
+		r.SetPathValue("productId", "p1")
+		w := httptest.NewRecorder()
+
+		handler.UpdateItem(w, r)
+
+		if w.Code != http.StatusPreconditionFailed {
+			t.Fatalf("expected 412, got %d", w.Code)
+		}
+	})
+
+	t.Run("conditional write loses race", func(t *testing.T) {
+		existing := &cartpkg.Cart{ID: "c1", UserID: "123", Version: 5, Items: []cartpkg.Item{{ProductID: "p1", Quantity: 1, Price: usd(500)}}}
+		repo := &RepositoryMock{
+			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) { return existing, nil },
+			UpsertCartFunc: func(ctx context.Context, c *cartpkg.Cart, activity cartpkg.ActivityFunc) error {
+				return cartpkg.ErrVersionConflict
+			},
+		}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		r := httptest.NewRequest(http.MethodPatch, "/api/cart/123/items/p1", bytes.NewBufferString(`{"quantity":2}`))
+		r.Header.Set("If-Match", `"c1.5"`)
+		r.SetPathValue("userId", "123")
+		r.SetPathValue("productId", "p1")
+		w := httptest.NewRecorder()
+
+		handler.UpdateItem(w, r)
+
+		if w.Code != http.StatusPreconditionFailed {
+			t.Fatalf("expected 412, got %d", w.Code)
+		}
+		if len(repo.UpsertCartCalls()) != 1 {
+			t.Fatalf("did not expect a conditional write to be retried")
+		}
+	})
+
+	t.Run("unconditional write retries on conflict", func(t *testing.T) {
+		version := int64(1)
+		repo := &RepositoryMock{
+			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+				return &cartpkg.Cart{ID: "c1", UserID: "123", Version: version, Items: []cartpkg.Item{{ProductID: "p1", Quantity: 1, Price: usd(500)}}}, nil
+			},
+			UpsertCartFunc: func(ctx context.Context, c *cartpkg.Cart, activity cartpkg.ActivityFunc) error {
+				if c.Version == 1 {
+					// someone else saved in between
+					version = 2
+					return cartpkg.ErrVersionConflict
+				}
+				c.Version++
+				return nil
+			},
+		}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		r := httptest.NewRequest(http.MethodPatch, "/api/cart/123/items/p1", bytes.NewBufferString(`{"quantity":2}`))
+		r.SetPathValue("userId", "123")
+		r.SetPathValue("productId", "p1")
+		w := httptest.NewRecorder()
+
+		handler.UpdateItem(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+		if len(repo.GetCartCalls()) != 2 || len(repo.UpsertCartCalls()) != 2 {
+			t.Fatalf("expected one retry, got %d loads and %d saves", len(repo.GetCartCalls()), len(repo.UpsertCartCalls()))
+		}
+		if got := w.Header().Get("ETag"); got != `"c1.3"` {
+			t.Fatalf("unexpected ETag %q", got)
+		}
+	})
+
+	t.Run("unconditional write gives up after repeated conflicts", func(t *testing.T) {
+		repo := &RepositoryMock{
+			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+				return &cartpkg.Cart{ID: "c1", UserID: "123", Version: 1, Items: []cartpkg.Item{{ProductID: "p1", Quantity: 1, Price: usd(500)}}}, nil
+			},
+			UpsertCartFunc: func(ctx context.Context, c *cartpkg.Cart, activity cartpkg.ActivityFunc) error {
+				return cartpkg.ErrVersionConflict
+			},
+		}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		r := httptest.NewRequest(http.MethodPatch, "/api/cart/123/items/p1", bytes.NewBufferString(`{"quantity":2}`))
+		r.SetPathValue("userId", "123")
+		r.SetPathValue("productId", "p1")
+		w := httptest.NewRecorder()
+
+		handler.UpdateItem(w, r)
+
+		if w.Code != http.StatusConflict {
+			t.Fatalf("expected 409, got %d", w.Code)
+		}
+	})
+}
+
+func TestRemoveItem(t *testing.T) {
+	t.Run("cart not found", func(t *testing.T) {
+		repo := &RepositoryMock{GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) { return nil, nil }}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		r := httptest.NewRequest(http.MethodDelete, "/api/cart/123/items/p1", nil)
+		r.SetPathValue("userId", "123")
+		r.SetPathValue("productId", "p1")
+		w := httptest.NewRecorder()
+
+		handler.RemoveItem(w, r)
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d", w.Code)
+		}
+	})
+
+	t.Run("removes line and recalculates total", func(t *testing.T) {
+		existing := &cartpkg.Cart{ID: "c1", UserID: "123", Items: []cartpkg.Item{
+			{ProductID: "p1", Quantity: 2, Price: usd(500)},
+			{ProductID: "p2", Quantity: 3, Price: usd(200)},
+		}, Total: usd(1600)}
+		var saved *cartpkg.Cart
+		repo := &RepositoryMock{
+			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) { return existing, nil },
+			UpsertCartFunc: func(ctx context.Context, c *cartpkg.Cart, activity cartpkg.ActivityFunc) error {
+				saved = c
+				return nil
+			},
+		}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		r := httptest.NewRequest(http.MethodDelete, "/api/cart/123/items/p1", nil)
+		r.SetPathValue("userId", "123")
+		r.SetPathValue("productId", "p1")
+		w := httptest.NewRecorder()
+
+		handler.RemoveItem(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+		if saved == nil || len(saved.Items) != 1 || saved.Items[0].ProductID != "p2" {
+			t.Fatalf("expected only p2 to remain, got %+v", saved)
+		}
+		if saved.Total != usd(600) {
+			t.Fatalf("expected total 6, got %v", saved.Total)
+		}
+	})
+}
+
+func TestClearCart(t *testing.T) {
+	t.Run("clear error", func(t *testing.T) {
+		repo := &RepositoryMock{ClearCartFunc: func(ctx context.Context, userID string, expectedVersion int64, activity cartpkg.ActivityFunc) error {
+			return errors.New("clear failed")
+		}}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		r := httptest.NewRequest(http.MethodDelete, "/api/cart/123", nil)
+		r.SetPathValue("userId", "123")
+		w := httptest.NewRecorder()
+
+		handler.ClearCart(w, r)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("expected 500, got %d", w.Code)
+		}
+	})
+
+	t.Run("success", func(t *testing.T) {
+		repo := &RepositoryMock{ClearCartFunc: func(ctx context.Context, userID string, expectedVersion int64, activity cartpkg.ActivityFunc) error {
+			return nil
+		}}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		r := httptest.NewRequest(http.MethodDelete, "/api/cart/123", nil)
+		r.SetPathValue("userId", "123")
+		w := httptest.NewRecorder()
+
+		handler.ClearCart(w, r)
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected 204, got %d", w.Code)
+		}
+		if len(repo.ClearCartCalls()) != 1 || repo.ClearCartCalls()[0].UserID != "123" || repo.ClearCartCalls()[0].ExpectedVersion != 0 {
+			t.Fatalf("expected unconditional ClearCart for user 123")
+		}
+	})
+
+	t.Run("if-match passes version", func(t *testing.T) {
+		repo := &RepositoryMock{
+			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+				return &cartpkg.Cart{ID: "c1", Version: 7}, nil
+			},
+			ClearCartFunc: func(ctx context.Context, userID string, expectedVersion int64, activity cartpkg.ActivityFunc) error {
+				return nil
+			},
+		}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		r := httptest.NewRequest(http.MethodDelete, "/api/cart/123", nil)
+		r.Header.Set("If-Match", `"c1.7"`)
+		r.SetPathValue("userId", "123")
+		w := httptest.NewRecorder()
+
+		handler.ClearCart(w, r)
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected 204, got %d", w.Code)
+		}
+		if calls := repo.ClearCartCalls(); len(calls) != 1 || calls[0].ExpectedVersion != 7 {
+			t.Fatalf("expected conditional ClearCart with version 7, got %+v", calls)
+		}
+	})
+
+	t.Run("if-match mismatch", func(t *testing.T) {
+		repo := &RepositoryMock{
+			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+				return &cartpkg.Cart{ID: "c1", Version: 8}, nil
+			},
+		}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		r := httptest.NewRequest(http.MethodDelete, "/api/cart/123", nil)
+		r.Header.Set("If-Match", `"c1.7"`)
+		r.SetPathValue("userId", "123")
+		w := httptest.NewRecorder()
+
+		handler.ClearCart(w, r)
+
+		if w.Code != http.StatusPreconditionFailed {
+			t.Fatalf("expected 412, got %d", w.Code)
+		}
+		if len(repo.ClearCartCalls()) != 0 {
+			t.Fatalf("did not expect the cart to be cleared")
+		}
+	})
+}
+
+func TestCreateGuestCart(t *testing.T) {
+	var saved *cartpkg.Cart
+	repo := &RepositoryMock{UpsertCartFunc: func(ctx context.Context, c *cartpkg.Cart, activity cartpkg.ActivityFunc) error {
+		saved = c
+		c.ID = "c1"
+		c.Version = 1
+		return nil
+	}}
+	handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+	r := httptest.NewRequest(http.MethodPost, "/api/cart/guests", nil)
+	w := httptest.NewRecorder()
+
+	handler.CreateGuestCart(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Code)
+	}
+	if saved == nil || !cartpkg.IsGuestID(saved.UserID) {
+		t.Fatalf("expected a guest cart to be stored, got %+v", saved)
+	}
+	var resp cartpkg.Cart
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.UserID != saved.UserID || w.Header().Get("ETag") != `"c1.1"` {
+		t.Fatalf("unexpected response %+v / %q", resp, w.Header().Get("ETag"))
+	}
+}
+
+func TestMergeCart(t *testing.T) {
+	guestID := cartpkg.NewGuestID()
+	newRequest := func(body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/merge", bytes.NewBufferString(body))
+		r.SetPathValue("userId", "123")
+		return r
+	}
+	carts := func(user *cartpkg.Cart) func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+		return func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+			if userID == guestID {
+				return &cartpkg.Cart{ID: "g1", UserID: guestID, Version: 1, Items: []cartpkg.Item{
+					{ProductID: "p1", Quantity: 3, Price: usd(200)},
+					{ProductID: "p2", Quantity: 1, Price: usd(400)},
+				}}, nil
+			}
+			return user, nil
+		}
+	}
+
+	t.Run("rejects invalid guest cart id", func(t *testing.T) {
+		handler := httphandler.NewCartHandler(&RepositoryMock{}, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		w := httptest.NewRecorder()
+
+		handler.MergeCart(w, newRequest(`{"guestCartId":"456"}`))
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("rejects unknown strategy", func(t *testing.T) {
+		handler := httphandler.NewCartHandler(&RepositoryMock{}, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		w := httptest.NewRecorder()
+
+		handler.MergeCart(w, newRequest(`{"guestCartId":"`+guestID+`","strategy":"newest"}`))
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("guest cart not found", func(t *testing.T) {
+		repo := &RepositoryMock{GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) { return nil, nil }}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		w := httptest.NewRecorder()
+
+		handler.MergeCart(w, newRequest(`{"guestCartId":"`+guestID+`"}`))
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d", w.Code)
+		}
+	})
+
+	t.Run("sums quantities by default", func(t *testing.T) {
+		user := &cartpkg.Cart{ID: "c1", UserID: "123", Version: 4, Items: []cartpkg.Item{{ProductID: "p1", Quantity: 2, Price: usd(200)}}}
+		var merged, deleted *cartpkg.Cart
+		repo := &RepositoryMock{
+			GetCartFunc: carts(user),
+			MergeCartsFunc: func(ctx context.Context, c *cartpkg.Cart, guest *cartpkg.Cart, activity cartpkg.ActivityFunc) error {
+				merged, deleted = c, guest
+				return nil
+			},
+		}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		w := httptest.NewRecorder()
+
+		handler.MergeCart(w, newRequest(`{"guestCartId":"`+guestID+`"}`))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+		if merged == nil || len(merged.Items) != 2 || merged.Items[0].Quantity != 5 {
+			t.Fatalf("unexpected merged cart %+v", merged)
+		}
+		if merged.Total != usd(1400) {
+			t.Fatalf("expected total 14, got %v", merged.Total)
+		}
+		if deleted == nil || deleted.ID != "g1" {
+			t.Fatalf("expected guest cart to be removed, got %+v", deleted)
+		}
+	})
+
+	t.Run("creates user cart from guest cart", func(t *testing.T) {
+		var merged *cartpkg.Cart
+		repo := &RepositoryMock{
+			GetCartFunc: carts(nil),
+			MergeCartsFunc: func(ctx context.Context, c *cartpkg.Cart, guest *cartpkg.Cart, activity cartpkg.ActivityFunc) error {
+				merged = c
+				return nil
+			},
+		}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		w := httptest.NewRecorder()
+
+		handler.MergeCart(w, newRequest(`{"guestCartId":"`+guestID+`","strategy":"max"}`))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+		if merged == nil || merged.UserID != "123" || merged.Version != 0 || len(merged.Items) != 2 {
+			t.Fatalf("expected a new user cart with the guest lines, got %+v", merged)
+		}
+	})
+
+	t.Run("stale if-match", func(t *testing.T) {
+		user := &cartpkg.Cart{ID: "c1", UserID: "123", Version: 4}
+		repo := &RepositoryMock{GetCartFunc: carts(user)}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, nil)
+		r := newRequest(`{"guestCartId":"` + guestID + `"}`)
+		r.Header.Set("If-Match", `"c1.3"`)
+		w := httptest.NewRecorder()
+
+		handler.MergeCart(w, r)
+
+		if w.Code != http.StatusPreconditionFailed {
+			t.Fatalf("expected 412, got %d", w.Code)
 		}
-		if !cleared {
-			t.Fatalf("expected cart to be cleared")
+		if len(repo.MergeCartsCalls()) != 0 {
+			t.Fatalf("did not expect carts to be merged")
 		}
 	})
 }
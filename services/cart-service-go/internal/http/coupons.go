@@ -0,0 +1,186 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/problem"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/promotion"
+)
+
+var (
+	errCouponApplied    = errors.New("coupon already applied")
+	errCouponNotApplied = errors.New("coupon not applied")
+)
+
+// ApplyCoupon enters a coupon code on the cart. The code is only accepted if
+// its promotion applies to the cart as it is now.
+func (h *CartHandler) ApplyCoupon(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("userId")
+	if userID == "" {
+		writeInvalid(w, r, "userId", problem.FieldRequired, "missing userId")
+		return
+	}
+
+	var body struct {
+		Code string `json:"code"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "invalid json")
+		return
+	}
+	code := promotion.NormalizeCode(body.Code)
+	if code == "" {
+		writeInvalid(w, r, "code", problem.FieldRequired, "missing code")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	p, err := h.promotions.GetByCode(ctx, code)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to load coupon")
+		return
+	}
+	if p == nil {
+		writeError(w, r, http.StatusNotFound, codeCouponNotFound, "coupon not found")
+		return
+	}
+
+	c, err := h.updateCart(ctx, userID, parseIfMatch(r), h.activity(r), func(c *cart.Cart) error {
+		if c.HasCoupon(code) {
+			return errCouponApplied
+		}
+		used, err := h.promotions.Redemptions(ctx, p.ID, c.UserID)
+		if err != nil {
+			return err
+		}
+		if _, err := p.Evaluate(promotionLines(c), c.Subtotal, time.Now(), used); err != nil {
+			return err
+		}
+		c.Coupons = append(c.Coupons, code)
+		return nil
+	})
+	if err != nil {
+		writeCouponError(w, r, err)
+		return
+	}
+
+	h.writeCart(ctx, w, r, http.StatusOK, c)
+}
+
+// RemoveCoupon takes a coupon code off the cart.
+func (h *CartHandler) RemoveCoupon(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("userId")
+	if userID == "" {
+		writeInvalid(w, r, "userId", problem.FieldRequired, "missing userId")
+		return
+	}
+	code := promotion.NormalizeCode(r.PathValue("code"))
+	if code == "" {
+		writeInvalid(w, r, "code", problem.FieldRequired, "missing code")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	c, err := h.updateCart(ctx, userID, parseIfMatch(r), h.activity(r), func(c *cart.Cart) error {
+		for i, cc := range c.Coupons {
+			if cc == code {
+				c.Coupons = append(c.Coupons[:i], c.Coupons[i+1:]...)
+				return nil
+			}
+		}
+		return errCouponNotApplied
+	})
+	if err != nil {
+		writeCouponError(w, r, err)
+		return
+	}
+
+	h.writeCart(ctx, w, r, http.StatusOK, c)
+}
+
+// applyPromotions works out the adjustments for the cart's coupons and
+// recalculates its total. Coupons that no longer apply, say because the cart
+// dropped below the minimum spend, are kept on the cart but give no discount.
+// Any tax worked out before is dropped, as it depends on the discounts.
+func (h *CartHandler) applyPromotions(ctx context.Context, c *cart.Cart) error {
+	c.Adjustments = nil
+	c.Tax = nil
+	if err := c.RecalculateTotal(); err != nil {
+		return err
+	}
+	if len(c.Coupons) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	lines := promotionLines(c)
+	for _, code := range c.Coupons {
+		p, err := h.promotions.GetByCode(ctx, code)
+		if err != nil {
+			return err
+		}
+		if p == nil {
+			continue
+		}
+		used, err := h.promotions.Redemptions(ctx, p.ID, c.UserID)
+		if err != nil {
+			return err
+		}
+		discount, err := p.Evaluate(lines, c.Subtotal, now, used)
+		if err != nil {
+			if isPromotionRejection(err) {
+				continue
+			}
+			return err
+		}
+		discount.Amount = -discount.Amount
+		c.Adjustments = append(c.Adjustments, cart.Adjustment{
+			Code:        code,
+			PromotionID: p.ID,
+			Description: p.Description,
+			Amount:      discount,
+		})
+	}
+	return c.RecalculateTotal()
+}
+
+func promotionLines(c *cart.Cart) []promotion.Line {
+	lines := make([]promotion.Line, 0, len(c.Items))
+	for _, it := range c.Items {
+		lines = append(lines, promotion.Line{ProductID: it.ProductID, Quantity: it.Quantity, UnitPrice: it.Price})
+	}
+	return lines
+}
+
+// isPromotionRejection reports whether err means the promotion can't be used
+// on the cart, as opposed to a failure to find out.
+func isPromotionRejection(err error) bool {
+	return errors.Is(err, promotion.ErrNotActive) ||
+		errors.Is(err, promotion.ErrMinSpendNotMet) ||
+		errors.Is(err, promotion.ErrNotApplicable) ||
+		errors.Is(err, promotion.ErrUsageLimitReached)
+}
+
+// writeCouponError maps errors from the coupon endpoints to responses.
+func writeCouponError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case isPromotionRejection(err):
+		writeError(w, r, http.StatusUnprocessableEntity, codeCouponNotApplicable, err.Error())
+	case errors.Is(err, errCouponApplied):
+		writeError(w, r, http.StatusConflict, codeCouponAlreadyApplied, "coupon already applied")
+	case errors.Is(err, errCouponNotApplied):
+		writeError(w, r, http.StatusNotFound, codeCouponNotApplied, "coupon not applied to cart")
+	default:
+		writeCartUpdateError(w, r, err)
+	}
+}
@@ -0,0 +1,247 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cartpkg "github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/events"
+	httphandler "github.com/andreasstove999/ecommerce-system/cart-service-go/internal/http"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/idempotency"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/outbox"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/pricing"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/promotion"
+)
+
+func tenPercentOff() *promotion.Promotion {
+	return &promotion.Promotion{
+		ID:          "promo-1",
+		Code:        "SAVE10",
+		Description: "10% off",
+		Kind:        promotion.KindPercentage,
+		PercentOff:  10,
+		MinSpend:    usd(1000),
+		Active:      true,
+	}
+}
+
+func promotionStore(p *promotion.Promotion, used int) *PromotionStoreMock {
+	return &PromotionStoreMock{
+		GetByCodeFunc: func(ctx context.Context, code string) (*promotion.Promotion, error) {
+			if p == nil || code != p.Code {
+				return nil, nil
+			}
+			return p, nil
+		},
+		RedemptionsFunc: func(ctx context.Context, promotionID, userID string) (int, error) {
+			return used, nil
+		},
+	}
+}
+
+func applyCouponRequest(code string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/api/cart/123/coupons", strings.NewReader(`{"code":"`+code+`"}`))
+	r.SetPathValue("userId", "123")
+	return r
+}
+
+func TestApplyCoupon(t *testing.T) {
+	cartWith := func(subtotal int64, coupons ...string) func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+		return func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+			return &cartpkg.Cart{
+				ID:       "c1",
+				UserID:   userID,
+				Items:    []cartpkg.Item{{ProductID: "p1", Quantity: 1, Price: usd(subtotal)}},
+				Coupons:  coupons,
+				Subtotal: usd(subtotal),
+				Version:  1,
+			}, nil
+		}
+	}
+
+	t.Run("unknown coupon", func(t *testing.T) {
+		handler := httphandler.NewCartHandler(&RepositoryMock{}, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, promotionStore(nil, 0), nil)
+		w := httptest.NewRecorder()
+
+		handler.ApplyCoupon(w, applyCouponRequest("NOPE"))
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d", w.Code)
+		}
+	})
+
+	t.Run("below minimum spend", func(t *testing.T) {
+		repo := &RepositoryMock{GetCartFunc: cartWith(500)}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, promotionStore(tenPercentOff(), 0), nil)
+		w := httptest.NewRecorder()
+
+		handler.ApplyCoupon(w, applyCouponRequest("SAVE10"))
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("expected 422, got %d", w.Code)
+		}
+		if len(repo.UpsertCartCalls()) != 0 {
+			t.Fatalf("did not expect the cart to be saved")
+		}
+	})
+
+	t.Run("usage limit reached", func(t *testing.T) {
+		p := tenPercentOff()
+		p.MaxUsesPerUser = 1
+		repo := &RepositoryMock{GetCartFunc: cartWith(2000)}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, promotionStore(p, 1), nil)
+		w := httptest.NewRecorder()
+
+		handler.ApplyCoupon(w, applyCouponRequest("SAVE10"))
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("expected 422, got %d", w.Code)
+		}
+	})
+
+	t.Run("already applied", func(t *testing.T) {
+		repo := &RepositoryMock{GetCartFunc: cartWith(2000, "SAVE10")}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, promotionStore(tenPercentOff(), 0), nil)
+		w := httptest.NewRecorder()
+
+		handler.ApplyCoupon(w, applyCouponRequest("save10"))
+
+		if w.Code != http.StatusConflict {
+			t.Fatalf("expected 409, got %d", w.Code)
+		}
+	})
+
+	t.Run("applies discount to the total", func(t *testing.T) {
+		repo := &RepositoryMock{
+			GetCartFunc:    cartWith(2000),
+			UpsertCartFunc: func(ctx context.Context, c *cartpkg.Cart, activity cartpkg.ActivityFunc) error { return nil },
+		}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, promotionStore(tenPercentOff(), 0), nil)
+		w := httptest.NewRecorder()
+
+		handler.ApplyCoupon(w, applyCouponRequest(" save10 "))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		saved := repo.UpsertCartCalls()
+		if len(saved) != 1 || len(saved[0].C.Coupons) != 1 || saved[0].C.Coupons[0] != "SAVE10" {
+			t.Fatalf("expected the normalised code to be saved, got %+v", saved)
+		}
+
+		var got cartpkg.Cart
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if got.Subtotal != usd(2000) || got.Total != usd(1800) {
+			t.Fatalf("expected subtotal 20.00 and total 18.00, got %+v and %+v", got.Subtotal, got.Total)
+		}
+		if len(got.Adjustments) != 1 || got.Adjustments[0].Amount != usd(-200) || got.Adjustments[0].PromotionID != "promo-1" {
+			t.Fatalf("unexpected adjustments %+v", got.Adjustments)
+		}
+	})
+}
+
+func TestRemoveCoupon(t *testing.T) {
+	newRequest := func(code string) *http.Request {
+		r := httptest.NewRequest(http.MethodDelete, "/api/cart/123/coupons/"+code, nil)
+		r.SetPathValue("userId", "123")
+		r.SetPathValue("code", code)
+		return r
+	}
+	repo := &RepositoryMock{
+		GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+			return &cartpkg.Cart{ID: "c1", UserID: userID, Items: []cartpkg.Item{}, Coupons: []string{"SAVE10"}}, nil
+		},
+		UpsertCartFunc: func(ctx context.Context, c *cartpkg.Cart, activity cartpkg.ActivityFunc) error { return nil },
+	}
+	handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, promotionStore(tenPercentOff(), 0), nil)
+
+	t.Run("not on cart", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		handler.RemoveCoupon(w, newRequest("OTHER"))
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d", w.Code)
+		}
+	})
+
+	t.Run("removes code", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		handler.RemoveCoupon(w, newRequest("save10"))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+		saved := repo.UpsertCartCalls()
+		if len(saved) != 1 || len(saved[0].C.Coupons) != 0 {
+			t.Fatalf("expected the coupon to be removed, got %+v", saved)
+		}
+	})
+}
+
+func TestCheckoutWithCoupon(t *testing.T) {
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/api/cart/123/checkout", nil)
+		r.SetPathValue("userId", "123")
+		return r
+	}
+	newRepo := func(checkoutErr error) *RepositoryMock {
+		return &RepositoryMock{
+			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+				return &cartpkg.Cart{
+					ID:      "c1",
+					UserID:  userID,
+					Items:   []cartpkg.Item{{ProductID: "p1", Quantity: 2, Price: usd(1000)}},
+					Coupons: []string{"SAVE10"},
+				}, nil
+			},
+			CheckoutCartFunc: func(ctx context.Context, c *cartpkg.Cart, evs []outbox.Event, idem *idempotency.Record) error {
+				return checkoutErr
+			},
+		}
+	}
+
+	t.Run("event carries the discounted total", func(t *testing.T) {
+		var published *cartpkg.Cart
+		publisher := &RabbitCartEventsPublisherMock{CartCheckedOutEventsFunc: func(c *cartpkg.Cart, metadata events.PublishMetadata) []outbox.Event {
+			published = c
+			return nil
+		}}
+		repo := newRepo(nil)
+		handler := httphandler.NewCartHandler(repo, publisher, pricing.NewInMemorySource(map[string]money.Money{"p1": usd(1000)}), &IdempotencyStoreMock{}, promotionStore(tenPercentOff(), 0), nil)
+		w := httptest.NewRecorder()
+
+		handler.Checkout(w, newRequest())
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+		if published == nil || published.Total != usd(1800) || len(published.Adjustments) != 1 {
+			t.Fatalf("expected a discounted cart to be published, got %+v", published)
+		}
+		if calls := repo.CheckoutCartCalls(); len(calls) != 1 || len(calls[0].C.Adjustments) != 1 {
+			t.Fatalf("expected the adjustment to be redeemed with the checkout, got %+v", calls)
+		}
+	})
+
+	t.Run("coupon used up concurrently", func(t *testing.T) {
+		handler := httphandler.NewCartHandler(newRepo(promotion.ErrUsageLimitReached), &RabbitCartEventsPublisherMock{CartCheckedOutEventsFunc: func(c *cartpkg.Cart, metadata events.PublishMetadata) []outbox.Event {
+			return nil
+		}}, pricing.NewInMemorySource(map[string]money.Money{"p1": usd(1000)}), &IdempotencyStoreMock{}, promotionStore(tenPercentOff(), 0), nil)
+		w := httptest.NewRecorder()
+
+		handler.Checkout(w, newRequest())
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("expected 422, got %d", w.Code)
+		}
+	})
+}
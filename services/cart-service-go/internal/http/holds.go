@@ -0,0 +1,71 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/inventory"
+)
+
+// errHoldFailed is returned when inventory could not be asked to hold stock,
+// as opposed to refusing for lack of it.
+var errHoldFailed = errors.New("failed to hold stock")
+
+// holdStock asks inventory to hold quantity of a product for the cart. It
+// returns a *inventory.ShortageError when there is not enough free stock, and
+// an error matching errHoldFailed when inventory could not be reached.
+func (h *CartHandler) holdStock(ctx context.Context, cartID, productID string, quantity int) error {
+	if h.holds == nil {
+		return nil
+	}
+	err := h.holds.Hold(ctx, cartID, productID, quantity, h.holdTTL)
+	if err == nil || errors.Is(err, inventory.ErrInsufficientStock) {
+		return err
+	}
+	return fmt.Errorf("%w: %s: %v", errHoldFailed, productID, err)
+}
+
+// releaseStock drops the cart's hold on a product. Holds left behind expire.
+func (h *CartHandler) releaseStock(ctx context.Context, cartID, productID string) {
+	if h.holds == nil {
+		return
+	}
+	if err := h.holds.Release(ctx, cartID, productID); err != nil {
+		h.logger.Printf("release hold %s for cart %s: %v", productID, cartID, err)
+	}
+}
+
+func (h *CartHandler) releaseAllStock(ctx context.Context, cartID string) {
+	if h.holds == nil {
+		return
+	}
+	if err := h.holds.ReleaseAll(ctx, cartID); err != nil {
+		h.logger.Printf("release holds for cart %s: %v", cartID, err)
+	}
+}
+
+// renewHolds keeps the holds of a cart in use from expiring.
+func (h *CartHandler) renewHolds(ctx context.Context, c *cart.Cart) {
+	if h.holds == nil || len(c.Items) == 0 {
+		return
+	}
+	if err := h.holds.Renew(ctx, c.UserID, h.holdTTL); err != nil {
+		h.logger.Printf("renew holds for cart %s: %v", c.UserID, err)
+	}
+}
+
+// moveHolds hands the guest cart's holds over to the cart it was merged into.
+// Lines that can no longer be held stay in the cart unheld.
+func (h *CartHandler) moveHolds(ctx context.Context, c *cart.Cart, guestID string) {
+	if h.holds == nil {
+		return
+	}
+	h.releaseAllStock(ctx, guestID)
+	for _, it := range c.Items {
+		if err := h.holdStock(ctx, c.UserID, it.ProductID, it.Quantity); err != nil {
+			h.logger.Printf("hold %s for merged cart %s: %v", it.ProductID, c.UserID, err)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cartpkg "github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	httphandler "github.com/andreasstove999/ecommerce-system/cart-service-go/internal/http"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/inventory"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/pricing"
+)
+
+func holdsRouter(repo *RepositoryMock, holds inventory.Holds) http.Handler {
+	prices := pricing.NewInMemorySource(map[string]money.Money{"p1": usd(1000), "p2": usd(500)})
+	return httphandler.NewRouter(repo, nil, prices, &IdempotencyStoreMock{}, nil, nil, httphandler.Config{Holds: holds})
+}
+
+func cartWith(items ...cartpkg.Item) func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+	return func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+		return &cartpkg.Cart{ID: "c1", UserID: userID, Items: append([]cartpkg.Item{}, items...), Version: 1}, nil
+	}
+}
+
+// addChecked adds to before as the repository does: the check runs first and
+// an error leaves the cart alone.
+func addChecked(before *cartpkg.Cart) func(ctx context.Context, userID string, items []cartpkg.Item, check cartpkg.CheckFunc, activity cartpkg.ActivityFunc) (*cartpkg.Cart, error) {
+	return func(ctx context.Context, userID string, items []cartpkg.Item, check cartpkg.CheckFunc, activity cartpkg.ActivityFunc) (*cartpkg.Cart, error) {
+		if check != nil {
+			if err := check(ctx, before); err != nil {
+				return nil, err
+			}
+		}
+		after := &cartpkg.Cart{ID: "c1", UserID: userID, Version: 1}
+		if before != nil {
+			after.Items = append(after.Items, before.Items...)
+			after.Version = before.Version + 1
+		}
+		for _, it := range items {
+			if idx := slices.IndexFunc(after.Items, func(x cartpkg.Item) bool { return x.ProductID == it.ProductID }); idx >= 0 {
+				after.Items[idx].Quantity += it.Quantity
+				continue
+			}
+			after.Items = append(after.Items, it)
+		}
+		return after, nil
+	}
+}
+
+// addItemChecked is addChecked for a single line.
+func addItemChecked(before *cartpkg.Cart) func(ctx context.Context, userID string, item cartpkg.Item, check cartpkg.CheckFunc, activity cartpkg.ActivityFunc) (*cartpkg.Cart, error) {
+	add := addChecked(before)
+	return func(ctx context.Context, userID string, item cartpkg.Item, check cartpkg.CheckFunc, activity cartpkg.ActivityFunc) (*cartpkg.Cart, error) {
+		return add(ctx, userID, []cartpkg.Item{item}, check, activity)
+	}
+}
+
+func TestAddItemHoldsStock(t *testing.T) {
+	holds := inventory.NewInMemoryHolds(map[string]int{"p1": 3})
+	repo := &RepositoryMock{
+		AddItemFunc: addItemChecked(&cartpkg.Cart{ID: "c1", UserID: "123", Items: []cartpkg.Item{{ProductID: "p1", Quantity: 1, Price: usd(1000)}}, Version: 1}),
+	}
+	w := httptest.NewRecorder()
+
+	holdsRouter(repo, holds).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/cart/123/items", strings.NewReader(`{"productId":"p1","quantity":1}`)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := holds.Held("123"); got["p1"] != 2 {
+		t.Fatalf("expected the whole line of 2 to be held, got %v", got)
+	}
+}
+
+func TestAddItemRejectsShortStock(t *testing.T) {
+	holds := inventory.NewInMemoryHolds(map[string]int{"p1": 3})
+	if err := holds.Hold(context.Background(), "other-cart", "p1", 2, 0); err != nil {
+		t.Fatalf("seed hold: %v", err)
+	}
+	repo := &RepositoryMock{AddItemFunc: addItemChecked(nil)}
+	w := httptest.NewRecorder()
+
+	holdsRouter(repo, holds).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/cart/123/items", strings.NewReader(`{"productId":"p1","quantity":2}`)))
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "insufficient stock for product p1: 1 available") {
+		t.Fatalf("unexpected body %s", w.Body.String())
+	}
+	if got := holds.Held("123"); len(got) != 0 {
+		t.Fatalf("expected nothing held, got %v", got)
+	}
+}
+
+func TestAddItemHoldsFollowConcurrentAdds(t *testing.T) {
+	holds := inventory.NewInMemoryHolds(map[string]int{"p1": 5})
+	// Each add finds the cart as the previous one left it, as the repository's
+	// lock makes them do.
+	stored := &cartpkg.Cart{ID: "c1", UserID: "123", Version: 1}
+	var mu sync.Mutex
+	repo := &RepositoryMock{
+		AddItemFunc: func(ctx context.Context, userID string, item cartpkg.Item, check cartpkg.CheckFunc, activity cartpkg.ActivityFunc) (*cartpkg.Cart, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			c, err := addItemChecked(stored)(ctx, userID, item, check, activity)
+			if err == nil {
+				stored = c
+			}
+			return c, err
+		},
+	}
+	router := holdsRouter(repo, holds)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/cart/123/items", strings.NewReader(`{"productId":"p1","quantity":1}`)))
+		}()
+	}
+	wg.Wait()
+
+	if got := holds.Held("123"); got["p1"] != 3 || stored.Items[0].Quantity != 3 {
+		t.Fatalf("expected the hold to match the line of 3, got %v for %+v", got, stored.Items)
+	}
+}
+
+func TestUpdateItemHoldsNewQuantity(t *testing.T) {
+	holds := inventory.NewInMemoryHolds(map[string]int{"p1": 3})
+	repo := &RepositoryMock{
+		GetCartFunc:    cartWith(cartpkg.Item{ProductID: "p1", Quantity: 1, Price: usd(1000)}),
+		UpsertCartFunc: func(ctx context.Context, c *cartpkg.Cart, activity cartpkg.ActivityFunc) error { return nil },
+	}
+	router := holdsRouter(repo, holds)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/api/cart/123/items/p1", strings.NewReader(`{"quantity":3}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := holds.Held("123"); got["p1"] != 3 {
+		t.Fatalf("expected 3 held, got %v", got)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/api/cart/123/items/p1", strings.NewReader(`{"quantity":4}`)))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(repo.UpsertCartCalls()) != 1 {
+		t.Fatalf("expected only the first update to be saved, got %d saves", len(repo.UpsertCartCalls()))
+	}
+}
+
+func TestRemovingItemsReleasesHolds(t *testing.T) {
+	holds := inventory.NewInMemoryHolds(map[string]int{"p1": 3, "p2": 3})
+	for _, id := range []string{"p1", "p2"} {
+		if err := holds.Hold(context.Background(), "123", id, 1, 0); err != nil {
+			t.Fatalf("seed hold: %v", err)
+		}
+	}
+	repo := &RepositoryMock{
+		GetCartFunc: cartWith(
+			cartpkg.Item{ProductID: "p1", Quantity: 1, Price: usd(1000)},
+			cartpkg.Item{ProductID: "p2", Quantity: 1, Price: usd(500)},
+		),
+		UpsertCartFunc: func(ctx context.Context, c *cartpkg.Cart, activity cartpkg.ActivityFunc) error { return nil },
+		ClearCartFunc: func(ctx context.Context, userID string, expectedVersion int64, activity cartpkg.ActivityFunc) error {
+			return nil
+		},
+	}
+	router := holdsRouter(repo, holds)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/cart/123/items/p1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := holds.Held("123"); len(got) != 1 || got["p2"] != 1 {
+		t.Fatalf("expected only p2 to stay held, got %v", got)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/cart/123", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if got := holds.Held("123"); len(got) != 0 {
+		t.Fatalf("expected no holds after clearing, got %v", got)
+	}
+}
+
+func TestMergeMovesHolds(t *testing.T) {
+	guestID := cartpkg.NewGuestID()
+	holds := inventory.NewInMemoryHolds(map[string]int{"p1": 3})
+	if err := holds.Hold(context.Background(), guestID, "p1", 2, 0); err != nil {
+		t.Fatalf("seed hold: %v", err)
+	}
+	repo := &RepositoryMock{
+		GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+			if userID == guestID {
+				return &cartpkg.Cart{ID: "g1", UserID: guestID, Items: []cartpkg.Item{{ProductID: "p1", Quantity: 2, Price: usd(1000)}}, Version: 1}, nil
+			}
+			return nil, nil
+		},
+		MergeCartsFunc: func(ctx context.Context, c *cartpkg.Cart, guest *cartpkg.Cart, activity cartpkg.ActivityFunc) error {
+			return nil
+		},
+	}
+	w := httptest.NewRecorder()
+
+	holdsRouter(repo, holds).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/cart/123/merge", strings.NewReader(`{"guestCartId":"`+guestID+`"}`)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := holds.Held(guestID); len(got) != 0 {
+		t.Fatalf("expected the guest holds to be released, got %v", got)
+	}
+	if got := holds.Held("123"); got["p1"] != 2 {
+		t.Fatalf("expected the user cart to hold 2, got %v", got)
+	}
+}
+
+// unreachableHolds fails every call as an inventory-service that is down would.
+type unreachableHolds struct{}
+
+func (unreachableHolds) Hold(ctx context.Context, cartID, productID string, quantity int, ttl time.Duration) error {
+	return errors.New("inventory request: connection refused")
+}
+
+func (unreachableHolds) Release(ctx context.Context, cartID, productID string) error {
+	return errors.New("inventory request: connection refused")
+}
+
+func (unreachableHolds) ReleaseAll(ctx context.Context, cartID string) error {
+	return errors.New("inventory request: connection refused")
+}
+
+func (unreachableHolds) Renew(ctx context.Context, cartID string, ttl time.Duration) error {
+	return errors.New("inventory request: connection refused")
+}
+
+func TestAddItemFailsWhenStockCannotBeHeld(t *testing.T) {
+	repo := &RepositoryMock{AddItemFunc: addItemChecked(nil)}
+	w := httptest.NewRecorder()
+
+	holdsRouter(repo, unreachableHolds{}).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/cart/123/items", strings.NewReader(`{"productId":"p1","quantity":1}`)))
+
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "failed to hold stock") {
+		t.Fatalf("unexpected body %s", w.Body.String())
+	}
+}
+
+func TestUpdateItemFailsWhenStockCannotBeHeld(t *testing.T) {
+	repo := &RepositoryMock{GetCartFunc: cartWith(cartpkg.Item{ProductID: "p1", Quantity: 1, Price: usd(1000)})}
+	w := httptest.NewRecorder()
+
+	holdsRouter(repo, unreachableHolds{}).ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/api/cart/123/items/p1", strings.NewReader(`{"quantity":2}`)))
+
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(repo.UpsertCartCalls()) != 0 {
+		t.Fatalf("expected nothing to be saved")
+	}
+}
@@ -0,0 +1,176 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/inventory"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/problem"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/rules"
+)
+
+var (
+	errPreconditionFailed = errors.New("precondition failed")
+	errItemNotFound       = errors.New("item not found")
+	errLoadCart           = errors.New("load cart")
+)
+
+// maxCartWriteAttempts bounds how often an unconditional read-modify-write is
+// retried after losing a race with another writer.
+const maxCartWriteAttempts = 3
+
+// cartETag returns the entity tag for c. It includes the cart ID so a tag taken
+// from a checked-out cart never matches the cart that replaces it.
+func cartETag(c *cart.Cart) string {
+	return fmt.Sprintf(`"%s.%d"`, c.ID, c.Version)
+}
+
+// ifMatch is a parsed If-Match header; nil means the request is unconditional.
+type ifMatch struct {
+	any  bool
+	tags []string
+}
+
+func parseIfMatch(r *http.Request) *ifMatch {
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" {
+		return nil
+	}
+
+	m := &ifMatch{}
+	for _, tag := range strings.Split(v, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			m.any = true
+			continue
+		}
+		m.tags = append(m.tags, tag)
+	}
+	return m
+}
+
+// matches uses the strong comparison If-Match requires, so weak tags never match.
+// A missing cart matches nothing, not even "*".
+func (m *ifMatch) matches(c *cart.Cart) bool {
+	if c == nil {
+		return false
+	}
+	if m.any {
+		return true
+	}
+	etag := cartETag(c)
+	for _, tag := range m.tags {
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// updateCart loads the user's cart, applies mutate and saves it with a version
+// check, recording activity with it. An unconditional request is retried when
+// another writer got there first; a conditional one fails with
+// errPreconditionFailed instead.
+func (h *CartHandler) updateCart(ctx context.Context, userID string, cond *ifMatch, activity cart.ActivityFunc, mutate func(c *cart.Cart) error) (*cart.Cart, error) {
+	return h.saveCartChange(ctx, userID, cond, false, mutate, func(ctx context.Context, c *cart.Cart) error {
+		return h.repo.UpsertCart(ctx, c, activity)
+	})
+}
+
+// saveCartChange is updateCart with the save step supplied by the caller, for
+// changes that write more than the cart in one transaction. With create set, a
+// user without a cart gets an empty one to mutate.
+func (h *CartHandler) saveCartChange(ctx context.Context, userID string, cond *ifMatch, create bool, mutate func(c *cart.Cart) error, save func(ctx context.Context, c *cart.Cart) error) (*cart.Cart, error) {
+	for attempt := 1; ; attempt++ {
+		c, err := h.repo.GetCart(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errLoadCart, err)
+		}
+		if cond != nil && !cond.matches(c) {
+			return nil, errPreconditionFailed
+		}
+		var before *cart.Cart
+		if c == nil {
+			if !create {
+				return nil, cart.ErrCartNotFound
+			}
+			c = &cart.Cart{UserID: userID, Items: []cart.Item{}}
+		} else {
+			before = snapshotCart(c)
+		}
+
+		if err := mutate(c); err != nil {
+			return nil, err
+		}
+		if err := recalculateTotal(c); err != nil {
+			return nil, err
+		}
+		if err := h.cartRules.Check(before, c); err != nil {
+			return nil, err
+		}
+
+		err = save(ctx, c)
+		if err == nil {
+			return c, nil
+		}
+		if !errors.Is(err, cart.ErrVersionConflict) {
+			return nil, err
+		}
+		if cond != nil {
+			return nil, errPreconditionFailed
+		}
+		if attempt == maxCartWriteAttempts {
+			return nil, err
+		}
+	}
+}
+
+// writeCart applies the cart's promotions and tax and writes it with its ETag.
+// Any cart written back to the customer counts as activity and renews its
+// stock holds.
+func (h *CartHandler) writeCart(ctx context.Context, w http.ResponseWriter, r *http.Request, status int, c *cart.Cart) {
+	h.renewHolds(ctx, c)
+	if err := h.applyPromotions(ctx, c); err != nil {
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to apply promotions")
+		return
+	}
+	if err := h.applyTax(ctx, c); err != nil {
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to calculate tax")
+		return
+	}
+	w.Header().Set("ETag", cartETag(c))
+	writeJSON(w, status, c)
+}
+
+// writeCartUpdateError maps errors from updateCart to responses.
+func writeCartUpdateError(w http.ResponseWriter, r *http.Request, err error) {
+	var shortage *inventory.ShortageError
+	var violated *rules.Error
+	switch {
+	case errors.As(err, &violated):
+		writeRulesError(w, r, violated)
+	case errors.Is(err, errPreconditionFailed):
+		writeError(w, r, http.StatusPreconditionFailed, problem.CodePreconditionFailed, "cart has been modified")
+	case errors.Is(err, cart.ErrVersionConflict):
+		writeError(w, r, http.StatusConflict, problem.CodeVersionConflict, "cart was modified concurrently, retry the request")
+	case errors.Is(err, cart.ErrCartNotFound):
+		writeError(w, r, http.StatusNotFound, codeCartNotFound, "cart not found")
+	case errors.Is(err, errItemNotFound):
+		writeError(w, r, http.StatusNotFound, codeItemNotFound, "item not found")
+	case errors.As(err, &shortage):
+		writeError(w, r, http.StatusConflict, codeInsufficientStock, fmt.Sprintf("insufficient stock for product %s: %d available", shortage.ProductID, shortage.Free))
+	case errors.Is(err, money.ErrCurrencyMismatch):
+		writeError(w, r, http.StatusConflict, codeCurrencyMismatch, "cart cannot contain items in different currencies")
+	case errors.Is(err, errHoldFailed):
+		writeError(w, r, http.StatusBadGateway, problem.CodeUpstreamFailed, "failed to hold stock")
+	case errors.Is(err, errLoadCart):
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to load cart")
+	default:
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to save cart")
+	}
+}
@@ -0,0 +1,57 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/problem"
+)
+
+// Error codes of the cart service, on top of the shared ones in package
+// problem. They are part of the API; see contracts/http/problems.md.
+const (
+	codeCartNotFound         = "cart_not_found"
+	codeItemNotFound         = "item_not_found"
+	codeProductNotFound      = "product_not_found"
+	codeWishlistNotFound     = "wishlist_not_found"
+	codeWishlistNameTaken    = "wishlist_name_taken"
+	codeCouponNotFound       = "coupon_not_found"
+	codeCouponNotApplied     = "coupon_not_applied"
+	codeCouponAlreadyApplied = "coupon_already_applied"
+	codeCouponNotApplicable  = "coupon_not_applicable"
+	codeCouponUsageLimit     = "coupon_usage_limit_reached"
+	codeCurrencyMismatch     = "currency_mismatch"
+	codeInsufficientStock    = "insufficient_stock"
+	codeGuestNotAllowed      = "guest_not_allowed"
+	codeCartRulesViolated    = "cart_rules_violated"
+	codeCartLinesRejected    = "cart_lines_rejected"
+	codeCartNeedsCorrections = "cart_needs_corrections"
+)
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, msg string) {
+	problem.Error(w, r, status, code, msg)
+}
+
+// writeInvalid rejects a request because of one of its fields.
+func writeInvalid(w http.ResponseWriter, r *http.Request, field, code, msg string) {
+	d := problem.Invalid(msg, problem.FieldError{Field: field, Code: code, Message: msg})
+	problem.Write(w, r, &d)
+}
+
+// writeFieldError rejects a request with the *problem.FieldError in err.
+func writeFieldError(w http.ResponseWriter, r *http.Request, err error) {
+	var field *problem.FieldError
+	if errors.As(err, &field) {
+		writeInvalid(w, r, field.Field, field.Code, field.Message)
+		return
+	}
+	writeError(w, r, http.StatusBadRequest, problem.CodeValidationFailed, err.Error())
+}
+
+// correctionsProblem refuses a checkout until the client accepts the
+// corrections; the validation's members sit next to the problem's.
+type correctionsProblem struct {
+	problem.Details
+	*cart.Validation
+}
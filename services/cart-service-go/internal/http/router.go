@@ -2,21 +2,104 @@ package http
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/idempotency"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/inventory"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/pricing"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/promotion"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/rules"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/tax"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/wishlist"
 )
 
-func NewRouter(cartRepo cart.Repository, cartPublisher CartEventsPublisher) http.Handler {
+// Config holds cart behaviour that is set by the operator rather than per request.
+type Config struct {
+	// MergeStrategy resolves quantity conflicts when a request to merge carts doesn't name one.
+	MergeStrategy cart.MergeStrategy
+	// DefaultTaxLocation is where carts are taxed until the customer sets a
+	// location. An empty country leaves those carts untaxed.
+	DefaultTaxLocation tax.Location
+	// Holds, when set, holds stock for cart lines as they are added or
+	// changed. The holds last HoldTTL (DefaultHoldTTL when zero) and are
+	// renewed whenever the cart is used.
+	Holds   inventory.Holds
+	HoldTTL time.Duration
+	// Wishlists, when set, enables the wishlist endpoints and moving lines
+	// between the cart and a wishlist.
+	Wishlists wishlist.Repository
+	// ActivityEvents publishes CartItemAdded, CartItemRemoved,
+	// CartItemQuantityChanged and CartCleared for every cart change.
+	ActivityEvents bool
+	// Rules, when set, limits what a cart may hold. Without them quantities
+	// only have to be positive.
+	Rules *rules.Rules
+	// Logger receives what the handlers cannot report to the client, such
+	// as failing to release a stock hold. The standard logger is used when
+	// it is nil.
+	Logger *log.Logger
+}
+
+// DefaultHoldTTL is how long stock stays held for an idle cart.
+const DefaultHoldTTL = 15 * time.Minute
+
+func NewRouter(cartRepo cart.Repository, cartPublisher CartEventsPublisher, prices pricing.PriceSource, idem idempotency.Store, promotions promotion.Store, taxes tax.Rules, cfg Config) http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/health", healthHandler)
 	// Wiring for cart
-	cartHandler := NewCartHandler(cartRepo, cartPublisher)
+	cartHandler := NewCartHandler(cartRepo, cartPublisher, prices, idem, promotions, taxes)
+	if cfg.MergeStrategy != "" {
+		cartHandler.mergeStrategy = cfg.MergeStrategy
+	}
+	cartHandler.defaultTaxLocation = cfg.DefaultTaxLocation
+	cartHandler.holds = cfg.Holds
+	cartHandler.holdTTL = cfg.HoldTTL
+	if cartHandler.holdTTL <= 0 {
+		cartHandler.holdTTL = DefaultHoldTTL
+	}
+	cartHandler.wishlists = cfg.Wishlists
+	if cfg.Logger != nil {
+		cartHandler.logger = cfg.Logger
+	}
+	cartHandler.activityEvents = cfg.ActivityEvents
+	if cfg.Rules != nil {
+		cartHandler.cartRules = cfg.Rules
+	}
+
+	mux.HandleFunc("POST /api/cart/guests", cartHandler.CreateGuestCart)                  // issue anonymous cart
+	mux.HandleFunc("POST /api/cart/{userId}/items", cartHandler.AddItem)                  // add/update item
+	mux.HandleFunc("POST /api/cart/{userId}/items:batch", cartHandler.AddItems)           // add many lines
+	mux.HandleFunc("PATCH /api/cart/{userId}/items/{productId}", cartHandler.UpdateItem)  // set quantity
+	mux.HandleFunc("PUT /api/cart/{userId}/items/{productId}", cartHandler.UpdateItem)    // set quantity
+	mux.HandleFunc("DELETE /api/cart/{userId}/items/{productId}", cartHandler.RemoveItem) // remove line
+	mux.HandleFunc("GET /api/cart/{userId}", cartHandler.GetCart)                         // fetch cart
+	mux.HandleFunc("PUT /api/cart/{userId}", cartHandler.ReplaceCart)                     // replace all lines
+	mux.HandleFunc("DELETE /api/cart/{userId}", cartHandler.ClearCart)                    // empty cart
+	mux.HandleFunc("POST /api/cart/{userId}/merge", cartHandler.MergeCart)                // fold guest cart in
+	mux.HandleFunc("POST /api/cart/{userId}/coupons", cartHandler.ApplyCoupon)            // enter coupon code
+	mux.HandleFunc("DELETE /api/cart/{userId}/coupons/{code}", cartHandler.RemoveCoupon)  // remove coupon code
+	mux.HandleFunc("PUT /api/cart/{userId}/tax-location", cartHandler.SetTaxLocation)     // where tax applies
+	mux.HandleFunc("POST /api/cart/{userId}/validate", cartHandler.Validate)              // pre-checkout checks
+	mux.HandleFunc("POST /api/cart/{userId}/checkout", cartHandler.Checkout)              // publish event
 
-	mux.HandleFunc("POST /api/cart/{userId}/items", cartHandler.AddItem)     // add/update item
-	mux.HandleFunc("GET /api/cart/{userId}", cartHandler.GetCart)            // fetch cart
-	mux.HandleFunc("POST /api/cart/{userId}/checkout", cartHandler.Checkout) // publish event
+	if cfg.Wishlists != nil {
+		mux.HandleFunc("POST /api/cart/{userId}/items/{productId}/move-to-wishlist", cartHandler.MoveToWishlist)       // save for later
+		mux.HandleFunc("GET /api/wishlists/{userId}", cartHandler.ListWishlists)                                       // user's lists
+		mux.HandleFunc("POST /api/wishlists/{userId}", cartHandler.CreateWishlist)                                     // new named list
+		mux.HandleFunc("GET /api/wishlists/{userId}/{listId}", cartHandler.GetWishlist)                                // fetch list
+		mux.HandleFunc("PATCH /api/wishlists/{userId}/{listId}", cartHandler.RenameWishlist)                           // rename list
+		mux.HandleFunc("DELETE /api/wishlists/{userId}/{listId}", cartHandler.DeleteWishlist)                          // delete list
+		mux.HandleFunc("POST /api/wishlists/{userId}/{listId}/items", cartHandler.AddWishlistItem)                     // park product
+		mux.HandleFunc("DELETE /api/wishlists/{userId}/{listId}/items/{productId}", cartHandler.RemoveWishlistItem)    // remove product
+		mux.HandleFunc("POST /api/wishlists/{userId}/{listId}/items/{productId}/move-to-cart", cartHandler.MoveToCart) // back to cart
+		mux.HandleFunc("POST /api/wishlists/{userId}/{listId}/share", cartHandler.ShareWishlist)                       // issue share token
+		mux.HandleFunc("DELETE /api/wishlists/{userId}/{listId}/share", cartHandler.UnshareWishlist)                   // revoke share token
+		mux.HandleFunc("GET /api/wishlists/shared/{token}", cartHandler.GetSharedWishlist)                             // public read-only view
+	}
 	return mux
 }
 
@@ -0,0 +1,31 @@
+package http
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/problem"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/rules"
+)
+
+// rulesProblem is the body of a change refused by the cart rules. It lists
+// each rule the change breaks.
+type rulesProblem struct {
+	problem.Details
+	Violations []rules.Violation `json:"violations"`
+}
+
+func writeRulesError(w http.ResponseWriter, r *http.Request, err *rules.Error) {
+	problem.Write(w, r, &rulesProblem{
+		Details:    problem.New(http.StatusUnprocessableEntity, codeCartRulesViolated, "cart rules violated"),
+		Violations: err.Violations,
+	})
+}
+
+// snapshotCart copies c's lines so they can be compared after c is mutated.
+func snapshotCart(c *cart.Cart) *cart.Cart {
+	snapshot := *c
+	snapshot.Items = slices.Clone(c.Items)
+	return &snapshot
+}
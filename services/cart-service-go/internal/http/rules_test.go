@@ -0,0 +1,144 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cartpkg "github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	httphandler "github.com/andreasstove999/ecommerce-system/cart-service-go/internal/http"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/pricing"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/rules"
+)
+
+func rulesRouter(t *testing.T, repo *RepositoryMock, cfg rules.Config) http.Handler {
+	t.Helper()
+	r, err := rules.New(cfg)
+	if err != nil {
+		t.Fatalf("new rules: %v", err)
+	}
+	prices := pricing.NewInMemorySource(map[string]money.Money{"p1": usd(1000), "p2": usd(500), "p3": usd(200)})
+	return httphandler.NewRouter(repo, nil, prices, &IdempotencyStoreMock{}, nil, nil, httphandler.Config{Rules: r})
+}
+
+type rulesError struct {
+	Error      string            `json:"error"`
+	Code       string            `json:"code"`
+	Violations []rules.Violation `json:"violations"`
+}
+
+func decodeRulesError(t *testing.T, w *httptest.ResponseRecorder) rulesError {
+	t.Helper()
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", w.Code, w.Body.String())
+	}
+	var body rulesError
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Error == "" || body.Code != "cart_rules_violated" || len(body.Violations) == 0 {
+		t.Fatalf("expected a structured error, got %s", w.Body.String())
+	}
+	return body
+}
+
+func TestAddItemRules(t *testing.T) {
+	t.Run("negative quantity is refused before pricing", func(t *testing.T) {
+		repo := &RepositoryMock{}
+		w := httptest.NewRecorder()
+
+		rulesRouter(t, repo, rules.Config{}).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/cart/u1/items", strings.NewReader(`{"productId":"p1","quantity":-3}`)))
+
+		body := decodeRulesError(t, w)
+		if v := body.Violations[0]; v.Rule != rules.RuleQuantity || v.ProductID != "p1" || v.Actual != -3 {
+			t.Fatalf("unexpected violation %+v", v)
+		}
+		if len(repo.AddItemCalls()) != 0 {
+			t.Fatalf("expected nothing to be added")
+		}
+	})
+
+	t.Run("product id pattern", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		rulesRouter(t, &RepositoryMock{}, rules.Config{ProductIDPatterns: []string{`sku-\d+`}}).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/cart/u1/items", strings.NewReader(`{"productId":"p1","quantity":1}`)))
+
+		if v := decodeRulesError(t, w).Violations[0]; v.Rule != rules.RuleProductID {
+			t.Fatalf("unexpected violation %+v", v)
+		}
+	})
+
+	t.Run("line quantity counts what is in the cart", func(t *testing.T) {
+		repo := &RepositoryMock{GetCartFunc: cartWith(cartpkg.Item{ProductID: "p1", Quantity: 4, Price: usd(1000)})}
+		w := httptest.NewRecorder()
+
+		rulesRouter(t, repo, rules.Config{MaxLineQuantity: 5}).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/cart/u1/items", strings.NewReader(`{"productId":"p1","quantity":2}`)))
+
+		if v := decodeRulesError(t, w).Violations[0]; v.Rule != rules.RuleMaxLineQuantity || v.Limit != 5 || v.Actual != 6 {
+			t.Fatalf("unexpected violation %+v", v)
+		}
+		if len(repo.AddItemCalls()) != 0 || len(repo.UpsertCartCalls()) != 0 {
+			t.Fatalf("expected nothing to be saved")
+		}
+	})
+
+	t.Run("within the limits", func(t *testing.T) {
+		repo := &RepositoryMock{
+			GetCartFunc:    cartWith(cartpkg.Item{ProductID: "p1", Quantity: 1, Price: usd(1000)}),
+			UpsertCartFunc: func(ctx context.Context, c *cartpkg.Cart, activity cartpkg.ActivityFunc) error { return nil },
+		}
+		w := httptest.NewRecorder()
+
+		rulesRouter(t, repo, rules.Config{MaxLines: 2}).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/cart/u1/items", strings.NewReader(`{"productId":"p2","quantity":1}`)))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if len(repo.UpsertCartCalls()) != 1 {
+			t.Fatalf("expected a versioned save while limits apply")
+		}
+	})
+
+	t.Run("the first item creates the cart", func(t *testing.T) {
+		repo := &RepositoryMock{
+			GetCartFunc:    func(ctx context.Context, userID string) (*cartpkg.Cart, error) { return nil, nil },
+			UpsertCartFunc: func(ctx context.Context, c *cartpkg.Cart, activity cartpkg.ActivityFunc) error { return nil },
+		}
+		w := httptest.NewRecorder()
+
+		rulesRouter(t, repo, rules.Config{MaxLines: 2}).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/cart/u1/items", strings.NewReader(`{"productId":"p1","quantity":1}`)))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if calls := repo.UpsertCartCalls(); len(calls) != 1 || calls[0].C.Version != 0 || len(calls[0].C.Items) != 1 {
+			t.Fatalf("expected a new cart with the line to be saved, got %+v", calls)
+		}
+	})
+}
+
+func TestMergeCartRules(t *testing.T) {
+	guestID := cartpkg.NewGuestID()
+	repo := &RepositoryMock{
+		GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+			if userID == guestID {
+				return &cartpkg.Cart{ID: "g1", UserID: guestID, Items: []cartpkg.Item{{ProductID: "p2", Quantity: 1, Price: usd(500)}, {ProductID: "p3", Quantity: 1, Price: usd(200)}}}, nil
+			}
+			return &cartpkg.Cart{ID: "c1", UserID: userID, Items: []cartpkg.Item{{ProductID: "p1", Quantity: 1, Price: usd(1000)}}}, nil
+		},
+	}
+	w := httptest.NewRecorder()
+
+	rulesRouter(t, repo, rules.Config{MaxLines: 2}).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/cart/u1/merge", strings.NewReader(`{"guestCartId":"`+guestID+`"}`)))
+
+	if v := decodeRulesError(t, w).Violations[0]; v.Rule != rules.RuleMaxLines || v.Actual != 3 {
+		t.Fatalf("unexpected violation %+v", v)
+	}
+	if len(repo.MergeCartsCalls()) != 0 {
+		t.Fatalf("expected the carts not to be merged")
+	}
+}
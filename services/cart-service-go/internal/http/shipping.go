@@ -0,0 +1,64 @@
+package http
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/problem"
+)
+
+// maxAddressFieldLength bounds each address line so a checkout event stays
+// a reasonable size.
+const maxAddressFieldLength = 200
+
+// parseShipping checks the shipping details of a checkout request and
+// returns them cleaned up. Both are optional: no address means no shipping
+// details, and an address without a method ships standard. A bad field is
+// reported as a *problem.FieldError.
+func parseShipping(address *cart.Address, method string) (*cart.Shipping, error) {
+	method = strings.ToLower(strings.TrimSpace(method))
+	if address == nil {
+		if method != "" {
+			return nil, &problem.FieldError{Field: "shippingMethod", Code: problem.FieldInvalid, Message: "shippingMethod requires a shippingAddress"}
+		}
+		return nil, nil
+	}
+	if method == "" {
+		method = cart.ShippingStandard
+	}
+	if !cart.ValidShippingMethod(method) {
+		return nil, &problem.FieldError{Field: "shippingMethod", Code: problem.FieldInvalid, Message: fmt.Sprintf("shippingMethod must be one of %s", strings.Join(cart.ShippingMethods, ", "))}
+	}
+
+	a := cart.Address{
+		Line1:      strings.TrimSpace(address.Line1),
+		Line2:      strings.TrimSpace(address.Line2),
+		City:       strings.TrimSpace(address.City),
+		State:      strings.TrimSpace(address.State),
+		PostalCode: strings.TrimSpace(address.PostalCode),
+		Country:    strings.ToUpper(strings.TrimSpace(address.Country)),
+	}
+	for _, f := range []struct {
+		name, value string
+		required    bool
+	}{
+		{"line1", a.Line1, true},
+		{"line2", a.Line2, false},
+		{"city", a.City, true},
+		{"state", a.State, false},
+		{"postalCode", a.PostalCode, true},
+	} {
+		if f.required && f.value == "" {
+			return nil, &problem.FieldError{Field: "shippingAddress." + f.name, Code: problem.FieldRequired, Message: fmt.Sprintf("shippingAddress.%s is required", f.name)}
+		}
+		if len(f.value) > maxAddressFieldLength {
+			return nil, &problem.FieldError{Field: "shippingAddress." + f.name, Code: problem.FieldTooLong, Message: fmt.Sprintf("shippingAddress.%s must be at most %d characters", f.name, maxAddressFieldLength)}
+		}
+	}
+	if !validCountry(a.Country) {
+		return nil, &problem.FieldError{Field: "shippingAddress.country", Code: problem.FieldInvalid, Message: "shippingAddress.country must be an ISO 3166-1 alpha-2 code"}
+	}
+
+	return &cart.Shipping{Address: a, Method: method}, nil
+}
@@ -0,0 +1,106 @@
+package http_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cartpkg "github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/events"
+	httphandler "github.com/andreasstove999/ecommerce-system/cart-service-go/internal/http"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/idempotency"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/outbox"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/pricing"
+)
+
+func TestCheckoutShipping(t *testing.T) {
+	newHandler := func() (*httphandler.CartHandler, *RepositoryMock, *RabbitCartEventsPublisherMock) {
+		repo := &RepositoryMock{
+			GetCartFunc: cartWith(cartpkg.Item{ProductID: "p1", Quantity: 1, Price: usd(1000)}),
+			CheckoutCartFunc: func(ctx context.Context, c *cartpkg.Cart, evs []outbox.Event, idem *idempotency.Record) error {
+				return nil
+			},
+		}
+		publisher := &RabbitCartEventsPublisherMock{CartCheckedOutEventsFunc: func(c *cartpkg.Cart, metadata events.PublishMetadata) []outbox.Event {
+			return []outbox.Event{{PartitionKey: c.ID, RoutingKey: events.CartCheckedOutV5RoutingKey}}
+		}}
+		prices := pricing.NewInMemorySource(map[string]money.Money{"p1": usd(1000)})
+		return httphandler.NewCartHandler(repo, publisher, prices, &IdempotencyStoreMock{}, nil, nil), repo, publisher
+	}
+	checkout := func(handler *httphandler.CartHandler, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/cart/u1/checkout", strings.NewReader(body))
+		r.SetPathValue("userId", "u1")
+		w := httptest.NewRecorder()
+		handler.Checkout(w, r)
+		return w
+	}
+
+	t.Run("passes the cleaned up details on", func(t *testing.T) {
+		handler, _, publisher := newHandler()
+
+		w := checkout(handler, `{"shippingAddress":{"line1":" Aaboulevarden 12 ","city":"Aarhus","postalCode":"8000","country":"dk"},"shippingMethod":"Express"}`)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		got := publisher.CartCheckedOutEventsCalls()[0].C.Shipping
+		want := cartpkg.Shipping{
+			Address: cartpkg.Address{Line1: "Aaboulevarden 12", City: "Aarhus", PostalCode: "8000", Country: "DK"},
+			Method:  cartpkg.ShippingExpress,
+		}
+		if got == nil || *got != want {
+			t.Fatalf("expected %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("an address ships standard by default", func(t *testing.T) {
+		handler, _, publisher := newHandler()
+
+		w := checkout(handler, `{"shippingAddress":{"line1":"1 Main St","city":"Springfield","state":"IL","postalCode":"62701","country":"US"}}`)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if got := publisher.CartCheckedOutEventsCalls()[0].C.Shipping; got == nil || got.Method != cartpkg.ShippingStandard {
+			t.Fatalf("expected standard shipping, got %+v", got)
+		}
+	})
+
+	t.Run("shipping details are optional", func(t *testing.T) {
+		handler, _, publisher := newHandler()
+
+		w := checkout(handler, "")
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if got := publisher.CartCheckedOutEventsCalls()[0].C.Shipping; got != nil {
+			t.Fatalf("expected no shipping details, got %+v", got)
+		}
+	})
+
+	for name, body := range map[string]string{
+		"method without an address": `{"shippingMethod":"express"}`,
+		"unknown method":            `{"shippingAddress":{"line1":"1 Main St","city":"Springfield","postalCode":"62701","country":"US"},"shippingMethod":"teleport"}`,
+		"missing line1":             `{"shippingAddress":{"line1":"  ","city":"Springfield","postalCode":"62701","country":"US"}}`,
+		"missing postal code":       `{"shippingAddress":{"line1":"1 Main St","city":"Springfield","country":"US"}}`,
+		"bad country":               `{"shippingAddress":{"line1":"1 Main St","city":"Springfield","postalCode":"62701","country":"USA"}}`,
+		"line too long":             `{"shippingAddress":{"line1":"` + strings.Repeat("x", 201) + `","city":"Springfield","postalCode":"62701","country":"US"}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			handler, repo, _ := newHandler()
+
+			w := checkout(handler, body)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+			}
+			if len(repo.CheckoutCartCalls()) != 0 {
+				t.Fatalf("did not expect checkout")
+			}
+		})
+	}
+}
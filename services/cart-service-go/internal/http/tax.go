@@ -0,0 +1,104 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/problem"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/tax"
+)
+
+// SetTaxLocation sets where the cart is taxed, typically from the shipping
+// address. An empty country goes back to the service default.
+func (h *CartHandler) SetTaxLocation(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("userId")
+	if userID == "" {
+		writeInvalid(w, r, "userId", problem.FieldRequired, "missing userId")
+		return
+	}
+
+	var body struct {
+		Country string `json:"country"`
+		Region  string `json:"region"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "invalid json")
+		return
+	}
+	country := strings.ToUpper(strings.TrimSpace(body.Country))
+	region := strings.ToUpper(strings.TrimSpace(body.Region))
+	if country != "" && !validCountry(country) {
+		writeInvalid(w, r, "country", problem.FieldInvalid, "country must be an ISO 3166-1 alpha-2 code")
+		return
+	}
+	if country == "" && region != "" {
+		writeInvalid(w, r, "country", problem.FieldRequired, "region requires a country")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	c, err := h.updateCart(ctx, userID, parseIfMatch(r), h.activity(r), func(c *cart.Cart) error {
+		c.TaxCountry, c.TaxRegion = country, region
+		return nil
+	})
+	if err != nil {
+		writeCartUpdateError(w, r, err)
+		return
+	}
+
+	h.writeCart(ctx, w, r, http.StatusOK, c)
+}
+
+// applyTax works out the tax on the cart after its discounts and
+// recalculates its total. Carts without a location are left untaxed.
+func (h *CartHandler) applyTax(ctx context.Context, c *cart.Cart) error {
+	c.Tax = nil
+	if err := c.RecalculateTotal(); err != nil {
+		return err
+	}
+	loc := h.taxLocation(c)
+	if loc.Country == "" {
+		return nil
+	}
+
+	currency := c.Currency()
+	lines := make([]tax.Line, 0, len(c.Items))
+	for _, it := range c.Items {
+		lines = append(lines, tax.Line{ProductID: it.ProductID, Amount: it.Price.Mul(it.Quantity)})
+	}
+	discount := money.New(c.Subtotal.Amount-c.Total.Amount, currency)
+
+	b, err := tax.Calculate(ctx, h.taxes, loc, lines, discount)
+	if err != nil {
+		return err
+	}
+	c.Tax = b
+	return c.RecalculateTotal()
+}
+
+func (h *CartHandler) taxLocation(c *cart.Cart) tax.Location {
+	if c.TaxCountry != "" {
+		return tax.Location{Country: c.TaxCountry, Region: c.TaxRegion}
+	}
+	return h.defaultTaxLocation
+}
+
+func validCountry(code string) bool {
+	if len(code) != 2 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cartpkg "github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/events"
+	httphandler "github.com/andreasstove999/ecommerce-system/cart-service-go/internal/http"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/idempotency"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/outbox"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/pricing"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/tax"
+)
+
+func taxRules() *tax.InMemoryRules {
+	rules := tax.NewInMemoryRules()
+	rules.SetRate(tax.Location{Country: "US"}, tax.Rate{Class: tax.DefaultClass, BasisPoints: 500})
+	rules.SetRate(tax.Location{Country: "US", Region: "NY"}, tax.Rate{Class: tax.DefaultClass, BasisPoints: 888})
+	rules.SetRate(tax.Location{Country: "US"}, tax.Rate{Class: "exempt"})
+	rules.SetClass("gift-card", "exempt")
+	return rules
+}
+
+func taxedCart(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+	return &cartpkg.Cart{
+		ID:     "c1",
+		UserID: userID,
+		Items: []cartpkg.Item{
+			{ProductID: "p1", Quantity: 2, Price: usd(1000)},
+			{ProductID: "gift-card", Quantity: 1, Price: usd(500)},
+		},
+		TaxCountry: "US",
+		TaxRegion:  "NY",
+		Version:    1,
+	}, nil
+}
+
+func TestGetCartIncludesTaxBreakdown(t *testing.T) {
+	handler := httphandler.NewCartHandler(&RepositoryMock{GetCartFunc: taxedCart}, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, taxRules())
+	r := httptest.NewRequest(http.MethodGet, "/api/cart/123", nil)
+	r.SetPathValue("userId", "123")
+	w := httptest.NewRecorder()
+
+	handler.GetCart(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var got cartpkg.Cart
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.Tax == nil || got.Tax.Country != "US" || got.Tax.Region != "NY" {
+		t.Fatalf("expected a tax breakdown for US/NY, got %+v", got.Tax)
+	}
+	if len(got.Tax.Lines) != 2 || got.Tax.Lines[0].Amount != usd(178) || got.Tax.Lines[1].Amount != usd(0) || got.Tax.Lines[1].TaxClass != "exempt" {
+		t.Fatalf("unexpected tax lines %+v", got.Tax.Lines)
+	}
+	if got.Subtotal != usd(2500) || got.Total != usd(2678) {
+		t.Fatalf("expected subtotal 25.00 and total 26.78, got %+v and %+v", got.Subtotal, got.Total)
+	}
+}
+
+func TestDefaultTaxLocation(t *testing.T) {
+	repo := &RepositoryMock{GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+		return &cartpkg.Cart{ID: "c1", UserID: userID, Items: []cartpkg.Item{{ProductID: "p1", Quantity: 1, Price: usd(1000)}}, Version: 1}, nil
+	}}
+	router := httphandler.NewRouter(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, taxRules(), httphandler.Config{
+		DefaultTaxLocation: tax.Location{Country: "US"},
+	})
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/cart/123", nil))
+
+	var got cartpkg.Cart
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.Tax == nil || got.Tax.Total != usd(50) || got.Total != usd(1050) {
+		t.Fatalf("expected the default rate to apply, got tax %+v and total %+v", got.Tax, got.Total)
+	}
+}
+
+func TestSetTaxLocation(t *testing.T) {
+	newRequest := func(body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPut, "/api/cart/123/tax-location", strings.NewReader(body))
+		r.SetPathValue("userId", "123")
+		return r
+	}
+
+	t.Run("invalid country", func(t *testing.T) {
+		handler := httphandler.NewCartHandler(&RepositoryMock{}, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, taxRules())
+		w := httptest.NewRecorder()
+
+		handler.SetTaxLocation(w, newRequest(`{"country":"USA"}`))
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("saves normalised location", func(t *testing.T) {
+		repo := &RepositoryMock{
+			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) {
+				return &cartpkg.Cart{ID: "c1", UserID: userID, Items: []cartpkg.Item{{ProductID: "p1", Quantity: 1, Price: usd(1000)}}, Version: 1}, nil
+			},
+			UpsertCartFunc: func(ctx context.Context, c *cartpkg.Cart, activity cartpkg.ActivityFunc) error { return nil },
+		}
+		handler := httphandler.NewCartHandler(repo, nil, pricing.NewInMemorySource(nil), &IdempotencyStoreMock{}, nil, taxRules())
+		w := httptest.NewRecorder()
+
+		handler.SetTaxLocation(w, newRequest(`{"country":"us","region":" ny"}`))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		saved := repo.UpsertCartCalls()
+		if len(saved) != 1 || saved[0].C.TaxCountry != "US" || saved[0].C.TaxRegion != "NY" {
+			t.Fatalf("expected US/NY to be saved, got %+v", saved)
+		}
+		var got cartpkg.Cart
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if got.Tax == nil || got.Tax.Total != usd(89) {
+			t.Fatalf("expected the NY rate to apply, got %+v", got.Tax)
+		}
+	})
+}
+
+func TestCheckoutCarriesTax(t *testing.T) {
+	var published *cartpkg.Cart
+	publisher := &RabbitCartEventsPublisherMock{CartCheckedOutEventsFunc: func(c *cartpkg.Cart, metadata events.PublishMetadata) []outbox.Event {
+		published = c
+		return nil
+	}}
+	repo := &RepositoryMock{
+		GetCartFunc: taxedCart,
+		CheckoutCartFunc: func(ctx context.Context, c *cartpkg.Cart, evs []outbox.Event, idem *idempotency.Record) error {
+			return nil
+		},
+	}
+	prices := pricing.NewInMemorySource(map[string]money.Money{"p1": usd(1000), "gift-card": usd(500)})
+	handler := httphandler.NewCartHandler(repo, publisher, prices, &IdempotencyStoreMock{}, nil, taxRules())
+	r := httptest.NewRequest(http.MethodPost, "/api/cart/123/checkout", nil)
+	r.SetPathValue("userId", "123")
+	w := httptest.NewRecorder()
+
+	handler.Checkout(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if published == nil || published.Tax == nil || published.Tax.Total != usd(178) || published.Total != usd(2678) {
+		t.Fatalf("expected the taxed cart to be published, got %+v", published)
+	}
+}
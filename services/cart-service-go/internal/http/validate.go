@@ -0,0 +1,146 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/inventory"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/pricing"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/problem"
+)
+
+// Validate reports what checkout would find wrong with the cart and what
+// the corrected cart looks like. The stored cart is left as it is, but its
+// stock holds are refreshed to the quantities of the corrected cart.
+func (h *CartHandler) Validate(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("userId")
+	if userID == "" {
+		writeInvalid(w, r, "userId", problem.FieldRequired, "missing userId")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	c, err := h.repo.GetCart(ctx, userID)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to load cart")
+		return
+	}
+	if c == nil {
+		writeError(w, r, http.StatusNotFound, codeCartNotFound, "cart not found")
+		return
+	}
+
+	v, err := h.validateCart(ctx, c)
+	if err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+	if err := h.applyPromotions(ctx, c); err != nil {
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to apply promotions")
+		return
+	}
+	if err := h.applyTax(ctx, c); err != nil {
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to calculate tax")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, v)
+}
+
+// validateCart checks each line against the catalog and, when holds are set
+// up, against inventory by holding its quantity. c is corrected in place and
+// becomes the validation's CorrectedCart; promotions and tax are left to the
+// caller.
+func (h *CartHandler) validateCart(ctx context.Context, c *cart.Cart) (*cart.Validation, error) {
+	problems := []cart.Problem{}
+	pricedAt := time.Now().UTC()
+	items := make([]cart.Item, 0, len(c.Items))
+	for _, it := range c.Items {
+		price, err := h.prices.Price(ctx, it.ProductID)
+		switch {
+		case errors.Is(err, pricing.ErrProductInactive):
+			problems = append(problems, cart.Problem{
+				Code:      cart.ProblemProductInactive,
+				ProductID: it.ProductID,
+				Message:   "product is no longer sold",
+			})
+			h.releaseStock(ctx, c.UserID, it.ProductID)
+			continue
+		case errors.Is(err, pricing.ErrProductNotFound):
+			problems = append(problems, cart.Problem{
+				Code:      cart.ProblemProductNotFound,
+				ProductID: it.ProductID,
+				Message:   "product no longer exists",
+			})
+			h.releaseStock(ctx, c.UserID, it.ProductID)
+			continue
+		case err != nil:
+			return nil, err
+		}
+
+		if price != it.Price {
+			previous := it.Price
+			problems = append(problems, cart.Problem{
+				Code:          cart.ProblemPriceChanged,
+				ProductID:     it.ProductID,
+				Message:       fmt.Sprintf("price changed from %s to %s", previous, price),
+				PreviousPrice: &previous,
+				CurrentPrice:  &price,
+			})
+		}
+		it.Price = price
+		it.PricedAt = pricedAt
+
+		var shortage *inventory.ShortageError
+		err = h.holdStock(ctx, c.UserID, it.ProductID, it.Quantity)
+		if err != nil && !errors.As(err, &shortage) {
+			return nil, err
+		}
+		if shortage != nil {
+			free := shortage.Free
+			problems = append(problems, cart.Problem{
+				Code:              cart.ProblemOutOfStock,
+				ProductID:         it.ProductID,
+				Message:           fmt.Sprintf("only %d of %d available", free, it.Quantity),
+				RequestedQuantity: it.Quantity,
+				AvailableQuantity: &free,
+			})
+			if free == 0 {
+				continue
+			}
+			// Keep what is left for the customer while they decide.
+			it.Quantity = free
+			if err := h.holdStock(ctx, c.UserID, it.ProductID, free); err != nil {
+				h.logger.Printf("hold remaining %d of %s for cart %s: %v", free, it.ProductID, c.UserID, err)
+			}
+		}
+		items = append(items, it)
+	}
+
+	c.Items = items
+	if err := recalculateTotal(c); err != nil {
+		return nil, err
+	}
+
+	v := &cart.Validation{Valid: len(problems) == 0, Problems: problems, CorrectedCart: c}
+	if !v.Valid {
+		v.CorrectionsToken = cart.CorrectionsToken(problems)
+	}
+	return v, nil
+}
+
+// writeValidationError maps errors from validateCart to responses.
+func writeValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, money.ErrCurrencyMismatch) || errors.Is(err, errHoldFailed) {
+		writeCartUpdateError(w, r, err)
+		return
+	}
+	writePriceError(w, r, err)
+}
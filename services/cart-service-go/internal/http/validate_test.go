@@ -0,0 +1,149 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cartpkg "github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/events"
+	httphandler "github.com/andreasstove999/ecommerce-system/cart-service-go/internal/http"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/idempotency"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/inventory"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/outbox"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/pricing"
+)
+
+func validateCart(t *testing.T, router http.Handler) cartpkg.Validation {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/cart/123/validate", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var v cartpkg.Validation
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decode validation: %v", err)
+	}
+	return v
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("valid cart", func(t *testing.T) {
+		repo := &RepositoryMock{GetCartFunc: cartWith(cartpkg.Item{ProductID: "p1", Quantity: 1, Price: usd(1000)})}
+		holds := inventory.NewInMemoryHolds(map[string]int{"p1": 5})
+
+		v := validateCart(t, holdsRouter(repo, holds))
+
+		if !v.Valid || len(v.Problems) != 0 || v.CorrectionsToken != "" {
+			t.Fatalf("expected a valid cart, got %+v", v)
+		}
+		if v.CorrectedCart == nil || v.CorrectedCart.Total != usd(1000) {
+			t.Fatalf("expected the cart back unchanged, got %+v", v.CorrectedCart)
+		}
+		if len(repo.UpsertCartCalls()) != 0 {
+			t.Fatalf("did not expect validation to save the cart")
+		}
+	})
+
+	t.Run("reports and corrects problems", func(t *testing.T) {
+		repo := &RepositoryMock{GetCartFunc: cartWith(
+			cartpkg.Item{ProductID: "p1", Quantity: 4, Price: usd(1000)},
+			cartpkg.Item{ProductID: "p2", Quantity: 1, Price: usd(400)},
+			cartpkg.Item{ProductID: "retired", Quantity: 1, Price: usd(300)},
+			cartpkg.Item{ProductID: "gone", Quantity: 1, Price: usd(200)},
+		)}
+		prices := pricing.NewInMemorySource(map[string]money.Money{"p1": usd(1000), "p2": usd(500), "retired": usd(300)})
+		prices.Deactivate("retired")
+		holds := inventory.NewInMemoryHolds(map[string]int{"p1": 3, "p2": 5, "retired": 5})
+		router := httphandler.NewRouter(repo, nil, prices, &IdempotencyStoreMock{}, nil, nil, httphandler.Config{Holds: holds})
+
+		v := validateCart(t, router)
+
+		if v.Valid || v.CorrectionsToken == "" {
+			t.Fatalf("expected problems, got %+v", v)
+		}
+		codes := map[string]string{}
+		for _, p := range v.Problems {
+			codes[p.ProductID] = p.Code
+		}
+		want := map[string]string{
+			"p1":      cartpkg.ProblemOutOfStock,
+			"p2":      cartpkg.ProblemPriceChanged,
+			"retired": cartpkg.ProblemProductInactive,
+			"gone":    cartpkg.ProblemProductNotFound,
+		}
+		if len(codes) != len(want) {
+			t.Fatalf("expected problems %v, got %+v", want, v.Problems)
+		}
+		for id, code := range want {
+			if codes[id] != code {
+				t.Fatalf("expected %s for %s, got %+v", code, id, v.Problems)
+			}
+		}
+		if p := v.Problems[0]; p.RequestedQuantity != 4 || p.AvailableQuantity == nil || *p.AvailableQuantity != 3 {
+			t.Fatalf("unexpected out of stock problem %+v", p)
+		}
+		if p := v.Problems[1]; *p.PreviousPrice != usd(400) || *p.CurrentPrice != usd(500) {
+			t.Fatalf("unexpected price problem %+v", p)
+		}
+
+		items := v.CorrectedCart.Items
+		if len(items) != 2 || items[0].Quantity != 3 || items[1].Price != usd(500) || v.CorrectedCart.Total != usd(3500) {
+			t.Fatalf("unexpected corrected cart %+v", v.CorrectedCart)
+		}
+		if got := holds.Held("123"); got["p1"] != 3 || got["p2"] != 1 || got["retired"] != 0 {
+			t.Fatalf("expected the corrected lines to be held, got %v", got)
+		}
+	})
+}
+
+func TestCheckoutRequiresAcceptedCorrections(t *testing.T) {
+	repo := &RepositoryMock{
+		GetCartFunc: cartWith(cartpkg.Item{ProductID: "p1", Quantity: 4, Price: usd(1000)}),
+		CheckoutCartFunc: func(ctx context.Context, c *cartpkg.Cart, evs []outbox.Event, idem *idempotency.Record) error {
+			return nil
+		},
+	}
+	publisher := &RabbitCartEventsPublisherMock{CartCheckedOutEventsFunc: func(c *cartpkg.Cart, metadata events.PublishMetadata) []outbox.Event {
+		return nil
+	}}
+	prices := pricing.NewInMemorySource(map[string]money.Money{"p1": usd(1000)})
+	holds := inventory.NewInMemoryHolds(map[string]int{"p1": 3})
+	router := httphandler.NewRouter(repo, publisher, prices, &IdempotencyStoreMock{}, nil, nil, httphandler.Config{Holds: holds})
+
+	checkout := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/cart/123/checkout", strings.NewReader(body)))
+		return w
+	}
+
+	w := checkout(`{"acceptCorrections":"stale"}`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a stale token, got %d", w.Code)
+	}
+	var v cartpkg.Validation
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decode validation: %v", err)
+	}
+	if v.Valid || len(v.Problems) != 1 || v.Problems[0].Code != cartpkg.ProblemOutOfStock {
+		t.Fatalf("unexpected validation %+v", v)
+	}
+
+	if w := checkout(`{"acceptCorrections":`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid json, got %d", w.Code)
+	}
+
+	w = checkout(`{"acceptCorrections":"` + v.CorrectionsToken + `"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	calls := repo.CheckoutCartCalls()
+	if len(calls) != 1 || calls[0].C.Items[0].Quantity != 3 || calls[0].C.Total != usd(3000) {
+		t.Fatalf("expected the corrected cart to be checked out, got %+v", calls)
+	}
+}
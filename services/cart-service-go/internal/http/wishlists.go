@@ -0,0 +1,438 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/problem"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/wishlist"
+	"github.com/google/uuid"
+)
+
+// ListWishlists returns the user's wishlists with their items.
+func (h *CartHandler) ListWishlists(w http.ResponseWriter, r *http.Request) {
+	userID, ok := wishlistOwner(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	lists, err := h.wishlists.List(ctx, userID)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to load wishlists")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"wishlists": lists})
+}
+
+// CreateWishlist starts a new, empty named list.
+func (h *CartHandler) CreateWishlist(w http.ResponseWriter, r *http.Request) {
+	userID, ok := wishlistOwner(w, r)
+	if !ok {
+		return
+	}
+	name, ok := decodeWishlistName(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	list, err := h.wishlists.Create(ctx, userID, name)
+	if err != nil {
+		writeWishlistError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, list)
+}
+
+func (h *CartHandler) GetWishlist(w http.ResponseWriter, r *http.Request) {
+	userID, listID, ok := wishlistPath(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	list, err := h.wishlists.Get(ctx, userID, listID)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to load wishlist")
+		return
+	}
+	if list == nil {
+		writeError(w, r, http.StatusNotFound, codeWishlistNotFound, "wishlist not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (h *CartHandler) RenameWishlist(w http.ResponseWriter, r *http.Request) {
+	userID, listID, ok := wishlistPath(w, r)
+	if !ok {
+		return
+	}
+	name, ok := decodeWishlistName(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	list, err := h.wishlists.Rename(ctx, userID, listID, name)
+	if err != nil {
+		writeWishlistError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (h *CartHandler) DeleteWishlist(w http.ResponseWriter, r *http.Request) {
+	userID, listID, ok := wishlistPath(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	if err := h.wishlists.Delete(ctx, userID, listID); err != nil {
+		writeWishlistError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddWishlistItem parks a product on the list. The product must exist in the
+// catalog; adding one already on the list adds to its quantity.
+func (h *CartHandler) AddWishlistItem(w http.ResponseWriter, r *http.Request) {
+	userID, listID, ok := wishlistPath(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		ProductID string `json:"productId"`
+		Quantity  int    `json:"quantity"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "invalid json")
+		return
+	}
+	if body.ProductID == "" {
+		writeInvalid(w, r, "productId", problem.FieldRequired, "missing productId")
+		return
+	}
+	if body.Quantity == 0 {
+		body.Quantity = 1
+	}
+	if body.Quantity < 0 {
+		writeInvalid(w, r, "quantity", problem.FieldInvalid, "quantity must be greater than zero")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	if _, err := h.prices.Price(ctx, body.ProductID); err != nil {
+		writePriceError(w, r, err)
+		return
+	}
+
+	list, err := h.wishlists.AddItem(ctx, userID, listID, wishlist.Item{ProductID: body.ProductID, Quantity: body.Quantity})
+	if err != nil {
+		writeWishlistError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (h *CartHandler) RemoveWishlistItem(w http.ResponseWriter, r *http.Request) {
+	userID, listID, ok := wishlistPath(w, r)
+	if !ok {
+		return
+	}
+	productID := r.PathValue("productId")
+	if productID == "" {
+		writeInvalid(w, r, "productId", problem.FieldRequired, "missing productId")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	list, err := h.wishlists.RemoveItem(ctx, userID, listID, productID)
+	if err != nil {
+		writeWishlistError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// ShareWishlist gives the list a share token, or returns the one it has.
+func (h *CartHandler) ShareWishlist(w http.ResponseWriter, r *http.Request) {
+	userID, listID, ok := wishlistPath(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	list, err := h.wishlists.Share(ctx, userID, listID)
+	if err != nil {
+		writeWishlistError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// UnshareWishlist revokes the list's share token.
+func (h *CartHandler) UnshareWishlist(w http.ResponseWriter, r *http.Request) {
+	userID, listID, ok := wishlistPath(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	list, err := h.wishlists.Unshare(ctx, userID, listID)
+	if err != nil {
+		writeWishlistError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// sharedWishlist is the read-only view of a wishlist given to anyone with its
+// share token. It leaves out who owns the list.
+type sharedWishlist struct {
+	Name      string          `json:"name"`
+	Items     []wishlist.Item `json:"items"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
+
+func (h *CartHandler) GetSharedWishlist(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+	if token == "" {
+		writeInvalid(w, r, "token", problem.FieldRequired, "missing token")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	list, err := h.wishlists.GetShared(ctx, token)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to load wishlist")
+		return
+	}
+	if list == nil {
+		writeError(w, r, http.StatusNotFound, codeWishlistNotFound, "wishlist not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, sharedWishlist{Name: list.Name, Items: list.Items, UpdatedAt: list.UpdatedAt})
+}
+
+// MoveToWishlist takes a line out of the cart and puts it on a wishlist, the
+// "Saved for later" list unless the body names one. Both change together.
+func (h *CartHandler) MoveToWishlist(w http.ResponseWriter, r *http.Request) {
+	userID, ok := wishlistOwner(w, r)
+	if !ok {
+		return
+	}
+	productID := r.PathValue("productId")
+	if productID == "" {
+		writeInvalid(w, r, "productId", problem.FieldRequired, "missing productId")
+		return
+	}
+
+	// The body is optional.
+	var body struct {
+		WishlistID string `json:"wishlistId"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "invalid json")
+		return
+	}
+	if body.WishlistID != "" && uuid.Validate(body.WishlistID) != nil {
+		writeError(w, r, http.StatusNotFound, codeWishlistNotFound, "wishlist not found")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	var moved cart.Item
+	c, err := h.saveCartChange(ctx, userID, parseIfMatch(r), false, func(c *cart.Cart) error {
+		idx := findItem(c, productID)
+		if idx < 0 {
+			return errItemNotFound
+		}
+		moved = c.Items[idx]
+		c.Items = append(c.Items[:idx], c.Items[idx+1:]...)
+		return nil
+	}, func(ctx context.Context, c *cart.Cart) error {
+		return h.repo.MoveToWishlist(ctx, c, body.WishlistID, wishlist.Item{ProductID: moved.ProductID, Quantity: moved.Quantity}, h.activity(r))
+	})
+	if err != nil {
+		writeMoveError(w, r, err)
+		return
+	}
+	h.releaseStock(ctx, userID, productID)
+
+	h.writeCart(ctx, w, r, http.StatusOK, c)
+}
+
+// MoveToCart takes a product off a wishlist and adds it to the cart at the
+// current catalog price, creating the cart if needed. The stock is held as for
+// any other add, and the wishlist keeps the product if it cannot be.
+func (h *CartHandler) MoveToCart(w http.ResponseWriter, r *http.Request) {
+	userID, listID, ok := wishlistPath(w, r)
+	if !ok {
+		return
+	}
+	productID := r.PathValue("productId")
+	if productID == "" {
+		writeInvalid(w, r, "productId", problem.FieldRequired, "missing productId")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	list, err := h.wishlists.Get(ctx, userID, listID)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to load wishlist")
+		return
+	}
+	if list == nil {
+		writeError(w, r, http.StatusNotFound, codeWishlistNotFound, "wishlist not found")
+		return
+	}
+	idx := list.FindItem(productID)
+	if idx < 0 {
+		writeError(w, r, http.StatusNotFound, codeItemNotFound, "item not found")
+		return
+	}
+	parked := list.Items[idx]
+
+	price, err := h.prices.Price(ctx, productID)
+	if err != nil {
+		writePriceError(w, r, err)
+		return
+	}
+
+	c, err := h.saveCartChange(ctx, userID, parseIfMatch(r), true, func(c *cart.Cart) error {
+		if idx := findItem(c, productID); idx >= 0 {
+			if err := h.holdStock(ctx, userID, productID, c.Items[idx].Quantity+parked.Quantity); err != nil {
+				return err
+			}
+			c.Items[idx].Quantity += parked.Quantity
+			c.Items[idx].Price = price
+			c.Items[idx].PricedAt = time.Now().UTC()
+			return nil
+		}
+		if err := h.holdStock(ctx, userID, productID, parked.Quantity); err != nil {
+			return err
+		}
+		c.Items = append(c.Items, cart.Item{
+			ProductID: productID,
+			Quantity:  parked.Quantity,
+			Price:     price,
+			PricedAt:  time.Now().UTC(),
+		})
+		return nil
+	}, func(ctx context.Context, c *cart.Cart) error {
+		return h.repo.MoveFromWishlist(ctx, c, listID, productID, h.activity(r))
+	})
+	if err != nil {
+		writeMoveError(w, r, err)
+		return
+	}
+
+	h.writeCart(ctx, w, r, http.StatusOK, c)
+}
+
+// wishlistOwner reads the owner from the path. Wishlists belong to signed-in
+// users only; guest IDs are refused.
+func wishlistOwner(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID := r.PathValue("userId")
+	if userID == "" {
+		writeInvalid(w, r, "userId", problem.FieldRequired, "missing userId")
+		return "", false
+	}
+	if cart.IsGuestID(userID) {
+		writeError(w, r, http.StatusBadRequest, codeGuestNotAllowed, "wishlists require a signed-in user")
+		return "", false
+	}
+	return userID, true
+}
+
+// wishlistPath reads the owner and list ID from the path. List IDs are UUIDs,
+// so anything else cannot name a list.
+func wishlistPath(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	userID, ok := wishlistOwner(w, r)
+	if !ok {
+		return "", "", false
+	}
+	listID := r.PathValue("listId")
+	if uuid.Validate(listID) != nil {
+		writeError(w, r, http.StatusNotFound, codeWishlistNotFound, "wishlist not found")
+		return "", "", false
+	}
+	return userID, listID, true
+}
+
+func decodeWishlistName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var body struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "invalid json")
+		return "", false
+	}
+	name, err := wishlist.NormalizeName(body.Name)
+	if err != nil {
+		writeInvalid(w, r, "name", problem.FieldInvalid, err.Error())
+		return "", false
+	}
+	return name, true
+}
+
+// writeWishlistError maps errors from the wishlist endpoints to responses.
+func writeWishlistError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, wishlist.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, codeWishlistNotFound, "wishlist not found")
+	case errors.Is(err, wishlist.ErrItemNotFound):
+		writeError(w, r, http.StatusNotFound, codeItemNotFound, "item not found")
+	case errors.Is(err, wishlist.ErrNameTaken):
+		writeError(w, r, http.StatusConflict, codeWishlistNameTaken, "wishlist name already in use")
+	default:
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to save wishlist")
+	}
+}
+
+// writeMoveError maps errors from moving a line between the cart and a
+// wishlist, which can come from either side.
+func writeMoveError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, wishlist.ErrNotFound) || errors.Is(err, wishlist.ErrItemNotFound) {
+		writeWishlistError(w, r, err)
+		return
+	}
+	writeCartUpdateError(w, r, err)
+}
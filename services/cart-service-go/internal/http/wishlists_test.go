@@ -0,0 +1,221 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cartpkg "github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	httphandler "github.com/andreasstove999/ecommerce-system/cart-service-go/internal/http"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/inventory"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/pricing"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/wishlist"
+)
+
+func wishlistRouter(repo *RepositoryMock, lists wishlist.Repository, holds inventory.Holds) http.Handler {
+	prices := pricing.NewInMemorySource(map[string]money.Money{"p1": usd(1000), "p2": usd(500)})
+	return httphandler.NewRouter(repo, nil, prices, &IdempotencyStoreMock{}, nil, nil, httphandler.Config{Holds: holds, Wishlists: lists})
+}
+
+func decodeWishlist(t *testing.T, w *httptest.ResponseRecorder) wishlist.Wishlist {
+	t.Helper()
+	var list wishlist.Wishlist
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode wishlist: %v", err)
+	}
+	return list
+}
+
+func TestWishlistEndpoints(t *testing.T) {
+	lists := wishlist.NewInMemoryRepository()
+	router := wishlistRouter(&RepositoryMock{}, lists, nil)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	w := do(http.MethodPost, "/api/wishlists/u1", `{"name":" Birthday "}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	list := decodeWishlist(t, w)
+	if list.Name != "Birthday" {
+		t.Fatalf("expected trimmed name, got %q", list.Name)
+	}
+	base := "/api/wishlists/u1/" + list.ID
+
+	if w := do(http.MethodPost, "/api/wishlists/u1", `{"name":"Birthday"}`); w.Code != http.StatusConflict {
+		t.Fatalf("duplicate name: expected 409, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/wishlists/u1", `{"name":""}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("empty name: expected 400, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, base+"/items", `{"productId":"nope"}`); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown product: expected 404, got %d", w.Code)
+	}
+
+	w = do(http.MethodPost, base+"/items", `{"productId":"p1"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("add item: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if items := decodeWishlist(t, w).Items; len(items) != 1 || items[0].Quantity != 1 {
+		t.Fatalf("expected p1 x1 on the list, got %+v", items)
+	}
+
+	w = do(http.MethodPost, base+"/share", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("share: expected 200, got %d", w.Code)
+	}
+	token := decodeWishlist(t, w).ShareToken
+	if token == "" {
+		t.Fatalf("expected a share token")
+	}
+
+	w = do(http.MethodGet, "/api/wishlists/shared/"+token, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("shared: expected 200, got %d", w.Code)
+	}
+	if body := w.Body.String(); !strings.Contains(body, `"name":"Birthday"`) || strings.Contains(body, "u1") {
+		t.Fatalf("expected the shared view without the owner, got %s", body)
+	}
+
+	if w := do(http.MethodGet, "/api/wishlists/u2/"+list.ID, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("other user: expected 404, got %d", w.Code)
+	}
+
+	do(http.MethodDelete, base+"/share", "")
+	if w := do(http.MethodGet, "/api/wishlists/shared/"+token, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("revoked token: expected 404, got %d", w.Code)
+	}
+
+	if w := do(http.MethodPatch, base, `{"name":"Christmas"}`); w.Code != http.StatusOK || decodeWishlist(t, w).Name != "Christmas" {
+		t.Fatalf("rename: got %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodDelete, base, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, base, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("deleted list: expected 404, got %d", w.Code)
+	}
+}
+
+func TestWishlistRequiresUser(t *testing.T) {
+	router := wishlistRouter(&RepositoryMock{}, wishlist.NewInMemoryRepository(), nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/wishlists/"+cartpkg.NewGuestID(), nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("guest: expected 400, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/wishlists/u1/not-a-uuid", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("bad list ID: expected 404, got %d", w.Code)
+	}
+}
+
+func TestMoveToWishlist(t *testing.T) {
+	lists := wishlist.NewInMemoryRepository()
+	holds := inventory.NewInMemoryHolds(map[string]int{"p1": 5})
+	if err := holds.Hold(context.Background(), "u1", "p1", 2, 0); err != nil {
+		t.Fatalf("seed hold: %v", err)
+	}
+	repo := &RepositoryMock{
+		GetCartFunc: cartWith(
+			cartpkg.Item{ProductID: "p1", Quantity: 2, Price: usd(1000)},
+			cartpkg.Item{ProductID: "p2", Quantity: 1, Price: usd(500)},
+		),
+		MoveToWishlistFunc: func(ctx context.Context, c *cartpkg.Cart, listID string, item wishlist.Item, activity cartpkg.ActivityFunc) error {
+			_, err := lists.AddItem(ctx, c.UserID, listID, item)
+			return err
+		},
+	}
+	w := httptest.NewRecorder()
+
+	wishlistRouter(repo, lists, holds).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/cart/u1/items/p1/move-to-wishlist", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	saved := repo.MoveToWishlistCalls()[0].C
+	if len(saved.Items) != 1 || saved.Items[0].ProductID != "p2" {
+		t.Fatalf("expected only p2 left in the cart, got %+v", saved.Items)
+	}
+	got, _ := lists.List(context.Background(), "u1")
+	if len(got) != 1 || got[0].Name != wishlist.SavedForLater || got[0].Items[0].Quantity != 2 {
+		t.Fatalf("expected p1 x2 on the saved for later list, got %+v", got)
+	}
+	if held := holds.Held("u1"); held["p1"] != 0 {
+		t.Fatalf("expected the hold to be released, got %v", held)
+	}
+}
+
+func TestMoveToCart(t *testing.T) {
+	ctx := context.Background()
+	lists := wishlist.NewInMemoryRepository()
+	list, _ := lists.Create(ctx, "u1", "Later")
+	if _, err := lists.AddItem(ctx, "u1", list.ID, wishlist.Item{ProductID: "p2", Quantity: 2}); err != nil {
+		t.Fatalf("seed list: %v", err)
+	}
+	target := "/api/wishlists/u1/" + list.ID + "/items/p2/move-to-cart"
+
+	t.Run("creates the cart at the current price", func(t *testing.T) {
+		holds := inventory.NewInMemoryHolds(map[string]int{"p2": 5})
+		repo := &RepositoryMock{
+			GetCartFunc: func(ctx context.Context, userID string) (*cartpkg.Cart, error) { return nil, nil },
+			MoveFromWishlistFunc: func(ctx context.Context, c *cartpkg.Cart, listID, productID string, activity cartpkg.ActivityFunc) error {
+				_, err := lists.RemoveItem(ctx, c.UserID, listID, productID)
+				return err
+			},
+		}
+		w := httptest.NewRecorder()
+
+		wishlistRouter(repo, lists, holds).ServeHTTP(w, httptest.NewRequest(http.MethodPost, target, nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		c := repo.MoveFromWishlistCalls()[0].C
+		if len(c.Items) != 1 || c.Items[0].Quantity != 2 || c.Items[0].Price != usd(500) {
+			t.Fatalf("expected p2 x2 at 5.00, got %+v", c.Items)
+		}
+		if held := holds.Held("u1"); held["p2"] != 2 {
+			t.Fatalf("expected 2 held, got %v", held)
+		}
+		if l, _ := lists.Get(ctx, "u1", list.ID); len(l.Items) != 0 {
+			t.Fatalf("expected the list to be empty, got %+v", l.Items)
+		}
+	})
+
+	t.Run("item not on list", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		wishlistRouter(&RepositoryMock{}, lists, nil).ServeHTTP(w, httptest.NewRequest(http.MethodPost, target, nil))
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d", w.Code)
+		}
+	})
+
+	t.Run("short stock keeps the item on the list", func(t *testing.T) {
+		if _, err := lists.AddItem(ctx, "u1", list.ID, wishlist.Item{ProductID: "p1", Quantity: 3}); err != nil {
+			t.Fatalf("seed list: %v", err)
+		}
+		holds := inventory.NewInMemoryHolds(map[string]int{"p1": 1})
+		repo := &RepositoryMock{GetCartFunc: cartWith()}
+		w := httptest.NewRecorder()
+
+		wishlistRouter(repo, lists, holds).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/wishlists/u1/"+list.ID+"/items/p1/move-to-cart", nil))
+
+		if w.Code != http.StatusConflict {
+			t.Fatalf("expected 409, got %d: %s", w.Code, w.Body.String())
+		}
+		if len(repo.MoveFromWishlistCalls()) != 0 {
+			t.Fatalf("expected nothing to be saved")
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// HeaderKey is the request header clients use to make a request safe to retry.
+const HeaderKey = "Idempotency-Key"
+
+// MaxKeyLength bounds the accepted header value.
+const MaxKeyLength = 255
+
+// Record is a stored response for a (user, key) pair.
+type Record struct {
+	UserID     string
+	Key        string
+	StatusCode int
+	Body       []byte
+	CreatedAt  time.Time
+}
+
+// Save stores rec using tx so the response is only remembered if the work it
+// describes commits.
+func Save(ctx context.Context, tx *sql.Tx, rec Record) error {
+	const insertSQL = `
+INSERT INTO idempotency_keys (user_id, idempotency_key, status_code, response_body, created_at)
+VALUES ($1, $2, $3, $4, NOW())
+ON CONFLICT (user_id, idempotency_key) DO NOTHING
+`
+	if _, err := tx.ExecContext(ctx, insertSQL, rec.UserID, rec.Key, rec.StatusCode, rec.Body); err != nil {
+		return fmt.Errorf("insert idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Store looks up responses recorded within the retention window.
+type Store interface {
+	// Lookup returns nil, nil when no live record exists for the key.
+	Lookup(ctx context.Context, userID, key string) (*Record, error)
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type store struct {
+	db        *sql.DB
+	retention time.Duration
+}
+
+func NewStore(db *sql.DB, retention time.Duration) Store {
+	return &store{db: db, retention: retention}
+}
+
+func (s *store) Lookup(ctx context.Context, userID, key string) (*Record, error) {
+	const query = `
+SELECT user_id, idempotency_key, status_code, response_body, created_at
+FROM idempotency_keys
+WHERE user_id = $1 AND idempotency_key = $2 AND created_at > $3
+`
+	var rec Record
+	err := s.db.QueryRowContext(ctx, query, userID, key, time.Now().Add(-s.retention)).
+		Scan(&rec.UserID, &rec.Key, &rec.StatusCode, &rec.Body, &rec.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("select idempotency key: %w", err)
+	}
+	return &rec, nil
+}
+
+func (s *store) DeleteExpired(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM idempotency_keys WHERE created_at <= $1`, time.Now().Add(-s.retention))
+	if err != nil {
+		return 0, fmt.Errorf("delete expired idempotency keys: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
+
+// InMemoryStore is a Store backed by a map, used in tests and local runs.
+type InMemoryStore struct {
+	mu        sync.Mutex
+	retention time.Duration
+	records   map[[2]string]Record
+}
+
+func NewInMemoryStore(retention time.Duration) *InMemoryStore {
+	return &InMemoryStore{retention: retention, records: make(map[[2]string]Record)}
+}
+
+// Save stores rec unless the key is already taken, like Save does in a
+// transaction.
+func (m *InMemoryStore) Save(rec Record) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := [2]string{rec.UserID, rec.Key}
+	if _, ok := m.records[k]; ok {
+		return
+	}
+	rec.CreatedAt = time.Now()
+	m.records[k] = rec
+}
+
+func (m *InMemoryStore) Lookup(ctx context.Context, userID, key string) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.records[[2]string{userID, key}]
+	if !ok || !rec.CreatedAt.After(time.Now().Add(-m.retention)) {
+		return nil, nil
+	}
+	return &rec, nil
+}
+
+func (m *InMemoryStore) DeleteExpired(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	cutoff := time.Now().Add(-m.retention)
+	for k, rec := range m.records {
+		if !rec.CreatedAt.After(cutoff) {
+			delete(m.records, k)
+			n++
+		}
+	}
+	return n, nil
+}
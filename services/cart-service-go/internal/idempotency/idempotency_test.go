@@ -0,0 +1,76 @@
+package idempotency
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestLookup(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	s := NewStore(db, time.Hour)
+
+	query := regexp.QuoteMeta("FROM idempotency_keys")
+	mock.ExpectQuery(query).
+		WithArgs("u1", "k1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "idempotency_key", "status_code", "response_body", "created_at"}).
+			AddRow("u1", "k1", 200, []byte(`{"status":"checkout completed"}`), time.Now()))
+	mock.ExpectQuery(query).
+		WithArgs("u1", "k2", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "idempotency_key", "status_code", "response_body", "created_at"}))
+
+	rec, err := s.Lookup(context.Background(), "u1", "k1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rec == nil || rec.StatusCode != 200 || string(rec.Body) != `{"status":"checkout completed"}` {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+
+	rec, err = s.Lookup(context.Background(), "u1", "k2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rec != nil {
+		t.Fatalf("expected no record for unknown key, got %+v", rec)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestSaveIgnoresDuplicateKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("ON CONFLICT (user_id, idempotency_key) DO NOTHING")).
+		WithArgs("u1", "k1", 200, []byte("{}")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if err := Save(context.Background(), tx, Record{UserID: "u1", Key: "k1", StatusCode: 200, Body: []byte("{}")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
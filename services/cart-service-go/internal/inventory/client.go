@@ -0,0 +1,113 @@
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client takes holds through inventory-service's holds API. Carts are the
+// holders, identified by the cart's user or guest ID.
+type Client struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewClient(baseURL string, client *http.Client) *Client {
+	if client == nil {
+		client = &http.Client{Timeout: 2 * time.Second}
+	}
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), client: client}
+}
+
+type shortage struct {
+	ProductID string `json:"productId"`
+	Requested int    `json:"requested"`
+	Free      int    `json:"free"`
+}
+
+func (c *Client) Hold(ctx context.Context, cartID, productID string, quantity int, ttl time.Duration) error {
+	body := map[string]int{"quantity": quantity, "ttlSeconds": ttlSeconds(ttl)}
+	resp, err := c.do(ctx, http.MethodPut, c.holdsPath(cartID)+"/"+url.PathEscape(productID), body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusConflict:
+		s := shortage{ProductID: productID, Requested: quantity}
+		if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+			return fmt.Errorf("decode inventory shortage: %w", err)
+		}
+		return &ShortageError{ProductID: s.ProductID, Requested: s.Requested, Free: s.Free}
+	default:
+		return fmt.Errorf("inventory returned status %d", resp.StatusCode)
+	}
+}
+
+func (c *Client) Release(ctx context.Context, cartID, productID string) error {
+	return c.expect(ctx, http.MethodDelete, c.holdsPath(cartID)+"/"+url.PathEscape(productID), nil, http.StatusNoContent)
+}
+
+func (c *Client) ReleaseAll(ctx context.Context, cartID string) error {
+	return c.expect(ctx, http.MethodDelete, c.holdsPath(cartID), nil, http.StatusNoContent)
+}
+
+func (c *Client) Renew(ctx context.Context, cartID string, ttl time.Duration) error {
+	return c.expect(ctx, http.MethodPost, c.holdsPath(cartID)+"/renew", map[string]int{"ttlSeconds": ttlSeconds(ttl)}, http.StatusOK)
+}
+
+func (c *Client) holdsPath(cartID string) string {
+	return c.baseURL + "/api/inventory/holds/" + url.PathEscape(cartID)
+}
+
+func (c *Client) expect(ctx context.Context, method, endpoint string, body any, status int) error {
+	resp, err := c.do(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		return fmt.Errorf("inventory returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (c *Client) do(ctx context.Context, method, endpoint string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("encode inventory request: %w", err)
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return nil, fmt.Errorf("build inventory request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("inventory request: %w", err)
+	}
+	return resp, nil
+}
+
+// ttlSeconds rounds up so a sub-second TTL still holds for a second.
+func ttlSeconds(ttl time.Duration) int {
+	return int((ttl + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,81 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient(t *testing.T) {
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		calls = append(calls, r.Method+" "+r.URL.Path+" "+string(body))
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/api/inventory/holds/cart-1/p1":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"holderId":"cart-1","productId":"p1","quantity":2}`))
+		case r.Method == http.MethodPut && r.URL.Path == "/api/inventory/holds/cart-1/short":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"productId":"short","requested":5,"free":1}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/inventory/holds/cart-1/renew":
+			_, _ = w.Write([]byte(`{"renewed":1}`))
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL+"/", srv.Client())
+	ctx := context.Background()
+
+	if err := c.Hold(ctx, "cart-1", "p1", 2, 15*time.Minute); err != nil {
+		t.Fatalf("hold: %v", err)
+	}
+
+	err := c.Hold(ctx, "cart-1", "short", 5, 15*time.Minute)
+	var shortage *ShortageError
+	if !errors.As(err, &shortage) || !errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("expected a shortage, got %v", err)
+	}
+	if shortage.ProductID != "short" || shortage.Requested != 5 || shortage.Free != 1 {
+		t.Fatalf("unexpected shortage %+v", shortage)
+	}
+
+	if err := c.Hold(ctx, "cart-1", "broken", 1, time.Minute); err == nil || errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("expected upstream error, got %v", err)
+	}
+	if err := c.Renew(ctx, "cart-1", 1500*time.Millisecond); err != nil {
+		t.Fatalf("renew: %v", err)
+	}
+	if err := c.Release(ctx, "cart-1", "p1"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if err := c.ReleaseAll(ctx, "cart-1"); err != nil {
+		t.Fatalf("release all: %v", err)
+	}
+
+	want := []string{
+		`PUT /api/inventory/holds/cart-1/p1 {"quantity":2,"ttlSeconds":900}`,
+		`PUT /api/inventory/holds/cart-1/short {"quantity":5,"ttlSeconds":900}`,
+		`PUT /api/inventory/holds/cart-1/broken {"quantity":1,"ttlSeconds":60}`,
+		`POST /api/inventory/holds/cart-1/renew {"ttlSeconds":2}`,
+		`DELETE /api/inventory/holds/cart-1/p1 `,
+		`DELETE /api/inventory/holds/cart-1 `,
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("calls = %q, want %q", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("call %d = %q, want %q", i, calls[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrInsufficientStock is matched by a ShortageError.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ShortageError is returned when a product does not have enough free stock
+// for a hold. Free is what could have been held instead.
+type ShortageError struct {
+	ProductID string
+	Requested int
+	Free      int
+}
+
+func (e *ShortageError) Error() string {
+	return fmt.Sprintf("insufficient stock for product %s: %d requested, %d free", e.ProductID, e.Requested, e.Free)
+}
+
+func (e *ShortageError) Is(target error) bool {
+	return target == ErrInsufficientStock
+}
+
+// Holds keeps stock aside for carts in inventory-service. Holds are soft: they
+// expire after their TTL unless renewed, and stock is only taken when an order
+// is placed.
+type Holds interface {
+	// Hold sets the cart's hold on a product to quantity. It returns a
+	// *ShortageError when the product lacks the free stock.
+	Hold(ctx context.Context, cartID, productID string, quantity int, ttl time.Duration) error
+	Release(ctx context.Context, cartID, productID string) error
+	ReleaseAll(ctx context.Context, cartID string) error
+	// Renew extends every unexpired hold of the cart by ttl from now.
+	Renew(ctx context.Context, cartID string, ttl time.Duration) error
+}
+
+// InMemoryHolds is a Holds backed by maps, used in tests and local runs. Holds
+// never expire.
+type InMemoryHolds struct {
+	mu    sync.Mutex
+	stock map[string]int
+	holds map[string]map[string]int
+}
+
+func NewInMemoryHolds(stock map[string]int) *InMemoryHolds {
+	m := &InMemoryHolds{stock: make(map[string]int, len(stock)), holds: make(map[string]map[string]int)}
+	for id, n := range stock {
+		m.stock[id] = n
+	}
+	return m
+}
+
+// Held returns the quantities held for a cart by product.
+func (m *InMemoryHolds) Held(cartID string) map[string]int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]int, len(m.holds[cartID]))
+	for id, n := range m.holds[cartID] {
+		out[id] = n
+	}
+	return out
+}
+
+func (m *InMemoryHolds) Hold(ctx context.Context, cartID, productID string, quantity int, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	free := m.stock[productID]
+	for holder, held := range m.holds {
+		if holder != cartID {
+			free -= held[productID]
+		}
+	}
+	if quantity > free {
+		return &ShortageError{ProductID: productID, Requested: quantity, Free: max(free, 0)}
+	}
+	if m.holds[cartID] == nil {
+		m.holds[cartID] = make(map[string]int)
+	}
+	m.holds[cartID][productID] = quantity
+	return nil
+}
+
+func (m *InMemoryHolds) Release(ctx context.Context, cartID, productID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.holds[cartID], productID)
+	return nil
+}
+
+func (m *InMemoryHolds) ReleaseAll(ctx context.Context, cartID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.holds, cartID)
+	return nil
+}
+
+func (m *InMemoryHolds) Renew(ctx context.Context, cartID string, ttl time.Duration) error {
+	return nil
+}
@@ -0,0 +1,71 @@
+package money
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	cases := []struct {
+		in       string
+		currency string
+		want     Money
+	}{
+		{"49.99", "USD", New(4999, "USD")},
+		{"0.1", "eur", New(10, "EUR")},
+		{"1.0E2", "USD", New(10000, "USD")},
+		{"1500", "JPY", New(1500, "JPY")},
+		{"1.2345", "KWD", New(1235, "KWD")},
+		{"0.005", "USD", New(1, "USD")},
+		{"-0.005", "USD", New(-1, "USD")},
+	}
+	for _, tc := range cases {
+		got, err := ParseDecimal(tc.in, tc.currency)
+		if err != nil {
+			t.Fatalf("ParseDecimal(%q): %v", tc.in, err)
+		}
+		if got != tc.want {
+			t.Fatalf("ParseDecimal(%q, %s) = %+v, want %+v", tc.in, tc.currency, got, tc.want)
+		}
+	}
+
+	if _, err := ParseDecimal("abc", "USD"); err == nil {
+		t.Fatalf("expected error for invalid amount")
+	}
+	if _, err := ParseDecimal("1", "US"); err == nil {
+		t.Fatalf("expected error for invalid currency")
+	}
+}
+
+func TestSumIsExact(t *testing.T) {
+	// 0.1 + 0.2 drifts as float64; in minor units it does not.
+	total, err := Sum("USD", New(10, "USD"), New(20, "USD"), New(1999, "USD").Mul(3))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if total != New(6027, "USD") {
+		t.Fatalf("unexpected total %+v", total)
+	}
+	if total.Decimal() != "60.27" {
+		t.Fatalf("unexpected decimal %s", total.Decimal())
+	}
+
+	if _, err := Sum("USD", New(1, "EUR")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("expected ErrCurrencyMismatch, got %v", err)
+	}
+}
+
+func TestDecimalAndFloat(t *testing.T) {
+	if got := New(-5, "USD").Decimal(); got != "-0.05" {
+		t.Fatalf("unexpected decimal %s", got)
+	}
+	if got := New(1500, "JPY").String(); got != "1500 JPY" {
+		t.Fatalf("unexpected string %s", got)
+	}
+	if got := New(4999, "USD").Float64(); got != 49.99 {
+		t.Fatalf("unexpected float %v", got)
+	}
+	if got := FromFloat(299.97, "USD"); got != New(29997, "USD") {
+		t.Fatalf("unexpected money %+v", got)
+	}
+}
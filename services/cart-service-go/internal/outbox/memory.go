@@ -0,0 +1,136 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// InMemoryStore is an outbox kept in memory, used in tests and local runs.
+// It allocates sequences per partition like the outbox table and serves the
+// relay the same way.
+type InMemoryStore struct {
+	mu        sync.Mutex
+	nextID    int64
+	sequences map[string]int64
+	messages  []memoryMessage
+}
+
+type memoryMessage struct {
+	Message
+	nextAttemptAt time.Time
+	publishedAt   time.Time
+}
+
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{sequences: make(map[string]int64)}
+}
+
+// Enqueue allocates sequences for the events and stores them, all or none:
+// if any event fails to encode nothing is stored and no sequence is used.
+func (s *InMemoryStore) Enqueue(evs ...Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	taken := make(map[string]int64)
+	msgs := make([]memoryMessage, 0, len(evs))
+	now := time.Now()
+	for _, ev := range evs {
+		if ev.PartitionKey == "" {
+			return fmt.Errorf("partition key is required")
+		}
+		taken[ev.PartitionKey]++
+		seq := s.sequences[ev.PartitionKey] + taken[ev.PartitionKey]
+
+		body, err := ev.Encode(seq)
+		if err != nil {
+			return fmt.Errorf("encode event: %w", err)
+		}
+		msgs = append(msgs, memoryMessage{
+			Message:       Message{PartitionKey: ev.PartitionKey, Sequence: seq, RoutingKey: ev.RoutingKey, Payload: body},
+			nextAttemptAt: now,
+		})
+	}
+
+	for key, n := range taken {
+		s.sequences[key] += n
+	}
+	for _, m := range msgs {
+		s.nextID++
+		m.ID = s.nextID
+		s.messages = append(s.messages, m)
+	}
+	return nil
+}
+
+// Messages returns every stored message, published or not, in insertion order.
+func (s *InMemoryStore) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Message, len(s.messages))
+	for i, m := range s.messages {
+		out[i] = m.Message
+	}
+	return out
+}
+
+func (s *InMemoryStore) FetchPending(ctx context.Context, limit int) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	blocked := make(map[string]bool)
+	var msgs []Message
+	for _, m := range s.messages {
+		if len(msgs) == limit {
+			break
+		}
+		if !m.publishedAt.IsZero() || blocked[m.PartitionKey] {
+			continue
+		}
+		// Only the oldest unpublished message of a partition may go out.
+		blocked[m.PartitionKey] = true
+		if m.nextAttemptAt.After(now) {
+			continue
+		}
+		msgs = append(msgs, m.Message)
+	}
+	return msgs, nil
+}
+
+func (s *InMemoryStore) MarkPublished(ctx context.Context, id int64) error {
+	return s.update(id, func(m *memoryMessage) {
+		m.publishedAt = time.Now()
+	})
+}
+
+func (s *InMemoryStore) MarkFailed(ctx context.Context, id int64, reason string, nextAttempt time.Time) error {
+	return s.update(id, func(m *memoryMessage) {
+		m.Attempts++
+		m.nextAttemptAt = nextAttempt
+	})
+}
+
+func (s *InMemoryStore) DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.messages)
+	s.messages = slices.DeleteFunc(s.messages, func(m memoryMessage) bool {
+		return !m.publishedAt.IsZero() && m.publishedAt.Before(before)
+	})
+	return int64(n - len(s.messages)), nil
+}
+
+func (s *InMemoryStore) update(id int64, fn func(m *memoryMessage)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.messages {
+		if s.messages[i].ID == id {
+			fn(&s.messages[i])
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func memoryEvent(partitionKey, routingKey string) Event {
+	return Event{
+		PartitionKey: partitionKey,
+		RoutingKey:   routingKey,
+		Encode:       func(sequence int64) ([]byte, error) { return []byte(routingKey), nil },
+	}
+}
+
+func TestInMemoryStoreEnqueue(t *testing.T) {
+	s := NewInMemoryStore()
+
+	if err := s.Enqueue(memoryEvent("c1", "a"), memoryEvent("c2", "b"), memoryEvent("c1", "c")); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	failing := Event{PartitionKey: "c1", RoutingKey: "d", Encode: func(int64) ([]byte, error) { return nil, errors.New("boom") }}
+	if err := s.Enqueue(memoryEvent("c1", "ok"), failing); err == nil {
+		t.Fatalf("expected the encode error")
+	}
+	if err := s.Enqueue(memoryEvent("c1", "e")); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	var got []string
+	for _, m := range s.Messages() {
+		got = append(got, fmt.Sprintf("%s:%s:%d", m.PartitionKey, m.RoutingKey, m.Sequence))
+	}
+	want := []string{"c1:a:1", "c2:b:1", "c1:c:2", "c1:e:3"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestInMemoryStoreFetchPending(t *testing.T) {
+	ctx := context.Background()
+	s := NewInMemoryStore()
+	if err := s.Enqueue(memoryEvent("c1", "a"), memoryEvent("c1", "b"), memoryEvent("c2", "c")); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	msgs, _ := s.FetchPending(ctx, 10)
+	if len(msgs) != 2 || msgs[0].RoutingKey != "a" || msgs[1].RoutingKey != "c" {
+		t.Fatalf("expected the oldest message of each partition, got %+v", msgs)
+	}
+
+	// A failed message holds back the rest of its partition until it is due.
+	_ = s.MarkFailed(ctx, msgs[0].ID, "broker down", time.Now().Add(time.Hour))
+	_ = s.MarkPublished(ctx, msgs[1].ID)
+	if msgs, _ = s.FetchPending(ctx, 10); len(msgs) != 0 {
+		t.Fatalf("expected nothing due, got %+v", msgs)
+	}
+
+	_ = s.MarkFailed(ctx, 1, "broker down", time.Now().Add(-time.Second))
+	msgs, _ = s.FetchPending(ctx, 10)
+	if len(msgs) != 1 || msgs[0].RoutingKey != "a" || msgs[0].Attempts != 2 {
+		t.Fatalf("expected the retried message, got %+v", msgs)
+	}
+	_ = s.MarkPublished(ctx, msgs[0].ID)
+	if msgs, _ = s.FetchPending(ctx, 10); len(msgs) != 1 || msgs[0].RoutingKey != "b" {
+		t.Fatalf("expected the next message of the partition, got %+v", msgs)
+	}
+
+	if n, _ := s.DeletePublishedBefore(ctx, time.Now().Add(time.Second)); n != 2 {
+		t.Fatalf("expected 2 published messages deleted, got %d", n)
+	}
+	if len(s.Messages()) != 1 {
+		t.Fatalf("expected the unpublished message kept, got %+v", s.Messages())
+	}
+}
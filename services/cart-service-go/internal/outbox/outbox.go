@@ -0,0 +1,146 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Event is an event to be written to the outbox as part of a business transaction.
+// Encode renders the message body once the partition sequence has been allocated,
+// so the sequence is taken in the same transaction as the change that caused it.
+type Event struct {
+	PartitionKey string
+	RoutingKey   string
+	Encode       func(sequence int64) ([]byte, error)
+}
+
+// Message is a stored outbox row waiting to be relayed to the broker.
+type Message struct {
+	ID           int64
+	PartitionKey string
+	Sequence     int64
+	RoutingKey   string
+	Payload      []byte
+	Attempts     int
+}
+
+// Enqueue allocates the next sequence for the event's partition and stores the
+// encoded event in the outbox using tx. Nothing is published until the relay
+// picks the row up after tx commits.
+func Enqueue(ctx context.Context, tx *sql.Tx, ev Event) error {
+	if ev.PartitionKey == "" {
+		return fmt.Errorf("partition key is required")
+	}
+
+	const nextSequenceSQL = `
+INSERT INTO event_sequences (partition_key, last_sequence, updated_at)
+VALUES ($1, 1, NOW())
+ON CONFLICT (partition_key) DO UPDATE
+SET last_sequence = event_sequences.last_sequence + 1,
+    updated_at = NOW()
+RETURNING last_sequence
+`
+	var seq int64
+	if err := tx.QueryRowContext(ctx, nextSequenceSQL, ev.PartitionKey).Scan(&seq); err != nil {
+		return fmt.Errorf("increment sequence: %w", err)
+	}
+
+	body, err := ev.Encode(seq)
+	if err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+
+	const insertSQL = `
+INSERT INTO outbox (partition_key, sequence, routing_key, payload, created_at, next_attempt_at)
+VALUES ($1, $2, $3, $4, NOW(), NOW())
+`
+	if _, err := tx.ExecContext(ctx, insertSQL, ev.PartitionKey, seq, ev.RoutingKey, body); err != nil {
+		return fmt.Errorf("insert outbox row: %w", err)
+	}
+	return nil
+}
+
+// Store gives the relay access to pending outbox rows.
+type Store interface {
+	// FetchPending returns due, unpublished messages in insertion order. Only the
+	// oldest unpublished message of each partition is returned so a failing
+	// message holds back the rest of its partition.
+	FetchPending(ctx context.Context, limit int) ([]Message, error)
+	MarkPublished(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, reason string, nextAttempt time.Time) error
+	DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) Store {
+	return &store{db: db}
+}
+
+func (s *store) FetchPending(ctx context.Context, limit int) ([]Message, error) {
+	const query = `
+SELECT o.id, o.partition_key, o.sequence, o.routing_key, o.payload, o.attempts
+FROM outbox o
+WHERE o.published_at IS NULL
+  AND o.next_attempt_at <= NOW()
+  AND NOT EXISTS (
+      SELECT 1 FROM outbox p
+      WHERE p.partition_key = o.partition_key
+        AND p.published_at IS NULL
+        AND p.id < o.id
+  )
+ORDER BY o.id
+LIMIT $1
+`
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select pending outbox rows: %w", err)
+	}
+	defer rows.Close()
+
+	var msgs []Message
+	for rows.Next() {
+		var m Message
+		if err := rows.Scan(&m.ID, &m.PartitionKey, &m.Sequence, &m.RoutingKey, &m.Payload, &m.Attempts); err != nil {
+			return nil, fmt.Errorf("scan outbox row: %w", err)
+		}
+		msgs = append(msgs, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return msgs, nil
+}
+
+func (s *store) MarkPublished(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE outbox SET published_at = NOW(), last_error = NULL WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("mark outbox row published: %w", err)
+	}
+	return nil
+}
+
+func (s *store) MarkFailed(ctx context.Context, id int64, reason string, nextAttempt time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE outbox SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $1`,
+		id, reason, nextAttempt)
+	if err != nil {
+		return fmt.Errorf("mark outbox row failed: %w", err)
+	}
+	return nil
+}
+
+func (s *store) DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM outbox WHERE published_at IS NOT NULL AND published_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("delete published outbox rows: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
@@ -0,0 +1,54 @@
+package outbox
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestEnqueueAllocatesSequenceInTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO event_sequences")).
+		WithArgs("cart-1").
+		WillReturnRows(sqlmock.NewRows([]string{"last_sequence"}).AddRow(int64(7)))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox")).
+		WithArgs("cart-1", int64(7), "cart.checkedout", []byte("seq=7")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	err = Enqueue(context.Background(), tx, Event{
+		PartitionKey: "cart-1",
+		RoutingKey:   "cart.checkedout",
+		Encode: func(sequence int64) ([]byte, error) {
+			return []byte("seq=" + strconv.FormatInt(sequence, 10)), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestEnqueueRequiresPartitionKey(t *testing.T) {
+	if err := Enqueue(context.Background(), nil, Event{}); err == nil {
+		t.Fatalf("expected error for missing partition key")
+	}
+}
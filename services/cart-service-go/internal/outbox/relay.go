@@ -0,0 +1,160 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Publisher sends a raw message body to the events exchange.
+type Publisher interface {
+	Publish(ctx context.Context, routingKey string, body []byte) error
+}
+
+// RelayConfig controls polling, retry backoff and cleanup of the relay.
+type RelayConfig struct {
+	PollInterval    time.Duration
+	BatchSize       int
+	InitialBackoff  time.Duration
+	MaxBackoff      time.Duration
+	Retention       time.Duration
+	CleanupInterval time.Duration
+}
+
+// DefaultRelayConfig returns the settings used when nothing is configured.
+func DefaultRelayConfig() RelayConfig {
+	return RelayConfig{
+		PollInterval:    time.Second,
+		BatchSize:       100,
+		InitialBackoff:  time.Second,
+		MaxBackoff:      5 * time.Minute,
+		Retention:       72 * time.Hour,
+		CleanupInterval: time.Hour,
+	}
+}
+
+// Relay publishes pending outbox rows and marks them as sent.
+// Delivery is at-least-once: a crash between publish and MarkPublished, or more
+// than one relay running against the same table, can publish a row twice.
+// Consumers deduplicate on (partitionKey, sequence).
+type Relay struct {
+	store  Store
+	pub    Publisher
+	cfg    RelayConfig
+	logger *log.Logger
+	now    func() time.Time
+}
+
+func NewRelay(store Store, pub Publisher, cfg RelayConfig, logger *log.Logger) *Relay {
+	def := DefaultRelayConfig()
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = def.PollInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = def.BatchSize
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = def.InitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = def.MaxBackoff
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = def.Retention
+	}
+	if cfg.CleanupInterval <= 0 {
+		cfg.CleanupInterval = def.CleanupInterval
+	}
+	return &Relay{store: store, pub: pub, cfg: cfg, logger: logger, now: time.Now}
+}
+
+// Run polls the outbox until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	poll := time.NewTicker(r.cfg.PollInterval)
+	defer poll.Stop()
+	cleanup := time.NewTicker(r.cfg.CleanupInterval)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+			r.Drain(ctx)
+		case <-cleanup.C:
+			r.Cleanup(ctx)
+		}
+	}
+}
+
+// Drain publishes batches until no more messages are due.
+func (r *Relay) Drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		published, err := r.PublishPending(ctx)
+		if err != nil {
+			r.logger.Printf("outbox relay: %v", err)
+			return
+		}
+		if published == 0 {
+			return
+		}
+	}
+}
+
+// PublishPending publishes one batch of due messages and returns how many were sent.
+func (r *Relay) PublishPending(ctx context.Context) (int, error) {
+	msgs, err := r.store.FetchPending(ctx, r.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	blocked := make(map[string]bool)
+	for _, m := range msgs {
+		if blocked[m.PartitionKey] {
+			continue
+		}
+
+		if err := r.pub.Publish(ctx, m.RoutingKey, m.Payload); err != nil {
+			// Hold back the rest of the partition so ordering is preserved.
+			blocked[m.PartitionKey] = true
+			next := r.now().Add(r.backoff(m.Attempts + 1))
+			r.logger.Printf("outbox relay: publish id=%d partition=%s seq=%d attempt=%d failed: %v",
+				m.ID, m.PartitionKey, m.Sequence, m.Attempts+1, err)
+			if markErr := r.store.MarkFailed(ctx, m.ID, err.Error(), next); markErr != nil {
+				return published, markErr
+			}
+			continue
+		}
+
+		if err := r.store.MarkPublished(ctx, m.ID); err != nil {
+			return published, err
+		}
+		published++
+	}
+	return published, nil
+}
+
+// Cleanup deletes published rows older than the retention period.
+func (r *Relay) Cleanup(ctx context.Context) {
+	n, err := r.store.DeletePublishedBefore(ctx, r.now().Add(-r.cfg.Retention))
+	if err != nil {
+		r.logger.Printf("outbox relay: cleanup: %v", err)
+		return
+	}
+	if n > 0 {
+		r.logger.Printf("outbox relay: removed %d published rows", n)
+	}
+}
+
+// backoff doubles the delay per attempt, capped at MaxBackoff.
+func (r *Relay) backoff(attempt int) time.Duration {
+	d := r.cfg.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= r.cfg.MaxBackoff {
+			return r.cfg.MaxBackoff
+		}
+	}
+	return d
+}
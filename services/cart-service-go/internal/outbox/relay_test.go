@@ -0,0 +1,154 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	pending   []Message
+	published []int64
+	failed    map[int64]time.Time
+	deleted   time.Time
+}
+
+func (f *fakeStore) FetchPending(ctx context.Context, limit int) ([]Message, error) {
+	var out []Message
+	for _, m := range f.pending {
+		if f.isPublished(m.ID) {
+			continue
+		}
+		if _, ok := f.failed[m.ID]; ok {
+			continue
+		}
+		out = append(out, m)
+		if len(out) == limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeStore) isPublished(id int64) bool {
+	for _, p := range f.published {
+		if p == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeStore) MarkPublished(ctx context.Context, id int64) error {
+	f.published = append(f.published, id)
+	return nil
+}
+
+func (f *fakeStore) MarkFailed(ctx context.Context, id int64, reason string, nextAttempt time.Time) error {
+	f.failed[id] = nextAttempt
+	return nil
+}
+
+func (f *fakeStore) DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	f.deleted = before
+	return 0, nil
+}
+
+type fakePublisher struct {
+	failFor map[string]bool
+	sent    []string
+}
+
+func (f *fakePublisher) Publish(ctx context.Context, routingKey string, body []byte) error {
+	if f.failFor[string(body)] {
+		return errors.New("broker unavailable")
+	}
+	f.sent = append(f.sent, string(body))
+	return nil
+}
+
+func newTestRelay(store Store, pub Publisher) *Relay {
+	r := NewRelay(store, pub, RelayConfig{InitialBackoff: time.Second, MaxBackoff: 4 * time.Second}, log.New(io.Discard, "", 0))
+	r.now = func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+	return r
+}
+
+func TestPublishPendingPublishesAndMarks(t *testing.T) {
+	store := &fakeStore{
+		pending: []Message{
+			{ID: 1, PartitionKey: "c1", Sequence: 1, RoutingKey: "cart.checkedout", Payload: []byte("a")},
+			{ID: 2, PartitionKey: "c2", Sequence: 1, RoutingKey: "cart.checkedout", Payload: []byte("b")},
+		},
+		failed: map[int64]time.Time{},
+	}
+	pub := &fakePublisher{}
+
+	n, err := newTestRelay(store, pub).PublishPending(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 2 || len(store.published) != 2 {
+		t.Fatalf("expected 2 published rows, got n=%d marked=%v", n, store.published)
+	}
+	if pub.sent[0] != "a" || pub.sent[1] != "b" {
+		t.Fatalf("expected rows in id order, got %v", pub.sent)
+	}
+}
+
+func TestPublishPendingBlocksPartitionOnFailure(t *testing.T) {
+	store := &fakeStore{
+		pending: []Message{
+			{ID: 1, PartitionKey: "c1", Sequence: 1, Payload: []byte("c1-1"), Attempts: 2},
+			{ID: 2, PartitionKey: "c1", Sequence: 2, Payload: []byte("c1-2")},
+			{ID: 3, PartitionKey: "c2", Sequence: 1, Payload: []byte("c2-1")},
+		},
+		failed: map[int64]time.Time{},
+	}
+	pub := &fakePublisher{failFor: map[string]bool{"c1-1": true}}
+	relay := newTestRelay(store, pub)
+
+	n, err := relay.PublishPending(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 1 || len(pub.sent) != 1 || pub.sent[0] != "c2-1" {
+		t.Fatalf("expected only the other partition to be published, got %v", pub.sent)
+	}
+	next, ok := store.failed[1]
+	if !ok {
+		t.Fatalf("expected failing row to be marked failed")
+	}
+	// third attempt: 1s * 2 * 2 = 4s
+	if want := relay.now().Add(4 * time.Second); !next.Equal(want) {
+		t.Fatalf("expected next attempt %v, got %v", want, next)
+	}
+	if _, ok := store.failed[2]; ok {
+		t.Fatalf("did not expect later row of blocked partition to be attempted")
+	}
+}
+
+func TestBackoffIsCapped(t *testing.T) {
+	relay := newTestRelay(&fakeStore{}, &fakePublisher{})
+
+	cases := map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: 4 * time.Second}
+	for attempt, want := range cases {
+		if got := relay.backoff(attempt); got != want {
+			t.Fatalf("attempt %d: expected %v, got %v", attempt, want, got)
+		}
+	}
+}
+
+func TestCleanupUsesRetention(t *testing.T) {
+	store := &fakeStore{}
+	relay := newTestRelay(store, &fakePublisher{})
+	relay.cfg.Retention = time.Hour
+
+	relay.Cleanup(context.Background())
+
+	if want := relay.now().Add(-time.Hour); !store.deleted.Equal(want) {
+		t.Fatalf("expected cutoff %v, got %v", want, store.deleted)
+	}
+}
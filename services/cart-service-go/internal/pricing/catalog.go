@@ -0,0 +1,75 @@
+package pricing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+// CatalogSource looks up prices from the catalog service's products API.
+type CatalogSource struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewCatalogSource(baseURL string, client *http.Client) *CatalogSource {
+	if client == nil {
+		client = &http.Client{Timeout: 2 * time.Second}
+	}
+	return &CatalogSource{baseURL: strings.TrimRight(baseURL, "/"), client: client}
+}
+
+// Price is decoded as json.Number so it never passes through float64.
+type catalogProduct struct {
+	ID       string      `json:"id"`
+	Price    json.Number `json:"price"`
+	Currency string      `json:"currency"`
+	Active   bool        `json:"active"`
+}
+
+func (s *CatalogSource) Price(ctx context.Context, productID string) (money.Money, error) {
+	endpoint := s.baseURL + "/api/catalog/products/" + url.PathEscape(productID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return money.Money{}, fmt.Errorf("build catalog request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return money.Money{}, fmt.Errorf("catalog request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// The catalog parses ids as UUIDs, so a malformed id comes back as 400.
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusBadRequest {
+		return money.Money{}, ErrProductNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return money.Money{}, fmt.Errorf("catalog returned status %d", resp.StatusCode)
+	}
+
+	var p catalogProduct
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return money.Money{}, fmt.Errorf("decode catalog product: %w", err)
+	}
+	if !p.Active {
+		return money.Money{}, ErrProductInactive
+	}
+
+	currency := p.Currency
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+	price, err := money.ParseDecimal(p.Price.String(), currency)
+	if err != nil {
+		return money.Money{}, fmt.Errorf("catalog product %s: %w", productID, err)
+	}
+	return price, nil
+}
@@ -0,0 +1,63 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+func TestCatalogSourcePrice(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/catalog/products/p1":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"p1","price":12.35,"currency":"EUR","active":true}`))
+		case "/api/catalog/products/no-currency":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"no-currency","price":0.3,"active":true}`))
+		case "/api/catalog/products/inactive":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"inactive","price":3,"active":false}`))
+		case "/api/catalog/products/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	src := NewCatalogSource(srv.URL+"/", srv.Client())
+
+	price, err := src.Price(context.Background(), "p1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if price != money.New(1235, "EUR") {
+		t.Fatalf("expected price 12.35 EUR, got %v", price)
+	}
+
+	price, err = src.Price(context.Background(), "no-currency")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if price != money.New(30, money.DefaultCurrency) {
+		t.Fatalf("expected default currency, got %v", price)
+	}
+
+	if _, err := src.Price(context.Background(), "missing"); !errors.Is(err, ErrProductNotFound) {
+		t.Fatalf("expected ErrProductNotFound for missing product, got %v", err)
+	}
+	if _, err := src.Price(context.Background(), "inactive"); !errors.Is(err, ErrProductNotFound) || !errors.Is(err, ErrProductInactive) {
+		t.Fatalf("expected ErrProductInactive for inactive product, got %v", err)
+	}
+	if _, err := src.Price(context.Background(), "missing"); errors.Is(err, ErrProductInactive) {
+		t.Fatalf("did not expect a missing product to be inactive")
+	}
+	if _, err := src.Price(context.Background(), "broken"); err == nil || errors.Is(err, ErrProductNotFound) {
+		t.Fatalf("expected upstream error, got %v", err)
+	}
+}
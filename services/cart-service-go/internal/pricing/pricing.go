@@ -0,0 +1,64 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+// ErrProductNotFound is returned when the price source does not know the product.
+var ErrProductNotFound = errors.New("product not found")
+
+// ErrProductInactive is returned for a product the catalog still has but no
+// longer sells. It matches ErrProductNotFound, as the product can't be priced.
+var ErrProductInactive = fmt.Errorf("product inactive: %w", ErrProductNotFound)
+
+// PriceSource resolves the current unit price of a product.
+type PriceSource interface {
+	Price(ctx context.Context, productID string) (money.Money, error)
+}
+
+// InMemorySource is a PriceSource backed by a map, used in tests and local runs.
+type InMemorySource struct {
+	mu       sync.RWMutex
+	prices   map[string]money.Money
+	inactive map[string]bool
+}
+
+func NewInMemorySource(prices map[string]money.Money) *InMemorySource {
+	s := &InMemorySource{prices: make(map[string]money.Money, len(prices)), inactive: make(map[string]bool)}
+	for id, p := range prices {
+		s.prices[id] = p
+	}
+	return s
+}
+
+// Set adds or replaces the price of a product.
+func (s *InMemorySource) Set(productID string, price money.Money) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prices[productID] = price
+}
+
+// Deactivate takes a product off sale while keeping it known.
+func (s *InMemorySource) Deactivate(productID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inactive[productID] = true
+}
+
+func (s *InMemorySource) Price(ctx context.Context, productID string) (money.Money, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.inactive[productID] {
+		return money.Money{}, ErrProductInactive
+	}
+	p, ok := s.prices[productID]
+	if !ok {
+		return money.Money{}, ErrProductNotFound
+	}
+	return p, nil
+}
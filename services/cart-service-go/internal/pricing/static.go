@@ -0,0 +1,31 @@
+package pricing
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+// ParseStaticPrices reads a price list such as "sku-1:19.99 EUR,sku-2:5 USD"
+// for an InMemorySource. An empty list has no prices.
+func ParseStaticPrices(s string) (map[string]money.Money, error) {
+	prices := map[string]money.Money{}
+	if strings.TrimSpace(s) == "" {
+		return prices, nil
+	}
+	for _, entry := range strings.Split(s, ",") {
+		productID, price, _ := strings.Cut(strings.TrimSpace(entry), ":")
+		productID = strings.TrimSpace(productID)
+		amount, currency, ok := strings.Cut(strings.TrimSpace(price), " ")
+		if productID == "" || !ok {
+			return nil, fmt.Errorf("invalid price entry %q: want product:amount currency", entry)
+		}
+		m, err := money.ParseDecimal(amount, currency)
+		if err != nil {
+			return nil, fmt.Errorf("invalid price for %s: %w", productID, err)
+		}
+		prices[productID] = m
+	}
+	return prices, nil
+}
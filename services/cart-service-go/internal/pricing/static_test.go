@@ -0,0 +1,27 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+func TestParseStaticPrices(t *testing.T) {
+	prices, err := ParseStaticPrices("sku-1:19.99 EUR, sku-2:5 usd")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(prices) != 2 || prices["sku-1"] != money.New(1999, "EUR") || prices["sku-2"] != money.New(500, "USD") {
+		t.Fatalf("unexpected prices: %v", prices)
+	}
+
+	if prices, err := ParseStaticPrices(""); err != nil || len(prices) != 0 {
+		t.Fatalf("expected no prices, got %v, %v", prices, err)
+	}
+
+	for _, in := range []string{"sku-1", "sku-1:19.99", ":1 EUR", "sku-1:abc EUR", "sku-1:1 XX"} {
+		if _, err := ParseStaticPrices(in); err == nil {
+			t.Fatalf("expected an error for %q", in)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Package problem writes error responses as RFC 7807 problem details. The
+// body and the codes are the ones every service shares; see
+// contracts/http/problems.md.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ContentType is the media type of a problem details body.
+const ContentType = "application/problem+json"
+
+// HeaderCorrelationID is the request header the correlation ID arrives in.
+const HeaderCorrelationID = "X-Correlation-Id"
+
+// TypeBase is prefixed to a code to form the problem type URI.
+const TypeBase = "https://github.com/andreasstove999/ecommerce-system/blob/main/contracts/http/problems.md#"
+
+// Codes shared by all services.
+const (
+	CodeInvalidJSON        = "invalid_json"
+	CodeValidationFailed   = "validation_failed"
+	CodeNotFound           = "not_found"
+	CodePreconditionFailed = "precondition_failed"
+	CodeVersionConflict    = "version_conflict"
+	CodeUpstreamFailed     = "upstream_failed"
+	CodeInternal           = "internal_error"
+)
+
+// Field error codes.
+const (
+	FieldRequired = "required"
+	FieldInvalid  = "invalid"
+	FieldTooLong  = "too_long"
+)
+
+// FieldError is one request field that failed validation. It is also an
+// error, so validation helpers can return it.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string { return e.Message }
+
+// Details is the body of an error response. Type, Title, Status, Detail and
+// Instance are the RFC 7807 members; the rest are extensions.
+type Details struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Code is stable; clients branch on it rather than on Detail.
+	Code          string       `json:"code"`
+	CorrelationID string       `json:"correlationId,omitempty"`
+	Errors        []FieldError `json:"errors,omitempty"`
+	// Error repeats Detail for clients of the earlier {"error": "..."} body.
+	Error string `json:"error"`
+}
+
+// New returns the details of a problem.
+func New(status int, code, detail string) Details {
+	return Details{
+		Type:   TypeBase + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+		Error:  detail,
+	}
+}
+
+// Invalid returns a validation_failed problem for the given fields.
+func Invalid(detail string, fields ...FieldError) Details {
+	d := New(http.StatusBadRequest, CodeValidationFailed, detail)
+	d.Errors = fields
+	return d
+}
+
+// Body is a *Details, or a pointer to a struct embedding Details to add
+// extension members.
+type Body interface {
+	details() *Details
+}
+
+func (d *Details) details() *Details { return d }
+
+// Write sends body as the response to r, filling in the instance and the
+// correlation ID from r.
+func Write(w http.ResponseWriter, r *http.Request, body Body) {
+	d := body.details()
+	if d.Instance == "" {
+		d.Instance = r.URL.Path
+	}
+	if d.CorrelationID == "" {
+		d.CorrelationID = r.Header.Get(HeaderCorrelationID)
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(d.Status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// Error writes a problem with no extensions.
+func Error(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	d := New(status, code, detail)
+	Write(w, r, &d)
+}
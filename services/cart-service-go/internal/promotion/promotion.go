@@ -0,0 +1,139 @@
+package promotion
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+var (
+	ErrNotFound          = errors.New("coupon not found")
+	ErrNotActive         = errors.New("coupon is not valid at this time")
+	ErrMinSpendNotMet    = errors.New("cart total is below the coupon's minimum spend")
+	ErrNotApplicable     = errors.New("coupon does not apply to this cart")
+	ErrUsageLimitReached = errors.New("coupon usage limit reached")
+)
+
+// Kind selects how a promotion's discount is worked out.
+type Kind string
+
+const (
+	// KindPercentage takes PercentOff percent off the cart subtotal.
+	KindPercentage Kind = "percentage"
+	// KindFixedAmount takes AmountOff off the subtotal, never more than the subtotal.
+	KindFixedAmount Kind = "fixed_amount"
+	// KindBuyXGetY makes GetQuantity units of ProductID free for every
+	// BuyQuantity+GetQuantity units in the cart.
+	KindBuyXGetY Kind = "buy_x_get_y"
+)
+
+// Promotion is a discount customers unlock with a coupon code.
+type Promotion struct {
+	ID          string
+	Code        string
+	Description string
+	Kind        Kind
+
+	PercentOff  int
+	AmountOff   money.Money
+	ProductID   string
+	BuyQuantity int
+	GetQuantity int
+
+	// MinSpend is the subtotal the cart must reach; a zero amount means none.
+	MinSpend money.Money
+	// StartsAt and EndsAt bound the validity window. A zero time leaves that
+	// side open; EndsAt itself is outside the window.
+	StartsAt time.Time
+	EndsAt   time.Time
+	// MaxUsesPerUser limits how many checkouts per user may use the
+	// promotion. Zero means unlimited.
+	MaxUsesPerUser int
+	Active         bool
+}
+
+// Line is the part of a cart line a promotion looks at.
+type Line struct {
+	ProductID string
+	Quantity  int
+	UnitPrice money.Money
+}
+
+// NormalizeCode makes coupon codes case-insensitive.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// ActiveAt reports whether the promotion can be used at t.
+func (p *Promotion) ActiveAt(t time.Time) bool {
+	if !p.Active {
+		return false
+	}
+	if !p.StartsAt.IsZero() && t.Before(p.StartsAt) {
+		return false
+	}
+	if !p.EndsAt.IsZero() && !t.Before(p.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// Evaluate checks that the promotion can be used at now by a user who has
+// already redeemed it used times, and returns the discount it gives on lines
+// whose sum is subtotal. The discount is positive and never exceeds subtotal.
+func (p *Promotion) Evaluate(lines []Line, subtotal money.Money, now time.Time, used int) (money.Money, error) {
+	if !p.ActiveAt(now) {
+		return money.Money{}, ErrNotActive
+	}
+	if p.MaxUsesPerUser > 0 && used >= p.MaxUsesPerUser {
+		return money.Money{}, ErrUsageLimitReached
+	}
+	if p.MinSpend.Amount > 0 {
+		if p.MinSpend.Currency != subtotal.Currency {
+			return money.Money{}, ErrNotApplicable
+		}
+		if subtotal.Amount < p.MinSpend.Amount {
+			return money.Money{}, ErrMinSpendNotMet
+		}
+	}
+
+	discount, err := p.discount(lines, subtotal)
+	if err != nil {
+		return money.Money{}, err
+	}
+	if discount.Amount > subtotal.Amount {
+		discount.Amount = subtotal.Amount
+	}
+	if discount.Amount <= 0 {
+		return money.Money{}, ErrNotApplicable
+	}
+	return discount, nil
+}
+
+func (p *Promotion) discount(lines []Line, subtotal money.Money) (money.Money, error) {
+	switch p.Kind {
+	case KindPercentage:
+		// Rounded half up to the minor unit.
+		return money.New((subtotal.Amount*int64(p.PercentOff)+50)/100, subtotal.Currency), nil
+	case KindFixedAmount:
+		if p.AmountOff.Currency != subtotal.Currency {
+			return money.Money{}, ErrNotApplicable
+		}
+		return p.AmountOff, nil
+	case KindBuyXGetY:
+		group := p.BuyQuantity + p.GetQuantity
+		if p.BuyQuantity <= 0 || p.GetQuantity <= 0 {
+			return money.Money{}, ErrNotApplicable
+		}
+		for _, l := range lines {
+			if l.ProductID == p.ProductID {
+				return l.UnitPrice.Mul(l.Quantity / group * p.GetQuantity), nil
+			}
+		}
+		return money.Money{}, ErrNotApplicable
+	default:
+		return money.Money{}, ErrNotApplicable
+	}
+}
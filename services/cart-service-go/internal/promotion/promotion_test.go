@@ -0,0 +1,101 @@
+package promotion
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+	lines := []Line{
+		{ProductID: "p1", Quantity: 5, UnitPrice: money.New(300, "USD")},
+		{ProductID: "p2", Quantity: 1, UnitPrice: money.New(999, "USD")},
+	}
+	subtotal := money.New(2499, "USD")
+
+	tests := []struct {
+		name    string
+		p       Promotion
+		used    int
+		want    money.Money
+		wantErr error
+	}{
+		{
+			name: "percentage rounds half up",
+			p:    Promotion{Kind: KindPercentage, PercentOff: 15, Active: true},
+			want: money.New(375, "USD"),
+		},
+		{
+			name: "fixed amount",
+			p:    Promotion{Kind: KindFixedAmount, AmountOff: money.New(500, "USD"), Active: true},
+			want: money.New(500, "USD"),
+		},
+		{
+			name: "fixed amount is capped at the subtotal",
+			p:    Promotion{Kind: KindFixedAmount, AmountOff: money.New(5000, "USD"), Active: true},
+			want: subtotal,
+		},
+		{
+			name:    "fixed amount in another currency",
+			p:       Promotion{Kind: KindFixedAmount, AmountOff: money.New(500, "EUR"), Active: true},
+			wantErr: ErrNotApplicable,
+		},
+		{
+			name: "buy two get one",
+			p:    Promotion{Kind: KindBuyXGetY, ProductID: "p1", BuyQuantity: 2, GetQuantity: 1, Active: true},
+			want: money.New(300, "USD"),
+		},
+		{
+			name:    "buy x get y without the product",
+			p:       Promotion{Kind: KindBuyXGetY, ProductID: "p9", BuyQuantity: 2, GetQuantity: 1, Active: true},
+			wantErr: ErrNotApplicable,
+		},
+		{
+			name:    "minimum spend",
+			p:       Promotion{Kind: KindPercentage, PercentOff: 10, MinSpend: money.New(5000, "USD"), Active: true},
+			wantErr: ErrMinSpendNotMet,
+		},
+		{
+			name:    "inactive",
+			p:       Promotion{Kind: KindPercentage, PercentOff: 10},
+			wantErr: ErrNotActive,
+		},
+		{
+			name:    "not started",
+			p:       Promotion{Kind: KindPercentage, PercentOff: 10, StartsAt: now.Add(time.Hour), Active: true},
+			wantErr: ErrNotActive,
+		},
+		{
+			name:    "ended",
+			p:       Promotion{Kind: KindPercentage, PercentOff: 10, EndsAt: now, Active: true},
+			wantErr: ErrNotActive,
+		},
+		{
+			name:    "usage limit",
+			p:       Promotion{Kind: KindPercentage, PercentOff: 10, MaxUsesPerUser: 2, Active: true},
+			used:    2,
+			wantErr: ErrUsageLimitReached,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Evaluate(lines, subtotal, now, tt.used)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package promotion
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Store reads promotions and their redemptions.
+type Store interface {
+	// GetByCode returns nil, nil when no promotion has the code.
+	GetByCode(ctx context.Context, code string) (*Promotion, error)
+	// Redemptions counts the user's checkouts that used the promotion.
+	Redemptions(ctx context.Context, promotionID, userID string) (int, error)
+}
+
+type store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) Store {
+	return &store{db: db}
+}
+
+func (s *store) GetByCode(ctx context.Context, code string) (*Promotion, error) {
+	const query = `
+SELECT id, code, description, kind, percent_off, amount_off_minor, COALESCE(product_id, ''),
+       buy_quantity, get_quantity, min_spend_minor, currency, starts_at, ends_at,
+       max_uses_per_user, active
+FROM promotions
+WHERE code = $1
+`
+	var (
+		p                  Promotion
+		currency           string
+		startsAt, endsAt   sql.NullTime
+		amountOff, minimum int64
+	)
+	err := s.db.QueryRowContext(ctx, query, NormalizeCode(code)).Scan(
+		&p.ID, &p.Code, &p.Description, &p.Kind, &p.PercentOff, &amountOff, &p.ProductID,
+		&p.BuyQuantity, &p.GetQuantity, &minimum, &currency, &startsAt, &endsAt,
+		&p.MaxUsesPerUser, &p.Active,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("select promotion: %w", err)
+	}
+
+	p.AmountOff.Amount, p.AmountOff.Currency = amountOff, currency
+	p.MinSpend.Amount, p.MinSpend.Currency = minimum, currency
+	p.StartsAt = startsAt.Time
+	p.EndsAt = endsAt.Time
+	return &p, nil
+}
+
+func (s *store) Redemptions(ctx context.Context, promotionID, userID string) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM promotion_redemptions WHERE promotion_id = $1 AND user_id = $2`,
+		promotionID, userID,
+	).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("count promotion redemptions: %w", err)
+	}
+	return n, nil
+}
+
+// Redeem records that the user's checkout of cartID used the promotion. It
+// runs in the checkout transaction and fails with ErrUsageLimitReached if the
+// user has used up the promotion, so concurrent checkouts cannot exceed the
+// limit together.
+func Redeem(ctx context.Context, tx *sql.Tx, promotionID, userID, cartID string) error {
+	// Serialises redemptions per promotion and user until the transaction ends.
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1 || ':' || $2))`, promotionID, userID); err != nil {
+		return fmt.Errorf("lock promotion redemptions: %w", err)
+	}
+
+	const limitSQL = `
+SELECT p.max_uses_per_user, COUNT(r.cart_id)
+FROM promotions p
+LEFT JOIN promotion_redemptions r ON r.promotion_id = p.id AND r.user_id = $2
+WHERE p.id = $1
+GROUP BY p.max_uses_per_user
+`
+	var limit, used int
+	if err := tx.QueryRowContext(ctx, limitSQL, promotionID, userID).Scan(&limit, &used); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("select promotion usage: %w", err)
+	}
+	if limit > 0 && used >= limit {
+		return ErrUsageLimitReached
+	}
+
+	const insertSQL = `
+INSERT INTO promotion_redemptions (promotion_id, user_id, cart_id, redeemed_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (promotion_id, cart_id) DO NOTHING
+`
+	if _, err := tx.ExecContext(ctx, insertSQL, promotionID, userID, cartID); err != nil {
+		return fmt.Errorf("insert promotion redemption: %w", err)
+	}
+	return nil
+}
+
+// InMemoryStore is a Store backed by maps, used in tests and local runs.
+type InMemoryStore struct {
+	mu          sync.Mutex
+	promotions  map[string]Promotion
+	redemptions map[string]map[string]string // promotion ID -> cart ID -> user ID
+}
+
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{promotions: make(map[string]Promotion), redemptions: make(map[string]map[string]string)}
+}
+
+// Add stores p, replacing any promotion with the same code.
+func (m *InMemoryStore) Add(p Promotion) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p.Code = NormalizeCode(p.Code)
+	m.promotions[p.Code] = p
+}
+
+func (m *InMemoryStore) GetByCode(ctx context.Context, code string) (*Promotion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.promotions[NormalizeCode(code)]
+	if !ok {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+func (m *InMemoryStore) Redemptions(ctx context.Context, promotionID, userID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.used(promotionID, userID), nil
+}
+
+// Redeem records the checkout of cartID against each of the promotions, all
+// or none. Like the Redeem that runs in the checkout transaction it fails
+// with ErrNotFound or ErrUsageLimitReached.
+func (m *InMemoryStore) Redeem(userID, cartID string, promotionIDs []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, id := range promotionIDs {
+		p := m.byID(id)
+		if p == nil {
+			return ErrNotFound
+		}
+		if p.MaxUsesPerUser > 0 && m.used(id, userID) >= p.MaxUsesPerUser {
+			return ErrUsageLimitReached
+		}
+	}
+	for _, id := range promotionIDs {
+		if m.redemptions[id] == nil {
+			m.redemptions[id] = make(map[string]string)
+		}
+		m.redemptions[id][cartID] = userID
+	}
+	return nil
+}
+
+func (m *InMemoryStore) byID(id string) *Promotion {
+	for _, p := range m.promotions {
+		if p.ID == id {
+			return &p
+		}
+	}
+	return nil
+}
+
+func (m *InMemoryStore) used(promotionID, userID string) int {
+	n := 0
+	for _, u := range m.redemptions[promotionID] {
+		if u == userID {
+			n++
+		}
+	}
+	return n
+}
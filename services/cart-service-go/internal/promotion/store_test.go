@@ -0,0 +1,69 @@
+package promotion
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRedeem(t *testing.T) {
+	redeem := func(t *testing.T, limit, used int) (sqlmock.Sqlmock, error) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("sqlmock: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("pg_advisory_xact_lock")).
+			WithArgs("promo-1", "u1").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT p.max_uses_per_user, COUNT(r.cart_id)")).
+			WithArgs("promo-1", "u1").
+			WillReturnRows(sqlmock.NewRows([]string{"max_uses_per_user", "count"}).AddRow(limit, used))
+		if limit == 0 || used < limit {
+			mock.ExpectExec(regexp.QuoteMeta("INSERT INTO promotion_redemptions")).
+				WithArgs("promo-1", "u1", "c1").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatalf("begin: %v", err)
+		}
+		return mock, Redeem(context.Background(), tx, "promo-1", "u1", "c1")
+	}
+
+	t.Run("records redemption", func(t *testing.T) {
+		mock, err := redeem(t, 2, 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		mock, err := redeem(t, 0, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("limit reached", func(t *testing.T) {
+		mock, err := redeem(t, 1, 1)
+		if !errors.Is(err, ErrUsageLimitReached) {
+			t.Fatalf("expected ErrUsageLimitReached, got %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("unmet expectations: %v", err)
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadConfig reads the rules from the JSON file named by CART_RULES_FILE, if
+// set, and then applies the CART_MAX_* and CART_PRODUCT_* variables on top of
+// it. getenv is os.Getenv outside tests.
+func LoadConfig(getenv func(string) string) (Config, error) {
+	var cfg Config
+	if path := getenv("CART_RULES_FILE"); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("read cart rules: %w", err)
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&cfg); err != nil {
+			return Config{}, fmt.Errorf("parse cart rules %s: %w", path, err)
+		}
+	}
+
+	var err error
+	if v := getenv("CART_MAX_LINE_QUANTITY"); v != "" {
+		if cfg.MaxLineQuantity, err = strconv.Atoi(v); err != nil {
+			return Config{}, fmt.Errorf("invalid CART_MAX_LINE_QUANTITY %q", v)
+		}
+	}
+	if v := getenv("CART_MAX_LINES"); v != "" {
+		if cfg.MaxLines, err = strconv.Atoi(v); err != nil {
+			return Config{}, fmt.Errorf("invalid CART_MAX_LINES %q", v)
+		}
+	}
+	if v := getenv("CART_MAX_VALUE"); v != "" {
+		cfg.MaxCartValue = map[string]int64{}
+		for currency, amount := range pairs(v) {
+			n, err := strconv.ParseInt(amount, 10, 64)
+			if err != nil {
+				return Config{}, fmt.Errorf("invalid CART_MAX_VALUE entry %s:%s", currency, amount)
+			}
+			cfg.MaxCartValue[strings.ToUpper(currency)] = n
+		}
+	}
+	if v := getenv("CART_PRODUCT_LIMITS"); v != "" {
+		cfg.ProductLimits = map[string]int{}
+		for productID, limit := range pairs(v) {
+			n, err := strconv.Atoi(limit)
+			if err != nil {
+				return Config{}, fmt.Errorf("invalid CART_PRODUCT_LIMITS entry %s:%s", productID, limit)
+			}
+			cfg.ProductLimits[productID] = n
+		}
+	}
+	if v := getenv("CART_PRODUCT_ID_PATTERNS"); v != "" {
+		cfg.ProductIDPatterns = strings.Fields(v)
+	}
+	return cfg, nil
+}
+
+// pairs splits "k1:v1,k2:v2". An entry without a colon has an empty value,
+// which fails the caller's number parsing.
+func pairs(s string) map[string]string {
+	out := map[string]string{}
+	for _, entry := range strings.Split(s, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(entry), ":")
+		out[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return out
+}
@@ -0,0 +1,229 @@
+// Package rules holds the operator's limits on what a cart may contain.
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+// Rule names reported in violations.
+const (
+	RuleQuantity        = "quantity"
+	RuleProductID       = "product_id"
+	RuleMaxLineQuantity = "max_line_quantity"
+	RuleProductLimit    = "product_limit"
+	RuleMaxLines        = "max_lines"
+	RuleMaxCartValue    = "max_cart_value"
+)
+
+// Config is the cart rules as the operator writes them. Zero values mean no
+// limit.
+type Config struct {
+	// MaxLineQuantity caps the quantity of any single line.
+	MaxLineQuantity int `json:"maxLineQuantity"`
+	// MaxLines caps the number of distinct products in a cart.
+	MaxLines int `json:"maxLines"`
+	// MaxCartValue caps the cart subtotal, in minor units per currency. Carts
+	// in a currency that is not listed have no cap.
+	MaxCartValue map[string]int64 `json:"maxCartValue"`
+	// ProductLimits caps how many of a product one cart may hold. They apply
+	// on top of MaxLineQuantity.
+	ProductLimits map[string]int `json:"productLimits"`
+	// ProductIDPatterns are regular expressions a product ID must match in
+	// full. An empty list allows any product ID.
+	ProductIDPatterns []string `json:"productIdPatterns"`
+}
+
+// Violation is one rule a cart change breaks.
+type Violation struct {
+	Rule      string `json:"rule"`
+	ProductID string `json:"productId,omitempty"`
+	Message   string `json:"message"`
+	// Limit and Actual are set for the quantity and line count rules.
+	Limit  int `json:"limit,omitempty"`
+	Actual int `json:"actual,omitempty"`
+	// MaxValue and Value are set for max_cart_value.
+	MaxValue *money.Money `json:"maxValue,omitempty"`
+	Value    *money.Money `json:"value,omitempty"`
+}
+
+// Error is returned for a cart change that breaks one or more rules.
+type Error struct {
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.Message
+	}
+	return "cart rules violated: " + strings.Join(msgs, "; ")
+}
+
+// Rules checks cart changes against a Config. The zero Rules only requires
+// quantities to be positive.
+type Rules struct {
+	cfg      Config
+	patterns []*regexp.Regexp
+}
+
+// New compiles cfg. It fails if a limit is negative or a pattern does not
+// compile.
+func New(cfg Config) (*Rules, error) {
+	if cfg.MaxLineQuantity < 0 || cfg.MaxLines < 0 {
+		return nil, fmt.Errorf("cart rules: limits must not be negative")
+	}
+	for currency, max := range cfg.MaxCartValue {
+		if max <= 0 {
+			return nil, fmt.Errorf("cart rules: max cart value for %s must be positive", currency)
+		}
+	}
+	for productID, max := range cfg.ProductLimits {
+		if max <= 0 {
+			return nil, fmt.Errorf("cart rules: purchase limit for %s must be positive", productID)
+		}
+	}
+
+	r := &Rules{cfg: cfg}
+	for _, p := range cfg.ProductIDPatterns {
+		re, err := regexp.Compile(`^(?:` + p + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("cart rules: product ID pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// LimitsCart reports whether any rule depends on what the cart already holds,
+// so a change can't be checked from the request alone.
+func (r *Rules) LimitsCart() bool {
+	return r.cfg.MaxLineQuantity > 0 || r.cfg.MaxLines > 0 || len(r.cfg.MaxCartValue) > 0 || len(r.cfg.ProductLimits) > 0
+}
+
+// CheckItem checks a request to put quantity of the product in the cart
+// before the cart is read. Check still has to pass on the resulting cart.
+func (r *Rules) CheckItem(productID string, quantity int) error {
+	var vs []Violation
+	if quantity < 1 {
+		vs = append(vs, Violation{
+			Rule:      RuleQuantity,
+			ProductID: productID,
+			Message:   "quantity must be greater than zero",
+			Limit:     1,
+			Actual:    quantity,
+		})
+	}
+	if !r.allowed(productID) {
+		vs = append(vs, Violation{
+			Rule:      RuleProductID,
+			ProductID: productID,
+			Message:   fmt.Sprintf("product ID %q is not allowed", productID),
+		})
+	}
+	vs = append(vs, r.lineViolations(productID, quantity)...)
+	return violations(vs)
+}
+
+// Check checks the change from before to after. Only what the change makes
+// worse counts, so a cart that broke a rule before the rules were tightened
+// can still be reduced. A nil before reads as an empty cart.
+func (r *Rules) Check(before, after *cart.Cart) error {
+	var previous []cart.Item
+	if before != nil {
+		previous = before.Items
+	}
+
+	var vs []Violation
+	for _, it := range after.Items {
+		was := 0
+		if idx := findLine(previous, it.ProductID); idx >= 0 {
+			was = previous[idx].Quantity
+		} else if !r.allowed(it.ProductID) {
+			vs = append(vs, Violation{
+				Rule:      RuleProductID,
+				ProductID: it.ProductID,
+				Message:   fmt.Sprintf("product ID %q is not allowed", it.ProductID),
+			})
+		}
+		if it.Quantity > was {
+			vs = append(vs, r.lineViolations(it.ProductID, it.Quantity)...)
+		}
+	}
+
+	if max := r.cfg.MaxLines; max > 0 && len(after.Items) > max && len(after.Items) > len(previous) {
+		vs = append(vs, Violation{
+			Rule:    RuleMaxLines,
+			Message: fmt.Sprintf("a cart can hold at most %d different products", max),
+			Limit:   max,
+			Actual:  len(after.Items),
+		})
+	}
+
+	if max, ok := r.cfg.MaxCartValue[after.Subtotal.Currency]; ok && after.Subtotal.Amount > max && increased(before, after) {
+		limit := money.New(max, after.Subtotal.Currency)
+		value := after.Subtotal
+		vs = append(vs, Violation{
+			Rule:     RuleMaxCartValue,
+			Message:  fmt.Sprintf("cart value must not exceed %s", limit),
+			MaxValue: &limit,
+			Value:    &value,
+		})
+	}
+	return violations(vs)
+}
+
+func (r *Rules) lineViolations(productID string, quantity int) []Violation {
+	var vs []Violation
+	if max := r.cfg.MaxLineQuantity; max > 0 && quantity > max {
+		vs = append(vs, Violation{
+			Rule:      RuleMaxLineQuantity,
+			ProductID: productID,
+			Message:   fmt.Sprintf("quantity must not exceed %d", max),
+			Limit:     max,
+			Actual:    quantity,
+		})
+	}
+	if max, ok := r.cfg.ProductLimits[productID]; ok && quantity > max {
+		vs = append(vs, Violation{
+			Rule:      RuleProductLimit,
+			ProductID: productID,
+			Message:   fmt.Sprintf("at most %d of product %s can be bought at once", max, productID),
+			Limit:     max,
+			Actual:    quantity,
+		})
+	}
+	return vs
+}
+
+func (r *Rules) allowed(productID string) bool {
+	if len(r.patterns) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(r.patterns, func(re *regexp.Regexp) bool { return re.MatchString(productID) })
+}
+
+// increased reports whether the change raised the cart's value. A change of
+// currency counts as a raise.
+func increased(before, after *cart.Cart) bool {
+	if before == nil || before.Subtotal.Currency != after.Subtotal.Currency {
+		return true
+	}
+	return after.Subtotal.Amount > before.Subtotal.Amount
+}
+
+func findLine(items []cart.Item, productID string) int {
+	return slices.IndexFunc(items, func(it cart.Item) bool { return it.ProductID == productID })
+}
+
+func violations(vs []Violation) error {
+	if len(vs) == 0 {
+		return nil
+	}
+	return &Error{Violations: vs}
+}
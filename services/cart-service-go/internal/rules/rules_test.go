@@ -0,0 +1,174 @@
+package rules
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/cart"
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+func mustNew(t *testing.T, cfg Config) *Rules {
+	t.Helper()
+	r, err := New(cfg)
+	if err != nil {
+		t.Fatalf("new rules: %v", err)
+	}
+	return r
+}
+
+func rulesOf(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var violated *Error
+	if !errors.As(err, &violated) {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+	var out []string
+	for _, v := range violated.Violations {
+		out = append(out, v.Rule)
+	}
+	return out
+}
+
+func newCart(items ...cart.Item) *cart.Cart {
+	c := &cart.Cart{UserID: "u1", Items: items}
+	_ = c.RecalculateTotal()
+	return c
+}
+
+func TestCheckItem(t *testing.T) {
+	r := mustNew(t, Config{
+		MaxLineQuantity:   10,
+		ProductLimits:     map[string]int{"sku-1": 2},
+		ProductIDPatterns: []string{`sku-\d+`},
+	})
+
+	tests := []struct {
+		name      string
+		productID string
+		quantity  int
+		want      []string
+	}{
+		{"ok", "sku-7", 3, nil},
+		{"negative quantity", "sku-7", -1, []string{RuleQuantity}},
+		{"pattern must match in full", "xsku-7", 1, []string{RuleProductID}},
+		{"over line limit", "sku-7", 11, []string{RuleMaxLineQuantity}},
+		{"over product limit", "sku-1", 3, []string{RuleProductLimit}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rulesOf(t, r.CheckItem(tt.productID, tt.quantity))
+			if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	if err := (&Rules{}).CheckItem("anything", 1000); err != nil {
+		t.Fatalf("expected the zero rules to only check the quantity, got %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	r := mustNew(t, Config{
+		MaxLineQuantity: 5,
+		MaxLines:        2,
+		MaxCartValue:    map[string]int64{"USD": 10000},
+	})
+	line := func(id string, qty int, cents int64) cart.Item {
+		return cart.Item{ProductID: id, Quantity: qty, Price: money.New(cents, "USD")}
+	}
+
+	t.Run("too many lines", func(t *testing.T) {
+		before := newCart(line("p1", 1, 100), line("p2", 1, 100))
+		after := newCart(line("p1", 1, 100), line("p2", 1, 100), line("p3", 1, 100))
+		if got := rulesOf(t, r.Check(before, after)); len(got) != 1 || got[0] != RuleMaxLines {
+			t.Fatalf("expected max_lines, got %v", got)
+		}
+	})
+
+	t.Run("cart value", func(t *testing.T) {
+		err := r.Check(nil, newCart(line("p1", 3, 4000)))
+		var violated *Error
+		if !errors.As(err, &violated) || violated.Violations[0].Rule != RuleMaxCartValue {
+			t.Fatalf("expected max_cart_value, got %v", err)
+		}
+		if v := violated.Violations[0]; *v.MaxValue != money.New(10000, "USD") || *v.Value != money.New(12000, "USD") {
+			t.Fatalf("unexpected amounts %+v", v)
+		}
+		if err := r.Check(nil, &cart.Cart{Items: []cart.Item{{ProductID: "p1", Quantity: 1, Price: money.New(1e6, "EUR")}}, Subtotal: money.New(1e6, "EUR")}); err != nil {
+			t.Fatalf("expected no cap for EUR, got %v", err)
+		}
+	})
+
+	t.Run("reducing a cart over the limits is allowed", func(t *testing.T) {
+		before := newCart(line("p1", 9, 2000), line("p2", 1, 100), line("p3", 1, 100))
+		after := newCart(line("p1", 7, 2000), line("p2", 1, 100))
+		if err := r.Check(before, after); err != nil {
+			t.Fatalf("expected no violation, got %v", err)
+		}
+	})
+
+	t.Run("raising an unchanged line is checked", func(t *testing.T) {
+		before := newCart(line("p1", 5, 100))
+		after := newCart(line("p1", 6, 100))
+		if got := rulesOf(t, r.Check(before, after)); len(got) != 1 || got[0] != RuleMaxLineQuantity {
+			t.Fatalf("expected max_line_quantity, got %v", got)
+		}
+	})
+}
+
+func TestNewRejectsBadConfig(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"negative line quantity": {MaxLineQuantity: -1},
+		"zero cart value":        {MaxCartValue: map[string]int64{"USD": 0}},
+		"zero product limit":     {ProductLimits: map[string]int{"p1": 0}},
+		"bad pattern":            {ProductIDPatterns: []string{"("}},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(`{"maxLineQuantity": 10, "maxLines": 20, "productLimits": {"p1": 1}}`), 0o600); err != nil {
+		t.Fatalf("write rules: %v", err)
+	}
+	env := map[string]string{
+		"CART_RULES_FILE":          path,
+		"CART_MAX_LINES":           "5",
+		"CART_MAX_VALUE":           "usd:50000, EUR:40000",
+		"CART_PRODUCT_ID_PATTERNS": `sku-\d+ gift-card`,
+	}
+
+	cfg, err := LoadConfig(func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MaxLineQuantity != 10 || cfg.MaxLines != 5 || cfg.ProductLimits["p1"] != 1 {
+		t.Fatalf("expected the env to override the file, got %+v", cfg)
+	}
+	if cfg.MaxCartValue["USD"] != 50000 || cfg.MaxCartValue["EUR"] != 40000 || len(cfg.ProductIDPatterns) != 2 {
+		t.Fatalf("unexpected env values %+v", cfg)
+	}
+
+	env["CART_PRODUCT_LIMITS"] = "p1"
+	if _, err := LoadConfig(func(key string) string { return env[key] }); err == nil {
+		t.Fatalf("expected an error for a limit without a value")
+	}
+
+	delete(env, "CART_PRODUCT_LIMITS")
+	if err := os.WriteFile(path, []byte(`{"maxQuantity": 10}`), 0o600); err != nil {
+		t.Fatalf("write rules: %v", err)
+	}
+	if _, err := LoadConfig(func(key string) string { return env[key] }); err == nil {
+		t.Fatalf("expected an error for an unknown field")
+	}
+}
@@ -0,0 +1,65 @@
+package tax
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+type store struct {
+	db *sql.DB
+}
+
+// NewStore returns Rules read from the tax_rates and product_tax_classes tables.
+func NewStore(db *sql.DB) Rules {
+	return &store{db: db}
+}
+
+func (s *store) Classes(ctx context.Context, productIDs []string) (map[string]string, error) {
+	out := make(map[string]string, len(productIDs))
+	if len(productIDs) == 0 {
+		return out, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT product_id, tax_class FROM product_tax_classes WHERE product_id = ANY($1)`, pq.Array(productIDs))
+	if err != nil {
+		return nil, fmt.Errorf("select product tax classes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, class string
+		if err := rows.Scan(&id, &class); err != nil {
+			return nil, fmt.Errorf("scan product tax class: %w", err)
+		}
+		out[id] = class
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("select product tax classes: %w", err)
+	}
+	return out, nil
+}
+
+func (s *store) Rate(ctx context.Context, loc Location, class string) (Rate, error) {
+	// The regional row sorts before the country-wide one.
+	const query = `
+SELECT rate_bp, prices_include_tax
+FROM tax_rates
+WHERE country = $1 AND region IN ($2, '') AND tax_class = $3
+ORDER BY region DESC
+LIMIT 1
+`
+	r := Rate{Class: class}
+	err := s.db.QueryRowContext(ctx, query, loc.Country, loc.Region, class).Scan(&r.BasisPoints, &r.Inclusive)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Rate{Class: class}, nil
+		}
+		return Rate{}, fmt.Errorf("select tax rate: %w", err)
+	}
+	return r, nil
+}
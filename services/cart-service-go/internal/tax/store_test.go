@@ -0,0 +1,44 @@
+package tax
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestStoreRate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM tax_rates")).
+		WithArgs("US", "NY", "standard").
+		WillReturnRows(sqlmock.NewRows([]string{"rate_bp", "prices_include_tax"}).AddRow(888, false))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM tax_rates")).
+		WithArgs("GB", "", "standard").
+		WillReturnRows(sqlmock.NewRows([]string{"rate_bp", "prices_include_tax"}))
+
+	s := NewStore(db)
+	r, err := s.Rate(context.Background(), Location{Country: "US", Region: "NY"}, DefaultClass)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r != (Rate{Class: DefaultClass, BasisPoints: 888}) {
+		t.Fatalf("unexpected rate %+v", r)
+	}
+
+	r, err = s.Rate(context.Background(), Location{Country: "GB"}, DefaultClass)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r != (Rate{Class: DefaultClass}) {
+		t.Fatalf("expected a zero rate without a table entry, got %+v", r)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
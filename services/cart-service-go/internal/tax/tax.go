@@ -0,0 +1,218 @@
+package tax
+
+import (
+	"context"
+	"sync"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+// DefaultClass is the tax class of products that have not been given one.
+const DefaultClass = "standard"
+
+// Location is where a cart is taxed. Region narrows the country, e.g. a US
+// state; an empty region means the country as a whole.
+type Location struct {
+	Country string
+	Region  string
+}
+
+// Rate is the tax on a product class at a location. BasisPoints is the rate
+// in hundredths of a percent (2500 = 25%). Inclusive means prices at the
+// location already contain the tax.
+type Rate struct {
+	Class       string
+	BasisPoints int
+	Inclusive   bool
+}
+
+// Rules looks up the rule tables tax is calculated from.
+type Rules interface {
+	// Classes returns the tax class of each product. Products missing from the
+	// result are in DefaultClass.
+	Classes(ctx context.Context, productIDs []string) (map[string]string, error)
+	// Rate returns the rate for class at loc. A rate for the region wins over
+	// the country's; with neither, the class is untaxed there.
+	Rate(ctx context.Context, loc Location, class string) (Rate, error)
+}
+
+// Line is a cart line to be taxed. Amount is quantity × unit price.
+type Line struct {
+	ProductID string
+	Amount    money.Money
+}
+
+// LineTax is the tax on one cart line.
+type LineTax struct {
+	ProductID       string `json:"productId"`
+	TaxClass        string `json:"taxClass"`
+	RateBasisPoints int    `json:"rateBasisPoints"`
+	Inclusive       bool   `json:"inclusive"`
+	// Taxable is the line's net amount after discounts, excluding tax.
+	Taxable money.Money `json:"taxableAmount"`
+	Amount  money.Money `json:"taxAmount"`
+}
+
+// Breakdown is the tax on a cart, line by line.
+type Breakdown struct {
+	Country string    `json:"country"`
+	Region  string    `json:"region,omitempty"`
+	Lines   []LineTax `json:"lines"`
+	// Total is all tax on the cart, whether or not it is included in prices.
+	Total money.Money `json:"totalTax"`
+	// Exclusive is the part of Total that is added on top of the prices.
+	Exclusive money.Money `json:"exclusiveTax"`
+}
+
+// Calculate works out the tax on lines at loc. discount is the positive
+// amount taken off the cart by promotions; it is spread over the lines in
+// proportion to their amounts before they are taxed. Amounts are rounded half
+// up to the minor unit per line.
+func Calculate(ctx context.Context, rules Rules, loc Location, lines []Line, discount money.Money) (*Breakdown, error) {
+	currency := discount.Currency
+	if len(lines) > 0 {
+		currency = lines[0].Amount.Currency
+	}
+
+	ids := make([]string, 0, len(lines))
+	for _, l := range lines {
+		ids = append(ids, l.ProductID)
+	}
+	classes, err := rules.Classes(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Breakdown{
+		Country:   loc.Country,
+		Region:    loc.Region,
+		Lines:     make([]LineTax, 0, len(lines)),
+		Total:     money.Zero(currency),
+		Exclusive: money.Zero(currency),
+	}
+	rates := make(map[string]Rate)
+	for i, amount := range allocateDiscount(lines, discount.Amount) {
+		class := classes[lines[i].ProductID]
+		if class == "" {
+			class = DefaultClass
+		}
+		rate, ok := rates[class]
+		if !ok {
+			if rate, err = rules.Rate(ctx, loc, class); err != nil {
+				return nil, err
+			}
+			rates[class] = rate
+		}
+
+		var taxAmount int64
+		net := amount
+		if rate.Inclusive {
+			taxAmount = divRound(amount*int64(rate.BasisPoints), 10000+int64(rate.BasisPoints))
+			net = amount - taxAmount
+		} else {
+			taxAmount = divRound(amount*int64(rate.BasisPoints), 10000)
+			b.Exclusive.Amount += taxAmount
+		}
+		b.Total.Amount += taxAmount
+
+		b.Lines = append(b.Lines, LineTax{
+			ProductID:       lines[i].ProductID,
+			TaxClass:        class,
+			RateBasisPoints: rate.BasisPoints,
+			Inclusive:       rate.Inclusive,
+			Taxable:         money.New(net, currency),
+			Amount:          money.New(taxAmount, currency),
+		})
+	}
+	return b, nil
+}
+
+// allocateDiscount returns the line amounts after taking discount off them
+// in proportion. Rounding leftovers go to the last line, and no line drops
+// below zero.
+func allocateDiscount(lines []Line, discount int64) []int64 {
+	amounts := make([]int64, len(lines))
+	var subtotal int64
+	for i, l := range lines {
+		amounts[i] = l.Amount.Amount
+		subtotal += l.Amount.Amount
+	}
+	if discount <= 0 || subtotal <= 0 {
+		return amounts
+	}
+	if discount > subtotal {
+		discount = subtotal
+	}
+
+	remaining := discount
+	for i := range amounts {
+		share := remaining
+		if i < len(amounts)-1 {
+			share = discount * lines[i].Amount.Amount / subtotal
+		}
+		if share > amounts[i] {
+			share = amounts[i]
+		}
+		amounts[i] -= share
+		remaining -= share
+	}
+	return amounts
+}
+
+// divRound divides non-negative a by positive b, rounding half up.
+func divRound(a, b int64) int64 {
+	return (2*a + b) / (2 * b)
+}
+
+type rateKey struct {
+	country, region, class string
+}
+
+// InMemoryRules is a Rules table backed by maps, used in tests and local runs.
+type InMemoryRules struct {
+	mu      sync.RWMutex
+	rates   map[rateKey]Rate
+	classes map[string]string
+}
+
+func NewInMemoryRules() *InMemoryRules {
+	return &InMemoryRules{rates: make(map[rateKey]Rate), classes: make(map[string]string)}
+}
+
+// SetRate adds or replaces the rate for r.Class at loc.
+func (m *InMemoryRules) SetRate(loc Location, r Rate) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rates[rateKey{loc.Country, loc.Region, r.Class}] = r
+}
+
+// SetClass puts a product in a tax class.
+func (m *InMemoryRules) SetClass(productID, class string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.classes[productID] = class
+}
+
+func (m *InMemoryRules) Classes(ctx context.Context, productIDs []string) (map[string]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make(map[string]string, len(productIDs))
+	for _, id := range productIDs {
+		if class, ok := m.classes[id]; ok {
+			out[id] = class
+		}
+	}
+	return out, nil
+}
+
+func (m *InMemoryRules) Rate(ctx context.Context, loc Location, class string) (Rate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if r, ok := m.rates[rateKey{loc.Country, loc.Region, class}]; ok {
+		return r, nil
+	}
+	if r, ok := m.rates[rateKey{loc.Country, "", class}]; ok {
+		return r, nil
+	}
+	return Rate{Class: class}, nil
+}
@@ -0,0 +1,125 @@
+package tax
+
+import (
+	"context"
+	"testing"
+
+	"github.com/andreasstove999/ecommerce-system/cart-service-go/internal/money"
+)
+
+func TestCalculate(t *testing.T) {
+	rules := NewInMemoryRules()
+	rules.SetRate(Location{Country: "US"}, Rate{Class: DefaultClass, BasisPoints: 500})
+	rules.SetRate(Location{Country: "US", Region: "NY"}, Rate{Class: DefaultClass, BasisPoints: 888})
+	rules.SetRate(Location{Country: "DE"}, Rate{Class: DefaultClass, BasisPoints: 1900, Inclusive: true})
+	rules.SetRate(Location{Country: "DE"}, Rate{Class: "reduced", BasisPoints: 700, Inclusive: true})
+	rules.SetClass("book", "reduced")
+
+	usd := func(amount int64) money.Money { return money.New(amount, "USD") }
+	eur := func(amount int64) money.Money { return money.New(amount, "EUR") }
+
+	tests := []struct {
+		name          string
+		loc           Location
+		lines         []Line
+		discount      money.Money
+		wantLines     []money.Money
+		wantTotal     money.Money
+		wantExclusive money.Money
+	}{
+		{
+			name:          "exclusive country rate",
+			loc:           Location{Country: "US", Region: "CA"},
+			lines:         []Line{{ProductID: "p1", Amount: usd(1999)}},
+			wantLines:     []money.Money{usd(100)},
+			wantTotal:     usd(100),
+			wantExclusive: usd(100),
+		},
+		{
+			name:          "regional rate wins",
+			loc:           Location{Country: "US", Region: "NY"},
+			lines:         []Line{{ProductID: "p1", Amount: usd(1000)}},
+			wantLines:     []money.Money{usd(89)},
+			wantTotal:     usd(89),
+			wantExclusive: usd(89),
+		},
+		{
+			name:          "inclusive rates per class",
+			loc:           Location{Country: "DE"},
+			lines:         []Line{{ProductID: "p1", Amount: eur(1190)}, {ProductID: "book", Amount: eur(1070)}},
+			wantLines:     []money.Money{eur(190), eur(70)},
+			wantTotal:     eur(260),
+			wantExclusive: eur(0),
+		},
+		{
+			name:          "discount is spread over the lines",
+			loc:           Location{Country: "US"},
+			lines:         []Line{{ProductID: "p1", Amount: usd(1000)}, {ProductID: "p2", Amount: usd(3000)}},
+			discount:      usd(400),
+			wantLines:     []money.Money{usd(45), usd(135)},
+			wantTotal:     usd(180),
+			wantExclusive: usd(180),
+		},
+		{
+			name:          "no rate for the country",
+			loc:           Location{Country: "GB"},
+			lines:         []Line{{ProductID: "p1", Amount: usd(1000)}},
+			wantLines:     []money.Money{usd(0)},
+			wantTotal:     usd(0),
+			wantExclusive: usd(0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := Calculate(context.Background(), rules, tt.loc, tt.lines, tt.discount)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(b.Lines) != len(tt.wantLines) {
+				t.Fatalf("expected %d lines, got %+v", len(tt.wantLines), b.Lines)
+			}
+			for i, want := range tt.wantLines {
+				if b.Lines[i].Amount != want {
+					t.Fatalf("line %d: expected tax %v, got %v", i, want, b.Lines[i].Amount)
+				}
+			}
+			if b.Total != tt.wantTotal || b.Exclusive != tt.wantExclusive {
+				t.Fatalf("expected total %v and exclusive %v, got %v and %v", tt.wantTotal, tt.wantExclusive, b.Total, b.Exclusive)
+			}
+		})
+	}
+}
+
+func TestCalculateInclusiveTaxableExcludesTax(t *testing.T) {
+	rules := NewInMemoryRules()
+	rules.SetRate(Location{Country: "DE"}, Rate{Class: DefaultClass, BasisPoints: 1900, Inclusive: true})
+
+	b, err := Calculate(context.Background(), rules, Location{Country: "DE"}, []Line{{ProductID: "p1", Amount: money.New(1190, "EUR")}}, money.Money{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := b.Lines[0]; got.Taxable != money.New(1000, "EUR") || !got.Inclusive || got.RateBasisPoints != 1900 || got.TaxClass != DefaultClass {
+		t.Fatalf("unexpected line %+v", got)
+	}
+}
+
+func TestAllocateDiscount(t *testing.T) {
+	lines := []Line{
+		{Amount: money.New(333, "USD")},
+		{Amount: money.New(333, "USD")},
+		{Amount: money.New(334, "USD")},
+	}
+
+	got := allocateDiscount(lines, 100)
+	if got[0]+got[1]+got[2] != 900 {
+		t.Fatalf("expected the whole discount to be allocated, got %v", got)
+	}
+
+	got = allocateDiscount(lines, 5000)
+	for i, amount := range got {
+		if amount != 0 {
+			t.Fatalf("line %d: expected a discount above the subtotal to zero every line, got %v", i, got)
+		}
+	}
+}
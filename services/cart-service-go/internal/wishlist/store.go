@@ -0,0 +1,307 @@
+package wishlist
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+type store struct {
+	db *sql.DB
+}
+
+// NewStore returns a Repository kept in the wishlists and wishlist_items tables.
+func NewStore(db *sql.DB) Repository {
+	return &store{db: db}
+}
+
+const selectList = `SELECT id, user_id, name, COALESCE(share_token, ''), created_at, updated_at FROM wishlists`
+
+func (s *store) List(ctx context.Context, userID string) ([]Wishlist, error) {
+	rows, err := s.db.QueryContext(ctx, selectList+` WHERE user_id = $1 ORDER BY created_at, id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("select wishlists: %w", err)
+	}
+	defer rows.Close()
+
+	out := []Wishlist{}
+	for rows.Next() {
+		var w Wishlist
+		if err := rows.Scan(&w.ID, &w.UserID, &w.Name, &w.ShareToken, &w.CreatedAt, &w.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan wishlist: %w", err)
+		}
+		out = append(out, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("select wishlists: %w", err)
+	}
+	rows.Close()
+
+	for i := range out {
+		if out[i].Items, err = loadItems(ctx, s.db, out[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (s *store) Get(ctx context.Context, userID, listID string) (*Wishlist, error) {
+	return loadList(ctx, s.db, selectList+` WHERE id = $1 AND user_id = $2`, listID, userID)
+}
+
+func (s *store) GetShared(ctx context.Context, token string) (*Wishlist, error) {
+	if token == "" {
+		return nil, nil
+	}
+	return loadList(ctx, s.db, selectList+` WHERE share_token = $1`, token)
+}
+
+func loadList(ctx context.Context, q queryer, query string, args ...any) (*Wishlist, error) {
+	var w Wishlist
+	err := q.QueryRowContext(ctx, query, args...).Scan(&w.ID, &w.UserID, &w.Name, &w.ShareToken, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("select wishlist: %w", err)
+	}
+	if w.Items, err = loadItems(ctx, q, w.ID); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func loadItems(ctx context.Context, q queryer, listID string) ([]Item, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT product_id, quantity, added_at FROM wishlist_items WHERE wishlist_id = $1 ORDER BY added_at, product_id`, listID)
+	if err != nil {
+		return nil, fmt.Errorf("select wishlist items: %w", err)
+	}
+	defer rows.Close()
+
+	items := []Item{}
+	for rows.Next() {
+		var it Item
+		if err := rows.Scan(&it.ProductID, &it.Quantity, &it.AddedAt); err != nil {
+			return nil, fmt.Errorf("scan wishlist item: %w", err)
+		}
+		items = append(items, it)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("select wishlist items: %w", err)
+	}
+	return items, nil
+}
+
+func (s *store) Create(ctx context.Context, userID, name string) (*Wishlist, error) {
+	const insertSQL = `
+INSERT INTO wishlists (id, user_id, name, created_at, updated_at)
+VALUES ($1, $2, $3, NOW(), NOW())
+ON CONFLICT (user_id, name) DO NOTHING
+RETURNING created_at, updated_at
+`
+	w := Wishlist{ID: uuid.NewString(), UserID: userID, Name: name, Items: []Item{}}
+	err := s.db.QueryRowContext(ctx, insertSQL, w.ID, userID, name).Scan(&w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNameTaken
+		}
+		return nil, fmt.Errorf("insert wishlist: %w", err)
+	}
+	return &w, nil
+}
+
+func (s *store) Rename(ctx context.Context, userID, listID, name string) (*Wishlist, error) {
+	return s.update(ctx, userID, listID, func(tx *sql.Tx) error {
+		var taken bool
+		const takenSQL = `SELECT EXISTS (SELECT 1 FROM wishlists WHERE user_id = $1 AND name = $2 AND id <> $3)`
+		if err := tx.QueryRowContext(ctx, takenSQL, userID, name, listID).Scan(&taken); err != nil {
+			return fmt.Errorf("check wishlist name: %w", err)
+		}
+		if taken {
+			return ErrNameTaken
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE wishlists SET name = $2 WHERE id = $1`, listID, name); err != nil {
+			return fmt.Errorf("rename wishlist: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *store) Delete(ctx context.Context, userID, listID string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM wishlists WHERE id = $1 AND user_id = $2`, listID, userID)
+	if err != nil {
+		return fmt.Errorf("delete wishlist: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *store) AddItem(ctx context.Context, userID, listID string, item Item) (w *Wishlist, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if listID, err = addItem(ctx, tx, userID, listID, item); err != nil {
+		return nil, err
+	}
+	if w, err = loadList(ctx, tx, selectList+` WHERE id = $1`, listID); err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	return w, err
+}
+
+// AddItemTx is AddItem run in the caller's transaction, for moving a cart line
+// onto a wishlist together with the cart change.
+func AddItemTx(ctx context.Context, tx *sql.Tx, userID, listID string, item Item) error {
+	_, err := addItem(ctx, tx, userID, listID, item)
+	return err
+}
+
+// addItem adds the line and returns the ID of the list it went to.
+func addItem(ctx context.Context, tx *sql.Tx, userID, listID string, item Item) (string, error) {
+	if listID == "" {
+		// Touching the row on conflict makes RETURNING yield the existing list.
+		const savedSQL = `
+INSERT INTO wishlists (id, user_id, name, created_at, updated_at)
+VALUES ($1, $2, $3, NOW(), NOW())
+ON CONFLICT (user_id, name) DO UPDATE SET updated_at = NOW()
+RETURNING id
+`
+		if err := tx.QueryRowContext(ctx, savedSQL, uuid.NewString(), userID, SavedForLater).Scan(&listID); err != nil {
+			return "", fmt.Errorf("upsert saved for later list: %w", err)
+		}
+	} else if err := touch(ctx, tx, userID, listID); err != nil {
+		return "", err
+	}
+
+	addedAt := item.AddedAt
+	if addedAt.IsZero() {
+		addedAt = time.Now().UTC()
+	}
+	const upsertSQL = `
+INSERT INTO wishlist_items (wishlist_id, product_id, quantity, added_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (wishlist_id, product_id) DO UPDATE
+SET quantity = wishlist_items.quantity + EXCLUDED.quantity
+`
+	if _, err := tx.ExecContext(ctx, upsertSQL, listID, item.ProductID, item.Quantity, addedAt); err != nil {
+		return "", fmt.Errorf("upsert wishlist item: %w", err)
+	}
+	return listID, nil
+}
+
+func (s *store) RemoveItem(ctx context.Context, userID, listID, productID string) (*Wishlist, error) {
+	return s.update(ctx, userID, listID, func(tx *sql.Tx) error {
+		return removeItem(ctx, tx, listID, productID)
+	})
+}
+
+// RemoveItemTx takes the product off the user's wishlist in the caller's
+// transaction, for moving it into the cart together with the cart change.
+func RemoveItemTx(ctx context.Context, tx *sql.Tx, userID, listID, productID string) error {
+	if err := touch(ctx, tx, userID, listID); err != nil {
+		return err
+	}
+	return removeItem(ctx, tx, listID, productID)
+}
+
+func removeItem(ctx context.Context, tx *sql.Tx, listID, productID string) error {
+	res, err := tx.ExecContext(ctx, `DELETE FROM wishlist_items WHERE wishlist_id = $1 AND product_id = $2`, listID, productID)
+	if err != nil {
+		return fmt.Errorf("delete wishlist item: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrItemNotFound
+	}
+	return nil
+}
+
+func (s *store) Share(ctx context.Context, userID, listID string) (*Wishlist, error) {
+	return s.update(ctx, userID, listID, func(tx *sql.Tx) error {
+		const shareSQL = `UPDATE wishlists SET share_token = COALESCE(share_token, $2) WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, shareSQL, listID, NewShareToken()); err != nil {
+			return fmt.Errorf("share wishlist: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *store) Unshare(ctx context.Context, userID, listID string) (*Wishlist, error) {
+	return s.update(ctx, userID, listID, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `UPDATE wishlists SET share_token = NULL WHERE id = $1`, listID); err != nil {
+			return fmt.Errorf("unshare wishlist: %w", err)
+		}
+		return nil
+	})
+}
+
+// update runs fn on the user's list in a transaction and returns the list as
+// it is afterwards.
+func (s *store) update(ctx context.Context, userID, listID string, fn func(tx *sql.Tx) error) (w *Wishlist, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = touch(ctx, tx, userID, listID); err != nil {
+		return nil, err
+	}
+	if err = fn(tx); err != nil {
+		return nil, err
+	}
+	if w, err = loadList(ctx, tx, selectList+` WHERE id = $1`, listID); err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	return w, err
+}
+
+// touch bumps the list's updated_at, which also locks it for the rest of the
+// transaction. It fails with ErrNotFound if the user has no such list.
+func touch(ctx context.Context, tx *sql.Tx, userID, listID string) error {
+	res, err := tx.ExecContext(ctx, `UPDATE wishlists SET updated_at = NOW() WHERE id = $1 AND user_id = $2`, listID, userID)
+	if err != nil {
+		return fmt.Errorf("lock wishlist: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
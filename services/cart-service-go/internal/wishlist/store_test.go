@@ -0,0 +1,109 @@
+package wishlist
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCreateNameTaken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("ON CONFLICT (user_id, name) DO NOTHING")).
+		WithArgs(sqlmock.AnyArg(), "u1", "Birthday").
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at"}))
+
+	if _, err := NewStore(db).Create(context.Background(), "u1", "Birthday"); !errors.Is(err, ErrNameTaken) {
+		t.Fatalf("expected ErrNameTaken, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAddItemTx(t *testing.T) {
+	t.Run("saved for later list is created on demand", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("ON CONFLICT (user_id, name) DO UPDATE SET updated_at = NOW()")).
+			WithArgs(sqlmock.AnyArg(), "u1", SavedForLater).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("l1"))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO wishlist_items")).
+			WithArgs("l1", "p1", 2, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatalf("begin: %v", err)
+		}
+		if err := AddItemTx(context.Background(), tx, "u1", "", Item{ProductID: "p1", Quantity: 2}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("someone else's list", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE wishlists SET updated_at = NOW() WHERE id = $1 AND user_id = $2")).
+			WithArgs("l1", "u1").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatalf("begin: %v", err)
+		}
+		if err := AddItemTx(context.Background(), tx, "u1", "l1", Item{ProductID: "p1", Quantity: 1}); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestRemoveItemTxMissingItem(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE wishlists SET updated_at = NOW()")).
+		WithArgs("l1", "u1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM wishlist_items")).
+		WithArgs("l1", "p1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if err := RemoveItemTx(context.Background(), tx, "u1", "l1", "p1"); !errors.Is(err, ErrItemNotFound) {
+		t.Fatalf("expected ErrItemNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,277 @@
+package wishlist
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// SavedForLater is the name of the list items go to when they are moved out
+// of the cart without naming a wishlist. It is created on first use.
+const SavedForLater = "Saved for later"
+
+// MaxNameLength is the longest wishlist name accepted, in characters.
+const MaxNameLength = 100
+
+var (
+	// ErrNotFound is returned when the user has no wishlist with the ID.
+	ErrNotFound = errors.New("wishlist not found")
+	// ErrItemNotFound is returned when the wishlist does not hold the product.
+	ErrItemNotFound = errors.New("wishlist item not found")
+	// ErrNameTaken is returned when the user already has a wishlist with the name.
+	ErrNameTaken = errors.New("wishlist name already in use")
+	// ErrInvalidName is returned for empty or overlong names.
+	ErrInvalidName = errors.New("wishlist name must be 1 to 100 characters")
+)
+
+// Item is a product parked on a wishlist. Unlike a cart line it holds no
+// price; the product is priced again when it goes back into the cart.
+type Item struct {
+	ProductID string    `json:"productId"`
+	Quantity  int       `json:"quantity"`
+	AddedAt   time.Time `json:"addedAt"`
+}
+
+// Wishlist is a named list of products a user keeps outside the cart. A list
+// with a ShareToken can be read by anyone who has the token.
+type Wishlist struct {
+	ID         string    `json:"wishlistId"`
+	UserID     string    `json:"userId"`
+	Name       string    `json:"name"`
+	ShareToken string    `json:"shareToken,omitempty"`
+	Items      []Item    `json:"items"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// Repository stores wishlists. All lookups except GetShared are scoped to the
+// owning user, so a list ID alone gives no access to someone else's list.
+type Repository interface {
+	// List returns the user's wishlists with their items, oldest first.
+	List(ctx context.Context, userID string) ([]Wishlist, error)
+	// Get returns nil, nil when the user has no wishlist with the ID.
+	Get(ctx context.Context, userID, listID string) (*Wishlist, error)
+	// GetShared returns the wishlist shared under token, or nil, nil.
+	GetShared(ctx context.Context, token string) (*Wishlist, error)
+	// Create fails with ErrNameTaken if the user already has a list with the name.
+	Create(ctx context.Context, userID, name string) (*Wishlist, error)
+	// Rename fails with ErrNameTaken if another of the user's lists has the name.
+	Rename(ctx context.Context, userID, listID, name string) (*Wishlist, error)
+	// Delete removes the list and its items.
+	Delete(ctx context.Context, userID, listID string) error
+	// AddItem adds item.Quantity to the product's line, creating the line when
+	// missing. An empty listID means the SavedForLater list, which is created
+	// if the user has none.
+	AddItem(ctx context.Context, userID, listID string, item Item) (*Wishlist, error)
+	RemoveItem(ctx context.Context, userID, listID, productID string) (*Wishlist, error)
+	// Share gives the list a share token, keeping the one it has if any.
+	Share(ctx context.Context, userID, listID string) (*Wishlist, error)
+	// Unshare drops the list's share token; links handed out stop working.
+	Unshare(ctx context.Context, userID, listID string) (*Wishlist, error)
+}
+
+// NormalizeName trims name and checks its length.
+func NormalizeName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > MaxNameLength {
+		return "", ErrInvalidName
+	}
+	return name, nil
+}
+
+// NewShareToken returns a random token that is hard to guess.
+func NewShareToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b) // never fails, see crypto/rand.Read
+	return hex.EncodeToString(b)
+}
+
+// FindItem returns the index of the product's line, or -1.
+func (w *Wishlist) FindItem(productID string) int {
+	return slices.IndexFunc(w.Items, func(it Item) bool { return it.ProductID == productID })
+}
+
+// InMemoryRepository is a Repository backed by a map, used in tests and local runs.
+type InMemoryRepository struct {
+	mu    sync.Mutex
+	lists map[string]*Wishlist
+	order []string
+}
+
+func NewInMemoryRepository() *InMemoryRepository {
+	return &InMemoryRepository{lists: make(map[string]*Wishlist)}
+}
+
+func (m *InMemoryRepository) List(ctx context.Context, userID string) ([]Wishlist, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []Wishlist{}
+	for _, id := range m.order {
+		if w := m.lists[id]; w.UserID == userID {
+			out = append(out, clone(w))
+		}
+	}
+	return out, nil
+}
+
+func (m *InMemoryRepository) Get(ctx context.Context, userID, listID string) (*Wishlist, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := m.lists[listID]
+	if w == nil || w.UserID != userID {
+		return nil, nil
+	}
+	c := clone(w)
+	return &c, nil
+}
+
+func (m *InMemoryRepository) GetShared(ctx context.Context, token string) (*Wishlist, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, w := range m.lists {
+		if token != "" && w.ShareToken == token {
+			c := clone(w)
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *InMemoryRepository) Create(ctx context.Context, userID, name string) (*Wishlist, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, err := m.create(userID, name)
+	if err != nil {
+		return nil, err
+	}
+	c := clone(w)
+	return &c, nil
+}
+
+func (m *InMemoryRepository) create(userID, name string) (*Wishlist, error) {
+	if m.byName(userID, name) != nil {
+		return nil, ErrNameTaken
+	}
+	now := time.Now().UTC()
+	w := &Wishlist{ID: uuid.NewString(), UserID: userID, Name: name, Items: []Item{}, CreatedAt: now, UpdatedAt: now}
+	m.lists[w.ID] = w
+	m.order = append(m.order, w.ID)
+	return w, nil
+}
+
+func (m *InMemoryRepository) byName(userID, name string) *Wishlist {
+	for _, w := range m.lists {
+		if w.UserID == userID && w.Name == name {
+			return w
+		}
+	}
+	return nil
+}
+
+func (m *InMemoryRepository) Rename(ctx context.Context, userID, listID, name string) (*Wishlist, error) {
+	return m.update(userID, listID, func(w *Wishlist) error {
+		if other := m.byName(userID, name); other != nil && other.ID != w.ID {
+			return ErrNameTaken
+		}
+		w.Name = name
+		return nil
+	})
+}
+
+func (m *InMemoryRepository) Delete(ctx context.Context, userID, listID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := m.lists[listID]
+	if w == nil || w.UserID != userID {
+		return ErrNotFound
+	}
+	delete(m.lists, listID)
+	m.order = slices.DeleteFunc(m.order, func(id string) bool { return id == listID })
+	return nil
+}
+
+func (m *InMemoryRepository) AddItem(ctx context.Context, userID, listID string, item Item) (*Wishlist, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if listID == "" {
+		w := m.byName(userID, SavedForLater)
+		if w == nil {
+			w, _ = m.create(userID, SavedForLater)
+		}
+		listID = w.ID
+	}
+	return m.updateLocked(userID, listID, func(w *Wishlist) error {
+		if idx := w.FindItem(item.ProductID); idx >= 0 {
+			w.Items[idx].Quantity += item.Quantity
+			return nil
+		}
+		if item.AddedAt.IsZero() {
+			item.AddedAt = time.Now().UTC()
+		}
+		w.Items = append(w.Items, item)
+		return nil
+	})
+}
+
+func (m *InMemoryRepository) RemoveItem(ctx context.Context, userID, listID, productID string) (*Wishlist, error) {
+	return m.update(userID, listID, func(w *Wishlist) error {
+		idx := w.FindItem(productID)
+		if idx < 0 {
+			return ErrItemNotFound
+		}
+		w.Items = slices.Delete(w.Items, idx, idx+1)
+		return nil
+	})
+}
+
+func (m *InMemoryRepository) Share(ctx context.Context, userID, listID string) (*Wishlist, error) {
+	return m.update(userID, listID, func(w *Wishlist) error {
+		if w.ShareToken == "" {
+			w.ShareToken = NewShareToken()
+		}
+		return nil
+	})
+}
+
+func (m *InMemoryRepository) Unshare(ctx context.Context, userID, listID string) (*Wishlist, error) {
+	return m.update(userID, listID, func(w *Wishlist) error {
+		w.ShareToken = ""
+		return nil
+	})
+}
+
+func (m *InMemoryRepository) update(userID, listID string, fn func(w *Wishlist) error) (*Wishlist, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.updateLocked(userID, listID, fn)
+}
+
+func (m *InMemoryRepository) updateLocked(userID, listID string, fn func(w *Wishlist) error) (*Wishlist, error) {
+	w := m.lists[listID]
+	if w == nil || w.UserID != userID {
+		return nil, ErrNotFound
+	}
+	if err := fn(w); err != nil {
+		return nil, err
+	}
+	w.UpdatedAt = time.Now().UTC()
+	c := clone(w)
+	return &c, nil
+}
+
+func clone(w *Wishlist) Wishlist {
+	c := *w
+	c.Items = slices.Clone(w.Items)
+	if c.Items == nil {
+		c.Items = []Item{}
+	}
+	return c
+}
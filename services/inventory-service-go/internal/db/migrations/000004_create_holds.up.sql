@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS holds (
+  holder_id  TEXT NOT NULL,
+  product_id TEXT NOT NULL,
+  quantity   INTEGER NOT NULL CHECK (quantity > 0),
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (holder_id, product_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_holds_product_expires ON holds (product_id, expires_at);
+CREATE INDEX IF NOT EXISTS idx_holds_expires ON holds (expires_at);
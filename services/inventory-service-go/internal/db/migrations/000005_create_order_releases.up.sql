@@ -0,0 +1,6 @@
+-- Orders whose reserved stock has been put back, so a redelivered
+-- OrderCancelled does not release it twice.
+CREATE TABLE IF NOT EXISTS order_releases (
+  order_id    TEXT PRIMARY KEY,
+  released_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
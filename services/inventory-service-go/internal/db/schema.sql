@@ -5,3 +5,14 @@ CREATE TABLE IF NOT EXISTS inventory_stock (
   available  INTEGER NOT NULL CHECK (available >= 0),
   updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
 );
+
+-- Soft holds taken by carts; rows past expires_at no longer count and are swept.
+CREATE TABLE IF NOT EXISTS holds (
+  holder_id  TEXT NOT NULL,
+  product_id TEXT NOT NULL,
+  quantity   INTEGER NOT NULL CHECK (quantity > 0),
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (holder_id, product_id)
+);
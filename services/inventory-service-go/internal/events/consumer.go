@@ -234,8 +234,15 @@ func StartOrderCreatedConsumer(ctx context.Context, conn *amqp.Connection, pool
 	dedupRepo := dedup.NewRepository(pool)
 	consumeEnveloped := consumeEnvelopedEnabled()
 
+	// order-service publishes OrderCreated v1 and v2 side by side when
+	// enveloped; bind only one so every order is reserved once.
+	queue := QueueOrderCreated
+	if consumeEnveloped {
+		queue = QueueOrderCreatedV2
+	}
+
 	consumer := NewConsumer(conn, logger)
-	consumer.Register(QueueOrderCreated, OrderCreatedHandler(repo, dedupRepo, pub, logger, orderCreatedConsumerName, consumeEnveloped))
+	consumer.Register(queue, OrderCreatedHandler(repo, dedupRepo, pub, logger, orderCreatedConsumerName, consumeEnveloped))
 
 	if err := consumer.Start(ctx); err != nil {
 		_ = pub.Close()
@@ -248,3 +255,15 @@ func StartOrderCreatedConsumer(ctx context.Context, conn *amqp.Connection, pool
 
 	return consumer, cleanup, nil
 }
+
+// StartOrderCancelledConsumer starts a consumer that listens for
+// OrderCancelled events and releases the stock reserved for the order.
+func StartOrderCancelledConsumer(ctx context.Context, conn *amqp.Connection, repo inventory.TransactionalRepository, logger *log.Logger) (*Consumer, error) {
+	consumer := NewConsumer(conn, logger)
+	consumer.Register(QueueOrderCancelled, OrderCancelledHandler(repo, logger, consumeEnvelopedEnabled()))
+
+	if err := consumer.Start(ctx); err != nil {
+		return nil, fmt.Errorf("start consumer: %w", err)
+	}
+	return consumer, nil
+}
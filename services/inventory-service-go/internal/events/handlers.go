@@ -86,6 +86,14 @@ func OrderCreatedHandler(repo inventory.TransactionalRepository, dedupRepo *dedu
 			return fmt.Errorf("reserve for order %s: %w", msg.Payload.OrderID, err)
 		}
 
+		// The cart's holds are done with once its order has been through
+		// reservation, whatever the outcome.
+		if msg.Payload.UserID != "" {
+			if err := repo.ReleaseAllWithTx(ctx, tx, msg.Payload.UserID); err != nil {
+				return fmt.Errorf("release holds for user %s: %w", msg.Payload.UserID, err)
+			}
+		}
+
 		if msg.Envelope != nil && incomingSeq != 0 {
 			if err := localDedup.UpsertLastSequence(ctx, consumerName, partitionKey, incomingSeq); err != nil {
 				return err
@@ -112,6 +120,55 @@ func OrderCreatedHandler(repo inventory.TransactionalRepository, dedupRepo *dedu
 	}
 }
 
+// OrderCancelledHandler puts the stock still reserved for a cancelled order
+// back. Each order is released once, so redelivered or duplicated
+// cancellations change nothing.
+func OrderCancelledHandler(repo inventory.TransactionalRepository, logger *log.Logger, consumeEnveloped bool) HandlerFunc {
+	return func(ctx context.Context, body []byte) error {
+		msg, err := parseOrderCancelled(body, consumeEnveloped)
+		if err != nil {
+			return err
+		}
+		if msg.Payload.OrderID == "" {
+			return fmt.Errorf("missing orderId")
+		}
+
+		lines := make([]inventory.Line, 0, len(msg.Payload.Reserved))
+		for _, it := range msg.Payload.Reserved {
+			if it.ProductID == "" || it.Quantity <= 0 {
+				continue
+			}
+			lines = append(lines, inventory.Line{ProductID: it.ProductID, Quantity: it.Quantity})
+		}
+		if len(lines) == 0 {
+			logger.Printf("order cancelled with nothing reserved order=%s reason=%s", msg.Payload.OrderID, msg.Payload.Reason)
+			return nil
+		}
+
+		tx, err := repo.BeginTx(ctx, pgx.TxOptions{})
+		if err != nil {
+			return fmt.Errorf("begin tx: %w", err)
+		}
+		defer func() { _ = tx.Rollback(ctx) }()
+
+		released, err := repo.ReleaseOrderWithTx(ctx, tx, msg.Payload.OrderID, lines)
+		if err != nil {
+			return fmt.Errorf("release order %s: %w", msg.Payload.OrderID, err)
+		}
+		if !released {
+			logger.Printf("skip duplicate cancellation order=%s", msg.Payload.OrderID)
+			return nil
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("commit release: %w", err)
+		}
+
+		logger.Printf("stock released for cancelled order=%s lines=%d reason=%s", msg.Payload.OrderID, len(lines), msg.Payload.Reason)
+		return nil
+	}
+}
+
 func consumeEnvelopedEnabled() bool {
 	v := os.Getenv(consumeEnvelopedEnv)
 	if v == "" {
@@ -42,6 +42,30 @@ func TestParseOrderCreatedEnvelopeExample(t *testing.T) {
 	}
 }
 
+func TestParseOrderCreatedV2EnvelopeExample(t *testing.T) {
+	body, err := os.ReadFile(filepath.Join("..", "..", "..", "..", "contracts", "examples", "order", "OrderCreated.v2.json"))
+	if err != nil {
+		t.Fatalf("read example: %v", err)
+	}
+
+	msg, err := parseOrderCreated(body, true)
+	if err != nil {
+		t.Fatalf("parse example: %v", err)
+	}
+
+	if msg.Envelope == nil || msg.Envelope.EventVersion != 2 {
+		t.Fatalf("unexpected envelope metadata %+v", msg.Envelope)
+	}
+	if msg.Payload.OrderID == "" || len(msg.Payload.Items) == 0 {
+		t.Fatalf("unexpected payload %+v", msg.Payload)
+	}
+	for _, it := range msg.Payload.Items {
+		if it.ProductID == "" || it.Quantity < 1 {
+			t.Fatalf("unexpected item %+v", it)
+		}
+	}
+}
+
 func TestOrderCreatedHandlerDedupAndGap(t *testing.T) {
 	store := newFakeStore(map[string]int{
 		"p1": 5,
@@ -64,6 +88,9 @@ func TestOrderCreatedHandlerDedupAndGap(t *testing.T) {
 	if store.available["p1"] != 3 {
 		t.Fatalf("available after first=%d want=3", store.available["p1"])
 	}
+	if len(repo.releasedHolders) != 1 || repo.releasedHolders[0] != "user-1" {
+		t.Fatalf("released holders=%v want=[user-1]", repo.releasedHolders)
+	}
 
 	// duplicate sequence should be ignored
 	if err := handler(context.Background(), body); err != nil {
@@ -75,6 +102,9 @@ func TestOrderCreatedHandlerDedupAndGap(t *testing.T) {
 	if store.available["p1"] != 3 {
 		t.Fatalf("available after duplicate=%d want=3", store.available["p1"])
 	}
+	if len(repo.releasedHolders) != 1 {
+		t.Fatalf("released holders after duplicate=%v want=[user-1]", repo.releasedHolders)
+	}
 
 	// higher sequence with gap should process
 	msgGap := makeOrderCreatedMessage("order-1", "user-1", "p1", 1, 3)
@@ -143,6 +173,114 @@ func TestOrderCreatedHandlerPropagatesCorrelation(t *testing.T) {
 	}
 }
 
+func TestParseOrderCancelledEnvelopeExample(t *testing.T) {
+	body, err := os.ReadFile(filepath.Join("..", "..", "..", "..", "contracts", "examples", "order", "OrderCancelled.v1.json"))
+	if err != nil {
+		t.Fatalf("read example: %v", err)
+	}
+
+	msg, err := parseOrderCancelled(body, true)
+	if err != nil {
+		t.Fatalf("parse example: %v", err)
+	}
+
+	if msg.Envelope == nil || msg.Envelope.EventName != EventTypeOrderCancelled {
+		t.Fatalf("unexpected envelope metadata %+v", msg.Envelope)
+	}
+	if msg.Payload.OrderID != "f1e2d3c4-b5a6-7988-99aa-bbccddeeff00" {
+		t.Fatalf("unexpected order id %s", msg.Payload.OrderID)
+	}
+	if len(msg.Payload.Reserved) != 1 || msg.Payload.Reserved[0].Quantity != 1 {
+		t.Fatalf("unexpected reserved %+v", msg.Payload.Reserved)
+	}
+}
+
+func TestOrderCancelledHandlerReleasesOnce(t *testing.T) {
+	store := newFakeStore(map[string]int{
+		"p1": 3,
+	})
+	repo := &fakeTransactionalRepo{store: store}
+
+	handler := OrderCancelledHandler(repo, log.New(os.Stdout, "", 0), true)
+
+	body, _ := json.Marshal(makeOrderCancelledMessage("order-1", "p1", 2))
+	if err := handler(context.Background(), body); err != nil {
+		t.Fatalf("first handle: %v", err)
+	}
+	if store.available["p1"] != 5 {
+		t.Fatalf("available after first=%d want=5", store.available["p1"])
+	}
+
+	// A redelivery, or the same cancellation with a new event ID, is ignored.
+	body, _ = json.Marshal(makeOrderCancelledMessage("order-1", "p1", 2))
+	if err := handler(context.Background(), body); err != nil {
+		t.Fatalf("second handle: %v", err)
+	}
+	if store.available["p1"] != 5 {
+		t.Fatalf("available after duplicate=%d want=5", store.available["p1"])
+	}
+}
+
+func TestOrderCancelledHandlerLegacyAndNothingReserved(t *testing.T) {
+	store := newFakeStore(map[string]int{
+		"p1": 3,
+	})
+	repo := &fakeTransactionalRepo{store: store}
+
+	handler := OrderCancelledHandler(repo, log.New(os.Stdout, "", 0), false)
+
+	legacy := legacyOrderCancelled{
+		EventType: EventTypeOrderCancelled,
+		OrderCancelledPayload: OrderCancelledPayload{
+			OrderID:   "order-1",
+			UserID:    "user-1",
+			Reason:    "customer_requested",
+			Reserved:  []OrderLineItem{{ProductID: "p1", Quantity: 1}},
+			Timestamp: time.Now().UTC(),
+		},
+	}
+	body, _ := json.Marshal(legacy)
+	if err := handler(context.Background(), body); err != nil {
+		t.Fatalf("legacy handle: %v", err)
+	}
+	if store.available["p1"] != 4 {
+		t.Fatalf("available after legacy=%d want=4", store.available["p1"])
+	}
+
+	// An order cancelled for depleted stock has nothing reserved to give back.
+	legacy.OrderID = "order-2"
+	legacy.Reason = "stock_depleted"
+	legacy.Reserved = nil
+	body, _ = json.Marshal(legacy)
+	if err := handler(context.Background(), body); err != nil {
+		t.Fatalf("nothing reserved handle: %v", err)
+	}
+	if store.available["p1"] != 4 || store.released["order-2"] {
+		t.Fatalf("unexpected release for order-2: available=%d", store.available["p1"])
+	}
+}
+
+func makeOrderCancelledMessage(orderID, productID string, quantity int) map[string]any {
+	return map[string]any{
+		"eventName":     EventTypeOrderCancelled,
+		"eventVersion":  1,
+		"eventId":       uuid.NewString(),
+		"correlationId": uuid.NewString(),
+		"producer":      "order-service",
+		"partitionKey":  orderID,
+		"sequence":      4,
+		"occurredAt":    time.Now().UTC(),
+		"schema":        "contracts/events/order/OrderCancelled.v1.payload.schema.json",
+		"payload": OrderCancelledPayload{
+			OrderID:   orderID,
+			UserID:    "user-1",
+			Reason:    "customer_requested",
+			Reserved:  []OrderLineItem{{ProductID: productID, Quantity: quantity}},
+			Timestamp: time.Now().UTC(),
+		},
+	}
+}
+
 func makeOrderCreatedMessage(orderID, userID, productID string, quantity int, seq int64) EnvelopedOrderCreated {
 	return EnvelopedOrderCreated{
 		EventEnvelope: EventEnvelope{
@@ -199,6 +337,7 @@ func (f *capturingPublisher) PublishStockDepleted(ctx context.Context, meta Even
 type fakeStore struct {
 	available   map[string]int
 	checkpoints map[string]map[string]int64
+	released    map[string]bool
 }
 
 func newFakeStore(avail map[string]int) *fakeStore {
@@ -209,12 +348,14 @@ func newFakeStore(avail map[string]int) *fakeStore {
 	return &fakeStore{
 		available:   cp,
 		checkpoints: make(map[string]map[string]int64),
+		released:    make(map[string]bool),
 	}
 }
 
 type fakeTransactionalRepo struct {
-	store      *fakeStore
-	reserveErr error
+	store           *fakeStore
+	reserveErr      error
+	releasedHolders []string
 }
 
 func (r *fakeTransactionalRepo) Get(ctx context.Context, productID string) (inventory.StockItem, error) {
@@ -247,10 +388,41 @@ func (r *fakeTransactionalRepo) ReserveWithTx(ctx context.Context, tx pgx.Tx, or
 	return fTx.reserve(lines), nil
 }
 
+func (r *fakeTransactionalRepo) ReleaseAllWithTx(ctx context.Context, tx pgx.Tx, holderID string) error {
+	r.releasedHolders = append(r.releasedHolders, holderID)
+	return nil
+}
+
+func (r *fakeTransactionalRepo) ReleaseOrderWithTx(ctx context.Context, tx pgx.Tx, orderID string, lines []inventory.Line) (bool, error) {
+	fTx := tx.(*fakeTx)
+	return fTx.releaseOrder(orderID, lines), nil
+}
+
+func (r *fakeTransactionalRepo) Hold(ctx context.Context, holderID, productID string, quantity int, ttl time.Duration) (inventory.HoldResult, error) {
+	return inventory.HoldResult{}, nil
+}
+
+func (r *fakeTransactionalRepo) Release(ctx context.Context, holderID, productID string) error {
+	return nil
+}
+
+func (r *fakeTransactionalRepo) ReleaseAll(ctx context.Context, holderID string) error {
+	return nil
+}
+
+func (r *fakeTransactionalRepo) Renew(ctx context.Context, holderID string, ttl time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func (r *fakeTransactionalRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
 type fakeTx struct {
 	store              *fakeStore
 	pendingAvailable   map[string]int
 	pendingCheckpoints map[string]map[string]int64
+	pendingReleased    map[string]bool
 	closed             bool
 }
 
@@ -259,6 +431,7 @@ func newFakeTx(store *fakeStore) *fakeTx {
 		store:              store,
 		pendingAvailable:   make(map[string]int),
 		pendingCheckpoints: make(map[string]map[string]int64),
+		pendingReleased:    make(map[string]bool),
 	}
 }
 
@@ -278,6 +451,9 @@ func (t *fakeTx) Commit(ctx context.Context) error {
 			t.store.checkpoints[consumer][pk] = seq
 		}
 	}
+	for orderID := range t.pendingReleased {
+		t.store.released[orderID] = true
+	}
 	t.closed = true
 	return nil
 }
@@ -367,6 +543,21 @@ func (t *fakeTx) reserve(lines []inventory.Line) inventory.ReserveResult {
 	return res
 }
 
+func (t *fakeTx) releaseOrder(orderID string, lines []inventory.Line) bool {
+	if t.store.released[orderID] || t.pendingReleased[orderID] {
+		return false
+	}
+	t.pendingReleased[orderID] = true
+	for _, line := range lines {
+		available, ok := t.pendingAvailable[line.ProductID]
+		if !ok {
+			available = t.store.available[line.ProductID]
+		}
+		t.pendingAvailable[line.ProductID] = available + line.Quantity
+	}
+	return true
+}
+
 type fakeRow struct {
 	val int64
 	err error
@@ -5,11 +5,13 @@ import (
 )
 
 const (
-	EventsExchange          = "ecommerce.events"
-	OrderCreatedRoutingKey  = "order.created.v1"
-	StockReservedRoutingKey = "stock.reserved.v1"
-	StockDepletedRoutingKey = "stock.depleted.v1"
-	inventoryServiceName    = "inventory-service-go"
+	EventsExchange           = "ecommerce.events"
+	OrderCreatedRoutingKey   = "order.created.v1"
+	OrderCreatedV2RoutingKey = "order.created.v2"
+	OrderCancelledRoutingKey = "order.cancelled.v1"
+	StockReservedRoutingKey  = "stock.reserved.v1"
+	StockDepletedRoutingKey  = "stock.depleted.v1"
+	inventoryServiceName     = "inventory-service-go"
 )
 
 func serviceQueue(serviceName, routingKey string) string {
@@ -0,0 +1,61 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	// QueueOrderCancelled is published by order-service-go Publisher.PublishOrderCancelled.
+	QueueOrderCancelled = OrderCancelledRoutingKey
+
+	EventTypeOrderCancelled = "OrderCancelled"
+)
+
+// OrderCancelledPayload matches the v1 payload schema. Only the order and the
+// lines still reserved for it are needed to release stock.
+type OrderCancelledPayload struct {
+	OrderID   string          `json:"orderId"`
+	UserID    string          `json:"userId"`
+	Reason    string          `json:"reason"`
+	Reserved  []OrderLineItem `json:"reserved"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// legacyOrderCancelled represents the non-enveloped shape.
+type legacyOrderCancelled struct {
+	EventType string `json:"eventType"`
+	OrderCancelledPayload
+}
+
+type OrderCancelledMessage struct {
+	Envelope *EventEnvelope
+	Payload  OrderCancelledPayload
+	Legacy   bool
+}
+
+func parseOrderCancelled(body []byte, consumeEnveloped bool) (OrderCancelledMessage, error) {
+	if consumeEnveloped {
+		env, err := parseEnvelope(body)
+		if err == nil && env.EventName != "" {
+			if err := env.Validate(EventTypeOrderCancelled, 1); err != nil {
+				return OrderCancelledMessage{}, fmt.Errorf("envelope validate: %w", err)
+			}
+			var payload OrderCancelledPayload
+			if err := json.Unmarshal(env.Payload, &payload); err != nil {
+				return OrderCancelledMessage{}, fmt.Errorf("unmarshal order cancelled payload: %w", err)
+			}
+			return OrderCancelledMessage{Envelope: &env, Payload: payload}, nil
+		}
+	}
+
+	var legacy legacyOrderCancelled
+	if err := json.Unmarshal(body, &legacy); err != nil {
+		return OrderCancelledMessage{}, fmt.Errorf("unmarshal legacy order cancelled: %w", err)
+	}
+	if legacy.OrderID == "" {
+		return OrderCancelledMessage{}, fmt.Errorf("missing orderId")
+	}
+	return OrderCancelledMessage{Payload: legacy.OrderCancelledPayload, Legacy: true}, nil
+}
@@ -9,6 +9,9 @@ import (
 const (
 	// QueueOrderCreated is published by order-service-go Publisher.PublishOrderCreated.
 	QueueOrderCreated = OrderCreatedRoutingKey
+	// QueueOrderCreatedV2 carries Money amounts and is consumed instead of v1
+	// when enveloped events are enabled.
+	QueueOrderCreatedV2 = OrderCreatedV2RoutingKey
 
 	EventTypeOrderCreated = "OrderCreated"
 )
@@ -35,6 +38,27 @@ type OrderLineItem struct {
 	Price     float64 `json:"price,omitempty"`
 }
 
+// Money is an amount in minor units with its ISO 4217 currency, as carried by
+// v2 payloads.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// orderCreatedV2Payload matches the v2 payload schema.
+type orderCreatedV2Payload struct {
+	OrderID string `json:"orderId"`
+	CartID  string `json:"cartId,omitempty"`
+	UserID  string `json:"userId"`
+	Items   []struct {
+		ProductID string `json:"productId"`
+		Quantity  int    `json:"quantity"`
+		Price     Money  `json:"price"`
+	} `json:"items"`
+	TotalAmount Money     `json:"totalAmount"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
 // legacyOrderCreated represents the previous non-enveloped shape.
 type legacyOrderCreated struct {
 	EventType   string          `json:"eventType"`
@@ -56,6 +80,9 @@ func parseOrderCreated(body []byte, consumeEnveloped bool) (OrderCreatedMessage,
 	if consumeEnveloped {
 		env, err := parseEnvelope(body)
 		if err == nil && env.EventName != "" {
+			if env.EventVersion == 2 {
+				return parseOrderCreatedV2(env)
+			}
 			if err := env.Validate(EventTypeOrderCreated, 1); err != nil {
 				return OrderCreatedMessage{}, fmt.Errorf("envelope validate: %w", err)
 			}
@@ -87,3 +114,27 @@ func parseOrderCreated(body []byte, consumeEnveloped bool) (OrderCreatedMessage,
 		Legacy: true,
 	}, nil
 }
+
+// parseOrderCreatedV2 maps a v2 event onto the payload used by the handler.
+// Reservations only need products and quantities, so the Money amounts are
+// not carried over.
+func parseOrderCreatedV2(env EventEnvelope) (OrderCreatedMessage, error) {
+	if err := env.Validate(EventTypeOrderCreated, 2); err != nil {
+		return OrderCreatedMessage{}, fmt.Errorf("envelope validate: %w", err)
+	}
+	var v2 orderCreatedV2Payload
+	if err := json.Unmarshal(env.Payload, &v2); err != nil {
+		return OrderCreatedMessage{}, fmt.Errorf("unmarshal order payload: %w", err)
+	}
+
+	payload := OrderCreatedPayload{
+		OrderID:   v2.OrderID,
+		CartID:    v2.CartID,
+		UserID:    v2.UserID,
+		Timestamp: v2.Timestamp,
+	}
+	for _, it := range v2.Items {
+		payload.Items = append(payload.Items, OrderLineItem{ProductID: it.ProductID, Quantity: it.Quantity})
+	}
+	return OrderCreatedMessage{Envelope: &env, Payload: payload}, nil
+}
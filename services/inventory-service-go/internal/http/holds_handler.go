@@ -0,0 +1,114 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/services/inventory-service-go/internal/problem"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxHoldTTL bounds how long a single request can hold stock for; holders
+// renew to keep stock longer.
+const maxHoldTTL = 24 * time.Hour
+
+type holdRequest struct {
+	Quantity   int `json:"quantity"`
+	TTLSeconds int `json:"ttlSeconds"`
+}
+
+type renewRequest struct {
+	TTLSeconds int `json:"ttlSeconds"`
+}
+
+// shortageResponse is the problem body of a hold refused for lack of stock.
+type shortageResponse struct {
+	problem.Details
+	ProductID string `json:"productId"`
+	Requested int    `json:"requested"`
+	Free      int    `json:"free"`
+}
+
+// PutHold sets the holder's hold on a product, answering 409 with the free
+// quantity when there is not enough stock.
+func (h *Handler) PutHold(w http.ResponseWriter, r *http.Request) {
+	holderID := chi.URLParam(r, "holderId")
+	productID := chi.URLParam(r, "productId")
+
+	var req holdRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "invalid json")
+		return
+	}
+	if req.Quantity <= 0 {
+		writeInvalid(w, r, "quantity", problem.FieldInvalid, "quantity must be positive")
+		return
+	}
+	ttl, ok := holdTTL(req.TTLSeconds)
+	if !ok {
+		writeInvalid(w, r, "ttlSeconds", problem.FieldInvalid, "ttlSeconds must be positive and at most a day")
+		return
+	}
+
+	res, err := h.repo.Hold(r.Context(), holderID, productID, req.Quantity, ttl)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "internal error")
+		return
+	}
+	if res.Depleted != nil {
+		problem.Write(w, r, &shortageResponse{
+			Details:   problem.New(http.StatusConflict, codeInsufficientStock, fmt.Sprintf("insufficient stock: %d available", res.Depleted.Available)),
+			ProductID: res.Depleted.ProductID,
+			Requested: res.Depleted.Requested,
+			Free:      res.Depleted.Available,
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res.Hold)
+}
+
+func (h *Handler) DeleteHold(w http.ResponseWriter, r *http.Request) {
+	if err := h.repo.Release(r.Context(), chi.URLParam(r, "holderId"), chi.URLParam(r, "productId")); err != nil {
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "internal error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) DeleteHolds(w http.ResponseWriter, r *http.Request) {
+	if err := h.repo.ReleaseAll(r.Context(), chi.URLParam(r, "holderId")); err != nil {
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "internal error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RenewHolds extends all of the holder's unexpired holds.
+func (h *Handler) RenewHolds(w http.ResponseWriter, r *http.Request) {
+	var req renewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "invalid json")
+		return
+	}
+	ttl, ok := holdTTL(req.TTLSeconds)
+	if !ok {
+		writeInvalid(w, r, "ttlSeconds", problem.FieldInvalid, "ttlSeconds must be positive and at most a day")
+		return
+	}
+
+	n, err := h.repo.Renew(r.Context(), chi.URLParam(r, "holderId"), ttl)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "internal error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"renewed": n})
+}
+
+func holdTTL(seconds int) (time.Duration, bool) {
+	ttl := time.Duration(seconds) * time.Second
+	return ttl, seconds > 0 && ttl <= maxHoldTTL
+}
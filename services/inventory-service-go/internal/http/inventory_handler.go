@@ -7,6 +7,7 @@ import (
 	"strconv"
 
 	"github.com/andreasstove999/ecommerce-system/services/inventory-service-go/internal/inventory"
+	"github.com/andreasstove999/ecommerce-system/services/inventory-service-go/internal/problem"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -28,10 +29,10 @@ func (h *Handler) GetAvailability(w http.ResponseWriter, r *http.Request) {
 	item, err := h.repo.Get(r.Context(), productID)
 	if err != nil {
 		if errors.Is(err, inventory.ErrNotFound) {
-			http.Error(w, "not found", http.StatusNotFound)
+			writeError(w, r, http.StatusNotFound, codeProductNotFound, "product not found")
 			return
 		}
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "internal error")
 		return
 	}
 
@@ -70,17 +71,21 @@ func (h *Handler) AdjustAvailability(w http.ResponseWriter, r *http.Request) {
 	var req adjustRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "bad request decoding", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "invalid json")
 		return
 	}
 
-	if req.ProductID == "" || req.Available < 0 {
-		http.Error(w, "bad request invalid payload", http.StatusBadRequest)
+	if req.ProductID == "" {
+		writeInvalid(w, r, "productId", problem.FieldRequired, "missing productId")
+		return
+	}
+	if req.Available < 0 {
+		writeInvalid(w, r, "available", problem.FieldInvalid, "available must not be negative")
 		return
 	}
 
 	if err := h.repo.SetAvailable(r.Context(), req.ProductID, int(req.Available)); err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "internal error")
 		return
 	}
 
@@ -92,3 +97,20 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(v)
 }
+
+// Problem codes specific to the inventory service; the shared codes live in
+// the problem package.
+const (
+	codeProductNotFound   = "product_not_found"
+	codeInsufficientStock = "insufficient_stock"
+)
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, msg string) {
+	problem.Error(w, r, status, code, msg)
+}
+
+// writeInvalid reports one request field that failed validation.
+func writeInvalid(w http.ResponseWriter, r *http.Request, field, code, msg string) {
+	d := problem.Invalid(msg, problem.FieldError{Field: field, Code: code, Message: msg})
+	problem.Write(w, r, &d)
+}
@@ -9,12 +9,15 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/andreasstove999/ecommerce-system/services/inventory-service-go/internal/inventory"
+	"github.com/andreasstove999/ecommerce-system/services/inventory-service-go/internal/problem"
 )
 
 type fakeRepo struct {
 	items  map[string]int
+	holds  map[string]map[string]int
 	getErr error
 	setErr error
 }
@@ -43,6 +46,45 @@ func (r *fakeRepo) Reserve(ctx context.Context, orderID string, lines []inventor
 	return inventory.ReserveResult{}, nil
 }
 
+func (r *fakeRepo) Hold(ctx context.Context, holderID, productID string, quantity int, ttl time.Duration) (inventory.HoldResult, error) {
+	free := r.items[productID]
+	for holder, held := range r.holds {
+		if holder != holderID {
+			free -= held[productID]
+		}
+	}
+	if quantity > free {
+		return inventory.HoldResult{Depleted: &inventory.DepletedLine{ProductID: productID, Requested: quantity, Available: free}}, nil
+	}
+	if r.holds == nil {
+		r.holds = map[string]map[string]int{}
+	}
+	if r.holds[holderID] == nil {
+		r.holds[holderID] = map[string]int{}
+	}
+	r.holds[holderID][productID] = quantity
+	return inventory.HoldResult{Hold: &inventory.Hold{
+		HolderID:  holderID,
+		ProductID: productID,
+		Quantity:  quantity,
+		ExpiresAt: time.Now().Add(ttl),
+	}}, nil
+}
+func (r *fakeRepo) Release(ctx context.Context, holderID, productID string) error {
+	delete(r.holds[holderID], productID)
+	return nil
+}
+func (r *fakeRepo) ReleaseAll(ctx context.Context, holderID string) error {
+	delete(r.holds, holderID)
+	return nil
+}
+func (r *fakeRepo) Renew(ctx context.Context, holderID string, ttl time.Duration) (int64, error) {
+	return int64(len(r.holds[holderID])), nil
+}
+func (r *fakeRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
 func TestHealth(t *testing.T) {
 	h := NewHandler(&fakeRepo{items: map[string]int{}})
 	router := NewRouter(h)
@@ -72,6 +114,9 @@ func TestGetAvailability_NotFound(t *testing.T) {
 	if res.Code != http.StatusNotFound {
 		t.Fatalf("expected 404, got %d", res.Code)
 	}
+	if ct := res.Header().Get("Content-Type"); ct != problem.ContentType {
+		t.Fatalf("expected a problem response, got %q", ct)
+	}
 }
 
 func TestGetAvailability_OK(t *testing.T) {
@@ -171,3 +216,109 @@ func TestAdjustAvailability_ServiceError(t *testing.T) {
 		t.Fatalf("expected 500, got %d", res.Code)
 	}
 }
+
+func TestPutHold_OK(t *testing.T) {
+	repo := &fakeRepo{items: map[string]int{"p1": 5}}
+	r := NewRouter(NewHandler(repo))
+
+	req := httptest.NewRequest(http.MethodPut, "/api/inventory/holds/cart-1/p1", strings.NewReader(`{"quantity":3,"ttlSeconds":900}`))
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d, body: %s", res.Code, res.Body.String())
+	}
+	var hold inventory.Hold
+	if err := json.NewDecoder(res.Body).Decode(&hold); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if hold.HolderID != "cart-1" || hold.ProductID != "p1" || hold.Quantity != 3 || hold.ExpiresAt.IsZero() {
+		t.Fatalf("unexpected hold: %+v", hold)
+	}
+	if got := repo.holds["cart-1"]["p1"]; got != 3 {
+		t.Fatalf("expected repo to hold 3, got %d", got)
+	}
+}
+
+func TestPutHold_Short(t *testing.T) {
+	repo := &fakeRepo{
+		items: map[string]int{"p1": 5},
+		holds: map[string]map[string]int{"cart-2": {"p1": 4}},
+	}
+	r := NewRouter(NewHandler(repo))
+
+	req := httptest.NewRequest(http.MethodPut, "/api/inventory/holds/cart-1/p1", strings.NewReader(`{"quantity":2,"ttlSeconds":900}`))
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+
+	if res.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", res.Code)
+	}
+	var body shortageResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body.ProductID != "p1" || body.Requested != 2 || body.Free != 1 || body.Code != codeInsufficientStock {
+		t.Fatalf("unexpected body: %+v", body)
+	}
+	if _, ok := repo.holds["cart-1"]; ok {
+		t.Fatalf("expected no hold for cart-1, got %v", repo.holds["cart-1"])
+	}
+}
+
+func TestPutHold_InvalidPayload(t *testing.T) {
+	for _, body := range []string{
+		`{"quantity":0,"ttlSeconds":900}`,
+		`{"quantity":1}`,
+		`{"quantity":1,"ttlSeconds":86401}`,
+		`{invalid`,
+	} {
+		r := NewRouter(NewHandler(&fakeRepo{items: map[string]int{"p1": 5}}))
+
+		req := httptest.NewRequest(http.MethodPut, "/api/inventory/holds/cart-1/p1", strings.NewReader(body))
+		res := httptest.NewRecorder()
+		r.ServeHTTP(res, req)
+
+		if res.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", body, res.Code)
+		}
+	}
+}
+
+func TestReleaseHolds(t *testing.T) {
+	repo := &fakeRepo{
+		items: map[string]int{"p1": 5, "p2": 5},
+		holds: map[string]map[string]int{"cart-1": {"p1": 1, "p2": 2}},
+	}
+	r := NewRouter(NewHandler(repo))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/inventory/holds/cart-1/p1", nil)
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	if res.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", res.Code)
+	}
+	if _, ok := repo.holds["cart-1"]["p1"]; ok {
+		t.Fatalf("expected p1 hold to be released")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/inventory/holds/cart-1/renew", strings.NewReader(`{"ttlSeconds":900}`))
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", res.Code)
+	}
+	if body := strings.TrimSpace(res.Body.String()); body != `{"renewed":1}` {
+		t.Fatalf("unexpected renew body %s", body)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/inventory/holds/cart-1", nil)
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	if res.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", res.Code)
+	}
+	if _, ok := repo.holds["cart-1"]; ok {
+		t.Fatalf("expected all holds to be released")
+	}
+}
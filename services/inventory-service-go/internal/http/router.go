@@ -19,6 +19,13 @@ func NewRouter(h *Handler) http.Handler {
 	r.Route("/api/inventory", func(r chi.Router) {
 		r.Get("/{productId}", h.GetAvailability)
 		r.Post("/adjust", h.AdjustAvailability)
+
+		r.Route("/holds/{holderId}", func(r chi.Router) {
+			r.Delete("/", h.DeleteHolds)
+			r.Post("/renew", h.RenewHolds)
+			r.Put("/{productId}", h.PutHold)
+			r.Delete("/{productId}", h.DeleteHold)
+		})
 	})
 
 	return r
@@ -0,0 +1,121 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// HoldRepository manages soft holds. Holds don't change the available stock;
+// they only lower what other holders can hold until they expire. Order
+// reservations ignore them.
+type HoldRepository interface {
+	// Hold sets the holder's hold on a product to quantity, replacing any
+	// previous one, if the product has enough free stock besides it.
+	Hold(ctx context.Context, holderID, productID string, quantity int, ttl time.Duration) (HoldResult, error)
+	Release(ctx context.Context, holderID, productID string) error
+	ReleaseAll(ctx context.Context, holderID string) error
+	// Renew pushes back the expiry of the holder's unexpired holds and returns
+	// how many there were.
+	Renew(ctx context.Context, holderID string, ttl time.Duration) (int64, error)
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+func (r *PostgresRepository) Hold(ctx context.Context, holderID, productID string, quantity int, ttl time.Duration) (HoldResult, error) {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return HoldResult{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// Locking the stock row serialises holds on the product.
+	var available int
+	err = tx.QueryRow(ctx, `
+		SELECT available
+		FROM inventory_stock
+		WHERE product_id=$1
+		FOR UPDATE
+	`, productID).Scan(&available)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return HoldResult{}, err
+		}
+		available = 0
+	}
+
+	var heldByOthers int
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(quantity), 0)
+		FROM holds
+		WHERE product_id=$1 AND holder_id<>$2 AND expires_at > now()
+	`, productID, holderID).Scan(&heldByOthers)
+	if err != nil {
+		return HoldResult{}, err
+	}
+
+	if free := available - heldByOthers; quantity > free {
+		return HoldResult{Depleted: &DepletedLine{
+			ProductID: productID,
+			Requested: quantity,
+			Available: max(free, 0),
+		}}, nil
+	}
+
+	hold := Hold{HolderID: holderID, ProductID: productID, Quantity: quantity}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO holds(holder_id, product_id, quantity, expires_at)
+		VALUES($1, $2, $3, now() + make_interval(secs => $4))
+		ON CONFLICT (holder_id, product_id) DO UPDATE
+		SET quantity=EXCLUDED.quantity, expires_at=EXCLUDED.expires_at, updated_at=now()
+		RETURNING expires_at
+	`, holderID, productID, quantity, ttl.Seconds()).Scan(&hold.ExpiresAt)
+	if err != nil {
+		return HoldResult{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return HoldResult{}, err
+	}
+	return HoldResult{Hold: &hold}, nil
+}
+
+func (r *PostgresRepository) Release(ctx context.Context, holderID, productID string) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM holds WHERE holder_id=$1 AND product_id=$2`, holderID, productID)
+	return err
+}
+
+func (r *PostgresRepository) ReleaseAll(ctx context.Context, holderID string) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM holds WHERE holder_id=$1`, holderID)
+	return err
+}
+
+// ReleaseAllWithTx drops the holder's holds as part of tx, so stock reserved
+// for an order is not also counted as held by the cart it came from.
+func (r *PostgresRepository) ReleaseAllWithTx(ctx context.Context, tx pgx.Tx, holderID string) error {
+	_, err := tx.Exec(ctx, `DELETE FROM holds WHERE holder_id=$1`, holderID)
+	return err
+}
+
+func (r *PostgresRepository) Renew(ctx context.Context, holderID string, ttl time.Duration) (int64, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE holds
+		SET expires_at = now() + make_interval(secs => $2), updated_at=now()
+		WHERE holder_id=$1 AND expires_at > now()
+	`, holderID, ttl.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// DeleteExpired removes holds past their expiry. Expired holds are already
+// ignored everywhere; this only keeps the table small.
+func (r *PostgresRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM holds WHERE expires_at <= now()`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,113 @@
+package inventory
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHold_Success(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	repo := NewPostgresRepository(mock)
+	expiresAt := time.Now().Add(15 * time.Minute).UTC()
+
+	mock.ExpectBeginTx(pgx.TxOptions{})
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT available FROM inventory_stock WHERE product_id=$1 FOR UPDATE")).
+		WithArgs("p1").
+		WillReturnRows(mock.NewRows([]string{"available"}).AddRow(10))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(SUM(quantity), 0) FROM holds WHERE product_id=$1 AND holder_id<>$2 AND expires_at > now()")).
+		WithArgs("p1", "cart-1").
+		WillReturnRows(mock.NewRows([]string{"sum"}).AddRow(7))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO holds(holder_id, product_id, quantity, expires_at)")).
+		WithArgs("cart-1", "p1", 3, float64(900)).
+		WillReturnRows(mock.NewRows([]string{"expires_at"}).AddRow(expiresAt))
+	mock.ExpectCommit()
+
+	res, err := repo.Hold(context.Background(), "cart-1", "p1", 3, 15*time.Minute)
+	require.NoError(t, err)
+	require.Nil(t, res.Depleted)
+	require.NotNil(t, res.Hold)
+	assert.Equal(t, Hold{HolderID: "cart-1", ProductID: "p1", Quantity: 3, ExpiresAt: expiresAt}, *res.Hold)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHold_Short(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	repo := NewPostgresRepository(mock)
+
+	mock.ExpectBeginTx(pgx.TxOptions{})
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT available FROM inventory_stock WHERE product_id=$1 FOR UPDATE")).
+		WithArgs("p1").
+		WillReturnRows(mock.NewRows([]string{"available"}).AddRow(10))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM holds WHERE product_id=$1 AND holder_id<>$2")).
+		WithArgs("p1", "cart-1").
+		WillReturnRows(mock.NewRows([]string{"sum"}).AddRow(8))
+	mock.ExpectRollback()
+
+	res, err := repo.Hold(context.Background(), "cart-1", "p1", 3, 15*time.Minute)
+	require.NoError(t, err)
+	require.Nil(t, res.Hold)
+	assert.Equal(t, &DepletedLine{ProductID: "p1", Requested: 3, Available: 2}, res.Depleted)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHold_UnknownProduct(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	repo := NewPostgresRepository(mock)
+
+	mock.ExpectBeginTx(pgx.TxOptions{})
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT available FROM inventory_stock WHERE product_id=$1 FOR UPDATE")).
+		WithArgs("p-missing").
+		WillReturnError(pgx.ErrNoRows)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM holds WHERE product_id=$1 AND holder_id<>$2")).
+		WithArgs("p-missing", "cart-1").
+		WillReturnRows(mock.NewRows([]string{"sum"}).AddRow(0))
+	mock.ExpectRollback()
+
+	res, err := repo.Hold(context.Background(), "cart-1", "p-missing", 1, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, &DepletedLine{ProductID: "p-missing", Requested: 1, Available: 0}, res.Depleted)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRenewAndDeleteExpired(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	repo := NewPostgresRepository(mock)
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE holds SET expires_at = now() + make_interval(secs => $2), updated_at=now() WHERE holder_id=$1 AND expires_at > now()")).
+		WithArgs("cart-1", float64(600)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 2))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM holds WHERE expires_at <= now()")).
+		WillReturnResult(pgxmock.NewResult("DELETE", 5))
+
+	n, err := repo.Renew(context.Background(), "cart-1", 10*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	n, err = repo.DeleteExpired(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
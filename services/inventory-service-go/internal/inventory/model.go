@@ -1,8 +1,14 @@
 package inventory
 
+import "time"
+
+// StockItem is a product's stock. Available is what is on the shelf, Held is
+// the part of it under unexpired cart holds and Free is what is left to hold.
 type StockItem struct {
 	ProductID string `json:"productId"`
 	Available int    `json:"available"`
+	Held      int    `json:"held"`
+	Free      int    `json:"free"`
 }
 
 type Line struct {
@@ -11,12 +17,28 @@ type Line struct {
 }
 
 type DepletedLine struct {
-	ProductID  string
-	Requested  int
-	Available  int
+	ProductID string
+	Requested int
+	Available int
 }
 
 type ReserveResult struct {
 	Reserved []Line
 	Depleted []DepletedLine
 }
+
+// Hold keeps Quantity of a product aside for a holder, normally a cart, until
+// ExpiresAt.
+type Hold struct {
+	HolderID  string    `json:"holderId"`
+	ProductID string    `json:"productId"`
+	Quantity  int       `json:"quantity"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// HoldResult has either the hold that was taken or, when free stock is short,
+// the shortage with Available set to the free quantity.
+type HoldResult struct {
+	Hold     *Hold
+	Depleted *DepletedLine
+}
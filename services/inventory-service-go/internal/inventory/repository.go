@@ -22,12 +22,15 @@ type Repository interface {
 	Get(ctx context.Context, productID string) (StockItem, error)
 	SetAvailable(ctx context.Context, productID string, available int) error
 	Reserve(ctx context.Context, orderID string, lines []Line) (ReserveResult, error)
+	HoldRepository
 }
 
 type TransactionalRepository interface {
 	Repository
 	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
 	ReserveWithTx(ctx context.Context, tx pgx.Tx, orderID string, lines []Line) (ReserveResult, error)
+	ReleaseAllWithTx(ctx context.Context, tx pgx.Tx, holderID string) error
+	ReleaseOrderWithTx(ctx context.Context, tx pgx.Tx, orderID string, lines []Line) (bool, error)
 }
 
 type PostgresRepository struct {
@@ -40,13 +43,20 @@ func NewPostgresRepository(pool DBPool) *PostgresRepository {
 
 func (r *PostgresRepository) Get(ctx context.Context, productID string) (StockItem, error) {
 	var item StockItem
-	row := r.pool.QueryRow(ctx, `SELECT product_id, available FROM inventory_stock WHERE product_id=$1`, productID)
-	if err := row.Scan(&item.ProductID, &item.Available); err != nil {
+	row := r.pool.QueryRow(ctx, `
+		SELECT s.product_id, s.available,
+			COALESCE((SELECT SUM(h.quantity) FROM holds h WHERE h.product_id = s.product_id AND h.expires_at > now()), 0)
+		FROM inventory_stock s
+		WHERE s.product_id=$1
+	`, productID)
+	if err := row.Scan(&item.ProductID, &item.Available, &item.Held); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return StockItem{}, ErrNotFound
 		}
 		return StockItem{}, err
 	}
+	// Stock adjusted below what is held leaves nothing free rather than a negative.
+	item.Free = max(item.Available-item.Held, 0)
 	return item, nil
 }
 
@@ -153,3 +163,32 @@ func (r *PostgresRepository) reserveWithTx(ctx context.Context, tx pgx.Tx, order
 
 	return res, nil
 }
+
+// ReleaseOrderWithTx puts the stock reserved for a cancelled order back. An
+// order is released once: it reports false, changing nothing, when the order
+// was released before.
+func (r *PostgresRepository) ReleaseOrderWithTx(ctx context.Context, tx pgx.Tx, orderID string, lines []Line) (bool, error) {
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO order_releases(order_id)
+		VALUES($1)
+		ON CONFLICT (order_id) DO NOTHING
+	`, orderID)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	for _, line := range lines {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO inventory_stock(product_id, available)
+			VALUES($1, $2)
+			ON CONFLICT (product_id) DO UPDATE SET available = inventory_stock.available + EXCLUDED.available, updated_at=now()
+		`, line.ProductID, line.Quantity)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -19,14 +19,16 @@ func TestGet_Success(t *testing.T) {
 
 	repo := NewPostgresRepository(mock)
 
-	mock.ExpectQuery(regexp.QuoteMeta("SELECT product_id, available FROM inventory_stock WHERE product_id=$1")).
+	mock.ExpectQuery(regexp.QuoteMeta("FROM inventory_stock s WHERE s.product_id=$1")).
 		WithArgs("prod-1").
-		WillReturnRows(mock.NewRows([]string{"product_id", "available"}).AddRow("prod-1", 10))
+		WillReturnRows(mock.NewRows([]string{"product_id", "available", "held"}).AddRow("prod-1", 10, 4))
 
 	item, err := repo.Get(context.Background(), "prod-1")
 	require.NoError(t, err)
 	assert.Equal(t, "prod-1", item.ProductID)
 	assert.Equal(t, 10, item.Available)
+	assert.Equal(t, 4, item.Held)
+	assert.Equal(t, 6, item.Free)
 
 	require.NoError(t, mock.ExpectationsWereMet())
 }
@@ -38,7 +40,7 @@ func TestGet_NotFound(t *testing.T) {
 
 	repo := NewPostgresRepository(mock)
 
-	mock.ExpectQuery(regexp.QuoteMeta("SELECT product_id, available FROM inventory_stock WHERE product_id=$1")).
+	mock.ExpectQuery(regexp.QuoteMeta("FROM inventory_stock s WHERE s.product_id=$1")).
 		WithArgs("prod-missing").
 		WillReturnError(pgx.ErrNoRows)
 
@@ -161,3 +163,55 @@ func TestReserve_DBError(t *testing.T) {
 
 	require.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestReleaseOrderWithTx(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	repo := NewPostgresRepository(mock)
+
+	mock.ExpectBeginTx(pgx.TxOptions{})
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO order_releases(order_id)")).
+		WithArgs("order-1").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec(regexp.QuoteMeta("SET available = inventory_stock.available + EXCLUDED.available")).
+		WithArgs("p1", 2).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec(regexp.QuoteMeta("SET available = inventory_stock.available + EXCLUDED.available")).
+		WithArgs("p2", 1).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	tx, err := repo.BeginTx(context.Background(), pgx.TxOptions{})
+	require.NoError(t, err)
+	released, err := repo.ReleaseOrderWithTx(context.Background(), tx, "order-1", []Line{{ProductID: "p1", Quantity: 2}, {ProductID: "p2", Quantity: 1}})
+	require.NoError(t, err)
+	assert.True(t, released)
+	require.NoError(t, tx.Commit(context.Background()))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReleaseOrderWithTx_AlreadyReleased(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	repo := NewPostgresRepository(mock)
+
+	mock.ExpectBeginTx(pgx.TxOptions{})
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO order_releases(order_id)")).
+		WithArgs("order-1").
+		WillReturnResult(pgxmock.NewResult("INSERT", 0))
+	mock.ExpectRollback()
+
+	tx, err := repo.BeginTx(context.Background(), pgx.TxOptions{})
+	require.NoError(t, err)
+	released, err := repo.ReleaseOrderWithTx(context.Background(), tx, "order-1", []Line{{ProductID: "p1", Quantity: 2}})
+	require.NoError(t, err)
+	assert.False(t, released)
+	require.NoError(t, tx.Rollback(context.Background()))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
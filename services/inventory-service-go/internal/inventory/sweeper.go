@@ -0,0 +1,28 @@
+package inventory
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// SweepHolds deletes expired holds every interval until ctx is cancelled.
+func SweepHolds(ctx context.Context, holds HoldRepository, interval time.Duration, logger *log.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := holds.DeleteExpired(ctx)
+			if err != nil {
+				logger.Printf("hold sweep: %v", err)
+				continue
+			}
+			if n > 0 {
+				logger.Printf("hold sweep: removed %d expired holds", n)
+			}
+		}
+	}
+}
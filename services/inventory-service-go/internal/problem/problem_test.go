@@ -0,0 +1,65 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/inventory/p1", nil)
+	r.Header.Set(HeaderCorrelationID, "cid-1")
+	w := httptest.NewRecorder()
+
+	Error(w, r, http.StatusNotFound, "product_not_found", "product not found")
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != ContentType {
+		t.Fatalf("expected %s, got %q", ContentType, ct)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := map[string]any{
+		"type":          TypeBase + "product_not_found",
+		"title":         "Not Found",
+		"status":        float64(404),
+		"detail":        "product not found",
+		"instance":      "/api/inventory/p1",
+		"code":          "product_not_found",
+		"correlationId": "cid-1",
+		"error":         "product not found",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("%s: expected %v, got %v", k, v, got[k])
+		}
+	}
+}
+
+func TestWriteExtensions(t *testing.T) {
+	type withLimit struct {
+		Details
+		Limit int `json:"limit"`
+	}
+	w := httptest.NewRecorder()
+
+	Write(w, httptest.NewRequest(http.MethodPost, "/x", nil), &withLimit{Details: Invalid("too many", FieldError{Field: "items", Code: FieldInvalid, Message: "too many"}), Limit: 3})
+
+	var got struct {
+		Code   string       `json:"code"`
+		Status int          `json:"status"`
+		Errors []FieldError `json:"errors"`
+		Limit  int          `json:"limit"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Code != CodeValidationFailed || got.Status != http.StatusBadRequest || got.Limit != 3 || len(got.Errors) != 1 || got.Errors[0].Field != "items" {
+		t.Fatalf("unexpected body %s", w.Body.String())
+	}
+}
@@ -14,6 +14,7 @@ import (
 	eventserver "github.com/andreasstove999/ecommerce-system/order-service-go/internal/events"
 	httpserver "github.com/andreasstove999/ecommerce-system/order-service-go/internal/http"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/order"
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/outbox"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/sequence"
 )
 
@@ -35,6 +36,7 @@ func main() {
 	orderRepo := order.NewRepository(database)
 	dedupRepo := dedup.NewRepository(database)
 	seqRepo := sequence.NewRepository(database)
+	outboxRepo := outbox.NewRepository(database)
 	defer database.Close()
 
 	// RabbitMQ
@@ -45,26 +47,39 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Publisher (needed by some handlers)
-	pub, err := eventserver.NewPublisher(rabbitConn, seqRepo, publishEnveloped)
+	// Publisher (needed by some handlers) writes events to the outbox in the
+	// transaction of the change they announce; the relay sends them on.
+	pub := eventserver.NewPublisher(outboxRepo, seqRepo, publishEnveloped)
+
+	sender, err := eventserver.NewOutboxSender(rabbitConn)
 	if err != nil {
-		logger.Fatalf("create publisher: %v", err)
+		logger.Fatalf("create outbox sender: %v", err)
+	}
+	defer sender.Close()
+	go outbox.NewRelay(outboxRepo, sender, outbox.DefaultRelayConfig(), logger).Run(ctx)
+
+	// Enveloped carts publish CartCheckedOut v2 to v5 next to v1; consume only
+	// v5, which carries the coupon discounts, the tax and the shipping
+	// details, so each checkout creates a single order.
+	cartCheckedOutRoutingKey := eventserver.RoutingCartCheckedOut
+	if consumeEnveloped {
+		cartCheckedOutRoutingKey = eventserver.RoutingCartCheckedOutV5
 	}
-	defer pub.Close()
 
 	// Create and configure consumer with all handlers
         consumer := eventserver.NewConsumer(rabbitConn, logger)
-        consumer.Register(eventserver.RoutingCartCheckedOut, eventserver.CartCheckedOutHandler(database, orderRepo, dedupRepo, pub, logger, consumeEnveloped))
+        consumer.Register(cartCheckedOutRoutingKey, eventserver.CartCheckedOutHandler(database, orderRepo, dedupRepo, pub, logger, consumeEnveloped))
         consumer.Register(eventserver.RoutingPaymentSucceeded, eventserver.PaymentSucceededHandler(orderRepo, pub, logger))
         consumer.Register(eventserver.RoutingPaymentFailed, eventserver.PaymentFailedHandler(orderRepo, logger))
         consumer.Register(eventserver.RoutingStockReserved, eventserver.StockReservedHandler(orderRepo, pub, logger))
+        consumer.Register(eventserver.RoutingStockDepleted, eventserver.StockDepletedHandler(orderRepo, pub, logger, consumeEnveloped))
 
 	if err := consumer.Start(ctx); err != nil {
 		logger.Fatalf("start consumer: %v", err)
 	}
 
 	// HTTP
-	mux := httpserver.NewRouter(orderRepo)
+	mux := httpserver.NewRouter(orderRepo, pub, logger)
 
 	srv := &http.Server{
 		Addr:         ":" + port,
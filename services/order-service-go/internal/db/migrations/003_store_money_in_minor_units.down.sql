@@ -0,0 +1,16 @@
+-- Rollback: 003_store_money_in_minor_units
+-- Description: Restore decimal amount columns (currencies are dropped)
+
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS price NUMERIC(12,2);
+UPDATE order_items SET price = price_minor / 100.0;
+ALTER TABLE order_items
+  ALTER COLUMN price SET NOT NULL,
+  DROP COLUMN IF EXISTS price_minor,
+  DROP COLUMN IF EXISTS currency;
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS total_amount NUMERIC(12,2);
+UPDATE orders SET total_amount = total_minor / 100.0;
+ALTER TABLE orders
+  ALTER COLUMN total_amount SET NOT NULL,
+  DROP COLUMN IF EXISTS total_minor,
+  DROP COLUMN IF EXISTS currency;
@@ -0,0 +1,25 @@
+-- Migration: 003_store_money_in_minor_units
+-- Description: Store amounts as integer minor units with an ISO 4217 currency.
+-- Existing orders were all placed in the catalog default currency (USD).
+
+ALTER TABLE orders
+  ADD COLUMN IF NOT EXISTS total_minor BIGINT,
+  ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'USD';
+
+UPDATE orders SET total_minor = ROUND(total_amount * 100) WHERE total_minor IS NULL;
+
+ALTER TABLE orders
+  ALTER COLUMN total_minor SET NOT NULL,
+  ALTER COLUMN currency DROP DEFAULT,
+  DROP COLUMN IF EXISTS total_amount;
+
+ALTER TABLE order_items
+  ADD COLUMN IF NOT EXISTS price_minor BIGINT,
+  ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'USD';
+
+UPDATE order_items SET price_minor = ROUND(price * 100) WHERE price_minor IS NULL;
+
+ALTER TABLE order_items
+  ALTER COLUMN price_minor SET NOT NULL,
+  ALTER COLUMN currency DROP DEFAULT,
+  DROP COLUMN IF EXISTS price;
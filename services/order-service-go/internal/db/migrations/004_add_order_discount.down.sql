@@ -0,0 +1,4 @@
+-- Rollback: 004_add_order_discount
+-- Description: Drop the order discount column
+
+ALTER TABLE orders DROP COLUMN IF EXISTS discount_minor;
@@ -0,0 +1,6 @@
+-- Migration: 004_add_order_discount
+-- Description: Record what coupons took off an order. total_minor is the
+-- discounted total; discount_minor is in the order's currency.
+
+ALTER TABLE orders
+  ADD COLUMN IF NOT EXISTS discount_minor BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+-- Rollback: 005_create_order_tax_lines
+-- Description: Drop the order tax lines
+
+DROP TABLE IF EXISTS order_tax_lines;
@@ -0,0 +1,20 @@
+-- Migration: 005_create_order_tax_lines
+-- Description: Store the tax on each order line as it was calculated at
+-- checkout, for invoices and tax reporting. taxable_minor is the line's net
+-- amount after discounts; inclusive lines had the tax in their price.
+
+CREATE TABLE IF NOT EXISTS order_tax_lines (
+  id UUID PRIMARY KEY,
+  order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+  product_id TEXT NOT NULL,
+  tax_class TEXT NOT NULL,
+  country TEXT NOT NULL,
+  region TEXT NOT NULL DEFAULT '',
+  rate_bp INT NOT NULL,
+  inclusive BOOLEAN NOT NULL,
+  taxable_minor BIGINT NOT NULL,
+  tax_minor BIGINT NOT NULL,
+  currency TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_tax_lines_order_id ON order_tax_lines(order_id);
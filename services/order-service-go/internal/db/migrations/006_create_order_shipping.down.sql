@@ -0,0 +1,4 @@
+-- Rollback: 006_create_order_shipping
+-- Description: Drop the order shipping details
+
+DROP TABLE IF EXISTS order_shipping;
@@ -0,0 +1,15 @@
+-- Migration: 006_create_order_shipping
+-- Description: Keep the shipping address and method chosen at checkout, so
+-- shipping does not have to guess where an order goes. Orders checked out
+-- without shipping details have no row.
+
+CREATE TABLE IF NOT EXISTS order_shipping (
+  order_id UUID PRIMARY KEY REFERENCES orders(id) ON DELETE CASCADE,
+  method TEXT NOT NULL,
+  line1 TEXT NOT NULL,
+  line2 TEXT NOT NULL DEFAULT '',
+  city TEXT NOT NULL,
+  state TEXT NOT NULL DEFAULT '',
+  postal_code TEXT NOT NULL,
+  country TEXT NOT NULL
+);
@@ -0,0 +1,6 @@
+-- Rollback: 007_add_order_cancellation
+-- Description: Drop the order cancellation columns
+
+ALTER TABLE orders
+  DROP COLUMN IF EXISTS cancelled_at,
+  DROP COLUMN IF EXISTS cancel_reason;
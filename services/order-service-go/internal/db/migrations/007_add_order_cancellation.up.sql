@@ -0,0 +1,6 @@
+-- Migration: 007_add_order_cancellation
+-- Description: Record why and when an order was cancelled.
+
+ALTER TABLE orders
+  ADD COLUMN IF NOT EXISTS cancel_reason TEXT NULL,
+  ADD COLUMN IF NOT EXISTS cancelled_at  TIMESTAMPTZ NULL;
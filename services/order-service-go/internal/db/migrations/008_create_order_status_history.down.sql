@@ -0,0 +1,4 @@
+-- Rollback: 008_create_order_status_history
+-- Description: Drop the order status history
+
+DROP TABLE IF EXISTS order_status_history;
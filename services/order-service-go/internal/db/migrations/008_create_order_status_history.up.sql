@@ -0,0 +1,16 @@
+-- Migration: 008_create_order_status_history
+-- Description: Record every order status change with its reason and the
+-- event that triggered it. event_id is NULL for changes not caused by an
+-- event, or by events that carry no ID.
+
+CREATE TABLE IF NOT EXISTS order_status_history (
+  id UUID PRIMARY KEY,
+  order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+  from_status TEXT NOT NULL,
+  to_status TEXT NOT NULL,
+  reason TEXT NULL,
+  event_id TEXT NULL,
+  changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_status_history_order_id ON order_status_history(order_id, changed_at);
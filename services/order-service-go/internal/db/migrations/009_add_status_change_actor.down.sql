@@ -0,0 +1,8 @@
+-- Rollback: 009_add_status_change_actor
+-- Description: Drop the status change actor columns
+
+ALTER TABLE order_status_history
+  DROP COLUMN IF EXISTS actor;
+
+ALTER TABLE orders
+  DROP COLUMN IF EXISTS cancelled_by;
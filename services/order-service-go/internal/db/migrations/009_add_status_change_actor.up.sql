@@ -0,0 +1,10 @@
+-- Migration: 009_add_status_change_actor
+-- Description: Record who changed an order's status. actor is the user ID
+-- for changes the customer asked for and NULL for changes the service made
+-- on an event.
+
+ALTER TABLE orders
+  ADD COLUMN IF NOT EXISTS cancelled_by TEXT NULL;
+
+ALTER TABLE order_status_history
+  ADD COLUMN IF NOT EXISTS actor TEXT NULL;
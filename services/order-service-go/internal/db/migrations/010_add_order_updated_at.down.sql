@@ -0,0 +1,5 @@
+-- Rollback: 010_add_order_updated_at
+-- Description: Drop the order updated_at column
+
+ALTER TABLE orders
+  DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,13 @@
+-- Migration: 010_add_order_updated_at
+-- Description: Track when an order last changed. Existing orders take the
+-- time of their last status change, or their creation time.
+
+ALTER TABLE orders
+  ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+UPDATE orders o
+SET updated_at = COALESCE(
+  (SELECT max(h.changed_at) FROM order_status_history h WHERE h.order_id = o.id),
+  o.cancelled_at,
+  o.created_at
+);
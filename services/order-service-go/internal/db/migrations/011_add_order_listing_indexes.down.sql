@@ -0,0 +1,6 @@
+-- Rollback: 011_add_order_listing_indexes
+-- Description: Drop the order listing indexes
+
+DROP INDEX IF EXISTS idx_order_items_order_id;
+DROP INDEX IF EXISTS idx_orders_user_status_created;
+DROP INDEX IF EXISTS idx_orders_user_created;
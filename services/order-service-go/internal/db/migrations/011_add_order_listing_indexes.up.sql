@@ -0,0 +1,7 @@
+-- Migration: 011_add_order_listing_indexes
+-- Description: Index the paginated order listing. Orders are listed per user,
+-- newest first by (created_at, id); items are joined per order.
+
+CREATE INDEX IF NOT EXISTS idx_orders_user_created ON orders(user_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_user_status_created ON orders(user_id, status, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_order_items_order_id ON order_items(order_id);
@@ -0,0 +1,4 @@
+-- Rollback: 012_create_outbox
+-- Description: Drop the outbox
+
+DROP TABLE IF EXISTS outbox;
@@ -0,0 +1,19 @@
+-- Migration: 012_create_outbox
+-- Description: Outbox of events to publish. Events are written in the
+-- transaction of the change they announce and sent by the outbox relay, in
+-- order within each partition (the order ID).
+
+CREATE TABLE IF NOT EXISTS outbox (
+  id              BIGSERIAL PRIMARY KEY,
+  partition_key   TEXT NOT NULL,
+  routing_key     TEXT NOT NULL,
+  payload         BYTEA NOT NULL,
+  attempts        INT NOT NULL DEFAULT 0,
+  last_error      TEXT NULL,
+  created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+  next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  published_at    TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(partition_key, id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_published_at ON outbox(published_at) WHERE published_at IS NOT NULL;
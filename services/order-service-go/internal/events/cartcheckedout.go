@@ -4,11 +4,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
+
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/money"
 )
 
 const (
-	cartCheckedOutEventName    = "CartCheckedOut"
-	cartCheckedOutEventVersion = 1
+	cartCheckedOutEventName      = "CartCheckedOut"
+	cartCheckedOutEventVersion   = 1
+	cartCheckedOutV2EventVersion = 2
+	cartCheckedOutV3EventVersion = 3
+	cartCheckedOutV4EventVersion = 4
+	cartCheckedOutV5EventVersion = 5
 )
 
 // CartCheckedOutPayload represents the v1 payload schema.
@@ -23,38 +29,300 @@ type CartCheckedOutPayload struct {
 // CartCheckedOutEnvelope is the enveloped event structure.
 type CartCheckedOutEnvelope = EventEnvelope[CartCheckedOutPayload]
 
-// parseCartCheckedOut parses an incoming CartCheckedOut message.
-// If allowEnveloped is true it will first try the v1 envelope format and
-// fall back to the legacy bare payload if unmarshalling fails.
-func parseCartCheckedOut(body []byte, allowEnveloped bool) (CartCheckedOutPayload, *CartCheckedOutEnvelope, error) {
+// CartItemV2 is a cart line with its unit price as Money.
+type CartItemV2 struct {
+	ProductID string      `json:"productId"`
+	Quantity  int         `json:"quantity"`
+	Price     money.Money `json:"price"`
+}
+
+// CartCheckedOutV2Payload represents the v2 payload schema, which carries
+// amounts as integer minor units with a currency.
+type CartCheckedOutV2Payload struct {
+	CartID      string       `json:"cartId"`
+	UserID      string       `json:"userId"`
+	Items       []CartItemV2 `json:"items"`
+	TotalAmount money.Money  `json:"totalAmount"`
+	Timestamp   time.Time    `json:"timestamp"`
+}
+
+// CartAdjustment is a coupon discount applied to the cart. Amount is negative.
+type CartAdjustment struct {
+	Code        string      `json:"code"`
+	PromotionID string      `json:"promotionId"`
+	Description string      `json:"description,omitempty"`
+	Amount      money.Money `json:"amount"`
+}
+
+// CartCheckedOutV3Payload represents the v3 payload schema, which adds the
+// subtotal and coupon adjustments to v2. TotalAmount is the discounted total.
+type CartCheckedOutV3Payload struct {
+	CartID      string           `json:"cartId"`
+	UserID      string           `json:"userId"`
+	Items       []CartItemV2     `json:"items"`
+	Subtotal    money.Money      `json:"subtotal"`
+	Adjustments []CartAdjustment `json:"adjustments"`
+	TotalAmount money.Money      `json:"totalAmount"`
+	Timestamp   time.Time        `json:"timestamp"`
+}
+
+// CartTaxLine is the tax on one cart line. TaxableAmount is the line's net
+// amount after discounts.
+type CartTaxLine struct {
+	ProductID       string      `json:"productId"`
+	TaxClass        string      `json:"taxClass"`
+	RateBasisPoints int         `json:"rateBasisPoints"`
+	Inclusive       bool        `json:"inclusive"`
+	TaxableAmount   money.Money `json:"taxableAmount"`
+	TaxAmount       money.Money `json:"taxAmount"`
+}
+
+// CartTax is the tax breakdown of a checked-out cart.
+type CartTax struct {
+	Country      string        `json:"country"`
+	Region       string        `json:"region,omitempty"`
+	Lines        []CartTaxLine `json:"lines"`
+	TotalTax     money.Money   `json:"totalTax"`
+	ExclusiveTax money.Money   `json:"exclusiveTax"`
+}
+
+// CartCheckedOutV4Payload represents the v4 payload schema, which adds the
+// tax breakdown to v3. Tax is nil for an untaxed cart; TotalAmount includes
+// the exclusive tax.
+type CartCheckedOutV4Payload struct {
+	CartID      string           `json:"cartId"`
+	UserID      string           `json:"userId"`
+	Items       []CartItemV2     `json:"items"`
+	Subtotal    money.Money      `json:"subtotal"`
+	Adjustments []CartAdjustment `json:"adjustments"`
+	Tax         *CartTax         `json:"tax,omitempty"`
+	TotalAmount money.Money      `json:"totalAmount"`
+	Timestamp   time.Time        `json:"timestamp"`
+}
+
+// CartAddress is the postal address a checked-out cart is delivered to.
+type CartAddress struct {
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city"`
+	State      string `json:"state,omitempty"`
+	PostalCode string `json:"postalCode"`
+	Country    string `json:"country"`
+}
+
+// CartShipping is the delivery chosen at checkout.
+type CartShipping struct {
+	Address CartAddress `json:"address"`
+	Method  string      `json:"method"`
+}
+
+// CartCheckedOutV5Payload represents the v5 payload schema, which adds the
+// shipping details to v4. Shipping is nil when the checkout carried none.
+type CartCheckedOutV5Payload struct {
+	CartID      string           `json:"cartId"`
+	UserID      string           `json:"userId"`
+	Items       []CartItemV2     `json:"items"`
+	Subtotal    money.Money      `json:"subtotal"`
+	Adjustments []CartAdjustment `json:"adjustments"`
+	Tax         *CartTax         `json:"tax,omitempty"`
+	Shipping    *CartShipping    `json:"shipping,omitempty"`
+	TotalAmount money.Money      `json:"totalAmount"`
+	Timestamp   time.Time        `json:"timestamp"`
+}
+
+// exclusiveTax is the tax added on top of the prices.
+func (p CartCheckedOutV5Payload) exclusiveTax() int64 {
+	if p.Tax == nil {
+		return 0
+	}
+	return p.Tax.ExclusiveTax.Amount
+}
+
+// Discount is what the adjustments took off the subtotal.
+func (p CartCheckedOutV5Payload) Discount() money.Money {
+	return money.New(p.Subtotal.Amount-(p.TotalAmount.Amount-p.exclusiveTax()), p.TotalAmount.Currency)
+}
+
+// parseCartCheckedOut parses an incoming CartCheckedOut message into the v5
+// payload. If allowEnveloped is true it will first try the envelope format
+// (v5, v4 which carries no shipping details, v3 which carries no tax either,
+// or v2 and v1 which carry no adjustments either; v1 amounts are converted in
+// the default currency) and fall back to the legacy bare payload if
+// unmarshalling fails. The returned envelope carries the undecoded payload.
+func parseCartCheckedOut(body []byte, allowEnveloped bool) (CartCheckedOutV5Payload, *EventEnvelope[json.RawMessage], error) {
 	if allowEnveloped {
-		var env CartCheckedOutEnvelope
+		var env EventEnvelope[json.RawMessage]
 		if err := json.Unmarshal(body, &env); err == nil && env.EventName != "" {
-			if err := env.Validate(cartCheckedOutEventName, cartCheckedOutEventVersion); err != nil {
-				return CartCheckedOutPayload{}, nil, fmt.Errorf("invalid envelope: %w", err)
+			var payload CartCheckedOutV5Payload
+			switch env.EventVersion {
+			case cartCheckedOutV5EventVersion:
+				if err := env.Validate(cartCheckedOutEventName, cartCheckedOutV5EventVersion); err != nil {
+					return CartCheckedOutV5Payload{}, nil, fmt.Errorf("invalid envelope: %w", err)
+				}
+				if err := json.Unmarshal(env.Payload, &payload); err != nil {
+					return CartCheckedOutV5Payload{}, nil, fmt.Errorf("invalid payload: %w", err)
+				}
+			case cartCheckedOutV4EventVersion:
+				if err := env.Validate(cartCheckedOutEventName, cartCheckedOutV4EventVersion); err != nil {
+					return CartCheckedOutV5Payload{}, nil, fmt.Errorf("invalid envelope: %w", err)
+				}
+				var v4 CartCheckedOutV4Payload
+				if err := json.Unmarshal(env.Payload, &v4); err != nil {
+					return CartCheckedOutV5Payload{}, nil, fmt.Errorf("invalid payload: %w", err)
+				}
+				payload = cartCheckedOutFromV4(v4)
+			case cartCheckedOutV3EventVersion:
+				if err := env.Validate(cartCheckedOutEventName, cartCheckedOutV3EventVersion); err != nil {
+					return CartCheckedOutV5Payload{}, nil, fmt.Errorf("invalid envelope: %w", err)
+				}
+				var v3 CartCheckedOutV3Payload
+				if err := json.Unmarshal(env.Payload, &v3); err != nil {
+					return CartCheckedOutV5Payload{}, nil, fmt.Errorf("invalid payload: %w", err)
+				}
+				payload = cartCheckedOutFromV3(v3)
+			case cartCheckedOutV2EventVersion:
+				if err := env.Validate(cartCheckedOutEventName, cartCheckedOutV2EventVersion); err != nil {
+					return CartCheckedOutV5Payload{}, nil, fmt.Errorf("invalid envelope: %w", err)
+				}
+				var v2 CartCheckedOutV2Payload
+				if err := json.Unmarshal(env.Payload, &v2); err != nil {
+					return CartCheckedOutV5Payload{}, nil, fmt.Errorf("invalid payload: %w", err)
+				}
+				payload = cartCheckedOutFromV2(v2)
+			default:
+				if err := env.Validate(cartCheckedOutEventName, cartCheckedOutEventVersion); err != nil {
+					return CartCheckedOutV5Payload{}, nil, fmt.Errorf("invalid envelope: %w", err)
+				}
+				var v1 CartCheckedOutPayload
+				if err := json.Unmarshal(env.Payload, &v1); err != nil {
+					return CartCheckedOutV5Payload{}, nil, fmt.Errorf("invalid payload: %w", err)
+				}
+				payload = cartCheckedOutFromV1(v1)
 			}
-			if env.Payload.CartID == "" || env.Payload.UserID == "" {
-				return CartCheckedOutPayload{}, nil, fmt.Errorf("invalid payload: missing cartId or userId")
+			if err := validateCartCheckedOut(payload); err != nil {
+				return CartCheckedOutV5Payload{}, nil, err
 			}
-			return env.Payload, &env, nil
+			return payload, &env, nil
 		}
 	}
 
 	var legacy CartCheckedOut
 	if err := json.Unmarshal(body, &legacy); err != nil {
-		return CartCheckedOutPayload{}, nil, fmt.Errorf("unmarshal legacy CartCheckedOut: %w", err)
+		return CartCheckedOutV5Payload{}, nil, fmt.Errorf("unmarshal legacy CartCheckedOut: %w", err)
 	}
 
-	payload := CartCheckedOutPayload{
+	payload := cartCheckedOutFromV1(CartCheckedOutPayload{
 		CartID:      legacy.CartID,
 		UserID:      legacy.UserID,
 		Items:       legacy.Items,
 		TotalAmount: legacy.TotalAmount,
 		Timestamp:   legacy.Timestamp,
-	}
-	if payload.CartID == "" || payload.UserID == "" {
-		return CartCheckedOutPayload{}, nil, fmt.Errorf("invalid payload: missing cartId or userId")
+	})
+	if err := validateCartCheckedOut(payload); err != nil {
+		return CartCheckedOutV5Payload{}, nil, err
 	}
 
 	return payload, nil, nil
 }
+
+// cartCheckedOutFromV4 leaves the order without shipping details, since v4
+// carries none.
+func cartCheckedOutFromV4(v4 CartCheckedOutV4Payload) CartCheckedOutV5Payload {
+	return CartCheckedOutV5Payload{
+		CartID:      v4.CartID,
+		UserID:      v4.UserID,
+		Items:       v4.Items,
+		Subtotal:    v4.Subtotal,
+		Adjustments: v4.Adjustments,
+		Tax:         v4.Tax,
+		TotalAmount: v4.TotalAmount,
+		Timestamp:   v4.Timestamp,
+	}
+}
+
+// cartCheckedOutFromV3 leaves the order untaxed, since v3 carries no tax.
+func cartCheckedOutFromV3(v3 CartCheckedOutV3Payload) CartCheckedOutV5Payload {
+	return CartCheckedOutV5Payload{
+		CartID:      v3.CartID,
+		UserID:      v3.UserID,
+		Items:       v3.Items,
+		Subtotal:    v3.Subtotal,
+		Adjustments: v3.Adjustments,
+		TotalAmount: v3.TotalAmount,
+		Timestamp:   v3.Timestamp,
+	}
+}
+
+// cartCheckedOutFromV2 treats the v2 total as undiscounted, since v2 carries
+// no adjustments.
+func cartCheckedOutFromV2(v2 CartCheckedOutV2Payload) CartCheckedOutV5Payload {
+	return CartCheckedOutV5Payload{
+		CartID:      v2.CartID,
+		UserID:      v2.UserID,
+		Items:       v2.Items,
+		Subtotal:    v2.TotalAmount,
+		TotalAmount: v2.TotalAmount,
+		Timestamp:   v2.Timestamp,
+	}
+}
+
+// cartCheckedOutFromV1 converts v1 and legacy amounts, which never carried a
+// currency, to Money in the default currency.
+func cartCheckedOutFromV1(v1 CartCheckedOutPayload) CartCheckedOutV5Payload {
+	payload := CartCheckedOutV2Payload{
+		CartID:      v1.CartID,
+		UserID:      v1.UserID,
+		TotalAmount: money.FromFloat(v1.TotalAmount, money.DefaultCurrency),
+		Timestamp:   v1.Timestamp,
+	}
+	for _, it := range v1.Items {
+		payload.Items = append(payload.Items, CartItemV2{
+			ProductID: it.ProductID,
+			Quantity:  it.Quantity,
+			Price:     money.FromFloat(it.Price, money.DefaultCurrency),
+		})
+	}
+	return cartCheckedOutFromV2(payload)
+}
+
+func validateCartCheckedOut(p CartCheckedOutV5Payload) error {
+	if p.CartID == "" || p.UserID == "" {
+		return fmt.Errorf("invalid payload: missing cartId or userId")
+	}
+	if !money.ValidCurrency(p.TotalAmount.Currency) {
+		return fmt.Errorf("invalid payload: invalid currency %q", p.TotalAmount.Currency)
+	}
+	if p.Subtotal.Currency != p.TotalAmount.Currency {
+		return fmt.Errorf("invalid payload: %w", money.ErrCurrencyMismatch)
+	}
+	for _, it := range p.Items {
+		if it.Price.Currency != p.TotalAmount.Currency {
+			return fmt.Errorf("invalid payload: %w", money.ErrCurrencyMismatch)
+		}
+	}
+	for _, adj := range p.Adjustments {
+		if adj.Amount.Currency != p.TotalAmount.Currency {
+			return fmt.Errorf("invalid payload: %w", money.ErrCurrencyMismatch)
+		}
+	}
+	if p.Tax != nil {
+		if p.Tax.TotalTax.Currency != p.TotalAmount.Currency || p.Tax.ExclusiveTax.Currency != p.TotalAmount.Currency {
+			return fmt.Errorf("invalid payload: %w", money.ErrCurrencyMismatch)
+		}
+		for _, tl := range p.Tax.Lines {
+			if tl.TaxableAmount.Currency != p.TotalAmount.Currency || tl.TaxAmount.Currency != p.TotalAmount.Currency {
+				return fmt.Errorf("invalid payload: %w", money.ErrCurrencyMismatch)
+			}
+		}
+	}
+	if sh := p.Shipping; sh != nil {
+		a := sh.Address
+		if sh.Method == "" || a.Line1 == "" || a.City == "" || a.PostalCode == "" || a.Country == "" {
+			return fmt.Errorf("invalid payload: incomplete shipping details")
+		}
+	}
+	if p.TotalAmount.Amount-p.exclusiveTax() > p.Subtotal.Amount {
+		return fmt.Errorf("invalid payload: total exceeds subtotal")
+	}
+	return nil
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/dedup"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/order"
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/outbox"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/sequence"
 )
 
@@ -220,35 +221,30 @@ func (c *Consumer) publishToDLQ(ctx context.Context, originalQueue string, body
 }
 
 // StartCartCheckedOutConsumer starts a consumer that listens for CartCheckedOut
-// events and persists orders using the provided repository.
-// It returns the consumer, a cleanup function for the publisher, and any error encountered.
+// events and persists orders using the provided repository. The OrderCreated
+// events it writes go to outboxRepo; sending them is left to the relay the
+// caller runs.
+// It returns the consumer and any error encountered.
 func StartCartCheckedOutConsumer(
 	ctx context.Context,
 	conn *amqp.Connection,
 	db *sql.DB,
 	repo order.Repository,
 	dedupRepo dedup.Repository,
+	outboxRepo outbox.Repository,
 	seqRepo sequence.Repository,
 	logger *log.Logger,
 	consumeEnveloped bool,
 	publishEnveloped bool,
-) (*Consumer, func(), error) {
-	pub, err := NewPublisher(conn, seqRepo, publishEnveloped)
-	if err != nil {
-		return nil, nil, fmt.Errorf("create publisher: %w", err)
-	}
+) (*Consumer, error) {
+	pub := NewPublisher(outboxRepo, seqRepo, publishEnveloped)
 
 	consumer := NewConsumer(conn, logger)
 	consumer.Register(RoutingCartCheckedOut, CartCheckedOutHandler(db, repo, dedupRepo, pub, logger, consumeEnveloped))
 
 	if err := consumer.Start(ctx); err != nil {
-		_ = pub.Close()
-		return nil, nil, fmt.Errorf("start consumer: %w", err)
-	}
-
-	cleanup := func() {
-		_ = pub.Close()
+		return nil, fmt.Errorf("start consumer: %w", err)
 	}
 
-	return consumer, cleanup, nil
+	return consumer, nil
 }
@@ -4,29 +4,38 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 
 	"github.com/google/uuid"
 
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/dedup"
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/money"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/order"
 )
 
 // Routing keys as constants for handler registration
 const (
 	RoutingCartCheckedOut   = CartCheckedOutRoutingKey
+	RoutingCartCheckedOutV2 = CartCheckedOutV2RoutingKey
+	RoutingCartCheckedOutV3 = CartCheckedOutV3RoutingKey
+	RoutingCartCheckedOutV4 = CartCheckedOutV4RoutingKey
+	RoutingCartCheckedOutV5 = CartCheckedOutV5RoutingKey
 	RoutingPaymentSucceeded = PaymentSucceededRoutingKey
 	RoutingPaymentFailed    = PaymentFailedRoutingKey
 	RoutingStockReserved    = StockReservedRoutingKey
+	RoutingStockDepleted    = StockDepletedRoutingKey
 
 	consumerNameCartCheckedOut = "order-service.cart-checkedout"
 )
 
 // OrderPublisher defines the subset of publisher methods used by handlers.
+// Events are written in tx, the transaction of the change they announce.
 type OrderPublisher interface {
-	PublishOrderCreated(ctx context.Context, o *order.Order, meta EnvelopeMetadata) error
-	PublishOrderCompleted(ctx context.Context, orderID, userID string, meta EnvelopeMetadata) error
+	PublishOrderCreated(ctx context.Context, tx *sql.Tx, o *order.Order, meta EnvelopeMetadata) error
+	PublishOrderCompleted(ctx context.Context, tx *sql.Tx, orderID, userID string, meta EnvelopeMetadata) error
+	PublishOrderCancelled(ctx context.Context, tx *sql.Tx, c *order.Cancellation, reserved []StockLine, meta EnvelopeMetadata) error
 }
 
 // CartCheckedOutHandler returns a handler for cart.checkedout events.
@@ -72,6 +81,7 @@ func CartCheckedOutHandler(
 			CartID:      payload.CartID,
 			UserID:      payload.UserID,
 			TotalAmount: payload.TotalAmount,
+			Discount:    payload.Discount(),
 			CreatedAt:   payload.Timestamp,
 		}
 
@@ -82,35 +92,63 @@ func CartCheckedOutHandler(
 				Price:     it.Price,
 			})
 		}
-
-		if envelope != nil && envelope.Sequence != nil {
-			tx, err := db.BeginTx(ctx, nil)
-			if err != nil {
-				return fmt.Errorf("begin tx: %w", err)
+		o.Tax = money.Zero(payload.TotalAmount.Currency)
+		o.TaxLines = []order.TaxLine{}
+		if payload.Tax != nil {
+			o.Tax = payload.Tax.TotalTax
+			for _, tl := range payload.Tax.Lines {
+				o.TaxLines = append(o.TaxLines, order.TaxLine{
+					ProductID:       tl.ProductID,
+					TaxClass:        tl.TaxClass,
+					Country:         payload.Tax.Country,
+					Region:          payload.Tax.Region,
+					RateBasisPoints: tl.RateBasisPoints,
+					Inclusive:       tl.Inclusive,
+					Taxable:         tl.TaxableAmount,
+					Amount:          tl.TaxAmount,
+				})
 			}
-			defer tx.Rollback()
+		}
 
-			if err := repo.CreateWithTx(ctx, tx, o); err != nil {
-				return fmt.Errorf("create order: %w", err)
+		if sh := payload.Shipping; sh != nil {
+			o.Shipping = &order.Shipping{
+				Address: order.Address{
+					Line1:      sh.Address.Line1,
+					Line2:      sh.Address.Line2,
+					City:       sh.Address.City,
+					State:      sh.Address.State,
+					PostalCode: sh.Address.PostalCode,
+					Country:    sh.Address.Country,
+				},
+				Method: sh.Method,
 			}
+		}
+
+		// The order, the dedup checkpoint and OrderCreated are committed
+		// together, so a redelivery skipped by dedup loses no event.
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("begin tx: %w", err)
+		}
+		defer tx.Rollback()
+
+		if err := repo.CreateWithTx(ctx, tx, o); err != nil {
+			return fmt.Errorf("create order: %w", err)
+		}
+		if envelope != nil && envelope.Sequence != nil {
 			if err := dedupRepo.UpsertLastSequence(ctx, tx, consumerNameCartCheckedOut, envelope.PartitionKey, *envelope.Sequence); err != nil {
 				return fmt.Errorf("update dedup checkpoint: %w", err)
 			}
-			if err := tx.Commit(); err != nil {
-				return fmt.Errorf("commit transaction: %w", err)
-			}
-		} else {
-			if err := repo.Create(ctx, o); err != nil {
-				return fmt.Errorf("create order: %w", err)
-			}
 		}
-
-		if err := pub.PublishOrderCreated(ctx, o, EnvelopeMetadata{
+		if err := pub.PublishOrderCreated(ctx, tx, o, EnvelopeMetadata{
 			CorrelationID: correlationID,
 			CausationID:   causationID,
 		}); err != nil {
 			return fmt.Errorf("publish OrderCreated: %w", err)
 		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit transaction: %w", err)
+		}
 
 		logger.Printf("created order %s for user %s from cart %s", o.ID, o.UserID, o.CartID)
 		return nil
@@ -133,11 +171,18 @@ func PaymentSucceededHandler(repo order.Repository, pub OrderPublisher, logger *
 
 		// If both payment + stock are ready -> complete and publish OrderCompleted
 		if state.ReadyToComplete {
-			if err := repo.MarkCompleted(ctx, ev.OrderID); err != nil {
-				return fmt.Errorf("mark completed: %w", err)
+			err := repo.MarkCompleted(ctx, ev.OrderID, order.Cause{Reason: "payment succeeded"}, func(tx *sql.Tx) error {
+				if err := pub.PublishOrderCompleted(ctx, tx, ev.OrderID, state.UserID, EnvelopeMetadata{}); err != nil {
+					return fmt.Errorf("publish OrderCompleted: %w", err)
+				}
+				return nil
+			})
+			if errors.Is(err, order.ErrIllegalTransition) {
+				logger.Printf("order %s not completed: %v", ev.OrderID, err)
+				return nil
 			}
-			if err := pub.PublishOrderCompleted(ctx, ev.OrderID, state.UserID, EnvelopeMetadata{}); err != nil {
-				return fmt.Errorf("publish OrderCompleted: %w", err)
+			if err != nil {
+				return fmt.Errorf("mark completed: %w", err)
 			}
 			logger.Printf("order %s completed (after payment success)", ev.OrderID)
 		}
@@ -155,7 +200,12 @@ func PaymentFailedHandler(repo order.Repository, logger *log.Logger) HandlerFunc
 			return fmt.Errorf("unmarshal PaymentFailed: %w", err)
 		}
 
-		if err := repo.MarkPaymentFailed(ctx, ev.OrderID, ev.Reason); err != nil {
+		err := repo.MarkPaymentFailed(ctx, ev.OrderID, order.Cause{Reason: ev.Reason})
+		if errors.Is(err, order.ErrIllegalTransition) {
+			logger.Printf("order %s payment failure ignored: %v", ev.OrderID, err)
+			return nil
+		}
+		if err != nil {
 			return fmt.Errorf("mark payment failed: %w", err)
 		}
 
@@ -179,11 +229,18 @@ func StockReservedHandler(repo order.Repository, pub OrderPublisher, logger *log
 		}
 
 		if state.ReadyToComplete {
-			if err := repo.MarkCompleted(ctx, ev.OrderID); err != nil {
-				return fmt.Errorf("mark completed: %w", err)
+			err := repo.MarkCompleted(ctx, ev.OrderID, order.Cause{Reason: "stock reserved"}, func(tx *sql.Tx) error {
+				if err := pub.PublishOrderCompleted(ctx, tx, ev.OrderID, state.UserID, EnvelopeMetadata{}); err != nil {
+					return fmt.Errorf("publish OrderCompleted: %w", err)
+				}
+				return nil
+			})
+			if errors.Is(err, order.ErrIllegalTransition) {
+				logger.Printf("order %s not completed: %v", ev.OrderID, err)
+				return nil
 			}
-			if err := pub.PublishOrderCompleted(ctx, ev.OrderID, state.UserID, EnvelopeMetadata{}); err != nil {
-				return fmt.Errorf("publish OrderCompleted: %w", err)
+			if err != nil {
+				return fmt.Errorf("mark completed: %w", err)
 			}
 			logger.Printf("order %s completed (after stock reserved)", ev.OrderID)
 		}
@@ -191,3 +248,44 @@ func StockReservedHandler(repo order.Repository, pub OrderPublisher, logger *log
 		return nil
 	}
 }
+
+// StockDepletedHandler returns a handler for stock.depleted events. The order
+// is cancelled and OrderCancelled lists the lines inventory did reserve, so
+// they can be released.
+func StockDepletedHandler(repo order.Repository, pub OrderPublisher, logger *log.Logger, consumeEnveloped bool) HandlerFunc {
+	return func(ctx context.Context, body []byte) error {
+		payload, envelope, err := parseStockDepleted(body, consumeEnveloped)
+		if err != nil {
+			return fmt.Errorf("parse StockDepleted: %w", err)
+		}
+
+		var meta EnvelopeMetadata
+		cause := order.Cause{Reason: order.CancelReasonStockDepleted}
+		if envelope != nil {
+			meta.CorrelationID = envelope.CorrelationID
+			meta.CausationID = envelope.EventID
+			cause.EventID = envelope.EventID
+		}
+
+		c, err := repo.MarkCancelled(ctx, payload.OrderID, cause, func(tx *sql.Tx, c *order.Cancellation) error {
+			if err := pub.PublishOrderCancelled(ctx, tx, c, payload.Reserved, meta); err != nil {
+				return fmt.Errorf("publish OrderCancelled: %w", err)
+			}
+			return nil
+		})
+		if errors.Is(err, order.ErrIllegalTransition) {
+			logger.Printf("order %s not cancelled for depleted stock: %v", payload.OrderID, err)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("mark cancelled: %w", err)
+		}
+		if c == nil {
+			logger.Printf("order %s not cancelled for depleted stock: unknown order", payload.OrderID)
+			return nil
+		}
+
+		logger.Printf("order %s cancelled: stock depleted for %d products", payload.OrderID, len(payload.Depleted))
+		return nil
+	}
+}
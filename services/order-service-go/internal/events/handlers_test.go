@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/money"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/order"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -20,14 +21,16 @@ type fakeEventRepo struct {
 	createFunc              func(ctx context.Context, o *order.Order) error
 	createWithTxFunc        func(ctx context.Context, tx *sql.Tx, o *order.Order) error
 	markPaymentSucceeded    func(ctx context.Context, orderID string) (*order.CompletionState, error)
-	markPaymentFailed       func(ctx context.Context, orderID string, reason string) error
+	markPaymentFailed       func(ctx context.Context, orderID string, cause order.Cause) error
 	markStockReserved       func(ctx context.Context, orderID string) (*order.CompletionState, error)
-	markCompleted           func(ctx context.Context, orderID string) error
+	markCompleted           func(ctx context.Context, orderID string, cause order.Cause) error
+	markCancelled           func(ctx context.Context, orderID string, cause order.Cause) (*order.Cancellation, error)
 	createdOrder            *order.Order
 	markCompletedInvoked    bool
 	markCompletedInvokedID  string
 	markPaymentFailedCalled bool
-	markPaymentFailedReason string
+	markPaymentFailedCause  order.Cause
+	markCancelledCause      order.Cause
 }
 
 type fakeDedupRepo struct {
@@ -54,19 +57,33 @@ func (f *fakeDedupRepo) UpsertLastSequence(ctx context.Context, tx *sql.Tx, cons
 type fakePublisher struct {
 	orderCreatedCalls   int
 	orderCompletedCalls int
+	cancelled           []*order.Cancellation
+	cancelledReserved   []StockLine
 	lastMeta            EnvelopeMetadata
+	lastTx              *sql.Tx
+	err                 error
 }
 
-func (f *fakePublisher) PublishOrderCreated(ctx context.Context, o *order.Order, meta EnvelopeMetadata) error {
+func (f *fakePublisher) PublishOrderCreated(ctx context.Context, tx *sql.Tx, o *order.Order, meta EnvelopeMetadata) error {
 	f.orderCreatedCalls++
 	f.lastMeta = meta
-	return nil
+	f.lastTx = tx
+	return f.err
 }
 
-func (f *fakePublisher) PublishOrderCompleted(ctx context.Context, orderID, userID string, meta EnvelopeMetadata) error {
+func (f *fakePublisher) PublishOrderCompleted(ctx context.Context, tx *sql.Tx, orderID, userID string, meta EnvelopeMetadata) error {
 	f.orderCompletedCalls++
 	f.lastMeta = meta
-	return nil
+	f.lastTx = tx
+	return f.err
+}
+
+func (f *fakePublisher) PublishOrderCancelled(ctx context.Context, tx *sql.Tx, c *order.Cancellation, reserved []StockLine, meta EnvelopeMetadata) error {
+	f.cancelled = append(f.cancelled, c)
+	f.cancelledReserved = reserved
+	f.lastMeta = meta
+	f.lastTx = tx
+	return f.err
 }
 
 func (f *fakeEventRepo) Create(ctx context.Context, o *order.Order) error {
@@ -89,8 +106,8 @@ func (f *fakeEventRepo) GetByID(ctx context.Context, orderID string) (*order.Ord
 	return nil, nil
 }
 
-func (f *fakeEventRepo) ListByUser(ctx context.Context, userID string) ([]order.Order, error) {
-	return nil, nil
+func (f *fakeEventRepo) ListByUser(ctx context.Context, userID string, filter order.ListFilter, page order.Page) ([]order.Order, *order.Cursor, error) {
+	return nil, nil, nil
 }
 
 func (f *fakeEventRepo) MarkPaymentSucceeded(ctx context.Context, orderID string) (*order.CompletionState, error) {
@@ -100,11 +117,11 @@ func (f *fakeEventRepo) MarkPaymentSucceeded(ctx context.Context, orderID string
 	return nil, nil
 }
 
-func (f *fakeEventRepo) MarkPaymentFailed(ctx context.Context, orderID string, reason string) error {
+func (f *fakeEventRepo) MarkPaymentFailed(ctx context.Context, orderID string, cause order.Cause) error {
 	f.markPaymentFailedCalled = true
-	f.markPaymentFailedReason = reason
+	f.markPaymentFailedCause = cause
 	if f.markPaymentFailed != nil {
-		return f.markPaymentFailed(ctx, orderID, reason)
+		return f.markPaymentFailed(ctx, orderID, cause)
 	}
 	return nil
 }
@@ -116,24 +133,55 @@ func (f *fakeEventRepo) MarkStockReserved(ctx context.Context, orderID string) (
 	return nil, nil
 }
 
-func (f *fakeEventRepo) MarkCompleted(ctx context.Context, orderID string) error {
+// MarkCompleted runs announce, without a transaction, unless markCompleted
+// fails.
+func (f *fakeEventRepo) MarkCompleted(ctx context.Context, orderID string, cause order.Cause, announce func(tx *sql.Tx) error) error {
 	f.markCompletedInvoked = true
 	f.markCompletedInvokedID = orderID
 	if f.markCompleted != nil {
-		return f.markCompleted(ctx, orderID)
+		if err := f.markCompleted(ctx, orderID, cause); err != nil {
+			return err
+		}
+	}
+	if announce != nil {
+		return announce(nil)
 	}
 	return nil
 }
 
-func TestHandleCartCheckedOut_CreatesOrder(t *testing.T) {
-	repo := &fakeEventRepo{
-		createFunc: func(ctx context.Context, o *order.Order) error {
-			// return an error to stop before publisher is used
-			return errors.New("stop before publish")
-		},
+// MarkCancelled runs announce, without a transaction, on the cancellation
+// markCancelled returns.
+func (f *fakeEventRepo) MarkCancelled(ctx context.Context, orderID string, cause order.Cause, announce func(tx *sql.Tx, c *order.Cancellation) error) (*order.Cancellation, error) {
+	f.markCancelledCause = cause
+	if f.markCancelled == nil {
+		return nil, nil
+	}
+	c, err := f.markCancelled(ctx, orderID, cause)
+	if err != nil || c == nil || announce == nil {
+		return c, err
 	}
+	if err := announce(nil, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
 
-	handler := CartCheckedOutHandler(nil, repo, &fakeDedupRepo{}, &fakePublisher{}, log.New(io.Discard, "", 0), false)
+func (f *fakeEventRepo) History(ctx context.Context, orderID string) (*order.History, error) {
+	return nil, nil
+}
+
+func TestHandleCartCheckedOut_CreatesOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	repo := &fakeEventRepo{}
+	pub := &fakePublisher{}
+
+	handler := CartCheckedOutHandler(db, repo, &fakeDedupRepo{}, pub, log.New(io.Discard, "", 0), false)
 
 	body := []byte(`{
 		"eventType": "CartCheckedOut",
@@ -144,8 +192,7 @@ func TestHandleCartCheckedOut_CreatesOrder(t *testing.T) {
 		"timestamp": "2024-01-01T00:00:00Z"
 	}`)
 
-	err := handler(context.Background(), body)
-	require.Error(t, err)
+	require.NoError(t, handler(context.Background(), body))
 
 	require.NotNil(t, repo.createdOrder)
 	assert.Equal(t, "cart-1", repo.createdOrder.CartID)
@@ -153,20 +200,71 @@ func TestHandleCartCheckedOut_CreatesOrder(t *testing.T) {
 	require.Len(t, repo.createdOrder.Items, 1)
 	assert.Equal(t, "p1", repo.createdOrder.Items[0].ProductID)
 	assert.Equal(t, 2, repo.createdOrder.Items[0].Quantity)
+	assert.Equal(t, money.New(350, money.DefaultCurrency), repo.createdOrder.Items[0].Price)
+	assert.Equal(t, money.New(700, money.DefaultCurrency), repo.createdOrder.TotalAmount)
+	assert.Equal(t, 1, pub.orderCreatedCalls)
+	assert.NotNil(t, pub.lastTx)
+	require.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestHandleCartCheckedOut_CreateError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
 	repo := &fakeEventRepo{
-		createFunc: func(ctx context.Context, o *order.Order) error {
+		createWithTxFunc: func(ctx context.Context, tx *sql.Tx, o *order.Order) error {
 			return errors.New("insert failed")
 		},
 	}
-	handler := CartCheckedOutHandler(nil, repo, &fakeDedupRepo{}, &fakePublisher{}, log.New(io.Discard, "", 0), false)
+	pub := &fakePublisher{}
+	handler := CartCheckedOutHandler(db, repo, &fakeDedupRepo{}, pub, log.New(io.Discard, "", 0), false)
 
 	body := []byte(`{"cartId":"cart-1","userId":"user-1","items":[],"totalAmount":0,"timestamp":"2024-01-01T00:00:00Z"}`)
 
-	err := handler(context.Background(), body)
+	err = handler(context.Background(), body)
 	require.Error(t, err)
+	assert.Zero(t, pub.orderCreatedCalls)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandleCartCheckedOut_PublishErrorRollsBack(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Neither the order nor the dedup checkpoint is committed, so the
+	// redelivered message is processed again.
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	dedupRepo := &fakeDedupRepo{}
+	pub := &fakePublisher{err: errors.New("insert outbox failed")}
+	handler := CartCheckedOutHandler(db, &fakeEventRepo{}, dedupRepo, pub, log.New(io.Discard, "", 0), true)
+
+	seq := int64(1)
+	body, err := json.Marshal(CartCheckedOutEnvelope{
+		EventName:    cartCheckedOutEventName,
+		EventVersion: cartCheckedOutEventVersion,
+		EventID:      "e1",
+		PartitionKey: "cart-1",
+		Sequence:     &seq,
+		Payload: CartCheckedOutPayload{
+			CartID:      "cart-1",
+			UserID:      "user-1",
+			Items:       []CartItem{{ProductID: "p1", Quantity: 1, Price: 5}},
+			TotalAmount: 5,
+			Timestamp:   time.Now(),
+		},
+	})
+	require.NoError(t, err)
+
+	require.Error(t, handler(context.Background(), body))
+	assert.Equal(t, dedupRepo.upsertTx, pub.lastTx)
+	require.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestHandlePaymentSucceeded_NotReady(t *testing.T) {
@@ -194,7 +292,7 @@ func TestHandlePaymentSucceeded_ReadyButCompleteFails(t *testing.T) {
 				ReadyToComplete: true,
 			}, nil
 		},
-		markCompleted: func(ctx context.Context, orderID string) error {
+		markCompleted: func(ctx context.Context, orderID string, cause order.Cause) error {
 			return errors.New("complete failed")
 		},
 	}
@@ -208,6 +306,53 @@ func TestHandlePaymentSucceeded_ReadyButCompleteFails(t *testing.T) {
 	assert.Equal(t, "order-1", repo.markCompletedInvokedID)
 }
 
+func TestHandlePaymentSucceeded_CompletesAndPublishes(t *testing.T) {
+	repo := &fakeEventRepo{
+		markPaymentSucceeded: func(ctx context.Context, orderID string) (*order.CompletionState, error) {
+			return &order.CompletionState{UserID: "user-1", ReadyToComplete: true}, nil
+		},
+	}
+	pub := &fakePublisher{}
+	handler := PaymentSucceededHandler(repo, pub, log.New(io.Discard, "", 0))
+
+	body := []byte(`{"orderId":"order-1","userId":"user-1","timestamp":"2024-01-01T00:00:00Z"}`)
+	require.NoError(t, handler(context.Background(), body))
+	assert.True(t, repo.markCompletedInvoked)
+	assert.Equal(t, 1, pub.orderCompletedCalls)
+}
+
+func TestHandlePaymentSucceeded_PublishErrorFailsCompletion(t *testing.T) {
+	repo := &fakeEventRepo{
+		markPaymentSucceeded: func(ctx context.Context, orderID string) (*order.CompletionState, error) {
+			return &order.CompletionState{UserID: "user-1", ReadyToComplete: true}, nil
+		},
+	}
+	pub := &fakePublisher{err: errors.New("insert outbox failed")}
+	handler := PaymentSucceededHandler(repo, pub, log.New(io.Discard, "", 0))
+
+	body := []byte(`{"orderId":"order-1","userId":"user-1","timestamp":"2024-01-01T00:00:00Z"}`)
+	require.Error(t, handler(context.Background(), body))
+	assert.Equal(t, 1, pub.orderCompletedCalls)
+}
+
+func TestHandlePaymentSucceeded_IllegalTransition(t *testing.T) {
+	repo := &fakeEventRepo{
+		markPaymentSucceeded: func(ctx context.Context, orderID string) (*order.CompletionState, error) {
+			return &order.CompletionState{UserID: "user-1", ReadyToComplete: true}, nil
+		},
+		markCompleted: func(ctx context.Context, orderID string, cause order.Cause) error {
+			return &order.TransitionError{OrderID: orderID, From: order.StatusCancelled, To: order.StatusCompleted}
+		},
+	}
+	pub := &fakePublisher{}
+	handler := PaymentSucceededHandler(repo, pub, log.New(io.Discard, "", 0))
+
+	body := []byte(`{"orderId":"order-1","userId":"user-1","timestamp":"2024-01-01T00:00:00Z"}`)
+	require.NoError(t, handler(context.Background(), body))
+	assert.True(t, repo.markCompletedInvoked)
+	assert.Zero(t, pub.orderCompletedCalls)
+}
+
 func TestHandlePaymentSucceeded_Error(t *testing.T) {
 	repo := &fakeEventRepo{
 		markPaymentSucceeded: func(ctx context.Context, orderID string) (*order.CompletionState, error) {
@@ -229,12 +374,12 @@ func TestHandlePaymentFailed(t *testing.T) {
 	require.NoError(t, handler(context.Background(), body))
 
 	assert.True(t, repo.markPaymentFailedCalled)
-	assert.Equal(t, "declined", repo.markPaymentFailedReason)
+	assert.Equal(t, "declined", repo.markPaymentFailedCause.Reason)
 }
 
 func TestHandlePaymentFailed_Error(t *testing.T) {
 	repo := &fakeEventRepo{
-		markPaymentFailed: func(ctx context.Context, orderID string, reason string) error {
+		markPaymentFailed: func(ctx context.Context, orderID string, cause order.Cause) error {
 			return errors.New("update failed")
 		},
 	}
@@ -270,7 +415,7 @@ func TestHandleStockReserved_ReadyButCompleteFails(t *testing.T) {
 				ReadyToComplete: true,
 			}, nil
 		},
-		markCompleted: func(ctx context.Context, orderID string) error {
+		markCompleted: func(ctx context.Context, orderID string, cause order.Cause) error {
 			return errors.New("complete failed")
 		},
 	}
@@ -406,3 +551,331 @@ func TestCartCheckedOutHandler_DedupProcessesHigherSequence(t *testing.T) {
 	assert.Equal(t, "corr-1", pub.lastMeta.CorrelationID)
 	require.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestCartCheckedOutHandler_V2KeepsExactAmounts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	repo := &fakeEventRepo{
+		createWithTxFunc: func(ctx context.Context, tx *sql.Tx, o *order.Order) error {
+			return nil
+		},
+	}
+	pub := &fakePublisher{}
+	dedupRepo := &fakeDedupRepo{}
+
+	handler := CartCheckedOutHandler(db, repo, dedupRepo, pub, log.New(io.Discard, "", 0), true)
+
+	seq := int64(2)
+	env := EventEnvelope[CartCheckedOutV2Payload]{
+		EventName:    cartCheckedOutEventName,
+		EventVersion: cartCheckedOutV2EventVersion,
+		EventID:      "e3",
+		PartitionKey: "cart-v2",
+		Sequence:     &seq,
+		Schema:       "contracts/events/cart/CartCheckedOut.v2.payload.schema.json",
+		Payload: CartCheckedOutV2Payload{
+			CartID:      "cart-v2",
+			UserID:      "user-4",
+			Items:       []CartItemV2{{ProductID: "p1", Quantity: 3, Price: money.New(1999, "EUR")}},
+			TotalAmount: money.New(5997, "EUR"),
+			Timestamp:   time.Now(),
+		},
+	}
+
+	body, err := json.Marshal(env)
+	require.NoError(t, err)
+
+	require.NoError(t, handler(context.Background(), body))
+	require.NotNil(t, repo.createdOrder)
+	assert.Equal(t, money.New(5997, "EUR"), repo.createdOrder.TotalAmount)
+	require.Len(t, repo.createdOrder.Items, 1)
+	assert.Equal(t, money.New(1999, "EUR"), repo.createdOrder.Items[0].Price)
+	assert.Equal(t, int64(2), dedupRepo.upserted)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCartCheckedOutHandler_V3PersistsDiscount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	repo := &fakeEventRepo{
+		createWithTxFunc: func(ctx context.Context, tx *sql.Tx, o *order.Order) error {
+			return nil
+		},
+	}
+	handler := CartCheckedOutHandler(db, repo, &fakeDedupRepo{}, &fakePublisher{}, log.New(io.Discard, "", 0), true)
+
+	seq := int64(3)
+	env := EventEnvelope[CartCheckedOutV3Payload]{
+		EventName:    cartCheckedOutEventName,
+		EventVersion: cartCheckedOutV3EventVersion,
+		EventID:      "e5",
+		PartitionKey: "cart-v3",
+		Sequence:     &seq,
+		Schema:       "contracts/events/cart/CartCheckedOut.v3.enveloped.schema.json",
+		Payload: CartCheckedOutV3Payload{
+			CartID:   "cart-v3",
+			UserID:   "user-6",
+			Items:    []CartItemV2{{ProductID: "p1", Quantity: 2, Price: money.New(1000, "USD")}},
+			Subtotal: money.New(2000, "USD"),
+			Adjustments: []CartAdjustment{
+				{Code: "SAVE10", PromotionID: "promo-1", Amount: money.New(-200, "USD")},
+			},
+			TotalAmount: money.New(1800, "USD"),
+			Timestamp:   time.Now(),
+		},
+	}
+
+	body, err := json.Marshal(env)
+	require.NoError(t, err)
+
+	require.NoError(t, handler(context.Background(), body))
+	require.NotNil(t, repo.createdOrder)
+	assert.Equal(t, money.New(1800, "USD"), repo.createdOrder.TotalAmount)
+	assert.Equal(t, money.New(200, "USD"), repo.createdOrder.Discount)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCartCheckedOutHandler_V4PersistsTaxLines(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	repo := &fakeEventRepo{
+		createWithTxFunc: func(ctx context.Context, tx *sql.Tx, o *order.Order) error {
+			return nil
+		},
+	}
+	handler := CartCheckedOutHandler(db, repo, &fakeDedupRepo{}, &fakePublisher{}, log.New(io.Discard, "", 0), true)
+
+	seq := int64(4)
+	env := EventEnvelope[CartCheckedOutV4Payload]{
+		EventName:    cartCheckedOutEventName,
+		EventVersion: cartCheckedOutV4EventVersion,
+		EventID:      "e6",
+		PartitionKey: "cart-v4",
+		Sequence:     &seq,
+		Schema:       "contracts/events/cart/CartCheckedOut.v4.enveloped.schema.json",
+		Payload: CartCheckedOutV4Payload{
+			CartID:   "cart-v4",
+			UserID:   "user-7",
+			Items:    []CartItemV2{{ProductID: "p1", Quantity: 2, Price: money.New(1000, "USD")}},
+			Subtotal: money.New(2000, "USD"),
+			Adjustments: []CartAdjustment{
+				{Code: "SAVE10", PromotionID: "promo-1", Amount: money.New(-200, "USD")},
+			},
+			Tax: &CartTax{
+				Country: "US",
+				Region:  "NY",
+				Lines: []CartTaxLine{{
+					ProductID:       "p1",
+					TaxClass:        "standard",
+					RateBasisPoints: 888,
+					TaxableAmount:   money.New(1800, "USD"),
+					TaxAmount:       money.New(160, "USD"),
+				}},
+				TotalTax:     money.New(160, "USD"),
+				ExclusiveTax: money.New(160, "USD"),
+			},
+			TotalAmount: money.New(1960, "USD"),
+			Timestamp:   time.Now(),
+		},
+	}
+
+	body, err := json.Marshal(env)
+	require.NoError(t, err)
+
+	require.NoError(t, handler(context.Background(), body))
+	require.NotNil(t, repo.createdOrder)
+	assert.Equal(t, money.New(1960, "USD"), repo.createdOrder.TotalAmount)
+	assert.Equal(t, money.New(200, "USD"), repo.createdOrder.Discount)
+	assert.Equal(t, money.New(160, "USD"), repo.createdOrder.Tax)
+	require.Len(t, repo.createdOrder.TaxLines, 1)
+	assert.Equal(t, order.TaxLine{
+		ProductID:       "p1",
+		TaxClass:        "standard",
+		Country:         "US",
+		Region:          "NY",
+		RateBasisPoints: 888,
+		Taxable:         money.New(1800, "USD"),
+		Amount:          money.New(160, "USD"),
+	}, repo.createdOrder.TaxLines[0])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCartCheckedOutHandler_V5PersistsShipping(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	repo := &fakeEventRepo{
+		createWithTxFunc: func(ctx context.Context, tx *sql.Tx, o *order.Order) error {
+			return nil
+		},
+	}
+	handler := CartCheckedOutHandler(db, repo, &fakeDedupRepo{}, &fakePublisher{}, log.New(io.Discard, "", 0), true)
+
+	seq := int64(5)
+	env := EventEnvelope[CartCheckedOutV5Payload]{
+		EventName:    cartCheckedOutEventName,
+		EventVersion: cartCheckedOutV5EventVersion,
+		EventID:      "e7",
+		PartitionKey: "cart-v5",
+		Sequence:     &seq,
+		Schema:       "contracts/events/cart/CartCheckedOut.v5.enveloped.schema.json",
+		Payload: CartCheckedOutV5Payload{
+			CartID:      "cart-v5",
+			UserID:      "user-8",
+			Items:       []CartItemV2{{ProductID: "p1", Quantity: 1, Price: money.New(1000, "DKK")}},
+			Subtotal:    money.New(1000, "DKK"),
+			Adjustments: []CartAdjustment{},
+			Shipping: &CartShipping{
+				Address: CartAddress{Line1: "Aaboulevarden 12", Line2: "3. th.", City: "Aarhus", PostalCode: "8000", Country: "DK"},
+				Method:  "express",
+			},
+			TotalAmount: money.New(1000, "DKK"),
+			Timestamp:   time.Now(),
+		},
+	}
+
+	body, err := json.Marshal(env)
+	require.NoError(t, err)
+
+	require.NoError(t, handler(context.Background(), body))
+	require.NotNil(t, repo.createdOrder)
+	assert.Equal(t, &order.Shipping{
+		Address: order.Address{Line1: "Aaboulevarden 12", Line2: "3. th.", City: "Aarhus", PostalCode: "8000", Country: "DK"},
+		Method:  "express",
+	}, repo.createdOrder.Shipping)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	t.Run("incomplete shipping details are rejected", func(t *testing.T) {
+		repo := &fakeEventRepo{}
+		handler := CartCheckedOutHandler(nil, repo, &fakeDedupRepo{}, &fakePublisher{}, log.New(io.Discard, "", 0), true)
+		env.Payload.Shipping.Address.PostalCode = ""
+
+		body, err := json.Marshal(env)
+		require.NoError(t, err)
+
+		require.Error(t, handler(context.Background(), body))
+		assert.Nil(t, repo.createdOrder)
+	})
+}
+
+func TestCartCheckedOutHandler_V2RejectsMixedCurrencies(t *testing.T) {
+	repo := &fakeEventRepo{}
+	handler := CartCheckedOutHandler(nil, repo, &fakeDedupRepo{}, &fakePublisher{}, log.New(io.Discard, "", 0), true)
+
+	seq := int64(1)
+	env := EventEnvelope[CartCheckedOutV2Payload]{
+		EventName:    cartCheckedOutEventName,
+		EventVersion: cartCheckedOutV2EventVersion,
+		EventID:      "e4",
+		PartitionKey: "cart-mixed",
+		Sequence:     &seq,
+		Payload: CartCheckedOutV2Payload{
+			CartID:      "cart-mixed",
+			UserID:      "user-5",
+			Items:       []CartItemV2{{ProductID: "p1", Quantity: 1, Price: money.New(100, "USD")}},
+			TotalAmount: money.New(100, "EUR"),
+		},
+	}
+
+	body, err := json.Marshal(env)
+	require.NoError(t, err)
+
+	err = handler(context.Background(), body)
+	require.ErrorIs(t, err, money.ErrCurrencyMismatch)
+	assert.Nil(t, repo.createdOrder)
+}
+
+func TestStockDepletedHandler_CancelsOrder(t *testing.T) {
+	repo := &fakeEventRepo{
+		markCancelled: func(ctx context.Context, orderID string, cause order.Cause) (*order.Cancellation, error) {
+			return &order.Cancellation{OrderID: orderID, UserID: "user-1", Reason: cause.Reason, TotalAmount: money.New(2500, "USD")}, nil
+		},
+	}
+	pub := &fakePublisher{}
+	handler := StockDepletedHandler(repo, pub, log.New(io.Discard, "", 0), true)
+
+	seq := int64(3)
+	env := EventEnvelope[StockDepletedPayload]{
+		EventName:     stockDepletedEventName,
+		EventVersion:  stockDepletedEventVersion,
+		EventID:       "e1",
+		CorrelationID: "c1",
+		PartitionKey:  "order-1",
+		Sequence:      &seq,
+		Payload: StockDepletedPayload{
+			OrderID:  "order-1",
+			UserID:   "user-1",
+			Depleted: []DepletedLine{{ProductID: "p1", Requested: 2, Available: 0}},
+			Reserved: []StockLine{{ProductID: "p2", Quantity: 1}},
+		},
+	}
+	body, err := json.Marshal(env)
+	require.NoError(t, err)
+
+	require.NoError(t, handler(context.Background(), body))
+	assert.Equal(t, order.Cause{Reason: order.CancelReasonStockDepleted, EventID: "e1"}, repo.markCancelledCause)
+	require.Len(t, pub.cancelled, 1)
+	assert.Equal(t, "order-1", pub.cancelled[0].OrderID)
+	assert.Equal(t, []StockLine{{ProductID: "p2", Quantity: 1}}, pub.cancelledReserved)
+	assert.Equal(t, EnvelopeMetadata{CorrelationID: "c1", CausationID: "e1"}, pub.lastMeta)
+}
+
+func TestStockDepletedHandler_Legacy(t *testing.T) {
+	repo := &fakeEventRepo{
+		markCancelled: func(ctx context.Context, orderID string, cause order.Cause) (*order.Cancellation, error) {
+			return &order.Cancellation{OrderID: orderID, UserID: "user-1", Reason: cause.Reason}, nil
+		},
+	}
+	pub := &fakePublisher{}
+	handler := StockDepletedHandler(repo, pub, log.New(io.Discard, "", 0), true)
+
+	body := []byte(`{"eventType":"StockDepleted","orderId":"order-1","userId":"user-1","depleted":[{"productId":"p1","requested":2,"available":1}],"timestamp":"2024-01-01T00:00:00Z"}`)
+	require.NoError(t, handler(context.Background(), body))
+	require.Len(t, pub.cancelled, 1)
+	assert.Empty(t, pub.cancelledReserved)
+}
+
+func TestStockDepletedHandler_AlreadyCancelled(t *testing.T) {
+	repo := &fakeEventRepo{
+		markCancelled: func(ctx context.Context, orderID string, cause order.Cause) (*order.Cancellation, error) {
+			return nil, &order.TransitionError{OrderID: orderID, From: order.StatusCancelled, To: order.StatusCancelled}
+		},
+	}
+	pub := &fakePublisher{}
+	handler := StockDepletedHandler(repo, pub, log.New(io.Discard, "", 0), true)
+
+	body := []byte(`{"orderId":"order-1","userId":"user-1","depleted":[{"productId":"p1","requested":2,"available":0}],"timestamp":"2024-01-01T00:00:00Z"}`)
+	require.NoError(t, handler(context.Background(), body))
+	assert.Empty(t, pub.cancelled)
+}
+
+func TestStockDepletedHandler_Error(t *testing.T) {
+	repo := &fakeEventRepo{
+		markCancelled: func(ctx context.Context, orderID string, cause order.Cause) (*order.Cancellation, error) {
+			return nil, errors.New("update failed")
+		},
+	}
+	handler := StockDepletedHandler(repo, &fakePublisher{}, log.New(io.Discard, "", 0), true)
+
+	body := []byte(`{"orderId":"order-1","userId":"user-1","depleted":[],"timestamp":"2024-01-01T00:00:00Z"}`)
+	require.Error(t, handler(context.Background(), body))
+}
@@ -7,11 +7,19 @@ import (
 const (
 	EventsExchange             = "ecommerce.events"
 	CartCheckedOutRoutingKey   = "cart.checkedout.v1"
+	CartCheckedOutV2RoutingKey = "cart.checkedout.v2"
+	CartCheckedOutV3RoutingKey = "cart.checkedout.v3"
+	CartCheckedOutV4RoutingKey = "cart.checkedout.v4"
+	CartCheckedOutV5RoutingKey = "cart.checkedout.v5"
 	PaymentSucceededRoutingKey = "payment.succeeded.v1"
 	PaymentFailedRoutingKey    = "payment.failed.v1"
 	StockReservedRoutingKey    = "stock.reserved.v1"
+	StockDepletedRoutingKey    = "stock.depleted.v1"
 	OrderCreatedRoutingKey     = "order.created.v1"
+	OrderCreatedV2RoutingKey   = "order.created.v2"
+	OrderCreatedV3RoutingKey   = "order.created.v3"
 	OrderCompletedRoutingKey   = "order.completed.v1"
+	OrderCancelledRoutingKey   = "order.cancelled.v1"
 	orderServiceName           = "order-service-go"
 )
 
@@ -0,0 +1,66 @@
+package events
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/money"
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/order"
+)
+
+const (
+	orderCancelledEventName    = "OrderCancelled"
+	orderCancelledEventVersion = 1
+	orderCancelledSchema       = "contracts/events/order/OrderCancelled.v1.payload.schema.json"
+)
+
+// OrderCancelledPayload represents the v1 payload schema. TotalAmount is
+// what to refund if payment was captured; Reserved is the stock to release.
+type OrderCancelledPayload struct {
+	OrderID     string      `json:"orderId"`
+	UserID      string      `json:"userId"`
+	Reason      string      `json:"reason"`
+	TotalAmount money.Money `json:"totalAmount"`
+	Reserved    []StockLine `json:"reserved"`
+	Timestamp   time.Time   `json:"timestamp"`
+}
+
+// OrderCancelled is the legacy bare event.
+type OrderCancelled struct {
+	EventType string `json:"eventType"`
+	OrderCancelledPayload
+}
+
+type OrderCancelledEnvelope = EventEnvelope[OrderCancelledPayload]
+
+func newOrderCancelledPayload(c *order.Cancellation, reserved []StockLine) OrderCancelledPayload {
+	if reserved == nil {
+		reserved = []StockLine{}
+	}
+	return OrderCancelledPayload{
+		OrderID:     c.OrderID,
+		UserID:      c.UserID,
+		Reason:      c.Reason,
+		TotalAmount: c.TotalAmount,
+		Reserved:    reserved,
+		Timestamp:   time.Now().UTC(),
+	}
+}
+
+// BuildOrderCancelledEnvelope builds an enveloped OrderCancelled event.
+func BuildOrderCancelledEnvelope(c *order.Cancellation, reserved []StockLine, seq int64, meta EnvelopeMetadata) OrderCancelledEnvelope {
+	return OrderCancelledEnvelope{
+		EventName:     orderCancelledEventName,
+		EventVersion:  orderCancelledEventVersion,
+		EventID:       uuid.NewString(),
+		CorrelationID: meta.CorrelationID,
+		CausationID:   meta.CausationID,
+		Producer:      "order-service",
+		PartitionKey:  c.OrderID,
+		Sequence:      &seq,
+		OccurredAt:    time.Now().UTC(),
+		Schema:        orderCancelledSchema,
+		Payload:       newOrderCancelledPayload(c, reserved),
+	}
+}
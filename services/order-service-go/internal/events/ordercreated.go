@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/uuid"
 
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/money"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/order"
 )
 
@@ -38,7 +39,7 @@ func BuildOrderCreatedEnvelope(o *order.Order, seq int64, meta EnvelopeMetadata)
 		items = append(items, CartItem{
 			ProductID: it.ProductID,
 			Quantity:  it.Quantity,
-			Price:     it.Price,
+			Price:     it.Price.Float64(),
 		})
 	}
 
@@ -54,6 +55,69 @@ func BuildOrderCreatedEnvelope(o *order.Order, seq int64, meta EnvelopeMetadata)
 		OccurredAt:    time.Now().UTC(),
 		Schema:        orderCreatedSchema,
 		Payload: OrderCreatedPayload{
+			OrderID:     o.ID,
+			CartID:      o.CartID,
+			UserID:      o.UserID,
+			Items:       items,
+			TotalAmount: o.TotalAmount.Float64(),
+			Timestamp:   o.CreatedAt,
+		},
+	}
+}
+
+const (
+	orderCreatedV2EventVersion = 2
+	orderCreatedV2Schema       = "contracts/events/order/OrderCreated.v2.payload.schema.json"
+)
+
+// OrderItemV2 is an order line with its unit price as Money.
+type OrderItemV2 struct {
+	ProductID string      `json:"productId"`
+	Quantity  int         `json:"quantity"`
+	Price     money.Money `json:"price"`
+}
+
+// OrderCreatedV2Payload represents the v2 payload schema, which carries
+// amounts as integer minor units with a currency.
+type OrderCreatedV2Payload struct {
+	OrderID     string        `json:"orderId"`
+	CartID      string        `json:"cartId"`
+	UserID      string        `json:"userId"`
+	Items       []OrderItemV2 `json:"items"`
+	TotalAmount money.Money   `json:"totalAmount"`
+	Timestamp   time.Time     `json:"timestamp"`
+}
+
+// OrderCreatedV2Envelope is the enveloped v2 event structure.
+type OrderCreatedV2Envelope = EventEnvelope[OrderCreatedV2Payload]
+
+// BuildOrderCreatedV2Envelope builds an enveloped OrderCreated v2 event.
+func BuildOrderCreatedV2Envelope(o *order.Order, seq int64, meta EnvelopeMetadata) OrderCreatedV2Envelope {
+	if meta.CorrelationID == "" {
+		meta.CorrelationID = uuid.NewString()
+	}
+
+	items := make([]OrderItemV2, 0, len(o.Items))
+	for _, it := range o.Items {
+		items = append(items, OrderItemV2{
+			ProductID: it.ProductID,
+			Quantity:  it.Quantity,
+			Price:     it.Price,
+		})
+	}
+
+	return OrderCreatedV2Envelope{
+		EventName:     orderCreatedEventName,
+		EventVersion:  orderCreatedV2EventVersion,
+		EventID:       uuid.NewString(),
+		CorrelationID: meta.CorrelationID,
+		CausationID:   meta.CausationID,
+		Producer:      "order-service",
+		PartitionKey:  o.ID,
+		Sequence:      &seq,
+		OccurredAt:    time.Now().UTC(),
+		Schema:        orderCreatedV2Schema,
+		Payload: OrderCreatedV2Payload{
 			OrderID:     o.ID,
 			CartID:      o.CartID,
 			UserID:      o.UserID,
@@ -63,3 +127,82 @@ func BuildOrderCreatedEnvelope(o *order.Order, seq int64, meta EnvelopeMetadata)
 		},
 	}
 }
+
+const (
+	orderCreatedV3EventVersion = 3
+	orderCreatedV3Schema       = "contracts/events/order/OrderCreated.v3.payload.schema.json"
+)
+
+// OrderAddress is the postal address an order is delivered to.
+type OrderAddress struct {
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city"`
+	State      string `json:"state,omitempty"`
+	PostalCode string `json:"postalCode"`
+	Country    string `json:"country"`
+}
+
+// OrderShipping is where and how an order is delivered.
+type OrderShipping struct {
+	Address OrderAddress `json:"address"`
+	Method  string       `json:"method"`
+}
+
+// OrderCreatedV3Payload represents the v3 payload schema, which adds the
+// shipping details to v2. Shipping is nil for an order checked out without
+// them.
+type OrderCreatedV3Payload struct {
+	OrderID     string         `json:"orderId"`
+	CartID      string         `json:"cartId"`
+	UserID      string         `json:"userId"`
+	Items       []OrderItemV2  `json:"items"`
+	Shipping    *OrderShipping `json:"shipping,omitempty"`
+	TotalAmount money.Money    `json:"totalAmount"`
+	Timestamp   time.Time      `json:"timestamp"`
+}
+
+// OrderCreatedV3Envelope is the enveloped v3 event structure.
+type OrderCreatedV3Envelope = EventEnvelope[OrderCreatedV3Payload]
+
+// BuildOrderCreatedV3Envelope builds an enveloped OrderCreated v3 event.
+func BuildOrderCreatedV3Envelope(o *order.Order, seq int64, meta EnvelopeMetadata) OrderCreatedV3Envelope {
+	v2 := BuildOrderCreatedV2Envelope(o, seq, meta)
+
+	env := OrderCreatedV3Envelope{
+		EventName:     v2.EventName,
+		EventVersion:  orderCreatedV3EventVersion,
+		EventID:       v2.EventID,
+		CorrelationID: v2.CorrelationID,
+		CausationID:   v2.CausationID,
+		Producer:      v2.Producer,
+		PartitionKey:  v2.PartitionKey,
+		Sequence:      v2.Sequence,
+		OccurredAt:    v2.OccurredAt,
+		Schema:        orderCreatedV3Schema,
+		Payload: OrderCreatedV3Payload{
+			OrderID:     v2.Payload.OrderID,
+			CartID:      v2.Payload.CartID,
+			UserID:      v2.Payload.UserID,
+			Items:       v2.Payload.Items,
+			TotalAmount: v2.Payload.TotalAmount,
+			Timestamp:   v2.Payload.Timestamp,
+		},
+	}
+
+	if s := o.Shipping; s != nil {
+		env.Payload.Shipping = &OrderShipping{
+			Address: OrderAddress{
+				Line1:      s.Address.Line1,
+				Line2:      s.Address.Line2,
+				City:       s.Address.City,
+				State:      s.Address.State,
+				PostalCode: s.Address.PostalCode,
+				Country:    s.Address.Country,
+			},
+			Method: s.Method,
+		}
+	}
+
+	return env
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/money"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/order"
 )
 
@@ -22,48 +23,16 @@ func TestOrderCreatedEnvelopeSchema(t *testing.T) {
 		ID:          uuid.NewString(),
 		CartID:      uuid.NewString(),
 		UserID:      uuid.NewString(),
-		TotalAmount: 25.50,
+		TotalAmount: money.New(2550, "USD"),
 		CreatedAt:   time.Now().UTC(),
 		Items: []order.Item{
-			{ProductID: uuid.NewString(), Quantity: 1, Price: 10.00},
-			{ProductID: uuid.NewString(), Quantity: 2, Price: 7.75},
+			{ProductID: uuid.NewString(), Quantity: 1, Price: money.New(1000, "USD")},
+			{ProductID: uuid.NewString(), Quantity: 2, Price: money.New(775, "USD")},
 		},
 	}
 
 	validate := func(env OrderCreatedEnvelope) error {
-		var asMap map[string]interface{}
-		body, err := json.Marshal(env)
-		if err != nil {
-			return err
-		}
-		if err := json.Unmarshal(body, &asMap); err != nil {
-			return err
-		}
-		for _, field := range requiredFields(envelopeSchema) {
-			if _, ok := asMap[field]; !ok {
-				return fmt.Errorf("missing required field %s", field)
-			}
-		}
-		if err := assertConst(envelopeSchema, asMap, "eventName"); err != nil {
-			return err
-		}
-		if err := assertConst(envelopeSchema, asMap, "eventVersion"); err != nil {
-			return err
-		}
-		if err := assertConst(envelopeSchema, asMap, "schema"); err != nil {
-			return err
-		}
-
-		payloadMap, ok := asMap["payload"].(map[string]interface{})
-		if !ok {
-			return fmt.Errorf("missing payload object")
-		}
-		for _, field := range requiredFields(payloadSchema) {
-			if _, ok := payloadMap[field]; !ok {
-				return fmt.Errorf("missing payload field %s", field)
-			}
-		}
-		return nil
+		return validateEnvelope(env, envelopeSchema, payloadSchema)
 	}
 
 	env := BuildOrderCreatedEnvelope(o, 1, EnvelopeMetadata{
@@ -76,6 +45,123 @@ func TestOrderCreatedEnvelopeSchema(t *testing.T) {
 	require.Error(t, validate(env))
 }
 
+func TestOrderCreatedV2EnvelopeSchema(t *testing.T) {
+	envelopeSchema := loadSchema(t, "OrderCreated.v2.enveloped.schema.json")
+	payloadSchema := loadSchema(t, "OrderCreated.v2.payload.schema.json")
+
+	o := &order.Order{
+		ID:          uuid.NewString(),
+		CartID:      uuid.NewString(),
+		UserID:      uuid.NewString(),
+		TotalAmount: money.New(2550, "EUR"),
+		CreatedAt:   time.Now().UTC(),
+		Items: []order.Item{
+			{ProductID: uuid.NewString(), Quantity: 1, Price: money.New(1000, "EUR")},
+			{ProductID: uuid.NewString(), Quantity: 2, Price: money.New(775, "EUR")},
+		},
+	}
+
+	env := BuildOrderCreatedV2Envelope(o, 2, EnvelopeMetadata{})
+	require.NoError(t, validateEnvelope(env, envelopeSchema, payloadSchema))
+	require.Equal(t, money.New(2550, "EUR"), env.Payload.TotalAmount)
+	require.Equal(t, money.New(775, "EUR"), env.Payload.Items[1].Price)
+
+	env.EventVersion = 1
+	require.Error(t, validateEnvelope(env, envelopeSchema, payloadSchema))
+}
+
+func TestOrderCreatedV3EnvelopeSchema(t *testing.T) {
+	envelopeSchema := loadSchema(t, "OrderCreated.v3.enveloped.schema.json")
+	payloadSchema := loadSchema(t, "OrderCreated.v3.payload.schema.json")
+
+	o := &order.Order{
+		ID:          uuid.NewString(),
+		CartID:      uuid.NewString(),
+		UserID:      uuid.NewString(),
+		TotalAmount: money.New(2000, "DKK"),
+		CreatedAt:   time.Now().UTC(),
+		Items: []order.Item{
+			{ProductID: uuid.NewString(), Quantity: 2, Price: money.New(1000, "DKK")},
+		},
+		Shipping: &order.Shipping{
+			Address: order.Address{Line1: "Aaboulevarden 12", City: "Aarhus", PostalCode: "8000", Country: "DK"},
+			Method:  "express",
+		},
+	}
+
+	env := BuildOrderCreatedV3Envelope(o, 3, EnvelopeMetadata{})
+	require.NoError(t, validateEnvelope(env, envelopeSchema, payloadSchema))
+	require.Equal(t, &OrderShipping{
+		Address: OrderAddress{Line1: "Aaboulevarden 12", City: "Aarhus", PostalCode: "8000", Country: "DK"},
+		Method:  "express",
+	}, env.Payload.Shipping)
+	require.Equal(t, int64(3), *env.Sequence)
+
+	o.Shipping = nil
+	env = BuildOrderCreatedV3Envelope(o, 3, EnvelopeMetadata{})
+	require.NoError(t, validateEnvelope(env, envelopeSchema, payloadSchema))
+	require.Nil(t, env.Payload.Shipping)
+
+	env.EventVersion = 2
+	require.Error(t, validateEnvelope(env, envelopeSchema, payloadSchema))
+}
+
+func TestOrderCancelledEnvelopeSchema(t *testing.T) {
+	envelopeSchema := loadSchema(t, "OrderCancelled.v1.enveloped.schema.json")
+	payloadSchema := loadSchema(t, "OrderCancelled.v1.payload.schema.json")
+
+	c := &order.Cancellation{
+		OrderID:     uuid.NewString(),
+		UserID:      uuid.NewString(),
+		Reason:      order.CancelReasonStockDepleted,
+		TotalAmount: money.New(2500, "USD"),
+	}
+
+	env := BuildOrderCancelledEnvelope(c, nil, 4, EnvelopeMetadata{})
+	require.NoError(t, validateEnvelope(env, envelopeSchema, payloadSchema))
+	require.NotNil(t, env.Payload.Reserved)
+	require.Equal(t, c.OrderID, env.PartitionKey)
+
+	env.EventName = "OrderCompleted"
+	require.Error(t, validateEnvelope(env, envelopeSchema, payloadSchema))
+}
+
+func validateEnvelope(env any, envelopeSchema, payloadSchema map[string]interface{}) error {
+	var asMap map[string]interface{}
+	body, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, &asMap); err != nil {
+		return err
+	}
+	for _, field := range requiredFields(envelopeSchema) {
+		if _, ok := asMap[field]; !ok {
+			return fmt.Errorf("missing required field %s", field)
+		}
+	}
+	if err := assertConst(envelopeSchema, asMap, "eventName"); err != nil {
+		return err
+	}
+	if err := assertConst(envelopeSchema, asMap, "eventVersion"); err != nil {
+		return err
+	}
+	if err := assertConst(envelopeSchema, asMap, "schema"); err != nil {
+		return err
+	}
+
+	payloadMap, ok := asMap["payload"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("missing payload object")
+	}
+	for _, field := range requiredFields(payloadSchema) {
+		if _, ok := payloadMap[field]; !ok {
+			return fmt.Errorf("missing payload field %s", field)
+		}
+	}
+	return nil
+}
+
 func loadSchema(t *testing.T, filename string) map[string]interface{} {
 	t.Helper()
 	schemaPath := filepath.Join("..", "..", "..", "..", "contracts", "events", "order", filename)
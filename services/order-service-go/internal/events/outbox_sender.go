@@ -0,0 +1,88 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/outbox"
+)
+
+// OutboxSender sends outbox messages to the events exchange on a channel in
+// confirm mode, so a message only counts as sent once the broker has taken
+// it. It is meant for the single goroutine of the outbox relay.
+type OutboxSender struct {
+	conn *amqp.Connection
+	ch   *amqp.Channel
+}
+
+func NewOutboxSender(conn *amqp.Connection) (*OutboxSender, error) {
+	s := &OutboxSender{conn: conn}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// open opens the channel, which is reopened by the next Send if it closes.
+func (s *OutboxSender) open() error {
+	ch, err := s.conn.Channel()
+	if err != nil {
+		return fmt.Errorf("open channel: %w", err)
+	}
+	if err := declareEventsExchange(ch); err != nil {
+		_ = ch.Close()
+		return fmt.Errorf("declare events exchange: %w", err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		_ = ch.Close()
+		return fmt.Errorf("enable publisher confirms: %w", err)
+	}
+	s.ch = ch
+	return nil
+}
+
+func (s *OutboxSender) Close() error {
+	return s.ch.Close()
+}
+
+// Send publishes m and waits for the broker to confirm it. A nack is an
+// error, so the message stays in the outbox.
+func (s *OutboxSender) Send(ctx context.Context, m outbox.Message) error {
+	if s.ch.IsClosed() {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+
+	pubCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	confirm, err := s.ch.PublishWithDeferredConfirmWithContext(
+		pubCtx,
+		EventsExchange,
+		m.RoutingKey,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Timestamp:    m.CreatedAt,
+			Body:         m.Payload,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("publish: %w", err)
+	}
+
+	acked, err := confirm.WaitContext(pubCtx)
+	if err != nil {
+		return fmt.Errorf("wait for confirm: %w", err)
+	}
+	if !acked {
+		return fmt.Errorf("broker nacked the message")
+	}
+	return nil
+}
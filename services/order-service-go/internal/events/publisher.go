@@ -2,53 +2,43 @@ package events
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"time"
 
-	amqp "github.com/rabbitmq/amqp091-go"
-
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/order"
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/outbox"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/sequence"
 )
 
 // TODO compare with cart-service-go/internal/events/rabbit.go there seems to be a lot of code duplication and some mismatches
 
+// Publisher publishes order events through the outbox: each event is written
+// in tx, the transaction of the change it announces, and the outbox relay
+// sends it once tx commits.
 type Publisher struct {
-	ch               *amqp.Channel
+	outbox           outbox.Repository
 	seqRepo          sequence.Repository
 	publishEnveloped bool
 }
 
-func NewPublisher(conn *amqp.Connection, seqRepo sequence.Repository, publishEnveloped bool) (*Publisher, error) {
-	ch, err := conn.Channel()
-	if err != nil {
-		return nil, fmt.Errorf("open channel: %w", err)
-	}
-
-	if err := declareEventsExchange(ch); err != nil {
-		return nil, fmt.Errorf("declare events exchange: %w", err)
-	}
-
+func NewPublisher(outboxRepo outbox.Repository, seqRepo sequence.Repository, publishEnveloped bool) *Publisher {
 	return &Publisher{
-		ch:               ch,
+		outbox:           outboxRepo,
 		seqRepo:          seqRepo,
 		publishEnveloped: publishEnveloped,
-	}, nil
-}
-
-func (p *Publisher) Close() error {
-	return p.ch.Close()
+	}
 }
 
-func (p *Publisher) PublishOrderCreated(ctx context.Context, o *order.Order, meta EnvelopeMetadata) error {
+func (p *Publisher) PublishOrderCreated(ctx context.Context, tx *sql.Tx, o *order.Order, meta EnvelopeMetadata) error {
 	if !p.publishEnveloped {
 		ev := OrderCreated{
 			EventType:   "OrderCreated",
 			OrderID:     o.ID,
 			CartID:      o.CartID,
 			UserID:      o.UserID,
-			TotalAmount: o.TotalAmount,
+			TotalAmount: o.TotalAmount.Float64(),
 			Timestamp:   time.Now().UTC(),
 		}
 
@@ -57,7 +47,7 @@ func (p *Publisher) PublishOrderCreated(ctx context.Context, o *order.Order, met
 			ev.Items = append(ev.Items, CartItem{
 				ProductID: it.ProductID,
 				Quantity:  it.Quantity,
-				Price:     it.Price,
+				Price:     it.Price.Float64(),
 			})
 		}
 
@@ -65,10 +55,10 @@ func (p *Publisher) PublishOrderCreated(ctx context.Context, o *order.Order, met
 		if err != nil {
 			return fmt.Errorf("marshal OrderCreated legacy: %w", err)
 		}
-		return p.publishJSON(ctx, OrderCreatedRoutingKey, body)
+		return p.publishJSON(ctx, tx, o.ID, OrderCreatedRoutingKey, body)
 	}
 
-	seq, err := p.seqRepo.NextSequence(ctx, o.ID)
+	seq, err := p.seqRepo.NextSequence(ctx, tx, o.ID)
 	if err != nil {
 		return fmt.Errorf("next sequence: %w", err)
 	}
@@ -79,10 +69,43 @@ func (p *Publisher) PublishOrderCreated(ctx context.Context, o *order.Order, met
 		return fmt.Errorf("marshal OrderCreated enveloped: %w", err)
 	}
 
-	return p.publishJSON(ctx, OrderCreatedRoutingKey, body)
+	if err := p.publishJSON(ctx, tx, o.ID, OrderCreatedRoutingKey, body); err != nil {
+		return err
+	}
+
+	// v2 carries exact Money amounts. It is published next to v1 until every
+	// consumer has moved over, with its own sequence in the order's partition.
+	seq, err = p.seqRepo.NextSequence(ctx, tx, o.ID)
+	if err != nil {
+		return fmt.Errorf("next sequence: %w", err)
+	}
+
+	envV2 := BuildOrderCreatedV2Envelope(o, seq, meta)
+	body, err = json.Marshal(envV2)
+	if err != nil {
+		return fmt.Errorf("marshal OrderCreated v2 enveloped: %w", err)
+	}
+
+	if err := p.publishJSON(ctx, tx, o.ID, OrderCreatedV2RoutingKey, body); err != nil {
+		return err
+	}
+
+	// v3 adds the shipping details the customer chose at checkout.
+	seq, err = p.seqRepo.NextSequence(ctx, tx, o.ID)
+	if err != nil {
+		return fmt.Errorf("next sequence: %w", err)
+	}
+
+	envV3 := BuildOrderCreatedV3Envelope(o, seq, meta)
+	body, err = json.Marshal(envV3)
+	if err != nil {
+		return fmt.Errorf("marshal OrderCreated v3 enveloped: %w", err)
+	}
+
+	return p.publishJSON(ctx, tx, o.ID, OrderCreatedV3RoutingKey, body)
 }
 
-func (p *Publisher) PublishOrderCompleted(ctx context.Context, orderID, userID string, meta EnvelopeMetadata) error {
+func (p *Publisher) PublishOrderCompleted(ctx context.Context, tx *sql.Tx, orderID, userID string, meta EnvelopeMetadata) error {
 	if !p.publishEnveloped {
 		ev := OrderCompleted{
 			EventType: "OrderCompleted",
@@ -96,10 +119,10 @@ func (p *Publisher) PublishOrderCompleted(ctx context.Context, orderID, userID s
 			return fmt.Errorf("marshal OrderCompleted legacy: %w", err)
 		}
 
-		return p.publishJSON(ctx, OrderCompletedRoutingKey, body)
+		return p.publishJSON(ctx, tx, orderID, OrderCompletedRoutingKey, body)
 	}
 
-	seq, err := p.seqRepo.NextSequence(ctx, orderID)
+	seq, err := p.seqRepo.NextSequence(ctx, tx, orderID)
 	if err != nil {
 		return fmt.Errorf("next sequence: %w", err)
 	}
@@ -110,23 +133,42 @@ func (p *Publisher) PublishOrderCompleted(ctx context.Context, orderID, userID s
 		return fmt.Errorf("marshal OrderCompleted enveloped: %w", err)
 	}
 
-	return p.publishJSON(ctx, OrderCompletedRoutingKey, body)
+	return p.publishJSON(ctx, tx, orderID, OrderCompletedRoutingKey, body)
+}
+
+// PublishOrderCancelled announces a cancelled order along with the stock
+// still reserved for it.
+func (p *Publisher) PublishOrderCancelled(ctx context.Context, tx *sql.Tx, c *order.Cancellation, reserved []StockLine, meta EnvelopeMetadata) error {
+	if !p.publishEnveloped {
+		ev := OrderCancelled{
+			EventType:             orderCancelledEventName,
+			OrderCancelledPayload: newOrderCancelledPayload(c, reserved),
+		}
+
+		body, err := json.Marshal(ev)
+		if err != nil {
+			return fmt.Errorf("marshal OrderCancelled legacy: %w", err)
+		}
+
+		return p.publishJSON(ctx, tx, c.OrderID, OrderCancelledRoutingKey, body)
+	}
+
+	seq, err := p.seqRepo.NextSequence(ctx, tx, c.OrderID)
+	if err != nil {
+		return fmt.Errorf("next sequence: %w", err)
+	}
+
+	env := BuildOrderCancelledEnvelope(c, reserved, seq, meta)
+	body, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("marshal OrderCancelled enveloped: %w", err)
+	}
+
+	return p.publishJSON(ctx, tx, c.OrderID, OrderCancelledRoutingKey, body)
 }
 
-func (p *Publisher) publishJSON(ctx context.Context, routingKey string, body []byte) error {
-	pubCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
-	defer cancel()
-
-	return p.ch.PublishWithContext(
-		pubCtx,
-		EventsExchange,
-		routingKey,
-		false,
-		false,
-		amqp.Publishing{
-			ContentType:  "application/json",
-			DeliveryMode: amqp.Persistent,
-			Body:         body,
-		},
-	)
+// publishJSON writes body to the outbox, partitioned by the order it is
+// about so that the events of an order are sent in order.
+func (p *Publisher) publishJSON(ctx context.Context, tx *sql.Tx, orderID, routingKey string, body []byte) error {
+	return p.outbox.Add(ctx, tx, outbox.Message{PartitionKey: orderID, RoutingKey: routingKey, Payload: body})
 }
@@ -0,0 +1,111 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/money"
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/order"
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/outbox"
+)
+
+type fakeOutbox struct {
+	added []outbox.Message
+	tx    *sql.Tx
+}
+
+func (f *fakeOutbox) Add(ctx context.Context, tx *sql.Tx, msgs ...outbox.Message) error {
+	f.tx = tx
+	f.added = append(f.added, msgs...)
+	return nil
+}
+
+func (f *fakeOutbox) FetchPending(ctx context.Context, limit int) ([]outbox.Message, error) {
+	return nil, nil
+}
+
+func (f *fakeOutbox) MarkPublished(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (f *fakeOutbox) MarkFailed(ctx context.Context, id int64, reason string, nextAttempt time.Time) error {
+	return nil
+}
+
+func (f *fakeOutbox) DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+type fakeSequence struct {
+	last map[string]int64
+	tx   *sql.Tx
+}
+
+func (f *fakeSequence) NextSequence(ctx context.Context, tx *sql.Tx, partitionKey string) (int64, error) {
+	f.tx = tx
+	f.last[partitionKey]++
+	return f.last[partitionKey], nil
+}
+
+// beginTx returns a transaction on a mock database, for handing to the
+// publisher.
+func beginTx(t *testing.T) *sql.Tx {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	mock.ExpectBegin()
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	return tx
+}
+
+func TestPublisherWritesOrderCreatedToOutbox(t *testing.T) {
+	ob := &fakeOutbox{}
+	seq := &fakeSequence{last: map[string]int64{}}
+	pub := NewPublisher(ob, seq, true)
+	tx := beginTx(t)
+
+	o := &order.Order{
+		ID:          "order-1",
+		CartID:      "cart-1",
+		UserID:      "user-1",
+		TotalAmount: money.New(1000, "USD"),
+		Items:       []order.Item{{ProductID: "p1", Quantity: 1, Price: money.New(1000, "USD")}},
+	}
+	require.NoError(t, pub.PublishOrderCreated(context.Background(), tx, o, EnvelopeMetadata{CorrelationID: "c1"}))
+
+	assert.Same(t, tx, ob.tx)
+	assert.Same(t, tx, seq.tx)
+	require.Len(t, ob.added, 3)
+	for i, key := range []string{OrderCreatedRoutingKey, OrderCreatedV2RoutingKey, OrderCreatedV3RoutingKey} {
+		assert.Equal(t, key, ob.added[i].RoutingKey)
+		var env EventEnvelope[json.RawMessage]
+		require.NoError(t, json.Unmarshal(ob.added[i].Payload, &env))
+		require.NotNil(t, env.Sequence)
+		assert.Equal(t, int64(i+1), *env.Sequence)
+		assert.Equal(t, "c1", env.CorrelationID)
+	}
+}
+
+func TestPublisherWritesLegacyOrderCompletedToOutbox(t *testing.T) {
+	ob := &fakeOutbox{}
+	pub := NewPublisher(ob, &fakeSequence{last: map[string]int64{}}, false)
+	tx := beginTx(t)
+
+	require.NoError(t, pub.PublishOrderCompleted(context.Background(), tx, "order-1", "user-1", EnvelopeMetadata{}))
+
+	require.Len(t, ob.added, 1)
+	assert.Equal(t, OrderCompletedRoutingKey, ob.added[0].RoutingKey)
+	var ev OrderCompleted
+	require.NoError(t, json.Unmarshal(ob.added[0].Payload, &ev))
+	assert.Equal(t, "OrderCompleted", ev.EventType)
+	assert.Equal(t, "order-1", ev.OrderID)
+}
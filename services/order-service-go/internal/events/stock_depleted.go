@@ -0,0 +1,72 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	stockDepletedEventName    = "StockDepleted"
+	stockDepletedEventVersion = 1
+)
+
+// DepletedLine is a product inventory could not reserve enough of.
+type DepletedLine struct {
+	ProductID string `json:"productId"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+}
+
+// StockLine is a quantity of a product held for an order.
+type StockLine struct {
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
+
+// StockDepletedPayload represents the v1 payload schema. Reserved lists the
+// lines inventory did reserve before running short.
+type StockDepletedPayload struct {
+	OrderID   string         `json:"orderId"`
+	UserID    string         `json:"userId"`
+	Depleted  []DepletedLine `json:"depleted"`
+	Reserved  []StockLine    `json:"reserved,omitempty"`
+	Timestamp time.Time      `json:"timestamp"`
+}
+
+// StockDepleted is the legacy bare event.
+type StockDepleted struct {
+	EventType string `json:"eventType"`
+	StockDepletedPayload
+}
+
+// parseStockDepleted parses an incoming StockDepleted message. If
+// allowEnveloped is true it will first try the envelope format and fall back
+// to the legacy bare payload, as parseCartCheckedOut does.
+func parseStockDepleted(body []byte, allowEnveloped bool) (StockDepletedPayload, *EventEnvelope[json.RawMessage], error) {
+	if allowEnveloped {
+		var env EventEnvelope[json.RawMessage]
+		if err := json.Unmarshal(body, &env); err == nil && env.EventName != "" {
+			if err := env.Validate(stockDepletedEventName, stockDepletedEventVersion); err != nil {
+				return StockDepletedPayload{}, nil, fmt.Errorf("invalid envelope: %w", err)
+			}
+			var payload StockDepletedPayload
+			if err := json.Unmarshal(env.Payload, &payload); err != nil {
+				return StockDepletedPayload{}, nil, fmt.Errorf("invalid payload: %w", err)
+			}
+			if payload.OrderID == "" {
+				return StockDepletedPayload{}, nil, fmt.Errorf("missing orderId")
+			}
+			return payload, &env, nil
+		}
+	}
+
+	var legacy StockDepleted
+	if err := json.Unmarshal(body, &legacy); err != nil {
+		return StockDepletedPayload{}, nil, fmt.Errorf("unmarshal legacy StockDepleted: %w", err)
+	}
+	if legacy.OrderID == "" {
+		return StockDepletedPayload{}, nil, fmt.Errorf("missing orderId")
+	}
+	return legacy.StockDepletedPayload, nil, nil
+}
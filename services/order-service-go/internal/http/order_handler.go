@@ -2,24 +2,73 @@ package http
 
 import (
 	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
+
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/events"
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/money"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/order"
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/problem"
+)
+
+// headerUserID carries the user a request is made for, as set by the gateway.
+const headerUserID = "X-User-Id"
+
+// headerNextCursor carries the cursor of the next page of a listing. It is
+// the cursor of the Link rel="next" URL, for callers that build their own.
+const headerNextCursor = "X-Next-Cursor"
+
+// Page sizes of an order listing.
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
 )
 
+// CancelPublisher publishes OrderCancelled for orders cancelled over HTTP,
+// in the transaction that cancels the order.
+type CancelPublisher interface {
+	PublishOrderCancelled(ctx context.Context, tx *sql.Tx, c *order.Cancellation, reserved []events.StockLine, meta events.EnvelopeMetadata) error
+}
+
 type OrderHandler struct {
-	repo order.Repository
+	repo   order.Repository
+	pub    CancelPublisher
+	logger *log.Logger
+}
+
+func NewOrderHandler(repo order.Repository, pub CancelPublisher, logger *log.Logger) *OrderHandler {
+	return &OrderHandler{repo: repo, pub: pub, logger: logger}
+}
+
+// cancelResponse is the body of a successful cancellation.
+type cancelResponse struct {
+	OrderID     string       `json:"orderId"`
+	Status      order.Status `json:"status"`
+	Reason      string       `json:"reason"`
+	CancelledBy string       `json:"cancelledBy"`
+	CancelledAt time.Time    `json:"cancelledAt"`
 }
 
-func NewOrderHandler(repo order.Repository) *OrderHandler {
-	return &OrderHandler{repo: repo}
+// notCancellableProblem refuses to cancel an order, giving its status.
+type notCancellableProblem struct {
+	problem.Details
+	OrderStatus order.Status `json:"orderStatus"`
 }
 
 func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	orderID := r.PathValue("orderId")
 	if orderID == "" {
-		writeError(w, http.StatusBadRequest, "missing orderId")
+		writeInvalid(w, r, "orderId", problem.FieldRequired, "missing orderId")
 		return
 	}
 
@@ -28,32 +77,246 @@ func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 
 	o, err := h.repo.GetByID(ctx, orderID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to load order")
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to load order")
 		return
 	}
 	if o == nil {
-		writeError(w, http.StatusNotFound, "order not found")
+		writeError(w, r, http.StatusNotFound, codeOrderNotFound, "order not found")
 		return
 	}
 
 	writeJSON(w, http.StatusOK, o)
 }
 
+// GetOrderHistory returns the order's current status and every status change,
+// oldest first.
+func (h *OrderHandler) GetOrderHistory(w http.ResponseWriter, r *http.Request) {
+	orderID := r.PathValue("orderId")
+	if orderID == "" {
+		writeInvalid(w, r, "orderId", problem.FieldRequired, "missing orderId")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	history, err := h.repo.History(ctx, orderID)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to load order history")
+		return
+	}
+	if history == nil {
+		writeError(w, r, http.StatusNotFound, codeOrderNotFound, "order not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, history)
+}
+
+// CancelOrder cancels an order for the user in X-User-Id, who must own it.
+// Orders that completed or were already cancelled are refused. OrderCancelled
+// is published with the cancellation, so inventory releases the stock and
+// payment refunds; if it cannot be written the order is not cancelled.
+func (h *OrderHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	orderID := r.PathValue("orderId")
+	if orderID == "" {
+		writeInvalid(w, r, "orderId", problem.FieldRequired, "missing orderId")
+		return
+	}
+	userID := r.Header.Get(headerUserID)
+	if userID == "" {
+		writeInvalid(w, r, headerUserID, problem.FieldRequired, "missing X-User-Id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	o, err := h.repo.GetByID(ctx, orderID)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to load order")
+		return
+	}
+	// Someone else's order is reported as missing rather than forbidden, so
+	// order IDs cannot be probed.
+	if o == nil || o.UserID != userID {
+		writeError(w, r, http.StatusNotFound, codeOrderNotFound, "order not found")
+		return
+	}
+
+	meta := events.EnvelopeMetadata{CorrelationID: r.Header.Get(problem.HeaderCorrelationID)}
+	cause := order.Cause{Reason: order.CancelReasonCustomerRequested, Actor: userID}
+	c, err := h.repo.MarkCancelled(ctx, orderID, cause, func(tx *sql.Tx, c *order.Cancellation) error {
+		// stock_ok is set once inventory has reserved every line; before
+		// that there is nothing to release.
+		var reserved []events.StockLine
+		if c.StockReserved {
+			for _, it := range o.Items {
+				reserved = append(reserved, events.StockLine{ProductID: it.ProductID, Quantity: it.Quantity})
+			}
+		}
+		return h.pub.PublishOrderCancelled(ctx, tx, c, reserved, meta)
+	})
+	var illegal *order.TransitionError
+	if errors.As(err, &illegal) {
+		problem.Write(w, r, &notCancellableProblem{
+			Details:     problem.New(http.StatusConflict, codeOrderNotCancellable, "order is "+string(illegal.From)+" and can no longer be cancelled"),
+			OrderStatus: illegal.From,
+		})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("cancel order %s for user %s: %v", orderID, userID, err)
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to cancel order")
+		return
+	}
+	if c == nil {
+		writeError(w, r, http.StatusNotFound, codeOrderNotFound, "order not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, cancelResponse{
+		OrderID:     c.OrderID,
+		Status:      order.StatusCancelled,
+		Reason:      c.Reason,
+		CancelledBy: c.CancelledBy,
+		CancelledAt: c.CancelledAt,
+	})
+}
+
 func (h *OrderHandler) ListOrdersByUser(w http.ResponseWriter, r *http.Request) {
 	userID := r.PathValue("userId")
 	if userID == "" {
-		writeError(w, http.StatusBadRequest, "missing userId")
+		writeInvalid(w, r, "userId", problem.FieldRequired, "missing userId")
+		return
+	}
+
+	q := r.URL.Query()
+	filter, fieldErr := parseListFilter(q)
+	if fieldErr != nil {
+		writeInvalid(w, r, fieldErr.Field, fieldErr.Code, fieldErr.Message)
+		return
+	}
+	page, fieldErr := parsePage(q)
+	if fieldErr != nil {
+		writeInvalid(w, r, fieldErr.Field, fieldErr.Code, fieldErr.Message)
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	orders, err := h.repo.ListByUser(ctx, userID)
+	orders, next, err := h.repo.ListByUser(ctx, userID, filter, page)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to load orders")
+		writeError(w, r, http.StatusInternalServerError, problem.CodeInternal, "failed to load orders")
 		return
 	}
 
+	if next != nil {
+		// The next page keeps the filters and limit of this one.
+		cursor := encodeCursor(*next)
+		q := r.URL.Query()
+		q.Set("cursor", cursor)
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, q.Encode()))
+		w.Header().Set(headerNextCursor, cursor)
+	}
 	writeJSON(w, http.StatusOK, orders)
 }
+
+// parseListFilter reads the filters of an order listing from the query.
+// status may be repeated or comma-separated.
+func parseListFilter(q url.Values) (order.ListFilter, *problem.FieldError) {
+	var f order.ListFilter
+	invalid := func(field, msg string) (order.ListFilter, *problem.FieldError) {
+		return f, &problem.FieldError{Field: field, Code: problem.FieldInvalid, Message: msg}
+	}
+	for _, v := range q["status"] {
+		for _, name := range strings.Split(v, ",") {
+			s, ok := order.ParseStatus(strings.TrimSpace(name))
+			if !ok {
+				return invalid("status", fmt.Sprintf("unknown status %q", name))
+			}
+			f.Statuses = append(f.Statuses, s)
+		}
+	}
+	if v := q.Get("createdFrom"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return invalid("createdFrom", "createdFrom must be an RFC 3339 timestamp")
+		}
+		f.CreatedFrom = t
+	}
+	if v := q.Get("createdTo"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return invalid("createdTo", "createdTo must be an RFC 3339 timestamp")
+		}
+		f.CreatedTo = t
+	}
+	if v := q.Get("currency"); v != "" {
+		c := strings.ToUpper(v)
+		if !money.ValidCurrency(c) {
+			return invalid("currency", "currency must be an ISO 4217 code")
+		}
+		f.Currency = c
+	}
+	if v := q.Get("minTotal"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return invalid("minTotal", "minTotal must be a non-negative amount in minor units")
+		}
+		// Minor units of different currencies do not compare.
+		if f.Currency == "" {
+			return f, &problem.FieldError{Field: "currency", Code: problem.FieldRequired, Message: "currency is required with minTotal"}
+		}
+		f.MinTotal = n
+	}
+	return f, nil
+}
+
+// parsePage reads the page of an order listing from the query: limit, the
+// page size, and cursor, from the Link or X-Next-Cursor of the page before.
+func parsePage(q url.Values) (order.Page, *problem.FieldError) {
+	p := order.Page{Limit: defaultListLimit}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxListLimit {
+			return p, &problem.FieldError{Field: "limit", Code: problem.FieldInvalid, Message: fmt.Sprintf("limit must be between 1 and %d", maxListLimit)}
+		}
+		p.Limit = n
+	}
+	if v := q.Get("cursor"); v != "" {
+		c, ok := decodeCursor(v)
+		if !ok {
+			return p, &problem.FieldError{Field: "cursor", Code: problem.FieldInvalid, Message: "invalid cursor"}
+		}
+		p.After = &c
+	}
+	return p, nil
+}
+
+// encodeCursor turns c into the opaque token clients pass back as cursor.
+func encodeCursor(c order.Cursor) string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "," + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(token string) (order.Cursor, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return order.Cursor{}, false
+	}
+	at, id, ok := strings.Cut(string(raw), ",")
+	if !ok {
+		return order.Cursor{}, false
+	}
+	// Order IDs are UUIDs; anything else would only fail in the query.
+	if _, err := uuid.Parse(id); err != nil {
+		return order.Cursor{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return order.Cursor{}, false
+	}
+	return order.Cursor{CreatedAt: t, ID: id}, true
+}
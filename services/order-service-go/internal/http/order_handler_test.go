@@ -5,12 +5,17 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"io"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/events"
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/money"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/order"
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/problem"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -18,11 +23,13 @@ import (
 type fakeRepo struct {
 	createFunc            func(ctx context.Context, o *order.Order) error
 	getByIDFunc           func(ctx context.Context, orderID string) (*order.Order, error)
-	listByUserFunc        func(ctx context.Context, userID string) ([]order.Order, error)
+	listByUserFunc        func(ctx context.Context, userID string, filter order.ListFilter, page order.Page) ([]order.Order, *order.Cursor, error)
 	markPaymentSucceeded  func(ctx context.Context, orderID string) (*order.CompletionState, error)
-	markPaymentFailed     func(ctx context.Context, orderID string, reason string) error
+	markPaymentFailed     func(ctx context.Context, orderID string, cause order.Cause) error
 	markStockReservedFunc func(ctx context.Context, orderID string) (*order.CompletionState, error)
-	markCompletedFunc     func(ctx context.Context, orderID string) error
+	markCompletedFunc     func(ctx context.Context, orderID string, cause order.Cause) error
+	markCancelledFunc     func(ctx context.Context, orderID string, cause order.Cause) (*order.Cancellation, error)
+	historyFunc           func(ctx context.Context, orderID string) (*order.History, error)
 }
 
 func (f *fakeRepo) Create(ctx context.Context, o *order.Order) error {
@@ -43,11 +50,11 @@ func (f *fakeRepo) GetByID(ctx context.Context, orderID string) (*order.Order, e
 	return nil, nil
 }
 
-func (f *fakeRepo) ListByUser(ctx context.Context, userID string) ([]order.Order, error) {
+func (f *fakeRepo) ListByUser(ctx context.Context, userID string, filter order.ListFilter, page order.Page) ([]order.Order, *order.Cursor, error) {
 	if f.listByUserFunc != nil {
-		return f.listByUserFunc(ctx, userID)
+		return f.listByUserFunc(ctx, userID, filter, page)
 	}
-	return nil, nil
+	return nil, nil, nil
 }
 
 func (f *fakeRepo) MarkPaymentSucceeded(ctx context.Context, orderID string) (*order.CompletionState, error) {
@@ -57,9 +64,9 @@ func (f *fakeRepo) MarkPaymentSucceeded(ctx context.Context, orderID string) (*o
 	return nil, nil
 }
 
-func (f *fakeRepo) MarkPaymentFailed(ctx context.Context, orderID string, reason string) error {
+func (f *fakeRepo) MarkPaymentFailed(ctx context.Context, orderID string, cause order.Cause) error {
 	if f.markPaymentFailed != nil {
-		return f.markPaymentFailed(ctx, orderID, reason)
+		return f.markPaymentFailed(ctx, orderID, cause)
 	}
 	return nil
 }
@@ -71,13 +78,50 @@ func (f *fakeRepo) MarkStockReserved(ctx context.Context, orderID string) (*orde
 	return nil, nil
 }
 
-func (f *fakeRepo) MarkCompleted(ctx context.Context, orderID string) error {
+func (f *fakeRepo) MarkCompleted(ctx context.Context, orderID string, cause order.Cause, announce func(tx *sql.Tx) error) error {
 	if f.markCompletedFunc != nil {
-		return f.markCompletedFunc(ctx, orderID)
+		return f.markCompletedFunc(ctx, orderID, cause)
 	}
 	return nil
 }
 
+// MarkCancelled runs announce, without a transaction, on the cancellation
+// markCancelledFunc returns; an error from it fails the cancellation.
+func (f *fakeRepo) MarkCancelled(ctx context.Context, orderID string, cause order.Cause, announce func(tx *sql.Tx, c *order.Cancellation) error) (*order.Cancellation, error) {
+	if f.markCancelledFunc == nil {
+		return nil, nil
+	}
+	c, err := f.markCancelledFunc(ctx, orderID, cause)
+	if err != nil || c == nil || announce == nil {
+		return c, err
+	}
+	if err := announce(nil, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (f *fakeRepo) History(ctx context.Context, orderID string) (*order.History, error) {
+	if f.historyFunc != nil {
+		return f.historyFunc(ctx, orderID)
+	}
+	return nil, nil
+}
+
+type fakeCancelPublisher struct {
+	cancelled []*order.Cancellation
+	reserved  []events.StockLine
+	meta      events.EnvelopeMetadata
+	err       error
+}
+
+func (f *fakeCancelPublisher) PublishOrderCancelled(ctx context.Context, tx *sql.Tx, c *order.Cancellation, reserved []events.StockLine, meta events.EnvelopeMetadata) error {
+	f.cancelled = append(f.cancelled, c)
+	f.reserved = reserved
+	f.meta = meta
+	return f.err
+}
+
 func TestGetOrder_Success(t *testing.T) {
 	repo := &fakeRepo{
 		getByIDFunc: func(ctx context.Context, orderID string) (*order.Order, error) {
@@ -85,12 +129,12 @@ func TestGetOrder_Success(t *testing.T) {
 				ID:          orderID,
 				CartID:      "cart-1",
 				UserID:      "user-1",
-				TotalAmount: 50,
+				TotalAmount: money.New(5000, "USD"),
 				CreatedAt:   time.Unix(0, 0),
 			}, nil
 		},
 	}
-	handler := NewOrderHandler(repo)
+	handler := NewOrderHandler(repo, nil, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/orders/abc", nil)
 	req.SetPathValue("orderId", "abc")
@@ -107,7 +151,7 @@ func TestGetOrder_Success(t *testing.T) {
 }
 
 func TestGetOrder_MissingPathParam(t *testing.T) {
-	handler := NewOrderHandler(&fakeRepo{})
+	handler := NewOrderHandler(&fakeRepo{}, nil, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/orders/", nil)
 	rr := httptest.NewRecorder()
@@ -116,9 +160,10 @@ func TestGetOrder_MissingPathParam(t *testing.T) {
 
 	require.Equal(t, http.StatusBadRequest, rr.Code)
 
-	var resp map[string]string
+	var resp map[string]any
 	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
 	assert.Equal(t, "missing orderId", resp["error"])
+	assert.Equal(t, "validation_failed", resp["code"])
 }
 
 func TestGetOrder_RepositoryError(t *testing.T) {
@@ -127,7 +172,7 @@ func TestGetOrder_RepositoryError(t *testing.T) {
 			return nil, errors.New("db down")
 		},
 	}
-	handler := NewOrderHandler(repo)
+	handler := NewOrderHandler(repo, nil, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/orders/abc", nil)
 	req.SetPathValue("orderId", "abc")
@@ -144,7 +189,7 @@ func TestGetOrder_NotFound(t *testing.T) {
 			return nil, nil
 		},
 	}
-	handler := NewOrderHandler(repo)
+	handler := NewOrderHandler(repo, nil, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/orders/abc", nil)
 	req.SetPathValue("orderId", "abc")
@@ -154,21 +199,66 @@ func TestGetOrder_NotFound(t *testing.T) {
 
 	require.Equal(t, http.StatusNotFound, rr.Code)
 
-	var resp map[string]string
+	assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+
+	var resp map[string]any
 	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
 	assert.Equal(t, "order not found", resp["error"])
+	assert.Equal(t, "order_not_found", resp["code"])
+	assert.Equal(t, "/api/orders/abc", resp["instance"])
+}
+
+func TestGetOrderHistory_Success(t *testing.T) {
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo := &fakeRepo{
+		historyFunc: func(ctx context.Context, orderID string) (*order.History, error) {
+			return &order.History{
+				OrderID: orderID,
+				Status:  order.StatusCancelled,
+				Changes: []order.StatusChange{
+					{From: order.StatusPending, To: order.StatusCancelled, Reason: order.CancelReasonStockDepleted, EventID: "e1", ChangedAt: at},
+				},
+			}, nil
+		},
+	}
+	rr := httptest.NewRecorder()
+
+	NewRouter(repo, nil, nil).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/orders/abc/history", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp order.History
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, "abc", resp.OrderID)
+	assert.Equal(t, order.StatusCancelled, resp.Status)
+	require.Len(t, resp.Changes, 1)
+	assert.Equal(t, "e1", resp.Changes[0].EventID)
+	assert.True(t, at.Equal(resp.Changes[0].ChangedAt))
+}
+
+func TestGetOrderHistory_NotFound(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	NewRouter(&fakeRepo{}, nil, nil).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/orders/abc/history", nil))
+
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	var resp map[string]any
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, "order_not_found", resp["code"])
+	assert.Equal(t, "/api/orders/abc/history", resp["instance"])
 }
 
 func TestListOrdersByUser_Success(t *testing.T) {
 	repo := &fakeRepo{
-		listByUserFunc: func(ctx context.Context, userID string) ([]order.Order, error) {
+		listByUserFunc: func(ctx context.Context, userID string, filter order.ListFilter, page order.Page) ([]order.Order, *order.Cursor, error) {
 			return []order.Order{
 				{ID: "o1", UserID: userID},
 				{ID: "o2", UserID: userID},
-			}, nil
+			}, nil, nil
 		},
 	}
-	handler := NewOrderHandler(repo)
+	handler := NewOrderHandler(repo, nil, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/users/user-123/orders", nil)
 	req.SetPathValue("userId", "user-123")
@@ -185,7 +275,7 @@ func TestListOrdersByUser_Success(t *testing.T) {
 }
 
 func TestListOrdersByUser_MissingUser(t *testing.T) {
-	handler := NewOrderHandler(&fakeRepo{})
+	handler := NewOrderHandler(&fakeRepo{}, nil, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/users//orders", nil)
 	rr := httptest.NewRecorder()
@@ -194,18 +284,146 @@ func TestListOrdersByUser_MissingUser(t *testing.T) {
 
 	require.Equal(t, http.StatusBadRequest, rr.Code)
 
-	var resp map[string]string
+	var resp map[string]any
 	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
 	assert.Equal(t, "missing userId", resp["error"])
 }
 
+func TestListOrdersByUser_StatusFilter(t *testing.T) {
+	var got order.ListFilter
+	repo := &fakeRepo{
+		listByUserFunc: func(ctx context.Context, userID string, filter order.ListFilter, page order.Page) ([]order.Order, *order.Cursor, error) {
+			got = filter
+			return []order.Order{}, nil, nil
+		},
+	}
+	rr := httptest.NewRecorder()
+
+	NewRouter(repo, nil, nil).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/users/user-1/orders?status=pending,stock_reserved&status=completed", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, []order.Status{order.StatusPending, order.StatusStockReserved, order.StatusCompleted}, got.Statuses)
+}
+
+func TestListOrdersByUser_UnknownStatus(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	NewRouter(&fakeRepo{}, nil, nil).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/users/user-1/orders?status=shipped", nil))
+
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	var resp struct {
+		Code   string               `json:"code"`
+		Errors []problem.FieldError `json:"errors"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, "validation_failed", resp.Code)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, problem.FieldError{Field: "status", Code: problem.FieldInvalid, Message: `unknown status "shipped"`}, resp.Errors[0])
+}
+
+func TestListOrdersByUser_Filters(t *testing.T) {
+	var got order.ListFilter
+	repo := &fakeRepo{
+		listByUserFunc: func(ctx context.Context, userID string, filter order.ListFilter, page order.Page) ([]order.Order, *order.Cursor, error) {
+			got = filter
+			return []order.Order{}, nil, nil
+		},
+	}
+	rr := httptest.NewRecorder()
+
+	NewRouter(repo, nil, nil).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/users/user-1/orders?createdFrom=2024-01-01T00:00:00Z&createdTo=2024-02-01T00:00:00Z&currency=usd&minTotal=5000", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), got.CreatedFrom)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), got.CreatedTo)
+	assert.Equal(t, "USD", got.Currency)
+	assert.Equal(t, int64(5000), got.MinTotal)
+}
+
+func TestListOrdersByUser_InvalidQuery(t *testing.T) {
+	for _, tc := range []struct{ query, field string }{
+		{"createdFrom=yesterday", "createdFrom"},
+		{"createdTo=2024-02-01", "createdTo"},
+		{"currency=dollars", "currency"},
+		{"currency=USD&minTotal=-1", "minTotal"},
+		{"minTotal=5000", "currency"},
+		{"limit=0", "limit"},
+		{"limit=101", "limit"},
+		{"cursor=not-a-cursor", "cursor"},
+		{"cursor=" + encodeCursor(order.Cursor{CreatedAt: time.Now(), ID: "o1"}), "cursor"},
+	} {
+		rr := httptest.NewRecorder()
+
+		NewRouter(&fakeRepo{}, nil, nil).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/users/user-1/orders?"+tc.query, nil))
+
+		require.Equal(t, http.StatusBadRequest, rr.Code, tc.query)
+		var resp struct {
+			Errors []problem.FieldError `json:"errors"`
+		}
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, tc.field, resp.Errors[0].Field, tc.query)
+	}
+}
+
+func TestListOrdersByUser_Pages(t *testing.T) {
+	at := time.Date(2024, 1, 1, 12, 0, 0, 123456000, time.UTC)
+	o1 := "6f1c2a3e-8b4d-4f5a-9c6e-7d8e9f0a1b2c"
+	var pages []order.Page
+	repo := &fakeRepo{
+		listByUserFunc: func(ctx context.Context, userID string, filter order.ListFilter, page order.Page) ([]order.Order, *order.Cursor, error) {
+			pages = append(pages, page)
+			if page.After == nil {
+				return []order.Order{{ID: "o2"}, {ID: o1}}, &order.Cursor{CreatedAt: at, ID: o1}, nil
+			}
+			return []order.Order{{ID: "o0"}}, nil, nil
+		},
+	}
+	router := NewRouter(repo, nil, nil)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/users/user-1/orders?limit=2&status=completed", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	cursor := rr.Header().Get("X-Next-Cursor")
+	require.NotEmpty(t, cursor)
+	assert.Equal(t, `</api/users/user-1/orders?cursor=`+cursor+`&limit=2&status=completed>; rel="next"`, rr.Header().Get("Link"))
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/users/user-1/orders?limit=2&status=completed&cursor="+cursor, nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get("Link"))
+	assert.Empty(t, rr.Header().Get("X-Next-Cursor"))
+	require.Len(t, pages, 2)
+	assert.Equal(t, order.Page{Limit: 2}, pages[0])
+	assert.Equal(t, order.Page{Limit: 2, After: &order.Cursor{CreatedAt: at, ID: o1}}, pages[1])
+}
+
+func TestListOrdersByUser_DefaultLimit(t *testing.T) {
+	var got order.Page
+	repo := &fakeRepo{
+		listByUserFunc: func(ctx context.Context, userID string, filter order.ListFilter, page order.Page) ([]order.Order, *order.Cursor, error) {
+			got = page
+			return []order.Order{}, nil, nil
+		},
+	}
+	rr := httptest.NewRecorder()
+
+	NewRouter(repo, nil, nil).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/users/user-1/orders", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, order.Page{Limit: defaultListLimit}, got)
+}
+
 func TestListOrdersByUser_RepositoryError(t *testing.T) {
 	repo := &fakeRepo{
-		listByUserFunc: func(ctx context.Context, userID string) ([]order.Order, error) {
-			return nil, errors.New("oops")
+		listByUserFunc: func(ctx context.Context, userID string, filter order.ListFilter, page order.Page) ([]order.Order, *order.Cursor, error) {
+			return nil, nil, errors.New("oops")
 		},
 	}
-	handler := NewOrderHandler(repo)
+	handler := NewOrderHandler(repo, nil, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/users/user-err/orders", nil)
 	req.SetPathValue("userId", "user-err")
@@ -218,11 +436,11 @@ func TestListOrdersByUser_RepositoryError(t *testing.T) {
 
 func TestListOrdersByUser_EmptyList(t *testing.T) {
 	repo := &fakeRepo{
-		listByUserFunc: func(ctx context.Context, userID string) ([]order.Order, error) {
-			return []order.Order{}, nil
+		listByUserFunc: func(ctx context.Context, userID string, filter order.ListFilter, page order.Page) ([]order.Order, *order.Cursor, error) {
+			return []order.Order{}, nil, nil
 		},
 	}
-	handler := NewOrderHandler(repo)
+	handler := NewOrderHandler(repo, nil, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/users/user-empty/orders", nil)
 	req.SetPathValue("userId", "user-empty")
@@ -237,6 +455,137 @@ func TestListOrdersByUser_EmptyList(t *testing.T) {
 	assert.Empty(t, resp)
 }
 
+// cancelRepo is a repository holding one order of user-1 that can be
+// cancelled, with stock reserved.
+func cancelRepo() *fakeRepo {
+	return &fakeRepo{
+		getByIDFunc: func(ctx context.Context, orderID string) (*order.Order, error) {
+			return &order.Order{
+				ID:          orderID,
+				UserID:      "user-1",
+				TotalAmount: money.New(2500, "USD"),
+				Items:       []order.Item{{ProductID: "p1", Quantity: 2, Price: money.New(1250, "USD")}},
+			}, nil
+		},
+		markCancelledFunc: func(ctx context.Context, orderID string, cause order.Cause) (*order.Cancellation, error) {
+			return &order.Cancellation{
+				OrderID:       orderID,
+				UserID:        "user-1",
+				Reason:        cause.Reason,
+				CancelledBy:   cause.Actor,
+				CancelledAt:   time.Unix(0, 0).UTC(),
+				TotalAmount:   money.New(2500, "USD"),
+				StockReserved: true,
+			}, nil
+		},
+	}
+}
+
+func cancelRequest(userID string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/orders/abc/cancel", nil)
+	if userID != "" {
+		req.Header.Set("X-User-Id", userID)
+	}
+	req.Header.Set("X-Correlation-Id", "cid-1")
+	return req
+}
+
+func TestCancelOrder_Success(t *testing.T) {
+	repo := cancelRepo()
+	var cause order.Cause
+	markCancelled := repo.markCancelledFunc
+	repo.markCancelledFunc = func(ctx context.Context, orderID string, c order.Cause) (*order.Cancellation, error) {
+		cause = c
+		return markCancelled(ctx, orderID, c)
+	}
+	pub := &fakeCancelPublisher{}
+	rr := httptest.NewRecorder()
+
+	NewRouter(repo, pub, log.New(io.Discard, "", 0)).ServeHTTP(rr, cancelRequest("user-1"))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, order.Cause{Reason: order.CancelReasonCustomerRequested, Actor: "user-1"}, cause)
+
+	var resp map[string]any
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, "cancelled", resp["status"])
+	assert.Equal(t, "customer_requested", resp["reason"])
+	assert.Equal(t, "user-1", resp["cancelledBy"])
+
+	require.Len(t, pub.cancelled, 1)
+	assert.Equal(t, []events.StockLine{{ProductID: "p1", Quantity: 2}}, pub.reserved)
+	assert.Equal(t, "cid-1", pub.meta.CorrelationID)
+}
+
+func TestCancelOrder_NothingReservedYet(t *testing.T) {
+	repo := cancelRepo()
+	repo.markCancelledFunc = func(ctx context.Context, orderID string, cause order.Cause) (*order.Cancellation, error) {
+		return &order.Cancellation{OrderID: orderID, UserID: "user-1", Reason: cause.Reason}, nil
+	}
+	pub := &fakeCancelPublisher{}
+	rr := httptest.NewRecorder()
+
+	NewRouter(repo, pub, log.New(io.Discard, "", 0)).ServeHTTP(rr, cancelRequest("user-1"))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Len(t, pub.cancelled, 1)
+	assert.Empty(t, pub.reserved)
+}
+
+func TestCancelOrder_OtherUsersOrder(t *testing.T) {
+	repo := cancelRepo()
+	pub := &fakeCancelPublisher{}
+	rr := httptest.NewRecorder()
+
+	NewRouter(repo, pub, log.New(io.Discard, "", 0)).ServeHTTP(rr, cancelRequest("user-2"))
+
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	var resp map[string]any
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, "order_not_found", resp["code"])
+	assert.Empty(t, pub.cancelled)
+}
+
+func TestCancelOrder_MissingUser(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	NewRouter(cancelRepo(), &fakeCancelPublisher{}, log.New(io.Discard, "", 0)).ServeHTTP(rr, cancelRequest(""))
+
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	var resp map[string]any
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, "validation_failed", resp["code"])
+}
+
+func TestCancelOrder_NotCancellable(t *testing.T) {
+	repo := cancelRepo()
+	repo.markCancelledFunc = func(ctx context.Context, orderID string, cause order.Cause) (*order.Cancellation, error) {
+		return nil, &order.TransitionError{OrderID: orderID, From: order.StatusCompleted, To: order.StatusCancelled}
+	}
+	pub := &fakeCancelPublisher{}
+	rr := httptest.NewRecorder()
+
+	NewRouter(repo, pub, log.New(io.Discard, "", 0)).ServeHTTP(rr, cancelRequest("user-1"))
+
+	require.Equal(t, http.StatusConflict, rr.Code)
+	var resp map[string]any
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, "order_not_cancellable", resp["code"])
+	assert.Equal(t, "completed", resp["orderStatus"])
+	assert.Equal(t, float64(http.StatusConflict), resp["status"])
+	assert.Empty(t, pub.cancelled)
+}
+
+func TestCancelOrder_OutboxFailureFailsCancellation(t *testing.T) {
+	pub := &fakeCancelPublisher{err: errors.New("insert outbox: connection reset")}
+	rr := httptest.NewRecorder()
+
+	NewRouter(cancelRepo(), pub, log.New(io.Discard, "", 0)).ServeHTTP(rr, cancelRequest("user-1"))
+
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.Len(t, pub.cancelled, 1)
+}
+
 func TestHealthHandler(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/health", nil)
 	rr := httptest.NewRecorder()
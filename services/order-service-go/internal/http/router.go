@@ -2,19 +2,23 @@ package http
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/order"
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/problem"
 )
 
-func NewRouter(repo order.Repository) http.Handler {
+func NewRouter(repo order.Repository, pub CancelPublisher, logger *log.Logger) http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/health", healthHandler)
 
-	h := NewOrderHandler(repo)
+	h := NewOrderHandler(repo, pub, logger)
 
 	mux.HandleFunc("GET /api/orders/{orderId}", h.GetOrder)
+	mux.HandleFunc("GET /api/orders/{orderId}/history", h.GetOrderHistory)
+	mux.HandleFunc("POST /api/orders/{orderId}/cancel", h.CancelOrder)
 	mux.HandleFunc("GET /api/users/{userId}/orders", h.ListOrdersByUser)
 
 	return mux
@@ -33,6 +37,20 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 	_ = json.NewEncoder(w).Encode(v)
 }
 
-func writeError(w http.ResponseWriter, status int, msg string) {
-	writeJSON(w, status, map[string]string{"error": msg})
+// Problem codes of the order API; the shared codes live in the problem
+// package.
+const (
+	codeOrderNotFound       = "order_not_found"
+	codeOrderNotCancellable = "order_not_cancellable"
+)
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, msg string) {
+	problem.Error(w, r, status, code, msg)
+}
+
+// writeInvalid reports a missing or invalid path parameter, query parameter,
+// header or body field.
+func writeInvalid(w http.ResponseWriter, r *http.Request, field, code, msg string) {
+	d := problem.Invalid(msg, problem.FieldError{Field: field, Code: code, Message: msg})
+	problem.Write(w, r, &d)
 }
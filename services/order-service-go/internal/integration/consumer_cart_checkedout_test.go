@@ -15,6 +15,7 @@ import (
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/dedup"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/events"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/order"
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/outbox"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/sequence"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/testutil"
 )
@@ -35,11 +36,8 @@ func TestCartCheckedOutConsumer_CreatesOrder(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	_, closePublisher, err := events.StartCartCheckedOutConsumer(ctx, conn, db, repo, dedupRepo, seqRepo, logger, true, true)
+	_, err := events.StartCartCheckedOutConsumer(ctx, conn, db, repo, dedupRepo, outbox.NewRepository(db), seqRepo, logger, true, true)
 	require.NoError(t, err)
-	if closePublisher != nil {
-		t.Cleanup(closePublisher)
-	}
 
 	ch, err := conn.Channel()
 	require.NoError(t, err)
@@ -89,7 +87,7 @@ func TestCartCheckedOutConsumer_CreatesOrder(t *testing.T) {
 	require.NoError(t, err)
 
 	require.Eventually(t, func() bool {
-		orders, err := repo.ListByUser(ctx, event.Payload.UserID)
+		orders, _, err := repo.ListByUser(ctx, event.Payload.UserID, order.ListFilter{}, order.Page{})
 		if err != nil {
 			return false
 		}
@@ -99,7 +97,7 @@ func TestCartCheckedOutConsumer_CreatesOrder(t *testing.T) {
 		return len(orders[0].Items) > 0
 	}, 5*time.Second, 100*time.Millisecond)
 
-	orders, err := repo.ListByUser(ctx, event.Payload.UserID)
+	orders, _, err := repo.ListByUser(ctx, event.Payload.UserID, order.ListFilter{}, order.Page{})
 	require.NoError(t, err)
 	require.Len(t, orders, 1)
 	require.NotEmpty(t, orders[0].Items)
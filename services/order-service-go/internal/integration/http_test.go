@@ -3,6 +3,8 @@ package integration
 import (
 	"context"
 	"encoding/json"
+	"io"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -12,6 +14,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	httpserver "github.com/andreasstove999/ecommerce-system/order-service-go/internal/http"
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/money"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/order"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/testutil"
 )
@@ -29,17 +32,17 @@ func TestGET_OrderByID_Returns200(t *testing.T) {
 	seededOrder := order.Order{
 		CartID:      "cart-http-123",
 		UserID:      "user-http",
-		TotalAmount: 64.75,
+		TotalAmount: money.New(6475, "USD"),
 		CreatedAt:   createdAt,
 		Items: []order.Item{
-			{ProductID: "prod-1", Quantity: 1, Price: 14.75},
-			{ProductID: "prod-2", Quantity: 2, Price: 25.00},
+			{ProductID: "prod-1", Quantity: 1, Price: money.New(1475, "USD")},
+			{ProductID: "prod-2", Quantity: 2, Price: money.New(2500, "USD")},
 		},
 	}
 
 	require.NoError(t, repo.Create(seedCtx, &seededOrder))
 
-	router := httpserver.NewRouter(repo)
+	router := httpserver.NewRouter(repo, nil, log.New(io.Discard, "", 0))
 
 	reqCtx, reqCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer reqCancel()
@@ -67,7 +70,7 @@ func TestGET_OrderByID_NotFound_Returns404(t *testing.T) {
 	t.Cleanup(cleanup)
 
 	repo := order.NewRepository(db)
-	router := httpserver.NewRouter(repo)
+	router := httpserver.NewRouter(repo, nil, log.New(io.Discard, "", 0))
 
 	reqCtx, reqCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer reqCancel()
@@ -101,27 +104,27 @@ func TestGET_ListOrdersByUser_Returns200(t *testing.T) {
 	firstOrder := order.Order{
 		CartID:      "cart-1",
 		UserID:      userID,
-		TotalAmount: 30.00,
+		TotalAmount: money.New(3000, "USD"),
 		CreatedAt:   now.Add(-1 * time.Minute),
 		Items: []order.Item{
-			{ProductID: "prod-a", Quantity: 1, Price: 10.00},
+			{ProductID: "prod-a", Quantity: 1, Price: money.New(1000, "USD")},
 		},
 	}
 
 	secondOrder := order.Order{
 		CartID:      "cart-2",
 		UserID:      userID,
-		TotalAmount: 50.00,
+		TotalAmount: money.New(5000, "USD"),
 		CreatedAt:   now,
 		Items: []order.Item{
-			{ProductID: "prod-b", Quantity: 2, Price: 25.00},
+			{ProductID: "prod-b", Quantity: 2, Price: money.New(2500, "USD")},
 		},
 	}
 
 	require.NoError(t, repo.Create(seedCtx, &firstOrder))
 	require.NoError(t, repo.Create(seedCtx, &secondOrder))
 
-	router := httpserver.NewRouter(repo)
+	router := httpserver.NewRouter(repo, nil, log.New(io.Discard, "", 0))
 
 	reqCtx, reqCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer reqCancel()
@@ -0,0 +1,79 @@
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/outbox"
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/testutil"
+)
+
+func TestOutbox_FailedMessageHoldsBackItsPartition(t *testing.T) {
+	db, cleanup := testutil.StartPostgres(t)
+	t.Cleanup(cleanup)
+	truncateTables(t, db)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	repo := outbox.NewRepository(db)
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, repo.Add(ctx, tx,
+		outbox.Message{PartitionKey: "order-1", RoutingKey: "order.created.v1", Payload: []byte(`{"n":1}`)},
+		outbox.Message{PartitionKey: "order-1", RoutingKey: "order.completed.v1", Payload: []byte(`{"n":2}`)},
+		outbox.Message{PartitionKey: "order-2", RoutingKey: "order.created.v1", Payload: []byte(`{"n":3}`)},
+	))
+	require.NoError(t, tx.Commit())
+
+	pending, err := repo.FetchPending(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	first, other := pending[0], pending[1]
+	require.Equal(t, "order-1", first.PartitionKey)
+	require.Equal(t, "order.created.v1", first.RoutingKey)
+	require.Equal(t, "order-2", other.PartitionKey)
+
+	// A failed send waits for its retry and holds back the rest of order-1.
+	require.NoError(t, repo.MarkFailed(ctx, first.ID, "broker nacked the message", time.Now().Add(time.Hour)))
+	require.NoError(t, repo.MarkPublished(ctx, other.ID))
+
+	pending, err = repo.FetchPending(ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	var (
+		attempts    int
+		lastError   sql.NullString
+		publishedAt sql.NullTime
+	)
+	row := db.QueryRowContext(ctx, `SELECT attempts, last_error, published_at FROM outbox WHERE id = $1`, first.ID)
+	require.NoError(t, row.Scan(&attempts, &lastError, &publishedAt))
+	require.Equal(t, 1, attempts)
+	require.Equal(t, "broker nacked the message", lastError.String)
+	require.False(t, publishedAt.Valid)
+
+	// Once due again it is sent first, then the rest of its partition.
+	require.NoError(t, repo.MarkFailed(ctx, first.ID, "broker nacked the message", time.Now().Add(-time.Second)))
+	pending, err = repo.FetchPending(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, first.ID, pending[0].ID)
+	require.Equal(t, 2, pending[0].Attempts)
+
+	require.NoError(t, repo.MarkPublished(ctx, first.ID))
+	pending, err = repo.FetchPending(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, "order.completed.v1", pending[0].RoutingKey)
+
+	row = db.QueryRowContext(ctx, `SELECT attempts, last_error, published_at FROM outbox WHERE id = $1`, first.ID)
+	require.NoError(t, row.Scan(&attempts, &lastError, &publishedAt))
+	require.False(t, lastError.Valid)
+	require.True(t, publishedAt.Valid)
+}
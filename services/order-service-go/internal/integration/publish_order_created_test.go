@@ -15,6 +15,7 @@ import (
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/dedup"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/events"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/order"
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/outbox"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/sequence"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/testutil"
 )
@@ -32,13 +33,18 @@ func TestCartCheckedOutConsumer_PublishesOrderCreated(t *testing.T) {
 
 	logger := log.New(io.Discard, "", 0)
 
-	publisher, err := events.NewPublisher(conn, seqRepo, true)
+	outboxRepo := outbox.NewRepository(db)
+	publisher := events.NewPublisher(outboxRepo, seqRepo, true)
+
+	sender, err := events.NewOutboxSender(conn)
 	require.NoError(t, err)
-	t.Cleanup(func() { _ = publisher.Close() })
+	t.Cleanup(func() { _ = sender.Close() })
 
 	ctx, cancel := context.WithCancel(context.Background())
 	t.Cleanup(cancel)
 
+	go outbox.NewRelay(outboxRepo, sender, outbox.RelayConfig{PollInterval: 50 * time.Millisecond}, logger).Run(ctx)
+
 	consumer := events.NewConsumer(conn, logger)
 	consumer.Register(events.RoutingCartCheckedOut, events.CartCheckedOutHandler(db, repo, dedupRepo, publisher, logger, true))
 
@@ -3,11 +3,13 @@ package integration
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/money"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/order"
 	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/testutil"
 )
@@ -26,11 +28,11 @@ func TestRepository_CreateAndGetByID(t *testing.T) {
 	orderToCreate := order.Order{
 		CartID:      "cart-123",
 		UserID:      "user-abc",
-		TotalAmount: 42.50,
+		TotalAmount: money.New(4250, "USD"),
 		CreatedAt:   createdAt,
 		Items: []order.Item{
-			{ProductID: "product-1", Quantity: 1, Price: 10.00},
-			{ProductID: "product-2", Quantity: 3, Price: 32.50},
+			{ProductID: "product-1", Quantity: 1, Price: money.New(1000, "USD")},
+			{ProductID: "product-2", Quantity: 3, Price: money.New(3250, "USD")},
 		},
 	}
 
@@ -64,27 +66,27 @@ func TestRepository_ListByUser_ReturnsOrdersWithItems(t *testing.T) {
 	olderOrder := order.Order{
 		CartID:      "cart-old",
 		UserID:      userID,
-		TotalAmount: 15.00,
+		TotalAmount: money.New(1500, "USD"),
 		CreatedAt:   now.Add(-10 * time.Minute),
 		Items: []order.Item{
-			{ProductID: "product-old", Quantity: 2, Price: 7.50},
+			{ProductID: "product-old", Quantity: 2, Price: money.New(750, "USD")},
 		},
 	}
 
 	newerOrder := order.Order{
 		CartID:      "cart-new",
 		UserID:      userID,
-		TotalAmount: 30.00,
+		TotalAmount: money.New(3000, "USD"),
 		CreatedAt:   now,
 		Items: []order.Item{
-			{ProductID: "product-new", Quantity: 1, Price: 30.00},
+			{ProductID: "product-new", Quantity: 1, Price: money.New(3000, "USD")},
 		},
 	}
 
 	require.NoError(t, repo.Create(ctx, &olderOrder))
 	require.NoError(t, repo.Create(ctx, &newerOrder))
 
-	orders, err := repo.ListByUser(ctx, userID)
+	orders, _, err := repo.ListByUser(ctx, userID, order.ListFilter{}, order.Page{})
 	require.NoError(t, err)
 
 	require.Len(t, orders, 2)
@@ -98,12 +100,69 @@ func TestRepository_ListByUser_ReturnsOrdersWithItems(t *testing.T) {
 	require.Equal(t, olderOrder.Items[0], orders[1].Items[0])
 }
 
+func TestRepository_ListByUser_Pages(t *testing.T) {
+	db, cleanup := testutil.StartPostgres(t)
+	t.Cleanup(cleanup)
+	truncateTables(t, db)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	repo := order.NewRepository(db)
+
+	userID := "user-pages"
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	var created []string
+	for i := 0; i < 5; i++ {
+		o := order.Order{
+			CartID:      fmt.Sprintf("cart-page-%d", i),
+			UserID:      userID,
+			TotalAmount: money.New(int64(1000*(i+1)), "USD"),
+			CreatedAt:   now.Add(time.Duration(i) * time.Minute),
+			Items: []order.Item{
+				{ProductID: "product-1", Quantity: 1, Price: money.New(int64(500*(i+1)), "USD")},
+				{ProductID: "product-2", Quantity: 1, Price: money.New(int64(500*(i+1)), "USD")},
+			},
+		}
+		require.NoError(t, repo.Create(ctx, &o))
+		created = append([]string{o.ID}, created...)
+	}
+
+	var listed []string
+	page := order.Page{Limit: 2}
+	for {
+		orders, next, err := repo.ListByUser(ctx, userID, order.ListFilter{}, page)
+		require.NoError(t, err)
+		for _, o := range orders {
+			require.Len(t, o.Items, 2)
+			listed = append(listed, o.ID)
+		}
+		if next == nil {
+			break
+		}
+		page.After = next
+	}
+	require.Equal(t, created, listed)
+
+	filtered, next, err := repo.ListByUser(ctx, userID, order.ListFilter{
+		CreatedFrom: now.Add(time.Minute),
+		CreatedTo:   now.Add(4 * time.Minute),
+		Currency:    "USD",
+		MinTotal:    3000,
+	}, order.Page{})
+	require.NoError(t, err)
+	require.Nil(t, next)
+	require.Len(t, filtered, 2)
+	require.Equal(t, created[1], filtered[0].ID)
+	require.Equal(t, created[2], filtered[1].ID)
+}
+
 func truncateTables(t *testing.T, db *sql.DB) {
 	t.Helper()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := db.ExecContext(ctx, `TRUNCATE order_items, orders, event_sequence, event_dedup_checkpoint`)
+	_, err := db.ExecContext(ctx, `TRUNCATE order_items, orders, event_sequence, event_dedup_checkpoint, outbox`)
 	require.NoError(t, err)
 }
@@ -1,18 +1,93 @@
 package order
 
-import "time"
+import (
+	"time"
+
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/money"
+)
 
 type Item struct {
-	ProductID string  `json:"productId"`
-	Quantity  int     `json:"quantity"`
-	Price     float64 `json:"price"`
+	ProductID string      `json:"productId"`
+	Quantity  int         `json:"quantity"`
+	Price     money.Money `json:"price"`
+}
+
+// TaxLine is the tax charged on one order line, as calculated at checkout.
+// Taxable is the line's net amount after discounts; Inclusive lines had the
+// tax in their price.
+type TaxLine struct {
+	ProductID       string      `json:"productId"`
+	TaxClass        string      `json:"taxClass"`
+	Country         string      `json:"country"`
+	Region          string      `json:"region,omitempty"`
+	RateBasisPoints int         `json:"rateBasisPoints"`
+	Inclusive       bool        `json:"inclusive"`
+	Taxable         money.Money `json:"taxableAmount"`
+	Amount          money.Money `json:"taxAmount"`
+}
+
+// Address is the postal address an order is delivered to. Country is an ISO
+// 3166-1 alpha-2 code.
+type Address struct {
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city"`
+	State      string `json:"state,omitempty"`
+	PostalCode string `json:"postalCode"`
+	Country    string `json:"country"`
+}
+
+// Shipping is where and how an order is delivered, as chosen at checkout.
+type Shipping struct {
+	Address Address `json:"address"`
+	Method  string  `json:"method"`
 }
 
 type Order struct {
-	ID          string    `json:"orderId"`
-	CartID      string    `json:"cartId"`
-	UserID      string    `json:"userId"`
-	Items       []Item    `json:"items"`
-	TotalAmount float64   `json:"totalAmount"`
-	CreatedAt   time.Time `json:"createdAt"`
+	ID     string `json:"orderId"`
+	CartID string `json:"cartId"`
+	UserID string `json:"userId"`
+	Items  []Item `json:"items"`
+	// TotalAmount is what the customer pays, after Discount and with the
+	// tax that was not included in the prices.
+	TotalAmount money.Money `json:"totalAmount"`
+	// Discount is what coupons took off the sum of the items.
+	Discount money.Money `json:"discount"`
+	// Tax is the sum of TaxLines, whether or not it was included in the prices.
+	Tax      money.Money `json:"tax"`
+	TaxLines []TaxLine   `json:"taxLines"`
+	// Shipping is nil for orders checked out without shipping details.
+	Shipping *Shipping `json:"shipping,omitempty"`
+	// Status is where the order is in its lifecycle. PaymentOK and StockOK
+	// are set as payment and inventory confirm the order; it completes once
+	// both are.
+	Status    Status `json:"status"`
+	PaymentOK bool   `json:"paymentOk"`
+	StockOK   bool   `json:"stockOk"`
+	// PaymentError is why payment failed, and CancelReason why the order was
+	// cancelled; both are empty otherwise.
+	PaymentError string    `json:"paymentError,omitempty"`
+	CancelReason string    `json:"cancelReason,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// StatusChange is one entry of an order's status history. EventID is the ID
+// of the event that caused the change, if any; Actor is the user who asked
+// for it, if any.
+type StatusChange struct {
+	From      Status    `json:"from"`
+	To        Status    `json:"to"`
+	Reason    string    `json:"reason,omitempty"`
+	EventID   string    `json:"eventId,omitempty"`
+	Actor     string    `json:"actor,omitempty"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+// History is an order's current status and the changes that led to it,
+// oldest first.
+type History struct {
+	OrderID string         `json:"orderId"`
+	Status  Status         `json:"status"`
+	Changes []StatusChange `json:"changes"`
 }
@@ -5,20 +5,26 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/money"
 )
 
 type Repository interface {
 	Create(ctx context.Context, o *Order) error
 	CreateWithTx(ctx context.Context, tx *sql.Tx, o *Order) error
 	GetByID(ctx context.Context, orderID string) (*Order, error)
-	ListByUser(ctx context.Context, userID string) ([]Order, error)
+	ListByUser(ctx context.Context, userID string, filter ListFilter, page Page) ([]Order, *Cursor, error)
 	MarkPaymentSucceeded(ctx context.Context, orderID string) (*CompletionState, error)
-	MarkPaymentFailed(ctx context.Context, orderID string, reason string) error
+	MarkPaymentFailed(ctx context.Context, orderID string, cause Cause) error
 	MarkStockReserved(ctx context.Context, orderID string) (*CompletionState, error)
-	MarkCompleted(ctx context.Context, orderID string) error
+	MarkCompleted(ctx context.Context, orderID string, cause Cause, announce func(tx *sql.Tx) error) error
+	MarkCancelled(ctx context.Context, orderID string, cause Cause, announce func(tx *sql.Tx, c *Cancellation) error) (*Cancellation, error)
+	History(ctx context.Context, orderID string) (*History, error)
 }
 
 type repo struct {
@@ -41,9 +47,9 @@ func (r *repo) Create(ctx context.Context, o *Order) error {
 	defer tx.Rollback()
 
 	_, err = tx.ExecContext(ctx,
-		`INSERT INTO orders (id, cart_id, user_id, total_amount, created_at)
-         VALUES ($1, $2, $3, $4, $5)`,
-		o.ID, o.CartID, o.UserID, o.TotalAmount, o.CreatedAt,
+		`INSERT INTO orders (id, cart_id, user_id, total_minor, discount_minor, currency, created_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		o.ID, o.CartID, o.UserID, o.TotalAmount.Amount, o.Discount.Amount, o.TotalAmount.Currency, o.CreatedAt,
 	)
 	if err != nil {
 		return fmt.Errorf("insert order: %w", err)
@@ -51,15 +57,22 @@ func (r *repo) Create(ctx context.Context, o *Order) error {
 
 	for _, it := range o.Items {
 		_, err = tx.ExecContext(ctx,
-			`INSERT INTO order_items (id, order_id, product_id, quantity, price)
-             VALUES ($1, $2, $3, $4, $5)`,
-			uuid.NewString(), o.ID, it.ProductID, it.Quantity, it.Price,
+			`INSERT INTO order_items (id, order_id, product_id, quantity, price_minor, currency)
+             VALUES ($1, $2, $3, $4, $5, $6)`,
+			uuid.NewString(), o.ID, it.ProductID, it.Quantity, it.Price.Amount, it.Price.Currency,
 		)
 		if err != nil {
 			return fmt.Errorf("insert order_item: %w", err)
 		}
 	}
 
+	if err := insertTaxLines(ctx, tx, o); err != nil {
+		return err
+	}
+	if err := insertShipping(ctx, tx, o); err != nil {
+		return err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("commit: %w", err)
 	}
@@ -72,9 +85,9 @@ func (r *repo) CreateWithTx(ctx context.Context, tx *sql.Tx, o *Order) error {
 	}
 
 	_, err := tx.ExecContext(ctx,
-		`INSERT INTO orders (id, cart_id, user_id, total_amount, created_at)
-         VALUES ($1, $2, $3, $4, $5)`,
-		o.ID, o.CartID, o.UserID, o.TotalAmount, o.CreatedAt,
+		`INSERT INTO orders (id, cart_id, user_id, total_minor, discount_minor, currency, created_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		o.ID, o.CartID, o.UserID, o.TotalAmount.Amount, o.Discount.Amount, o.TotalAmount.Currency, o.CreatedAt,
 	)
 	if err != nil {
 		return fmt.Errorf("insert order: %w", err)
@@ -82,33 +95,79 @@ func (r *repo) CreateWithTx(ctx context.Context, tx *sql.Tx, o *Order) error {
 
 	for _, it := range o.Items {
 		_, err = tx.ExecContext(ctx,
-			`INSERT INTO order_items (id, order_id, product_id, quantity, price)
-             VALUES ($1, $2, $3, $4, $5)`,
-			uuid.NewString(), o.ID, it.ProductID, it.Quantity, it.Price,
+			`INSERT INTO order_items (id, order_id, product_id, quantity, price_minor, currency)
+             VALUES ($1, $2, $3, $4, $5, $6)`,
+			uuid.NewString(), o.ID, it.ProductID, it.Quantity, it.Price.Amount, it.Price.Currency,
 		)
 		if err != nil {
 			return fmt.Errorf("insert order_item: %w", err)
 		}
 	}
+	if err := insertTaxLines(ctx, tx, o); err != nil {
+		return err
+	}
+	return insertShipping(ctx, tx, o)
+}
+
+func insertTaxLines(ctx context.Context, tx *sql.Tx, o *Order) error {
+	for _, tl := range o.TaxLines {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO order_tax_lines (id, order_id, product_id, tax_class, country, region, rate_bp, inclusive, taxable_minor, tax_minor, currency)
+             VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+			uuid.NewString(), o.ID, tl.ProductID, tl.TaxClass, tl.Country, tl.Region, tl.RateBasisPoints, tl.Inclusive, tl.Taxable.Amount, tl.Amount.Amount, tl.Amount.Currency,
+		)
+		if err != nil {
+			return fmt.Errorf("insert order_tax_line: %w", err)
+		}
+	}
+	return nil
+}
+
+func insertShipping(ctx context.Context, tx *sql.Tx, o *Order) error {
+	if o.Shipping == nil {
+		return nil
+	}
+	a := o.Shipping.Address
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO order_shipping (order_id, method, line1, line2, city, state, postal_code, country)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		o.ID, o.Shipping.Method, a.Line1, a.Line2, a.City, a.State, a.PostalCode, a.Country,
+	)
+	if err != nil {
+		return fmt.Errorf("insert order_shipping: %w", err)
+	}
 	return nil
 }
 
 func (r *repo) GetByID(ctx context.Context, orderID string) (*Order, error) {
-	var o Order
+	var (
+		o            Order
+		paymentError sql.NullString
+		cancelReason sql.NullString
+	)
 	err := r.db.QueryRowContext(ctx,
-		`SELECT id, cart_id, user_id, total_amount, created_at
+		`SELECT id, cart_id, user_id, total_minor, discount_minor, currency, created_at,
+                updated_at, status, payment_ok, stock_ok, payment_error, cancel_reason
          FROM orders WHERE id = $1`,
 		orderID,
-	).Scan(&o.ID, &o.CartID, &o.UserID, &o.TotalAmount, &o.CreatedAt)
+	).Scan(
+		&o.ID, &o.CartID, &o.UserID, &o.TotalAmount.Amount, &o.Discount.Amount, &o.TotalAmount.Currency, &o.CreatedAt,
+		&o.UpdatedAt, &o.Status, &o.PaymentOK, &o.StockOK, &paymentError, &cancelReason,
+	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("select order: %w", err)
 	}
+	o.PaymentError = paymentError.String
+	o.CancelReason = cancelReason.String
+	o.Discount.Currency = o.TotalAmount.Currency
+	o.Tax.Currency = o.TotalAmount.Currency
+	o.TaxLines = []TaxLine{}
 
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT product_id, quantity, price
+		`SELECT product_id, quantity, price_minor, currency
          FROM order_items WHERE order_id = $1`,
 		o.ID,
 	)
@@ -119,7 +178,7 @@ func (r *repo) GetByID(ctx context.Context, orderID string) (*Order, error) {
 
 	for rows.Next() {
 		var it Item
-		if err := rows.Scan(&it.ProductID, &it.Quantity, &it.Price); err != nil {
+		if err := rows.Scan(&it.ProductID, &it.Quantity, &it.Price.Amount, &it.Price.Currency); err != nil {
 			return nil, fmt.Errorf("scan order_item: %w", err)
 		}
 		o.Items = append(o.Items, it)
@@ -128,21 +187,135 @@ func (r *repo) GetByID(ctx context.Context, orderID string) (*Order, error) {
 		return nil, fmt.Errorf("rows: %w", err)
 	}
 
+	taxRows, err := r.db.QueryContext(ctx,
+		`SELECT order_id, product_id, tax_class, country, region, rate_bp, inclusive, taxable_minor, tax_minor, currency
+         FROM order_tax_lines WHERE order_id = $1 ORDER BY product_id`,
+		o.ID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("select order_tax_lines: %w", err)
+	}
+	defer taxRows.Close()
+
+	err = scanTaxLines(taxRows, func(string) *Order { return &o })
+	if err != nil {
+		return nil, err
+	}
+
+	shippingRows, err := r.db.QueryContext(ctx,
+		`SELECT order_id, method, line1, line2, city, state, postal_code, country
+         FROM order_shipping WHERE order_id = $1`,
+		o.ID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("select order_shipping: %w", err)
+	}
+	defer shippingRows.Close()
+
+	err = scanShipping(shippingRows, func(string) *Order { return &o })
+	if err != nil {
+		return nil, err
+	}
 	return &o, nil
 }
 
-func (r *repo) ListByUser(ctx context.Context, userID string) ([]Order, error) {
+// ListFilter narrows the orders ListByUser returns. Empty fields match
+// everything.
+type ListFilter struct {
+	// Statuses keeps orders in any of these statuses.
+	Statuses []Status
+	// CreatedFrom keeps orders created at or after it.
+	CreatedFrom time.Time
+	// CreatedTo keeps orders created before it.
+	CreatedTo time.Time
+	// Currency keeps orders in this ISO 4217 currency.
+	Currency string
+	// MinTotal keeps orders whose total is at least this many minor units.
+	// Minor units only compare within a currency, so it is used together
+	// with Currency.
+	MinTotal int64
+}
+
+// where returns the condition selecting a user's orders that match f, with
+// its arguments. The orders table is aliased o.
+func (f ListFilter) where(userID string) (string, []any) {
+	cond := "o.user_id = $1"
+	args := []any{userID}
+	if len(f.Statuses) > 0 {
+		placeholders := make([]string, len(f.Statuses))
+		for i, s := range f.Statuses {
+			args = append(args, string(s))
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		cond += " AND o.status IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+	if !f.CreatedFrom.IsZero() {
+		args = append(args, f.CreatedFrom)
+		cond += fmt.Sprintf(" AND o.created_at >= $%d", len(args))
+	}
+	if !f.CreatedTo.IsZero() {
+		args = append(args, f.CreatedTo)
+		cond += fmt.Sprintf(" AND o.created_at < $%d", len(args))
+	}
+	if f.Currency != "" {
+		args = append(args, f.Currency)
+		cond += fmt.Sprintf(" AND o.currency = $%d", len(args))
+	}
+	if f.MinTotal > 0 {
+		args = append(args, f.MinTotal)
+		cond += fmt.Sprintf(" AND o.total_minor >= $%d", len(args))
+	}
+	return cond, args
+}
+
+// Cursor is the position of an order in a listing. Orders are listed newest
+// first, ordered by creation time and then ID.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// Page selects a page of a listing: at most Limit orders following After.
+// A zero Limit lists every order; a nil After starts at the newest.
+type Page struct {
+	Limit int
+	After *Cursor
+}
+
+// ListByUser returns a page of the user's orders matching filter, newest
+// first, with the cursor of the next page. The cursor is nil on the last
+// page.
+func (r *repo) ListByUser(ctx context.Context, userID string, filter ListFilter, page Page) ([]Order, *Cursor, error) {
+	where, args := filter.where(userID)
+	if page.After != nil {
+		args = append(args, page.After.CreatedAt, page.After.ID)
+		where += fmt.Sprintf(" AND (o.created_at, o.id) < ($%d, $%d)", len(args)-1, len(args))
+	}
+	limit := ""
+	if page.Limit > 0 {
+		// One more than asked for tells whether there is a next page.
+		args = append(args, page.Limit+1)
+		limit = fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	// The orders are paged before their items are joined, so the limit
+	// counts orders rather than item rows.
 	rows, err := r.db.QueryContext(ctx, `
+		WITH page AS (
+			SELECT o.* FROM orders o
+			WHERE `+where+`
+			ORDER BY o.created_at DESC, o.id DESC`+limit+`
+		)
 		SELECT
-			o.id, o.cart_id, o.user_id, o.total_amount, o.created_at,
-			oi.product_id, oi.quantity, oi.price
-		FROM orders o
+			o.id, o.cart_id, o.user_id, o.total_minor, o.discount_minor, o.currency, o.created_at,
+			o.updated_at, o.status, o.payment_ok, o.stock_ok, o.payment_error, o.cancel_reason,
+			oi.product_id, oi.quantity, oi.price_minor, oi.currency
+		FROM page o
 		LEFT JOIN order_items oi ON oi.order_id = o.id
-		WHERE o.user_id = $1
-		ORDER BY o.created_at DESC, o.id
-	`, userID)
+		ORDER BY o.created_at DESC, o.id DESC
+	`, args...)
 	if err != nil {
-		return nil, fmt.Errorf("select orders+items: %w", err)
+		return nil, nil, fmt.Errorf("select orders+items: %w", err)
 	}
 	defer rows.Close()
 
@@ -151,34 +324,53 @@ func (r *repo) ListByUser(ctx context.Context, userID string) ([]Order, error) {
 
 	for rows.Next() {
 		var (
-			orderID     string
-			cartID      string
-			uID         string
-			totalAmount float64
-			createdAt   time.Time
+			orderID       string
+			cartID        string
+			uID           string
+			totalMinor    int64
+			discountMinor int64
+			currency      string
+			createdAt     time.Time
+			updatedAt     time.Time
+			status        Status
+			paymentOK     bool
+			stockOK       bool
+			paymentError  sql.NullString
+			cancelReason  sql.NullString
 
 			// LEFT JOIN: item columns may be NULL
-			productID sql.NullString
-			qty       sql.NullInt64
-			price     sql.NullFloat64
+			productID     sql.NullString
+			qty           sql.NullInt64
+			priceMinor    sql.NullInt64
+			priceCurrency sql.NullString
 		)
 
 		if err := rows.Scan(
-			&orderID, &cartID, &uID, &totalAmount, &createdAt,
-			&productID, &qty, &price,
+			&orderID, &cartID, &uID, &totalMinor, &discountMinor, &currency, &createdAt,
+			&updatedAt, &status, &paymentOK, &stockOK, &paymentError, &cancelReason,
+			&productID, &qty, &priceMinor, &priceCurrency,
 		); err != nil {
-			return nil, fmt.Errorf("scan orders+items: %w", err)
+			return nil, nil, fmt.Errorf("scan orders+items: %w", err)
 		}
 
 		idx, exists := indexByID[orderID]
 		if !exists {
 			orders = append(orders, Order{
-				ID:          orderID,
-				CartID:      cartID,
-				UserID:      uID,
-				TotalAmount: totalAmount,
-				CreatedAt:   createdAt,
-				Items:       []Item{},
+				ID:           orderID,
+				CartID:       cartID,
+				UserID:       uID,
+				TotalAmount:  money.New(totalMinor, currency),
+				Discount:     money.New(discountMinor, currency),
+				Tax:          money.Zero(currency),
+				TaxLines:     []TaxLine{},
+				Status:       status,
+				PaymentOK:    paymentOK,
+				StockOK:      stockOK,
+				PaymentError: paymentError.String,
+				CancelReason: cancelReason.String,
+				CreatedAt:    createdAt,
+				UpdatedAt:    updatedAt,
+				Items:        []Item{},
 			})
 			idx = len(orders) - 1
 			indexByID[orderID] = idx
@@ -189,16 +381,118 @@ func (r *repo) ListByUser(ctx context.Context, userID string) ([]Order, error) {
 			orders[idx].Items = append(orders[idx].Items, Item{
 				ProductID: productID.String,
 				Quantity:  int(qty.Int64),
-				Price:     price.Float64,
+				Price:     money.New(priceMinor.Int64, priceCurrency.String),
 			})
 		}
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows: %w", err)
+		return nil, nil, fmt.Errorf("rows: %w", err)
+	}
+
+	var next *Cursor
+	if page.Limit > 0 && len(orders) > page.Limit {
+		delete(indexByID, orders[page.Limit].ID)
+		orders = orders[:page.Limit]
+		last := orders[len(orders)-1]
+		next = &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	if len(orders) == 0 {
+		return orders, nil, nil
+	}
+
+	orderIDs := make([]string, len(orders))
+	for i, o := range orders {
+		orderIDs[i] = o.ID
+	}
+
+	taxRows, err := r.db.QueryContext(ctx, `
+		SELECT t.order_id, t.product_id, t.tax_class, t.country, t.region, t.rate_bp, t.inclusive, t.taxable_minor, t.tax_minor, t.currency
+		FROM order_tax_lines t
+		WHERE t.order_id = ANY($1)
+		ORDER BY t.order_id, t.product_id
+	`, pq.Array(orderIDs))
+	if err != nil {
+		return nil, nil, fmt.Errorf("select order_tax_lines: %w", err)
+	}
+	defer taxRows.Close()
+
+	orderFor := func(orderID string) *Order {
+		if idx, ok := indexByID[orderID]; ok {
+			return &orders[idx]
+		}
+		return nil
+	}
+	if err := scanTaxLines(taxRows, orderFor); err != nil {
+		return nil, nil, err
+	}
+
+	shippingRows, err := r.db.QueryContext(ctx, `
+		SELECT s.order_id, s.method, s.line1, s.line2, s.city, s.state, s.postal_code, s.country
+		FROM order_shipping s
+		WHERE s.order_id = ANY($1)
+	`, pq.Array(orderIDs))
+	if err != nil {
+		return nil, nil, fmt.Errorf("select order_shipping: %w", err)
+	}
+	defer shippingRows.Close()
+
+	if err := scanShipping(shippingRows, orderFor); err != nil {
+		return nil, nil, err
+	}
+	return orders, next, nil
+}
+
+// scanTaxLines adds each tax line to the order returned by orderFor and sums
+// up the orders' Tax.
+func scanTaxLines(rows *sql.Rows, orderFor func(orderID string) *Order) error {
+	for rows.Next() {
+		var (
+			orderID string
+			tl      TaxLine
+		)
+		if err := rows.Scan(
+			&orderID, &tl.ProductID, &tl.TaxClass, &tl.Country, &tl.Region, &tl.RateBasisPoints, &tl.Inclusive,
+			&tl.Taxable.Amount, &tl.Amount.Amount, &tl.Amount.Currency,
+		); err != nil {
+			return fmt.Errorf("scan order_tax_line: %w", err)
+		}
+		tl.Taxable.Currency = tl.Amount.Currency
+
+		o := orderFor(orderID)
+		if o == nil {
+			continue
+		}
+		o.TaxLines = append(o.TaxLines, tl)
+		o.Tax.Amount += tl.Amount.Amount
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows: %w", err)
 	}
+	return nil
+}
 
-	return orders, nil
+// scanShipping sets the shipping details of the order returned by orderFor.
+func scanShipping(rows *sql.Rows, orderFor func(orderID string) *Order) error {
+	for rows.Next() {
+		var (
+			orderID string
+			s       Shipping
+		)
+		if err := rows.Scan(
+			&orderID, &s.Method, &s.Address.Line1, &s.Address.Line2, &s.Address.City, &s.Address.State,
+			&s.Address.PostalCode, &s.Address.Country,
+		); err != nil {
+			return fmt.Errorf("scan order_shipping: %w", err)
+		}
+		if o := orderFor(orderID); o != nil {
+			o.Shipping = &s
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows: %w", err)
+	}
+	return nil
 }
 
 type CompletionState struct {
@@ -209,7 +503,8 @@ type CompletionState struct {
 func (r *repo) MarkPaymentSucceeded(ctx context.Context, orderID string) (*CompletionState, error) {
 	_, err := r.db.ExecContext(ctx,
 		`UPDATE orders
-		 SET payment_ok = true
+		 SET payment_ok = true,
+		     updated_at = now()
 		 WHERE id = $1`,
 		orderID,
 	)
@@ -220,25 +515,30 @@ func (r *repo) MarkPaymentSucceeded(ctx context.Context, orderID string) (*Compl
 	return r.completionState(ctx, orderID)
 }
 
-func (r *repo) MarkPaymentFailed(ctx context.Context, orderID string, reason string) error {
-	_, err := r.db.ExecContext(ctx,
-		`UPDATE orders
-		 SET status = 'payment_failed',
-		     payment_ok = false,
-		     payment_error = $2
-		 WHERE id = $1`,
-		orderID, reason,
-	)
-	if err != nil {
-		return fmt.Errorf("update payment_failed: %w", err)
-	}
-	return nil
+// MarkPaymentFailed moves an order to payment_failed, keeping cause.Reason as
+// the payment error.
+func (r *repo) MarkPaymentFailed(ctx context.Context, orderID string, cause Cause) error {
+	_, err := r.changeStatus(ctx, orderID, StatusPaymentFailed, cause, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			`UPDATE orders
+			 SET payment_ok = false,
+			     payment_error = $2
+			 WHERE id = $1`,
+			orderID, cause.Reason,
+		)
+		if err != nil {
+			return fmt.Errorf("update payment_error: %w", err)
+		}
+		return nil
+	})
+	return err
 }
 
 func (r *repo) MarkStockReserved(ctx context.Context, orderID string) (*CompletionState, error) {
 	_, err := r.db.ExecContext(ctx,
 		`UPDATE orders
-		 SET stock_ok = true
+		 SET stock_ok = true,
+		     updated_at = now()
 		 WHERE id = $1`,
 		orderID,
 	)
@@ -248,15 +548,159 @@ func (r *repo) MarkStockReserved(ctx context.Context, orderID string) (*Completi
 	return r.completionState(ctx, orderID)
 }
 
-func (r *repo) MarkCompleted(ctx context.Context, orderID string) error {
-	_, err := r.db.ExecContext(ctx,
-		`UPDATE orders SET status = 'completed' WHERE id = $1`,
+// MarkCompleted completes an order. announce, if set, runs in the same
+// transaction to record the events announcing the completion; an error from
+// it leaves the order as it was.
+func (r *repo) MarkCompleted(ctx context.Context, orderID string, cause Cause, announce func(tx *sql.Tx) error) error {
+	_, err := r.changeStatus(ctx, orderID, StatusCompleted, cause, announce)
+	return err
+}
+
+// Cancellation is an order that was just cancelled, with what is needed to
+// unwind it. StockReserved is set if inventory had reserved every line.
+type Cancellation struct {
+	OrderID       string
+	UserID        string
+	Reason        string
+	CancelledBy   string
+	CancelledAt   time.Time
+	TotalAmount   money.Money
+	StockReserved bool
+}
+
+// MarkCancelled cancels an order, recording cause.Reason as the cancel reason
+// and cause.Actor as who cancelled it. It returns nil if there is no such
+// order, and an error matching ErrIllegalTransition if the order is already
+// completed or cancelled. announce, if set, runs in the same transaction as
+// MarkCompleted's does.
+func (r *repo) MarkCancelled(ctx context.Context, orderID string, cause Cause, announce func(tx *sql.Tx, c *Cancellation) error) (*Cancellation, error) {
+	c := &Cancellation{OrderID: orderID, Reason: cause.Reason, CancelledBy: cause.Actor}
+	found, err := r.changeStatus(ctx, orderID, StatusCancelled, cause, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx,
+			`UPDATE orders
+			 SET cancel_reason = $2,
+			     cancelled_by = $3,
+			     cancelled_at = now()
+			 WHERE id = $1
+			 RETURNING user_id, total_minor, currency, stock_ok, cancelled_at`,
+			orderID, cause.Reason, nullString(cause.Actor),
+		).Scan(&c.UserID, &c.TotalAmount.Amount, &c.TotalAmount.Currency, &c.StockReserved, &c.CancelledAt)
+		if err != nil {
+			return fmt.Errorf("update cancel_reason: %w", err)
+		}
+		if announce != nil {
+			return announce(tx, c)
+		}
+		return nil
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return c, nil
+}
+
+// changeStatus moves an order to status to and records the change in
+// order_status_history, running apply in the same transaction for the columns
+// that go with the new status. It returns false if there is no such order, and
+// a *TransitionError if the order's current status may not change to to.
+func (r *repo) changeStatus(ctx context.Context, orderID string, to Status, cause Cause, apply func(tx *sql.Tx) error) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var from Status
+	err = tx.QueryRowContext(ctx,
+		`SELECT status FROM orders WHERE id = $1 FOR UPDATE`,
 		orderID,
+	).Scan(&from)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("select status: %w", err)
+	}
+	if !from.CanTransitionTo(to) {
+		return false, &TransitionError{OrderID: orderID, From: from, To: to}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE orders SET status = $2, updated_at = now() WHERE id = $1`,
+		orderID, string(to),
 	)
 	if err != nil {
-		return fmt.Errorf("update status completed: %w", err)
+		return false, fmt.Errorf("update status %s: %w", to, err)
 	}
-	return nil
+	if apply != nil {
+		if err := apply(tx); err != nil {
+			return false, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO order_status_history (id, order_id, from_status, to_status, reason, event_id, actor)
+         VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		uuid.NewString(), orderID, string(from), string(to), nullString(cause.Reason), nullString(cause.EventID), nullString(cause.Actor),
+	)
+	if err != nil {
+		return false, fmt.Errorf("insert order_status_history: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit: %w", err)
+	}
+	return true, nil
+}
+
+// History returns an order's status changes, oldest first, or nil if there is
+// no such order.
+func (r *repo) History(ctx context.Context, orderID string) (*History, error) {
+	h := &History{OrderID: orderID, Changes: []StatusChange{}}
+	err := r.db.QueryRowContext(ctx,
+		`SELECT status FROM orders WHERE id = $1`,
+		orderID,
+	).Scan(&h.Status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("select status: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT from_status, to_status, reason, event_id, actor, changed_at
+         FROM order_status_history WHERE order_id = $1 ORDER BY changed_at, id`,
+		orderID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("select order_status_history: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			c       StatusChange
+			reason  sql.NullString
+			eventID sql.NullString
+			actor   sql.NullString
+		)
+		if err := rows.Scan(&c.From, &c.To, &reason, &eventID, &actor, &c.ChangedAt); err != nil {
+			return nil, fmt.Errorf("scan order_status_history: %w", err)
+		}
+		c.Reason = reason.String
+		c.EventID = eventID.String
+		c.Actor = actor.String
+		h.Changes = append(h.Changes, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return h, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
 }
 
 func (r *repo) completionState(ctx context.Context, orderID string) (*CompletionState, error) {
@@ -279,8 +723,8 @@ func (r *repo) completionState(ctx context.Context, orderID string) (*Completion
 		return nil, fmt.Errorf("select completion state: %w", err)
 	}
 
-	// Do not complete failed/cancelled orders
-	if status == "payment_failed" || status == "cancelled" {
+	// Do not complete failed/cancelled orders, nor complete an order twice
+	if status == "payment_failed" || status == "cancelled" || status == "completed" {
 		return &CompletionState{UserID: userID, ReadyToComplete: false}, nil
 	}
 
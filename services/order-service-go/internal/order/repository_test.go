@@ -10,6 +10,8 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/require"
+
+	"github.com/andreasstove999/ecommerce-system/order-service-go/internal/money"
 )
 
 func TestRepositoryCreate_Success(t *testing.T) {
@@ -25,28 +27,28 @@ func TestRepositoryCreate_Success(t *testing.T) {
 		ID:          "order-123",
 		CartID:      "cart-1",
 		UserID:      "user-1",
-		TotalAmount: 25.5,
+		TotalAmount: money.New(2550, "USD"),
 		CreatedAt:   now,
 		Items: []Item{
-			{ProductID: "p1", Quantity: 1, Price: 10.0},
-			{ProductID: "p2", Quantity: 2, Price: 7.75},
+			{ProductID: "p1", Quantity: 1, Price: money.New(1000, "USD")},
+			{ProductID: "p2", Quantity: 2, Price: money.New(775, "USD")},
 		},
 	}
 
 	mock.ExpectBegin()
-	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO orders (id, cart_id, user_id, total_amount, created_at)
-         VALUES ($1, $2, $3, $4, $5)`)).
-		WithArgs(o.ID, o.CartID, o.UserID, o.TotalAmount, o.CreatedAt).
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO orders (id, cart_id, user_id, total_minor, discount_minor, currency, created_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7)`)).
+		WithArgs(o.ID, o.CartID, o.UserID, o.TotalAmount.Amount, o.Discount.Amount, o.TotalAmount.Currency, o.CreatedAt).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO order_items (id, order_id, product_id, quantity, price)
-             VALUES ($1, $2, $3, $4, $5)`)).
-		WithArgs(sqlmock.AnyArg(), o.ID, "p1", 1, 10.0).
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO order_items (id, order_id, product_id, quantity, price_minor, currency)
+             VALUES ($1, $2, $3, $4, $5, $6)`)).
+		WithArgs(sqlmock.AnyArg(), o.ID, "p1", 1, int64(1000), "USD").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO order_items (id, order_id, product_id, quantity, price)
-             VALUES ($1, $2, $3, $4, $5)`)).
-		WithArgs(sqlmock.AnyArg(), o.ID, "p2", 2, 7.75).
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO order_items (id, order_id, product_id, quantity, price_minor, currency)
+             VALUES ($1, $2, $3, $4, $5, $6)`)).
+		WithArgs(sqlmock.AnyArg(), o.ID, "p2", 2, int64(775), "USD").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	mock.ExpectCommit()
@@ -55,6 +57,73 @@ func TestRepositoryCreate_Success(t *testing.T) {
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestRepositoryCreateWithTx_TaxLines(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	o := &Order{
+		ID:          "order-tax",
+		CartID:      "cart-tax",
+		UserID:      "user-tax",
+		TotalAmount: money.New(1089, "USD"),
+		CreatedAt:   time.Now(),
+		TaxLines: []TaxLine{
+			{ProductID: "p1", TaxClass: "standard", Country: "US", Region: "NY", RateBasisPoints: 888, Taxable: money.New(1000, "USD"), Amount: money.New(89, "USD")},
+		},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO orders`)).
+		WithArgs(o.ID, o.CartID, o.UserID, o.TotalAmount.Amount, o.Discount.Amount, o.TotalAmount.Currency, o.CreatedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO order_tax_lines (id, order_id, product_id, tax_class, country, region, rate_bp, inclusive, taxable_minor, tax_minor, currency)`)).
+		WithArgs(sqlmock.AnyArg(), o.ID, "p1", "standard", "US", "NY", 888, false, int64(1000), int64(89), "USD").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateWithTx(ctx, tx, o))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryCreateWithTx_Shipping(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	o := &Order{
+		ID:          "order-ship",
+		CartID:      "cart-ship",
+		UserID:      "user-ship",
+		TotalAmount: money.New(1000, "DKK"),
+		CreatedAt:   time.Now(),
+		Shipping: &Shipping{
+			Address: Address{Line1: "Aaboulevarden 12", City: "Aarhus", PostalCode: "8000", Country: "DK"},
+			Method:  "standard",
+		},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO orders`)).
+		WithArgs(o.ID, o.CartID, o.UserID, o.TotalAmount.Amount, o.Discount.Amount, o.TotalAmount.Currency, o.CreatedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO order_shipping (order_id, method, line1, line2, city, state, postal_code, country)`)).
+		WithArgs(o.ID, "standard", "Aaboulevarden 12", "", "Aarhus", "", "8000", "DK").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateWithTx(ctx, tx, o))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestRepositoryCreate_OrderInsertError(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
@@ -68,14 +137,14 @@ func TestRepositoryCreate_OrderInsertError(t *testing.T) {
 		ID:          "order-err",
 		CartID:      "cart-err",
 		UserID:      "user-err",
-		TotalAmount: 10,
+		TotalAmount: money.New(1000, "USD"),
 		CreatedAt:   now,
 	}
 
 	mock.ExpectBegin()
-	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO orders (id, cart_id, user_id, total_amount, created_at)
-         VALUES ($1, $2, $3, $4, $5)`)).
-		WithArgs(o.ID, o.CartID, o.UserID, o.TotalAmount, o.CreatedAt).
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO orders (id, cart_id, user_id, total_minor, discount_minor, currency, created_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7)`)).
+		WithArgs(o.ID, o.CartID, o.UserID, o.TotalAmount.Amount, o.Discount.Amount, o.TotalAmount.Currency, o.CreatedAt).
 		WillReturnError(errors.New("insert failed"))
 	mock.ExpectRollback()
 
@@ -97,22 +166,22 @@ func TestRepositoryCreate_ItemInsertError(t *testing.T) {
 		ID:          "order-item-err",
 		CartID:      "cart-item",
 		UserID:      "user-item",
-		TotalAmount: 5,
+		TotalAmount: money.New(500, "USD"),
 		CreatedAt:   now,
 		Items: []Item{
-			{ProductID: "p1", Quantity: 1, Price: 5},
+			{ProductID: "p1", Quantity: 1, Price: money.New(500, "USD")},
 		},
 	}
 
 	mock.ExpectBegin()
-	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO orders (id, cart_id, user_id, total_amount, created_at)
-         VALUES ($1, $2, $3, $4, $5)`)).
-		WithArgs(o.ID, o.CartID, o.UserID, o.TotalAmount, o.CreatedAt).
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO orders (id, cart_id, user_id, total_minor, discount_minor, currency, created_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7)`)).
+		WithArgs(o.ID, o.CartID, o.UserID, o.TotalAmount.Amount, o.Discount.Amount, o.TotalAmount.Currency, o.CreatedAt).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO order_items (id, order_id, product_id, quantity, price)
-             VALUES ($1, $2, $3, $4, $5)`)).
-		WithArgs(sqlmock.AnyArg(), o.ID, "p1", 1, 5.0).
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO order_items (id, order_id, product_id, quantity, price_minor, currency)
+             VALUES ($1, $2, $3, $4, $5, $6)`)).
+		WithArgs(sqlmock.AnyArg(), o.ID, "p1", 1, int64(500), "USD").
 		WillReturnError(errors.New("item insert failed"))
 	mock.ExpectRollback()
 
@@ -128,7 +197,8 @@ func TestRepositoryGetByID_NotFound(t *testing.T) {
 
 	repo := NewRepository(db)
 
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, cart_id, user_id, total_amount, created_at
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, cart_id, user_id, total_minor, discount_minor, currency, created_at,
+                updated_at, status, payment_ok, stock_ok, payment_error, cancel_reason
          FROM orders WHERE id = $1`)).
 		WithArgs("missing").
 		WillReturnError(sql.ErrNoRows)
@@ -146,25 +216,310 @@ func TestRepositoryListByUser_EmptyResult(t *testing.T) {
 
 	repo := NewRepository(db)
 
-	rows := sqlmock.NewRows([]string{
-		"id", "cart_id", "user_id", "total_amount", "created_at",
-		"product_id", "quantity", "price",
-	})
+	rows := sqlmock.NewRows(listColumns)
 
 	mock.ExpectQuery(regexp.QuoteMeta(`
+		WITH page AS (
+			SELECT o.* FROM orders o
+			WHERE o.user_id = $1
+			ORDER BY o.created_at DESC, o.id DESC
+		)
 		SELECT
-			o.id, o.cart_id, o.user_id, o.total_amount, o.created_at,
-			oi.product_id, oi.quantity, oi.price
-		FROM orders o
+			o.id, o.cart_id, o.user_id, o.total_minor, o.discount_minor, o.currency, o.created_at,
+			o.updated_at, o.status, o.payment_ok, o.stock_ok, o.payment_error, o.cancel_reason,
+			oi.product_id, oi.quantity, oi.price_minor, oi.currency
+		FROM page o
 		LEFT JOIN order_items oi ON oi.order_id = o.id
-		WHERE o.user_id = $1
-		ORDER BY o.created_at DESC, o.id
+		ORDER BY o.created_at DESC, o.id DESC
 	`)).
 		WithArgs("user-empty").
 		WillReturnRows(rows)
 
-	orders, err := repo.ListByUser(context.Background(), "user-empty")
+	orders, next, err := repo.ListByUser(context.Background(), "user-empty", ListFilter{}, Page{})
 	require.NoError(t, err)
 	require.Empty(t, orders)
+	require.Nil(t, next)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// listColumns are the columns of the ListByUser query.
+var listColumns = []string{
+	"id", "cart_id", "user_id", "total_minor", "discount_minor", "currency", "created_at",
+	"updated_at", "status", "payment_ok", "stock_ok", "payment_error", "cancel_reason",
+	"product_id", "quantity", "price_minor", "currency",
+}
+
+func TestRepositoryListByUser_StatusFilter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`WHERE o.user_id = \$1 AND o.status IN \(\$2, \$3\)\s+ORDER BY o.created_at DESC, o.id DESC\s+\)`).
+		WithArgs("user-1", "payment_failed", "cancelled").
+		WillReturnRows(sqlmock.NewRows(listColumns).
+			AddRow("order-1", "cart-1", "user-1", int64(2500), int64(0), "USD", at, at.Add(time.Minute), "payment_failed", false, true, "declined", nil, "p1", int64(1), int64(2500), "USD"))
+	mock.ExpectQuery(`FROM order_tax_lines t\s+WHERE t.order_id = ANY\(\$1\)`).
+		WithArgs(`{"order-1"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"order_id", "product_id", "tax_class", "country", "region", "rate_bp", "inclusive", "taxable_minor", "tax_minor", "currency"}))
+	mock.ExpectQuery(`FROM order_shipping s\s+WHERE s.order_id = ANY\(\$1\)`).
+		WithArgs(`{"order-1"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"order_id", "method", "line1", "line2", "city", "state", "postal_code", "country"}))
+
+	orders, next, err := repo.ListByUser(context.Background(), "user-1", ListFilter{Statuses: []Status{StatusPaymentFailed, StatusCancelled}}, Page{})
+	require.NoError(t, err)
+	require.Nil(t, next)
+	require.Len(t, orders, 1)
+	o := orders[0]
+	require.Equal(t, StatusPaymentFailed, o.Status)
+	require.False(t, o.PaymentOK)
+	require.True(t, o.StockOK)
+	require.Equal(t, "declined", o.PaymentError)
+	require.Empty(t, o.CancelReason)
+	require.Equal(t, at.Add(time.Minute), o.UpdatedAt)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryListByUser_Page(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	from := at.Add(-24 * time.Hour)
+	after := &Cursor{CreatedAt: at, ID: "order-9"}
+
+	// Two orders are asked for; the third row only shows there is more.
+	mock.ExpectQuery(`WHERE o.user_id = \$1 AND o.created_at >= \$2 AND o.currency = \$3 AND o.total_minor >= \$4 AND \(o.created_at, o.id\) < \(\$5, \$6\)\s+ORDER BY o.created_at DESC, o.id DESC LIMIT \$7\s+\)`).
+		WithArgs("user-1", from, "USD", int64(1000), at, "order-9", 3).
+		WillReturnRows(sqlmock.NewRows(listColumns).
+			AddRow("order-3", "cart-3", "user-1", int64(3000), int64(0), "USD", at.Add(-time.Minute), at, "pending", false, false, nil, nil, "p1", int64(1), int64(1500), "USD").
+			AddRow("order-3", "cart-3", "user-1", int64(3000), int64(0), "USD", at.Add(-time.Minute), at, "pending", false, false, nil, nil, "p2", int64(1), int64(1500), "USD").
+			AddRow("order-2", "cart-2", "user-1", int64(2000), int64(0), "USD", at.Add(-2*time.Minute), at, "completed", true, true, nil, nil, "p1", int64(2), int64(1000), "USD").
+			AddRow("order-1", "cart-1", "user-1", int64(1000), int64(0), "USD", at.Add(-3*time.Minute), at, "completed", true, true, nil, nil, "p1", int64(1), int64(1000), "USD"))
+	mock.ExpectQuery(`FROM order_tax_lines t`).
+		WithArgs(`{"order-3","order-2"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"order_id", "product_id", "tax_class", "country", "region", "rate_bp", "inclusive", "taxable_minor", "tax_minor", "currency"}))
+	mock.ExpectQuery(`FROM order_shipping s`).
+		WithArgs(`{"order-3","order-2"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"order_id", "method", "line1", "line2", "city", "state", "postal_code", "country"}))
+
+	orders, next, err := repo.ListByUser(context.Background(), "user-1", ListFilter{CreatedFrom: from, Currency: "USD", MinTotal: 1000}, Page{Limit: 2, After: after})
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	require.Equal(t, "order-3", orders[0].ID)
+	require.Len(t, orders[0].Items, 2)
+	require.Equal(t, "order-2", orders[1].ID)
+	require.Equal(t, &Cursor{CreatedAt: at.Add(-2 * time.Minute), ID: "order-2"}, next)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expectTransition expects changeStatus to lock an order found in status from.
+func expectTransition(mock sqlmock.Sqlmock, orderID string, from Status) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT status FROM orders WHERE id = $1 FOR UPDATE`)).
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(string(from)))
+}
+
+func TestRepositoryMarkCancelled(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	expectTransition(mock, "order-1", StatusPending)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE orders SET status = $2, updated_at = now() WHERE id = $1`)).
+		WithArgs("order-1", "cancelled").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`UPDATE orders\s+SET cancel_reason = \$2,\s+cancelled_by = \$3,\s+cancelled_at = now\(\)\s+WHERE id = \$1\s+RETURNING user_id, total_minor, currency, stock_ok, cancelled_at`).
+		WithArgs("order-1", CancelReasonStockDepleted, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "total_minor", "currency", "stock_ok", "cancelled_at"}).AddRow("user-1", int64(2500), "USD", false, at))
+	mock.ExpectExec(`INSERT INTO order_status_history`).
+		WithArgs(sqlmock.AnyArg(), "order-1", "pending", "cancelled", CancelReasonStockDepleted, "e1", nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var announced *Cancellation
+	c, err := repo.MarkCancelled(context.Background(), "order-1", Cause{Reason: CancelReasonStockDepleted, EventID: "e1"}, func(tx *sql.Tx, c *Cancellation) error {
+		require.NotNil(t, tx)
+		announced = c
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, &Cancellation{OrderID: "order-1", UserID: "user-1", Reason: CancelReasonStockDepleted, CancelledAt: at, TotalAmount: money.New(2500, "USD")}, c)
+	require.Same(t, c, announced)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryMarkCancelled_AlreadyFinal(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	expectTransition(mock, "order-1", StatusCompleted)
+	mock.ExpectRollback()
+
+	c, err := repo.MarkCancelled(context.Background(), "order-1", Cause{Reason: CancelReasonStockDepleted}, nil)
+	require.ErrorIs(t, err, ErrIllegalTransition)
+	require.Nil(t, c)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryMarkCancelled_UnknownOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status FROM orders`).
+		WithArgs("order-1").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}))
+	mock.ExpectRollback()
+
+	c, err := repo.MarkCancelled(context.Background(), "order-1", Cause{Reason: CancelReasonStockDepleted}, nil)
+	require.NoError(t, err)
+	require.Nil(t, c)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryMarkCompleted_RecordsHistory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	expectTransition(mock, "order-1", StatusPending)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE orders SET status = $2, updated_at = now() WHERE id = $1`)).
+		WithArgs("order-1", "completed").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO order_status_history`).
+		WithArgs(sqlmock.AnyArg(), "order-1", "pending", "completed", "payment succeeded", nil, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	announced := false
+	require.NoError(t, repo.MarkCompleted(context.Background(), "order-1", Cause{Reason: "payment succeeded"}, func(tx *sql.Tx) error {
+		announced = tx != nil
+		return nil
+	}))
+	require.True(t, announced)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryMarkCompleted_AnnounceFails(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	expectTransition(mock, "order-1", StatusStockReserved)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE orders SET status = $2, updated_at = now() WHERE id = $1`)).
+		WithArgs("order-1", "completed").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	err = repo.MarkCompleted(context.Background(), "order-1", Cause{}, func(tx *sql.Tx) error {
+		return errors.New("outbox unavailable")
+	})
+	require.EqualError(t, err, "outbox unavailable")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryMarkCompleted_PaymentFailed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	expectTransition(mock, "order-1", StatusPaymentFailed)
+	mock.ExpectRollback()
+
+	err = repo.MarkCompleted(context.Background(), "order-1", Cause{}, nil)
+	var te *TransitionError
+	require.ErrorAs(t, err, &te)
+	require.Equal(t, &TransitionError{OrderID: "order-1", From: StatusPaymentFailed, To: StatusCompleted}, te)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryMarkPaymentFailed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	expectTransition(mock, "order-1", StatusPending)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE orders SET status = $2, updated_at = now() WHERE id = $1`)).
+		WithArgs("order-1", "payment_failed").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE orders\s+SET payment_ok = false,\s+payment_error = \$2`).
+		WithArgs("order-1", "declined").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO order_status_history`).
+		WithArgs(sqlmock.AnyArg(), "order-1", "pending", "payment_failed", "declined", nil, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.MarkPaymentFailed(context.Background(), "order-1", Cause{Reason: "declined"}))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryHistory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT status FROM orders WHERE id = $1`)).
+		WithArgs("order-1").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("cancelled"))
+	mock.ExpectQuery(`SELECT from_status, to_status, reason, event_id, actor, changed_at\s+FROM order_status_history WHERE order_id = \$1 ORDER BY changed_at, id`).
+		WithArgs("order-1").
+		WillReturnRows(sqlmock.NewRows([]string{"from_status", "to_status", "reason", "event_id", "actor", "changed_at"}).
+			AddRow("pending", "payment_failed", "declined", nil, nil, at).
+			AddRow("payment_failed", "cancelled", CancelReasonCustomerRequested, nil, "user-1", at.Add(time.Minute)))
+
+	h, err := repo.History(context.Background(), "order-1")
+	require.NoError(t, err)
+	require.Equal(t, &History{
+		OrderID: "order-1",
+		Status:  StatusCancelled,
+		Changes: []StatusChange{
+			{From: StatusPending, To: StatusPaymentFailed, Reason: "declined", ChangedAt: at},
+			{From: StatusPaymentFailed, To: StatusCancelled, Reason: CancelReasonCustomerRequested, Actor: "user-1", ChangedAt: at.Add(time.Minute)},
+		},
+	}, h)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryHistory_UnknownOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectQuery(`SELECT status FROM orders`).
+		WithArgs("order-1").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}))
+
+	h, err := repo.History(context.Background(), "order-1")
+	require.NoError(t, err)
+	require.Nil(t, h)
 	require.NoError(t, mock.ExpectationsWereMet())
 }
@@ -1,5 +1,10 @@
 package order
 
+import (
+	"errors"
+	"fmt"
+)
+
 type Status string
 
 const (
@@ -10,3 +15,69 @@ const (
 	StatusCompleted     Status = "completed"
 	StatusCancelled     Status = "cancelled"
 )
+
+// Reasons an order is cancelled for.
+const (
+	// CancelReasonStockDepleted: inventory could not reserve every line.
+	CancelReasonStockDepleted = "stock_depleted"
+	// CancelReasonCustomerRequested: the customer cancelled the order.
+	CancelReasonCustomerRequested = "customer_requested"
+)
+
+// transitions lists the statuses each status may change to. Completed and
+// cancelled orders are final.
+var transitions = map[Status][]Status{
+	StatusPending:       {StatusStockReserved, StatusPaymentFailed, StatusCompleted, StatusCancelled},
+	StatusStockReserved: {StatusPaymentFailed, StatusCompleted, StatusCancelled},
+	StatusPaymentFailed: {StatusCancelled},
+	StatusCompleted:     {},
+	StatusCancelled:     {},
+}
+
+// ParseStatus returns the status named s, or false if there is none.
+func ParseStatus(s string) (Status, bool) {
+	st := Status(s)
+	_, ok := transitions[st]
+	return st, ok
+}
+
+// CanTransitionTo reports whether an order in status s may change to next.
+func (s Status) CanTransitionTo(next Status) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal reports whether an order in status s can no longer change.
+func (s Status) IsFinal() bool {
+	return len(transitions[s]) == 0
+}
+
+// ErrIllegalTransition is matched by the errors returned for a status change
+// the state machine does not allow.
+var ErrIllegalTransition = errors.New("illegal order status transition")
+
+// TransitionError is a status change that was refused.
+type TransitionError struct {
+	OrderID string
+	From    Status
+	To      Status
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("order %s cannot change from %s to %s", e.OrderID, e.From, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool { return target == ErrIllegalTransition }
+
+// Cause is what made an order change status: a reason for people, the ID of
+// the event that triggered it, when there is one, and the actor. Actor is the
+// user who asked for the change, or empty when the service made it.
+type Cause struct {
+	Reason  string
+	EventID string
+	Actor   string
+}
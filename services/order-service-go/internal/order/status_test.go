@@ -0,0 +1,30 @@
+package order
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusTransitions(t *testing.T) {
+	cases := []struct {
+		from, to Status
+		allowed  bool
+	}{
+		{StatusPending, StatusCompleted, true},
+		{StatusPending, StatusCancelled, true},
+		{StatusStockReserved, StatusPaymentFailed, true},
+		{StatusPaymentFailed, StatusCancelled, true},
+		{StatusPaymentFailed, StatusCompleted, false},
+		{StatusCompleted, StatusCancelled, false},
+		{StatusCancelled, StatusCancelled, false},
+		{StatusPending, StatusPending, false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.allowed, c.from.CanTransitionTo(c.to), "%s -> %s", c.from, c.to)
+	}
+
+	assert.True(t, StatusCompleted.IsFinal())
+	assert.True(t, StatusCancelled.IsFinal())
+	assert.False(t, StatusPaymentFailed.IsFinal())
+}
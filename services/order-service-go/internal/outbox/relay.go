@@ -0,0 +1,163 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Sender sends outbox messages to the broker. Send returns once the broker
+// has confirmed the message.
+type Sender interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// RelayConfig controls polling, retry backoff and cleanup of the relay.
+type RelayConfig struct {
+	PollInterval    time.Duration
+	BatchSize       int
+	InitialBackoff  time.Duration
+	MaxBackoff      time.Duration
+	Retention       time.Duration
+	CleanupInterval time.Duration
+}
+
+// DefaultRelayConfig returns the settings used when nothing is configured.
+func DefaultRelayConfig() RelayConfig {
+	return RelayConfig{
+		PollInterval:    500 * time.Millisecond,
+		BatchSize:       100,
+		InitialBackoff:  time.Second,
+		MaxBackoff:      5 * time.Minute,
+		Retention:       7 * 24 * time.Hour,
+		CleanupInterval: time.Hour,
+	}
+}
+
+// Relay sends the messages written to the outbox and marks them published.
+// Delivery is at least once: a crash between sending and MarkPublished, or
+// more than one instance relaying, can send a message twice. Consumers
+// deduplicate on the envelope's event ID.
+type Relay struct {
+	repo   Repository
+	sender Sender
+	cfg    RelayConfig
+	logger *log.Logger
+	now    func() time.Time
+}
+
+// NewRelay creates a relay. Zero fields of cfg take their defaults.
+func NewRelay(repo Repository, sender Sender, cfg RelayConfig, logger *log.Logger) *Relay {
+	def := DefaultRelayConfig()
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = def.PollInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = def.BatchSize
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = def.InitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = def.MaxBackoff
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = def.Retention
+	}
+	if cfg.CleanupInterval <= 0 {
+		cfg.CleanupInterval = def.CleanupInterval
+	}
+	return &Relay{repo: repo, sender: sender, cfg: cfg, logger: logger, now: time.Now}
+}
+
+// Run relays until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	poll := time.NewTicker(r.cfg.PollInterval)
+	defer poll.Stop()
+	cleanup := time.NewTicker(r.cfg.CleanupInterval)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+			r.Drain(ctx)
+		case <-cleanup.C:
+			r.Cleanup(ctx)
+		}
+	}
+}
+
+// Drain sends batches until no more messages are due.
+func (r *Relay) Drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		sent, err := r.SendPending(ctx)
+		if err != nil {
+			r.logger.Printf("outbox relay: %v", err)
+			return
+		}
+		if sent == 0 {
+			return
+		}
+	}
+}
+
+// SendPending sends one batch of due messages and returns how many were sent.
+// A failed send is retried after a backoff that doubles per attempt, and
+// holds back the rest of its partition until then.
+func (r *Relay) SendPending(ctx context.Context) (int, error) {
+	msgs, err := r.repo.FetchPending(ctx, r.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	blocked := make(map[string]bool)
+	for _, m := range msgs {
+		if blocked[m.PartitionKey] {
+			continue
+		}
+
+		if err := r.sender.Send(ctx, m); err != nil {
+			blocked[m.PartitionKey] = true
+			next := r.now().Add(r.backoff(m.Attempts + 1))
+			r.logger.Printf("outbox relay: send id=%d partition=%s %s attempt=%d failed: %v",
+				m.ID, m.PartitionKey, m.RoutingKey, m.Attempts+1, err)
+			if markErr := r.repo.MarkFailed(ctx, m.ID, err.Error(), next); markErr != nil {
+				return sent, markErr
+			}
+			continue
+		}
+
+		if err := r.repo.MarkPublished(ctx, m.ID); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// Cleanup deletes messages published longer ago than the retention.
+func (r *Relay) Cleanup(ctx context.Context) {
+	n, err := r.repo.DeletePublishedBefore(ctx, r.now().Add(-r.cfg.Retention))
+	if err != nil {
+		r.logger.Printf("outbox relay: cleanup: %v", err)
+		return
+	}
+	if n > 0 {
+		r.logger.Printf("outbox relay: removed %d published messages", n)
+	}
+}
+
+// backoff doubles the delay per attempt, capped at MaxBackoff.
+func (r *Relay) backoff(attempt int) time.Duration {
+	d := r.cfg.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= r.cfg.MaxBackoff {
+			return r.cfg.MaxBackoff
+		}
+	}
+	return d
+}
@@ -0,0 +1,167 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRepo keeps messages in memory, handing out the oldest pending, due
+// message of each partition like the Postgres repository.
+type fakeRepo struct {
+	mu        sync.Mutex
+	msgs      []Message
+	published map[int64]bool
+	failed    map[int64]time.Time
+	deleted   []time.Time
+}
+
+func newFakeRepo(msgs ...Message) *fakeRepo {
+	return &fakeRepo{msgs: msgs, published: map[int64]bool{}, failed: map[int64]time.Time{}}
+}
+
+func (f *fakeRepo) Add(ctx context.Context, tx *sql.Tx, msgs ...Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.msgs = append(f.msgs, msgs...)
+	return nil
+}
+
+func (f *fakeRepo) FetchPending(ctx context.Context, limit int) ([]Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []Message
+	seen := map[string]bool{}
+	for _, m := range f.msgs {
+		if f.published[m.ID] || seen[m.PartitionKey] {
+			continue
+		}
+		seen[m.PartitionKey] = true
+		if next, ok := f.failed[m.ID]; ok && next.After(time.Now()) {
+			continue
+		}
+		if len(out) < limit {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeRepo) MarkPublished(ctx context.Context, id int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.published[id] = true
+	return nil
+}
+
+func (f *fakeRepo) MarkFailed(ctx context.Context, id int64, reason string, nextAttempt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failed[id] = nextAttempt
+	for i := range f.msgs {
+		if f.msgs[i].ID == id {
+			f.msgs[i].Attempts++
+		}
+	}
+	return nil
+}
+
+func (f *fakeRepo) DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleted = append(f.deleted, before)
+	return 0, nil
+}
+
+func (f *fakeRepo) pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.msgs) - len(f.published)
+}
+
+// fakeSender records what it sends and fails the IDs in fail.
+type fakeSender struct {
+	mu   sync.Mutex
+	sent []int64
+	fail map[int64]bool
+}
+
+func (f *fakeSender) Send(ctx context.Context, m Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail[m.ID] {
+		return errors.New("broker nacked the message")
+	}
+	f.sent = append(f.sent, m.ID)
+	return nil
+}
+
+func TestRelayDrain(t *testing.T) {
+	repo := newFakeRepo(
+		Message{ID: 1, PartitionKey: "order-1"},
+		Message{ID: 2, PartitionKey: "order-2"},
+		Message{ID: 3, PartitionKey: "order-1"},
+	)
+	sender := &fakeSender{}
+	relay := NewRelay(repo, sender, RelayConfig{BatchSize: 1}, log.New(io.Discard, "", 0))
+
+	relay.Drain(context.Background())
+
+	require.Equal(t, []int64{1, 2, 3}, sender.sent)
+	require.Zero(t, repo.pending())
+}
+
+func TestRelaySendPending_FailureHoldsBackItsPartition(t *testing.T) {
+	repo := newFakeRepo(
+		Message{ID: 1, PartitionKey: "order-1"},
+		Message{ID: 2, PartitionKey: "order-2"},
+		Message{ID: 3, PartitionKey: "order-1"},
+	)
+	sender := &fakeSender{fail: map[int64]bool{1: true}}
+	relay := NewRelay(repo, sender, RelayConfig{}, log.New(io.Discard, "", 0))
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	relay.now = func() time.Time { return now }
+
+	n, err := relay.SendPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, []int64{2}, sender.sent, "order-1 waits for its first message")
+	require.Equal(t, now.Add(time.Second), repo.failed[1])
+}
+
+func TestRelayBackoff(t *testing.T) {
+	relay := NewRelay(newFakeRepo(), &fakeSender{}, RelayConfig{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}, log.New(io.Discard, "", 0))
+
+	require.Equal(t, time.Second, relay.backoff(1))
+	require.Equal(t, 2*time.Second, relay.backoff(2))
+	require.Equal(t, 4*time.Second, relay.backoff(3))
+	require.Equal(t, 5*time.Second, relay.backoff(4))
+	require.Equal(t, 5*time.Second, relay.backoff(10))
+}
+
+func TestRelayRun(t *testing.T) {
+	repo := newFakeRepo(Message{ID: 1, PartitionKey: "order-1"}, Message{ID: 2, PartitionKey: "order-1"})
+	relay := NewRelay(repo, &fakeSender{}, RelayConfig{PollInterval: time.Millisecond, CleanupInterval: time.Millisecond}, log.New(io.Discard, "", 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+		return len(repo.published) == 2 && len(repo.deleted) > 0
+	}, time.Second, time.Millisecond)
+	cancel()
+	<-done
+}
@@ -0,0 +1,130 @@
+// Package outbox stores events to publish next to the changes they announce,
+// and relays them to the broker once those changes are committed.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Message is an event in the outbox. Messages with the same PartitionKey,
+// the order they are about, are sent in the order they were added.
+type Message struct {
+	ID           int64
+	PartitionKey string
+	RoutingKey   string
+	Payload      []byte
+	Attempts     int
+	CreatedAt    time.Time
+}
+
+// Repository manages the outbox table.
+type Repository interface {
+	// Add writes messages in tx, so they are only sent if tx commits.
+	Add(ctx context.Context, tx *sql.Tx, msgs ...Message) error
+	// FetchPending returns due, unpublished messages in insertion order. Only
+	// the oldest unpublished message of each partition is returned, so a
+	// failing message holds back the rest of its partition.
+	FetchPending(ctx context.Context, limit int) ([]Message, error)
+	// MarkPublished records that the message was sent.
+	MarkPublished(ctx context.Context, id int64) error
+	// MarkFailed records a failed send and when to try the message again.
+	MarkFailed(ctx context.Context, id int64, reason string, nextAttempt time.Time) error
+	// DeletePublishedBefore deletes messages published before the given time.
+	DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type repo struct {
+	db *sql.DB
+}
+
+// NewRepository creates an outbox repository.
+func NewRepository(db *sql.DB) Repository {
+	return &repo{db: db}
+}
+
+func (r *repo) Add(ctx context.Context, tx *sql.Tx, msgs ...Message) error {
+	for _, m := range msgs {
+		if m.PartitionKey == "" {
+			return fmt.Errorf("outbox %s: partition key is required", m.RoutingKey)
+		}
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO outbox (partition_key, routing_key, payload) VALUES ($1, $2, $3)`,
+			m.PartitionKey, m.RoutingKey, m.Payload,
+		)
+		if err != nil {
+			return fmt.Errorf("insert outbox %s: %w", m.RoutingKey, err)
+		}
+	}
+	return nil
+}
+
+func (r *repo) FetchPending(ctx context.Context, limit int) ([]Message, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT o.id, o.partition_key, o.routing_key, o.payload, o.attempts, o.created_at
+         FROM outbox o
+         WHERE o.published_at IS NULL
+           AND o.next_attempt_at <= now()
+           AND NOT EXISTS (
+               SELECT 1 FROM outbox p
+               WHERE p.partition_key = o.partition_key
+                 AND p.published_at IS NULL
+                 AND p.id < o.id
+           )
+         ORDER BY o.id
+         LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("select outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var msgs []Message
+	for rows.Next() {
+		var m Message
+		if err := rows.Scan(&m.ID, &m.PartitionKey, &m.RoutingKey, &m.Payload, &m.Attempts, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan outbox: %w", err)
+		}
+		msgs = append(msgs, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return msgs, nil
+}
+
+func (r *repo) MarkPublished(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE outbox SET last_error = NULL, published_at = now() WHERE id = $1`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("mark outbox %d published: %w", id, err)
+	}
+	return nil
+}
+
+func (r *repo) MarkFailed(ctx context.Context, id int64, reason string, nextAttempt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE outbox SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $1`,
+		id, reason, nextAttempt,
+	)
+	if err != nil {
+		return fmt.Errorf("mark outbox %d failed: %w", id, err)
+	}
+	return nil
+}
+
+func (r *repo) DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM outbox WHERE published_at IS NOT NULL AND published_at < $1`,
+		before,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete published outbox: %w", err)
+	}
+	return res.RowsAffected()
+}
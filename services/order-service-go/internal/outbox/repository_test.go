@@ -0,0 +1,110 @@
+package outbox
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+var outboxColumns = []string{"id", "partition_key", "routing_key", "payload", "attempts", "created_at"}
+
+func TestRepositoryAdd(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO outbox (partition_key, routing_key, payload) VALUES ($1, $2, $3)`)).
+		WithArgs("order-1", "order.created.v1", []byte(`{"a":1}`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO outbox (partition_key, routing_key, payload) VALUES ($1, $2, $3)`)).
+		WithArgs("order-1", "order.created.v2", []byte(`{"a":2}`)).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, repo.Add(context.Background(), tx,
+		Message{PartitionKey: "order-1", RoutingKey: "order.created.v1", Payload: []byte(`{"a":1}`)},
+		Message{PartitionKey: "order-1", RoutingKey: "order.created.v2", Payload: []byte(`{"a":2}`)},
+	))
+	require.NoError(t, tx.Commit())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryAdd_RequiresPartitionKey(t *testing.T) {
+	err := NewRepository(nil).Add(context.Background(), nil, Message{RoutingKey: "order.created.v1"})
+	require.ErrorContains(t, err, "partition key is required")
+}
+
+func TestRepositoryFetchPending(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`FROM outbox o\s+WHERE o.published_at IS NULL\s+AND o.next_attempt_at <= now\(\)\s+AND NOT EXISTS`).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows(outboxColumns).
+			AddRow(int64(1), "order-1", "order.created.v1", []byte(`{}`), 0, at).
+			AddRow(int64(4), "order-2", "order.completed.v1", []byte(`{}`), 2, at))
+
+	msgs, err := repo.FetchPending(context.Background(), 10)
+	require.NoError(t, err)
+	require.Equal(t, []Message{
+		{ID: 1, PartitionKey: "order-1", RoutingKey: "order.created.v1", Payload: []byte(`{}`), CreatedAt: at},
+		{ID: 4, PartitionKey: "order-2", RoutingKey: "order.completed.v1", Payload: []byte(`{}`), Attempts: 2, CreatedAt: at},
+	}, msgs)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryMarkPublished(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE outbox SET last_error = NULL, published_at = now() WHERE id = $1`)).
+		WithArgs(int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, NewRepository(db).MarkPublished(context.Background(), 7))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryMarkFailed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	next := time.Date(2024, 1, 1, 12, 0, 2, 0, time.UTC)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE outbox SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $1`)).
+		WithArgs(int64(7), "broker nacked the message", next).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, NewRepository(db).MarkFailed(context.Background(), 7, "broker nacked the message", next))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryDeletePublishedBefore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	before := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM outbox WHERE published_at IS NOT NULL AND published_at < $1`)).
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	n, err := NewRepository(db).DeletePublishedBefore(context.Background(), before)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), n)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
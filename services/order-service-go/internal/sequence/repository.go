@@ -6,9 +6,11 @@ import (
 	"fmt"
 )
 
-// Repository manages producer-side sequences for events.
+// Repository manages producer-side sequences for events. Sequences are taken
+// in the transaction that writes the event, so a rolled back event leaves no
+// gap.
 type Repository interface {
-	NextSequence(ctx context.Context, partitionKey string) (int64, error)
+	NextSequence(ctx context.Context, tx *sql.Tx, partitionKey string) (int64, error)
 }
 
 type repo struct {
@@ -20,9 +22,9 @@ func NewRepository(db *sql.DB) Repository {
 	return &repo{db: db}
 }
 
-func (r *repo) NextSequence(ctx context.Context, partitionKey string) (int64, error) {
+func (r *repo) NextSequence(ctx context.Context, tx *sql.Tx, partitionKey string) (int64, error) {
 	var seq int64
-	if err := r.db.QueryRowContext(ctx, `
+	if err := tx.QueryRowContext(ctx, `
 		INSERT INTO event_sequence (partition_key, last_sequence, updated_at)
 		VALUES ($1, 1, NOW())
 		ON CONFLICT (partition_key)